@@ -2,9 +2,13 @@
 package analysis
 
 import (
+	"context"
 	"fmt"
+	"maps"
+	"slices"
 	"strings"
 
+	"github.com/aezell/agrev/internal/config"
 	"github.com/aezell/agrev/internal/diff"
 	"github.com/aezell/agrev/internal/model"
 )
@@ -12,11 +16,14 @@ import (
 // Finding represents a single analysis finding attached to a file and line range.
 type Finding struct {
 	Pass     string // which analysis pass produced this
+	Rule     string // the ID of the rule it reports, e.g. "AGV-SEC-003"
 	File     string
 	Line     int    // primary line number (in new file), 0 if file-level
+	Scope    string // the function or type the line is in, if known
 	Message  string
 	Severity model.Severity
 	Risk     model.RiskLevel
+	State    model.FindingState // what reviewers concluded about it in earlier runs
 }
 
 func (f Finding) String() string {
@@ -24,12 +31,35 @@ func (f Finding) String() string {
 	if f.Line > 0 {
 		loc = fmt.Sprintf("%s:%d", f.File, f.Line)
 	}
+	if f.Scope != "" {
+		loc += " (in " + f.Scope + ")"
+	}
 	return fmt.Sprintf("[%s] %s: %s", f.Pass, loc, f.Message)
 }
 
+// DocsURL returns the documentation of the finding's rule, or "" for a
+// finding without a built-in rule.
+func (f Finding) DocsURL() string {
+	r, ok := LookupRule(f.Rule)
+	if !ok {
+		return ""
+	}
+	return r.URL()
+}
+
+// Fingerprint identifies the finding across runs. It leaves out the line
+// number, so a finding keeps its fingerprint when lines above it move.
+func (f Finding) Fingerprint() string {
+	return hashBlock([]string{f.Pass, f.File, f.Scope, f.Message})
+}
+
 // Results holds all findings from running analysis passes.
 type Results struct {
 	Findings []Finding
+
+	// Suppressed holds the findings reviewers marked as false positives,
+	// left out of Findings.
+	Suppressed []Finding
 }
 
 // ByFile returns findings grouped by file path.
@@ -64,9 +94,14 @@ func (r *Results) MaxRisk() model.RiskLevel {
 }
 
 // Summary returns a one-line summary of findings.
+// False positives suppressed by reviewers are counted at the end.
 func (r *Results) Summary() string {
+	suppressed := ""
+	if n := len(r.Suppressed); n > 0 {
+		suppressed = fmt.Sprintf(" (%d false positive(s) suppressed)", n)
+	}
 	if len(r.Findings) == 0 {
-		return "No issues found"
+		return "No issues found" + suppressed
 	}
 
 	counts := make(map[model.RiskLevel]int)
@@ -80,7 +115,7 @@ func (r *Results) Summary() string {
 			parts = append(parts, fmt.Sprintf("%d %s", c, level))
 		}
 	}
-	return strings.Join(parts, ", ")
+	return strings.Join(parts, ", ") + suppressed
 }
 
 // Pass is a function that analyzes a diff and returns findings.
@@ -95,6 +130,12 @@ func AllPasses() []Pass {
 		SchemaChangePass,
 		AntiPatternPass,
 		BlastRadiusPass,
+		OSVPass,
+		SemgrepPass,
+		LintPass,
+		GitleaksPass,
+		IaCPass,
+		CoveragePass,
 	}
 }
 
@@ -106,24 +147,123 @@ var PassNames = map[string]Pass{
 	"schema":        SchemaChangePass,
 	"anti_patterns": AntiPatternPass,
 	"blast_radius":  BlastRadiusPass,
+	"osv":           OSVPass,
+	"semgrep":       SemgrepPass,
+	"lint":          LintPass,
+	"gitleaks":      GitleaksPass,
+	"iac":           IaCPass,
+	"coverage":      CoveragePass,
 }
 
 // Run executes all passes (or a subset) and returns the aggregated results.
 func Run(ds *diff.DiffSet, repoDir string, skip []string) *Results {
+	results, _ := RunContext(context.Background(), ds, repoDir, skip)
+	return results
+}
+
+// contextPasses are cancellable versions of the passes that scan the
+// repository, used by RunContext in place of their PassNames entries. Those
+// that search the repository's files share one index of them for the run.
+var contextPasses = map[string]func(context.Context, *diff.DiffSet, string) []Finding{
+	"blast_radius": blastRadius,
+	"deleted":      deletedCode,
+	"osv":          osvScan,
+	"semgrep":      semgrepScan,
+	"lint":         lint,
+	"gitleaks":     gitleaksScan,
+	"iac":          iacScan,
+	"coverage":     uncovered,
+}
+
+// RunContext is Run, stopping early with ctx.Err() when ctx is cancelled.
+func RunContext(ctx context.Context, ds *diff.DiffSet, repoDir string, skip []string) (*Results, error) {
+	results := &Results{}
+	err := RunEach(ctx, ds, repoDir, skip, func(_ string, findings []Finding) {
+		results.Findings = append(results.Findings, findings...)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// RunEach runs the passes in name order, calling fn with each pass's
+// findings as it completes, so callers can stream them. It first fills in
+// the scope of ds's hunks from the repository (see diff.FillScopes), and
+// gives each finding the scope of its line and the risk and severity the
+// repository's .agrev.yml overrides give it. The passes that search the
+// repository share an index of its files, read the first time one needs
+// it.
+func RunEach(ctx context.Context, ds *diff.DiffSet, repoDir string, skip []string, fn func(pass string, findings []Finding)) error {
 	skipSet := make(map[string]bool)
 	for _, s := range skip {
 		skipSet[s] = true
 	}
 
-	results := &Results{}
+	diff.FillScopes(ds, repoDir)
+	overrides := loadOverrides(repoDir)
+	if repoDir != "" {
+		ctx = withIndex(ctx, repoDir)
+	}
+	files := make(map[string]*diff.File, len(ds.Files))
+	for _, f := range ds.Files {
+		files[f.Name()] = f
+	}
 
-	for name, pass := range PassNames {
+	for _, name := range slices.Sorted(maps.Keys(PassNames)) {
 		if skipSet[name] {
 			continue
 		}
-		findings := pass(ds, repoDir)
-		results.Findings = append(results.Findings, findings...)
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		var findings []Finding
+		if cp, ok := contextPasses[name]; ok {
+			findings = cp(ctx, ds, repoDir)
+		} else {
+			findings = PassNames[name](ds, repoDir)
+		}
+		// Deleted-code findings point into the old file, where the hunks
+		// can't say what encloses them
+		for i, fin := range findings {
+			if f := files[fin.File]; f != nil && fin.Line > 0 && name != "deleted" {
+				findings[i].Scope = f.ScopeAt(fin.Line)
+			}
+		}
+		remap(findings, overrides)
+		fn(name, findings)
 	}
 
-	return results
+	return ctx.Err()
+}
+
+// loadOverrides reads the repository's risk and severity overrides. A
+// config that doesn't load is reported by the commands that read its other
+// settings; analysis then goes without overrides.
+func loadOverrides(repoDir string) []config.Override {
+	cfg, err := config.Load(repoDir)
+	if err != nil {
+		return nil
+	}
+	return cfg.Analysis.Overrides
+}
+
+// remap gives each finding the risk and severity of the first override it
+// matches.
+func remap(findings []Finding, overrides []config.Override) {
+	for i, f := range findings {
+		for j := range overrides {
+			o := &overrides[j]
+			if !o.Matches(f.Rule, f.Pass, f.Message) {
+				continue
+			}
+			if r, ok := model.ParseRiskLevel(o.Risk); ok {
+				findings[i].Risk = r
+			}
+			if s, ok := model.ParseSeverity(o.Severity); ok {
+				findings[i].Severity = s
+			}
+			break
+		}
+	}
 }
@@ -1,9 +1,17 @@
 package analysis
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
 	"strings"
 	"testing"
 
+	"github.com/aezell/agrev/internal/config"
+	"github.com/aezell/agrev/internal/coverage"
 	"github.com/aezell/agrev/internal/diff"
 	"github.com/aezell/agrev/internal/model"
 )
@@ -46,6 +54,29 @@ func TestNewDependencyPass(t *testing.T) {
 	}
 }
 
+func TestSubmoduleFinding(t *testing.T) {
+	ds, err := diff.Parse(`diff --git a/lib b/lib
+index f016375..5f6b995 160000
+--- a/lib
++++ b/lib
+@@ -1 +1 @@
+-Subproject commit f0163751f6e5426c5d700848e735d09a79f11dc0
++Subproject commit 5f6b99566e8141c66ab73afae25f966fded1fff6
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	findings := NewDependencyPass(ds, "")
+	if len(findings) != 1 {
+		t.Fatalf("expected 1 finding, got %d: %v", len(findings), findings)
+	}
+	f := findings[0]
+	if f.File != "lib" || f.Line != 0 || f.Risk != model.RiskMedium || f.Message != "Submodule lib bumped from f016375 to 5f6b995" {
+		t.Errorf("unexpected finding %+v", f)
+	}
+}
+
 const npmDiff = `diff --git a/package.json b/package.json
 index abc1234..def5678 100644
 --- a/package.json
@@ -70,6 +101,485 @@ func TestNpmDependencyDetection(t *testing.T) {
 	}
 }
 
+const cargoLockDiff = `diff --git a/Cargo.lock b/Cargo.lock
+index abc1234..def5678 100644
+--- a/Cargo.lock
++++ b/Cargo.lock
+@@ -20,4 +20,4 @@
+ [[package]]
+ name = "time"
+-version = "0.1.44"
++version = "0.1.45"
+ source = "registry+https://github.com/rust-lang/crates.io-index"
+`
+
+// osvReport is what the fake osv-scanner prints: a vulnerability in a
+// package go.mod adds, one in a package it leaves alone, and one in the
+// crate Cargo.lock bumps, given by severity rather than a score.
+const osvReport = `{"results": [
+  {"source": {"path": "go.mod", "type": "lockfile"}, "packages": [
+    {"package": {"name": "github.com/newdep/foo", "version": "v1.2.3", "ecosystem": "Go"},
+     "vulnerabilities": [{"id": "GO-2024-0001", "summary": "Path traversal in foo"}],
+     "groups": [{"ids": ["GO-2024-0001", "CVE-2024-1234"], "max_severity": "9.8"}]},
+    {"package": {"name": "github.com/existing/dep", "version": "v1.0.0", "ecosystem": "Go"},
+     "vulnerabilities": [{"id": "GO-2023-0002"}],
+     "groups": [{"ids": ["GO-2023-0002"], "max_severity": "7.5"}]}
+  ]},
+  {"source": {"path": "%s/Cargo.lock", "type": "lockfile"}, "packages": [
+    {"package": {"name": "time", "version": "0.1.45", "ecosystem": "crates.io"},
+     "vulnerabilities": [{"id": "RUSTSEC-2020-0071", "summary": "Segfault in time", "database_specific": {"severity": "moderate"}}],
+     "groups": [{"ids": ["RUSTSEC-2020-0071"]}]}
+  ]}
+]}`
+
+func TestOSVPass(t *testing.T) {
+	dir := t.TempDir()
+	script := filepath.Join(dir, "osv-scanner")
+	out := filepath.Join(dir, "report.json")
+	args := filepath.Join(dir, "args")
+	if err := os.WriteFile(out, []byte(fmt.Sprintf(osvReport, dir)), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(script, []byte("#!/bin/sh\necho \"$@\" > "+args+"\ncat "+out+"\nexit 1\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	defer func(old string) { osvScanner = old }(osvScanner)
+	osvScanner = script
+
+	ds, err := diff.Parse(depDiff + cargoLockDiff + npmDiff)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if findings := OSVPass(ds, ""); len(findings) != 0 {
+		t.Errorf("expected no findings without a repository, got %v", findings)
+	}
+	findings := OSVPass(ds, dir)
+	if len(findings) != 2 {
+		t.Fatalf("expected findings for the added and bumped packages only, got %v", findings)
+	}
+	if got, _ := os.ReadFile(args); string(got) != "--format json --lockfile go.mod --lockfile Cargo.lock\n" {
+		t.Errorf("osv-scanner ran with %q; package.json isn't a lockfile", got)
+	}
+
+	foo, crate := findings[0], findings[1]
+	if foo.File != "go.mod" || foo.Line != 6 || foo.Rule != RuleVulnerability || foo.Risk != model.RiskCritical || foo.Severity != model.SeverityError ||
+		foo.Message != "github.com/newdep/foo@v1.2.3 has a known vulnerability: GO-2024-0001 (CVE-2024-1234): Path traversal in foo" {
+		t.Errorf("unexpected finding %+v", foo)
+	}
+	if crate.File != "Cargo.lock" || crate.Line != 22 || crate.Risk != model.RiskMedium || crate.Severity != model.SeverityWarning {
+		t.Errorf("unexpected finding %+v", crate)
+	}
+
+	osvScanner = filepath.Join(dir, "missing")
+	if findings := OSVPass(ds, dir); len(findings) != 0 {
+		t.Errorf("expected no findings without osv-scanner, got %v", findings)
+	}
+}
+
+// bumpDiff is a Dependabot-style update: a Go module bumped in go.mod and
+// go.sum, a crate bumped in Cargo.lock, and an npm package replaced by
+// another in package-lock.json.
+const bumpDiff = `diff --git a/go.mod b/go.mod
+index abc1234..def5678 100644
+--- a/go.mod
++++ b/go.mod
+@@ -5,4 +5,4 @@ go 1.21
+ require (
+ 	github.com/existing/dep v1.0.0
+-	github.com/foo/bar/v2 v2.3.1
++	github.com/foo/bar/v2 v2.4.0
+ )
+diff --git a/go.sum b/go.sum
+index abc1234..def5678 100644
+--- a/go.sum
++++ b/go.sum
+@@ -1,2 +1,2 @@
+-github.com/foo/bar/v2 v2.3.1 h1:old=
+-github.com/foo/bar/v2 v2.3.1/go.mod h1:old=
++github.com/foo/bar/v2 v2.4.0 h1:new=
++github.com/foo/bar/v2 v2.4.0/go.mod h1:new=
+` + cargoLockDiff + `diff --git a/package-lock.json b/package-lock.json
+index abc1234..def5678 100644
+--- a/package-lock.json
++++ b/package-lock.json
+@@ -10,6 +10,6 @@
+     },
+-    "node_modules/left-pad": {
+-      "version": "1.3.0",
++    "node_modules/pad-left": {
++      "version": "2.1.0",
+       "license": "MIT"
+     },
+     "node_modules/lodash": {
+`
+
+func TestDependencyUpdate(t *testing.T) {
+	defer func(old string) { osvScanner = old }(osvScanner)
+	osvScanner = "/nonexistent/osv-scanner"
+
+	ds, err := diff.Parse(bumpDiff)
+	if err != nil {
+		t.Fatal(err)
+	}
+	results := &Results{Findings: []Finding{{
+		Pass: "osv", Rule: RuleVulnerability, File: "Cargo.lock", Line: 22,
+		Message: "time@0.1.45 has a known vulnerability: RUSTSEC-2020-0071: Segfault in time",
+	}}}
+	bumps, ok := DependencyUpdate(ds, results)
+	if !ok {
+		t.Fatal("expected a dependency update")
+	}
+	var got []string
+	for _, b := range bumps {
+		got = append(got, fmt.Sprintf("%s %s %s:%d %s %s %s", b.Ecosystem, b.Name, b.File, b.Line, b.Versions(), b.Jump(), b.Status()))
+	}
+	want := []string{
+		"go github.com/foo/bar/v2 go.mod:7 v2.3.1 → v2.4.0 minor not checked",
+		"cargo time Cargo.lock:22 0.1.44 → 0.1.45 patch 1 known: RUSTSEC-2020-0071",
+		"npm pad-left package-lock.json:12 new at 2.1.0 new not checked",
+		"npm left-pad package-lock.json:0 removed (was 1.3.0) removed ",
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("got bumps\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	if url := bumps[0].Changelog; url != "https://github.com/foo/bar/compare/v2.3.1...v2.4.0" {
+		t.Errorf("unexpected changelog %q", url)
+	}
+	if url := bumps[1].Changelog; url != "https://crates.io/crates/time/versions" {
+		t.Errorf("unexpected changelog %q", url)
+	}
+
+	ds, err = diff.Parse(bumpDiff + secDiffAuth)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := DependencyUpdate(ds, results); ok {
+		t.Error("a change to code isn't a dependency update")
+	}
+}
+
+func TestBumpJump(t *testing.T) {
+	for _, tt := range []struct{ from, to, want string }{
+		{"1.2.3", "2.0.0", "major"},
+		{"^4.17.20", "^4.18.0", "minor"},
+		{"v0.1.0", "v0.1.1", "patch"},
+		{"==2.31.0", "==2.30.0", "downgrade"},
+		{"1.0.0-beta.1", "1.0.0", ""},
+		{"v0.0.0-20231010123456-abcdef123456", "v0.0.0-20240101000000-123456abcdef", ""},
+		{"latest", "1.0.0", ""},
+	} {
+		if got := (Bump{From: tt.from, To: tt.to}).Jump(); got != tt.want {
+			t.Errorf("%s → %s: got %q, want %q", tt.from, tt.to, got, tt.want)
+		}
+	}
+}
+
+const semgrepReport = `{"results": [
+  {"check_id": "rules.sql-format", "path": "db.go", "start": {"line": 13}, "end": {"line": 13},
+   "extra": {"message": "SQL built with\n  string formatting", "severity": "ERROR"}},
+  {"check_id": "rules.multi-line", "path": "db.go", "start": {"line": 12}, "end": {"line": 14},
+   "extra": {"message": "Spans the change", "severity": "INFO"}},
+  {"check_id": "rules.untouched", "path": "db.go", "start": {"line": 10}, "end": {"line": 10},
+   "extra": {"message": "Was there before", "severity": "WARNING"}},
+  {"check_id": "rules.elsewhere", "path": "other.go", "start": {"line": 1}, "end": {"line": 1},
+   "extra": {"message": "Not in the change", "severity": "ERROR"}}
+]}`
+
+func TestSemgrepPass(t *testing.T) {
+	dir := t.TempDir()
+	script := filepath.Join(dir, "semgrep")
+	out := filepath.Join(dir, "report.json")
+	args := filepath.Join(dir, "args")
+	if err := os.WriteFile(out, []byte(semgrepReport), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(script, []byte("#!/bin/sh\necho \"$@\" > "+args+"\ncat "+out+"\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	defer func(old string) { semgrepCommand = old }(semgrepCommand)
+	semgrepCommand = script
+
+	ds, err := diff.Parse(secDiffDB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if findings := SemgrepPass(ds, dir); len(findings) != 0 {
+		t.Errorf("expected no findings without rules, got %v", findings)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, ".semgrep.yml"), []byte("rules: []\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	findings := SemgrepPass(ds, dir)
+	if got, _ := os.ReadFile(args); string(got) != "scan --json --quiet --metrics off --config .semgrep.yml -- db.go\n" {
+		t.Errorf("semgrep ran with %q", got)
+	}
+	if len(findings) != 2 {
+		t.Fatalf("expected the matches on added lines, got %v", findings)
+	}
+	sql, multi := findings[0], findings[1]
+	if sql.Rule != "rules.sql-format" || sql.Line != 13 || sql.Message != "SQL built with string formatting" || sql.Risk != model.RiskHigh || sql.Severity != model.SeverityError {
+		t.Errorf("unexpected finding %+v", sql)
+	}
+	if multi.Rule != "rules.multi-line" || multi.Line != 13 || multi.Risk != model.RiskLow {
+		t.Errorf("expected a match spanning the change on its first added line, got %+v", multi)
+	}
+
+	cfg := "analysis:\n  semgrep:\n    config: [p/golang, rules/]\n    all_lines: true\n"
+	if err := os.WriteFile(filepath.Join(dir, config.FileName), []byte(cfg), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	findings = SemgrepPass(ds, dir)
+	if got, _ := os.ReadFile(args); string(got) != "scan --json --quiet --metrics off --config p/golang --config rules/ -- db.go\n" {
+		t.Errorf("semgrep ran with %q", got)
+	}
+	if len(findings) != 3 || findings[2].Line != 10 || findings[2].Risk != model.RiskMedium {
+		t.Errorf("expected matches anywhere in the changed files with all_lines, got %v", findings)
+	}
+}
+
+func TestLintPass(t *testing.T) {
+	dir := t.TempDir()
+	script := filepath.Join(dir, "fake-eslint")
+	out := filepath.Join(dir, "report.json")
+	args := filepath.Join(dir, "args")
+	report := `[{"filePath": "` + dir + `/auth.go", "messages": [
+  {"ruleId": "no-env", "severity": 2, "message": "Reads the environment", "line": 6},
+  {"ruleId": "no-import", "severity": 1, "message": "Unused import", "line": 3},
+  {"ruleId": null, "severity": 2, "message": "Parsing error", "line": 40}
+]}]`
+	if err := os.WriteFile(out, []byte(report), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(script, []byte("#!/bin/sh\necho \"$@\" > "+args+"\ncat "+out+"\nexit 1\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	cfg := "analysis:\n  linters:\n    - name: eslint\n      command: [" + script + ", --format, json]\n      format: eslint\n      files: [\"*.go\"]\n"
+	if err := os.WriteFile(filepath.Join(dir, config.FileName), []byte(cfg), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	ds, err := diff.Parse(secDiffAuth + npmDiff)
+	if err != nil {
+		t.Fatal(err)
+	}
+	findings := LintPass(ds, dir)
+	if got, _ := os.ReadFile(args); string(got) != "--format json auth.go\n" {
+		t.Errorf("the linter ran with %q", got)
+	}
+	if len(findings) != 2 {
+		t.Fatalf("expected the reports on changed lines, got %v", findings)
+	}
+	env, imp := findings[0], findings[1]
+	if env.Pass != "lint" || env.Rule != "eslint/no-env" || env.File != "auth.go" || env.Line != 6 || env.Risk != model.RiskMedium {
+		t.Errorf("unexpected finding %+v", env)
+	}
+	if imp.Rule != "eslint/no-import" || imp.Risk != model.RiskLow || imp.Severity != model.SeverityWarning {
+		t.Errorf("unexpected finding %+v", imp)
+	}
+
+	changesConfig, err := diff.Parse(secDiffAuth + "diff --git a/.agrev.yml b/.agrev.yml\nnew file mode 100644\n--- /dev/null\n+++ b/.agrev.yml\n@@ -0,0 +1 @@\n+analysis: {}\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if findings := LintPass(changesConfig, dir); len(findings) != 0 {
+		t.Errorf("expected no linters to run while .agrev.yml is changed, got %v", findings)
+	}
+}
+
+func TestParseLintReports(t *testing.T) {
+	tests := []struct {
+		format, out string
+		want        lintReport
+	}{
+		{"checkstyle", `<?xml version="1.0"?><checkstyle version="5.0"><file name="main.go"><error line="12" column="2" severity="error" message="Error return value is not checked" source="errcheck"></error></file></checkstyle>`,
+			lintReport{file: "main.go", line: 12, rule: "errcheck", severity: "error", message: "Error return value is not checked"}},
+		{"ruff", `[{"code": "F401", "message": "os imported but unused", "filename": "/repo/app.py", "location": {"row": 1, "column": 8}}]`,
+			lintReport{file: "/repo/app.py", line: 1, rule: "F401", severity: "warning", message: "os imported but unused"}},
+		{"golangci-lint", `{"Issues": [{"FromLinter": "unused", "Text": "func f is unused", "Severity": "", "Pos": {"Filename": "a/b.go", "Line": 7}}]}`,
+			lintReport{file: "a/b.go", line: 7, rule: "unused", severity: "warning", message: "func f is unused"}},
+	}
+	for _, tt := range tests {
+		got, err := parseLintReports(tt.format, []byte(tt.out))
+		if err != nil || len(got) != 1 || got[0] != tt.want {
+			t.Errorf("%s: got %+v, %v", tt.format, got, err)
+		}
+	}
+	if got, err := parseLintReports("ruff", nil); err != nil || got != nil {
+		t.Errorf("expected no reports from no output, got %v, %v", got, err)
+	}
+	if _, err := parseLintReports("eslint", []byte("Oops! Something went wrong")); err == nil {
+		t.Error("expected output that isn't JSON to be an error")
+	}
+}
+
+func TestGitleaksPass(t *testing.T) {
+	dir := t.TempDir()
+	script := filepath.Join(dir, "gitleaks")
+	scanned := filepath.Join(dir, "scanned")
+	report := `[{"RuleID": "generic-api-key", "Description": "Detected a Generic API Key", "File": "SRC/db.go", "StartLine": 14, "Secret": "REDACTED"}]`
+	fake := "#!/bin/sh\n" +
+		"while [ $# -gt 0 ]; do case \"$1\" in --source) src=$2; shift;; --report-path) out=$2; shift;; esac; shift; done\n" +
+		"cat \"$src/db.go\" > " + scanned + "\n" +
+		"echo '" + report + "' | sed \"s|SRC|$src|\" > \"$out\"\n"
+	if err := os.WriteFile(script, []byte(fake), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	defer func(old string) { gitleaksCommand = old }(gitleaksCommand)
+	gitleaksCommand = script
+
+	ds, err := diff.Parse(secDiffDB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	findings := GitleaksPass(ds, "")
+	if len(findings) != 1 {
+		t.Fatalf("expected 1 finding, got %v", findings)
+	}
+	f := findings[0]
+	if f.Pass != "gitleaks" || f.Rule != "generic-api-key" || f.File != "db.go" || f.Line != 14 || f.Risk != model.RiskCritical || f.Message != "Possible secret: Detected a Generic API Key" {
+		t.Errorf("unexpected finding %+v", f)
+	}
+	got, _ := os.ReadFile(scanned)
+	want := strings.Repeat("\n", 12) + "\tdb.Exec(\"DELETE FROM users WHERE id = ?\", id)\n\tcmd := exec.Command(\"bash\", \"-c\", userInput)\n"
+	if string(got) != want {
+		t.Errorf("expected gitleaks to scan only the added lines, at their line numbers, got %q", got)
+	}
+}
+
+const iacDiff = `diff --git a/main.tf b/main.tf
+index abc1234..def5678 100644
+--- a/main.tf
++++ b/main.tf
+@@ -1,6 +1,7 @@
+ resource "aws_s3_bucket" "logs" {
+   bucket = "logs"
+ }
+ resource "aws_s3_bucket" "data" {
+   bucket = "data"
++  acl    = "public-read"
+ }
+diff --git a/deploy.yaml b/deploy.yaml
+new file mode 100644
+--- /dev/null
++++ b/deploy.yaml
+@@ -0,0 +1,2 @@
++apiVersion: v1
++kind: Pod
+diff --git a/ci.yaml b/ci.yaml
+new file mode 100644
+--- /dev/null
++++ b/ci.yaml
+@@ -0,0 +1 @@
++on: push
+`
+
+func TestIaCPass(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{"deploy.yaml": "apiVersion: v1\nkind: Pod\n", "ci.yaml": "on: push\n"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	script := filepath.Join(dir, "checkov")
+	out := filepath.Join(dir, "report.json")
+	args := filepath.Join(dir, "args")
+	report := `[{"check_type": "terraform", "results": {"failed_checks": [
+    {"check_id": "CKV_AWS_20", "check_name": "S3 Bucket has an ACL defined which allows public READ access.", "file_path": "/main.tf", "file_line_range": [4, 7], "resource": "aws_s3_bucket.data", "severity": "HIGH"},
+    {"check_id": "CKV_AWS_18", "check_name": "Ensure the S3 bucket has access logging enabled", "file_path": "/main.tf", "file_line_range": [1, 3], "resource": "aws_s3_bucket.logs", "severity": null}
+  ]}},
+  {"check_type": "kubernetes", "results": {"failed_checks": [
+    {"check_id": "CKV_K8S_21", "check_name": "The default namespace should not be used", "file_path": "/deploy.yaml", "file_line_range": [1, 2], "resource": "Pod.default", "severity": null}
+  ]}}]`
+	if err := os.WriteFile(out, []byte(report), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(script, []byte("#!/bin/sh\necho \"$@\" > "+args+"\ncat "+out+"\nexit 1\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	defer func(old map[string]string) { iacCommands = old }(iacCommands)
+	iacCommands = map[string]string{"trivy": filepath.Join(dir, "missing"), "checkov": script, "tfsec": filepath.Join(dir, "missing")}
+
+	ds, err := diff.Parse(iacDiff)
+	if err != nil {
+		t.Fatal(err)
+	}
+	findings := IaCPass(ds, dir)
+	if got, _ := os.ReadFile(args); string(got) != "--quiet --compact --output json --soft-fail --file main.tf deploy.yaml\n" {
+		t.Errorf("checkov ran with %q", got)
+	}
+	if len(findings) != 2 {
+		t.Fatalf("expected findings on the resources the change touches, got %v", findings)
+	}
+	acl, ns := findings[0], findings[1]
+	if acl.Pass != "iac" || acl.Rule != "CKV_AWS_20" || acl.File != "main.tf" || acl.Line != 6 || acl.Risk != model.RiskHigh ||
+		acl.Message != "aws_s3_bucket.data: S3 Bucket has an ACL defined which allows public READ access." {
+		t.Errorf("unexpected finding %+v", acl)
+	}
+	if ns.Rule != "CKV_K8S_21" || ns.File != "deploy.yaml" || ns.Line != 1 || ns.Risk != model.RiskMedium {
+		t.Errorf("unexpected finding %+v", ns)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, config.FileName), []byte("analysis:\n  iac:\n    scanner: tfsec\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if findings := IaCPass(ds, dir); len(findings) != 0 {
+		t.Errorf("expected no findings when the configured scanner isn't installed, got %v", findings)
+	}
+}
+
+func TestParseIaCResults(t *testing.T) {
+	trivy := `{"Results": [{"Target": "Dockerfile", "Misconfigurations": [
+  {"ID": "AVD-DS-0002", "Title": "Image user should not be 'root'", "Message": "Specify at least 1 USER command in Dockerfile", "Severity": "HIGH", "Status": "FAIL"},
+  {"ID": "AVD-DS-0001", "Title": "':latest' tag used", "Severity": "MEDIUM", "Status": "PASS", "CauseMetadata": {"StartLine": 1, "EndLine": 1}}
+]}]}`
+	got, err := parseIaCResults("trivy", []byte(trivy))
+	if err != nil || len(got) != 1 || got[0] != (iacResult{file: "Dockerfile", rule: "AVD-DS-0002", message: "Specify at least 1 USER command in Dockerfile", severity: "HIGH"}) {
+		t.Errorf("trivy: got %+v, %v", got, err)
+	}
+
+	tfsec := `{"results": [{"rule_id": "AVD-AWS-0086", "rule_description": "S3 Access block should block public ACL", "description": "No public access block so not blocking public acls",
+  "severity": "HIGH", "resource": "aws_s3_bucket.data", "location": {"filename": "/repo/main.tf", "start_line": 4, "end_line": 7}}]}`
+	got, err = parseIaCResults("tfsec", []byte(tfsec))
+	want := iacResult{file: "/repo/main.tf", start: 4, end: 7, rule: "AVD-AWS-0086", message: "No public access block so not blocking public acls", resource: "aws_s3_bucket.data", severity: "HIGH"}
+	if err != nil || len(got) != 1 || got[0] != want {
+		t.Errorf("tfsec: got %+v, %v", got, err)
+	}
+}
+
+func TestCoveragePass(t *testing.T) {
+	// auth.go's lines 1-7 are added; 5 ran and 3, 6, and 7 didn't
+	profile, err := coverage.Parse([]byte("SF:/ci/repo/auth.go\nDA:3,0\nDA:5,1\nDA:6,0\nDA:7,0\nend_of_record\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ds, err := diff.Parse(secDiffAuth + secDiffDB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if findings := CoveragePass(ds, ""); len(findings) != 0 {
+		t.Errorf("expected no findings without a report, got %v", findings)
+	}
+
+	results, err := RunContext(WithCoverage(context.Background(), profile), ds, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, f := range results.Findings {
+		if f.Pass == "coverage" {
+			got = append(got, fmt.Sprintf("%s:%d %s", f.File, f.Line, f.Message))
+			if f.Rule != RuleUncovered || f.Risk != model.RiskLow {
+				t.Errorf("unexpected finding %+v", f)
+			}
+		}
+	}
+	want := []string{"auth.go:3 Added line 3 isn't covered by tests", "auth.go:6 Added lines 6-7 aren't covered by tests"}
+	if !slices.Equal(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
 // --- Security surface tests ---
 
 const secDiffAuth = `diff --git a/auth.go b/auth.go
@@ -211,6 +721,26 @@ index abc1234..def5678 100644
 +      summary: List users
 `
 
+func TestLineEndingConversion(t *testing.T) {
+	raw := "diff --git a/a.txt b/a.txt\n--- a/a.txt\n+++ b/a.txt\n@@ -1,2 +1,2 @@\n" +
+		"-one\r\n-two\r\n+one\n+two\n" +
+		"diff --git a/b.txt b/b.txt\n--- a/b.txt\n+++ b/b.txt\n@@ -1,2 +1,2 @@\n" +
+		" one\r\n-two\r\n+two\n"
+	ds, err := diff.Parse(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var found []string
+	for _, f := range AntiPatternPass(ds, "") {
+		if containsCI(f.Message, "line endings") {
+			found = append(found, f.File+": "+f.Message)
+		}
+	}
+	if len(found) != 1 || !strings.Contains(found[0], "a.txt: Line endings converted from CRLF to LF") {
+		t.Errorf("expected one finding for a.txt, got %q", found)
+	}
+}
+
 func TestSchemaChangePass(t *testing.T) {
 	ds, err := diff.Parse(schemaDiffMigration + schemaDiffOpenAPI)
 	if err != nil {
@@ -300,6 +830,92 @@ func TestDeletedCodePass(t *testing.T) {
 	}
 }
 
+func TestDeletedCodePassSkipsMoves(t *testing.T) {
+	raw := `diff --git a/old.go b/old.go
+--- a/old.go
++++ b/old.go
+@@ -1,5 +1,1 @@
+ package old
+-func helper(a, b int) int {
+-	sum := a + b
+-	return sum
+-}
+diff --git a/new.go b/new.go
+--- a/new.go
++++ b/new.go
+@@ -1,1 +1,5 @@
+ package new
++func helper(a, b int) int {
++	sum := a + b
++	return sum
++}
+`
+	ds, err := diff.Parse(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if findings := DeletedCodePass(ds, ""); len(findings) != 0 {
+		t.Errorf("expected no findings for a moved function, got %v", findings)
+	}
+}
+
+func TestRepoIndex(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) {
+		t.Helper()
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("pkg/helper.go", "package pkg\n\nfunc oldHelper() int { return 1 }\n")
+	write("pkg/helper_test.go", "package pkg\n\nvar _ = oldHelper()\n")
+	write("pkg/sub/deep_test.go", "package sub\n\nvar _ = oldHelper\n")
+	write("pkg/sub/deeper/deepest_test.go", "package deeper\n\nvar _ = oldHelper\n")
+	write("cmd/main.go", "package main\n\nvar a, b = oldHelper(), oldHelperX()\n")
+	write("vendor/lib/lib.go", "package lib\n\nvar _ = oldHelper()\n")
+	write(".git/hooks/x.go", "oldHelper()\n")
+	write("README.md", "oldHelper\n")
+	write("big.go", strings.Repeat("oldHelper()\n", maxIndexedSize/12+1))
+
+	ctx := withIndex(context.Background(), dir)
+	ix := repoIndex(ctx, dir)
+	if repoIndex(ctx, dir) != ix {
+		t.Error("expected the index built once for the run")
+	}
+	var paths []string
+	for _, f := range ix.files {
+		paths = append(paths, f.path)
+	}
+	want := []string{"cmd/main.go", "pkg/helper.go", "pkg/helper_test.go", "pkg/sub/deep_test.go", "pkg/sub/deeper/deepest_test.go"}
+	if !slices.Equal(paths, want) {
+		t.Errorf("expected only source and test files outside vendored, hidden, and oversized ones, got %v", paths)
+	}
+
+	if n := countReferences(ctx, dir, "pkg/helper.go", "oldHelper"); n != 4 {
+		t.Errorf("expected 4 references outside the defining file, got %d", n)
+	}
+
+	ds, err := diff.Parse(`diff --git a/pkg/helper.go b/pkg/helper.go
+--- a/pkg/helper.go
++++ b/pkg/helper.go
+@@ -1,3 +1,1 @@
+ package pkg
+-
+-func oldHelper() int { return 1 }
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	findings := deletedCode(ctx, ds, dir)
+	if len(findings) != 1 || findings[0].Rule != RuleDeletedTested || !strings.HasSuffix(findings[0].Message, "pkg/helper_test.go, pkg/sub/deep_test.go") {
+		t.Errorf("expected the tests next to the file and one level down, got %v", findings)
+	}
+}
+
 // --- Duplication tests ---
 
 const dupDiff = `diff --git a/a.go b/a.go
@@ -366,6 +982,30 @@ func TestRunAllPasses(t *testing.T) {
 	t.Logf("Max risk: %s", results.MaxRisk())
 }
 
+func TestRunFindingScopes(t *testing.T) {
+	ds, err := diff.Parse(antiDiff)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var except, todo *Finding
+	results := Run(ds, "", nil)
+	for i, f := range results.Findings {
+		switch {
+		case containsCI(f.Message, "exception"):
+			except = &results.Findings[i]
+		case containsCI(f.Message, "TODO"):
+			todo = &results.Findings[i]
+		}
+	}
+	if except == nil || except.Scope != "def handle()" {
+		t.Errorf("expected the bare except to be scoped to handle(), got %+v", except)
+	}
+	if todo == nil || todo.Scope != "" {
+		t.Errorf("expected no scope for a top-level comment, got %+v", todo)
+	}
+}
+
 func TestRunWithSkip(t *testing.T) {
 	ds, err := diff.Parse(secDiffAuth)
 	if err != nil {
@@ -381,6 +1021,24 @@ func TestRunWithSkip(t *testing.T) {
 	}
 }
 
+func TestRunContextCancelled(t *testing.T) {
+	ds, err := diff.Parse(antiDiff + schemaDiffMigration)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	results, err := RunContext(context.Background(), ds, "", nil)
+	if err != nil || len(results.Findings) == 0 {
+		t.Fatalf("expected findings, got %v, %v", results, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := RunContext(ctx, ds, "", nil); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+}
+
 func TestResultsByFile(t *testing.T) {
 	ds, err := diff.Parse(antiDiff + schemaDiffMigration)
 	if err != nil {
@@ -410,8 +1068,105 @@ func TestResultsByRisk(t *testing.T) {
 	}
 }
 
+func TestCompare(t *testing.T) {
+	todo := Finding{Pass: "anti_patterns", File: "a.go", Line: 10, Message: "TODO left in code", Risk: model.RiskLow}
+	secret := Finding{Pass: "security", File: "b.go", Line: 3, Message: "possible hardcoded secret", Risk: model.RiskHigh}
+	schema := Finding{Pass: "schema", File: "m.sql", Message: "drops a column", Risk: model.RiskHigh}
+
+	moved := todo
+	moved.Line = 14 // shifted by an unrelated edit
+	second := todo
+	second.Line = 30
+
+	baseline := &Results{Findings: []Finding{todo, schema}}
+	current := &Results{Findings: []Finding{second, moved, secret}}
+	c := Compare(baseline, current)
+
+	if len(c.New) != 2 || c.New[0] != secret || c.New[1] != second {
+		t.Errorf("expected the secret and one TODO to be new, got %+v", c.New)
+	}
+	if len(c.Fixed) != 1 || c.Fixed[0] != schema {
+		t.Errorf("expected the schema finding fixed, got %+v", c.Fixed)
+	}
+	if len(c.Persisting) != 1 || c.Persisting[0].Line != 14 {
+		t.Errorf("expected the moved TODO to persist, got %+v", c.Persisting)
+	}
+
+	want := "Introduces 2 new finding(s) (1 high, 1 low), resolves 1; 1 persist"
+	if got := c.Summary(); got != want {
+		t.Errorf("Summary() = %q, want %q", got, want)
+	}
+}
+
 // --- Helpers ---
 
 func containsCI(s, substr string) bool {
 	return strings.Contains(strings.ToLower(s), strings.ToLower(substr))
 }
+
+func TestRunOverrides(t *testing.T) {
+	dir := t.TempDir()
+	cfg := `analysis:
+  overrides:
+    - pass: anti_patterns
+      match: "TODO|FIXME"
+      risk: info
+      severity: info
+    - pass: deps
+      risk: high
+    - rule: agv-ant-001
+      risk: critical
+`
+	if err := os.WriteFile(filepath.Join(dir, config.FileName), []byte(cfg), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	ds, err := diff.Parse(antiDiff + depDiff)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	results := Run(ds, dir, nil)
+	var todo, except, dep bool
+	for _, f := range results.Findings {
+		switch {
+		case containsCI(f.Message, "TODO"):
+			todo = f.Risk == model.RiskInfo && f.Severity == model.SeverityInfo
+		case containsCI(f.Message, "exception"):
+			except = f.Risk == model.RiskCritical
+		case f.Pass == "deps":
+			dep = f.Risk == model.RiskHigh
+		}
+	}
+	if !todo || !except || !dep {
+		t.Errorf("expected TODOs as info, dependencies as high, and broad excepts as critical, got %+v", results.Findings)
+	}
+}
+
+func TestRules(t *testing.T) {
+	seen := make(map[string]bool)
+	for _, r := range Rules {
+		if seen[r.ID] || PassNames[r.Pass] == nil {
+			t.Errorf("rule %+v is a duplicate or names an unknown pass", r)
+		}
+		seen[r.ID] = true
+	}
+
+	ds, err := diff.Parse(antiDiff + depDiff)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range Run(ds, t.TempDir(), nil).Findings {
+		r, ok := LookupRule(f.Rule)
+		if !ok || r.Pass != f.Pass {
+			t.Errorf("finding %v has no rule of its pass", f)
+		}
+	}
+
+	f := Finding{Pass: "security", Rule: RuleSQL}
+	if got := f.DocsURL(); got != DocsURL+"#agv-sec-003" {
+		t.Errorf("DocsURL = %q", got)
+	}
+	if got := (Finding{Pass: "custom"}).DocsURL(); got != "" {
+		t.Errorf("expected no docs for a finding without a rule, got %q", got)
+	}
+}
@@ -36,15 +36,20 @@ var (
 	todoPattern = regexp.MustCompile(`(?i)\b(TODO|FIXME|HACK|XXX|TEMP|TEMPORARY)\b`)
 )
 
-// AntiPatternPass detects common agent anti-patterns.
+// AntiPatternPass detects common agent anti-patterns. Lockfiles and other
+// generated files are left out; nobody wrote their TODOs or duplication.
 func AntiPatternPass(ds *diff.DiffSet, repoDir string) []Finding {
 	var findings []Finding
 
 	for _, f := range ds.Files {
+		if f.Generated() {
+			continue
+		}
 		name := f.Name()
 		findings = append(findings, checkBroadExceptions(f, name)...)
 		findings = append(findings, checkCommentedCode(f, name)...)
 		findings = append(findings, checkTodos(f, name)...)
+		findings = append(findings, checkLineEndings(f, name)...)
 	}
 
 	// Check for near-duplicate code blocks across files
@@ -64,6 +69,7 @@ func checkBroadExceptions(f *diff.File, name string) []Finding {
 					if pat.MatchString(line.Line) {
 						findings = append(findings, Finding{
 							Pass:     "anti_patterns",
+							Rule:     RuleBroadException,
 							File:     name,
 							Line:     lineNum,
 							Message:  fmt.Sprintf("Broad exception handling: %s", strings.TrimSpace(line.Line)),
@@ -94,6 +100,7 @@ func checkCommentedCode(f *diff.File, name string) []Finding {
 					if pat.MatchString(line.Line) {
 						findings = append(findings, Finding{
 							Pass:     "anti_patterns",
+							Rule:     RuleCommentedCode,
 							File:     name,
 							Line:     lineNum,
 							Message:  fmt.Sprintf("Commented-out code: %s", strings.TrimSpace(line.Line)),
@@ -123,6 +130,7 @@ func checkTodos(f *diff.File, name string) []Finding {
 				if matches := todoPattern.FindString(line.Line); matches != "" {
 					findings = append(findings, Finding{
 						Pass:     "anti_patterns",
+						Rule:     RuleTodo,
 						File:     name,
 						Line:     lineNum,
 						Message:  fmt.Sprintf("Agent left %s marker: %s", matches, strings.TrimSpace(line.Line)),
@@ -142,6 +150,24 @@ func checkTodos(f *diff.File, name string) []Finding {
 
 // checkDuplication looks for near-duplicate code blocks introduced by the diff.
 // It uses a sliding window of N lines over added content and looks for repeated hashes.
+// checkLineEndings reports a file whose line endings were converted
+// wholesale, which makes every line show as changed and hides the edits
+// that matter among them.
+func checkLineEndings(f *diff.File, name string) []Finding {
+	from, to, ok := f.LineEndingChange()
+	if !ok {
+		return nil
+	}
+	return []Finding{{
+		Pass:     "anti_patterns",
+		Rule:     RuleLineEndings,
+		File:     name,
+		Message:  fmt.Sprintf("Line endings converted from %s to %s (%d lines rewritten)", from, to, f.AddedLines),
+		Severity: model.SeverityWarning,
+		Risk:     model.RiskMedium,
+	}}
+}
+
 func checkDuplication(ds *diff.DiffSet) []Finding {
 	const windowSize = 4
 
@@ -153,6 +179,9 @@ func checkDuplication(ds *diff.DiffSet) []Finding {
 	blocks := make(map[string][]blockLoc) // hash -> locations
 
 	for _, f := range ds.Files {
+		if f.Generated() {
+			continue
+		}
 		name := f.Name()
 
 		// Collect all added lines with their line numbers
@@ -198,6 +227,7 @@ func checkDuplication(ds *diff.DiffSet) []Finding {
 		for _, loc := range locs[1:] {
 			findings = append(findings, Finding{
 				Pass:     "anti_patterns",
+				Rule:     RuleDuplicate,
 				File:     loc.file,
 				Line:     loc.line,
 				Message:  fmt.Sprintf("Near-duplicate code block (also at %s:%d)", locs[0].file, locs[0].line),
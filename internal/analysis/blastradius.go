@@ -1,11 +1,10 @@
 package analysis
 
 import (
+	"context"
 	"fmt"
-	"os"
 	"path/filepath"
 	"regexp"
-	"strings"
 
 	"github.com/bluekeyes/go-gitdiff/gitdiff"
 	"github.com/aezell/agrev/internal/diff"
@@ -14,6 +13,12 @@ import (
 
 // BlastRadiusPass estimates how many callers reference changed functions.
 func BlastRadiusPass(ds *diff.DiffSet, repoDir string) []Finding {
+	return blastRadius(context.Background(), ds, repoDir)
+}
+
+// blastRadius is BlastRadiusPass, abandoning the repository search when
+// ctx is cancelled.
+func blastRadius(ctx context.Context, ds *diff.DiffSet, repoDir string) []Finding {
 	if repoDir == "" {
 		return nil
 	}
@@ -27,10 +32,14 @@ func BlastRadiusPass(ds *diff.DiffSet, repoDir string) []Finding {
 		changedFuncs := extractChangedFunctions(f)
 
 		for _, fn := range changedFuncs {
-			count := countReferences(repoDir, name, fn)
+			if ctx.Err() != nil {
+				return findings
+			}
+			count := countReferences(ctx, repoDir, name, fn)
 			if count > 15 {
 				findings = append(findings, Finding{
 					Pass:     "blast_radius",
+					Rule:     RuleHighBlastRadius,
 					File:     name,
 					Line:     0,
 					Message:  fmt.Sprintf("Function %q has %d references (high blast radius)", fn, count),
@@ -40,6 +49,7 @@ func BlastRadiusPass(ds *diff.DiffSet, repoDir string) []Finding {
 			} else if count > 5 {
 				findings = append(findings, Finding{
 					Pass:     "blast_radius",
+					Rule:     RuleBlastRadius,
 					File:     name,
 					Line:     0,
 					Message:  fmt.Sprintf("Function %q has %d references across the codebase", fn, count),
@@ -77,58 +87,14 @@ func extractChangedFunctions(f *diff.File) []string {
 	return funcs
 }
 
-func countReferences(repoDir, sourceFile, funcName string) int {
+// countReferences counts the references to funcName in the repository's
+// source files other than sourceFile, stopping once there are more than 20.
+func countReferences(ctx context.Context, repoDir, sourceFile, funcName string) int {
 	if len(funcName) < 3 {
 		return 0
 	}
-
 	pattern := regexp.MustCompile(`\b` + regexp.QuoteMeta(funcName) + `\b`)
-	count := 0
-
-	// Walk the repo directory looking for source files
-	_ = filepath.Walk(repoDir, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return nil // skip errors
-		}
-
-		// Skip hidden dirs, vendor, node_modules, etc.
-		if info.IsDir() {
-			base := filepath.Base(path)
-			if strings.HasPrefix(base, ".") || base == "vendor" || base == "node_modules" || base == "dist" || base == "build" {
-				return filepath.SkipDir
-			}
-			return nil
-		}
-
-		// Only check source files
-		if !isSourceFile(path) {
-			return nil
-		}
-
-		// Skip the source file itself
-		rel, _ := filepath.Rel(repoDir, path)
-		if rel == sourceFile {
-			return nil
-		}
-
-		// Read and search
-		content, err := os.ReadFile(path)
-		if err != nil {
-			return nil
-		}
-
-		matches := pattern.FindAll(content, -1)
-		count += len(matches)
-
-		// Early exit if we have enough
-		if count > 20 {
-			return filepath.SkipAll
-		}
-
-		return nil
-	})
-
-	return count
+	return repoIndex(ctx, repoDir).countMatches(ctx, pattern, funcName, sourceFile, 20)
 }
 
 func isSourceFile(path string) bool {
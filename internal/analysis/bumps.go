@@ -0,0 +1,377 @@
+package analysis
+
+import (
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/bluekeyes/go-gitdiff/gitdiff"
+	"github.com/aezell/agrev/internal/diff"
+)
+
+// Bump is a dependency a change moves from one version to another, adds,
+// or removes.
+type Bump struct {
+	Ecosystem string // go, npm, cargo, pip, gem, or hex
+	Name      string
+	From      string // the old version or constraint; "" for a new dependency
+	To        string // the new one; "" for a removed dependency
+	File      string
+	Line      int // the line giving the new version; 0 for a removed dependency
+
+	// Changelog is where to read what changed between the versions: a
+	// compare view for Go modules on GitHub, otherwise the package's
+	// version history on its registry.
+	Changelog string
+
+	// Vulnerabilities are the osv pass's findings on the new version.
+	// Checked is whether osv-scanner was there to look it up.
+	Vulnerabilities []Finding
+	Checked         bool
+}
+
+// Versions describes the version change, e.g. "1.2.3 → 1.3.0".
+func (b Bump) Versions() string {
+	switch {
+	case b.From == "":
+		return "new at " + b.To
+	case b.To == "":
+		return "removed (was " + b.From + ")"
+	}
+	return b.From + " → " + b.To
+}
+
+// Jump classifies the version change as "major", "minor", or "patch" by
+// the first of the leading numbers to change, "downgrade" when it goes
+// down, "new" or "removed", or "" when the versions don't compare, as
+// with a changed constraint or pre-release.
+func (b Bump) Jump() string {
+	switch {
+	case b.From == "":
+		return "new"
+	case b.To == "":
+		return "removed"
+	}
+	from, to := versionNumbers(b.From), versionNumbers(b.To)
+	if from == nil || to == nil {
+		return ""
+	}
+	for i, kind := range []string{"major", "minor", "patch"} {
+		x, y := 0, 0
+		if i < len(from) {
+			x = from[i]
+		}
+		if i < len(to) {
+			y = to[i]
+		}
+		switch {
+		case y > x:
+			return kind
+		case y < x:
+			return "downgrade"
+		}
+	}
+	return ""
+}
+
+// Status describes the new version's known vulnerabilities: their IDs,
+// "none known", or "not checked" without osv-scanner.
+func (b Bump) Status() string {
+	switch {
+	case len(b.Vulnerabilities) > 0:
+		ids := make([]string, len(b.Vulnerabilities))
+		for i, f := range b.Vulnerabilities {
+			ids[i] = vulnerabilityID(f)
+		}
+		return fmt.Sprintf("%d known: %s", len(ids), strings.Join(ids, ", "))
+	case b.To == "":
+		return ""
+	case !b.Checked:
+		return "not checked"
+	}
+	return "none known"
+}
+
+// vulnerabilityID is the advisory ID an osv finding's message gives, with
+// its aliases.
+func vulnerabilityID(f Finding) string {
+	_, id, ok := strings.Cut(f.Message, "has a known vulnerability: ")
+	if !ok {
+		return f.Message
+	}
+	id, _, _ = strings.Cut(id, ": ")
+	return id
+}
+
+// DependencyUpdate returns the dependencies ds bumps, adds, and removes,
+// with the osv pass's findings in results on them, if ds only changes
+// manifests and lockfiles, as Renovate and Dependabot pull requests do. ok
+// is false for any other change. results may be nil.
+func DependencyUpdate(ds *diff.DiffSet, results *Results) (bumps []Bump, ok bool) {
+	if len(ds.Files) == 0 {
+		return nil, false
+	}
+	for _, f := range ds.Files {
+		if _, isDep := depFiles[baseName(f.Name())]; !isDep || f.IsSubmodule || f.IsBinary {
+			return nil, false
+		}
+	}
+
+	_, err := exec.LookPath(osvScanner)
+	checked := err == nil
+	seen := make(map[string]bool)
+	for _, f := range ds.Files {
+		eco := depFiles[baseName(f.Name())]
+		for _, b := range fileBumps(f, eco) {
+			// A package is in both its manifest and its lockfile, and twice
+			// in go.sum; the first says it
+			key := eco + " " + b.Name
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			b.Changelog = changelogURL(eco, b.Name, b.From, b.To)
+			b.Checked = checked
+			if results != nil && b.To != "" {
+				for _, fin := range results.Findings {
+					if fin.Rule == RuleVulnerability && strings.HasPrefix(osvNormalize(fin.Message), osvNormalize(b.Name)+"@") {
+						b.Vulnerabilities = append(b.Vulnerabilities, fin)
+					}
+				}
+			}
+			bumps = append(bumps, b)
+		}
+	}
+	return bumps, true
+}
+
+// depLine is a line of one side of a hunk of a manifest or lockfile.
+type depLine struct {
+	num     int
+	text    string
+	changed bool
+}
+
+// depEntry is a package version a manifest or lockfile gives.
+type depEntry struct {
+	name, version string
+	line          int
+	changed       bool // whether the change adds or removes the line giving the version
+}
+
+// fileBumps compares the package versions the old and new sides of f's
+// hunks give. Only versions on changed lines count, so a package's other
+// versions in a lockfile are left alone.
+func fileBumps(f *diff.File, eco string) []Bump {
+	var bumps []Bump
+	base := baseName(f.Name())
+	for _, frag := range f.Fragments {
+		var old, new []depLine
+		oldNum, newNum := int(frag.OldPosition), int(frag.NewPosition)
+		for _, l := range frag.Lines {
+			text := strings.TrimRight(l.Line, "\r\n")
+			if l.Op != gitdiff.OpAdd {
+				old = append(old, depLine{num: oldNum, text: text, changed: l.Op == gitdiff.OpDelete})
+				oldNum++
+			}
+			if l.Op != gitdiff.OpDelete {
+				new = append(new, depLine{num: newNum, text: text, changed: l.Op == gitdiff.OpAdd})
+				newNum++
+			}
+		}
+
+		from := make(map[string]string)
+		var removed []string
+		for _, e := range depEntries(old, base, eco) {
+			if _, ok := from[e.name]; e.changed && !ok {
+				from[e.name] = e.version
+				removed = append(removed, e.name)
+			}
+		}
+		to := make(map[string]bool)
+		for _, e := range depEntries(new, base, eco) {
+			if !e.changed || to[e.name] {
+				continue
+			}
+			to[e.name] = true
+			if v, ok := from[e.name]; !ok || v != e.version {
+				bumps = append(bumps, Bump{Ecosystem: eco, Name: e.name, From: v, To: e.version, File: f.Name(), Line: e.line})
+			}
+		}
+		for _, name := range removed {
+			if !to[name] {
+				bumps = append(bumps, Bump{Ecosystem: eco, Name: name, From: from[name], File: f.Name()})
+			}
+		}
+	}
+	return bumps
+}
+
+var (
+	gemLockSpec = regexp.MustCompile(`^ {4}([^ ]+) \(([^)]+)\)$`)
+	mixLockDep  = regexp.MustCompile(`^\s*"([^"]+)": \{:hex, :[^,]+, "([^"]+)"`)
+)
+
+// depEntries reads the package versions the lines of one side of a hunk
+// give. Most lockfiles give a package's version on a line of its own below
+// its name, so the name must be in the hunk too.
+func depEntries(lines []depLine, base, eco string) []depEntry {
+	var entries []depEntry
+	current := "" // the package the next version line is for
+	add := func(l depLine, name, version string) {
+		entries = append(entries, depEntry{name: name, version: version, line: l.num, changed: l.changed})
+		current = ""
+	}
+	for _, l := range lines {
+		text := strings.TrimSpace(l.text)
+		switch base {
+		case "Cargo.lock", "poetry.lock":
+			// name = "serde"
+			// version = "1.0.190"
+			if v, ok := quotedValue(text, "name", " = "); ok {
+				current = v
+			} else if v, ok := quotedValue(text, "version", " = "); ok && current != "" {
+				add(l, current, v)
+			}
+
+		case "package-lock.json", "Pipfile.lock":
+			// "node_modules/lodash": {
+			//   "version": "4.17.21",
+			if key, ok := strings.CutSuffix(text, `": {`); ok && strings.HasPrefix(key, `"`) {
+				current = key[1:]
+				if i := strings.LastIndex(current, "node_modules/"); i >= 0 {
+					current = current[i+len("node_modules/"):]
+				}
+			} else if v, ok := quotedValue(strings.TrimSuffix(text, ","), `"version"`, ": "); ok && current != "" {
+				add(l, current, strings.TrimPrefix(v, "=="))
+			}
+
+		case "yarn.lock":
+			// lodash@^4.17.20, lodash@^4.17.21:
+			//   version "4.17.21"
+			if !strings.HasPrefix(l.text, " ") && strings.HasSuffix(text, ":") && !strings.HasPrefix(text, "#") {
+				spec, _, _ := strings.Cut(strings.Trim(strings.TrimSuffix(text, ":"), `"`), ",")
+				name, _, berry := strings.Cut(spec, "@npm:")
+				if i := strings.LastIndex(spec, "@"); !berry && i > 0 {
+					name = spec[:i]
+				}
+				current = name
+			} else if v, ok := strings.CutPrefix(text, "version"); ok && current != "" {
+				add(l, current, strings.Trim(strings.TrimLeft(v, ": "), `"`))
+			}
+
+		case "pnpm-lock.yaml":
+			// /lodash@4.17.21:  or  '@babel/core@7.23.0(supports-color@8.1.1)':
+			if key, ok := strings.CutSuffix(text, ":"); ok && !strings.Contains(key, " ") {
+				key, _, _ = strings.Cut(strings.Trim(key, `'"`), "(")
+				key = strings.TrimPrefix(key, "/")
+				if i := strings.LastIndex(key, "@"); i > 0 {
+					add(l, key[:i], key[i+1:])
+				}
+			}
+
+		case "Gemfile.lock":
+			//     rails (7.1.2)
+			if m := gemLockSpec.FindStringSubmatch(l.text); m != nil {
+				add(l, m[1], m[2])
+			}
+
+		case "mix.lock":
+			//   "jason": {:hex, :jason, "1.4.1", ...
+			if m := mixLockDep.FindStringSubmatch(l.text); m != nil {
+				add(l, m[1], m[2])
+			}
+
+		default:
+			if name, version := parseDepLine(text, eco); name != "" {
+				add(l, name, version)
+			}
+		}
+	}
+	return entries
+}
+
+// quotedValue returns the quoted value of a "key = value" line.
+func quotedValue(line, key, sep string) (string, bool) {
+	v, ok := strings.CutPrefix(line, key+sep)
+	if !ok || len(v) < 2 || v[0] != '"' || v[len(v)-1] != '"' {
+		return "", false
+	}
+	return v[1 : len(v)-1], true
+}
+
+// versionNumbers reads the dot-separated numbers a version or constraint
+// starts with, such as [1 2 3] in "^v1.2.3-beta", or nil if it has none.
+func versionNumbers(v string) []int {
+	v = strings.TrimLeft(v, "^~=<>! v")
+	var nums []int
+	for _, part := range strings.Split(v, ".") {
+		end := 0
+		for end < len(part) && part[end] >= '0' && part[end] <= '9' {
+			end++
+		}
+		n, err := strconv.Atoi(part[:end])
+		if err != nil {
+			break
+		}
+		nums = append(nums, n)
+		if end < len(part) {
+			break
+		}
+	}
+	return nums
+}
+
+var (
+	// goPseudoVersion matches the commit a Go pseudo-version names, as in
+	// v0.0.0-20231010123456-abcdef123456.
+	goPseudoVersion = regexp.MustCompile(`-\d{14}-([0-9a-f]{12})$`)
+
+	// goMajorSuffix matches the last element of a module path such as
+	// github.com/foo/bar/v2.
+	goMajorSuffix = regexp.MustCompile(`^v[0-9]+$`)
+)
+
+// changelogURL is where to read what changed in a package between two
+// versions, or "" for an unknown ecosystem.
+func changelogURL(eco, name, from, to string) string {
+	switch eco {
+	case "go":
+		// A module at the root of a GitHub repository, or its major
+		// version, has its versions as tags
+		parts := strings.Split(name, "/")
+		if parts[0] == "github.com" && (len(parts) == 3 || len(parts) == 4 && goMajorSuffix.MatchString(parts[3])) {
+			repo := "https://" + strings.Join(parts[:3], "/")
+			if from == "" || to == "" {
+				return repo + "/releases"
+			}
+			return repo + "/compare/" + goRef(from) + "..." + goRef(to)
+		}
+		if to == "" {
+			return "https://pkg.go.dev/" + name
+		}
+		return "https://pkg.go.dev/" + name + "@" + to
+	case "npm":
+		return "https://www.npmjs.com/package/" + name + "?activeTab=versions"
+	case "cargo":
+		return "https://crates.io/crates/" + name + "/versions"
+	case "pip":
+		return "https://pypi.org/project/" + name + "/#history"
+	case "gem":
+		return "https://rubygems.org/gems/" + name + "/versions"
+	case "hex":
+		return "https://hex.pm/packages/" + name
+	}
+	return ""
+}
+
+// goRef is the git tag or commit a Go module version is at.
+func goRef(version string) string {
+	version = strings.TrimSuffix(version, "+incompatible")
+	if m := goPseudoVersion.FindStringSubmatch(version); m != nil {
+		return m[1]
+	}
+	return version
+}
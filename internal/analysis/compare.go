@@ -0,0 +1,126 @@
+package analysis
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Comparison is the difference between two analysis runs.
+type Comparison struct {
+	New        []Finding // in the current run only
+	Fixed      []Finding // in the baseline only
+	Persisting []Finding // in both, as reported by the current run
+}
+
+// Compare matches findings between a baseline and a current run. Findings
+// are the same when their pass, file, and message agree; among duplicates,
+// the closest lines pair up, since unrelated edits shift lines between runs.
+func Compare(baseline, current *Results) *Comparison {
+	type key struct{ pass, file, message string }
+	type group struct{ base, cur []Finding }
+	groups := make(map[key]*group)
+	var order []key
+	add := func(f Finding) *group {
+		k := key{f.Pass, f.File, f.Message}
+		g := groups[k]
+		if g == nil {
+			g = &group{}
+			groups[k] = g
+			order = append(order, k)
+		}
+		return g
+	}
+	for _, f := range baseline.Findings {
+		g := add(f)
+		g.base = append(g.base, f)
+	}
+	for _, f := range current.Findings {
+		g := add(f)
+		g.cur = append(g.cur, f)
+	}
+
+	c := &Comparison{}
+	for _, k := range order {
+		g := groups[k]
+		type pair struct{ b, c, dist int }
+		var pairs []pair
+		for i, b := range g.base {
+			for j, f := range g.cur {
+				pairs = append(pairs, pair{i, j, abs(b.Line - f.Line)})
+			}
+		}
+		sort.SliceStable(pairs, func(i, j int) bool { return pairs[i].dist < pairs[j].dist })
+
+		baseUsed := make([]bool, len(g.base))
+		curUsed := make([]bool, len(g.cur))
+		for _, p := range pairs {
+			if !baseUsed[p.b] && !curUsed[p.c] {
+				baseUsed[p.b], curUsed[p.c] = true, true
+				c.Persisting = append(c.Persisting, g.cur[p.c])
+			}
+		}
+		for i, f := range g.base {
+			if !baseUsed[i] {
+				c.Fixed = append(c.Fixed, f)
+			}
+		}
+		for j, f := range g.cur {
+			if !curUsed[j] {
+				c.New = append(c.New, f)
+			}
+		}
+	}
+
+	sortFindings(c.New)
+	sortFindings(c.Fixed)
+	sortFindings(c.Persisting)
+	return c
+}
+
+// Summary describes the comparison in one sentence, e.g. "Introduces 3 new
+// findings (2 high, 1 low), resolves 1; 4 persist".
+func (c *Comparison) Summary() string {
+	if len(c.New) == 0 && len(c.Fixed) == 0 {
+		if len(c.Persisting) == 0 {
+			return "No findings in either run"
+		}
+		return fmt.Sprintf("No new findings; %d persist", len(c.Persisting))
+	}
+
+	var s string
+	if len(c.New) == 0 {
+		s = "Introduces no new findings"
+	} else {
+		s = fmt.Sprintf("Introduces %d new finding(s) (%s)", len(c.New), (&Results{Findings: c.New}).Summary())
+	}
+	if len(c.Fixed) > 0 {
+		s += fmt.Sprintf(", resolves %d", len(c.Fixed))
+	}
+	if len(c.Persisting) > 0 {
+		s += fmt.Sprintf("; %d persist", len(c.Persisting))
+	}
+	return s
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// sortFindings orders findings by risk (highest first), then location.
+func sortFindings(fs []Finding) {
+	sort.SliceStable(fs, func(i, j int) bool {
+		if fs[i].Risk != fs[j].Risk {
+			return fs[i].Risk > fs[j].Risk
+		}
+		if fs[i].File != fs[j].File {
+			return fs[i].File < fs[j].File
+		}
+		if fs[i].Line != fs[j].Line {
+			return fs[i].Line < fs[j].Line
+		}
+		return fs[i].Pass < fs[j].Pass
+	})
+}
@@ -0,0 +1,92 @@
+package analysis
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/bluekeyes/go-gitdiff/gitdiff"
+	"github.com/aezell/agrev/internal/coverage"
+	"github.com/aezell/agrev/internal/diff"
+	"github.com/aezell/agrev/internal/model"
+)
+
+type coverageKey struct{}
+
+// WithCoverage returns a context carrying a coverage report of the changed
+// code, which the coverage pass run with it checks the added lines against.
+func WithCoverage(ctx context.Context, p *coverage.Profile) context.Context {
+	return context.WithValue(ctx, coverageKey{}, p)
+}
+
+// CoveragePass flags added lines a coverage report says no test ran. It
+// needs the report, given with WithCoverage, so on its own it finds
+// nothing.
+func CoveragePass(ds *diff.DiffSet, repoDir string) []Finding {
+	return uncovered(context.Background(), ds, repoDir)
+}
+
+// uncovered is CoveragePass with the report ctx carries. Each run of added
+// lines without coverage is one finding, on its first line; lines the
+// report doesn't instrument, such as comments, don't break a run.
+func uncovered(ctx context.Context, ds *diff.DiffSet, repoDir string) []Finding {
+	p, _ := ctx.Value(coverageKey{}).(*coverage.Profile)
+	if p == nil {
+		return nil
+	}
+
+	var findings []Finding
+	for _, f := range ds.Files {
+		if f.IsDeleted || f.IsBinary || f.AddedLines == 0 {
+			continue
+		}
+		lines, ok := p.Lines(f.Name())
+		if !ok {
+			continue // not code the tests measure
+		}
+
+		start, end := 0, 0
+		flush := func() {
+			if start == 0 {
+				return
+			}
+			msg := fmt.Sprintf("Added line %d isn't covered by tests", start)
+			if end > start {
+				msg = fmt.Sprintf("Added lines %d-%d aren't covered by tests", start, end)
+			}
+			findings = append(findings, Finding{
+				Pass:     "coverage",
+				Rule:     RuleUncovered,
+				File:     f.Name(),
+				Line:     start,
+				Message:  msg,
+				Severity: model.SeverityWarning,
+				Risk:     model.RiskLow,
+			})
+			start, end = 0, 0
+		}
+		for _, frag := range f.Fragments {
+			num := int(frag.NewPosition)
+			for _, l := range frag.Lines {
+				switch l.Op {
+				case gitdiff.OpContext:
+					flush()
+					num++
+				case gitdiff.OpAdd:
+					if covered, instrumented := lines[num]; instrumented {
+						if covered {
+							flush()
+						} else {
+							if start == 0 {
+								start = num
+							}
+							end = num
+						}
+					}
+					num++
+				}
+			}
+			flush()
+		}
+	}
+	return findings
+}
@@ -1,8 +1,9 @@
 package analysis
 
 import (
+	"context"
 	"fmt"
-	"os"
+	"path"
 	"path/filepath"
 	"regexp"
 	"strings"
@@ -33,8 +34,15 @@ var funcDefPatterns = []*regexp.Regexp{
 	regexp.MustCompile(`^\s*defp?\s+(\w+)\s*[(\n]`),
 }
 
-// DeletedCodePass checks for deleted functions and warns if they have test references.
+// DeletedCodePass checks for deleted functions and warns if they have test
+// references. Functions moved to another file aren't deleted.
 func DeletedCodePass(ds *diff.DiffSet, repoDir string) []Finding {
+	return deletedCode(context.Background(), ds, repoDir)
+}
+
+// deletedCode is DeletedCodePass, searching the repository index ctx
+// shares.
+func deletedCode(ctx context.Context, ds *diff.DiffSet, repoDir string) []Finding {
 	var findings []Finding
 
 	for _, f := range ds.Files {
@@ -42,11 +50,15 @@ func DeletedCodePass(ds *diff.DiffSet, repoDir string) []Finding {
 		deletedFuncs := extractDeletedFunctions(f)
 
 		for _, fn := range deletedFuncs {
+			if _, moved := ds.MoveAt(f, fn.line, true); moved {
+				continue
+			}
 			// Search for test references
-			testRefs := findTestReferences(repoDir, name, fn.name)
+			testRefs := findTestReferences(ctx, repoDir, name, fn.name)
 			if len(testRefs) > 0 {
 				findings = append(findings, Finding{
 					Pass:     "deleted",
+					Rule:     RuleDeletedTested,
 					File:     name,
 					Line:     fn.line,
 					Message:  fmt.Sprintf("Deleted function %q is referenced in tests: %s", fn.name, strings.Join(testRefs, ", ")),
@@ -56,6 +68,7 @@ func DeletedCodePass(ds *diff.DiffSet, repoDir string) []Finding {
 			} else {
 				findings = append(findings, Finding{
 					Pass:     "deleted",
+					Rule:     RuleDeleted,
 					File:     name,
 					Line:     fn.line,
 					Message:  fmt.Sprintf("Deleted function: %s", fn.name),
@@ -98,42 +111,19 @@ func extractDeletedFunctions(f *diff.File) []funcInfo {
 	return funcs
 }
 
-func findTestReferences(repoDir, filePath, funcName string) []string {
+// findTestReferences lists the test files next to filePath, or one
+// directory down, that mention funcName.
+func findTestReferences(ctx context.Context, repoDir, filePath, funcName string) []string {
 	if repoDir == "" {
 		return nil
 	}
 
 	var refs []string
 	testPattern := regexp.MustCompile(`\b` + regexp.QuoteMeta(funcName) + `\b`)
-
-	// Determine test file patterns based on language
-	dir := filepath.Dir(filepath.Join(repoDir, filePath))
-	testGlobs := []string{
-		filepath.Join(dir, "*_test.*"),
-		filepath.Join(dir, "test_*"),
-		filepath.Join(dir, "*_spec.*"),
-		filepath.Join(dir, "**", "*_test.*"),
-	}
-
-	for _, pattern := range testGlobs {
-		matches, err := filepath.Glob(pattern)
-		if err != nil {
-			continue
-		}
-		for _, match := range matches {
-			content, err := os.ReadFile(match)
-			if err != nil {
-				continue
-			}
-			if testPattern.Match(content) {
-				rel, _ := filepath.Rel(repoDir, match)
-				if rel == "" {
-					rel = match
-				}
-				refs = append(refs, rel)
-			}
+	for _, f := range repoIndex(ctx, repoDir).testFiles(path.Dir(filePath)) {
+		if testPattern.Match(f.content) {
+			refs = append(refs, filepath.FromSlash(f.path))
 		}
 	}
-
 	return refs
 }
@@ -30,24 +30,29 @@ var depFiles = map[string]string{
 	"mix.lock":           "hex",
 }
 
-// NewDependencyPass detects new dependencies added in the diff.
+// NewDependencyPass detects new dependencies added in the diff, and
+// submodules added, removed, or moved to another commit.
 func NewDependencyPass(ds *diff.DiffSet, repoDir string) []Finding {
 	var findings []Finding
 
 	for _, f := range ds.Files {
 		name := f.Name()
+		if f.IsSubmodule {
+			findings = append(findings, submoduleFinding(f, repoDir))
+			continue
+		}
 		eco, isDep := depFiles[baseName(name)]
 		if !isDep {
 			continue
 		}
 
-		newDeps := extractNewDeps(f, eco)
-		for _, dep := range newDeps {
+		for _, dep := range extractNewDeps(f, eco) {
 			findings = append(findings, Finding{
 				Pass:     "deps",
+				Rule:     RuleNewDependency,
 				File:     name,
-				Line:     dep.line,
-				Message:  fmt.Sprintf("New %s dependency: %s", eco, dep.name),
+				Line:     dep.Line,
+				Message:  fmt.Sprintf("New %s dependency: %s", eco, dep.Name),
 				Severity: model.SeverityWarning,
 				Risk:     model.RiskMedium,
 			})
@@ -57,21 +62,80 @@ func NewDependencyPass(ds *diff.DiffSet, repoDir string) []Finding {
 	return findings
 }
 
-type depInfo struct {
-	name string
-	line int
+// Dependency is a dependency added in a diff's manifests or lockfiles.
+type Dependency struct {
+	Ecosystem string // go, npm, cargo, pip, gem, or hex
+	Name      string
+	Version   string // the version or constraint given, e.g. "v1.2.3" or "^4.17.21"; may be empty
+	File      string
+	Line      int
 }
 
-func extractNewDeps(f *diff.File, ecosystem string) []depInfo {
-	var deps []depInfo
+// NewDependencies returns the dependencies the deps pass reports as new,
+// with the versions they were added at.
+func NewDependencies(ds *diff.DiffSet) []Dependency {
+	var deps []Dependency
+	for _, f := range ds.Files {
+		if eco, ok := depFiles[baseName(f.Name())]; ok && !f.IsSubmodule {
+			deps = append(deps, extractNewDeps(f, eco)...)
+		}
+	}
+	return deps
+}
+
+// submoduleFinding describes a submodule change. A submodule pins a whole
+// repository, so changing it deserves the same look as a new dependency.
+func submoduleFinding(f *diff.File, repoDir string) Finding {
+	short := func(hash string) string { return hash[:min(len(hash), 7)] }
+	var msg string
+	switch {
+	case f.OldCommit == "":
+		msg = fmt.Sprintf("New submodule %s at %s", f.NewName, short(f.NewCommit))
+	case f.NewCommit == "":
+		msg = fmt.Sprintf("Removed submodule %s (was at %s)", f.OldName, short(f.OldCommit))
+	case f.OldCommit == f.NewCommit:
+		msg = fmt.Sprintf("Submodule %s has uncommitted changes", f.NewName)
+	default:
+		msg = fmt.Sprintf("Submodule %s bumped from %s to %s", f.NewName, short(f.OldCommit), short(f.NewCommit))
+		if log, err := diff.SubmoduleLog(repoDir, f); err == nil {
+			var gained, dropped int
+			for _, l := range log {
+				if strings.HasPrefix(l, "<") {
+					dropped++
+				} else {
+					gained++
+				}
+			}
+			msg += fmt.Sprintf(" (%d new commits", gained)
+			if dropped > 0 {
+				msg += fmt.Sprintf(", %d dropped", dropped)
+			}
+			msg += ")"
+		}
+	}
+	if f.Dirty && f.OldCommit != f.NewCommit {
+		msg += "; its working tree has uncommitted changes"
+	}
+	return Finding{
+		Pass:     "deps",
+		Rule:     RuleSubmodule,
+		File:     f.Name(),
+		Message:  msg,
+		Severity: model.SeverityWarning,
+		Risk:     model.RiskMedium,
+	}
+}
+
+func extractNewDeps(f *diff.File, ecosystem string) []Dependency {
+	var deps []Dependency
 
 	for _, frag := range f.Fragments {
 		lineNum := int(frag.NewPosition)
 		for _, line := range frag.Lines {
 			if line.Op == gitdiff.OpAdd {
 				text := strings.TrimSpace(line.Line)
-				if dep := parseDepLine(text, ecosystem); dep != "" {
-					deps = append(deps, depInfo{name: dep, line: lineNum})
+				if name, version := parseDepLine(text, ecosystem); name != "" {
+					deps = append(deps, Dependency{Ecosystem: ecosystem, Name: name, Version: version, File: f.Name(), Line: lineNum})
 				}
 			}
 			if line.Op == gitdiff.OpAdd || line.Op == gitdiff.OpContext {
@@ -83,22 +147,25 @@ func extractNewDeps(f *diff.File, ecosystem string) []depInfo {
 	return deps
 }
 
-func parseDepLine(line, eco string) string {
+// parseDepLine returns the dependency an added line declares, if any, and
+// the version or constraint it gives.
+func parseDepLine(line, eco string) (name, version string) {
 	switch eco {
 	case "go":
 		// go.mod: require github.com/foo/bar v1.2.3
 		// go.mod: \tgithub.com/foo/bar v1.2.3
+		// go.sum: github.com/foo/bar v1.2.3/go.mod h1:...
 		line = strings.TrimSpace(line)
 		if strings.HasPrefix(line, "require ") {
 			parts := strings.Fields(line)
 			if len(parts) >= 3 {
-				return parts[1]
+				return parts[1], parts[2]
 			}
 		}
 		// Inside require block
 		parts := strings.Fields(line)
 		if len(parts) >= 2 && strings.Contains(parts[0], "/") && !strings.HasPrefix(parts[0], "//") {
-			return parts[0]
+			return parts[0], strings.TrimSuffix(parts[1], "/go.mod")
 		}
 
 	case "npm":
@@ -111,7 +178,11 @@ func parseDepLine(line, eco string) string {
 			if name != "" && !strings.HasPrefix(name, "@types/") &&
 				name != "dependencies" && name != "devDependencies" &&
 				name != "peerDependencies" && name != "name" && name != "version" {
-				return name
+				version := strings.Trim(parts[1], `" `)
+				if strings.HasPrefix(version, "{") {
+					version = "" // a lockfile entry; its version follows
+				}
+				return name, version
 			}
 		}
 
@@ -124,7 +195,17 @@ func parseDepLine(line, eco string) string {
 			if name != "" && name != "name" && name != "version" && name != "edition" &&
 				name != "authors" && name != "description" && name != "license" &&
 				!strings.Contains(name, ".") {
-				return name
+				spec := strings.TrimSpace(parts[1])
+				// { version = "1.0", features = [...] }
+				if _, after, ok := strings.Cut(spec, "version"); ok && strings.HasPrefix(spec, "{") {
+					spec = strings.TrimLeft(after, " =")
+				}
+				if strings.HasPrefix(spec, `"`) {
+					if end := strings.Index(spec[1:], `"`); end >= 0 {
+						return name, spec[1 : end+1]
+					}
+				}
+				return name, ""
 			}
 		}
 
@@ -132,26 +213,34 @@ func parseDepLine(line, eco string) string {
 		// requirements.txt: package==1.0.0 or package>=1.0
 		line = strings.TrimSpace(line)
 		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "-") {
-			return ""
+			return "", ""
 		}
 		// Split on version specifiers
 		for _, sep := range []string{"==", ">=", "<=", "!=", "~=", ">"} {
 			if idx := strings.Index(line, sep); idx > 0 {
-				return strings.TrimSpace(line[:idx])
+				version, _, _ := strings.Cut(line[idx:], ";") // environment markers
+				version = strings.TrimSpace(version)
+				if sep == "==" {
+					version = strings.TrimSpace(version[2:])
+				}
+				return strings.TrimSpace(line[:idx]), version
 			}
 		}
 		if !strings.Contains(line, " ") {
-			return line
+			return line, ""
 		}
 
 	case "gem":
 		// Gemfile: gem 'name', '~> 1.0'
 		line = strings.TrimSpace(line)
 		if strings.HasPrefix(line, "gem ") {
-			parts := strings.SplitN(line, ",", 2)
+			parts := strings.Split(line, ",")
 			name := strings.TrimPrefix(parts[0], "gem ")
 			name = strings.Trim(name, `'" `)
-			return name
+			if len(parts) > 1 && !strings.Contains(parts[1], ":") {
+				return name, strings.Trim(parts[1], `'" `)
+			}
+			return name, ""
 		}
 
 	case "hex":
@@ -160,12 +249,17 @@ func parseDepLine(line, eco string) string {
 		if strings.HasPrefix(line, "{:") {
 			end := strings.Index(line, ",")
 			if end > 2 {
-				return strings.TrimPrefix(line[:end], "{:")
+				version, _, _ := strings.Cut(line[end+1:], ",")
+				version = strings.Trim(strings.TrimSpace(version), `"}`)
+				if strings.Contains(version, ":") {
+					version = "" // options, such as a git source
+				}
+				return strings.TrimPrefix(line[:end], "{:"), version
 			}
 		}
 	}
 
-	return ""
+	return "", ""
 }
 
 func baseName(path string) string {
@@ -0,0 +1,138 @@
+package analysis
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/bluekeyes/go-gitdiff/gitdiff"
+	"github.com/aezell/agrev/internal/diff"
+	"github.com/aezell/agrev/internal/model"
+)
+
+// gitleaksCommand is the gitleaks command the gitleaks pass runs.
+var gitleaksCommand = "gitleaks"
+
+// gitleaksLeak is the part of a gitleaks JSON report entry the pass reads.
+type gitleaksLeak struct {
+	RuleID      string `json:"RuleID"`
+	Description string `json:"Description"`
+	File        string `json:"File"`
+	StartLine   int    `json:"StartLine"`
+}
+
+// GitleaksPass scans the lines the change adds for secrets with gitleaks,
+// when it is installed. Where the security pass flags code that handles
+// secrets, gitleaks' rules recognize the secrets themselves, such as an
+// AWS access key or a private key. Findings keep the gitleaks rule's ID.
+func GitleaksPass(ds *diff.DiffSet, repoDir string) []Finding {
+	return gitleaksScan(context.Background(), ds, repoDir)
+}
+
+// gitleaksScan is GitleaksPass, stopping gitleaks when ctx is cancelled.
+func gitleaksScan(ctx context.Context, ds *diff.DiffSet, repoDir string) []Finding {
+	bin, err := exec.LookPath(gitleaksCommand)
+	if err != nil {
+		return nil
+	}
+	dir, err := os.MkdirTemp("", "agrev-gitleaks-")
+	if err != nil {
+		return nil
+	}
+	defer os.RemoveAll(dir)
+
+	// gitleaks scans a copy of the changed files holding only the added
+	// lines, at their line numbers, so it sees neither the rest of the
+	// repository nor the removed lines
+	src := filepath.Join(dir, "src")
+	added := make(map[string]bool)
+	for _, f := range ds.Files {
+		name := f.Name()
+		if f.IsDeleted || f.IsBinary || f.IsSubmodule || f.AddedLines == 0 || !filepath.IsLocal(name) {
+			continue
+		}
+		path := filepath.Join(src, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return nil
+		}
+		if err := os.WriteFile(path, []byte(addedContent(f)), 0o600); err != nil {
+			return nil
+		}
+		added[name] = true
+	}
+	if len(added) == 0 {
+		return nil
+	}
+
+	report := filepath.Join(dir, "report.json")
+	args := []string{"detect", "--no-git", "--source", src, "--report-format", "json", "--report-path", report,
+		"--redact", "--no-banner", "--exit-code", "0"}
+	if repoDir != "" {
+		// The repository's own rules and allowlists
+		cfg := filepath.Join(repoDir, ".gitleaks.toml")
+		if _, err := os.Stat(cfg); err == nil {
+			args = append(args, "--config", cfg)
+		}
+	}
+	if err := exec.CommandContext(ctx, bin, args...).Run(); err != nil {
+		return nil
+	}
+	data, err := os.ReadFile(report)
+	if err != nil {
+		return nil
+	}
+	var leaks []gitleaksLeak
+	if err := json.Unmarshal(data, &leaks); err != nil {
+		return nil
+	}
+
+	var findings []Finding
+	for _, l := range leaks {
+		file := changedFile(l.File, src, added)
+		if file == "" {
+			continue
+		}
+		msg := "Possible secret"
+		if l.Description != "" {
+			msg = fmt.Sprintf("Possible secret: %s", l.Description)
+		}
+		findings = append(findings, Finding{
+			Pass:     "gitleaks",
+			Rule:     l.RuleID,
+			File:     file,
+			Line:     l.StartLine,
+			Message:  msg,
+			Severity: model.SeverityError,
+			Risk:     model.RiskCritical,
+		})
+	}
+	return findings
+}
+
+// addedContent is f's new version with every line but those the change
+// adds left blank.
+func addedContent(f *diff.File) string {
+	var lines []string
+	for _, frag := range f.Fragments {
+		num := int(frag.NewPosition)
+		for _, l := range frag.Lines {
+			if l.Op == gitdiff.OpDelete {
+				continue
+			}
+			for len(lines) < num-1 {
+				lines = append(lines, "")
+			}
+			text := ""
+			if l.Op == gitdiff.OpAdd {
+				text = strings.TrimRight(l.Line, "\r\n")
+			}
+			lines = append(lines, text)
+			num++
+		}
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
@@ -0,0 +1,283 @@
+package analysis
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/aezell/agrev/internal/config"
+	"github.com/aezell/agrev/internal/diff"
+	"github.com/aezell/agrev/internal/model"
+)
+
+// iacCommands are the commands of the scanners the iac pass runs, by the
+// names in config.IaCScanners.
+var iacCommands = map[string]string{
+	"trivy":   "trivy",
+	"checkov": "checkov",
+	"tfsec":   "tfsec",
+}
+
+// k8sAPIVersion and k8sKind match the top-level keys every Kubernetes
+// object has.
+var (
+	k8sAPIVersion = regexp.MustCompile(`(?m)^apiVersion:`)
+	k8sKind       = regexp.MustCompile(`(?m)^kind:`)
+)
+
+// iacResult is a misconfiguration a scanner reports.
+type iacResult struct {
+	file       string
+	start, end int // the lines of the resource; 0 for the whole file
+	rule       string
+	message    string
+	resource   string
+	severity   string
+}
+
+// IaCPass runs an infrastructure-as-code scanner (trivy, checkov, or
+// tfsec, whichever is installed or .agrev.yml names) on the changed
+// Terraform, Kubernetes, and Dockerfiles, and reports the misconfigurations
+// in the resources the change touches. Findings keep the scanner's check
+// ID, e.g. "CKV_AWS_20".
+func IaCPass(ds *diff.DiffSet, repoDir string) []Finding {
+	return iacScan(context.Background(), ds, repoDir)
+}
+
+// iacScan is IaCPass, stopping the scanner when ctx is cancelled.
+func iacScan(ctx context.Context, ds *diff.DiffSet, repoDir string) []Finding {
+	if repoDir == "" {
+		return nil
+	}
+	added := make(map[string]map[int]bool)
+	var files []string
+	terraform := false
+	for _, f := range ds.Files {
+		name := f.Name()
+		if f.IsDeleted || f.IsBinary || f.IsSubmodule || f.AddedLines == 0 || !isIaCFile(repoDir, name) {
+			continue
+		}
+		added[name] = addedLineSet(f)
+		files = append(files, name)
+		terraform = terraform || isTerraform(name)
+	}
+	if len(files) == 0 {
+		return nil
+	}
+
+	cfg, err := config.Load(repoDir)
+	if err != nil {
+		return nil
+	}
+	scanner, bin := cfg.Analysis.IaC.Scanner, ""
+	for _, name := range config.IaCScanners {
+		if scanner != "" && name != scanner {
+			continue
+		}
+		if b, err := exec.LookPath(iacCommands[name]); err == nil {
+			scanner, bin = name, b
+			break
+		}
+	}
+	// tfsec reads only Terraform
+	if bin == "" || scanner == "tfsec" && !terraform {
+		return nil
+	}
+
+	var args []string
+	switch scanner {
+	case "trivy":
+		args = []string{"config", "--format", "json", "--quiet", "--exit-code", "0", "."}
+	case "checkov":
+		args = append([]string{"--quiet", "--compact", "--output", "json", "--soft-fail", "--file"}, files...)
+	case "tfsec":
+		args = []string{".", "--format", "json", "--soft-fail", "--no-colour"}
+	}
+	cmd := exec.CommandContext(ctx, bin, args...)
+	cmd.Dir = repoDir
+	out, _ := cmd.Output()
+	results, err := parseIaCResults(scanner, out)
+	if err != nil {
+		return nil
+	}
+
+	var findings []Finding
+	for _, r := range results {
+		file := changedFile(r.file, repoDir, added)
+		if file == "" {
+			continue
+		}
+		// A misconfigured resource is reported on the first line of it the
+		// change adds; one the change didn't touch was there before
+		line := 0
+		if r.start > 0 {
+			for l := r.start; l <= max(r.end, r.start); l++ {
+				if added[file][l] {
+					line = l
+					break
+				}
+			}
+			if line == 0 {
+				continue
+			}
+		}
+		msg := r.message
+		if r.resource != "" {
+			msg = fmt.Sprintf("%s: %s", r.resource, msg)
+		}
+		risk, severity := iacRisk(r.severity)
+		findings = append(findings, Finding{
+			Pass:     "iac",
+			Rule:     r.rule,
+			File:     file,
+			Line:     line,
+			Message:  strings.Join(strings.Fields(msg), " "),
+			Severity: severity,
+			Risk:     risk,
+		})
+	}
+	return findings
+}
+
+// isIaCFile reports whether name, in the repository at repoDir, is a
+// Terraform file, a Dockerfile, or a Kubernetes manifest.
+func isIaCFile(repoDir, name string) bool {
+	base := path.Base(name)
+	switch {
+	case isTerraform(name):
+		return true
+	case base == "Dockerfile" || base == "Containerfile" || strings.HasPrefix(base, "Dockerfile.") || strings.HasSuffix(base, ".Dockerfile"):
+		return true
+	case strings.HasSuffix(base, ".yaml") || strings.HasSuffix(base, ".yml"):
+		data, err := os.ReadFile(filepath.Join(repoDir, filepath.FromSlash(name)))
+		return err == nil && k8sAPIVersion.Match(data) && k8sKind.Match(data)
+	}
+	return false
+}
+
+func isTerraform(name string) bool {
+	return strings.HasSuffix(name, ".tf") || strings.HasSuffix(name, ".tf.json")
+}
+
+// parseIaCResults reads a scanner's JSON report.
+func parseIaCResults(scanner string, out []byte) ([]iacResult, error) {
+	var results []iacResult
+	switch scanner {
+	case "trivy":
+		var report struct {
+			Results []struct {
+				Target            string `json:"Target"`
+				Misconfigurations []struct {
+					ID            string `json:"ID"`
+					Title         string `json:"Title"`
+					Message       string `json:"Message"`
+					Severity      string `json:"Severity"`
+					Status        string `json:"Status"`
+					CauseMetadata struct {
+						Resource  string `json:"Resource"`
+						StartLine int    `json:"StartLine"`
+						EndLine   int    `json:"EndLine"`
+					} `json:"CauseMetadata"`
+				} `json:"Misconfigurations"`
+			} `json:"Results"`
+		}
+		if err := json.Unmarshal(out, &report); err != nil {
+			return nil, err
+		}
+		for _, res := range report.Results {
+			for _, m := range res.Misconfigurations {
+				if m.Status != "" && m.Status != "FAIL" {
+					continue
+				}
+				msg := m.Message
+				if msg == "" {
+					msg = m.Title
+				}
+				c := m.CauseMetadata
+				results = append(results, iacResult{file: res.Target, start: c.StartLine, end: c.EndLine, rule: m.ID, message: msg, resource: c.Resource, severity: m.Severity})
+			}
+		}
+
+	case "checkov":
+		type checkovReport struct {
+			Results struct {
+				FailedChecks []struct {
+					CheckID       string `json:"check_id"`
+					CheckName     string `json:"check_name"`
+					FilePath      string `json:"file_path"`
+					FileLineRange []int  `json:"file_line_range"`
+					Resource      string `json:"resource"`
+					Severity      string `json:"severity"`
+				} `json:"failed_checks"`
+			} `json:"results"`
+		}
+		// One report per framework; a single framework's isn't in a list
+		var reports []checkovReport
+		if err := json.Unmarshal(out, &reports); err != nil {
+			var one checkovReport
+			if err := json.Unmarshal(out, &one); err != nil {
+				return nil, err
+			}
+			reports = []checkovReport{one}
+		}
+		for _, rep := range reports {
+			for _, c := range rep.Results.FailedChecks {
+				r := iacResult{file: strings.TrimPrefix(c.FilePath, "/"), rule: c.CheckID, message: c.CheckName, resource: c.Resource, severity: c.Severity}
+				if len(c.FileLineRange) == 2 {
+					r.start, r.end = c.FileLineRange[0], c.FileLineRange[1]
+				}
+				results = append(results, r)
+			}
+		}
+
+	case "tfsec":
+		var report struct {
+			Results []struct {
+				RuleID          string `json:"rule_id"`
+				RuleDescription string `json:"rule_description"`
+				Description     string `json:"description"`
+				Severity        string `json:"severity"`
+				Resource        string `json:"resource"`
+				Location        struct {
+					Filename  string `json:"filename"`
+					StartLine int    `json:"start_line"`
+					EndLine   int    `json:"end_line"`
+				} `json:"location"`
+			} `json:"results"`
+		}
+		if err := json.Unmarshal(out, &report); err != nil {
+			return nil, err
+		}
+		for _, res := range report.Results {
+			msg := res.Description
+			if msg == "" {
+				msg = res.RuleDescription
+			}
+			l := res.Location
+			results = append(results, iacResult{file: l.Filename, start: l.StartLine, end: l.EndLine, rule: res.RuleID, message: msg, resource: res.Resource, severity: res.Severity})
+		}
+	}
+	return results, nil
+}
+
+// iacRisk maps a scanner's severity to a risk and severity. Checks without
+// one, as many of checkov's are, are medium.
+func iacRisk(s string) (model.RiskLevel, model.Severity) {
+	switch strings.ToUpper(s) {
+	case "CRITICAL":
+		return model.RiskCritical, model.SeverityError
+	case "HIGH":
+		return model.RiskHigh, model.SeverityError
+	case "LOW":
+		return model.RiskLow, model.SeverityWarning
+	case "INFO":
+		return model.RiskInfo, model.SeverityInfo
+	}
+	return model.RiskMedium, model.SeverityWarning
+}
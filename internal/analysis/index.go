@@ -0,0 +1,166 @@
+package analysis
+
+import (
+	"bytes"
+	"context"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// maxIndexedSize is the largest file the index reads. Bigger ones are
+// generated or minified code, not where references are looked for.
+const maxIndexedSize = 1 << 20
+
+// fileIndex is a repository's source and test files and their contents,
+// read once, by a pool of workers, for all the passes that search the
+// repository. Hidden, vendored, and build output directories are left out.
+type fileIndex struct {
+	files []indexedFile // in the order a walk of the repository finds them
+}
+
+type indexedFile struct {
+	path    string // slash-separated, relative to the repository
+	content []byte
+}
+
+type indexKey struct{}
+
+// lazyIndex is a repository's index, built the first time a pass asks for
+// it.
+type lazyIndex struct {
+	repoDir string
+	once    sync.Once
+	index   *fileIndex
+}
+
+// withIndex returns a context whose passes share one index of repoDir.
+func withIndex(ctx context.Context, repoDir string) context.Context {
+	return context.WithValue(ctx, indexKey{}, &lazyIndex{repoDir: repoDir})
+}
+
+// repoIndex returns the index of repoDir ctx shares, or if it has none, a
+// new one. An index built while ctx is cancelled may be missing files.
+func repoIndex(ctx context.Context, repoDir string) *fileIndex {
+	if lazy, ok := ctx.Value(indexKey{}).(*lazyIndex); ok && lazy.repoDir == repoDir {
+		lazy.once.Do(func() { lazy.index = buildIndex(ctx, repoDir) })
+		return lazy.index
+	}
+	return buildIndex(ctx, repoDir)
+}
+
+// buildIndex walks repoDir for the files to index, then reads them on as
+// many workers as there are CPUs.
+func buildIndex(ctx context.Context, repoDir string) *fileIndex {
+	var paths []string
+	_ = filepath.WalkDir(repoDir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil // skip errors
+		}
+		if ctx.Err() != nil {
+			return filepath.SkipAll
+		}
+		if d.IsDir() {
+			if p != repoDir && skipIndexDir(d.Name()) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() || !isSourceFile(p) && !isTestFile(d.Name()) {
+			return nil
+		}
+		if info, err := d.Info(); err != nil || info.Size() > maxIndexedSize {
+			return nil
+		}
+		paths = append(paths, p)
+		return nil
+	})
+
+	files := make([]indexedFile, len(paths))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for range min(runtime.GOMAXPROCS(0), len(paths)) {
+		wg.Go(func() {
+			for i := range jobs {
+				content, err := os.ReadFile(paths[i])
+				if err != nil {
+					continue
+				}
+				rel, _ := filepath.Rel(repoDir, paths[i])
+				files[i] = indexedFile{path: filepath.ToSlash(rel), content: content}
+			}
+		})
+	}
+	for i := range paths {
+		if ctx.Err() != nil {
+			break
+		}
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	ix := &fileIndex{files: files[:0]}
+	for _, f := range files {
+		if f.path != "" {
+			ix.files = append(ix.files, f)
+		}
+	}
+	return ix
+}
+
+// skipIndexDir reports whether the directory called name is left out of
+// the index.
+func skipIndexDir(name string) bool {
+	return strings.HasPrefix(name, ".") || name == "vendor" || name == "node_modules" || name == "dist" || name == "build"
+}
+
+// testFilePatterns match the names of test files in the languages the
+// passes know.
+var testFilePatterns = []string{"*_test.*", "test_*", "*_spec.*"}
+
+func isTestFile(name string) bool {
+	for _, pat := range testFilePatterns {
+		if ok, _ := path.Match(pat, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// countMatches counts the matches of pattern in the source files other
+// than skip, stopping once the count passes limit. word is a literal the
+// matches contain, so files without it aren't searched.
+func (ix *fileIndex) countMatches(ctx context.Context, pattern *regexp.Regexp, word, skip string, limit int) int {
+	count := 0
+	for _, f := range ix.files {
+		if ctx.Err() != nil || count > limit {
+			break
+		}
+		if f.path == skip || !isSourceFile(f.path) || !bytes.Contains(f.content, []byte(word)) {
+			continue
+		}
+		count += len(pattern.FindAllIndex(f.content, -1))
+	}
+	return count
+}
+
+// testFiles returns the test files in dir and, for *_test.* files, in its
+// subdirectories one level down.
+func (ix *fileIndex) testFiles(dir string) []indexedFile {
+	var out []indexedFile
+	for _, f := range ix.files {
+		name, parent := path.Base(f.path), path.Dir(f.path)
+		if parent == dir && isTestFile(name) {
+			out = append(out, f)
+		} else if ok, _ := path.Match("*_test.*", name); ok && path.Dir(parent) == dir {
+			out = append(out, f)
+		}
+	}
+	return out
+}
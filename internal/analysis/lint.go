@@ -0,0 +1,264 @@
+package analysis
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/aezell/agrev/internal/config"
+	"github.com/aezell/agrev/internal/diff"
+	"github.com/aezell/agrev/internal/model"
+)
+
+// lintReport is one problem a linter reports.
+type lintReport struct {
+	file     string
+	line     int
+	rule     string
+	severity string // "error", "warning", or "info"
+	message  string
+}
+
+// LintPass runs the external linters .agrev.yml configures on the changed
+// files and reports what they find on lines the change adds. Findings'
+// rules are the linter's, prefixed with its name, e.g. "ruff/F401".
+func LintPass(ds *diff.DiffSet, repoDir string) []Finding {
+	return lint(context.Background(), ds, repoDir)
+}
+
+// lint is LintPass, stopping the linter running when ctx is cancelled.
+func lint(ctx context.Context, ds *diff.DiffSet, repoDir string) []Finding {
+	if repoDir == "" {
+		return nil
+	}
+	// The commands come from .agrev.yml, so a change to it could run
+	// anything; its linters wait until it is merged
+	for _, f := range ds.Files {
+		if f.Name() == config.FileName || f.OldName == config.FileName {
+			return nil
+		}
+	}
+	cfg, err := config.Load(repoDir)
+	if err != nil || len(cfg.Analysis.Linters) == 0 {
+		return nil
+	}
+
+	added := make(map[string]map[int]bool)
+	for _, f := range ds.Files {
+		if !f.IsDeleted && !f.IsBinary && !f.IsSubmodule && f.AddedLines > 0 {
+			added[f.Name()] = addedLineSet(f)
+		}
+	}
+
+	var findings []Finding
+	for _, l := range cfg.Analysis.Linters {
+		if ctx.Err() != nil {
+			return findings
+		}
+		var files []string
+		for _, f := range ds.Files {
+			if _, ok := added[f.Name()]; ok && lintMatches(l.Files, f.Name()) {
+				files = append(files, f.Name())
+			}
+		}
+		if len(files) == 0 {
+			continue
+		}
+		findings = append(findings, runLinter(ctx, l, repoDir, files, added)...)
+	}
+	return findings
+}
+
+// runLinter runs a linter on files and returns its reports on the lines
+// the change adds.
+func runLinter(ctx context.Context, l config.LinterConfig, repoDir string, files []string, added map[string]map[int]bool) []Finding {
+	args := l.Command[1:]
+	if !l.WholeRepo {
+		args = append(append([]string{}, args...), files...)
+	}
+	cmd := exec.CommandContext(ctx, l.Command[0], args...)
+	cmd.Dir = repoDir
+	// Linters exit non-zero when they find problems, so the output decides
+	out, _ := cmd.Output()
+	reports, err := parseLintReports(l.Format, out)
+	if err != nil {
+		return nil
+	}
+
+	var findings []Finding
+	for _, r := range reports {
+		file := changedFile(r.file, repoDir, added)
+		if file == "" || !lintMatches(l.Files, file) {
+			continue
+		}
+		if !added[file][r.line] && !l.AllLines {
+			continue
+		}
+		rule := l.Name
+		if r.rule != "" {
+			rule += "/" + r.rule
+		}
+		risk, severity := model.RiskLow, model.SeverityWarning
+		switch r.severity {
+		case "error":
+			risk = model.RiskMedium
+		case "info":
+			risk, severity = model.RiskInfo, model.SeverityInfo
+		}
+		findings = append(findings, Finding{
+			Pass:     "lint",
+			Rule:     rule,
+			File:     file,
+			Line:     r.line,
+			Message:  strings.Join(strings.Fields(r.message), " "),
+			Severity: severity,
+			Risk:     risk,
+		})
+	}
+	return findings
+}
+
+// lintMatches reports whether name matches one of the globs, or there are
+// none. A glob without a slash matches the file name at any depth.
+func lintMatches(globs []string, name string) bool {
+	if len(globs) == 0 {
+		return true
+	}
+	for _, g := range globs {
+		target := name
+		if !strings.Contains(g, "/") {
+			target = path.Base(name)
+		}
+		if ok, _ := path.Match(g, target); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// parseLintReports reads a linter's output in one of
+// config.LinterFormats. Empty output has no reports.
+func parseLintReports(format string, out []byte) ([]lintReport, error) {
+	if len(strings.TrimSpace(string(out))) == 0 {
+		return nil, nil
+	}
+	var reports []lintReport
+	switch format {
+	case "checkstyle":
+		var doc struct {
+			Files []struct {
+				Name   string `xml:"name,attr"`
+				Errors []struct {
+					Line     int    `xml:"line,attr"`
+					Severity string `xml:"severity,attr"`
+					Message  string `xml:"message,attr"`
+					Source   string `xml:"source,attr"`
+				} `xml:"error"`
+			} `xml:"file"`
+		}
+		if err := xml.Unmarshal(out, &doc); err != nil {
+			return nil, err
+		}
+		for _, f := range doc.Files {
+			for _, e := range f.Errors {
+				reports = append(reports, lintReport{file: f.Name, line: e.Line, rule: e.Source, severity: lintSeverity(e.Severity), message: e.Message})
+			}
+		}
+
+	case "eslint":
+		var files []struct {
+			FilePath string `json:"filePath"`
+			Messages []struct {
+				RuleID   string `json:"ruleId"`
+				Severity int    `json:"severity"` // 1 warning, 2 error
+				Message  string `json:"message"`
+				Line     int    `json:"line"`
+			} `json:"messages"`
+		}
+		if err := json.Unmarshal(out, &files); err != nil {
+			return nil, err
+		}
+		for _, f := range files {
+			for _, m := range f.Messages {
+				severity := "warning"
+				if m.Severity == 2 {
+					severity = "error"
+				}
+				reports = append(reports, lintReport{file: f.FilePath, line: m.Line, rule: m.RuleID, severity: severity, message: m.Message})
+			}
+		}
+
+	case "ruff":
+		var diags []struct {
+			Code     string `json:"code"`
+			Message  string `json:"message"`
+			Filename string `json:"filename"`
+			Location struct {
+				Row int `json:"row"`
+			} `json:"location"`
+		}
+		if err := json.Unmarshal(out, &diags); err != nil {
+			return nil, err
+		}
+		for _, d := range diags {
+			reports = append(reports, lintReport{file: d.Filename, line: d.Location.Row, rule: d.Code, severity: "warning", message: d.Message})
+		}
+
+	case "golangci-lint":
+		var doc struct {
+			Issues []struct {
+				FromLinter string `json:"FromLinter"`
+				Text       string `json:"Text"`
+				Severity   string `json:"Severity"`
+				Pos        struct {
+					Filename string `json:"Filename"`
+					Line     int    `json:"Line"`
+				} `json:"Pos"`
+			} `json:"Issues"`
+		}
+		if err := json.Unmarshal(out, &doc); err != nil {
+			return nil, err
+		}
+		for _, i := range doc.Issues {
+			reports = append(reports, lintReport{file: i.Pos.Filename, line: i.Pos.Line, rule: i.FromLinter, severity: lintSeverity(i.Severity), message: i.Text})
+		}
+	}
+	return reports, nil
+}
+
+// lintSeverity reads a severity as linters write it; without one, a
+// report is a warning.
+func lintSeverity(s string) string {
+	switch strings.ToLower(s) {
+	case "error", "fatal":
+		return "error"
+	case "info", "ignore":
+		return "info"
+	}
+	return "warning"
+}
+
+// changedFile is the changed file a tool's report is for, given the path it
+// reports, which may be absolute, or "" if it isn't one of files.
+func changedFile[T any](name, repoDir string, files map[string]T) string {
+	if filepath.IsAbs(name) {
+		if rel, err := filepath.Rel(repoDir, name); err == nil {
+			name = rel
+		}
+	}
+	name = filepath.ToSlash(filepath.Clean(name))
+	if _, ok := files[name]; ok {
+		return name
+	}
+	// repoDir may be reached through a symlink the tool resolved
+	for f := range files {
+		if strings.HasSuffix(name, "/"+f) {
+			return f
+		}
+	}
+	return ""
+}
@@ -0,0 +1,261 @@
+package analysis
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/bluekeyes/go-gitdiff/gitdiff"
+	"github.com/aezell/agrev/internal/diff"
+	"github.com/aezell/agrev/internal/model"
+)
+
+// osvScanner is the osv-scanner command the osv pass runs.
+var osvScanner = "osv-scanner"
+
+// osvLockfiles are the manifests and lockfiles osv-scanner reads.
+var osvLockfiles = map[string]bool{
+	"go.mod":            true,
+	"package-lock.json": true,
+	"yarn.lock":         true,
+	"pnpm-lock.yaml":    true,
+	"Cargo.lock":        true,
+	"requirements.txt":  true,
+	"Pipfile.lock":      true,
+	"poetry.lock":       true,
+	"Gemfile.lock":      true,
+	"mix.lock":          true,
+}
+
+// osvOutput is the part of osv-scanner's JSON output the pass reads.
+type osvOutput struct {
+	Results []struct {
+		Source struct {
+			Path string `json:"path"`
+		} `json:"source"`
+		Packages []struct {
+			Package struct {
+				Name    string `json:"name"`
+				Version string `json:"version"`
+			} `json:"package"`
+			Vulnerabilities []osvVuln `json:"vulnerabilities"`
+			Groups          []struct {
+				IDs         []string `json:"ids"`
+				MaxSeverity string   `json:"max_severity"`
+			} `json:"groups"`
+		} `json:"packages"`
+	} `json:"results"`
+}
+
+type osvVuln struct {
+	ID               string `json:"id"`
+	Summary          string `json:"summary"`
+	DatabaseSpecific struct {
+		Severity string `json:"severity"`
+	} `json:"database_specific"`
+}
+
+// OSVPass looks up the packages the changed lockfiles add or bump in the
+// OSV database, using osv-scanner when it is installed. Where the deps pass
+// says a dependency is new, this one says it is known to be vulnerable.
+func OSVPass(ds *diff.DiffSet, repoDir string) []Finding {
+	return osvScan(context.Background(), ds, repoDir)
+}
+
+// osvScan is OSVPass, stopping osv-scanner when ctx is cancelled.
+func osvScan(ctx context.Context, ds *diff.DiffSet, repoDir string) []Finding {
+	if repoDir == "" {
+		return nil
+	}
+	bin, err := exec.LookPath(osvScanner)
+	if err != nil {
+		return nil
+	}
+
+	// The new version's lines in each changed lockfile's hunks
+	added := make(map[string][][]osvLine)
+	args := []string{"--format", "json"}
+	for _, f := range ds.Files {
+		name := f.Name()
+		if f.IsDeleted || f.IsBinary || f.IsSubmodule || !osvLockfiles[baseName(name)] || f.AddedLines == 0 {
+			continue
+		}
+		added[name] = osvHunkLines(f)
+		args = append(args, "--lockfile", name)
+	}
+	if len(added) == 0 {
+		return nil
+	}
+
+	cmd := exec.CommandContext(ctx, bin, args...)
+	cmd.Dir = repoDir
+	out, err := cmd.Output()
+	// osv-scanner exits 1 when it finds vulnerabilities
+	var exitErr *exec.ExitError
+	if err != nil && !(errors.As(err, &exitErr) && exitErr.ExitCode() == 1) {
+		return nil
+	}
+	var report osvOutput
+	if err := json.Unmarshal(out, &report); err != nil {
+		return nil
+	}
+
+	var findings []Finding
+	for _, res := range report.Results {
+		file := changedFile(res.Source.Path, repoDir, added)
+		if file == "" {
+			continue
+		}
+		for _, pkg := range res.Packages {
+			// Vulnerabilities of packages the change didn't touch were
+			// there before it
+			line := osvChangedLine(added[file], pkg.Package.Name, pkg.Package.Version)
+			if line == 0 {
+				continue
+			}
+			vulns := make(map[string]osvVuln)
+			for _, v := range pkg.Vulnerabilities {
+				vulns[v.ID] = v
+			}
+			for _, g := range pkg.Groups {
+				if len(g.IDs) == 0 {
+					continue
+				}
+				v := vulns[g.IDs[0]]
+				id := g.IDs[0]
+				if others := g.IDs[1:]; len(others) > 0 {
+					id += " (" + strings.Join(others, ", ") + ")"
+				}
+				msg := fmt.Sprintf("%s@%s has a known vulnerability: %s", pkg.Package.Name, pkg.Package.Version, id)
+				if v.Summary != "" {
+					msg += ": " + v.Summary
+				}
+				risk := osvRisk(g.MaxSeverity, v.DatabaseSpecific.Severity)
+				severity := model.SeverityWarning
+				if risk >= model.RiskHigh {
+					severity = model.SeverityError
+				}
+				findings = append(findings, Finding{
+					Pass:     "osv",
+					Rule:     RuleVulnerability,
+					File:     file,
+					Line:     line,
+					Message:  msg,
+					Severity: severity,
+					Risk:     risk,
+				})
+			}
+		}
+	}
+	return findings
+}
+
+// osvLine is a line of a hunk in the new version of a file.
+type osvLine struct {
+	num   int
+	text  string // lowercased, with "_" as "-", as package names compare
+	added bool
+}
+
+// osvHunkLines returns the new version's lines of each of f's hunks.
+func osvHunkLines(f *diff.File) [][]osvLine {
+	var hunks [][]osvLine
+	for _, frag := range f.Fragments {
+		var lines []osvLine
+		num := int(frag.NewPosition)
+		for _, l := range frag.Lines {
+			if l.Op == gitdiff.OpDelete {
+				continue
+			}
+			lines = append(lines, osvLine{num: num, text: osvNormalize(l.Line), added: l.Op == gitdiff.OpAdd})
+			num++
+		}
+		hunks = append(hunks, lines)
+	}
+	return hunks
+}
+
+// osvChangedLine returns the added line that adds or bumps a package, or 0
+// if the change leaves it alone. Lockfiles such as Cargo.lock give the
+// version on a line of its own under the name, so an added line with the
+// version counts when the name is on one of the lines just above it.
+func osvChangedLine(hunks [][]osvLine, name, version string) int {
+	name, version = osvNormalize(name), osvNormalize(version)
+	for _, lines := range hunks {
+		for i, l := range lines {
+			if !l.added {
+				continue
+			}
+			if osvMentions(l.text, name) {
+				return l.num
+			}
+			if version == "" || !osvMentions(l.text, version) {
+				continue
+			}
+			for _, above := range lines[max(0, i-3):i] {
+				if osvMentions(above.text, name) {
+					return l.num
+				}
+			}
+		}
+	}
+	return 0
+}
+
+// osvNormalize lowercases s and writes "_" as "-": registries such as
+// PyPI compare package names that way.
+func osvNormalize(s string) string {
+	return strings.ReplaceAll(strings.ToLower(s), "_", "-")
+}
+
+// osvMentions reports whether s appears in line as a whole word, not as part
+// of a longer name or version.
+func osvMentions(line, s string) bool {
+	if s == "" {
+		return false
+	}
+	word := func(c byte) bool {
+		return c == '-' || c == '.' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z'
+	}
+	for i := 0; ; {
+		j := strings.Index(line[i:], s)
+		if j < 0 {
+			return false
+		}
+		start, end := i+j, i+j+len(s)
+		if (start == 0 || !word(line[start-1])) && (end == len(line) || !word(line[end])) {
+			return true
+		}
+		i = start + 1
+	}
+}
+
+// osvRisk maps a vulnerability's CVSS score, or without one the severity
+// its advisory database gives, to a risk level.
+func osvRisk(score, severity string) model.RiskLevel {
+	if s, err := strconv.ParseFloat(score, 64); err == nil {
+		switch {
+		case s >= 9:
+			return model.RiskCritical
+		case s >= 7:
+			return model.RiskHigh
+		case s >= 4:
+			return model.RiskMedium
+		default:
+			return model.RiskLow
+		}
+	}
+	switch strings.ToUpper(severity) {
+	case "CRITICAL":
+		return model.RiskCritical
+	case "HIGH":
+		return model.RiskHigh
+	case "LOW":
+		return model.RiskLow
+	}
+	return model.RiskMedium
+}
@@ -0,0 +1,91 @@
+package analysis
+
+import "strings"
+
+// Rule IDs of the built-in checks. An ID names one kind of finding and
+// never changes meaning, so suppressions, baselines, and SARIF reports can
+// refer to it across releases; retired checks keep their IDs unused.
+const (
+	RuleNewDependency = "AGV-DEP-001"
+	RuleSubmodule     = "AGV-DEP-002"
+	RuleVulnerability = "AGV-DEP-003"
+
+	RuleAuthentication = "AGV-SEC-001"
+	RuleAuthorization  = "AGV-SEC-002"
+	RuleSQL            = "AGV-SEC-003"
+	RuleCryptography   = "AGV-SEC-004"
+	RuleFileSystem     = "AGV-SEC-005"
+	RuleSecrets        = "AGV-SEC-006"
+	RuleNetwork        = "AGV-SEC-007"
+	RuleExec           = "AGV-SEC-008"
+
+	RuleSchemaFile = "AGV-SCH-001"
+	RuleDDL        = "AGV-SCH-002"
+
+	RuleDeletedTested = "AGV-DEL-001"
+	RuleDeleted       = "AGV-DEL-002"
+
+	RuleBroadException = "AGV-ANT-001"
+	RuleCommentedCode  = "AGV-ANT-002"
+	RuleTodo           = "AGV-ANT-003"
+	RuleLineEndings    = "AGV-ANT-004"
+	RuleDuplicate      = "AGV-ANT-005"
+
+	RuleHighBlastRadius = "AGV-BLR-001"
+	RuleBlastRadius     = "AGV-BLR-002"
+
+	RuleUncovered = "AGV-COV-001"
+)
+
+// DocsURL is the page documenting the rules. Each rule has a section on it
+// anchored by its lowercased ID.
+const DocsURL = "https://github.com/aezell/agrev/blob/main/docs/rules.md"
+
+// Rule describes one of the built-in checks.
+type Rule struct {
+	ID   string // e.g. "AGV-SEC-003"
+	Pass string // the pass that reports it
+	Name string // a short description, e.g. "SQL or database access changed"
+}
+
+// URL returns the rule's documentation.
+func (r Rule) URL() string {
+	return DocsURL + "#" + strings.ToLower(r.ID)
+}
+
+// Rules lists the built-in rules by ID.
+var Rules = []Rule{
+	{RuleNewDependency, "deps", "New dependency added"},
+	{RuleSubmodule, "deps", "Submodule added, removed, or moved"},
+	{RuleVulnerability, "osv", "Dependency with a known vulnerability"},
+	{RuleAuthentication, "security", "Authentication code changed"},
+	{RuleAuthorization, "security", "Authorization code changed"},
+	{RuleSQL, "security", "SQL or database access changed"},
+	{RuleCryptography, "security", "Cryptography code changed"},
+	{RuleFileSystem, "security", "File system writes or path handling changed"},
+	{RuleSecrets, "security", "Environment variables or secrets changed"},
+	{RuleNetwork, "security", "Network or TLS settings changed"},
+	{RuleExec, "security", "Subprocess or eval call changed"},
+	{RuleSchemaFile, "schema", "Schema or migration file changed"},
+	{RuleDDL, "schema", "DDL statement added"},
+	{RuleDeletedTested, "deleted", "Deleted function still referenced in tests"},
+	{RuleDeleted, "deleted", "Function deleted"},
+	{RuleBroadException, "anti_patterns", "Broad exception handling"},
+	{RuleCommentedCode, "anti_patterns", "Commented-out code"},
+	{RuleTodo, "anti_patterns", "TODO or FIXME marker left in"},
+	{RuleLineEndings, "anti_patterns", "Line endings converted"},
+	{RuleDuplicate, "anti_patterns", "Near-duplicate code block"},
+	{RuleHighBlastRadius, "blast_radius", "Changed function with many references"},
+	{RuleBlastRadius, "blast_radius", "Changed function with several references"},
+	{RuleUncovered, "coverage", "Added lines not covered by tests"},
+}
+
+// LookupRule returns the built-in rule with the ID.
+func LookupRule(id string) (Rule, bool) {
+	for _, r := range Rules {
+		if r.ID == id {
+			return r, true
+		}
+	}
+	return Rule{}, false
+}
@@ -51,6 +51,7 @@ func SchemaChangePass(ds *diff.DiffSet, repoDir string) []Finding {
 				risk := model.RiskHigh
 				findings = append(findings, Finding{
 					Pass:     "schema",
+					Rule:     RuleSchemaFile,
 					File:     name,
 					Message:  fmt.Sprintf("Changes to %s file", sp.description),
 					Severity: model.SeverityWarning,
@@ -80,6 +81,7 @@ func checkDDL(f *diff.File) []Finding {
 					if pat.MatchString(text) {
 						findings = append(findings, Finding{
 							Pass:     "schema",
+							Rule:     RuleDDL,
 							File:     name,
 							Line:     lineNum,
 							Message:  fmt.Sprintf("DDL statement: %s", strings.TrimSpace(text)),
@@ -13,11 +13,13 @@ import (
 // Security-sensitive patterns grouped by category.
 var securityPatterns = []struct {
 	category string
+	rule     string
 	patterns []*regexp.Regexp
 	risk     model.RiskLevel
 }{
 	{
 		category: "authentication",
+		rule:     RuleAuthentication,
 		patterns: compilePatterns(
 			`(?i)(auth|login|logout|signin|signup|password|credential|token|jwt|oauth|session|cookie)`,
 		),
@@ -25,6 +27,7 @@ var securityPatterns = []struct {
 	},
 	{
 		category: "authorization",
+		rule:     RuleAuthorization,
 		patterns: compilePatterns(
 			`(?i)(permission|role|access.?control|rbac|acl|authorize|forbidden|is.?admin|can.?access)`,
 		),
@@ -32,6 +35,7 @@ var securityPatterns = []struct {
 	},
 	{
 		category: "SQL/database",
+		rule:     RuleSQL,
 		patterns: compilePatterns(
 			`(?i)(db\.exec|db\.query|\.prepare\(|raw.?sql|sql\.)`,
 			`(?i)(\bSELECT\b|\bINSERT\b|\bUPDATE\b|\bDELETE\b|\bDROP\b|\bALTER\b)\s`,
@@ -41,6 +45,7 @@ var securityPatterns = []struct {
 	},
 	{
 		category: "cryptography",
+		rule:     RuleCryptography,
 		patterns: compilePatterns(
 			`(?i)(encrypt|decrypt|hash|hmac|cipher|aes|rsa|sha256|sha512|bcrypt|argon|scrypt|pbkdf)`,
 			`(?i)(private.?key|public.?key|secret.?key|signing.?key|crypto\.)`,
@@ -49,6 +54,7 @@ var securityPatterns = []struct {
 	},
 	{
 		category: "file system",
+		rule:     RuleFileSystem,
 		patterns: compilePatterns(
 			`(?i)(os\.Remove|os\.Rename|os\.Chmod|os\.Chown|os\.MkdirAll|os\.WriteFile|ioutil\.WriteFile)`,
 			`(?i)(unlink|rmdir|chmod|chown|write_file|open.*[\"']w)`,
@@ -58,6 +64,7 @@ var securityPatterns = []struct {
 	},
 	{
 		category: "environment/secrets",
+		rule:     RuleSecrets,
 		patterns: compilePatterns(
 			`(?i)(os\.Getenv|os\.environ|process\.env|ENV\[|getenv)`,
 			`(?i)(api.?key|secret|password|token)\s*[:=]`,
@@ -67,6 +74,7 @@ var securityPatterns = []struct {
 	},
 	{
 		category: "network/HTTP",
+		rule:     RuleNetwork,
 		patterns: compilePatterns(
 			`(?i)(http\.ListenAndServe|\.listen\(|cors|origin|allow.?origin)`,
 			`(?i)(tls\.Config|InsecureSkipVerify|disable.?ssl|verify.?ssl.*false)`,
@@ -75,6 +83,7 @@ var securityPatterns = []struct {
 	},
 	{
 		category: "subprocess/exec",
+		rule:     RuleExec,
 		patterns: compilePatterns(
 			`(?i)(exec\.Command|os\.system|subprocess|child_process|shell_exec|system\()`,
 			`(?i)(eval\(|exec\(|compile\()`,
@@ -116,6 +125,7 @@ func SecuritySurfacePass(ds *diff.DiffSet, repoDir string) []Finding {
 							if re.MatchString(text) {
 								findings = append(findings, Finding{
 									Pass:     "security",
+									Rule:     sp.rule,
 									File:     name,
 									Line:     lineNum,
 									Message:  fmt.Sprintf("Security-sensitive change (%s): %s", sp.category, strings.TrimSpace(text)),
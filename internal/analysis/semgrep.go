@@ -0,0 +1,176 @@
+package analysis
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/bluekeyes/go-gitdiff/gitdiff"
+	"github.com/aezell/agrev/internal/config"
+	"github.com/aezell/agrev/internal/diff"
+	"github.com/aezell/agrev/internal/model"
+)
+
+// semgrepCommand is the semgrep command the semgrep pass runs.
+var semgrepCommand = "semgrep"
+
+// semgrepDefaults are the rules the semgrep pass looks for in the
+// repository when .agrev.yml names none.
+var semgrepDefaults = []string{".semgrep.yml", ".semgrep.yaml", ".semgrep"}
+
+// semgrepOutput is the part of semgrep's JSON output the pass reads.
+type semgrepOutput struct {
+	Results []struct {
+		CheckID string `json:"check_id"`
+		Path    string `json:"path"`
+		Start   struct {
+			Line int `json:"line"`
+		} `json:"start"`
+		End struct {
+			Line int `json:"line"`
+		} `json:"end"`
+		Extra struct {
+			Message  string `json:"message"`
+			Severity string `json:"severity"`
+		} `json:"extra"`
+	} `json:"results"`
+}
+
+// SemgrepPass runs the repository's semgrep rules on the changed files,
+// when semgrep is installed, and reports the matches on lines the change
+// adds. Findings keep the semgrep rule's ID, so overrides and triage can
+// refer to it.
+func SemgrepPass(ds *diff.DiffSet, repoDir string) []Finding {
+	return semgrepScan(context.Background(), ds, repoDir)
+}
+
+// semgrepScan is SemgrepPass, stopping semgrep when ctx is cancelled.
+func semgrepScan(ctx context.Context, ds *diff.DiffSet, repoDir string) []Finding {
+	if repoDir == "" {
+		return nil
+	}
+	bin, err := exec.LookPath(semgrepCommand)
+	if err != nil {
+		return nil
+	}
+	cfg, err := config.Load(repoDir)
+	if err != nil {
+		return nil
+	}
+	rules := cfg.Analysis.Semgrep.Config
+	if len(rules) == 0 {
+		for _, name := range semgrepDefaults {
+			if _, err := os.Stat(filepath.Join(repoDir, name)); err == nil {
+				rules = []string{name}
+				break
+			}
+		}
+	}
+	if len(rules) == 0 {
+		return nil
+	}
+
+	// The lines each changed file gains
+	added := make(map[string]map[int]bool)
+	var targets []string
+	for _, f := range ds.Files {
+		if f.IsDeleted || f.IsBinary || f.IsSubmodule || f.AddedLines == 0 {
+			continue
+		}
+		added[f.Name()] = addedLineSet(f)
+		targets = append(targets, f.Name())
+	}
+	if len(targets) == 0 {
+		return nil
+	}
+
+	args := []string{"scan", "--json", "--quiet", "--metrics", "off"}
+	for _, r := range rules {
+		args = append(args, "--config", r)
+	}
+	args = append(args, "--")
+	cmd := exec.CommandContext(ctx, bin, append(args, targets...)...)
+	cmd.Dir = repoDir
+	out, err := cmd.Output()
+	// semgrep exits 1 when it finds matches and is told to fail on them
+	var exitErr *exec.ExitError
+	if err != nil && !(errors.As(err, &exitErr) && exitErr.ExitCode() == 1) {
+		return nil
+	}
+	var report semgrepOutput
+	if err := json.Unmarshal(out, &report); err != nil {
+		return nil
+	}
+
+	var findings []Finding
+	for _, res := range report.Results {
+		file := changedFile(res.Path, repoDir, added)
+		if file == "" {
+			continue
+		}
+		lines := added[file]
+		// A match that spans lines counts from the first the change adds
+		line := 0
+		for l := res.Start.Line; l <= max(res.End.Line, res.Start.Line); l++ {
+			if lines[l] {
+				line = l
+				break
+			}
+		}
+		if line == 0 {
+			if !cfg.Analysis.Semgrep.AllLines {
+				continue
+			}
+			line = res.Start.Line
+		}
+		risk, severity := semgrepRisk(res.Extra.Severity)
+		findings = append(findings, Finding{
+			Pass:     "semgrep",
+			Rule:     res.CheckID,
+			File:     file,
+			Line:     line,
+			Message:  strings.Join(strings.Fields(res.Extra.Message), " "),
+			Severity: severity,
+			Risk:     risk,
+		})
+	}
+	return findings
+}
+
+// addedLineSet returns the numbers, in the new version, of the lines f
+// adds.
+func addedLineSet(f *diff.File) map[int]bool {
+	lines := make(map[int]bool)
+	for _, frag := range f.Fragments {
+		num := int(frag.NewPosition)
+		for _, l := range frag.Lines {
+			switch l.Op {
+			case gitdiff.OpAdd:
+				lines[num] = true
+				num++
+			case gitdiff.OpContext:
+				num++
+			}
+		}
+	}
+	return lines
+}
+
+// semgrepRisk maps a semgrep rule's severity to a risk and severity. Rules
+// give ERROR, WARNING, or INFO, or in newer rulesets CRITICAL, HIGH,
+// MEDIUM, or LOW.
+func semgrepRisk(s string) (model.RiskLevel, model.Severity) {
+	switch strings.ToUpper(s) {
+	case "CRITICAL":
+		return model.RiskCritical, model.SeverityError
+	case "ERROR", "HIGH":
+		return model.RiskHigh, model.SeverityError
+	case "INFO", "LOW":
+		return model.RiskLow, model.SeverityInfo
+	}
+	return model.RiskMedium, model.SeverityWarning
+}
@@ -0,0 +1,186 @@
+// Package annotate attaches notes from the agent's trace to the lines of a
+// diff: links to the steps that wrote them, the reasoning the agent gave
+// before writing them, and risks in how they were written.
+package annotate
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/bluekeyes/go-gitdiff/gitdiff"
+	"github.com/aezell/agrev/internal/diff"
+	"github.com/aezell/agrev/internal/model"
+	"github.com/aezell/agrev/internal/trace"
+)
+
+// maxMessage is the longest a step's summary is quoted in a message.
+const maxMessage = 100
+
+// Build annotates the files of ds from t, by file name, each file's
+// annotations ordered by line. A nil trace yields none.
+func Build(ds *diff.DiffSet, t *trace.Trace) map[string][]model.Annotation {
+	out := make(map[string][]model.Annotation)
+	if t == nil {
+		return out
+	}
+	for _, f := range ds.Files {
+		if anns := File(f, t); len(anns) > 0 {
+			out[f.Name()] = anns
+		}
+	}
+	return out
+}
+
+// File annotates one file of a diff from t:
+//
+//   - a trace link to each write or edit step whose new text is in the
+//     file's changes, on the lines it wrote (the whole file for writes);
+//   - an info note with the reasoning the agent gave just before it;
+//   - a risk note when the agent changed a file that already existed
+//     without reading it first.
+func File(f *diff.File, t *trace.Trace) []model.Annotation {
+	if t == nil || f.IsDeleted {
+		return nil
+	}
+	name := f.Name()
+	lines := newLines(f)
+
+	var anns []model.Annotation
+	read, changed := false, false
+	for i, s := range t.Steps {
+		if !s.Touches(name) {
+			continue
+		}
+		switch s.Type {
+		case trace.StepFileRead:
+			read = true
+			continue
+		case trace.StepFileWrite, trace.StepFileEdit:
+		default:
+			continue
+		}
+
+		rng, ok := stepRange(s, lines)
+		if !ok {
+			continue
+		}
+		anns = append(anns, model.Annotation{
+			Type:    model.AnnotationTraceLink,
+			Range:   rng,
+			Message: fmt.Sprintf("step %d (%s): %s", i+1, s.Type, oneLine(s.Summary)),
+			Step:    i + 1,
+		})
+		if why := reasoning(t.Steps, i); why != "" {
+			anns = append(anns, model.Annotation{
+				Type:    model.AnnotationInfo,
+				Range:   rng,
+				Message: "agent: " + why,
+			})
+		}
+		if !read && !changed && !f.IsNew {
+			what := "edited"
+			if s.Type == trace.StepFileWrite {
+				what = "overwritten"
+			}
+			anns = append(anns, model.Annotation{
+				Type:     model.AnnotationRisk,
+				Range:    rng,
+				Message:  what + " without reading the file first",
+				Severity: model.SeverityWarning,
+			})
+		}
+		changed = true
+	}
+	sort.SliceStable(anns, func(i, j int) bool { return anns[i].Range.Start < anns[j].Range.Start })
+	return anns
+}
+
+// stepRange finds the new-file lines a step wrote. Writes replace the whole
+// file; edits are found by their new text among the changed lines, and are
+// left out when it isn't there, as a later step replaced it.
+func stepRange(s trace.Step, lines map[int]newLine) (model.LineRange, bool) {
+	if s.LineStart > 0 {
+		return model.LineRange{Start: s.LineStart, End: max(s.LineEnd, s.LineStart)}, true
+	}
+	if s.Type == trace.StepFileWrite {
+		return model.LineRange{}, true
+	}
+
+	text := strings.Split(strings.TrimRight(s.NewString, "\n"), "\n")
+	first := -1
+	for i, l := range text {
+		if strings.TrimSpace(l) != "" {
+			first = i
+			break
+		}
+	}
+	if first < 0 {
+		return model.LineRange{}, false
+	}
+	want := strings.TrimSpace(text[first])
+
+	var starts []int
+	for n, l := range lines {
+		if strings.TrimSpace(l.text) == want {
+			starts = append(starts, n)
+		}
+	}
+	sort.Ints(starts)
+	for _, n := range starts {
+		rng := model.LineRange{Start: max(1, n-first), End: n - first + len(text) - 1}
+		for m := rng.Start; m <= rng.End; m++ {
+			if lines[m].added {
+				return rng, true
+			}
+		}
+	}
+	return model.LineRange{}, false
+}
+
+// reasoning returns the summary of the plan or reasoning step the agent
+// took just before step i, if it took one since its last change or the
+// user's last message.
+func reasoning(steps []trace.Step, i int) string {
+	for j := i - 1; j >= 0; j-- {
+		switch steps[j].Type {
+		case trace.StepPlan, trace.StepReasoning:
+			return oneLine(steps[j].Summary)
+		case trace.StepFileWrite, trace.StepFileEdit, trace.StepUserMessage:
+			return ""
+		}
+	}
+	return ""
+}
+
+// newLine is a line in the new version of a file that the diff shows.
+type newLine struct {
+	text  string
+	added bool
+}
+
+// newLines maps the new-file line numbers the diff of f shows to their
+// text.
+func newLines(f *diff.File) map[int]newLine {
+	lines := make(map[int]newLine)
+	for _, frag := range f.Fragments {
+		n := int(frag.NewPosition)
+		for _, l := range frag.Lines {
+			if l.Op == gitdiff.OpDelete {
+				continue
+			}
+			lines[n] = newLine{text: strings.TrimRight(l.Line, "\r\n"), added: l.Op == gitdiff.OpAdd}
+			n++
+		}
+	}
+	return lines
+}
+
+// oneLine shortens a summary to its first line, cut to maxMessage runes.
+func oneLine(s string) string {
+	s, _, _ = strings.Cut(strings.TrimSpace(s), "\n")
+	if r := []rune(s); len(r) > maxMessage {
+		return string(r[:maxMessage-1]) + "…"
+	}
+	return s
+}
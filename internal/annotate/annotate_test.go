@@ -0,0 +1,66 @@
+package annotate
+
+import (
+	"testing"
+
+	"github.com/aezell/agrev/internal/diff"
+	"github.com/aezell/agrev/internal/model"
+	"github.com/aezell/agrev/internal/trace"
+)
+
+const testDiff = `diff --git a/auth.go b/auth.go
+--- a/auth.go
++++ b/auth.go
+@@ -1,4 +1,6 @@
+ package auth
+
+ func Check() {
++	token := os.Getenv("TOKEN")
++	_ = token
+ }
+diff --git a/notes.md b/notes.md
+new file mode 100644
+--- /dev/null
++++ b/notes.md
+@@ -0,0 +1 @@
++# Notes
+`
+
+func TestBuild(t *testing.T) {
+	ds, err := diff.Parse(testDiff)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	tr := &trace.Trace{Steps: []trace.Step{
+		{Type: trace.StepReasoning, Summary: "Read the token from the environment\nso it isn't hardcoded"},
+		{Type: trace.StepFileEdit, Summary: "Edit auth.go", FilePath: "/work/auth.go", NewString: "\n\ttoken := os.Getenv(\"TOKEN\")\n\t_ = token\n"},
+		{Type: trace.StepFileEdit, Summary: "Edit auth.go again", FilePath: "/work/auth.go", NewString: "\tsomething := replaced()\n"},
+		{Type: trace.StepFileWrite, Summary: "Write notes.md", FilePath: "notes.md"},
+	}}
+
+	anns := Build(ds, tr)
+	auth := anns["auth.go"]
+	if len(auth) != 3 {
+		t.Fatalf("expected a link, the reasoning, and a risk note on auth.go, got %+v", auth)
+	}
+	link := auth[0]
+	if link.Type != model.AnnotationTraceLink || link.Step != 2 || link.Range != (model.LineRange{Start: 3, End: 5}) {
+		t.Errorf("unexpected trace link %+v", link)
+	}
+	if auth[1].Type != model.AnnotationInfo || auth[1].Message != "agent: Read the token from the environment" {
+		t.Errorf("unexpected reasoning %+v", auth[1])
+	}
+	if auth[2].Type != model.AnnotationRisk || auth[2].Message != "edited without reading the file first" {
+		t.Errorf("unexpected risk note %+v", auth[2])
+	}
+
+	// The file is new, so there was nothing to read
+	notes := anns["notes.md"]
+	if len(notes) != 1 || notes[0].Range != (model.LineRange{}) || notes[0].Message != "step 4 (write): Write notes.md" {
+		t.Errorf("expected a file-level link on notes.md, got %+v", notes)
+	}
+
+	if got := Build(ds, nil); len(got) != 0 {
+		t.Errorf("expected no annotations without a trace, got %+v", got)
+	}
+}
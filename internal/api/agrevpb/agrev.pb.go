@@ -0,0 +1,1984 @@
+// gRPC interface to the agrev analysis engine, served by 'agrev serve
+// --grpc-port'. It mirrors the HTTP API's stateless endpoints; when tokens
+// are configured, calls need an "authorization: Bearer <token>" metadata
+// entry.
+//
+// Regenerate the Go code from this directory with protoc-gen-go and
+// protoc-gen-go-grpc:
+//
+//	protoc --go_out=. --go_opt=paths=source_relative \
+//	  --go-grpc_out=. --go-grpc_opt=paths=source_relative agrev.proto
+
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.11
+// 	protoc        (unknown)
+// source: agrev.proto
+
+package agrevpb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type LineOp int32
+
+const (
+	LineOp_LINE_OP_UNSPECIFIED LineOp = 0
+	LineOp_LINE_OP_CONTEXT     LineOp = 1
+	LineOp_LINE_OP_ADD         LineOp = 2
+	LineOp_LINE_OP_DELETE      LineOp = 3
+)
+
+// Enum value maps for LineOp.
+var (
+	LineOp_name = map[int32]string{
+		0: "LINE_OP_UNSPECIFIED",
+		1: "LINE_OP_CONTEXT",
+		2: "LINE_OP_ADD",
+		3: "LINE_OP_DELETE",
+	}
+	LineOp_value = map[string]int32{
+		"LINE_OP_UNSPECIFIED": 0,
+		"LINE_OP_CONTEXT":     1,
+		"LINE_OP_ADD":         2,
+		"LINE_OP_DELETE":      3,
+	}
+)
+
+func (x LineOp) Enum() *LineOp {
+	p := new(LineOp)
+	*p = x
+	return p
+}
+
+func (x LineOp) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (LineOp) Descriptor() protoreflect.EnumDescriptor {
+	return file_agrev_proto_enumTypes[0].Descriptor()
+}
+
+func (LineOp) Type() protoreflect.EnumType {
+	return &file_agrev_proto_enumTypes[0]
+}
+
+func (x LineOp) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use LineOp.Descriptor instead.
+func (LineOp) EnumDescriptor() ([]byte, []int) {
+	return file_agrev_proto_rawDescGZIP(), []int{0}
+}
+
+type RiskLevel int32
+
+const (
+	RiskLevel_RISK_LEVEL_UNSPECIFIED RiskLevel = 0
+	RiskLevel_RISK_LEVEL_INFO        RiskLevel = 1
+	RiskLevel_RISK_LEVEL_LOW         RiskLevel = 2
+	RiskLevel_RISK_LEVEL_MEDIUM      RiskLevel = 3
+	RiskLevel_RISK_LEVEL_HIGH        RiskLevel = 4
+	RiskLevel_RISK_LEVEL_CRITICAL    RiskLevel = 5
+)
+
+// Enum value maps for RiskLevel.
+var (
+	RiskLevel_name = map[int32]string{
+		0: "RISK_LEVEL_UNSPECIFIED",
+		1: "RISK_LEVEL_INFO",
+		2: "RISK_LEVEL_LOW",
+		3: "RISK_LEVEL_MEDIUM",
+		4: "RISK_LEVEL_HIGH",
+		5: "RISK_LEVEL_CRITICAL",
+	}
+	RiskLevel_value = map[string]int32{
+		"RISK_LEVEL_UNSPECIFIED": 0,
+		"RISK_LEVEL_INFO":        1,
+		"RISK_LEVEL_LOW":         2,
+		"RISK_LEVEL_MEDIUM":      3,
+		"RISK_LEVEL_HIGH":        4,
+		"RISK_LEVEL_CRITICAL":    5,
+	}
+)
+
+func (x RiskLevel) Enum() *RiskLevel {
+	p := new(RiskLevel)
+	*p = x
+	return p
+}
+
+func (x RiskLevel) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (RiskLevel) Descriptor() protoreflect.EnumDescriptor {
+	return file_agrev_proto_enumTypes[1].Descriptor()
+}
+
+func (RiskLevel) Type() protoreflect.EnumType {
+	return &file_agrev_proto_enumTypes[1]
+}
+
+func (x RiskLevel) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use RiskLevel.Descriptor instead.
+func (RiskLevel) EnumDescriptor() ([]byte, []int) {
+	return file_agrev_proto_rawDescGZIP(), []int{1}
+}
+
+type Severity int32
+
+const (
+	Severity_SEVERITY_UNSPECIFIED Severity = 0
+	Severity_SEVERITY_INFO        Severity = 1
+	Severity_SEVERITY_WARNING     Severity = 2
+	Severity_SEVERITY_ERROR       Severity = 3
+)
+
+// Enum value maps for Severity.
+var (
+	Severity_name = map[int32]string{
+		0: "SEVERITY_UNSPECIFIED",
+		1: "SEVERITY_INFO",
+		2: "SEVERITY_WARNING",
+		3: "SEVERITY_ERROR",
+	}
+	Severity_value = map[string]int32{
+		"SEVERITY_UNSPECIFIED": 0,
+		"SEVERITY_INFO":        1,
+		"SEVERITY_WARNING":     2,
+		"SEVERITY_ERROR":       3,
+	}
+)
+
+func (x Severity) Enum() *Severity {
+	p := new(Severity)
+	*p = x
+	return p
+}
+
+func (x Severity) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (Severity) Descriptor() protoreflect.EnumDescriptor {
+	return file_agrev_proto_enumTypes[2].Descriptor()
+}
+
+func (Severity) Type() protoreflect.EnumType {
+	return &file_agrev_proto_enumTypes[2]
+}
+
+func (x Severity) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use Severity.Descriptor instead.
+func (Severity) EnumDescriptor() ([]byte, []int) {
+	return file_agrev_proto_rawDescGZIP(), []int{2}
+}
+
+type StepType int32
+
+const (
+	StepType_STEP_TYPE_UNSPECIFIED StepType = 0
+	StepType_STEP_TYPE_PLAN        StepType = 1
+	StepType_STEP_TYPE_REASONING   StepType = 2
+	StepType_STEP_TYPE_READ        StepType = 3
+	StepType_STEP_TYPE_WRITE       StepType = 4
+	StepType_STEP_TYPE_EDIT        StepType = 5
+	StepType_STEP_TYPE_BASH        StepType = 6
+	StepType_STEP_TYPE_RESULT      StepType = 7
+	StepType_STEP_TYPE_USER        StepType = 8
+)
+
+// Enum value maps for StepType.
+var (
+	StepType_name = map[int32]string{
+		0: "STEP_TYPE_UNSPECIFIED",
+		1: "STEP_TYPE_PLAN",
+		2: "STEP_TYPE_REASONING",
+		3: "STEP_TYPE_READ",
+		4: "STEP_TYPE_WRITE",
+		5: "STEP_TYPE_EDIT",
+		6: "STEP_TYPE_BASH",
+		7: "STEP_TYPE_RESULT",
+		8: "STEP_TYPE_USER",
+	}
+	StepType_value = map[string]int32{
+		"STEP_TYPE_UNSPECIFIED": 0,
+		"STEP_TYPE_PLAN":        1,
+		"STEP_TYPE_REASONING":   2,
+		"STEP_TYPE_READ":        3,
+		"STEP_TYPE_WRITE":       4,
+		"STEP_TYPE_EDIT":        5,
+		"STEP_TYPE_BASH":        6,
+		"STEP_TYPE_RESULT":      7,
+		"STEP_TYPE_USER":        8,
+	}
+)
+
+func (x StepType) Enum() *StepType {
+	p := new(StepType)
+	*p = x
+	return p
+}
+
+func (x StepType) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (StepType) Descriptor() protoreflect.EnumDescriptor {
+	return file_agrev_proto_enumTypes[3].Descriptor()
+}
+
+func (StepType) Type() protoreflect.EnumType {
+	return &file_agrev_proto_enumTypes[3]
+}
+
+func (x StepType) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use StepType.Descriptor instead.
+func (StepType) EnumDescriptor() ([]byte, []int) {
+	return file_agrev_proto_rawDescGZIP(), []int{3}
+}
+
+type DecisionState int32
+
+const (
+	DecisionState_DECISION_STATE_PENDING  DecisionState = 0
+	DecisionState_DECISION_STATE_APPROVED DecisionState = 1
+	DecisionState_DECISION_STATE_REJECTED DecisionState = 2
+)
+
+// Enum value maps for DecisionState.
+var (
+	DecisionState_name = map[int32]string{
+		0: "DECISION_STATE_PENDING",
+		1: "DECISION_STATE_APPROVED",
+		2: "DECISION_STATE_REJECTED",
+	}
+	DecisionState_value = map[string]int32{
+		"DECISION_STATE_PENDING":  0,
+		"DECISION_STATE_APPROVED": 1,
+		"DECISION_STATE_REJECTED": 2,
+	}
+)
+
+func (x DecisionState) Enum() *DecisionState {
+	p := new(DecisionState)
+	*p = x
+	return p
+}
+
+func (x DecisionState) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (DecisionState) Descriptor() protoreflect.EnumDescriptor {
+	return file_agrev_proto_enumTypes[4].Descriptor()
+}
+
+func (DecisionState) Type() protoreflect.EnumType {
+	return &file_agrev_proto_enumTypes[4]
+}
+
+func (x DecisionState) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use DecisionState.Descriptor instead.
+func (DecisionState) EnumDescriptor() ([]byte, []int) {
+	return file_agrev_proto_rawDescGZIP(), []int{4}
+}
+
+type ParseRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Diff          string                 `protobuf:"bytes,1,opt,name=diff,proto3" json:"diff,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ParseRequest) Reset() {
+	*x = ParseRequest{}
+	mi := &file_agrev_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ParseRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ParseRequest) ProtoMessage() {}
+
+func (x *ParseRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_agrev_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ParseRequest.ProtoReflect.Descriptor instead.
+func (*ParseRequest) Descriptor() ([]byte, []int) {
+	return file_agrev_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *ParseRequest) GetDiff() string {
+	if x != nil {
+		return x.Diff
+	}
+	return ""
+}
+
+type DiffSet struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Files         []*File                `protobuf:"bytes,1,rep,name=files,proto3" json:"files,omitempty"`
+	Stats         *DiffStats             `protobuf:"bytes,2,opt,name=stats,proto3" json:"stats,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DiffSet) Reset() {
+	*x = DiffSet{}
+	mi := &file_agrev_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DiffSet) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DiffSet) ProtoMessage() {}
+
+func (x *DiffSet) ProtoReflect() protoreflect.Message {
+	mi := &file_agrev_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DiffSet.ProtoReflect.Descriptor instead.
+func (*DiffSet) Descriptor() ([]byte, []int) {
+	return file_agrev_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *DiffSet) GetFiles() []*File {
+	if x != nil {
+		return x.Files
+	}
+	return nil
+}
+
+func (x *DiffSet) GetStats() *DiffStats {
+	if x != nil {
+		return x.Stats
+	}
+	return nil
+}
+
+type DiffStats struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Files         int32                  `protobuf:"varint,1,opt,name=files,proto3" json:"files,omitempty"`
+	Added         int32                  `protobuf:"varint,2,opt,name=added,proto3" json:"added,omitempty"`
+	Deleted       int32                  `protobuf:"varint,3,opt,name=deleted,proto3" json:"deleted,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DiffStats) Reset() {
+	*x = DiffStats{}
+	mi := &file_agrev_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DiffStats) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DiffStats) ProtoMessage() {}
+
+func (x *DiffStats) ProtoReflect() protoreflect.Message {
+	mi := &file_agrev_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DiffStats.ProtoReflect.Descriptor instead.
+func (*DiffStats) Descriptor() ([]byte, []int) {
+	return file_agrev_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *DiffStats) GetFiles() int32 {
+	if x != nil {
+		return x.Files
+	}
+	return 0
+}
+
+func (x *DiffStats) GetAdded() int32 {
+	if x != nil {
+		return x.Added
+	}
+	return 0
+}
+
+func (x *DiffStats) GetDeleted() int32 {
+	if x != nil {
+		return x.Deleted
+	}
+	return 0
+}
+
+type File struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	OldName       string                 `protobuf:"bytes,2,opt,name=old_name,json=oldName,proto3" json:"old_name,omitempty"`
+	NewName       string                 `protobuf:"bytes,3,opt,name=new_name,json=newName,proto3" json:"new_name,omitempty"`
+	IsNew         bool                   `protobuf:"varint,4,opt,name=is_new,json=isNew,proto3" json:"is_new,omitempty"`
+	IsDeleted     bool                   `protobuf:"varint,5,opt,name=is_deleted,json=isDeleted,proto3" json:"is_deleted,omitempty"`
+	IsRenamed     bool                   `protobuf:"varint,6,opt,name=is_renamed,json=isRenamed,proto3" json:"is_renamed,omitempty"`
+	IsBinary      bool                   `protobuf:"varint,7,opt,name=is_binary,json=isBinary,proto3" json:"is_binary,omitempty"`
+	AddedLines    int32                  `protobuf:"varint,8,opt,name=added_lines,json=addedLines,proto3" json:"added_lines,omitempty"`
+	DeletedLines  int32                  `protobuf:"varint,9,opt,name=deleted_lines,json=deletedLines,proto3" json:"deleted_lines,omitempty"`
+	Hunks         []*Hunk                `protobuf:"bytes,10,rep,name=hunks,proto3" json:"hunks,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *File) Reset() {
+	*x = File{}
+	mi := &file_agrev_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *File) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*File) ProtoMessage() {}
+
+func (x *File) ProtoReflect() protoreflect.Message {
+	mi := &file_agrev_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use File.ProtoReflect.Descriptor instead.
+func (*File) Descriptor() ([]byte, []int) {
+	return file_agrev_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *File) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *File) GetOldName() string {
+	if x != nil {
+		return x.OldName
+	}
+	return ""
+}
+
+func (x *File) GetNewName() string {
+	if x != nil {
+		return x.NewName
+	}
+	return ""
+}
+
+func (x *File) GetIsNew() bool {
+	if x != nil {
+		return x.IsNew
+	}
+	return false
+}
+
+func (x *File) GetIsDeleted() bool {
+	if x != nil {
+		return x.IsDeleted
+	}
+	return false
+}
+
+func (x *File) GetIsRenamed() bool {
+	if x != nil {
+		return x.IsRenamed
+	}
+	return false
+}
+
+func (x *File) GetIsBinary() bool {
+	if x != nil {
+		return x.IsBinary
+	}
+	return false
+}
+
+func (x *File) GetAddedLines() int32 {
+	if x != nil {
+		return x.AddedLines
+	}
+	return 0
+}
+
+func (x *File) GetDeletedLines() int32 {
+	if x != nil {
+		return x.DeletedLines
+	}
+	return 0
+}
+
+func (x *File) GetHunks() []*Hunk {
+	if x != nil {
+		return x.Hunks
+	}
+	return nil
+}
+
+// Hunk is a hunk of a file. Its index within the file identifies it in
+// hunk-level decisions.
+type Hunk struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Index         int32                  `protobuf:"varint,1,opt,name=index,proto3" json:"index,omitempty"`
+	Header        string                 `protobuf:"bytes,2,opt,name=header,proto3" json:"header,omitempty"`
+	Section       string                 `protobuf:"bytes,3,opt,name=section,proto3" json:"section,omitempty"` // enclosing function or heading, if git found one
+	OldStart      int64                  `protobuf:"varint,4,opt,name=old_start,json=oldStart,proto3" json:"old_start,omitempty"`
+	OldLines      int64                  `protobuf:"varint,5,opt,name=old_lines,json=oldLines,proto3" json:"old_lines,omitempty"`
+	NewStart      int64                  `protobuf:"varint,6,opt,name=new_start,json=newStart,proto3" json:"new_start,omitempty"`
+	NewLines      int64                  `protobuf:"varint,7,opt,name=new_lines,json=newLines,proto3" json:"new_lines,omitempty"`
+	Lines         []*Line                `protobuf:"bytes,8,rep,name=lines,proto3" json:"lines,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Hunk) Reset() {
+	*x = Hunk{}
+	mi := &file_agrev_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Hunk) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Hunk) ProtoMessage() {}
+
+func (x *Hunk) ProtoReflect() protoreflect.Message {
+	mi := &file_agrev_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Hunk.ProtoReflect.Descriptor instead.
+func (*Hunk) Descriptor() ([]byte, []int) {
+	return file_agrev_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *Hunk) GetIndex() int32 {
+	if x != nil {
+		return x.Index
+	}
+	return 0
+}
+
+func (x *Hunk) GetHeader() string {
+	if x != nil {
+		return x.Header
+	}
+	return ""
+}
+
+func (x *Hunk) GetSection() string {
+	if x != nil {
+		return x.Section
+	}
+	return ""
+}
+
+func (x *Hunk) GetOldStart() int64 {
+	if x != nil {
+		return x.OldStart
+	}
+	return 0
+}
+
+func (x *Hunk) GetOldLines() int64 {
+	if x != nil {
+		return x.OldLines
+	}
+	return 0
+}
+
+func (x *Hunk) GetNewStart() int64 {
+	if x != nil {
+		return x.NewStart
+	}
+	return 0
+}
+
+func (x *Hunk) GetNewLines() int64 {
+	if x != nil {
+		return x.NewLines
+	}
+	return 0
+}
+
+func (x *Hunk) GetLines() []*Line {
+	if x != nil {
+		return x.Lines
+	}
+	return nil
+}
+
+type Line struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Op            LineOp                 `protobuf:"varint,1,opt,name=op,proto3,enum=agrev.v1.LineOp" json:"op,omitempty"`
+	Text          string                 `protobuf:"bytes,2,opt,name=text,proto3" json:"text,omitempty"`                    // without the trailing newline
+	OldNum        int32                  `protobuf:"varint,3,opt,name=old_num,json=oldNum,proto3" json:"old_num,omitempty"` // 0 for added lines
+	NewNum        int32                  `protobuf:"varint,4,opt,name=new_num,json=newNum,proto3" json:"new_num,omitempty"` // 0 for deleted lines
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Line) Reset() {
+	*x = Line{}
+	mi := &file_agrev_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Line) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Line) ProtoMessage() {}
+
+func (x *Line) ProtoReflect() protoreflect.Message {
+	mi := &file_agrev_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Line.ProtoReflect.Descriptor instead.
+func (*Line) Descriptor() ([]byte, []int) {
+	return file_agrev_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *Line) GetOp() LineOp {
+	if x != nil {
+		return x.Op
+	}
+	return LineOp_LINE_OP_UNSPECIFIED
+}
+
+func (x *Line) GetText() string {
+	if x != nil {
+		return x.Text
+	}
+	return ""
+}
+
+func (x *Line) GetOldNum() int32 {
+	if x != nil {
+		return x.OldNum
+	}
+	return 0
+}
+
+func (x *Line) GetNewNum() int32 {
+	if x != nil {
+		return x.NewNum
+	}
+	return 0
+}
+
+type AnalyzeRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Diff          string                 `protobuf:"bytes,1,opt,name=diff,proto3" json:"diff,omitempty"`
+	RepoDir       string                 `protobuf:"bytes,2,opt,name=repo_dir,json=repoDir,proto3" json:"repo_dir,omitempty"` // enables passes that read the repository, like blast_radius
+	Skip          []string               `protobuf:"bytes,3,rep,name=skip,proto3" json:"skip,omitempty"`                      // pass names to skip
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AnalyzeRequest) Reset() {
+	*x = AnalyzeRequest{}
+	mi := &file_agrev_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AnalyzeRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AnalyzeRequest) ProtoMessage() {}
+
+func (x *AnalyzeRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_agrev_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AnalyzeRequest.ProtoReflect.Descriptor instead.
+func (*AnalyzeRequest) Descriptor() ([]byte, []int) {
+	return file_agrev_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *AnalyzeRequest) GetDiff() string {
+	if x != nil {
+		return x.Diff
+	}
+	return ""
+}
+
+func (x *AnalyzeRequest) GetRepoDir() string {
+	if x != nil {
+		return x.RepoDir
+	}
+	return ""
+}
+
+func (x *AnalyzeRequest) GetSkip() []string {
+	if x != nil {
+		return x.Skip
+	}
+	return nil
+}
+
+type AnalyzeResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Summary       string                 `protobuf:"bytes,1,opt,name=summary,proto3" json:"summary,omitempty"`
+	MaxRisk       RiskLevel              `protobuf:"varint,2,opt,name=max_risk,json=maxRisk,proto3,enum=agrev.v1.RiskLevel" json:"max_risk,omitempty"`
+	Findings      []*Finding             `protobuf:"bytes,3,rep,name=findings,proto3" json:"findings,omitempty"`
+	Stats         *DiffStats             `protobuf:"bytes,4,opt,name=stats,proto3" json:"stats,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AnalyzeResponse) Reset() {
+	*x = AnalyzeResponse{}
+	mi := &file_agrev_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AnalyzeResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AnalyzeResponse) ProtoMessage() {}
+
+func (x *AnalyzeResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_agrev_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AnalyzeResponse.ProtoReflect.Descriptor instead.
+func (*AnalyzeResponse) Descriptor() ([]byte, []int) {
+	return file_agrev_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *AnalyzeResponse) GetSummary() string {
+	if x != nil {
+		return x.Summary
+	}
+	return ""
+}
+
+func (x *AnalyzeResponse) GetMaxRisk() RiskLevel {
+	if x != nil {
+		return x.MaxRisk
+	}
+	return RiskLevel_RISK_LEVEL_UNSPECIFIED
+}
+
+func (x *AnalyzeResponse) GetFindings() []*Finding {
+	if x != nil {
+		return x.Findings
+	}
+	return nil
+}
+
+func (x *AnalyzeResponse) GetStats() *DiffStats {
+	if x != nil {
+		return x.Stats
+	}
+	return nil
+}
+
+type AnalyzeEvent struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Types that are valid to be assigned to Event:
+	//
+	//	*AnalyzeEvent_Parsed
+	//	*AnalyzeEvent_Pass
+	//	*AnalyzeEvent_Done
+	Event         isAnalyzeEvent_Event `protobuf_oneof:"event"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AnalyzeEvent) Reset() {
+	*x = AnalyzeEvent{}
+	mi := &file_agrev_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AnalyzeEvent) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AnalyzeEvent) ProtoMessage() {}
+
+func (x *AnalyzeEvent) ProtoReflect() protoreflect.Message {
+	mi := &file_agrev_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AnalyzeEvent.ProtoReflect.Descriptor instead.
+func (*AnalyzeEvent) Descriptor() ([]byte, []int) {
+	return file_agrev_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *AnalyzeEvent) GetEvent() isAnalyzeEvent_Event {
+	if x != nil {
+		return x.Event
+	}
+	return nil
+}
+
+func (x *AnalyzeEvent) GetParsed() *DiffSet {
+	if x != nil {
+		if x, ok := x.Event.(*AnalyzeEvent_Parsed); ok {
+			return x.Parsed
+		}
+	}
+	return nil
+}
+
+func (x *AnalyzeEvent) GetPass() *PassResult {
+	if x != nil {
+		if x, ok := x.Event.(*AnalyzeEvent_Pass); ok {
+			return x.Pass
+		}
+	}
+	return nil
+}
+
+func (x *AnalyzeEvent) GetDone() *AnalyzeDone {
+	if x != nil {
+		if x, ok := x.Event.(*AnalyzeEvent_Done); ok {
+			return x.Done
+		}
+	}
+	return nil
+}
+
+type isAnalyzeEvent_Event interface {
+	isAnalyzeEvent_Event()
+}
+
+type AnalyzeEvent_Parsed struct {
+	Parsed *DiffSet `protobuf:"bytes,1,opt,name=parsed,proto3,oneof"`
+}
+
+type AnalyzeEvent_Pass struct {
+	Pass *PassResult `protobuf:"bytes,2,opt,name=pass,proto3,oneof"`
+}
+
+type AnalyzeEvent_Done struct {
+	Done *AnalyzeDone `protobuf:"bytes,3,opt,name=done,proto3,oneof"`
+}
+
+func (*AnalyzeEvent_Parsed) isAnalyzeEvent_Event() {}
+
+func (*AnalyzeEvent_Pass) isAnalyzeEvent_Event() {}
+
+func (*AnalyzeEvent_Done) isAnalyzeEvent_Event() {}
+
+type PassResult struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Pass          string                 `protobuf:"bytes,1,opt,name=pass,proto3" json:"pass,omitempty"`
+	Findings      []*Finding             `protobuf:"bytes,2,rep,name=findings,proto3" json:"findings,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PassResult) Reset() {
+	*x = PassResult{}
+	mi := &file_agrev_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PassResult) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PassResult) ProtoMessage() {}
+
+func (x *PassResult) ProtoReflect() protoreflect.Message {
+	mi := &file_agrev_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PassResult.ProtoReflect.Descriptor instead.
+func (*PassResult) Descriptor() ([]byte, []int) {
+	return file_agrev_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *PassResult) GetPass() string {
+	if x != nil {
+		return x.Pass
+	}
+	return ""
+}
+
+func (x *PassResult) GetFindings() []*Finding {
+	if x != nil {
+		return x.Findings
+	}
+	return nil
+}
+
+type AnalyzeDone struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Summary       string                 `protobuf:"bytes,1,opt,name=summary,proto3" json:"summary,omitempty"`
+	MaxRisk       RiskLevel              `protobuf:"varint,2,opt,name=max_risk,json=maxRisk,proto3,enum=agrev.v1.RiskLevel" json:"max_risk,omitempty"`
+	Total         int32                  `protobuf:"varint,3,opt,name=total,proto3" json:"total,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AnalyzeDone) Reset() {
+	*x = AnalyzeDone{}
+	mi := &file_agrev_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AnalyzeDone) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AnalyzeDone) ProtoMessage() {}
+
+func (x *AnalyzeDone) ProtoReflect() protoreflect.Message {
+	mi := &file_agrev_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AnalyzeDone.ProtoReflect.Descriptor instead.
+func (*AnalyzeDone) Descriptor() ([]byte, []int) {
+	return file_agrev_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *AnalyzeDone) GetSummary() string {
+	if x != nil {
+		return x.Summary
+	}
+	return ""
+}
+
+func (x *AnalyzeDone) GetMaxRisk() RiskLevel {
+	if x != nil {
+		return x.MaxRisk
+	}
+	return RiskLevel_RISK_LEVEL_UNSPECIFIED
+}
+
+func (x *AnalyzeDone) GetTotal() int32 {
+	if x != nil {
+		return x.Total
+	}
+	return 0
+}
+
+type Finding struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Pass          string                 `protobuf:"bytes,1,opt,name=pass,proto3" json:"pass,omitempty"`
+	File          string                 `protobuf:"bytes,2,opt,name=file,proto3" json:"file,omitempty"`
+	Line          int32                  `protobuf:"varint,3,opt,name=line,proto3" json:"line,omitempty"` // 0 for file-level findings
+	Message       string                 `protobuf:"bytes,4,opt,name=message,proto3" json:"message,omitempty"`
+	Severity      Severity               `protobuf:"varint,5,opt,name=severity,proto3,enum=agrev.v1.Severity" json:"severity,omitempty"`
+	Risk          RiskLevel              `protobuf:"varint,6,opt,name=risk,proto3,enum=agrev.v1.RiskLevel" json:"risk,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Finding) Reset() {
+	*x = Finding{}
+	mi := &file_agrev_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Finding) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Finding) ProtoMessage() {}
+
+func (x *Finding) ProtoReflect() protoreflect.Message {
+	mi := &file_agrev_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Finding.ProtoReflect.Descriptor instead.
+func (*Finding) Descriptor() ([]byte, []int) {
+	return file_agrev_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *Finding) GetPass() string {
+	if x != nil {
+		return x.Pass
+	}
+	return ""
+}
+
+func (x *Finding) GetFile() string {
+	if x != nil {
+		return x.File
+	}
+	return ""
+}
+
+func (x *Finding) GetLine() int32 {
+	if x != nil {
+		return x.Line
+	}
+	return 0
+}
+
+func (x *Finding) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+func (x *Finding) GetSeverity() Severity {
+	if x != nil {
+		return x.Severity
+	}
+	return Severity_SEVERITY_UNSPECIFIED
+}
+
+func (x *Finding) GetRisk() RiskLevel {
+	if x != nil {
+		return x.Risk
+	}
+	return RiskLevel_RISK_LEVEL_UNSPECIFIED
+}
+
+type LoadTraceRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Path          string                 `protobuf:"bytes,1,opt,name=path,proto3" json:"path,omitempty"`     // trace file; empty to detect one for repo_dir
+	Format        string                 `protobuf:"bytes,2,opt,name=format,proto3" json:"format,omitempty"` // claude-code, aider, or generic; empty to guess from the file
+	RepoDir       string                 `protobuf:"bytes,3,opt,name=repo_dir,json=repoDir,proto3" json:"repo_dir,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *LoadTraceRequest) Reset() {
+	*x = LoadTraceRequest{}
+	mi := &file_agrev_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *LoadTraceRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LoadTraceRequest) ProtoMessage() {}
+
+func (x *LoadTraceRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_agrev_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LoadTraceRequest.ProtoReflect.Descriptor instead.
+func (*LoadTraceRequest) Descriptor() ([]byte, []int) {
+	return file_agrev_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *LoadTraceRequest) GetPath() string {
+	if x != nil {
+		return x.Path
+	}
+	return ""
+}
+
+func (x *LoadTraceRequest) GetFormat() string {
+	if x != nil {
+		return x.Format
+	}
+	return ""
+}
+
+func (x *LoadTraceRequest) GetRepoDir() string {
+	if x != nil {
+		return x.RepoDir
+	}
+	return ""
+}
+
+type Trace struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Source        string                 `protobuf:"bytes,1,opt,name=source,proto3" json:"source,omitempty"`
+	SessionId     string                 `protobuf:"bytes,2,opt,name=session_id,json=sessionId,proto3" json:"session_id,omitempty"`
+	StartTime     *timestamppb.Timestamp `protobuf:"bytes,3,opt,name=start_time,json=startTime,proto3" json:"start_time,omitempty"`
+	EndTime       *timestamppb.Timestamp `protobuf:"bytes,4,opt,name=end_time,json=endTime,proto3" json:"end_time,omitempty"`
+	Summary       string                 `protobuf:"bytes,5,opt,name=summary,proto3" json:"summary,omitempty"`
+	FilesChanged  []string               `protobuf:"bytes,6,rep,name=files_changed,json=filesChanged,proto3" json:"files_changed,omitempty"`
+	Steps         []*Step                `protobuf:"bytes,7,rep,name=steps,proto3" json:"steps,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Trace) Reset() {
+	*x = Trace{}
+	mi := &file_agrev_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Trace) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Trace) ProtoMessage() {}
+
+func (x *Trace) ProtoReflect() protoreflect.Message {
+	mi := &file_agrev_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Trace.ProtoReflect.Descriptor instead.
+func (*Trace) Descriptor() ([]byte, []int) {
+	return file_agrev_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *Trace) GetSource() string {
+	if x != nil {
+		return x.Source
+	}
+	return ""
+}
+
+func (x *Trace) GetSessionId() string {
+	if x != nil {
+		return x.SessionId
+	}
+	return ""
+}
+
+func (x *Trace) GetStartTime() *timestamppb.Timestamp {
+	if x != nil {
+		return x.StartTime
+	}
+	return nil
+}
+
+func (x *Trace) GetEndTime() *timestamppb.Timestamp {
+	if x != nil {
+		return x.EndTime
+	}
+	return nil
+}
+
+func (x *Trace) GetSummary() string {
+	if x != nil {
+		return x.Summary
+	}
+	return ""
+}
+
+func (x *Trace) GetFilesChanged() []string {
+	if x != nil {
+		return x.FilesChanged
+	}
+	return nil
+}
+
+func (x *Trace) GetSteps() []*Step {
+	if x != nil {
+		return x.Steps
+	}
+	return nil
+}
+
+type Step struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Type          StepType               `protobuf:"varint,1,opt,name=type,proto3,enum=agrev.v1.StepType" json:"type,omitempty"`
+	Timestamp     *timestamppb.Timestamp `protobuf:"bytes,2,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	Summary       string                 `protobuf:"bytes,3,opt,name=summary,proto3" json:"summary,omitempty"`
+	Detail        string                 `protobuf:"bytes,4,opt,name=detail,proto3" json:"detail,omitempty"`
+	FilePath      string                 `protobuf:"bytes,5,opt,name=file_path,json=filePath,proto3" json:"file_path,omitempty"`
+	OldString     string                 `protobuf:"bytes,6,opt,name=old_string,json=oldString,proto3" json:"old_string,omitempty"`
+	NewString     string                 `protobuf:"bytes,7,opt,name=new_string,json=newString,proto3" json:"new_string,omitempty"`
+	Command       string                 `protobuf:"bytes,8,opt,name=command,proto3" json:"command,omitempty"`
+	ExitCode      int32                  `protobuf:"varint,9,opt,name=exit_code,json=exitCode,proto3" json:"exit_code,omitempty"`
+	Output        string                 `protobuf:"bytes,10,opt,name=output,proto3" json:"output,omitempty"`
+	LineStart     int32                  `protobuf:"varint,11,opt,name=line_start,json=lineStart,proto3" json:"line_start,omitempty"`
+	LineEnd       int32                  `protobuf:"varint,12,opt,name=line_end,json=lineEnd,proto3" json:"line_end,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Step) Reset() {
+	*x = Step{}
+	mi := &file_agrev_proto_msgTypes[14]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Step) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Step) ProtoMessage() {}
+
+func (x *Step) ProtoReflect() protoreflect.Message {
+	mi := &file_agrev_proto_msgTypes[14]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Step.ProtoReflect.Descriptor instead.
+func (*Step) Descriptor() ([]byte, []int) {
+	return file_agrev_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *Step) GetType() StepType {
+	if x != nil {
+		return x.Type
+	}
+	return StepType_STEP_TYPE_UNSPECIFIED
+}
+
+func (x *Step) GetTimestamp() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Timestamp
+	}
+	return nil
+}
+
+func (x *Step) GetSummary() string {
+	if x != nil {
+		return x.Summary
+	}
+	return ""
+}
+
+func (x *Step) GetDetail() string {
+	if x != nil {
+		return x.Detail
+	}
+	return ""
+}
+
+func (x *Step) GetFilePath() string {
+	if x != nil {
+		return x.FilePath
+	}
+	return ""
+}
+
+func (x *Step) GetOldString() string {
+	if x != nil {
+		return x.OldString
+	}
+	return ""
+}
+
+func (x *Step) GetNewString() string {
+	if x != nil {
+		return x.NewString
+	}
+	return ""
+}
+
+func (x *Step) GetCommand() string {
+	if x != nil {
+		return x.Command
+	}
+	return ""
+}
+
+func (x *Step) GetExitCode() int32 {
+	if x != nil {
+		return x.ExitCode
+	}
+	return 0
+}
+
+func (x *Step) GetOutput() string {
+	if x != nil {
+		return x.Output
+	}
+	return ""
+}
+
+func (x *Step) GetLineStart() int32 {
+	if x != nil {
+		return x.LineStart
+	}
+	return 0
+}
+
+func (x *Step) GetLineEnd() int32 {
+	if x != nil {
+		return x.LineEnd
+	}
+	return 0
+}
+
+type ApplyDecisionsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Diff          string                 `protobuf:"bytes,1,opt,name=diff,proto3" json:"diff,omitempty"`
+	Decisions     []*Decision            `protobuf:"bytes,2,rep,name=decisions,proto3" json:"decisions,omitempty"` // files and hunks without one are pending
+	Comments      []*Comment             `protobuf:"bytes,3,rep,name=comments,proto3" json:"comments,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ApplyDecisionsRequest) Reset() {
+	*x = ApplyDecisionsRequest{}
+	mi := &file_agrev_proto_msgTypes[15]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ApplyDecisionsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ApplyDecisionsRequest) ProtoMessage() {}
+
+func (x *ApplyDecisionsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_agrev_proto_msgTypes[15]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ApplyDecisionsRequest.ProtoReflect.Descriptor instead.
+func (*ApplyDecisionsRequest) Descriptor() ([]byte, []int) {
+	return file_agrev_proto_rawDescGZIP(), []int{15}
+}
+
+func (x *ApplyDecisionsRequest) GetDiff() string {
+	if x != nil {
+		return x.Diff
+	}
+	return ""
+}
+
+func (x *ApplyDecisionsRequest) GetDecisions() []*Decision {
+	if x != nil {
+		return x.Decisions
+	}
+	return nil
+}
+
+func (x *ApplyDecisionsRequest) GetComments() []*Comment {
+	if x != nil {
+		return x.Comments
+	}
+	return nil
+}
+
+// Decision is a reviewer's decision on a whole file or, with hunk_index,
+// on one hunk. Hunk decisions override their file's.
+type Decision struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	FileIndex     int32                  `protobuf:"varint,1,opt,name=file_index,json=fileIndex,proto3" json:"file_index,omitempty"`
+	HunkIndex     *int32                 `protobuf:"varint,2,opt,name=hunk_index,json=hunkIndex,proto3,oneof" json:"hunk_index,omitempty"`
+	State         DecisionState          `protobuf:"varint,3,opt,name=state,proto3,enum=agrev.v1.DecisionState" json:"state,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Decision) Reset() {
+	*x = Decision{}
+	mi := &file_agrev_proto_msgTypes[16]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Decision) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Decision) ProtoMessage() {}
+
+func (x *Decision) ProtoReflect() protoreflect.Message {
+	mi := &file_agrev_proto_msgTypes[16]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Decision.ProtoReflect.Descriptor instead.
+func (*Decision) Descriptor() ([]byte, []int) {
+	return file_agrev_proto_rawDescGZIP(), []int{16}
+}
+
+func (x *Decision) GetFileIndex() int32 {
+	if x != nil {
+		return x.FileIndex
+	}
+	return 0
+}
+
+func (x *Decision) GetHunkIndex() int32 {
+	if x != nil && x.HunkIndex != nil {
+		return *x.HunkIndex
+	}
+	return 0
+}
+
+func (x *Decision) GetState() DecisionState {
+	if x != nil {
+		return x.State
+	}
+	return DecisionState_DECISION_STATE_PENDING
+}
+
+type Comment struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	File          string                 `protobuf:"bytes,1,opt,name=file,proto3" json:"file,omitempty"`
+	Line          int32                  `protobuf:"varint,2,opt,name=line,proto3" json:"line,omitempty"`
+	Body          string                 `protobuf:"bytes,3,opt,name=body,proto3" json:"body,omitempty"`
+	Author        string                 `protobuf:"bytes,4,opt,name=author,proto3" json:"author,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Comment) Reset() {
+	*x = Comment{}
+	mi := &file_agrev_proto_msgTypes[17]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Comment) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Comment) ProtoMessage() {}
+
+func (x *Comment) ProtoReflect() protoreflect.Message {
+	mi := &file_agrev_proto_msgTypes[17]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Comment.ProtoReflect.Descriptor instead.
+func (*Comment) Descriptor() ([]byte, []int) {
+	return file_agrev_proto_rawDescGZIP(), []int{17}
+}
+
+func (x *Comment) GetFile() string {
+	if x != nil {
+		return x.File
+	}
+	return ""
+}
+
+func (x *Comment) GetLine() int32 {
+	if x != nil {
+		return x.Line
+	}
+	return 0
+}
+
+func (x *Comment) GetBody() string {
+	if x != nil {
+		return x.Body
+	}
+	return ""
+}
+
+func (x *Comment) GetAuthor() string {
+	if x != nil {
+		return x.Author
+	}
+	return ""
+}
+
+type ApplyDecisionsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Patch         string                 `protobuf:"bytes,1,opt,name=patch,proto3" json:"patch,omitempty"` // empty when nothing was approved
+	Files         []string               `protobuf:"bytes,2,rep,name=files,proto3" json:"files,omitempty"` // files in the patch
+	CommitMessage string                 `protobuf:"bytes,3,opt,name=commit_message,json=commitMessage,proto3" json:"commit_message,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ApplyDecisionsResponse) Reset() {
+	*x = ApplyDecisionsResponse{}
+	mi := &file_agrev_proto_msgTypes[18]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ApplyDecisionsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ApplyDecisionsResponse) ProtoMessage() {}
+
+func (x *ApplyDecisionsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_agrev_proto_msgTypes[18]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ApplyDecisionsResponse.ProtoReflect.Descriptor instead.
+func (*ApplyDecisionsResponse) Descriptor() ([]byte, []int) {
+	return file_agrev_proto_rawDescGZIP(), []int{18}
+}
+
+func (x *ApplyDecisionsResponse) GetPatch() string {
+	if x != nil {
+		return x.Patch
+	}
+	return ""
+}
+
+func (x *ApplyDecisionsResponse) GetFiles() []string {
+	if x != nil {
+		return x.Files
+	}
+	return nil
+}
+
+func (x *ApplyDecisionsResponse) GetCommitMessage() string {
+	if x != nil {
+		return x.CommitMessage
+	}
+	return ""
+}
+
+var File_agrev_proto protoreflect.FileDescriptor
+
+const file_agrev_proto_rawDesc = "" +
+	"\n" +
+	"\vagrev.proto\x12\bagrev.v1\x1a\x1fgoogle/protobuf/timestamp.proto\"\"\n" +
+	"\fParseRequest\x12\x12\n" +
+	"\x04diff\x18\x01 \x01(\tR\x04diff\"Z\n" +
+	"\aDiffSet\x12$\n" +
+	"\x05files\x18\x01 \x03(\v2\x0e.agrev.v1.FileR\x05files\x12)\n" +
+	"\x05stats\x18\x02 \x01(\v2\x13.agrev.v1.DiffStatsR\x05stats\"Q\n" +
+	"\tDiffStats\x12\x14\n" +
+	"\x05files\x18\x01 \x01(\x05R\x05files\x12\x14\n" +
+	"\x05added\x18\x02 \x01(\x05R\x05added\x12\x18\n" +
+	"\adeleted\x18\x03 \x01(\x05R\adeleted\"\xae\x02\n" +
+	"\x04File\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x19\n" +
+	"\bold_name\x18\x02 \x01(\tR\aoldName\x12\x19\n" +
+	"\bnew_name\x18\x03 \x01(\tR\anewName\x12\x15\n" +
+	"\x06is_new\x18\x04 \x01(\bR\x05isNew\x12\x1d\n" +
+	"\n" +
+	"is_deleted\x18\x05 \x01(\bR\tisDeleted\x12\x1d\n" +
+	"\n" +
+	"is_renamed\x18\x06 \x01(\bR\tisRenamed\x12\x1b\n" +
+	"\tis_binary\x18\a \x01(\bR\bisBinary\x12\x1f\n" +
+	"\vadded_lines\x18\b \x01(\x05R\n" +
+	"addedLines\x12#\n" +
+	"\rdeleted_lines\x18\t \x01(\x05R\fdeletedLines\x12$\n" +
+	"\x05hunks\x18\n" +
+	" \x03(\v2\x0e.agrev.v1.HunkR\x05hunks\"\xe8\x01\n" +
+	"\x04Hunk\x12\x14\n" +
+	"\x05index\x18\x01 \x01(\x05R\x05index\x12\x16\n" +
+	"\x06header\x18\x02 \x01(\tR\x06header\x12\x18\n" +
+	"\asection\x18\x03 \x01(\tR\asection\x12\x1b\n" +
+	"\told_start\x18\x04 \x01(\x03R\boldStart\x12\x1b\n" +
+	"\told_lines\x18\x05 \x01(\x03R\boldLines\x12\x1b\n" +
+	"\tnew_start\x18\x06 \x01(\x03R\bnewStart\x12\x1b\n" +
+	"\tnew_lines\x18\a \x01(\x03R\bnewLines\x12$\n" +
+	"\x05lines\x18\b \x03(\v2\x0e.agrev.v1.LineR\x05lines\"n\n" +
+	"\x04Line\x12 \n" +
+	"\x02op\x18\x01 \x01(\x0e2\x10.agrev.v1.LineOpR\x02op\x12\x12\n" +
+	"\x04text\x18\x02 \x01(\tR\x04text\x12\x17\n" +
+	"\aold_num\x18\x03 \x01(\x05R\x06oldNum\x12\x17\n" +
+	"\anew_num\x18\x04 \x01(\x05R\x06newNum\"S\n" +
+	"\x0eAnalyzeRequest\x12\x12\n" +
+	"\x04diff\x18\x01 \x01(\tR\x04diff\x12\x19\n" +
+	"\brepo_dir\x18\x02 \x01(\tR\arepoDir\x12\x12\n" +
+	"\x04skip\x18\x03 \x03(\tR\x04skip\"\xb5\x01\n" +
+	"\x0fAnalyzeResponse\x12\x18\n" +
+	"\asummary\x18\x01 \x01(\tR\asummary\x12.\n" +
+	"\bmax_risk\x18\x02 \x01(\x0e2\x13.agrev.v1.RiskLevelR\amaxRisk\x12-\n" +
+	"\bfindings\x18\x03 \x03(\v2\x11.agrev.v1.FindingR\bfindings\x12)\n" +
+	"\x05stats\x18\x04 \x01(\v2\x13.agrev.v1.DiffStatsR\x05stats\"\x9d\x01\n" +
+	"\fAnalyzeEvent\x12+\n" +
+	"\x06parsed\x18\x01 \x01(\v2\x11.agrev.v1.DiffSetH\x00R\x06parsed\x12*\n" +
+	"\x04pass\x18\x02 \x01(\v2\x14.agrev.v1.PassResultH\x00R\x04pass\x12+\n" +
+	"\x04done\x18\x03 \x01(\v2\x15.agrev.v1.AnalyzeDoneH\x00R\x04doneB\a\n" +
+	"\x05event\"O\n" +
+	"\n" +
+	"PassResult\x12\x12\n" +
+	"\x04pass\x18\x01 \x01(\tR\x04pass\x12-\n" +
+	"\bfindings\x18\x02 \x03(\v2\x11.agrev.v1.FindingR\bfindings\"m\n" +
+	"\vAnalyzeDone\x12\x18\n" +
+	"\asummary\x18\x01 \x01(\tR\asummary\x12.\n" +
+	"\bmax_risk\x18\x02 \x01(\x0e2\x13.agrev.v1.RiskLevelR\amaxRisk\x12\x14\n" +
+	"\x05total\x18\x03 \x01(\x05R\x05total\"\xb8\x01\n" +
+	"\aFinding\x12\x12\n" +
+	"\x04pass\x18\x01 \x01(\tR\x04pass\x12\x12\n" +
+	"\x04file\x18\x02 \x01(\tR\x04file\x12\x12\n" +
+	"\x04line\x18\x03 \x01(\x05R\x04line\x12\x18\n" +
+	"\amessage\x18\x04 \x01(\tR\amessage\x12.\n" +
+	"\bseverity\x18\x05 \x01(\x0e2\x12.agrev.v1.SeverityR\bseverity\x12'\n" +
+	"\x04risk\x18\x06 \x01(\x0e2\x13.agrev.v1.RiskLevelR\x04risk\"Y\n" +
+	"\x10LoadTraceRequest\x12\x12\n" +
+	"\x04path\x18\x01 \x01(\tR\x04path\x12\x16\n" +
+	"\x06format\x18\x02 \x01(\tR\x06format\x12\x19\n" +
+	"\brepo_dir\x18\x03 \x01(\tR\arepoDir\"\x95\x02\n" +
+	"\x05Trace\x12\x16\n" +
+	"\x06source\x18\x01 \x01(\tR\x06source\x12\x1d\n" +
+	"\n" +
+	"session_id\x18\x02 \x01(\tR\tsessionId\x129\n" +
+	"\n" +
+	"start_time\x18\x03 \x01(\v2\x1a.google.protobuf.TimestampR\tstartTime\x125\n" +
+	"\bend_time\x18\x04 \x01(\v2\x1a.google.protobuf.TimestampR\aendTime\x12\x18\n" +
+	"\asummary\x18\x05 \x01(\tR\asummary\x12#\n" +
+	"\rfiles_changed\x18\x06 \x03(\tR\ffilesChanged\x12$\n" +
+	"\x05steps\x18\a \x03(\v2\x0e.agrev.v1.StepR\x05steps\"\xfe\x02\n" +
+	"\x04Step\x12&\n" +
+	"\x04type\x18\x01 \x01(\x0e2\x12.agrev.v1.StepTypeR\x04type\x128\n" +
+	"\ttimestamp\x18\x02 \x01(\v2\x1a.google.protobuf.TimestampR\ttimestamp\x12\x18\n" +
+	"\asummary\x18\x03 \x01(\tR\asummary\x12\x16\n" +
+	"\x06detail\x18\x04 \x01(\tR\x06detail\x12\x1b\n" +
+	"\tfile_path\x18\x05 \x01(\tR\bfilePath\x12\x1d\n" +
+	"\n" +
+	"old_string\x18\x06 \x01(\tR\toldString\x12\x1d\n" +
+	"\n" +
+	"new_string\x18\a \x01(\tR\tnewString\x12\x18\n" +
+	"\acommand\x18\b \x01(\tR\acommand\x12\x1b\n" +
+	"\texit_code\x18\t \x01(\x05R\bexitCode\x12\x16\n" +
+	"\x06output\x18\n" +
+	" \x01(\tR\x06output\x12\x1d\n" +
+	"\n" +
+	"line_start\x18\v \x01(\x05R\tlineStart\x12\x19\n" +
+	"\bline_end\x18\f \x01(\x05R\alineEnd\"\x8c\x01\n" +
+	"\x15ApplyDecisionsRequest\x12\x12\n" +
+	"\x04diff\x18\x01 \x01(\tR\x04diff\x120\n" +
+	"\tdecisions\x18\x02 \x03(\v2\x12.agrev.v1.DecisionR\tdecisions\x12-\n" +
+	"\bcomments\x18\x03 \x03(\v2\x11.agrev.v1.CommentR\bcomments\"\x8b\x01\n" +
+	"\bDecision\x12\x1d\n" +
+	"\n" +
+	"file_index\x18\x01 \x01(\x05R\tfileIndex\x12\"\n" +
+	"\n" +
+	"hunk_index\x18\x02 \x01(\x05H\x00R\thunkIndex\x88\x01\x01\x12-\n" +
+	"\x05state\x18\x03 \x01(\x0e2\x17.agrev.v1.DecisionStateR\x05stateB\r\n" +
+	"\v_hunk_index\"]\n" +
+	"\aComment\x12\x12\n" +
+	"\x04file\x18\x01 \x01(\tR\x04file\x12\x12\n" +
+	"\x04line\x18\x02 \x01(\x05R\x04line\x12\x12\n" +
+	"\x04body\x18\x03 \x01(\tR\x04body\x12\x16\n" +
+	"\x06author\x18\x04 \x01(\tR\x06author\"k\n" +
+	"\x16ApplyDecisionsResponse\x12\x14\n" +
+	"\x05patch\x18\x01 \x01(\tR\x05patch\x12\x14\n" +
+	"\x05files\x18\x02 \x03(\tR\x05files\x12%\n" +
+	"\x0ecommit_message\x18\x03 \x01(\tR\rcommitMessage*[\n" +
+	"\x06LineOp\x12\x17\n" +
+	"\x13LINE_OP_UNSPECIFIED\x10\x00\x12\x13\n" +
+	"\x0fLINE_OP_CONTEXT\x10\x01\x12\x0f\n" +
+	"\vLINE_OP_ADD\x10\x02\x12\x12\n" +
+	"\x0eLINE_OP_DELETE\x10\x03*\x95\x01\n" +
+	"\tRiskLevel\x12\x1a\n" +
+	"\x16RISK_LEVEL_UNSPECIFIED\x10\x00\x12\x13\n" +
+	"\x0fRISK_LEVEL_INFO\x10\x01\x12\x12\n" +
+	"\x0eRISK_LEVEL_LOW\x10\x02\x12\x15\n" +
+	"\x11RISK_LEVEL_MEDIUM\x10\x03\x12\x13\n" +
+	"\x0fRISK_LEVEL_HIGH\x10\x04\x12\x17\n" +
+	"\x13RISK_LEVEL_CRITICAL\x10\x05*a\n" +
+	"\bSeverity\x12\x18\n" +
+	"\x14SEVERITY_UNSPECIFIED\x10\x00\x12\x11\n" +
+	"\rSEVERITY_INFO\x10\x01\x12\x14\n" +
+	"\x10SEVERITY_WARNING\x10\x02\x12\x12\n" +
+	"\x0eSEVERITY_ERROR\x10\x03*\xcd\x01\n" +
+	"\bStepType\x12\x19\n" +
+	"\x15STEP_TYPE_UNSPECIFIED\x10\x00\x12\x12\n" +
+	"\x0eSTEP_TYPE_PLAN\x10\x01\x12\x17\n" +
+	"\x13STEP_TYPE_REASONING\x10\x02\x12\x12\n" +
+	"\x0eSTEP_TYPE_READ\x10\x03\x12\x13\n" +
+	"\x0fSTEP_TYPE_WRITE\x10\x04\x12\x12\n" +
+	"\x0eSTEP_TYPE_EDIT\x10\x05\x12\x12\n" +
+	"\x0eSTEP_TYPE_BASH\x10\x06\x12\x14\n" +
+	"\x10STEP_TYPE_RESULT\x10\a\x12\x12\n" +
+	"\x0eSTEP_TYPE_USER\x10\b*e\n" +
+	"\rDecisionState\x12\x1a\n" +
+	"\x16DECISION_STATE_PENDING\x10\x00\x12\x1b\n" +
+	"\x17DECISION_STATE_APPROVED\x10\x01\x12\x1b\n" +
+	"\x17DECISION_STATE_REJECTED\x10\x022\xcf\x02\n" +
+	"\x05Agrev\x122\n" +
+	"\x05Parse\x12\x16.agrev.v1.ParseRequest\x1a\x11.agrev.v1.DiffSet\x12>\n" +
+	"\aAnalyze\x12\x18.agrev.v1.AnalyzeRequest\x1a\x19.agrev.v1.AnalyzeResponse\x12C\n" +
+	"\rAnalyzeStream\x12\x18.agrev.v1.AnalyzeRequest\x1a\x16.agrev.v1.AnalyzeEvent0\x01\x128\n" +
+	"\tLoadTrace\x12\x1a.agrev.v1.LoadTraceRequest\x1a\x0f.agrev.v1.Trace\x12S\n" +
+	"\x0eApplyDecisions\x12\x1f.agrev.v1.ApplyDecisionsRequest\x1a .agrev.v1.ApplyDecisionsResponseB.Z,github.com/aezell/agrev/internal/api/agrevpbb\x06proto3"
+
+var (
+	file_agrev_proto_rawDescOnce sync.Once
+	file_agrev_proto_rawDescData []byte
+)
+
+func file_agrev_proto_rawDescGZIP() []byte {
+	file_agrev_proto_rawDescOnce.Do(func() {
+		file_agrev_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_agrev_proto_rawDesc), len(file_agrev_proto_rawDesc)))
+	})
+	return file_agrev_proto_rawDescData
+}
+
+var file_agrev_proto_enumTypes = make([]protoimpl.EnumInfo, 5)
+var file_agrev_proto_msgTypes = make([]protoimpl.MessageInfo, 19)
+var file_agrev_proto_goTypes = []any{
+	(LineOp)(0),                    // 0: agrev.v1.LineOp
+	(RiskLevel)(0),                 // 1: agrev.v1.RiskLevel
+	(Severity)(0),                  // 2: agrev.v1.Severity
+	(StepType)(0),                  // 3: agrev.v1.StepType
+	(DecisionState)(0),             // 4: agrev.v1.DecisionState
+	(*ParseRequest)(nil),           // 5: agrev.v1.ParseRequest
+	(*DiffSet)(nil),                // 6: agrev.v1.DiffSet
+	(*DiffStats)(nil),              // 7: agrev.v1.DiffStats
+	(*File)(nil),                   // 8: agrev.v1.File
+	(*Hunk)(nil),                   // 9: agrev.v1.Hunk
+	(*Line)(nil),                   // 10: agrev.v1.Line
+	(*AnalyzeRequest)(nil),         // 11: agrev.v1.AnalyzeRequest
+	(*AnalyzeResponse)(nil),        // 12: agrev.v1.AnalyzeResponse
+	(*AnalyzeEvent)(nil),           // 13: agrev.v1.AnalyzeEvent
+	(*PassResult)(nil),             // 14: agrev.v1.PassResult
+	(*AnalyzeDone)(nil),            // 15: agrev.v1.AnalyzeDone
+	(*Finding)(nil),                // 16: agrev.v1.Finding
+	(*LoadTraceRequest)(nil),       // 17: agrev.v1.LoadTraceRequest
+	(*Trace)(nil),                  // 18: agrev.v1.Trace
+	(*Step)(nil),                   // 19: agrev.v1.Step
+	(*ApplyDecisionsRequest)(nil),  // 20: agrev.v1.ApplyDecisionsRequest
+	(*Decision)(nil),               // 21: agrev.v1.Decision
+	(*Comment)(nil),                // 22: agrev.v1.Comment
+	(*ApplyDecisionsResponse)(nil), // 23: agrev.v1.ApplyDecisionsResponse
+	(*timestamppb.Timestamp)(nil),  // 24: google.protobuf.Timestamp
+}
+var file_agrev_proto_depIdxs = []int32{
+	8,  // 0: agrev.v1.DiffSet.files:type_name -> agrev.v1.File
+	7,  // 1: agrev.v1.DiffSet.stats:type_name -> agrev.v1.DiffStats
+	9,  // 2: agrev.v1.File.hunks:type_name -> agrev.v1.Hunk
+	10, // 3: agrev.v1.Hunk.lines:type_name -> agrev.v1.Line
+	0,  // 4: agrev.v1.Line.op:type_name -> agrev.v1.LineOp
+	1,  // 5: agrev.v1.AnalyzeResponse.max_risk:type_name -> agrev.v1.RiskLevel
+	16, // 6: agrev.v1.AnalyzeResponse.findings:type_name -> agrev.v1.Finding
+	7,  // 7: agrev.v1.AnalyzeResponse.stats:type_name -> agrev.v1.DiffStats
+	6,  // 8: agrev.v1.AnalyzeEvent.parsed:type_name -> agrev.v1.DiffSet
+	14, // 9: agrev.v1.AnalyzeEvent.pass:type_name -> agrev.v1.PassResult
+	15, // 10: agrev.v1.AnalyzeEvent.done:type_name -> agrev.v1.AnalyzeDone
+	16, // 11: agrev.v1.PassResult.findings:type_name -> agrev.v1.Finding
+	1,  // 12: agrev.v1.AnalyzeDone.max_risk:type_name -> agrev.v1.RiskLevel
+	2,  // 13: agrev.v1.Finding.severity:type_name -> agrev.v1.Severity
+	1,  // 14: agrev.v1.Finding.risk:type_name -> agrev.v1.RiskLevel
+	24, // 15: agrev.v1.Trace.start_time:type_name -> google.protobuf.Timestamp
+	24, // 16: agrev.v1.Trace.end_time:type_name -> google.protobuf.Timestamp
+	19, // 17: agrev.v1.Trace.steps:type_name -> agrev.v1.Step
+	3,  // 18: agrev.v1.Step.type:type_name -> agrev.v1.StepType
+	24, // 19: agrev.v1.Step.timestamp:type_name -> google.protobuf.Timestamp
+	21, // 20: agrev.v1.ApplyDecisionsRequest.decisions:type_name -> agrev.v1.Decision
+	22, // 21: agrev.v1.ApplyDecisionsRequest.comments:type_name -> agrev.v1.Comment
+	4,  // 22: agrev.v1.Decision.state:type_name -> agrev.v1.DecisionState
+	5,  // 23: agrev.v1.Agrev.Parse:input_type -> agrev.v1.ParseRequest
+	11, // 24: agrev.v1.Agrev.Analyze:input_type -> agrev.v1.AnalyzeRequest
+	11, // 25: agrev.v1.Agrev.AnalyzeStream:input_type -> agrev.v1.AnalyzeRequest
+	17, // 26: agrev.v1.Agrev.LoadTrace:input_type -> agrev.v1.LoadTraceRequest
+	20, // 27: agrev.v1.Agrev.ApplyDecisions:input_type -> agrev.v1.ApplyDecisionsRequest
+	6,  // 28: agrev.v1.Agrev.Parse:output_type -> agrev.v1.DiffSet
+	12, // 29: agrev.v1.Agrev.Analyze:output_type -> agrev.v1.AnalyzeResponse
+	13, // 30: agrev.v1.Agrev.AnalyzeStream:output_type -> agrev.v1.AnalyzeEvent
+	18, // 31: agrev.v1.Agrev.LoadTrace:output_type -> agrev.v1.Trace
+	23, // 32: agrev.v1.Agrev.ApplyDecisions:output_type -> agrev.v1.ApplyDecisionsResponse
+	28, // [28:33] is the sub-list for method output_type
+	23, // [23:28] is the sub-list for method input_type
+	23, // [23:23] is the sub-list for extension type_name
+	23, // [23:23] is the sub-list for extension extendee
+	0,  // [0:23] is the sub-list for field type_name
+}
+
+func init() { file_agrev_proto_init() }
+func file_agrev_proto_init() {
+	if File_agrev_proto != nil {
+		return
+	}
+	file_agrev_proto_msgTypes[8].OneofWrappers = []any{
+		(*AnalyzeEvent_Parsed)(nil),
+		(*AnalyzeEvent_Pass)(nil),
+		(*AnalyzeEvent_Done)(nil),
+	}
+	file_agrev_proto_msgTypes[16].OneofWrappers = []any{}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_agrev_proto_rawDesc), len(file_agrev_proto_rawDesc)),
+			NumEnums:      5,
+			NumMessages:   19,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_agrev_proto_goTypes,
+		DependencyIndexes: file_agrev_proto_depIdxs,
+		EnumInfos:         file_agrev_proto_enumTypes,
+		MessageInfos:      file_agrev_proto_msgTypes,
+	}.Build()
+	File_agrev_proto = out.File
+	file_agrev_proto_goTypes = nil
+	file_agrev_proto_depIdxs = nil
+}
@@ -0,0 +1,304 @@
+// gRPC interface to the agrev analysis engine, served by 'agrev serve
+// --grpc-port'. It mirrors the HTTP API's stateless endpoints; when tokens
+// are configured, calls need an "authorization: Bearer <token>" metadata
+// entry.
+//
+// Regenerate the Go code from this directory with protoc-gen-go and
+// protoc-gen-go-grpc:
+//
+//	protoc --go_out=. --go_opt=paths=source_relative \
+//	  --go-grpc_out=. --go-grpc_opt=paths=source_relative agrev.proto
+
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.6.2
+// - protoc             (unknown)
+// source: agrev.proto
+
+package agrevpb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	Agrev_Parse_FullMethodName          = "/agrev.v1.Agrev/Parse"
+	Agrev_Analyze_FullMethodName        = "/agrev.v1.Agrev/Analyze"
+	Agrev_AnalyzeStream_FullMethodName  = "/agrev.v1.Agrev/AnalyzeStream"
+	Agrev_LoadTrace_FullMethodName      = "/agrev.v1.Agrev/LoadTrace"
+	Agrev_ApplyDecisions_FullMethodName = "/agrev.v1.Agrev/ApplyDecisions"
+)
+
+// AgrevClient is the client API for Agrev service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type AgrevClient interface {
+	// Parse splits a unified diff into files, hunks, and lines.
+	Parse(ctx context.Context, in *ParseRequest, opts ...grpc.CallOption) (*DiffSet, error)
+	// Analyze runs the analysis passes over a diff.
+	Analyze(ctx context.Context, in *AnalyzeRequest, opts ...grpc.CallOption) (*AnalyzeResponse, error)
+	// AnalyzeStream is Analyze, sending the parsed diff first and then each
+	// pass's findings as the pass completes.
+	AnalyzeStream(ctx context.Context, in *AnalyzeRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[AnalyzeEvent], error)
+	// LoadTrace loads an agent trace from a file, or detects the most
+	// recent one for a repository.
+	LoadTrace(ctx context.Context, in *LoadTraceRequest, opts ...grpc.CallOption) (*Trace, error)
+	// ApplyDecisions turns review decisions on a diff into the patch of
+	// approved changes and a commit message, as 'agrev review' does.
+	ApplyDecisions(ctx context.Context, in *ApplyDecisionsRequest, opts ...grpc.CallOption) (*ApplyDecisionsResponse, error)
+}
+
+type agrevClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewAgrevClient(cc grpc.ClientConnInterface) AgrevClient {
+	return &agrevClient{cc}
+}
+
+func (c *agrevClient) Parse(ctx context.Context, in *ParseRequest, opts ...grpc.CallOption) (*DiffSet, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(DiffSet)
+	err := c.cc.Invoke(ctx, Agrev_Parse_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *agrevClient) Analyze(ctx context.Context, in *AnalyzeRequest, opts ...grpc.CallOption) (*AnalyzeResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(AnalyzeResponse)
+	err := c.cc.Invoke(ctx, Agrev_Analyze_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *agrevClient) AnalyzeStream(ctx context.Context, in *AnalyzeRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[AnalyzeEvent], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Agrev_ServiceDesc.Streams[0], Agrev_AnalyzeStream_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[AnalyzeRequest, AnalyzeEvent]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Agrev_AnalyzeStreamClient = grpc.ServerStreamingClient[AnalyzeEvent]
+
+func (c *agrevClient) LoadTrace(ctx context.Context, in *LoadTraceRequest, opts ...grpc.CallOption) (*Trace, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Trace)
+	err := c.cc.Invoke(ctx, Agrev_LoadTrace_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *agrevClient) ApplyDecisions(ctx context.Context, in *ApplyDecisionsRequest, opts ...grpc.CallOption) (*ApplyDecisionsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ApplyDecisionsResponse)
+	err := c.cc.Invoke(ctx, Agrev_ApplyDecisions_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// AgrevServer is the server API for Agrev service.
+// All implementations must embed UnimplementedAgrevServer
+// for forward compatibility.
+type AgrevServer interface {
+	// Parse splits a unified diff into files, hunks, and lines.
+	Parse(context.Context, *ParseRequest) (*DiffSet, error)
+	// Analyze runs the analysis passes over a diff.
+	Analyze(context.Context, *AnalyzeRequest) (*AnalyzeResponse, error)
+	// AnalyzeStream is Analyze, sending the parsed diff first and then each
+	// pass's findings as the pass completes.
+	AnalyzeStream(*AnalyzeRequest, grpc.ServerStreamingServer[AnalyzeEvent]) error
+	// LoadTrace loads an agent trace from a file, or detects the most
+	// recent one for a repository.
+	LoadTrace(context.Context, *LoadTraceRequest) (*Trace, error)
+	// ApplyDecisions turns review decisions on a diff into the patch of
+	// approved changes and a commit message, as 'agrev review' does.
+	ApplyDecisions(context.Context, *ApplyDecisionsRequest) (*ApplyDecisionsResponse, error)
+	mustEmbedUnimplementedAgrevServer()
+}
+
+// UnimplementedAgrevServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedAgrevServer struct{}
+
+func (UnimplementedAgrevServer) Parse(context.Context, *ParseRequest) (*DiffSet, error) {
+	return nil, status.Error(codes.Unimplemented, "method Parse not implemented")
+}
+func (UnimplementedAgrevServer) Analyze(context.Context, *AnalyzeRequest) (*AnalyzeResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method Analyze not implemented")
+}
+func (UnimplementedAgrevServer) AnalyzeStream(*AnalyzeRequest, grpc.ServerStreamingServer[AnalyzeEvent]) error {
+	return status.Error(codes.Unimplemented, "method AnalyzeStream not implemented")
+}
+func (UnimplementedAgrevServer) LoadTrace(context.Context, *LoadTraceRequest) (*Trace, error) {
+	return nil, status.Error(codes.Unimplemented, "method LoadTrace not implemented")
+}
+func (UnimplementedAgrevServer) ApplyDecisions(context.Context, *ApplyDecisionsRequest) (*ApplyDecisionsResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method ApplyDecisions not implemented")
+}
+func (UnimplementedAgrevServer) mustEmbedUnimplementedAgrevServer() {}
+func (UnimplementedAgrevServer) testEmbeddedByValue()               {}
+
+// UnsafeAgrevServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to AgrevServer will
+// result in compilation errors.
+type UnsafeAgrevServer interface {
+	mustEmbedUnimplementedAgrevServer()
+}
+
+func RegisterAgrevServer(s grpc.ServiceRegistrar, srv AgrevServer) {
+	// If the following call panics, it indicates UnimplementedAgrevServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&Agrev_ServiceDesc, srv)
+}
+
+func _Agrev_Parse_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ParseRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AgrevServer).Parse(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Agrev_Parse_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AgrevServer).Parse(ctx, req.(*ParseRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Agrev_Analyze_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AnalyzeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AgrevServer).Analyze(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Agrev_Analyze_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AgrevServer).Analyze(ctx, req.(*AnalyzeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Agrev_AnalyzeStream_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(AnalyzeRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(AgrevServer).AnalyzeStream(m, &grpc.GenericServerStream[AnalyzeRequest, AnalyzeEvent]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Agrev_AnalyzeStreamServer = grpc.ServerStreamingServer[AnalyzeEvent]
+
+func _Agrev_LoadTrace_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(LoadTraceRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AgrevServer).LoadTrace(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Agrev_LoadTrace_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AgrevServer).LoadTrace(ctx, req.(*LoadTraceRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Agrev_ApplyDecisions_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ApplyDecisionsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AgrevServer).ApplyDecisions(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Agrev_ApplyDecisions_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AgrevServer).ApplyDecisions(ctx, req.(*ApplyDecisionsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Agrev_ServiceDesc is the grpc.ServiceDesc for Agrev service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Agrev_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "agrev.v1.Agrev",
+	HandlerType: (*AgrevServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Parse",
+			Handler:    _Agrev_Parse_Handler,
+		},
+		{
+			MethodName: "Analyze",
+			Handler:    _Agrev_Analyze_Handler,
+		},
+		{
+			MethodName: "LoadTrace",
+			Handler:    _Agrev_LoadTrace_Handler,
+		},
+		{
+			MethodName: "ApplyDecisions",
+			Handler:    _Agrev_ApplyDecisions_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "AnalyzeStream",
+			Handler:       _Agrev_AnalyzeStream_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "agrev.proto",
+}
@@ -2,11 +2,17 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
-	"log"
+	"log/slog"
+	"net"
 	"net/http"
+	"sync"
 	"time"
+
+	"google.golang.org/grpc"
+	savedsession "github.com/aezell/agrev/internal/session"
 )
 
 // Server is the agrev HTTP API server.
@@ -14,40 +20,237 @@ type Server struct {
 	addr   string
 	mux    *http.ServeMux
 	server *http.Server
+	grpc   *grpc.Server
+	log    *slog.Logger
+	tokens []Token
+	spec   []byte // OpenAPI document, built once
+	source func() (*Source, error)
+
+	workspace *workspace // nil without Options.Workspace
+
+	sessions *sessionRegistry
+
+	// ctx is the parent of every request context; cancel aborts running
+	// analyses when shutdown runs out of time.
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	// WebSocket connections are hijacked, so http.Server.Shutdown doesn't
+	// wait for them; Shutdown closes them itself.
+	wsMu    sync.Mutex
+	wsConns map[*wsConn]struct{}
+	wsWG    sync.WaitGroup
+	closing bool // set by Shutdown; no new sessions are tracked
+}
+
+// Options configures a Server.
+type Options struct {
+	// Tokens are the bearer tokens accepted on /api routes. Empty leaves
+	// the API unauthenticated.
+	Tokens []Token
+
+	// Version is reported in the OpenAPI document.
+	Version string
+
+	// Source, when set, enables the web review UI at / and returns the
+	// change it reviews, loaded afresh for each page load.
+	Source func() (*Source, error)
+
+	// Workspace, when set, enables the /api/workspace endpoints for editor
+	// extensions and returns the uncommitted changes of the repository the
+	// server runs in, loaded afresh for each request.
+	Workspace func() (*Source, error)
+
+	// Logger receives request and session logs. Nil uses slog.Default().
+	Logger *slog.Logger
 }
 
 // New creates a new API server.
-func New(addr string) *Server {
-	s := &Server{addr: addr}
+func New(addr string, opts Options) *Server {
+	s := &Server{addr: addr, tokens: opts.Tokens, source: opts.Source, sessions: newSessionRegistry()}
+	if opts.Workspace != nil {
+		s.workspace = &workspace{load: opts.Workspace}
+	}
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+	s.wsConns = make(map[*wsConn]struct{})
+	s.log = opts.Logger
+	if s.log == nil {
+		s.log = slog.Default()
+	}
+	s.spec = openAPISpec(opts.Version)
 	s.mux = http.NewServeMux()
 	s.registerRoutes()
 	s.server = &http.Server{
 		Addr:         addr,
-		Handler:      s.mux,
+		Handler:      s.logRequests(s.mux),
+		ErrorLog:     slog.NewLogLogger(s.log.Handler(), slog.LevelError),
 		ReadTimeout:  30 * time.Second,
 		WriteTimeout: 60 * time.Second,
 		IdleTimeout:  120 * time.Second,
+		BaseContext:  func(net.Listener) context.Context { return s.ctx },
 	}
+	s.grpc = s.newGRPCServer()
 	return s
 }
 
+// route describes an endpoint, both for the mux and the OpenAPI document.
+type route struct {
+	method  string
+	path    string
+	summary string
+	public  bool              // served without a token
+	web     bool              // served only with the web UI enabled
+	editor  bool              // served only with Options.Workspace set
+	scope   Scope             // otherwise, the token scope required
+	query   map[string]string // query parameters and their descriptions
+	request any               // request body, nil for none
+	reply   any               // 200 response body
+	handle  func(*Server, http.ResponseWriter, *http.Request)
+}
+
+// routes lists every endpoint. It is a function rather than a variable
+// because the OpenAPI handler refers back to it.
+func routes() []route {
+	return []route{
+		{method: "GET", path: "/health", summary: "Health check", public: true,
+			reply: healthResponse{}, handle: (*Server).handleHealth},
+		{method: "GET", path: "/api/openapi.json", summary: "This OpenAPI document", public: true,
+			handle: (*Server).handleOpenAPI},
+		{method: "POST", path: "/api/analyze", summary: "Run analysis on a diff", scope: ScopeRead,
+			request: analyzeRequest{}, reply: analyzeResponse{}, handle: (*Server).handleAnalyze},
+		{method: "POST", path: "/api/analyze/batch", summary: "Run analysis on several diffs or commits, with an aggregate report", scope: ScopeRead,
+			request: batchAnalyzeRequest{}, reply: batchAnalyzeResponse{}, handle: (*Server).handleAnalyzeBatch},
+		{method: "POST", path: "/api/parse", summary: "Parse a diff into structured files", scope: ScopeRead,
+			request: parseRequest{}, reply: parseResponse{}, handle: (*Server).handleParse},
+		{method: "POST", path: "/api/summary", summary: "Generate summary from agent trace", scope: ScopeRead,
+			request: summaryRequest{}, reply: summaryResponse{}, handle: (*Server).handleSummary},
+		{method: "GET", path: "/api/ws", summary: "WebSocket for interactive review sessions", scope: ScopeWrite,
+			query: map[string]string{
+				"session":  "ID of a session to join instead of starting a new one",
+				"reviewer": "name to attribute decisions and comments to (default: the token's name)",
+			},
+			handle: (*Server).handleWebSocket},
+		{method: "POST", path: "/api/sessions/{id}/patch", summary: "The approved changes of a review session as a patch", scope: ScopeRead,
+			reply: patchResponse{}, handle: (*Server).handleSessionPatch},
+		{method: "POST", path: "/api/sessions/{id}/commit-message", summary: "A commit message for the approved changes of a review session", scope: ScopeRead,
+			reply: commitMessageResponse{}, handle: (*Server).handleSessionCommitMessage},
+		{method: "GET", path: "/api/sessions/{id}/export", summary: "The state of a review session in the .agrev/session.json format", scope: ScopeRead,
+			reply: savedsession.Session{}, handle: (*Server).handleSessionExport},
+		{method: "POST", path: "/api/sessions/{id}/import", summary: "Restore decisions and comments from a saved session onto the files they were made on", scope: ScopeWrite,
+			request: savedsession.Session{}, reply: wsStateResponse{}, handle: (*Server).handleSessionImport},
+		{method: "GET", path: "/api/sessions/{id}/files/{index}/lines", summary: "Rendered diff lines of a session file, with syntax tokens", scope: ScopeRead,
+			query: map[string]string{"style": "chroma style for token colors (default " + defaultStyle + ")"},
+			reply: linesResponse{}, handle: (*Server).handleSessionLines},
+		{method: "GET", path: "/api/source", summary: "The change under review (agrev serve --web only)", scope: ScopeRead,
+			web: true, reply: sourceResponse{}, handle: (*Server).handleSource},
+		{method: "GET", path: "/api/workspace/diff", summary: "The uncommitted changes of the server's repository", scope: ScopeRead,
+			editor: true, reply: workspaceDiffResponse{}, handle: (*Server).handleWorkspaceDiff},
+		{method: "GET", path: "/api/workspace/findings", summary: "Findings on the uncommitted changes, for one file or all", scope: ScopeRead,
+			editor: true, query: map[string]string{"file": "path of the file, relative to the repository (default: every file)"},
+			reply: workspaceFindingsResponse{}, handle: (*Server).handleWorkspaceFindings},
+		{method: "GET", path: "/api/workspace/decisions", summary: "Decisions of the repository's saved review on the uncommitted changes", scope: ScopeRead,
+			editor: true, reply: wsStateResponse{}, handle: (*Server).handleWorkspaceDecisions},
+		{method: "POST", path: "/api/workspace/decisions", summary: "Approve, reject, or undo a file or hunk in the repository's saved review", scope: ScopeWrite,
+			editor: true, request: workspaceDecisionRequest{}, reply: wsStateResponse{}, handle: (*Server).handleWorkspaceDecide},
+		{method: "GET", path: "/api/workspace/trace", summary: "The agent trace's steps and notes on a file", scope: ScopeRead,
+			editor: true, query: map[string]string{"file": "path of the file, relative to the repository"},
+			reply: workspaceTraceResponse{}, handle: (*Server).handleWorkspaceTrace},
+	}
+}
+
 func (s *Server) registerRoutes() {
-	s.mux.HandleFunc("GET /health", s.handleHealth)
-	s.mux.HandleFunc("POST /api/analyze", s.handleAnalyze)
-	s.mux.HandleFunc("POST /api/parse", s.handleParse)
-	s.mux.HandleFunc("POST /api/summary", s.handleSummary)
-	s.mux.HandleFunc("GET /api/ws", s.handleWebSocket)
+	for _, rt := range routes() {
+		if rt.web && s.source == nil || rt.editor && s.workspace == nil {
+			continue
+		}
+		h := func(w http.ResponseWriter, r *http.Request) { rt.handle(s, w, r) }
+		if !rt.public {
+			h = s.authorize(rt.scope, h)
+		}
+		s.mux.HandleFunc(rt.method+" "+rt.path, h)
+	}
+	if s.source != nil {
+		// The page itself is public; it asks for a token when the API
+		// needs one.
+		s.mux.Handle("GET /", webHandler())
+	}
 }
 
-// ListenAndServe starts the HTTP server.
+// ListenAndServe starts the HTTP server. After Shutdown it returns
+// http.ErrServerClosed.
 func (s *Server) ListenAndServe() error {
-	log.Printf("agrev API server listening on %s", s.addr)
+	s.log.Info("agrev API server listening", "addr", s.addr)
 	return s.server.ListenAndServe()
 }
 
+// Shutdown stops accepting connections, sends WebSocket clients a close
+// frame, and waits for in-flight requests, gRPC calls, and sessions to
+// finish. If ctx expires first, running analyses are cancelled, the
+// remaining connections are closed, and ctx's error is returned.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.wsMu.Lock()
+	s.closing = true
+	for conn := range s.wsConns {
+		sendWSClose(conn)
+	}
+	s.wsMu.Unlock()
+
+	grpcDone := make(chan struct{})
+	go func() {
+		s.grpc.GracefulStop()
+		close(grpcDone)
+	}()
+
+	err := s.server.Shutdown(ctx)
+	sessionsDone := make(chan struct{})
+	go func() {
+		s.wsWG.Wait()
+		<-grpcDone
+		close(sessionsDone)
+	}()
+	if err == nil {
+		select {
+		case <-sessionsDone:
+		case <-ctx.Done():
+			err = ctx.Err()
+		}
+	}
+	s.cancel()
+	if err != nil {
+		s.log.Warn("shutdown timed out; cancelling in-flight work", "error", err)
+		s.server.Close()
+		s.grpc.Stop()
+		s.wsMu.Lock()
+		for conn := range s.wsConns {
+			conn.Close()
+		}
+		s.wsMu.Unlock()
+		<-sessionsDone
+	}
+	return err
+}
+
+// trackWebSocket registers conn for Shutdown until the returned func is
+// called. It reports false when the server is already shutting down.
+func (s *Server) trackWebSocket(conn *wsConn) (untrack func(), ok bool) {
+	s.wsMu.Lock()
+	defer s.wsMu.Unlock()
+	if s.closing {
+		return nil, false
+	}
+	s.wsConns[conn] = struct{}{}
+	s.wsWG.Add(1)
+	return func() {
+		s.wsMu.Lock()
+		delete(s.wsConns, conn)
+		s.wsMu.Unlock()
+		s.wsWG.Done()
+	}, true
+}
+
 // Handler returns the HTTP handler for testing.
 func (s *Server) Handler() http.Handler {
-	return s.mux
+	return s.server.Handler
 }
 
 // writeJSON writes a JSON response.
@@ -57,13 +260,16 @@ func writeJSON(w http.ResponseWriter, status int, v any) {
 	enc := json.NewEncoder(w)
 	enc.SetIndent("", "  ")
 	if err := enc.Encode(v); err != nil {
-		log.Printf("json encode error: %v", err)
+		// Reported with the request by logRequests
+		if rec, ok := w.(*statusRecorder); ok {
+			rec.err = fmt.Errorf("encoding response: %w", err)
+		}
 	}
 }
 
 // writeError writes a JSON error response.
 func writeError(w http.ResponseWriter, status int, msg string) {
-	writeJSON(w, status, map[string]string{"error": msg})
+	writeJSON(w, status, errorResponse{Error: msg})
 }
 
 // readJSON decodes a JSON request body into v.
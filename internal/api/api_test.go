@@ -2,13 +2,38 @@ package api
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"io"
+	"log/slog"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/bluekeyes/go-gitdiff/gitdiff"
 	"github.com/gorilla/websocket"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/aezell/agrev/internal/analysis"
+	"github.com/aezell/agrev/internal/audit"
+	"github.com/aezell/agrev/internal/api/agrevpb"
+	"github.com/aezell/agrev/internal/diff"
+	"github.com/aezell/agrev/internal/jsonrpc"
+	savedsession "github.com/aezell/agrev/internal/session"
+	"github.com/aezell/agrev/internal/trace"
 )
 
 const testDiff = `diff --git a/main.go b/main.go
@@ -36,7 +61,7 @@ new file mode 100644
 `
 
 func newTestServer() *Server {
-	return New(":0")
+	return New(":0", Options{Logger: slog.New(slog.DiscardHandler)})
 }
 
 func TestHealthEndpoint(t *testing.T) {
@@ -132,6 +157,55 @@ func TestParseEndpoint(t *testing.T) {
 	if resp.Stats.Added != 7 {
 		t.Errorf("expected 7 added lines, got %d", resp.Stats.Added)
 	}
+	want := []groupStatsJSON{{Name: "Go", Files: 2, Added: 7, Deleted: 1}}
+	if !reflect.DeepEqual(resp.Stats.Languages, want) {
+		t.Errorf("languages = %+v, want %+v", resp.Stats.Languages, want)
+	}
+	want[0].Name = "."
+	if !reflect.DeepEqual(resp.Stats.Directories, want) {
+		t.Errorf("directories = %+v, want %+v", resp.Stats.Directories, want)
+	}
+}
+
+func TestParseEndpointMoves(t *testing.T) {
+	srv := newTestServer()
+
+	raw := `diff --git a/old.go b/old.go
+--- a/old.go
++++ b/old.go
+@@ -1,4 +1,1 @@
+ package old
+-func a() { one() }
+-func b() { two() }
+-func c() { three() }
+diff --git a/new.go b/new.go
+--- a/new.go
++++ b/new.go
+@@ -1,1 +1,4 @@
+ package new
++func a() { one() }
++func b() { two() }
++func c() { three() }
+`
+	body, _ := json.Marshal(parseRequest{Diff: raw})
+	req := httptest.NewRequest(http.MethodPost, "/api/parse", bytes.NewReader(body))
+	w := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(w, req)
+
+	var resp parseResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("json decode: %v", err)
+	}
+	want := moveJSON{FromFile: "old.go", FromStart: 2, FromEnd: 4, ToFile: "new.go", ToStart: 2, ToEnd: 4}
+	if len(resp.Moves) != 1 || resp.Moves[0] != want {
+		t.Errorf("expected move %+v, got %+v", want, resp.Moves)
+	}
+
+	ds, _ := diff.Parse(raw)
+	lines := renderLines(ds, ds.Files[1], defaultStyle)
+	if lines[1].Moved || !lines[2].Moved {
+		t.Errorf("expected only the added lines marked moved, got %+v", lines[1:3])
+	}
 }
 
 func TestSummaryNoInput(t *testing.T) {
@@ -163,6 +237,323 @@ func TestAnalyzeInvalidJSON(t *testing.T) {
 	}
 }
 
+func TestAuthorization(t *testing.T) {
+	srv := New(":0", Options{Tokens: []Token{
+		{Name: "ci", Value: "read-secret", Scope: ScopeRead},
+		{Name: "bot", Value: "write-secret", Scope: ScopeWrite},
+	}})
+	body, _ := json.Marshal(analyzeRequest{Diff: testDiff})
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		header string
+		want   int
+	}{
+		{"health is open", http.MethodGet, "/health", "", http.StatusOK},
+		{"no token", http.MethodPost, "/api/analyze", "", http.StatusUnauthorized},
+		{"wrong token", http.MethodPost, "/api/analyze", "Bearer nope", http.StatusUnauthorized},
+		{"not bearer", http.MethodPost, "/api/analyze", "Basic read-secret", http.StatusUnauthorized},
+		{"read token", http.MethodPost, "/api/analyze", "Bearer read-secret", http.StatusOK},
+		{"write token", http.MethodPost, "/api/analyze", "bearer write-secret", http.StatusOK},
+		{"read token on ws", http.MethodGet, "/api/ws", "Bearer read-secret", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, bytes.NewReader(body))
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			w := httptest.NewRecorder()
+			srv.Handler().ServeHTTP(w, req)
+			if w.Code != tt.want {
+				t.Errorf("got %d, want %d: %s", w.Code, tt.want, w.Body.String())
+			}
+		})
+	}
+
+	ts := httptest.NewServer(srv.Handler())
+	defer ts.Close()
+	wsURL := "ws" + strings.TrimPrefix(ts.URL, "http") + "/api/ws"
+	if _, resp, err := websocket.DefaultDialer.Dial(wsURL, nil); err == nil || resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("ws without token: expected 401, got err=%v", err)
+	}
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL+"?access_token=write-secret", nil)
+	if err != nil {
+		t.Fatalf("ws with access_token: %v", err)
+	}
+	conn.Close()
+}
+
+func TestOpenAPIEndpoint(t *testing.T) {
+	srv := New(":0", Options{Version: "1.2.3", Tokens: []Token{{Name: "ci", Value: "secret"}}})
+	req := httptest.NewRequest(http.MethodGet, "/api/openapi.json", nil)
+	w := httptest.NewRecorder()
+
+	srv.Handler().ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200 without a token, got %d", w.Code)
+	}
+
+	var doc struct {
+		OpenAPI string `json:"openapi"`
+		Info    struct {
+			Version string `json:"version"`
+		} `json:"info"`
+		Paths      map[string]map[string]json.RawMessage `json:"paths"`
+		Components struct {
+			Schemas map[string]struct {
+				Ref        string `json:"$ref"`
+				Properties map[string]struct {
+					Enum []string `json:"enum"`
+				} `json:"properties"`
+				OneOf []json.RawMessage `json:"oneOf"`
+			} `json:"schemas"`
+		} `json:"components"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &doc); err != nil {
+		t.Fatalf("json decode: %v", err)
+	}
+	if doc.OpenAPI != "3.0.3" || doc.Info.Version != "1.2.3" {
+		t.Errorf("openapi %q, version %q", doc.OpenAPI, doc.Info.Version)
+	}
+
+	for _, rt := range routes() {
+		if _, ok := doc.Paths[rt.path][strings.ToLower(rt.method)]; !ok {
+			t.Errorf("%s %s is not documented", rt.method, rt.path)
+		}
+	}
+
+	schemas := doc.Components.Schemas
+	for _, name := range []string{"AnalyzeRequest", "AnalyzeResponse", "Finding", "File", "WsLoadDiff", "WsSummaryResponse", "ErrorResponse"} {
+		if _, ok := schemas[name]; !ok {
+			t.Errorf("missing schema %s", name)
+		}
+	}
+	if got := schemas["Finding"].Properties["risk"].Enum; len(got) != 5 {
+		t.Errorf("Finding.risk enum = %v", got)
+	}
+	if got := len(schemas["WsClientMessage"].OneOf); got != len(wsClientMessages) {
+		t.Errorf("WsClientMessage has %d variants, want %d", got, len(wsClientMessages))
+	}
+	if got := len(schemas["WsServerMessage"].OneOf); got != len(wsServerMessages) {
+		t.Errorf("WsServerMessage has %d variants, want %d", got, len(wsServerMessages))
+	}
+}
+
+func TestWebUI(t *testing.T) {
+	if w := get(t, newTestServer(), "/"); w.Code != http.StatusNotFound {
+		t.Errorf("/ without --web: expected 404, got %d", w.Code)
+	}
+	if w := get(t, newTestServer(), "/api/source"); w.Code != http.StatusNotFound {
+		t.Errorf("/api/source without --web: expected 404, got %d", w.Code)
+	}
+
+	tr := &trace.Trace{Source: "generic", Steps: []trace.Step{
+		{Type: trace.StepFileEdit, Summary: "Edit main.go", FilePath: "main.go", OldString: "a", NewString: "\tprintln(\"goodbye\")"},
+	}}
+	srv := New(":0", Options{Source: func() (*Source, error) {
+		return &Source{Label: "HEAD~1..HEAD", Diff: testDiff, RepoDir: "/repo", Trace: tr}, nil
+	}})
+
+	w := get(t, srv, "/")
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "app.js") {
+		t.Errorf("/: got %d %q", w.Code, w.Body.String())
+	}
+	for _, path := range []string{"/app.js", "/style.css"} {
+		if w := get(t, srv, path); w.Code != http.StatusOK {
+			t.Errorf("%s: got %d", path, w.Code)
+		}
+	}
+
+	w = get(t, srv, "/api/source")
+	var resp sourceResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("json decode: %v", err)
+	}
+	if resp.Label != "HEAD~1..HEAD" || resp.Diff != testDiff || resp.RepoDir != "/repo" {
+		t.Errorf("unexpected source %+v", resp)
+	}
+	if resp.Trace == nil || len(resp.Trace.Steps) != 1 || resp.Trace.Steps[0].Type != "edit" || resp.Trace.Steps[0].File != "main.go" {
+		t.Errorf("unexpected trace %+v", resp.Trace)
+	}
+	if anns := resp.Annotations["main.go"]; len(anns) != 2 || anns[0].Type != "trace_link" || anns[0].Start != 5 || anns[0].Step != 1 ||
+		anns[1].Message != "edited without reading the file first" {
+		t.Errorf("unexpected annotations %+v", resp.Annotations)
+	}
+}
+
+func get(t *testing.T, srv *Server, path string) *httptest.ResponseRecorder {
+	t.Helper()
+	w := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+	return w
+}
+
+// syncBuffer is a bytes.Buffer safe for the concurrent writes of a server.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+// records decodes the JSON log lines written so far.
+func (b *syncBuffer) records(t *testing.T) []map[string]any {
+	t.Helper()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var out []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(b.buf.String()), "\n") {
+		var rec map[string]any
+		if err := json.Unmarshal([]byte(line), &rec); err != nil {
+			t.Fatalf("log line %q: %v", line, err)
+		}
+		out = append(out, rec)
+	}
+	return out
+}
+
+func TestRequestLogging(t *testing.T) {
+	var logs syncBuffer
+	srv := New(":0", Options{Logger: slog.New(slog.NewJSONHandler(&logs, nil))})
+
+	req := httptest.NewRequest(http.MethodGet, "/health", nil)
+	req.Header.Set("X-Request-ID", "ci-run-42")
+	w := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(w, req)
+	if got := w.Header().Get("X-Request-ID"); got != "ci-run-42" {
+		t.Errorf("expected the client's request ID echoed, got %q", got)
+	}
+
+	w = httptest.NewRecorder()
+	srv.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/analyze", strings.NewReader("{bad")))
+	generated := w.Header().Get("X-Request-ID")
+	if len(generated) != 16 {
+		t.Errorf("expected a generated request ID, got %q", generated)
+	}
+
+	recs := logs.records(t)
+	if len(recs) != 2 {
+		t.Fatalf("expected 2 log records, got %d: %v", len(recs), recs)
+	}
+	if r := recs[0]; r["msg"] != "request" || r["request_id"] != "ci-run-42" || r["path"] != "/health" || r["status"] != float64(200) {
+		t.Errorf("unexpected record %v", r)
+	}
+	if r := recs[1]; r["request_id"] != generated || r["status"] != float64(400) || r["method"] != "POST" {
+		t.Errorf("unexpected record %v", r)
+	}
+
+	// WebSocket session logs carry the request and session IDs
+	ts := httptest.NewServer(srv.Handler())
+	defer ts.Close()
+	wsURL := "ws" + strings.TrimPrefix(ts.URL, "http") + "/api/ws"
+	conn, resp, err := websocket.DefaultDialer.Dial(wsURL, http.Header{"X-Request-ID": {"ws-1"}})
+	if err != nil {
+		t.Fatalf("ws dial: %v", err)
+	}
+	if got := resp.Header.Get("X-Request-ID"); got != "ws-1" {
+		t.Errorf("expected request ID on the upgrade response, got %q", got)
+	}
+	conn.ReadJSON(&wsMessage{}) // joined
+	conn.ReadJSON(&wsMessage{}) // participants
+	loadData, _ := json.Marshal(wsLoadDiff{Diff: testDiff})
+	conn.WriteJSON(wsMessage{Type: wsMsgLoadDiff, Data: loadData})
+	var msg wsMessage
+	conn.ReadJSON(&msg)
+	var parsed wsParsedResponse
+	json.Unmarshal(msg.Data, &parsed)
+	conn.ReadJSON(&wsMessage{})
+	conn.Close()
+
+	want := map[string]bool{"websocket session opened": false, "diff loaded": false, "websocket session closed": false, "request": false}
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		for _, r := range logs.records(t)[2:] {
+			if _, ok := want[r["msg"].(string)]; ok && r["request_id"] == "ws-1" &&
+				(r["msg"] == "request" || r["session_id"] == parsed.SessionID) {
+				want[r["msg"].(string)] = true
+			}
+		}
+		done := true
+		for _, seen := range want {
+			done = done && seen
+		}
+		if done {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("missing websocket log records: %v", want)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestShutdownClosesWebSockets(t *testing.T) {
+	srv := New(":0", Options{})
+	ts := httptest.NewUnstartedServer(nil)
+	ts.Config = srv.server
+	ts.Start()
+	defer ts.Close()
+
+	wsURL := "ws" + strings.TrimPrefix(ts.URL, "http") + "/api/ws"
+	conn, _ := dialSession(t, wsURL)
+	defer conn.Close()
+	loadData, _ := json.Marshal(wsLoadDiff{Diff: testDiff})
+	conn.WriteJSON(wsMessage{Type: wsMsgLoadDiff, Data: loadData})
+	conn.ReadJSON(&wsMessage{}) // parsed
+	conn.ReadJSON(&wsMessage{}) // analysis
+
+	done := make(chan error, 1)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		done <- srv.Shutdown(ctx)
+	}()
+
+	// Reading handles the close frame and echoes it back
+	_, _, err := conn.ReadMessage()
+	if !websocket.IsCloseError(err, websocket.CloseGoingAway) {
+		t.Errorf("expected a going-away close frame, got %v", err)
+	}
+	if err := <-done; err != nil {
+		t.Errorf("Shutdown: %v", err)
+	}
+	if srv.ctx.Err() == nil {
+		t.Error("expected request contexts to be cancelled after shutdown")
+	}
+
+	if _, _, err := websocket.DefaultDialer.Dial(wsURL, nil); err == nil {
+		t.Error("expected new connections to be refused after shutdown")
+	}
+}
+
+// dialSession connects to a review session and reads the joined and
+// participants messages every connection starts with.
+func dialSession(t *testing.T, url string) (*websocket.Conn, wsJoinedResponse) {
+	t.Helper()
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("ws dial: %v", err)
+	}
+	var msg wsMessage
+	if err := conn.ReadJSON(&msg); err != nil || msg.Type != wsMsgJoined {
+		t.Fatalf("expected 'joined' message, got %q (%v)", msg.Type, err)
+	}
+	var joined wsJoinedResponse
+	json.Unmarshal(msg.Data, &joined)
+	if err := conn.ReadJSON(&msg); err != nil || msg.Type != wsMsgParticipants {
+		t.Fatalf("expected 'participants' message, got %q (%v)", msg.Type, err)
+	}
+	return conn, joined
+}
+
 func TestServeCommandRegistered(t *testing.T) {
 	// Verify the serve command exists via the root test
 	srv := newTestServer()
@@ -178,10 +569,7 @@ func TestWebSocketReviewSession(t *testing.T) {
 
 	// Connect WebSocket
 	wsURL := "ws" + strings.TrimPrefix(ts.URL, "http") + "/api/ws"
-	conn, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
-	if err != nil {
-		t.Fatalf("ws dial: %v", err)
-	}
+	conn, _ := dialSession(t, wsURL)
 	defer conn.Close()
 
 	// Send load_diff
@@ -284,10 +672,7 @@ func TestWebSocketUndo(t *testing.T) {
 	defer ts.Close()
 
 	wsURL := "ws" + strings.TrimPrefix(ts.URL, "http") + "/api/ws"
-	conn, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
-	if err != nil {
-		t.Fatalf("ws dial: %v", err)
-	}
+	conn, _ := dialSession(t, wsURL)
 	defer conn.Close()
 
 	// Load diff
@@ -317,3 +702,1088 @@ func TestWebSocketUndo(t *testing.T) {
 		t.Errorf("expected pending after undo, got %q", dec.Decision)
 	}
 }
+
+func TestWebSocketTriage(t *testing.T) {
+	srv := newTestServer()
+	ts := httptest.NewServer(srv.Handler())
+	defer ts.Close()
+
+	wsURL := "ws" + strings.TrimPrefix(ts.URL, "http") + "/api/ws"
+	conn, _ := dialSession(t, wsURL)
+	defer conn.Close()
+
+	repoDir := t.TempDir()
+	diffText := `diff --git a/config.go b/config.go
+--- a/config.go
++++ b/config.go
+@@ -1,2 +1,3 @@
+ package main
++var secret = os.Getenv("API_SECRET")
+ func main() {}
+`
+	load := func() wsAnalysisResponse {
+		t.Helper()
+		loadData, _ := json.Marshal(wsLoadDiff{Diff: diffText, RepoDir: repoDir})
+		conn.WriteJSON(wsMessage{Type: wsMsgLoadDiff, Data: loadData})
+		conn.ReadJSON(&wsMessage{}) // parsed
+		var msg wsMessage
+		if err := conn.ReadJSON(&msg); err != nil || msg.Type != wsMsgAnalysis {
+			t.Fatalf("expected analysis, got %q (%v)", msg.Type, err)
+		}
+		var resp wsAnalysisResponse
+		json.Unmarshal(msg.Data, &resp)
+		return resp
+	}
+
+	// Triage before a diff is loaded is an error, and the session stays usable
+	data, _ := json.Marshal(wsTriageMsg{Fingerprint: "early", State: "fixed"})
+	conn.WriteJSON(wsMessage{Type: wsMsgTriage, Data: data})
+	var msg wsMessage
+	if err := conn.ReadJSON(&msg); err != nil || msg.Type != wsMsgError {
+		t.Fatalf("expected an error triaging before load_diff, got %q (%v)", msg.Type, err)
+	}
+
+	first := load()
+	if len(first.Findings) == 0 || first.Findings[0].Fingerprint == "" {
+		t.Fatalf("expected findings with fingerprints, got %+v", first.Findings)
+	}
+	fp := first.Findings[0].Fingerprint
+
+	data, _ = json.Marshal(wsTriageMsg{Fingerprint: fp, State: "false_positive"})
+	conn.WriteJSON(wsMessage{Type: wsMsgTriage, Data: data})
+	if err := conn.ReadJSON(&msg); err != nil || msg.Type != wsMsgTriaged {
+		t.Fatalf("expected finding_triaged, got %q (%v)", msg.Type, err)
+	}
+	var triaged wsTriageResponse
+	json.Unmarshal(msg.Data, &triaged)
+	if triaged.Fingerprint != fp || triaged.State != "false_positive" {
+		t.Errorf("unexpected triage response %+v", triaged)
+	}
+
+	// Loading the diff again suppresses it
+	second := load()
+	if len(second.Suppressed) != 1 || second.Suppressed[0].Fingerprint != fp || second.Suppressed[0].State != "false_positive" {
+		t.Errorf("expected the finding suppressed, got %+v", second.Suppressed)
+	}
+	if len(second.Findings) != len(first.Findings)-1 {
+		t.Errorf("expected one finding fewer, got %d of %d", len(second.Findings), len(first.Findings))
+	}
+
+	data, _ = json.Marshal(wsTriageMsg{Fingerprint: "nope", State: "fixed"})
+	conn.WriteJSON(wsMessage{Type: wsMsgTriage, Data: data})
+	if err := conn.ReadJSON(&msg); err != nil || msg.Type != wsMsgError {
+		t.Errorf("expected an error for an unknown fingerprint, got %q (%v)", msg.Type, err)
+	}
+
+	// Actions on a diff loaded from a repository go into its audit log
+	data, _ = json.Marshal(wsDecisionMsg{FileIndex: 0, Note: "no secrets in env"})
+	conn.WriteJSON(wsMessage{Type: wsMsgReject, Data: data})
+	if err := conn.ReadJSON(&msg); err != nil || msg.Type != wsMsgDecision {
+		t.Fatalf("expected decision, got %q (%v)", msg.Type, err)
+	}
+	events, err := audit.Load(repoDir)
+	if err != nil || len(events) != 3 {
+		t.Fatalf("expected the triage, rejection, and note audited, got %+v, %v", events, err)
+	}
+	if e := events[0]; e.Action != audit.ActionTriage || e.Source != "api" || e.Reviewer == "" || e.File != "config.go" {
+		t.Errorf("unexpected triage event %+v", e)
+	}
+	if e := events[2]; e.Action != audit.ActionNote || e.Detail != "no secrets in env" || e.Reviewer != events[1].Reviewer {
+		t.Errorf("unexpected note event %+v", e)
+	}
+}
+
+func TestWebSocketHunkDecisions(t *testing.T) {
+	const twoHunks = `diff --git a/main.go b/main.go
+--- a/main.go
++++ b/main.go
+@@ -1,3 +1,3 @@ package main
+ package main
+-var a = 1
++var a = 2
+ 
+@@ -10,3 +10,3 @@ func main() {
+ func main() {
+-	println(a)
++	println(a, a)
+ }
+`
+	srv := newTestServer()
+	ts := httptest.NewServer(srv.Handler())
+	defer ts.Close()
+
+	wsURL := "ws" + strings.TrimPrefix(ts.URL, "http") + "/api/ws"
+	conn, _ := dialSession(t, wsURL)
+	defer conn.Close()
+
+	roundTrip := func(msgType string, payload any) wsMessage {
+		t.Helper()
+		data, _ := json.Marshal(payload)
+		if err := conn.WriteJSON(wsMessage{Type: msgType, Data: data}); err != nil {
+			t.Fatalf("ws write: %v", err)
+		}
+		var msg wsMessage
+		if err := conn.ReadJSON(&msg); err != nil {
+			t.Fatalf("ws read: %v", err)
+		}
+		return msg
+	}
+	hunk := func(i int) *int { return &i }
+
+	msg := roundTrip(wsMsgLoadDiff, wsLoadDiff{Diff: twoHunks})
+	var parsed wsParsedResponse
+	json.Unmarshal(msg.Data, &parsed)
+	if len(parsed.Files) != 1 || len(parsed.Files[0].Hunks) != 2 {
+		t.Fatalf("expected 1 file with 2 hunks, got %+v", parsed.Files)
+	}
+	h := parsed.Files[0].Hunks[1]
+	if h.Index != 1 || h.NewStart != 10 || h.NewLines != 3 || h.Section != "func main() {" || h.Added != 1 || h.Deleted != 1 {
+		t.Errorf("unexpected hunk metadata %+v", h)
+	}
+	conn.ReadJSON(&wsMessage{}) // analysis
+
+	msg = roundTrip(wsMsgApprove, wsDecisionMsg{FileIndex: 0, HunkIndex: hunk(0)})
+	var dec wsDecisionResponse
+	json.Unmarshal(msg.Data, &dec)
+	if dec.HunkIndex == nil || *dec.HunkIndex != 0 || dec.Decision != "approved" {
+		t.Errorf("unexpected hunk decision %+v", dec)
+	}
+	json.Unmarshal(roundTrip(wsMsgReject, wsDecisionMsg{FileIndex: 0, HunkIndex: hunk(1), Note: " print once "}).Data, &dec)
+	if dec.Note != "print once" {
+		t.Errorf("expected the rejection's note, got %+v", dec)
+	}
+
+	if msg = roundTrip(wsMsgApprove, wsDecisionMsg{FileIndex: 0, HunkIndex: hunk(2)}); msg.Type != wsMsgError {
+		t.Errorf("expected error for hunk out of range, got %q", msg.Type)
+	}
+
+	var summary wsSummaryResponse
+	json.Unmarshal(roundTrip(wsMsgFinish, nil).Data, &summary)
+	if summary.Partial != 1 || summary.Files[0].Decision != "partial" ||
+		strings.Join(summary.Files[0].Hunks, ",") != "approved,rejected" ||
+		len(summary.Files[0].HunkNotes) != 2 || summary.Files[0].HunkNotes[1] != "print once" {
+		t.Errorf("unexpected summary %+v", summary)
+	}
+
+	resp, err := http.Post(ts.URL+"/api/sessions/"+parsed.SessionID+"/patch", "application/json", nil)
+	if err != nil {
+		t.Fatalf("POST patch: %v", err)
+	}
+	var patch patchResponse
+	json.NewDecoder(resp.Body).Decode(&patch)
+	resp.Body.Close()
+	if !strings.Contains(patch.Patch, "+var a = 2") || strings.Contains(patch.Patch, "println(a, a)") {
+		t.Errorf("patch should hold only the approved hunk:\n%s", patch.Patch)
+	}
+
+	// Undoing a hunk falls back to the file decision
+	roundTrip(wsMsgApprove, wsDecisionMsg{FileIndex: 0})
+	roundTrip(wsMsgReject, wsDecisionMsg{FileIndex: 0, HunkIndex: hunk(1)})
+	json.Unmarshal(roundTrip(wsMsgUndo, wsDecisionMsg{FileIndex: 0, HunkIndex: hunk(1)}).Data, &dec)
+	if dec.Decision != "approved" {
+		t.Errorf("expected hunk undo to fall back to approved, got %q", dec.Decision)
+	}
+	summary = wsSummaryResponse{}
+	json.Unmarshal(roundTrip(wsMsgFinish, nil).Data, &summary)
+	if summary.Approved != 1 || summary.Files[0].Hunks != nil {
+		t.Errorf("unexpected summary after undo %+v", summary)
+	}
+
+	// A file can be approved on follow-ups
+	json.Unmarshal(roundTrip(wsMsgApprove, wsDecisionMsg{FileIndex: 0, Conditions: []string{" add a test ", ""}}).Data, &dec)
+	if len(dec.Conditions) != 1 || dec.Conditions[0] != "add a test" {
+		t.Errorf("expected the approval's follow-up, got %+v", dec)
+	}
+	summary = wsSummaryResponse{}
+	json.Unmarshal(roundTrip(wsMsgFinish, nil).Data, &summary)
+	if len(summary.Files[0].Conditions) != 1 {
+		t.Errorf("expected the follow-up in the summary, got %+v", summary.Files[0])
+	}
+
+	// Files and hunks can be labeled for triage
+	var labels wsLabelsResponse
+	msg = roundTrip(wsMsgLabel, wsLabelMsg{FileIndex: 0, HunkIndex: hunk(1), Labels: []string{"needs security review", "#docs"}})
+	json.Unmarshal(msg.Data, &labels)
+	if msg.Type != wsMsgLabels || labels.HunkIndex == nil || strings.Join(labels.Labels, ",") != "docs,needs-security-review" {
+		t.Errorf("unexpected labels response %q %+v", msg.Type, labels)
+	}
+	json.Unmarshal(roundTrip(wsMsgUnlabel, wsLabelMsg{FileIndex: 0, HunkIndex: hunk(1), Labels: []string{"docs"}}).Data, &labels)
+	if strings.Join(labels.Labels, ",") != "needs-security-review" {
+		t.Errorf("expected docs removed, got %+v", labels)
+	}
+	if msg = roundTrip(wsMsgLabel, wsLabelMsg{FileIndex: 0}); msg.Type != wsMsgError {
+		t.Errorf("expected error for no labels, got %q", msg.Type)
+	}
+	summary = wsSummaryResponse{}
+	json.Unmarshal(roundTrip(wsMsgFinish, nil).Data, &summary)
+	if f := summary.Files[0]; len(f.Labels) != 0 || len(f.HunkLabels) != 2 || len(f.HunkLabels[1]) != 1 {
+		t.Errorf("expected the hunk's label in the summary, got %+v", f)
+	}
+}
+
+func TestWebSocketSplitHunk(t *testing.T) {
+	const old = "package main\n\nvar a = 1\nvar b = 1\nvar c = 1\nvar d = 1\n\n\n\n\n\nfunc main() {\n\tprintln(a)\n}\n"
+	const twoRuns = `diff --git a/main.go b/main.go
+index abc1234..def5678 100644
+--- a/main.go
++++ b/main.go
+@@ -1,9 +1,9 @@
+ package main
+ 
+-var a = 1
++var a = 2
+ var b = 1
+ var c = 1
+-var d = 1
++var d = 2
+ 
+ 
+ 
+@@ -10,5 +10,5 @@
+ 
+ 
+ func main() {
+-	println(a)
++	println(a, a)
+ }
+`
+	srv := newTestServer()
+	ts := httptest.NewServer(srv.Handler())
+	defer ts.Close()
+
+	wsURL := "ws" + strings.TrimPrefix(ts.URL, "http") + "/api/ws"
+	conn, _ := dialSession(t, wsURL)
+	defer conn.Close()
+
+	roundTrip := func(msgType string, payload any) wsMessage {
+		t.Helper()
+		data, _ := json.Marshal(payload)
+		if err := conn.WriteJSON(wsMessage{Type: msgType, Data: data}); err != nil {
+			t.Fatalf("ws write: %v", err)
+		}
+		var msg wsMessage
+		if err := conn.ReadJSON(&msg); err != nil {
+			t.Fatalf("ws read: %v", err)
+		}
+		return msg
+	}
+	hunk := func(i int) *int { return &i }
+
+	var parsed wsParsedResponse
+	json.Unmarshal(roundTrip(wsMsgLoadDiff, wsLoadDiff{Diff: twoRuns}).Data, &parsed)
+	if hs := parsed.Files[0].Hunks; len(hs) != 2 || !hs[0].Splittable || hs[1].Splittable {
+		t.Fatalf("unexpected hunks %+v", hs)
+	}
+	conn.ReadJSON(&wsMessage{}) // analysis
+
+	// The second hunk's decision and comments follow it to its new index,
+	// and comments on the split hunk go to the piece holding their line
+	roundTrip(wsMsgReject, wsDecisionMsg{FileIndex: 0, HunkIndex: hunk(1)})
+	roundTrip(wsMsgComment, wsCommentMsg{FileIndex: 0, Line: 13, Body: "why twice?"})
+	roundTrip(wsMsgComment, wsCommentMsg{FileIndex: 0, Line: 6, Body: "d too?"})
+	msg := roundTrip(wsMsgSplit, wsDecisionMsg{FileIndex: 0, HunkIndex: hunk(0)})
+	if msg.Type != wsMsgSplitHunk {
+		t.Fatalf("expected %s, got %s: %s", wsMsgSplitHunk, msg.Type, msg.Data)
+	}
+	var split wsSplitResponse
+	json.Unmarshal(msg.Data, &split)
+	if split.Hunks != 2 || len(split.File.Hunks) != 3 || split.File.Hunks[1].OldStart != 4 || split.File.Hunks[2].OldStart != 10 {
+		t.Errorf("unexpected split %+v", split)
+	}
+
+	if msg = roundTrip(wsMsgSplit, wsDecisionMsg{FileIndex: 0, HunkIndex: hunk(0)}); msg.Type != wsMsgError {
+		t.Errorf("expected error splitting a single run of changes, got %q", msg.Type)
+	}
+	if msg = roundTrip(wsMsgSplit, wsDecisionMsg{FileIndex: 0}); msg.Type != wsMsgError {
+		t.Errorf("expected error splitting without a hunk, got %q", msg.Type)
+	}
+
+	roundTrip(wsMsgApprove, wsDecisionMsg{FileIndex: 0, HunkIndex: hunk(1)})
+	var summary wsSummaryResponse
+	json.Unmarshal(roundTrip(wsMsgFinish, nil).Data, &summary)
+	if got := strings.Join(summary.Files[0].Hunks, ","); got != "pending,approved,rejected" {
+		t.Errorf("hunk decisions = %s", got)
+	}
+	if cs := summary.Comments; len(cs) != 2 || cs[0].HunkIndex == nil || *cs[0].HunkIndex != 2 || cs[1].HunkIndex == nil || *cs[1].HunkIndex != 1 {
+		t.Errorf("unexpected comments after the split %+v", cs)
+	}
+
+	// The patch holds half of the original hunk, and applies
+	resp, err := http.Post(ts.URL+"/api/sessions/"+parsed.SessionID+"/patch", "application/json", nil)
+	if err != nil {
+		t.Fatalf("POST patch: %v", err)
+	}
+	var patch patchResponse
+	json.NewDecoder(resp.Body).Decode(&patch)
+	resp.Body.Close()
+	ds, err := diff.Parse(patch.Patch)
+	if err != nil || len(ds.Files) != 1 {
+		t.Fatalf("parsing patch: %v\n%s", err, patch.Patch)
+	}
+	var out strings.Builder
+	if err := gitdiff.Apply(&out, strings.NewReader(old), &gitdiff.File{TextFragments: ds.Files[0].Fragments}); err != nil {
+		t.Fatalf("applying patch: %v\n%s", err, patch.Patch)
+	}
+	if want := strings.Replace(old, "var d = 1", "var d = 2", 1); out.String() != want {
+		t.Errorf("patch gave:\n%s", out.String())
+	}
+}
+
+func TestSessionPatchEndpoints(t *testing.T) {
+	srv := newTestServer()
+	ts := httptest.NewServer(srv.Handler())
+	defer ts.Close()
+
+	wsURL := "ws" + strings.TrimPrefix(ts.URL, "http") + "/api/ws"
+	conn, _ := dialSession(t, wsURL)
+	defer conn.Close()
+
+	send := func(msgType string, payload any) wsMessage {
+		t.Helper()
+		data, _ := json.Marshal(payload)
+		conn.WriteJSON(wsMessage{Type: msgType, Data: data})
+		var msg wsMessage
+		if err := conn.ReadJSON(&msg); err != nil {
+			t.Fatalf("ws read: %v", err)
+		}
+		return msg
+	}
+	post := func(path string, v any) int {
+		t.Helper()
+		resp, err := http.Post(ts.URL+path, "application/json", nil)
+		if err != nil {
+			t.Fatalf("POST %s: %v", path, err)
+		}
+		defer resp.Body.Close()
+		if v != nil {
+			json.NewDecoder(resp.Body).Decode(v)
+		}
+		return resp.StatusCode
+	}
+
+	var parsed wsParsedResponse
+	json.Unmarshal(send(wsMsgLoadDiff, wsLoadDiff{Diff: testDiff}).Data, &parsed)
+	conn.ReadJSON(&wsMessage{}) // analysis
+	if parsed.SessionID == "" {
+		t.Fatal("parsed response has no session_id")
+	}
+	base := "/api/sessions/" + parsed.SessionID
+
+	var patch patchResponse
+	if code := post(base+"/patch", &patch); code != http.StatusOK || patch.Patch != "" || len(patch.Files) != 0 {
+		t.Errorf("before any approval: %d %+v", code, patch)
+	}
+
+	send(wsMsgApprove, wsDecisionMsg{FileIndex: 0})
+	send(wsMsgReject, wsDecisionMsg{FileIndex: 1})
+	post(base+"/patch", &patch)
+	if !strings.Contains(patch.Patch, "+\tprintln(\"goodbye\")") || strings.Contains(patch.Patch, "util.go") ||
+		strings.Join(patch.Files, ",") != "main.go" {
+		t.Errorf("unexpected patch %+v", patch)
+	}
+
+	var msg commitMessageResponse
+	if code := post(base+"/commit-message", &msg); code != http.StatusOK || msg.Subject != "Update main.go" ||
+		!strings.Contains(msg.Message, "Rejected files:\n  - util.go") {
+		t.Errorf("unexpected commit message %d %+v", code, msg)
+	}
+
+	if code := post("/api/sessions/nope/patch", nil); code != http.StatusNotFound {
+		t.Errorf("unknown session: expected 404, got %d", code)
+	}
+
+	// The session outlives its connection
+	conn.Close()
+	if code := post(base+"/patch", &patch); code != http.StatusOK {
+		t.Errorf("after disconnect: expected 200, got %d", code)
+	}
+}
+
+func TestSessionExportImport(t *testing.T) {
+	srv := newTestServer()
+	ts := httptest.NewServer(srv.Handler())
+	defer ts.Close()
+	wsURL := "ws" + strings.TrimPrefix(ts.URL, "http") + "/api/ws"
+
+	// load opens a session on testDiff and returns its connection and ID
+	load := func() (*websocket.Conn, string) {
+		t.Helper()
+		conn, _ := dialSession(t, wsURL)
+		data, _ := json.Marshal(wsLoadDiff{Diff: testDiff})
+		conn.WriteJSON(wsMessage{Type: wsMsgLoadDiff, Data: data})
+		var msg wsMessage
+		conn.ReadJSON(&msg)
+		var parsed wsParsedResponse
+		json.Unmarshal(msg.Data, &parsed)
+		conn.ReadJSON(&wsMessage{}) // analysis
+		return conn, parsed.SessionID
+	}
+	send := func(conn *websocket.Conn, msgType string, payload any) {
+		t.Helper()
+		data, _ := json.Marshal(payload)
+		conn.WriteJSON(wsMessage{Type: msgType, Data: data})
+		conn.ReadJSON(&wsMessage{})
+	}
+
+	first, id := load()
+	defer first.Close()
+	send(first, wsMsgApprove, wsDecisionMsg{FileIndex: 0})
+	send(first, wsMsgComment, wsCommentMsg{FileIndex: 1, Line: 2, Body: "name it sum"})
+
+	resp, err := http.Get(ts.URL + "/api/sessions/" + id + "/export")
+	if err != nil {
+		t.Fatalf("GET export: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	saved, err := savedsession.Parse(body)
+	if err != nil {
+		t.Fatalf("export is not a saved session: %v\n%s", err, body)
+	}
+	if len(saved.Files) != 2 || saved.Files[0].Decision != "approved" || len(saved.Comments) != 1 {
+		t.Errorf("unexpected export %+v", saved)
+	}
+
+	second, id := load()
+	defer second.Close()
+	resp, err = http.Post(ts.URL+"/api/sessions/"+id+"/import", "application/json", bytes.NewReader(body))
+	if err != nil {
+		t.Fatalf("POST import: %v", err)
+	}
+	var state wsStateResponse
+	json.NewDecoder(resp.Body).Decode(&state)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || len(state.Files) != 2 || state.Files[0].Decision != "approved" ||
+		len(state.Comments) != 1 || state.Comments[0].Body != "name it sum" {
+		t.Errorf("unexpected state after import: %d %+v", resp.StatusCode, state)
+	}
+	var msg wsMessage
+	if second.ReadJSON(&msg); msg.Type != wsMsgState {
+		t.Errorf("expected the import broadcast, got %q", msg.Type)
+	}
+
+	resp, err = http.Post(ts.URL+"/api/sessions/"+id+"/import", "application/json", strings.NewReader(`{"version": 99}`))
+	if err != nil {
+		t.Fatalf("POST import: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("newer version: expected 400, got %d", resp.StatusCode)
+	}
+}
+
+func TestSessionLinesEndpoint(t *testing.T) {
+	srv := newTestServer()
+	ts := httptest.NewServer(srv.Handler())
+	defer ts.Close()
+
+	wsURL := "ws" + strings.TrimPrefix(ts.URL, "http") + "/api/ws"
+	conn, _ := dialSession(t, wsURL)
+	defer conn.Close()
+
+	loadData, _ := json.Marshal(wsLoadDiff{Diff: testDiff})
+	conn.WriteJSON(wsMessage{Type: wsMsgLoadDiff, Data: loadData})
+	var msg wsMessage
+	conn.ReadJSON(&msg)
+	var parsed wsParsedResponse
+	json.Unmarshal(msg.Data, &parsed)
+	conn.ReadJSON(&wsMessage{}) // analysis
+
+	get := func(path string) (int, linesResponse) {
+		t.Helper()
+		resp, err := http.Get(ts.URL + path)
+		if err != nil {
+			t.Fatalf("GET %s: %v", path, err)
+		}
+		defer resp.Body.Close()
+		var lr linesResponse
+		json.NewDecoder(resp.Body).Decode(&lr)
+		return resp.StatusCode, lr
+	}
+
+	base := "/api/sessions/" + parsed.SessionID + "/files/"
+	code, lr := get(base + "0/lines")
+	if code != http.StatusOK || lr.File != "main.go" || lr.Style != defaultStyle {
+		t.Fatalf("unexpected response %d %+v", code, lr)
+	}
+
+	var ops []string
+	for _, l := range lr.Lines {
+		ops = append(ops, l.Op)
+	}
+	if got := strings.Join(ops, ","); got != "hunk,context,context,context,delete,add,add,context" {
+		t.Errorf("unexpected ops %s", got)
+	}
+	del, add := lr.Lines[4], lr.Lines[5]
+	if del.OldNum != 4 || del.NewNum != 0 || add.NewNum != 4 || add.OldNum != 0 {
+		t.Errorf("unexpected numbers: delete %d/%d, add %d/%d", del.OldNum, del.NewNum, add.OldNum, add.NewNum)
+	}
+	if len(add.Changed) == 0 || add.Text[add.Changed[0].Start:add.Changed[0].End] == "" {
+		t.Errorf("expected word-level changes on the added line, got %+v", add.Changed)
+	}
+	var text strings.Builder
+	colored := false
+	for _, tok := range add.Tokens {
+		text.WriteString(tok.Text)
+		colored = colored || tok.Color != ""
+	}
+	if text.String() != add.Text || !colored {
+		t.Errorf("tokens %+v don't cover %q with colors", add.Tokens, add.Text)
+	}
+
+	if code, _ := get(base + "0/lines?style=nope"); code != http.StatusBadRequest {
+		t.Errorf("unknown style: expected 400, got %d", code)
+	}
+	if code, _ := get(base + "9/lines"); code != http.StatusNotFound {
+		t.Errorf("file out of range: expected 404, got %d", code)
+	}
+	if code, lr := get(base + "1/lines?style=github"); code != http.StatusOK || lr.Lines[1].NewNum != 1 || lr.Style != "github" {
+		t.Errorf("new file: unexpected %d %+v", code, lr)
+	}
+}
+
+func TestWebSocketComment(t *testing.T) {
+	srv := newTestServer()
+	ts := httptest.NewServer(srv.Handler())
+	defer ts.Close()
+
+	wsURL := "ws" + strings.TrimPrefix(ts.URL, "http") + "/api/ws"
+	conn, _ := dialSession(t, wsURL)
+	defer conn.Close()
+
+	loadData, _ := json.Marshal(wsLoadDiff{Diff: testDiff})
+	conn.WriteJSON(wsMessage{Type: wsMsgLoadDiff, Data: loadData})
+	conn.ReadJSON(&wsMessage{})
+	conn.ReadJSON(&wsMessage{})
+
+	commentData, _ := json.Marshal(wsCommentMsg{FileIndex: 0, Line: 4, Body: "why two prints?"})
+	conn.WriteJSON(wsMessage{Type: wsMsgComment, Data: commentData})
+
+	var msg wsMessage
+	if err := conn.ReadJSON(&msg); err != nil {
+		t.Fatalf("ws read comments: %v", err)
+	}
+	if msg.Type != wsMsgComments {
+		t.Fatalf("expected 'comments' message, got %q", msg.Type)
+	}
+
+	conn.WriteJSON(wsMessage{Type: wsMsgFinish})
+	if err := conn.ReadJSON(&msg); err != nil {
+		t.Fatalf("ws read summary: %v", err)
+	}
+
+	var summary wsSummaryResponse
+	json.Unmarshal(msg.Data, &summary)
+	if len(summary.Comments) != 1 {
+		t.Fatalf("expected 1 comment in summary, got %d", len(summary.Comments))
+	}
+	c := summary.Comments[0]
+	if c.File != "main.go" || c.Line != 4 {
+		t.Errorf("unexpected comment: %+v", c)
+	}
+	if c.HunkIndex == nil || *c.HunkIndex != 0 || c.Time == "" {
+		t.Errorf("expected the comment placed in hunk 0 with a time, got %+v", c)
+	}
+}
+
+func TestSharedReviewSession(t *testing.T) {
+	srv := newTestServer()
+	ts := httptest.NewServer(srv.Handler())
+	defer ts.Close()
+	wsURL := "ws" + strings.TrimPrefix(ts.URL, "http") + "/api/ws"
+
+	// read returns the next message, which must be of the given type
+	read := func(conn *websocket.Conn, want string, v any) {
+		t.Helper()
+		var msg wsMessage
+		if err := conn.ReadJSON(&msg); err != nil {
+			t.Fatalf("ws read %s: %v", want, err)
+		}
+		if msg.Type != want {
+			t.Fatalf("expected %q message, got %q: %s", want, msg.Type, msg.Data)
+		}
+		if v != nil {
+			json.Unmarshal(msg.Data, v)
+		}
+	}
+
+	alice, joined := dialSession(t, wsURL+"?reviewer=alice")
+	defer alice.Close()
+	if joined.Reviewer != "alice" || joined.SessionID == "" {
+		t.Fatalf("unexpected joined message %+v", joined)
+	}
+	loadData, _ := json.Marshal(wsLoadDiff{Diff: testDiff})
+	alice.WriteJSON(wsMessage{Type: wsMsgLoadDiff, Data: loadData})
+	read(alice, wsMsgParsed, nil)
+	read(alice, wsMsgAnalysis, nil)
+	approve, _ := json.Marshal(wsDecisionMsg{FileIndex: 0})
+	alice.WriteJSON(wsMessage{Type: wsMsgApprove, Data: approve})
+	read(alice, wsMsgDecision, nil)
+
+	if _, resp, err := websocket.DefaultDialer.Dial(wsURL+"?session=nope", nil); err == nil || resp.StatusCode != http.StatusNotFound {
+		t.Errorf("joining an unknown session: expected 404, got err=%v", err)
+	}
+
+	// A second reviewer joining is brought up to date
+	bob, _, err := websocket.DefaultDialer.Dial(wsURL+"?session="+joined.SessionID+"&reviewer=bob", nil)
+	if err != nil {
+		t.Fatalf("ws join: %v", err)
+	}
+	defer bob.Close()
+	var bobJoined wsJoinedResponse
+	read(bob, wsMsgJoined, &bobJoined)
+	if bobJoined.SessionID != joined.SessionID || strings.Join(bobJoined.Participants, ",") != "alice,bob" {
+		t.Errorf("unexpected joined message %+v", bobJoined)
+	}
+	var parsed wsParsedResponse
+	read(bob, wsMsgParsed, &parsed)
+	if len(parsed.Files) != 2 {
+		t.Errorf("expected the loaded diff, got %+v", parsed)
+	}
+	read(bob, wsMsgAnalysis, nil)
+	var state wsStateResponse
+	read(bob, wsMsgState, &state)
+	if len(state.Files) != 2 || state.Files[0].Decision != "approved" || state.Files[1].Decision != "pending" {
+		t.Errorf("unexpected state %+v", state)
+	}
+	read(bob, wsMsgParticipants, nil)
+	var participants wsParticipantsResponse
+	read(alice, wsMsgParticipants, &participants)
+	if participants.Joined != "bob" || len(participants.Participants) != 2 {
+		t.Errorf("unexpected participants %+v", participants)
+	}
+
+	// Decisions and comments reach everyone, attributed to their author
+	reject, _ := json.Marshal(wsDecisionMsg{FileIndex: 1})
+	bob.WriteJSON(wsMessage{Type: wsMsgReject, Data: reject})
+	for _, conn := range []*websocket.Conn{alice, bob} {
+		var d wsDecisionResponse
+		read(conn, wsMsgDecision, &d)
+		if d.FileIndex != 1 || d.Decision != "rejected" || d.Reviewer != "bob" {
+			t.Errorf("unexpected decision %+v", d)
+		}
+	}
+	comment, _ := json.Marshal(wsCommentMsg{FileIndex: 0, Body: "LGTM"})
+	alice.WriteJSON(wsMessage{Type: wsMsgComment, Data: comment})
+	for _, conn := range []*websocket.Conn{alice, bob} {
+		var comments []commentJSON
+		read(conn, wsMsgComments, &comments)
+		if len(comments) != 1 || comments[0].Author != "alice" {
+			t.Errorf("unexpected comments %+v", comments)
+		}
+	}
+
+	bob.Close()
+	read(alice, wsMsgParticipants, &participants)
+	if participants.Left != "bob" || strings.Join(participants.Participants, ",") != "alice" {
+		t.Errorf("unexpected participants %+v", participants)
+	}
+}
+
+// grpcClient serves srv's gRPC API over an in-memory listener.
+func grpcClient(t *testing.T, srv *Server) agrevpb.AgrevClient {
+	t.Helper()
+	l := bufconn.Listen(1 << 20)
+	go srv.grpc.Serve(l)
+	t.Cleanup(srv.grpc.Stop)
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return l.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("grpc client: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return agrevpb.NewAgrevClient(conn)
+}
+
+func TestGRPCService(t *testing.T) {
+	client := grpcClient(t, newTestServer())
+	ctx := context.Background()
+
+	ds, err := client.Parse(ctx, &agrevpb.ParseRequest{Diff: testDiff})
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if len(ds.Files) != 2 || ds.Stats.Added != 7 || ds.Files[1].Name != "util.go" || !ds.Files[1].IsNew {
+		t.Fatalf("unexpected diff set %v", ds)
+	}
+	lines := ds.Files[0].Hunks[0].Lines
+	if len(lines) != 7 || lines[3].Op != agrevpb.LineOp_LINE_OP_DELETE || lines[3].OldNum != 4 ||
+		lines[4].Op != agrevpb.LineOp_LINE_OP_ADD || lines[4].NewNum != 4 || lines[4].Text != "\tprintln(\"hello world\")" {
+		t.Errorf("unexpected lines %v", lines)
+	}
+
+	if _, err := client.Parse(ctx, &agrevpb.ParseRequest{}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("Parse without a diff: expected InvalidArgument, got %v", err)
+	}
+
+	resp, err := client.Analyze(ctx, &agrevpb.AnalyzeRequest{Diff: testDiff})
+	if err != nil {
+		t.Fatalf("Analyze: %v", err)
+	}
+	if resp.MaxRisk == agrevpb.RiskLevel_RISK_LEVEL_UNSPECIFIED || resp.Stats.Files != 2 || resp.Summary == "" {
+		t.Errorf("unexpected analysis %v", resp)
+	}
+
+	stream, err := client.AnalyzeStream(ctx, &agrevpb.AnalyzeRequest{Diff: testDiff, Skip: []string{"security"}})
+	if err != nil {
+		t.Fatalf("AnalyzeStream: %v", err)
+	}
+	var events []*agrevpb.AnalyzeEvent
+	for {
+		ev, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("AnalyzeStream recv: %v", err)
+		}
+		events = append(events, ev)
+	}
+	if len(events) != 2+len(analysis.PassNames)-1 {
+		t.Fatalf("expected parsed, a result per pass, and done; got %d events", len(events))
+	}
+	if events[0].GetParsed() == nil || events[len(events)-1].GetDone() == nil {
+		t.Errorf("expected parsed first and done last, got %v", events)
+	}
+	for _, ev := range events[1 : len(events)-1] {
+		if ev.GetPass() == nil || ev.GetPass().Pass == "security" {
+			t.Errorf("unexpected event %v", ev)
+		}
+	}
+
+	hunk := int32(0)
+	applied, err := client.ApplyDecisions(ctx, &agrevpb.ApplyDecisionsRequest{
+		Diff: testDiff,
+		Decisions: []*agrevpb.Decision{
+			{FileIndex: 0, HunkIndex: &hunk, State: agrevpb.DecisionState_DECISION_STATE_APPROVED},
+			{FileIndex: 1, State: agrevpb.DecisionState_DECISION_STATE_REJECTED},
+		},
+		Comments: []*agrevpb.Comment{{File: "main.go", Line: 4, Body: "nice", Author: "alice"}},
+	})
+	if err != nil {
+		t.Fatalf("ApplyDecisions: %v", err)
+	}
+	if len(applied.Files) != 1 || applied.Files[0] != "main.go" || !strings.Contains(applied.Patch, "hello world") ||
+		strings.Contains(applied.Patch, "util.go") || applied.CommitMessage == "" {
+		t.Errorf("unexpected result %v", applied)
+	}
+	_, err = client.ApplyDecisions(ctx, &agrevpb.ApplyDecisionsRequest{
+		Diff:      testDiff,
+		Decisions: []*agrevpb.Decision{{FileIndex: 5, State: agrevpb.DecisionState_DECISION_STATE_APPROVED}},
+	})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("out-of-range decision: expected InvalidArgument, got %v", err)
+	}
+
+	if _, err := client.LoadTrace(ctx, &agrevpb.LoadTraceRequest{}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("LoadTrace without a path: expected InvalidArgument, got %v", err)
+	}
+}
+
+func TestGRPCAuthorization(t *testing.T) {
+	srv := New(":0", Options{
+		Tokens: []Token{{Name: "ide", Value: "read-secret", Scope: ScopeRead}},
+		Logger: slog.New(slog.DiscardHandler),
+	})
+	client := grpcClient(t, srv)
+	req := &agrevpb.ParseRequest{Diff: testDiff}
+
+	if _, err := client.Parse(context.Background(), req); status.Code(err) != codes.Unauthenticated {
+		t.Errorf("without a token: expected Unauthenticated, got %v", err)
+	}
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer wrong")
+	if _, err := client.Parse(ctx, req); status.Code(err) != codes.Unauthenticated {
+		t.Errorf("with a wrong token: expected Unauthenticated, got %v", err)
+	}
+	ctx = metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer read-secret", "x-request-id", "ide-7")
+	var header metadata.MD
+	if _, err := client.Parse(ctx, req, grpc.Header(&header)); err != nil {
+		t.Errorf("with a read token: %v", err)
+	}
+	if got := header.Get("x-request-id"); len(got) != 1 || got[0] != "ide-7" {
+		t.Errorf("expected the request ID echoed, got %v", got)
+	}
+}
+
+func TestAnalyzeBatchEndpoint(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+	dir := t.TempDir()
+	run := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		cmd.Env = append(os.Environ(),
+			"GIT_AUTHOR_NAME=Test", "GIT_AUTHOR_EMAIL=test@example.com",
+			"GIT_COMMITTER_NAME=Test", "GIT_COMMITTER_EMAIL=test@example.com")
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	run("init", "-q")
+	os.WriteFile(filepath.Join(dir, "a.txt"), []byte("one\n"), 0644)
+	run("add", ".")
+	run("commit", "-q", "-m", "base")
+	os.WriteFile(filepath.Join(dir, "a.txt"), []byte("one\ntwo\n"), 0644)
+	run("commit", "-q", "-am", "Add two")
+
+	srv := newTestServer()
+	post := func(req batchAnalyzeRequest) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(req)
+		w := httptest.NewRecorder()
+		srv.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/analyze/batch", bytes.NewReader(body)))
+		return w
+	}
+
+	w := post(batchAnalyzeRequest{
+		Items: []batchItem{
+			{Name: "agent-pr-1", Diff: testDiff},
+			{},
+		},
+		Commits: []string{"HEAD", "HEAD~1..HEAD", "nope"},
+		RepoDir: dir,
+	})
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp batchAnalyzeResponse
+	json.Unmarshal(w.Body.Bytes(), &resp)
+
+	names := []string{}
+	for _, it := range resp.Items {
+		names = append(names, it.Name)
+	}
+	if strings.Join(names, ",") != "agent-pr-1,#2,HEAD,HEAD~1..HEAD,nope" {
+		t.Fatalf("unexpected items %v", names)
+	}
+	if r := resp.Items[0].Result; r == nil || r.Stats.Files != 2 {
+		t.Errorf("unexpected result for a diff %+v", resp.Items[0])
+	}
+	if resp.Items[1].Error == "" || resp.Items[4].Error == "" {
+		t.Errorf("expected errors for the bad diff and commit, got %+v", resp.Items)
+	}
+	for _, it := range resp.Items[2:4] {
+		if it.Result == nil || it.Result.Stats.Files != 1 || it.Result.Stats.Added != 1 {
+			t.Errorf("unexpected result for commit %s: %+v", it.Name, it)
+		}
+	}
+
+	agg := resp.Aggregate
+	if agg.Items != 5 || agg.Failed != 2 || len(agg.Riskiest) != 3 || agg.Stats.Files != 4 {
+		t.Errorf("unexpected aggregate %+v", agg)
+	}
+	total := 0
+	for _, n := range agg.ByRisk {
+		total += n
+	}
+	if total != 3 {
+		t.Errorf("expected every analyzed item counted by risk, got %v", agg.ByRisk)
+	}
+
+	for _, bad := range []batchAnalyzeRequest{
+		{},
+		{Commits: []string{"HEAD"}},
+		{Commits: []string{"--output=/tmp/x"}, RepoDir: dir},
+		{Items: make([]batchItem, maxBatchItems+1)},
+	} {
+		if w := post(bad); w.Code != http.StatusBadRequest {
+			t.Errorf("%+v: expected 400, got %d", bad, w.Code)
+		}
+	}
+}
+
+func TestEditorServer(t *testing.T) {
+	repoDir := t.TempDir()
+	current := testDiff
+	toServer, fromEditor := io.Pipe()
+	fromServer, toEditor := io.Pipe()
+	done := make(chan error, 1)
+	go func() {
+		done <- ServeEditor(toServer, toEditor, EditorOptions{
+			RepoDir:  repoDir,
+			Diff:     func() (string, error) { return current, nil },
+			Reviewer: "Ada <ada@example.com>",
+		})
+		toEditor.Close()
+	}()
+	conn := jsonrpc.NewConn(fromServer, fromEditor)
+
+	// call returns the result of a request, and the notifications sent
+	// before it
+	id := 0
+	call := func(method string, params, result any) (*jsonrpc.Error, []*jsonrpc.Message) {
+		t.Helper()
+		id++
+		data, _ := json.Marshal(params)
+		rawID, _ := json.Marshal(id)
+		go conn.Write(&jsonrpc.Message{ID: rawID, Method: method, Params: data})
+		var notes []*jsonrpc.Message
+		for {
+			m, err := conn.Read()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if m.Method != "" {
+				notes = append(notes, m)
+				continue
+			}
+			if m.Error == nil {
+				json.Unmarshal(m.Result, result)
+			}
+			return m.Error, notes
+		}
+	}
+
+	if err, _ := call(editorAnalyze, nil, nil); err == nil || err.Code != jsonrpc.CodeInvalidRequest {
+		t.Errorf("expected analyze before parse to fail, got %v", err)
+	}
+
+	var parsed editorParsed
+	if err, _ := call(editorParse, nil, &parsed); err != nil {
+		t.Fatal(err)
+	}
+	if len(parsed.Files) != 2 || len(parsed.State.Files) != 2 || parsed.State.Files[0].Decision != "pending" {
+		t.Fatalf("unexpected parse result %+v", parsed)
+	}
+
+	var analyzed wsAnalysisResponse
+	err, notes := call(editorAnalyze, editorAnalyzeParams{}, &analyzed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	streamed := 0
+	for _, n := range notes {
+		var p editorFindingsParams
+		if n.Method != editorFindings || json.Unmarshal(n.Params, &p) != nil || p.Pass == "" {
+			t.Fatalf("unexpected notification %+v", n)
+		}
+		streamed += len(p.Findings)
+	}
+	if len(notes) != len(analysis.PassNames) || streamed != analyzed.Total {
+		t.Errorf("expected each pass's findings streamed, got %d notifications with %d findings of %d", len(notes), streamed, analyzed.Total)
+	}
+
+	var decided wsDecisionResponse
+	if err, _ := call(editorReject, wsDecisionMsg{FileIndex: 1, Note: "unused"}, &decided); err != nil {
+		t.Fatal(err)
+	}
+	if decided.Decision != "rejected" || decided.Note != "unused" || decided.Reviewer != "Ada <ada@example.com>" {
+		t.Errorf("unexpected decision %+v", decided)
+	}
+	if err, _ := call(editorApprove, wsDecisionMsg{FileIndex: 5}, &decided); err == nil || err.Code != jsonrpc.CodeInvalidParams {
+		t.Errorf("expected an out of range file refused, got %v", err)
+	}
+	saved, loadErr := savedsession.Load(repoDir)
+	if loadErr != nil || saved == nil || saved.Files[1].Decision != "rejected" {
+		t.Fatalf("expected the decision saved, got %+v, %v", saved, loadErr)
+	}
+	events, _ := audit.Load(repoDir)
+	if len(events) != 2 || events[0].Source != "editor" || events[0].Reviewer != "Ada <ada@example.com>" {
+		t.Errorf("expected the rejection and its note audited, got %+v", events)
+	}
+
+	// util.go is unchanged, so it keeps its decision; main.go changed
+	current = strings.Replace(testDiff, `println("goodbye")`, `println("bye")`, 1)
+	var refreshed editorRefreshed
+	if err, _ := call(editorRefresh, nil, &refreshed); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(refreshed.Changed, []int{0}) || refreshed.State.Files[1].Decision != "rejected" {
+		t.Errorf("unexpected refresh %+v", refreshed)
+	}
+
+	fromEditor.Close()
+	if err := <-done; err != nil {
+		t.Errorf("expected the server to stop when the editor goes, got %v", err)
+	}
+}
+
+func TestWorkspaceEndpoints(t *testing.T) {
+	if w := get(t, newTestServer(), "/api/workspace/diff"); w.Code != http.StatusNotFound {
+		t.Errorf("/api/workspace/diff without a workspace: expected 404, got %d", w.Code)
+	}
+
+	repoDir := t.TempDir()
+	tr := &trace.Trace{Source: "generic", Steps: []trace.Step{
+		{Type: trace.StepFileRead, Summary: "Read util.go", FilePath: "util.go"},
+		{Type: trace.StepFileEdit, Summary: "Edit main.go", FilePath: "main.go", OldString: "a", NewString: "\tprintln(\"goodbye\")"},
+	}}
+	srv := New(":0", Options{
+		Tokens: []Token{{Name: "vscode", Value: "secret", Scope: ScopeWrite}},
+		Workspace: func() (*Source, error) {
+			return &Source{Diff: testDiff, RepoDir: repoDir, Trace: tr}, nil
+		},
+	})
+	do := func(method, path string, body any, out any) int {
+		t.Helper()
+		data, _ := json.Marshal(body)
+		req := httptest.NewRequest(method, path, bytes.NewReader(data))
+		req.Header.Set("Authorization", "Bearer secret")
+		w := httptest.NewRecorder()
+		srv.Handler().ServeHTTP(w, req)
+		if out != nil {
+			json.Unmarshal(w.Body.Bytes(), out)
+		}
+		return w.Code
+	}
+
+	var changes workspaceDiffResponse
+	if code := do("GET", "/api/workspace/diff", nil, &changes); code != http.StatusOK {
+		t.Fatalf("diff: got %d", code)
+	}
+	if changes.RepoDir != repoDir || changes.Diff != testDiff || len(changes.Files) != 2 || changes.Stats.Files != 2 {
+		t.Errorf("unexpected diff %+v", changes)
+	}
+
+	var all, main workspaceFindingsResponse
+	do("GET", "/api/workspace/findings", nil, &all)
+	do("GET", "/api/workspace/findings?file=main.go", nil, &main)
+	for _, f := range main.Findings {
+		if f.File != "main.go" {
+			t.Errorf("expected only main.go's findings, got %+v", f)
+		}
+	}
+	if len(main.Findings) > len(all.Findings) {
+		t.Errorf("expected main.go's findings among all %d, got %d", len(all.Findings), len(main.Findings))
+	}
+
+	var state wsStateResponse
+	if code := do("POST", "/api/workspace/decisions", workspaceDecisionRequest{File: "util.go", Decision: "reject", Note: "unused"}, &state); code != http.StatusOK {
+		t.Fatalf("decide: got %d", code)
+	}
+	if state.Files[1].Decision != "rejected" || state.Files[0].Decision != "pending" {
+		t.Errorf("unexpected state %+v", state)
+	}
+	if code := do("POST", "/api/workspace/decisions", workspaceDecisionRequest{File: "gone.go", Decision: "approve"}, nil); code != http.StatusNotFound {
+		t.Errorf("expected a file without changes refused, got %d", code)
+	}
+	if code := do("POST", "/api/workspace/decisions", workspaceDecisionRequest{File: "main.go", Decision: "maybe"}, nil); code != http.StatusBadRequest {
+		t.Errorf("expected an unknown decision refused, got %d", code)
+	}
+	saved, err := savedsession.Load(repoDir)
+	if err != nil || saved == nil || saved.Files[1].Decision != "rejected" {
+		t.Fatalf("expected the decision saved, got %+v, %v", saved, err)
+	}
+	events, _ := audit.Load(repoDir)
+	if len(events) != 2 || events[0].Source != "api" || events[0].Reviewer != "vscode" {
+		t.Errorf("expected the rejection and its note audited, got %+v", events)
+	}
+	state = wsStateResponse{}
+	do("GET", "/api/workspace/decisions", nil, &state)
+	if state.Files[1].Decision != "rejected" {
+		t.Errorf("expected the saved decision, got %+v", state)
+	}
+
+	var steps workspaceTraceResponse
+	if code := do("GET", "/api/workspace/trace?file=main.go", nil, &steps); code != http.StatusOK {
+		t.Fatalf("trace: got %d", code)
+	}
+	if len(steps.Steps) != 1 || steps.Steps[0].Step != 2 || steps.Steps[0].Type != "edit" || len(steps.Annotations) == 0 || steps.Annotations[0].Step != 2 {
+		t.Errorf("unexpected trace %+v", steps)
+	}
+	if code := do("GET", "/api/workspace/trace", nil, nil); code != http.StatusBadRequest {
+		t.Errorf("expected the file required, got %d", code)
+	}
+}
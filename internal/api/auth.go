@@ -0,0 +1,110 @@
+package api
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"net/http"
+	"strings"
+)
+
+// Scope is what a token is allowed to do.
+type Scope int
+
+const (
+	// ScopeRead allows the stateless endpoints: analyze, parse, summary.
+	ScopeRead Scope = iota
+	// ScopeWrite also allows review sessions, which record decisions.
+	ScopeWrite
+)
+
+func (s Scope) String() string {
+	if s == ScopeWrite {
+		return "write"
+	}
+	return "read"
+}
+
+// ParseScope parses "read" or "write" ("read-write" is accepted too).
+func ParseScope(name string) (Scope, bool) {
+	switch strings.ToLower(name) {
+	case "", "read", "read-only":
+		return ScopeRead, true
+	case "write", "read-write":
+		return ScopeWrite, true
+	}
+	return 0, false
+}
+
+// Token is a bearer token accepted by the server.
+type Token struct {
+	Name  string // for logs; never the secret itself
+	Value string
+	Scope Scope
+}
+
+// authorize wraps h so it only runs for requests carrying a token with at
+// least the given scope. Without configured tokens every request is let
+// through, as before authentication existed.
+func (s *Server) authorize(scope Scope, h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if len(s.tokens) == 0 {
+			h(w, r)
+			return
+		}
+		tok, ok := s.authenticate(r)
+		if !ok {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="agrev"`)
+			writeError(w, http.StatusUnauthorized, "missing or invalid bearer token")
+			return
+		}
+		if tok.Scope < scope {
+			writeError(w, http.StatusForbidden, "token "+tok.Name+" has "+tok.Scope.String()+" scope; this endpoint needs "+scope.String())
+			return
+		}
+		h(w, r.WithContext(context.WithValue(r.Context(), tokenKey{}, tok)))
+	}
+}
+
+type tokenKey struct{}
+
+// tokenFrom returns the token a request was authorized with, if any.
+func tokenFrom(ctx context.Context) (Token, bool) {
+	tok, ok := ctx.Value(tokenKey{}).(Token)
+	return tok, ok
+}
+
+// authenticate finds the token presented by r. Browsers can't set headers
+// on WebSocket connections, so upgrade requests may pass it as the
+// access_token query parameter instead.
+func (s *Server) authenticate(r *http.Request) (Token, bool) {
+	presented := ""
+	if auth := r.Header.Get("Authorization"); len(auth) > 7 && strings.EqualFold(auth[:7], "bearer ") {
+		presented = strings.TrimSpace(auth[7:])
+	} else if strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+		presented = r.URL.Query().Get("access_token")
+	}
+	return s.matchToken(presented)
+}
+
+// matchToken finds the configured token equal to presented.
+func (s *Server) matchToken(presented string) (Token, bool) {
+	if presented == "" {
+		return Token{}, false
+	}
+
+	// Compare digests so neither the contents nor the length of a token
+	// leak through timing, and check every token rather than stopping at
+	// the first match.
+	sum := sha256.Sum256([]byte(presented))
+	var found Token
+	match := 0
+	for _, tok := range s.tokens {
+		want := sha256.Sum256([]byte(tok.Value))
+		if subtle.ConstantTimeCompare(sum[:], want[:]) == 1 {
+			found = tok
+			match = 1
+		}
+	}
+	return found, match == 1
+}
@@ -0,0 +1,185 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/aezell/agrev/internal/analysis"
+	"github.com/aezell/agrev/internal/diff"
+	"github.com/aezell/agrev/internal/model"
+)
+
+// maxBatchItems bounds one batch request, commits included.
+const maxBatchItems = 100
+
+type batchAnalyzeRequest struct {
+	Items   []batchItem `json:"items,omitempty"`
+	Commits []string    `json:"commits,omitempty"` // commits or ranges in repo_dir, analyzed as items named after them
+	RepoDir string      `json:"repo_dir,omitempty"` // default repository for items, required with commits
+	Skip    []string    `json:"skip,omitempty"`
+}
+
+// batchItem is a named diff to analyze.
+type batchItem struct {
+	Name    string `json:"name"`
+	Diff    string `json:"diff"`
+	RepoDir string `json:"repo_dir,omitempty"` // overrides the request's repo_dir
+}
+
+type batchAnalyzeResponse struct {
+	Items     []batchItemResult `json:"items"` // in request order: items, then commits
+	Aggregate batchAggregate    `json:"aggregate"`
+}
+
+// batchItemResult is one item's analysis, or why it couldn't be analyzed.
+type batchItemResult struct {
+	Name   string           `json:"name"`
+	Error  string           `json:"error,omitempty"`
+	Result *analyzeResponse `json:"result,omitempty"`
+}
+
+// batchAggregate summarizes a batch across its items.
+type batchAggregate struct {
+	Items    int            `json:"items"`
+	Failed   int            `json:"failed"`
+	Findings int            `json:"findings"`
+	MaxRisk  string         `json:"max_risk" enum:"info,low,medium,high,critical"`
+	ByRisk   map[string]int `json:"by_risk"`  // number of items at each max risk level
+	Riskiest []string       `json:"riskiest"` // analyzed item names, highest risk first
+	Stats    diffStatsJSON  `json:"stats"`    // totals over the analyzed items
+}
+
+func (s *Server) handleAnalyzeBatch(w http.ResponseWriter, r *http.Request) {
+	var req batchAnalyzeRequest
+	if err := readJSON(r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request: "+err.Error())
+		return
+	}
+
+	n := len(req.Items) + len(req.Commits)
+	switch {
+	case n == 0:
+		writeError(w, http.StatusBadRequest, "items or commits are required")
+		return
+	case n > maxBatchItems:
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("at most %d items per batch", maxBatchItems))
+		return
+	case len(req.Commits) > 0 && req.RepoDir == "":
+		writeError(w, http.StatusBadRequest, "repo_dir is required with commits")
+		return
+	}
+	for _, c := range req.Commits {
+		// Commits are passed to git diff, so they must not look like flags
+		if c == "" || strings.HasPrefix(c, "-") {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid commit %q", c))
+			return
+		}
+	}
+
+	results := make([]batchItemResult, n)
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for range min(n, runtime.GOMAXPROCS(0)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				results[i] = s.analyzeBatchItem(r, req, i)
+			}
+		}()
+	}
+	for i := range n {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	if err := r.Context().Err(); err != nil {
+		s.logger(r.Context()).Info("analysis cancelled", "error", err)
+		writeError(w, http.StatusServiceUnavailable, "analysis cancelled")
+		return
+	}
+	writeJSON(w, http.StatusOK, batchAnalyzeResponse{Items: results, Aggregate: aggregateBatch(results)})
+}
+
+// analyzeBatchItem analyzes the i'th item of req, counting items before
+// commits.
+func (s *Server) analyzeBatchItem(r *http.Request, req batchAnalyzeRequest, i int) batchItemResult {
+	var item batchItem
+	if i < len(req.Items) {
+		item = req.Items[i]
+		if item.Name == "" {
+			item.Name = fmt.Sprintf("#%d", i+1)
+		}
+		if item.RepoDir == "" {
+			item.RepoDir = req.RepoDir
+		}
+	} else {
+		commit := req.Commits[i-len(req.Items)]
+		item = batchItem{Name: commit, RepoDir: req.RepoDir}
+		// A single commit is compared with its parent
+		rev := commit
+		if !strings.Contains(commit, "..") {
+			rev = commit + "^!"
+		}
+		raw, _, err := diff.GitDiffRange(req.RepoDir, rev, 3)
+		if err != nil {
+			return batchItemResult{Name: item.Name, Error: err.Error()}
+		}
+		item.Diff = raw
+	}
+
+	if item.Diff == "" {
+		return batchItemResult{Name: item.Name, Error: "diff is empty"}
+	}
+	ds, err := diff.Parse(item.Diff)
+	if err != nil {
+		return batchItemResult{Name: item.Name, Error: "parsing diff: " + err.Error()}
+	}
+	res, err := analysis.RunContext(r.Context(), ds, item.RepoDir, req.Skip)
+	if err != nil {
+		return batchItemResult{Name: item.Name, Error: "analysis cancelled"}
+	}
+	resp := newAnalyzeResponse(ds, res)
+	return batchItemResult{Name: item.Name, Result: &resp}
+}
+
+func aggregateBatch(items []batchItemResult) batchAggregate {
+	agg := batchAggregate{Items: len(items), MaxRisk: model.RiskInfo.String(), ByRisk: map[string]int{}, Riskiest: []string{}}
+	maxRisk := model.RiskInfo
+	var analyzed []batchItemResult
+	for _, it := range items {
+		if it.Result == nil {
+			agg.Failed++
+			continue
+		}
+		analyzed = append(analyzed, it)
+		agg.Findings += it.Result.Total
+		agg.ByRisk[it.Result.MaxRisk]++
+		agg.Stats.Files += it.Result.Stats.Files
+		agg.Stats.Added += it.Result.Stats.Added
+		agg.Stats.Deleted += it.Result.Stats.Deleted
+		if r, _ := model.ParseRiskLevel(it.Result.MaxRisk); r > maxRisk {
+			maxRisk = r
+		}
+	}
+	agg.MaxRisk = maxRisk.String()
+
+	// Highest risk first; among equals, more findings first
+	sort.SliceStable(analyzed, func(a, b int) bool {
+		ra, _ := model.ParseRiskLevel(analyzed[a].Result.MaxRisk)
+		rb, _ := model.ParseRiskLevel(analyzed[b].Result.MaxRisk)
+		if ra != rb {
+			return ra > rb
+		}
+		return analyzed[a].Result.Total > analyzed[b].Result.Total
+	})
+	for _, it := range analyzed {
+		agg.Riskiest = append(agg.Riskiest, it.Name)
+	}
+	return agg
+}
@@ -0,0 +1,324 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+
+	"github.com/aezell/agrev/internal/analysis"
+	"github.com/aezell/agrev/internal/audit"
+	"github.com/aezell/agrev/internal/config"
+	"github.com/aezell/agrev/internal/diff"
+	"github.com/aezell/agrev/internal/jsonrpc"
+	"github.com/aezell/agrev/internal/model"
+	"github.com/aezell/agrev/internal/review"
+	savedsession "github.com/aezell/agrev/internal/session"
+	"github.com/aezell/agrev/internal/triage"
+)
+
+// Editor protocol methods, called by the editor.
+const (
+	editorParse   = "parse"
+	editorRefresh = "refresh"
+	editorAnalyze = "analyze"
+	editorApprove = "approve"
+	editorReject  = "reject"
+	editorUndo    = "undo"
+	editorState   = "state"
+)
+
+// Editor protocol notifications, sent to the editor: each analysis pass's
+// findings as it finishes, and problems that didn't fail a request.
+const (
+	editorFindings = "findings"
+	editorLog      = "log"
+)
+
+// EditorOptions configures ServeEditor.
+type EditorOptions struct {
+	// RepoDir is the repository the changes are in. Decisions are saved to
+	// its .agrev/session.json and audit log, and findings triaged in it are
+	// applied. "" leaves the review in memory.
+	RepoDir string
+
+	// Diff returns the changes parse and refresh load when not given a
+	// diff, such as the working tree's.
+	Diff func() (string, error)
+
+	Skip     []string // analysis passes to skip unless analyze names others
+	Reviewer string   // who makes the decisions, for the audit log
+}
+
+// editorServer is the review an editor drives over stdio, the same review
+// a WebSocket session holds without anyone else joining it.
+type editorServer struct {
+	opts    EditorOptions
+	conn    *jsonrpc.Conn
+	session *reviewSession
+	saved   *savedsession.Session // the saved review decisions are kept in
+}
+
+// editorParseParams are the parameters of parse and refresh.
+type editorParseParams struct {
+	Diff string `json:"diff,omitempty"` // the changes; by default those EditorOptions.Diff returns
+}
+
+// editorAnalyzeParams are the parameters of analyze.
+type editorAnalyzeParams struct {
+	Skip []string `json:"skip,omitempty"`
+}
+
+// editorParsed is the result of parse: the files, and their decisions
+// carried over from the saved review.
+type editorParsed struct {
+	Files []fileJSON      `json:"files"`
+	Stats diffStatsJSON   `json:"stats"`
+	Moves []moveJSON      `json:"moves"`
+	State wsStateResponse `json:"state"`
+}
+
+// editorRefreshed is the result of refresh. Changed lists the indexes of
+// the files that are new or changed since the last parse or refresh, which
+// start over undecided; the rest keep their decisions. Findings are
+// cleared until the next analyze.
+type editorRefreshed struct {
+	editorParsed
+	Changed []int    `json:"changed"`
+	Removed []string `json:"removed,omitempty"` // files no longer changed
+}
+
+// editorFindingsParams are the findings of one analysis pass.
+type editorFindingsParams struct {
+	Pass     string        `json:"pass"`
+	Findings []findingJSON `json:"findings"`
+}
+
+// ServeEditor runs the editor protocol: JSON-RPC 2.0 requests framed with
+// Content-Length headers, as in LSP, read from r and answered on w until r
+// ends. It lets an editor plugin parse the changes, analyze them with the
+// findings streamed pass by pass, and approve, reject, and undo files and
+// hunks.
+func ServeEditor(r io.Reader, w io.Writer, opts EditorOptions) error {
+	e := &editorServer{opts: opts, conn: jsonrpc.NewConn(r, w), session: &reviewSession{}}
+	for {
+		m, err := e.conn.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if rpcErr, ok := err.(*jsonrpc.Error); ok {
+			e.conn.Reply(nil, nil, rpcErr)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if m.Method == "" || m.IsNotification() {
+			continue // the protocol has no notifications from the editor
+		}
+		result, err := e.handle(m)
+		if err := e.conn.Reply(m.ID, result, err); err != nil {
+			return err
+		}
+	}
+}
+
+func (e *editorServer) handle(m *jsonrpc.Message) (any, error) {
+	switch m.Method {
+	case editorParse, editorRefresh:
+		var p editorParseParams
+		if err := unmarshalParams(m.Params, &p); err != nil {
+			return nil, err
+		}
+		if m.Method == editorRefresh {
+			return e.refresh(p)
+		}
+		return e.parse(p)
+	case editorAnalyze:
+		p := editorAnalyzeParams{Skip: e.opts.Skip}
+		if err := unmarshalParams(m.Params, &p); err != nil {
+			return nil, err
+		}
+		return e.analyze(p)
+	case editorApprove, editorReject, editorUndo:
+		var p wsDecisionMsg
+		if err := unmarshalParams(m.Params, &p); err != nil {
+			return nil, err
+		}
+		return e.decide(m.Method, p)
+	case editorState:
+		if e.session.review == nil {
+			return nil, errNoDiff
+		}
+		return e.session.stateResponse(), nil
+	}
+	return nil, jsonrpc.Errorf(jsonrpc.CodeMethodNotFound, "unknown method %s", m.Method)
+}
+
+var errNoDiff = jsonrpc.Errorf(jsonrpc.CodeInvalidRequest, "no diff loaded; call parse first")
+
+func unmarshalParams(params json.RawMessage, v any) error {
+	if len(params) == 0 || string(params) == "null" {
+		return nil
+	}
+	if err := json.Unmarshal(params, v); err != nil {
+		return jsonrpc.Errorf(jsonrpc.CodeInvalidParams, "invalid params: %v", err)
+	}
+	return nil
+}
+
+// load parses the changes p gives, or those the options return.
+func (e *editorServer) load(p editorParseParams) (*diff.DiffSet, error) {
+	raw := p.Diff
+	if raw == "" {
+		if e.opts.Diff == nil {
+			return nil, jsonrpc.Errorf(jsonrpc.CodeInvalidParams, "diff is required")
+		}
+		var err error
+		if raw, err = e.opts.Diff(); err != nil {
+			return nil, err
+		}
+	}
+	return diff.Parse(raw)
+}
+
+// parse starts the review over on the changes, resuming the decisions of
+// the saved review on files that haven't changed since.
+func (e *editorServer) parse(p editorParseParams) (*editorParsed, error) {
+	ds, err := e.load(p)
+	if err != nil {
+		return nil, err
+	}
+	s := e.session
+	s.review = review.New(ds, nil, nil)
+	s.repoDir, s.triage, s.audit, e.saved = e.opts.RepoDir, nil, nil, nil
+	if s.repoDir != "" {
+		if cfg, err := config.Load(s.repoDir); err != nil {
+			return nil, err
+		} else if !cfg.NoAudit {
+			s.audit = audit.New(s.repoDir, "editor")
+			s.audit.Reviewer, s.audit.Range = e.opts.Reviewer, s.review.CommitRange
+		}
+		if e.saved, err = savedsession.Load(s.repoDir); err != nil {
+			return nil, err
+		}
+		if e.saved != nil {
+			e.saved.Restore(s.review)
+		}
+	}
+	return e.parsed(), nil
+}
+
+// refresh loads the changes again, keeping the decisions on files whose
+// changes are the same.
+func (e *editorServer) refresh(p editorParseParams) (*editorRefreshed, error) {
+	if e.session.review == nil {
+		return nil, errNoDiff
+	}
+	ds, err := e.load(p)
+	if err != nil {
+		return nil, err
+	}
+	before := savedsession.New(e.session.review.Diff)
+	before.Record(e.session.review)
+	after := savedsession.New(ds)
+
+	rs := review.New(ds, nil, nil)
+	before.Restore(rs)
+	e.session.review = rs
+
+	unchanged := make(map[string]bool)
+	for _, f := range before.Files {
+		unchanged[f.Name+"\x00"+f.Hash] = true
+	}
+	out := &editorRefreshed{editorParsed: *e.parsed(), Changed: []int{}}
+	current := make(map[string]bool)
+	for i, f := range after.Files {
+		current[f.Name] = true
+		if !unchanged[f.Name+"\x00"+f.Hash] {
+			out.Changed = append(out.Changed, i)
+		}
+	}
+	for _, f := range before.Files {
+		if !current[f.Name] {
+			out.Removed = append(out.Removed, f.Name)
+		}
+	}
+	return out, nil
+}
+
+func (e *editorServer) parsed() *editorParsed {
+	p := e.session.parsedResponse()
+	return &editorParsed{Files: p.Files, Stats: p.Stats, Moves: p.Moves, State: e.session.stateResponse()}
+}
+
+// analyze runs the analysis, sending each pass's findings as it finishes,
+// and returns them all.
+func (e *editorServer) analyze(p editorAnalyzeParams) (*wsAnalysisResponse, error) {
+	s := e.session
+	if s.review == nil {
+		return nil, errNoDiff
+	}
+	if s.repoDir != "" {
+		var err error
+		if s.triage, err = triage.Load(s.repoDir); err != nil {
+			return nil, err
+		}
+	}
+	results := &analysis.Results{}
+	err := analysis.RunEach(context.Background(), s.review.Diff, s.repoDir, p.Skip, func(pass string, findings []analysis.Finding) {
+		passResults := &analysis.Results{Findings: findings}
+		s.triage.Apply(passResults)
+		results.Findings = append(results.Findings, passResults.Findings...)
+		results.Suppressed = append(results.Suppressed, passResults.Suppressed...)
+
+		note := editorFindingsParams{Pass: pass, Findings: []findingJSON{}}
+		for _, f := range passResults.Findings {
+			note.Findings = append(note.Findings, newFindingJSON(f))
+		}
+		e.conn.Notify(editorFindings, note)
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.review.SetResults(results)
+	resp := newAnalysisResponse(results)
+	return &resp, nil
+}
+
+// decide approves, rejects, or undoes the decision on a file or hunk, and
+// saves the review so 'agrev review' and 'agrev report' pick it up.
+func (e *editorServer) decide(method string, p wsDecisionMsg) (*wsDecisionResponse, error) {
+	s := e.session
+	if s.review == nil {
+		return nil, errNoDiff
+	}
+	if msg := s.checkTarget(p); msg != "" {
+		return nil, jsonrpc.Errorf(jsonrpc.CodeInvalidParams, "%s", msg)
+	}
+	var resp wsDecisionResponse
+	logAction := func(ev audit.Event) {
+		if err := s.audit.Write(ev); err != nil {
+			e.conn.Notify(editorLog, map[string]string{"message": "writing audit log: " + err.Error()})
+		}
+	}
+	switch method {
+	case editorApprove:
+		resp = s.decide(p, model.DecisionApproved, logAction)
+	case editorReject:
+		resp = s.decide(p, model.DecisionRejected, logAction)
+	default:
+		resp = s.undo(p, logAction)
+	}
+	resp.Reviewer = e.opts.Reviewer
+
+	if s.repoDir != "" {
+		if e.saved == nil {
+			e.saved = savedsession.New(s.review.Diff)
+		}
+		e.saved.Record(s.review)
+		if err := e.saved.Save(s.repoDir); err != nil {
+			return nil, err
+		}
+	}
+	return &resp, nil
+}
@@ -0,0 +1,403 @@
+package api
+
+import (
+	"context"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/bluekeyes/go-gitdiff/gitdiff"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/aezell/agrev/internal/analysis"
+	"github.com/aezell/agrev/internal/api/agrevpb"
+	"github.com/aezell/agrev/internal/diff"
+	"github.com/aezell/agrev/internal/model"
+	"github.com/aezell/agrev/internal/review"
+	"github.com/aezell/agrev/internal/trace"
+)
+
+// newGRPCServer creates the gRPC server for the Agrev service defined in
+// agrevpb/agrev.proto, sharing the HTTP server's tokens and logger.
+func (s *Server) newGRPCServer() *grpc.Server {
+	g := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(s.unaryInterceptor),
+		grpc.ChainStreamInterceptor(s.streamInterceptor),
+	)
+	agrevpb.RegisterAgrevServer(g, &grpcService{s: s})
+	return g
+}
+
+// ListenAndServeGRPC serves the gRPC API on addr. After Shutdown it
+// returns nil.
+func (s *Server) ListenAndServeGRPC(addr string) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	s.log.Info("agrev gRPC server listening", "addr", addr)
+	return s.grpc.Serve(l)
+}
+
+// unaryInterceptor and streamInterceptor authorize and log each call, as
+// authorize and logRequests do for HTTP requests.
+func (s *Server) unaryInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	ctx, done, err := s.startRPC(ctx, info.FullMethod)
+	if err != nil {
+		done(err)
+		return nil, err
+	}
+	resp, err := handler(ctx, req)
+	done(err)
+	return resp, err
+}
+
+func (s *Server) streamInterceptor(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx, done, err := s.startRPC(ss.Context(), info.FullMethod)
+	if err != nil {
+		done(err)
+		return err
+	}
+	err = handler(srv, &contextStream{ServerStream: ss, ctx: ctx})
+	done(err)
+	return err
+}
+
+// startRPC checks the call's token, which every method needs read scope
+// for, and returns a context carrying a request-scoped logger and a func
+// that logs the call's outcome.
+func (s *Server) startRPC(ctx context.Context, method string) (context.Context, func(error), error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	id := first(md.Get(strings.ToLower(requestIDHeader)))
+	if !validRequestID.MatchString(id) {
+		id = newRequestID()
+	}
+	grpc.SetHeader(ctx, metadata.Pairs(strings.ToLower(requestIDHeader), id))
+
+	log := s.log.With("request_id", id)
+	ctx = context.WithValue(ctx, loggerKey{}, log)
+	start := time.Now()
+	done := func(err error) {
+		attrs := []any{"method", method, "code", status.Code(err).String(), "duration", time.Since(start)}
+		if err != nil {
+			attrs = append(attrs, "error", status.Convert(err).Message())
+		}
+		log.Info("rpc", attrs...)
+	}
+
+	if len(s.tokens) == 0 {
+		return ctx, done, nil
+	}
+	presented := first(md.Get("authorization"))
+	if len(presented) > 7 && strings.EqualFold(presented[:7], "bearer ") {
+		presented = strings.TrimSpace(presented[7:])
+	} else {
+		presented = ""
+	}
+	tok, ok := s.matchToken(presented)
+	if !ok {
+		return ctx, done, status.Error(codes.Unauthenticated, "missing or invalid bearer token")
+	}
+	if tok.Scope < ScopeRead {
+		return ctx, done, status.Error(codes.PermissionDenied, "token "+tok.Name+" has "+tok.Scope.String()+" scope; this method needs "+ScopeRead.String())
+	}
+	return context.WithValue(ctx, tokenKey{}, tok), done, nil
+}
+
+func first(values []string) string {
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
+
+// contextStream replaces a stream's context with one carrying the
+// request-scoped logger.
+type contextStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *contextStream) Context() context.Context { return s.ctx }
+
+// grpcService implements agrevpb.AgrevServer.
+type grpcService struct {
+	agrevpb.UnimplementedAgrevServer
+	s *Server
+}
+
+func (g *grpcService) Parse(ctx context.Context, req *agrevpb.ParseRequest) (*agrevpb.DiffSet, error) {
+	ds, err := parseDiffRPC(req.GetDiff())
+	if err != nil {
+		return nil, err
+	}
+	return diffSetPB(ds), nil
+}
+
+func (g *grpcService) Analyze(ctx context.Context, req *agrevpb.AnalyzeRequest) (*agrevpb.AnalyzeResponse, error) {
+	ds, err := parseDiffRPC(req.GetDiff())
+	if err != nil {
+		return nil, err
+	}
+	results, err := analysis.RunContext(ctx, ds, req.GetRepoDir(), req.GetSkip())
+	if err != nil {
+		return nil, status.FromContextError(err).Err()
+	}
+	resp := &agrevpb.AnalyzeResponse{
+		Summary: results.Summary(),
+		MaxRisk: riskPB(results.MaxRisk()),
+		Stats:   statsPB(ds),
+	}
+	for _, f := range results.Findings {
+		resp.Findings = append(resp.Findings, findingPB(f))
+	}
+	return resp, nil
+}
+
+func (g *grpcService) AnalyzeStream(req *agrevpb.AnalyzeRequest, stream agrevpb.Agrev_AnalyzeStreamServer) error {
+	ds, err := parseDiffRPC(req.GetDiff())
+	if err != nil {
+		return err
+	}
+	if err := stream.Send(&agrevpb.AnalyzeEvent{Event: &agrevpb.AnalyzeEvent_Parsed{Parsed: diffSetPB(ds)}}); err != nil {
+		return err
+	}
+
+	results := &analysis.Results{}
+	var sendErr error
+	err = analysis.RunEach(stream.Context(), ds, req.GetRepoDir(), req.GetSkip(), func(pass string, findings []analysis.Finding) {
+		results.Findings = append(results.Findings, findings...)
+		if sendErr != nil {
+			return
+		}
+		pr := &agrevpb.PassResult{Pass: pass}
+		for _, f := range findings {
+			pr.Findings = append(pr.Findings, findingPB(f))
+		}
+		sendErr = stream.Send(&agrevpb.AnalyzeEvent{Event: &agrevpb.AnalyzeEvent_Pass{Pass: pr}})
+	})
+	if sendErr != nil {
+		return sendErr
+	}
+	if err != nil {
+		return status.FromContextError(err).Err()
+	}
+	return stream.Send(&agrevpb.AnalyzeEvent{Event: &agrevpb.AnalyzeEvent_Done{Done: &agrevpb.AnalyzeDone{
+		Summary: results.Summary(),
+		MaxRisk: riskPB(results.MaxRisk()),
+		Total:   int32(len(results.Findings)),
+	}}})
+}
+
+func (g *grpcService) LoadTrace(ctx context.Context, req *agrevpb.LoadTraceRequest) (*agrevpb.Trace, error) {
+	var t *trace.Trace
+	var err error
+	switch {
+	case req.GetPath() != "":
+		t, err = trace.Load(req.GetPath(), req.GetFormat())
+	case req.GetRepoDir() != "":
+		t, err = trace.DetectAndLoad(req.GetRepoDir())
+	default:
+		return nil, status.Error(codes.InvalidArgument, "path or repo_dir is required")
+	}
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "loading trace: "+err.Error())
+	}
+	if t == nil {
+		return nil, status.Error(codes.NotFound, "no trace found")
+	}
+	return tracePB(t), nil
+}
+
+func (g *grpcService) ApplyDecisions(ctx context.Context, req *agrevpb.ApplyDecisionsRequest) (*agrevpb.ApplyDecisionsResponse, error) {
+	ds, err := parseDiffRPC(req.GetDiff())
+	if err != nil {
+		return nil, err
+	}
+
+	// Replay the decisions into a session so the patch and commit message
+	// match the WebSocket protocol's
+	session := &reviewSession{review: review.New(ds, nil, nil)}
+	for _, d := range req.GetDecisions() {
+		target := wsDecisionMsg{FileIndex: int(d.GetFileIndex())}
+		if d.HunkIndex != nil {
+			h := int(d.GetHunkIndex())
+			target.HunkIndex = &h
+		}
+		if msg := session.checkTarget(target); msg != "" {
+			return nil, status.Error(codes.InvalidArgument, msg)
+		}
+		decision := decisionModel(d.GetState())
+		if target.HunkIndex != nil {
+			session.review.Decisions.SetHunk(target.FileIndex, *target.HunkIndex, decision)
+		} else {
+			session.review.Decisions.SetFile(target.FileIndex, decision)
+		}
+	}
+	for _, c := range req.GetComments() {
+		comment := model.Comment{
+			File:   c.GetFile(),
+			Line:   int(c.GetLine()),
+			Body:   c.GetBody(),
+			Author: c.GetAuthor(),
+			Time:   time.Now(),
+		}
+		for _, f := range ds.Files {
+			if f.Name() == comment.File {
+				comment.Hunk = f.HunkAt(comment.Line)
+				break
+			}
+		}
+		session.review.Comments = append(session.review.Comments, comment)
+	}
+
+	result := session.result()
+	resp := &agrevpb.ApplyDecisionsResponse{
+		Patch:         result.GeneratePatch(),
+		CommitMessage: result.GenerateCommitMessage(),
+	}
+	for _, f := range result.ApprovedFiles() {
+		if !f.IsBinary {
+			resp.Files = append(resp.Files, f.Name())
+		}
+	}
+	return resp, nil
+}
+
+func parseDiffRPC(raw string) (*diff.DiffSet, error) {
+	if raw == "" {
+		return nil, status.Error(codes.InvalidArgument, "diff is required")
+	}
+	ds, err := diff.Parse(raw)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "parsing diff: "+err.Error())
+	}
+	return ds, nil
+}
+
+// --- Conversions to the protobuf types ---
+
+func statsPB(ds *diff.DiffSet) *agrevpb.DiffStats {
+	files, added, deleted := ds.Stats()
+	return &agrevpb.DiffStats{Files: int32(files), Added: int32(added), Deleted: int32(deleted)}
+}
+
+func diffSetPB(ds *diff.DiffSet) *agrevpb.DiffSet {
+	out := &agrevpb.DiffSet{Stats: statsPB(ds)}
+	for _, f := range ds.Files {
+		pf := &agrevpb.File{
+			Name:         f.Name(),
+			OldName:      f.OldName,
+			NewName:      f.NewName,
+			IsNew:        f.IsNew,
+			IsDeleted:    f.IsDeleted,
+			IsRenamed:    f.IsRenamed,
+			IsBinary:     f.IsBinary,
+			AddedLines:   int32(f.AddedLines),
+			DeletedLines: int32(f.DeletedLines),
+		}
+		for i, frag := range f.Fragments {
+			pf.Hunks = append(pf.Hunks, hunkPB(i, frag))
+		}
+		out.Files = append(out.Files, pf)
+	}
+	return out
+}
+
+func hunkPB(index int, frag *gitdiff.TextFragment) *agrevpb.Hunk {
+	h := &agrevpb.Hunk{
+		Index:    int32(index),
+		Header:   diff.DisplayText(frag.Header()),
+		Section:  diff.DisplayText(frag.Comment),
+		OldStart: frag.OldPosition,
+		OldLines: frag.OldLines,
+		NewStart: frag.NewPosition,
+		NewLines: frag.NewLines,
+	}
+	oldNum, newNum := int32(frag.OldPosition), int32(frag.NewPosition)
+	for _, l := range frag.Lines {
+		// Proto strings must be UTF-8; other encodings are sent decoded
+		line := &agrevpb.Line{Text: diff.DisplayText(strings.TrimSuffix(l.Line, "\n"))}
+		switch l.Op {
+		case gitdiff.OpContext:
+			line.Op, line.OldNum, line.NewNum = agrevpb.LineOp_LINE_OP_CONTEXT, oldNum, newNum
+			oldNum++
+			newNum++
+		case gitdiff.OpDelete:
+			line.Op, line.OldNum = agrevpb.LineOp_LINE_OP_DELETE, oldNum
+			oldNum++
+		case gitdiff.OpAdd:
+			line.Op, line.NewNum = agrevpb.LineOp_LINE_OP_ADD, newNum
+			newNum++
+		}
+		h.Lines = append(h.Lines, line)
+	}
+	return h
+}
+
+func findingPB(f analysis.Finding) *agrevpb.Finding {
+	return &agrevpb.Finding{
+		Pass:     f.Pass,
+		File:     f.File,
+		Line:     int32(f.Line),
+		Message:  diff.DisplayText(f.Message),
+		Severity: agrevpb.Severity(f.Severity + 1),
+		Risk:     riskPB(f.Risk),
+	}
+}
+
+// riskPB maps a risk level to its enum value, which is offset by the
+// proto's UNSPECIFIED zero value.
+func riskPB(r model.RiskLevel) agrevpb.RiskLevel {
+	return agrevpb.RiskLevel(r + 1)
+}
+
+func decisionModel(d agrevpb.DecisionState) model.ReviewDecision {
+	switch d {
+	case agrevpb.DecisionState_DECISION_STATE_APPROVED:
+		return model.DecisionApproved
+	case agrevpb.DecisionState_DECISION_STATE_REJECTED:
+		return model.DecisionRejected
+	default:
+		return model.DecisionPending
+	}
+}
+
+func tracePB(t *trace.Trace) *agrevpb.Trace {
+	out := &agrevpb.Trace{
+		Source:       t.Source,
+		SessionId:    t.SessionID,
+		StartTime:    timestampPB(t.StartTime),
+		EndTime:      timestampPB(t.EndTime),
+		Summary:      t.Summary,
+		FilesChanged: t.FilesChanged,
+	}
+	for _, st := range t.Steps {
+		out.Steps = append(out.Steps, &agrevpb.Step{
+			Type:      agrevpb.StepType(st.Type + 1),
+			Timestamp: timestampPB(st.Timestamp),
+			Summary:   st.Summary,
+			Detail:    st.Detail,
+			FilePath:  st.FilePath,
+			OldString: st.OldString,
+			NewString: st.NewString,
+			Command:   st.Command,
+			ExitCode:  int32(st.ExitCode),
+			Output:    st.Output,
+			LineStart: int32(st.LineStart),
+			LineEnd:   int32(st.LineEnd),
+		})
+	}
+	return out
+}
+
+func timestampPB(t time.Time) *timestamppb.Timestamp {
+	if t.IsZero() {
+		return nil
+	}
+	return timestamppb.New(t)
+}
@@ -2,17 +2,27 @@ package api
 
 import (
 	"net/http"
+	"time"
 
 	"github.com/aezell/agrev/internal/analysis"
 	"github.com/aezell/agrev/internal/diff"
 	"github.com/aezell/agrev/internal/model"
 	"github.com/aezell/agrev/internal/trace"
+	"github.com/aezell/agrev/internal/triage"
 )
 
 // --- Health ---
 
+type healthResponse struct {
+	Status string `json:"status"`
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
 func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
-	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+	writeJSON(w, http.StatusOK, healthResponse{Status: "ok"})
 }
 
 // --- Analyze ---
@@ -24,26 +34,123 @@ type analyzeRequest struct {
 }
 
 type analyzeResponse struct {
-	Summary  string           `json:"summary"`
-	MaxRisk  string           `json:"max_risk"`
-	Total    int              `json:"total"`
-	Findings []findingJSON    `json:"findings"`
-	Stats    diffStatsJSON    `json:"stats"`
+	Summary  string        `json:"summary"`
+	MaxRisk  string        `json:"max_risk" enum:"info,low,medium,high,critical"`
+	Total    int           `json:"total"`
+	Findings []findingJSON `json:"findings"`
+	Stats    diffStatsJSON `json:"stats"`
+
+	// Suppressed are the findings reviewers marked as false positives, when
+	// repo_dir names a repository with triaged findings.
+	Suppressed []findingJSON `json:"suppressed,omitempty"`
 }
 
 type findingJSON struct {
 	Pass     string `json:"pass"`
+	Rule     string `json:"rule,omitempty"`     // e.g. "AGV-SEC-003", for built-in checks
+	DocsURL  string `json:"docs_url,omitempty"` // the rule's documentation
 	File     string `json:"file"`
 	Line     int    `json:"line,omitempty"`
+	Scope    string `json:"scope,omitempty"`
 	Message  string `json:"message"`
-	Severity string `json:"severity"`
-	Risk     string `json:"risk"`
+	Severity string `json:"severity" enum:"info,warning,error"`
+	Risk     string `json:"risk" enum:"info,low,medium,high,critical"`
+
+	// Fingerprint identifies the finding across runs, for triaging it.
+	Fingerprint string `json:"fingerprint"`
+	State       string `json:"state,omitempty" enum:"acknowledged,false_positive,fixed"` // as triaged by reviewers
+}
+
+func newFindingJSON(f analysis.Finding) findingJSON {
+	fj := findingJSON{
+		Pass:        f.Pass,
+		Rule:        f.Rule,
+		DocsURL:     f.DocsURL(),
+		File:        f.File,
+		Line:        f.Line,
+		Scope:       f.Scope,
+		Message:     f.Message,
+		Severity:    severityStr(f.Severity),
+		Risk:        f.Risk.String(),
+		Fingerprint: f.Fingerprint(),
+	}
+	if f.State != model.FindingOpen {
+		fj.State = f.State.String()
+	}
+	return fj
+}
+
+type commentJSON struct {
+	File      string `json:"file"`
+	Line      int    `json:"line,omitempty"`
+	HunkIndex *int   `json:"hunk_index,omitempty"` // 0-based, as in decisions; absent for file-level comments
+	Body      string `json:"body"`
+	Author    string `json:"author,omitempty"`
+	Time      string `json:"time,omitempty"` // RFC 3339
+}
+
+func commentsJSON(comments []model.Comment) []commentJSON {
+	var out []commentJSON
+	for _, c := range comments {
+		cj := commentJSON{File: c.File, Line: c.Line, Body: c.Body, Author: c.Author}
+		if c.Hunk > 0 {
+			h := c.Hunk - 1
+			cj.HunkIndex = &h
+		}
+		if !c.Time.IsZero() {
+			cj.Time = c.Time.UTC().Format(time.RFC3339)
+		}
+		out = append(out, cj)
+	}
+	return out
+}
+
+// newComment makes a comment on line of f, in the hunk holding it.
+func newComment(f *diff.File, line int, body, author string) model.Comment {
+	return model.Comment{
+		File:   f.Name(),
+		Line:   line,
+		Hunk:   f.HunkAt(line),
+		Body:   body,
+		Author: author,
+		Time:   time.Now(),
+	}
 }
 
 type diffStatsJSON struct {
 	Files   int `json:"files"`
 	Added   int `json:"added"`
 	Deleted int `json:"deleted"`
+
+	// Breakdowns by language and directory, most lines changed first
+	Languages   []groupStatsJSON `json:"languages,omitempty"`
+	Directories []groupStatsJSON `json:"directories,omitempty"`
+}
+
+type groupStatsJSON struct {
+	Name    string `json:"name"`
+	Files   int    `json:"files"`
+	Added   int    `json:"added"`
+	Deleted int    `json:"deleted"`
+}
+
+func newDiffStatsJSON(ds *diff.DiffSet) diffStatsJSON {
+	nFiles, added, deleted := ds.Stats()
+	return diffStatsJSON{
+		Files:       nFiles,
+		Added:       added,
+		Deleted:     deleted,
+		Languages:   groupStatsJSONs(ds.StatsByLanguage()),
+		Directories: groupStatsJSONs(ds.StatsByDir()),
+	}
+}
+
+func groupStatsJSONs(groups []diff.GroupStats) []groupStatsJSON {
+	var out []groupStatsJSON
+	for _, g := range groups {
+		out = append(out, groupStatsJSON{Name: g.Name, Files: g.Files, Added: g.Added, Deleted: g.Deleted})
+	}
+	return out
 }
 
 func (s *Server) handleAnalyze(w http.ResponseWriter, r *http.Request) {
@@ -64,32 +171,49 @@ func (s *Server) handleAnalyze(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	results := analysis.Run(ds, req.RepoDir, req.Skip)
+	results, err := analysis.RunContext(r.Context(), ds, req.RepoDir, req.Skip)
+	if err != nil {
+		s.logger(r.Context()).Info("analysis cancelled", "error", err)
+		writeError(w, http.StatusServiceUnavailable, "analysis cancelled")
+		return
+	}
+	if err := applyTriage(req.RepoDir, results); err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, newAnalyzeResponse(ds, results))
+}
 
-	nFiles, added, deleted := ds.Stats()
+// applyTriage suppresses or down-ranks the findings triaged in repoDir's
+// .agrev/findings.json. Without a repository there is nothing to apply.
+func applyTriage(repoDir string, results *analysis.Results) error {
+	if repoDir == "" {
+		return nil
+	}
+	store, err := triage.Load(repoDir)
+	if err != nil {
+		return err
+	}
+	store.Apply(results)
+	return nil
+}
+
+func newAnalyzeResponse(ds *diff.DiffSet, results *analysis.Results) analyzeResponse {
 	resp := analyzeResponse{
 		Summary: results.Summary(),
 		MaxRisk: results.MaxRisk().String(),
 		Total:   len(results.Findings),
-		Stats: diffStatsJSON{
-			Files:   nFiles,
-			Added:   added,
-			Deleted: deleted,
-		},
+		Stats:   newDiffStatsJSON(ds),
 	}
 
 	for _, f := range results.Findings {
-		resp.Findings = append(resp.Findings, findingJSON{
-			Pass:     f.Pass,
-			File:     f.File,
-			Line:     f.Line,
-			Message:  f.Message,
-			Severity: severityStr(f.Severity),
-			Risk:     f.Risk.String(),
-		})
+		resp.Findings = append(resp.Findings, newFindingJSON(f))
 	}
-
-	writeJSON(w, http.StatusOK, resp)
+	for _, f := range results.Suppressed {
+		resp.Suppressed = append(resp.Suppressed, newFindingJSON(f))
+	}
+	return resp
 }
 
 // --- Parse ---
@@ -101,18 +225,109 @@ type parseRequest struct {
 type parseResponse struct {
 	Files []fileJSON    `json:"files"`
 	Stats diffStatsJSON `json:"stats"`
+	Moves []moveJSON    `json:"moves"`
 }
 
 type fileJSON struct {
-	Name         string `json:"name"`
-	OldName      string `json:"old_name,omitempty"`
-	NewName      string `json:"new_name,omitempty"`
-	IsNew        bool   `json:"is_new,omitempty"`
-	IsDeleted    bool   `json:"is_deleted,omitempty"`
-	IsRenamed    bool   `json:"is_renamed,omitempty"`
-	AddedLines   int    `json:"added_lines"`
-	DeletedLines int    `json:"deleted_lines"`
-	Fragments    int    `json:"fragments"`
+	Name         string     `json:"name"`
+	OldName      string     `json:"old_name,omitempty"`
+	NewName      string     `json:"new_name,omitempty"`
+	IsNew        bool       `json:"is_new,omitempty"`
+	IsDeleted    bool       `json:"is_deleted,omitempty"`
+	IsRenamed    bool       `json:"is_renamed,omitempty"`
+	Similarity   int        `json:"similarity,omitempty"` // percent of lines a renamed file kept
+	Paired       bool       `json:"paired,omitempty"`     // renamed, though the diff showed a deletion and an addition
+	IsSubmodule  bool       `json:"is_submodule,omitempty"`
+	OldCommit    string     `json:"old_commit,omitempty"` // submodule commits before and after
+	NewCommit    string     `json:"new_commit,omitempty"`
+	Generated    string     `json:"generated,omitempty"` // one-line summary of a lockfile or other generated file
+	AddedLines   int        `json:"added_lines"`
+	DeletedLines int        `json:"deleted_lines"`
+	Fragments    int        `json:"fragments"`
+	Hunks        []hunkJSON `json:"hunks"`
+}
+
+// hunkJSON describes a hunk. Its index within the file identifies it in
+// hunk-level decisions.
+type hunkJSON struct {
+	Index    int    `json:"index"`
+	Header   string `json:"header"`
+	Section  string `json:"section,omitempty"` // enclosing function or heading, if git found one
+	OldStart int64  `json:"old_start"`
+	OldLines int64  `json:"old_lines"`
+	NewStart int64  `json:"new_start"`
+	NewLines int64  `json:"new_lines"`
+	Added    int64  `json:"added"`
+	Deleted  int64  `json:"deleted"`
+
+	// Splittable is set when the hunk has more than one run of changes, so
+	// a split message can break it up.
+	Splittable bool `json:"splittable,omitempty"`
+}
+
+// moveJSON is a block of code deleted from one file and added, unchanged
+// but for whitespace, to another. Line ranges are inclusive.
+type moveJSON struct {
+	FromFile  string `json:"from_file"`
+	FromStart int    `json:"from_start"` // in the old file
+	FromEnd   int    `json:"from_end"`
+	ToFile    string `json:"to_file"`
+	ToStart   int    `json:"to_start"` // in the new file
+	ToEnd     int    `json:"to_end"`
+}
+
+func movesJSON(ds *diff.DiffSet) []moveJSON {
+	moves := []moveJSON{}
+	for _, mv := range ds.Moves {
+		moves = append(moves, moveJSON{
+			FromFile:  mv.FromFile,
+			FromStart: mv.From.Start,
+			FromEnd:   mv.From.End,
+			ToFile:    mv.ToFile,
+			ToStart:   mv.To.Start,
+			ToEnd:     mv.To.End,
+		})
+	}
+	return moves
+}
+
+func newFileJSON(f *diff.File) fileJSON {
+	fj := fileJSON{
+		Name:         f.Name(),
+		OldName:      f.OldName,
+		NewName:      f.NewName,
+		IsNew:        f.IsNew,
+		IsDeleted:    f.IsDeleted,
+		IsRenamed:    f.IsRenamed,
+		Similarity:   f.Similarity,
+		Paired:       f.Paired,
+		IsSubmodule:  f.IsSubmodule,
+		OldCommit:    f.OldCommit,
+		NewCommit:    f.NewCommit,
+		AddedLines:   f.AddedLines,
+		DeletedLines: f.DeletedLines,
+		Fragments:    len(f.Fragments),
+		Hunks:        []hunkJSON{},
+	}
+	if f.Generated() {
+		fj.Generated = f.GeneratedSummary()
+	}
+	for i, frag := range f.Fragments {
+		fj.Hunks = append(fj.Hunks, hunkJSON{
+			Index:    i,
+			Header:   frag.Header(),
+			Section:  frag.Comment,
+			OldStart: frag.OldPosition,
+			OldLines: frag.OldLines,
+			NewStart: frag.NewPosition,
+			NewLines: frag.NewLines,
+			Added:    frag.LinesAdded,
+			Deleted:  frag.LinesDeleted,
+
+			Splittable: f.Combined == nil && len(diff.SplitFragment(frag)) > 1,
+		})
+	}
+	return fj
 }
 
 func (s *Server) handleParse(w http.ResponseWriter, r *http.Request) {
@@ -133,27 +348,13 @@ func (s *Server) handleParse(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	nFiles, added, deleted := ds.Stats()
 	resp := parseResponse{
-		Stats: diffStatsJSON{
-			Files:   nFiles,
-			Added:   added,
-			Deleted: deleted,
-		},
+		Stats: newDiffStatsJSON(ds),
+		Moves: movesJSON(ds),
 	}
 
 	for _, f := range ds.Files {
-		resp.Files = append(resp.Files, fileJSON{
-			Name:         f.Name(),
-			OldName:      f.OldName,
-			NewName:      f.NewName,
-			IsNew:        f.IsNew,
-			IsDeleted:    f.IsDeleted,
-			IsRenamed:    f.IsRenamed,
-			AddedLines:   f.AddedLines,
-			DeletedLines: f.DeletedLines,
-			Fragments:    len(f.Fragments),
-		})
+		resp.Files = append(resp.Files, newFileJSON(f))
 	}
 
 	writeJSON(w, http.StatusOK, resp)
@@ -162,7 +363,7 @@ func (s *Server) handleParse(w http.ResponseWriter, r *http.Request) {
 // --- Summary ---
 
 type summaryRequest struct {
-	TracePath string `json:"trace_path"`
+	TracePath string `json:"trace_path,omitempty"`
 	RepoDir   string `json:"repo_dir,omitempty"`
 }
 
@@ -0,0 +1,171 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/alecthomas/chroma/v2/styles"
+	"github.com/bluekeyes/go-gitdiff/gitdiff"
+	"github.com/aezell/agrev/internal/diff"
+)
+
+// defaultStyle is the chroma style used when a request doesn't name one,
+// matching the TUI's default dark theme.
+const defaultStyle = "dracula"
+
+type linesResponse struct {
+	SessionID string     `json:"session_id"`
+	FileIndex int        `json:"file_index"`
+	File      string     `json:"file"`
+	Style     string     `json:"style"`
+	Lines     []lineJSON `json:"lines"`
+}
+
+// lineJSON is one rendered diff line. Hunk headers come first in each hunk
+// with op "hunk"; the other lines carry their line numbers on the sides
+// they exist on.
+type lineJSON struct {
+	Op      string      `json:"op" enum:"hunk,context,add,delete"`
+	Hunk    int         `json:"hunk"`
+	OldNum  int         `json:"old_num,omitempty"`
+	NewNum  int         `json:"new_num,omitempty"`
+	Text    string      `json:"text"`
+	Tokens  []tokenJSON `json:"tokens,omitempty"`
+	Changed []spanJSON  `json:"changed,omitempty"` // word-level changes against the paired add/delete line
+	NoEOL   bool        `json:"no_eol,omitempty"`  // no newline at end of file
+	CRLF    bool        `json:"crlf,omitempty"`    // ends in CR LF, with the CR left out of text
+	Moved   bool        `json:"moved,omitempty"`   // part of a block moved to or from another file
+}
+
+type tokenJSON struct {
+	Text  string `json:"text"`
+	Color string `json:"color,omitempty"` // "#rrggbb"; empty for the default foreground
+}
+
+// spanJSON is a byte range of Text.
+type spanJSON struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
+}
+
+func (s *Server) handleSessionLines(w http.ResponseWriter, r *http.Request) {
+	style := r.URL.Query().Get("style")
+	if style == "" {
+		style = defaultStyle
+	}
+	if _, ok := styles.Registry[style]; !ok {
+		writeError(w, http.StatusBadRequest, "unknown style: "+style)
+		return
+	}
+
+	s.withSession(w, r, func(session *reviewSession) {
+		index, err := strconv.Atoi(r.PathValue("index"))
+		if err != nil || index < 0 || index >= len(session.review.Diff.Files) {
+			writeError(w, http.StatusNotFound, "file index out of range")
+			return
+		}
+		f := session.review.Diff.Files[index]
+		writeJSON(w, http.StatusOK, linesResponse{
+			SessionID: session.id,
+			FileIndex: index,
+			File:      f.Name(),
+			Style:     style,
+			Lines:     renderLines(session.review.Diff, f, style),
+		})
+	})
+}
+
+// renderLines lays out a file of ds as display lines with syntax tokens,
+// word-level changes, and moved code marked, as the TUI draws them.
+func renderLines(ds *diff.DiffSet, f *diff.File, style string) []lineJSON {
+	lines := []lineJSON{}
+	var code []int // indexes of non-header lines, for highlighting
+	for h, frag := range f.Fragments {
+		lines = append(lines, lineJSON{Op: "hunk", Hunk: h, Text: frag.Header()})
+
+		oldNum, newNum := int(frag.OldPosition), int(frag.NewPosition)
+		// An empty side's position names the line before the hunk
+		if frag.OldLines == 0 {
+			oldNum++
+		}
+		if frag.NewLines == 0 {
+			newNum++
+		}
+
+		start := len(lines)
+		for _, l := range frag.Lines {
+			line := lineJSON{
+				Hunk:  h,
+				Text:  diff.DisplayText(strings.TrimSuffix(strings.TrimSuffix(l.Line, "\n"), "\r")),
+				NoEOL: !strings.HasSuffix(l.Line, "\n"),
+				CRLF:  strings.HasSuffix(l.Line, "\r\n"),
+			}
+			switch l.Op {
+			case gitdiff.OpContext:
+				line.Op, line.OldNum, line.NewNum = "context", oldNum, newNum
+				oldNum++
+				newNum++
+			case gitdiff.OpDelete:
+				line.Op, line.OldNum = "delete", oldNum
+				_, line.Moved = ds.MoveAt(f, oldNum, true)
+				oldNum++
+			case gitdiff.OpAdd:
+				line.Op, line.NewNum = "add", newNum
+				_, line.Moved = ds.MoveAt(f, newNum, false)
+				newNum++
+			}
+			lines = append(lines, line)
+			code = append(code, len(lines)-1)
+		}
+		markChanged(lines[start:])
+	}
+
+	text := make([]string, len(code))
+	for i, idx := range code {
+		text[i] = lines[idx].Text
+	}
+	for i, hl := range diff.HighlightLinesStyle(f.Name(), style, text) {
+		if i >= len(code) {
+			break
+		}
+		for _, t := range hl.Tokens {
+			if t.Text != "" {
+				lines[code[i]].Tokens = append(lines[code[i]].Tokens, tokenJSON{Text: t.Text, Color: t.Color})
+			}
+		}
+	}
+	return lines
+}
+
+// markChanged pairs each run of deleted lines with the added lines that
+// follow it and records the word-level changes between them.
+func markChanged(lines []lineJSON) {
+	for i := 0; i < len(lines); {
+		if lines[i].Op != "delete" {
+			i++
+			continue
+		}
+		delStart := i
+		for i < len(lines) && lines[i].Op == "delete" {
+			i++
+		}
+		addStart := i
+		for i < len(lines) && lines[i].Op == "add" {
+			i++
+		}
+		for p := 0; p < min(addStart-delStart, i-addStart); p++ {
+			del, add := &lines[delStart+p], &lines[addStart+p]
+			oldSpans, newSpans := diff.IntralineDiff(del.Text, add.Text)
+			del.Changed, add.Changed = spansJSON(oldSpans), spansJSON(newSpans)
+		}
+	}
+}
+
+func spansJSON(spans []diff.Span) []spanJSON {
+	var out []spanJSON
+	for _, sp := range spans {
+		out = append(out, spanJSON{Start: sp.Start, End: sp.End})
+	}
+	return out
+}
@@ -0,0 +1,287 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"path"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// handleOpenAPI serves the OpenAPI 3 document describing the API.
+func (s *Server) handleOpenAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(s.spec)
+}
+
+// openAPISpec builds the OpenAPI document from the route table and the
+// request and response structs, so it can't drift from the handlers.
+// OpenAPI has no way to describe WebSocket messages, so they are listed as
+// the WsClientMessage and WsServerMessage schemas and referenced from the
+// /api/ws operation's x-websocket extension.
+func openAPISpec(version string) []byte {
+	if version == "" {
+		version = "dev"
+	}
+	g := &schemaGen{schemas: map[string]any{}}
+
+	paths := map[string]any{}
+	for _, rt := range routes() {
+		op := map[string]any{
+			"summary":     rt.summary,
+			"operationId": operationID(rt.path),
+		}
+		params := pathParams(rt.path)
+		for _, name := range sortedKeys(rt.query) {
+			params = append(params, map[string]any{
+				"name":        name,
+				"in":          "query",
+				"description": rt.query[name],
+				"schema":      map[string]any{"type": "string"},
+			})
+		}
+		if len(params) > 0 {
+			op["parameters"] = params
+		}
+		if rt.request != nil {
+			op["requestBody"] = map[string]any{
+				"required": true,
+				"content":  map[string]any{"application/json": map[string]any{"schema": g.schema(reflect.TypeOf(rt.request))}},
+			}
+		}
+
+		responses := map[string]any{}
+		switch {
+		case rt.path == "/api/openapi.json":
+			responses["200"] = map[string]any{
+				"description": "OpenAPI 3 document",
+				"content":     map[string]any{"application/json": map[string]any{"schema": map[string]any{"type": "object"}}},
+			}
+		case rt.path == "/api/ws":
+			responses["101"] = map[string]any{"description": "Switching to the WebSocket protocol"}
+			op["x-websocket"] = map[string]any{
+				"client": map[string]any{"$ref": "#/components/schemas/WsClientMessage"},
+				"server": map[string]any{"$ref": "#/components/schemas/WsServerMessage"},
+			}
+		default:
+			responses["200"] = map[string]any{
+				"description": "OK",
+				"content":     map[string]any{"application/json": map[string]any{"schema": g.schema(reflect.TypeOf(rt.reply))}},
+			}
+		}
+		errResp := func(desc string) map[string]any {
+			return map[string]any{
+				"description": desc,
+				"content":     map[string]any{"application/json": map[string]any{"schema": g.schema(reflect.TypeOf(errorResponse{}))}},
+			}
+		}
+		if rt.request != nil {
+			responses["400"] = errResp("Invalid request")
+		}
+		if strings.Contains(rt.path, "{id}") {
+			responses["404"] = errResp("No such session")
+			responses["409"] = errResp("No diff loaded in the session")
+		}
+		if !rt.public {
+			responses["401"] = errResp("Missing or invalid bearer token")
+			responses["403"] = errResp("Token lacks the required scope")
+			op["security"] = []any{map[string]any{"bearer": []string{}}}
+			op["x-agrev-scope"] = rt.scope.String()
+		}
+		op["responses"] = responses
+
+		item, _ := paths[rt.path].(map[string]any)
+		if item == nil {
+			item = map[string]any{}
+			paths[rt.path] = item
+		}
+		item[strings.ToLower(rt.method)] = op
+	}
+
+	g.schemas["WsClientMessage"] = g.wsMessages(wsClientMessages)
+	g.schemas["WsServerMessage"] = g.wsMessages(wsServerMessages)
+
+	doc := map[string]any{
+		"openapi": "3.0.3",
+		"info": map[string]any{
+			"title":       "agrev API",
+			"version":     version,
+			"description": "Analysis engine and interactive review sessions for agent-generated changes.",
+		},
+		"paths": paths,
+		"components": map[string]any{
+			"schemas": g.schemas,
+			"securitySchemes": map[string]any{
+				"bearer": map[string]any{"type": "http", "scheme": "bearer"},
+			},
+		},
+	}
+	data, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		panic("api: building OpenAPI document: " + err.Error())
+	}
+	return data
+}
+
+// operationID turns "/api/analyze" into "analyze" and
+// "/api/sessions/{id}/patch" into "sessions_id_patch".
+func operationID(path string) string {
+	path = strings.TrimPrefix(strings.TrimPrefix(path, "/api"), "/")
+	return strings.NewReplacer(".", "_", "/", "_", "-", "_", "{", "", "}", "").Replace(path)
+}
+
+// pathParams describes the {name} wildcards in a route path.
+func pathParams(path string) []any {
+	var params []any
+	for _, seg := range strings.Split(path, "/") {
+		if strings.HasPrefix(seg, "{") && strings.HasSuffix(seg, "}") {
+			name, typ := strings.Trim(seg, "{}"), "string"
+			if name == "index" {
+				typ = "integer"
+			}
+			params = append(params, map[string]any{
+				"name":     name,
+				"in":       "path",
+				"required": true,
+				"schema":   map[string]any{"type": typ},
+			})
+		}
+	}
+	return params
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// schemaGen converts Go types to JSON Schema, collecting named structs as
+// components.
+type schemaGen struct {
+	schemas map[string]any
+}
+
+func (g *schemaGen) schema(t reflect.Type) map[string]any {
+	if t == reflect.TypeOf(time.Time{}) {
+		return map[string]any{"type": "string", "format": "date-time"}
+	}
+	switch t.Kind() {
+	case reflect.Pointer:
+		return g.schema(t.Elem())
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if t == reflect.TypeOf(json.RawMessage{}) {
+			return map[string]any{}
+		}
+		return map[string]any{"type": "array", "items": g.schema(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": g.schema(t.Elem())}
+	case reflect.Struct:
+		name := schemaName(t)
+		if _, ok := g.schemas[name]; !ok {
+			g.schemas[name] = nil // reserve the name against recursion
+			g.schemas[name] = g.object(t)
+		}
+		return map[string]any{"$ref": "#/components/schemas/" + name}
+	}
+	return map[string]any{}
+}
+
+func (g *schemaGen) object(t reflect.Type) map[string]any {
+	props := map[string]any{}
+	var required []string
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if f.Anonymous && tag == "" && f.Type.Kind() == reflect.Struct {
+			// Embedded fields are marshaled inline
+			embedded := g.object(f.Type)
+			for name, prop := range embedded["properties"].(map[string]any) {
+				props[name] = prop
+			}
+			if req, ok := embedded["required"].([]string); ok {
+				required = append(required, req...)
+			}
+			continue
+		}
+		if !f.IsExported() || tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if name == "" {
+			name = f.Name
+		}
+		prop := g.schema(f.Type)
+		if enum := f.Tag.Get("enum"); enum != "" {
+			prop["enum"] = strings.Split(enum, ",")
+		}
+		props[name] = prop
+		if !strings.Contains(opts, "omitempty") {
+			required = append(required, name)
+		}
+	}
+	obj := map[string]any{"type": "object", "properties": props}
+	if len(required) > 0 {
+		obj["required"] = required
+	}
+	return obj
+}
+
+// wsMessages describes a set of WebSocket messages as a oneOf over
+// envelopes whose type is fixed to each message type.
+func (g *schemaGen) wsMessages(msgs map[string]any) map[string]any {
+	types := make([]string, 0, len(msgs))
+	for typ := range msgs {
+		types = append(types, typ)
+	}
+	sort.Strings(types)
+
+	var variants []any
+	for _, typ := range types {
+		props := map[string]any{"type": map[string]any{"type": "string", "enum": []string{typ}}}
+		required := []string{"type"}
+		if payload := msgs[typ]; payload != nil {
+			props["data"] = g.schema(reflect.TypeOf(payload))
+			required = append(required, "data")
+		}
+		variants = append(variants, map[string]any{
+			"type":       "object",
+			"title":      typ,
+			"properties": props,
+			"required":   required,
+		})
+	}
+	return map[string]any{"oneOf": variants}
+}
+
+// schemaName turns a Go type name like findingJSON or wsLoadDiff into a
+// component name like Finding or WsLoadDiff. Types from other packages are
+// prefixed with the package's name, so session.File becomes SessionFile
+// rather than clashing with fileJSON.
+func schemaName(t reflect.Type) string {
+	name := strings.TrimSuffix(t.Name(), "JSON")
+	if name == "" {
+		return t.Name()
+	}
+	if pkg := path.Base(t.PkgPath()); pkg != "api" && !strings.HasPrefix(strings.ToLower(name), pkg) {
+		name = pkg + name
+	}
+	r := []rune(name)
+	r[0] = unicode.ToUpper(r[0])
+	return string(r)
+}
@@ -0,0 +1,222 @@
+package api
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	savedsession "github.com/aezell/agrev/internal/session"
+	"github.com/aezell/agrev/internal/tui"
+)
+
+// sessionTTL is how long a review session stays available to the REST
+// endpoints and to rejoin after its last WebSocket connection closes.
+const sessionTTL = time.Hour
+
+// sessionRegistry tracks review sessions by ID so REST endpoints can read
+// the state a WebSocket connection built up.
+type sessionRegistry struct {
+	mu       sync.Mutex
+	sessions map[string]*reviewSession
+}
+
+func newSessionRegistry() *sessionRegistry {
+	return &sessionRegistry{sessions: make(map[string]*reviewSession)}
+}
+
+// create registers a new session, dropping sessions whose last
+// connection closed more than sessionTTL ago.
+func (r *sessionRegistry) create() *reviewSession {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	for id, s := range r.sessions {
+		s.mu.Lock()
+		expired := len(s.participants) == 0 && now.Sub(s.lastUsed) > sessionTTL
+		s.mu.Unlock()
+		if expired {
+			delete(r.sessions, id)
+		}
+	}
+
+	s := &reviewSession{
+		id:       newSessionID(),
+		created:  now,
+		lastUsed: now,
+	}
+	r.sessions[s.id] = s
+	return s
+}
+
+func (r *sessionRegistry) get(id string) *reviewSession {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.sessions[id]
+}
+
+// join adds a reviewer's connection to the session and tells everyone.
+// The newcomer is brought up to date with the diff, analysis, decisions,
+// and comments so far.
+func (s *reviewSession) join(conn *wsConn, reviewer string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.joins++
+	if reviewer == "" {
+		reviewer = fmt.Sprintf("reviewer %d", s.joins)
+	}
+	s.participants = append(s.participants, participant{conn: conn, reviewer: reviewer})
+	s.lastUsed = time.Now()
+
+	sendWSMessage(conn, wsMsgJoined, wsJoinedResponse{SessionID: s.id, Reviewer: reviewer, Participants: s.reviewers()})
+	if s.review != nil {
+		sendWSMessage(conn, wsMsgParsed, s.parsedResponse())
+		if s.review.Results != nil {
+			sendWSMessage(conn, wsMsgAnalysis, newAnalysisResponse(s.review.Results))
+		}
+		sendWSMessage(conn, wsMsgState, s.stateResponse())
+	}
+	s.broadcast(wsMsgParticipants, wsParticipantsResponse{Participants: s.reviewers(), Joined: reviewer})
+}
+
+// leave removes a connection from the session, starting its TTL when it
+// was the last one.
+func (s *reviewSession) leave(conn *wsConn) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	reviewer := s.reviewer(conn)
+	s.participants = slices.DeleteFunc(s.participants, func(p participant) bool { return p.conn == conn })
+	s.lastUsed = time.Now()
+	s.broadcast(wsMsgParticipants, wsParticipantsResponse{Participants: s.reviewers(), Left: reviewer})
+}
+
+// reviewer is the name conn joined under.
+func (s *reviewSession) reviewer(conn *wsConn) string {
+	for _, p := range s.participants {
+		if p.conn == conn {
+			return p.reviewer
+		}
+	}
+	return ""
+}
+
+// reviewers lists the connected reviewers in the order they joined.
+func (s *reviewSession) reviewers() []string {
+	names := []string{}
+	for _, p := range s.participants {
+		names = append(names, p.reviewer)
+	}
+	return names
+}
+
+// broadcast sends a message to every connection in the session.
+func (s *reviewSession) broadcast(msgType string, data any) {
+	for _, p := range s.participants {
+		sendWSMessage(p.conn, msgType, data)
+	}
+}
+
+func newSessionID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// result converts the session's decisions into a tui.ReviewResult, so the
+// patch and commit message match what 'agrev review' produces.
+func (s *reviewSession) result() *tui.ReviewResult {
+	return &tui.ReviewResult{
+		Decisions: s.review.Decisions.Clone(),
+		Files:     s.review.Diff.Files,
+		Comments:  s.review.Comments,
+	}
+}
+
+type patchResponse struct {
+	SessionID string   `json:"session_id"`
+	Patch     string   `json:"patch"` // empty when nothing was approved
+	Files     []string `json:"files"` // files in the patch
+}
+
+type commitMessageResponse struct {
+	SessionID string `json:"session_id"`
+	Subject   string `json:"subject"`
+	Message   string `json:"message"` // empty when nothing was approved
+}
+
+func (s *Server) handleSessionPatch(w http.ResponseWriter, r *http.Request) {
+	s.withSession(w, r, func(session *reviewSession) {
+		result := session.result()
+		resp := patchResponse{SessionID: session.id, Patch: result.GeneratePatch(), Files: []string{}}
+		for _, f := range result.ApprovedFiles() {
+			if !f.IsBinary {
+				resp.Files = append(resp.Files, f.Name())
+			}
+		}
+		writeJSON(w, http.StatusOK, resp)
+	})
+}
+
+func (s *Server) handleSessionCommitMessage(w http.ResponseWriter, r *http.Request) {
+	s.withSession(w, r, func(session *reviewSession) {
+		msg := session.result().GenerateCommitMessage()
+		subject, _, _ := strings.Cut(msg, "\n")
+		writeJSON(w, http.StatusOK, commitMessageResponse{SessionID: session.id, Subject: subject, Message: msg})
+	})
+}
+
+// handleSessionExport gives the session's state in the format agrev saves
+// to .agrev/session.json, so a review started here can be resumed in the
+// TUI and vice versa.
+func (s *Server) handleSessionExport(w http.ResponseWriter, r *http.Request) {
+	s.withSession(w, r, func(session *reviewSession) {
+		saved := savedsession.New(session.review.Diff)
+		saved.Created = session.created
+		saved.Record(session.review)
+		writeJSON(w, http.StatusOK, saved)
+	})
+}
+
+// handleSessionImport restores decisions and comments from a saved session
+// onto the files of the session's diff they were made on, replacing the
+// session's own, and tells everyone in it.
+func (s *Server) handleSessionImport(w http.ResponseWriter, r *http.Request) {
+	var saved savedsession.Session
+	if err := readJSON(r, &saved); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request: "+err.Error())
+		return
+	}
+	if err := saved.Validate(); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	s.withSession(w, r, func(session *reviewSession) {
+		saved.Restore(session.review)
+		state := session.stateResponse()
+		session.broadcast(wsMsgState, state)
+		writeJSON(w, http.StatusOK, state)
+	})
+}
+
+// withSession runs fn with the session named in the request path locked,
+// or writes an error if there is no such session or it has no diff yet.
+func (s *Server) withSession(w http.ResponseWriter, r *http.Request, fn func(*reviewSession)) {
+	session := s.sessions.get(r.PathValue("id"))
+	if session == nil {
+		writeError(w, http.StatusNotFound, "no such session")
+		return
+	}
+	session.mu.Lock()
+	defer session.mu.Unlock()
+	if session.review == nil {
+		writeError(w, http.StatusConflict, "no diff loaded in this session")
+		return
+	}
+	session.lastUsed = time.Now()
+	fn(session)
+}
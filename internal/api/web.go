@@ -0,0 +1,131 @@
+package api
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+	"time"
+
+	"github.com/aezell/agrev/internal/annotate"
+	"github.com/aezell/agrev/internal/diff"
+	"github.com/aezell/agrev/internal/model"
+	"github.com/aezell/agrev/internal/trace"
+)
+
+// webFS holds the browser review UI served by 'agrev serve --web'. It is
+// plain HTML, CSS, and JavaScript speaking the WebSocket protocol, so there
+// is no build step.
+//
+//go:embed web
+var webFS embed.FS
+
+// Source is the change the web UI reviews.
+type Source struct {
+	Label   string // e.g. "HEAD~3..HEAD"; empty for the working tree
+	Diff    string
+	RepoDir string
+	Trace   *trace.Trace // nil without a trace
+}
+
+type sourceResponse struct {
+	Label   string     `json:"label,omitempty"`
+	Diff    string     `json:"diff"`
+	RepoDir string     `json:"repo_dir,omitempty"`
+	Trace   *traceJSON `json:"trace,omitempty"`
+
+	// Notes from the trace on the files' lines, by file name
+	Annotations map[string][]annotationJSON `json:"annotations,omitempty"`
+}
+
+type annotationJSON struct {
+	Type    string `json:"type" enum:"warning,info,trace_link,risk"`
+	Start   int    `json:"start,omitempty"` // 0 for the whole file
+	End     int    `json:"end,omitempty"`
+	Message string `json:"message"`
+	Step    int    `json:"step,omitempty"`
+}
+
+type traceJSON struct {
+	Source    string     `json:"source"`
+	SessionID string     `json:"session_id,omitempty"`
+	Summary   string     `json:"summary,omitempty"`
+	Steps     []stepJSON `json:"steps"`
+}
+
+type stepJSON struct {
+	Type      string `json:"type" enum:"plan,reasoning,read,write,edit,bash,result,user"`
+	Timestamp string `json:"timestamp,omitempty"`
+	Summary   string `json:"summary"`
+	Detail    string `json:"detail,omitempty"`
+	File      string `json:"file,omitempty"`
+	OldString string `json:"old_string,omitempty"`
+	NewString string `json:"new_string,omitempty"`
+	Command   string `json:"command,omitempty"`
+	ExitCode  int    `json:"exit_code,omitempty"`
+	Output    string `json:"output,omitempty"`
+}
+
+func newTraceJSON(t *trace.Trace) *traceJSON {
+	out := &traceJSON{Source: t.Source, SessionID: t.SessionID, Summary: t.Summary, Steps: []stepJSON{}}
+	for _, s := range t.Steps {
+		step := stepJSON{
+			Type:      s.Type.String(),
+			Summary:   s.Summary,
+			File:      s.FilePath,
+			OldString: s.OldString,
+			NewString: s.NewString,
+			Command:   s.Command,
+			ExitCode:  s.ExitCode,
+			Output:    s.Output,
+		}
+		if s.Detail != s.Summary {
+			step.Detail = s.Detail
+		}
+		if !s.Timestamp.IsZero() {
+			step.Timestamp = s.Timestamp.Format(time.RFC3339)
+		}
+		out.Steps = append(out.Steps, step)
+	}
+	return out
+}
+
+func newAnnotationsJSON(byFile map[string][]model.Annotation) map[string][]annotationJSON {
+	out := make(map[string][]annotationJSON, len(byFile))
+	for name, anns := range byFile {
+		for _, a := range anns {
+			out[name] = append(out[name], annotationJSON{
+				Type:    a.Type.String(),
+				Start:   a.Range.Start,
+				End:     a.Range.End,
+				Message: a.Message,
+				Step:    a.Step,
+			})
+		}
+	}
+	return out
+}
+
+func (s *Server) handleSource(w http.ResponseWriter, r *http.Request) {
+	src, err := s.source()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	resp := sourceResponse{Label: src.Label, Diff: src.Diff, RepoDir: src.RepoDir}
+	if src.Trace != nil {
+		resp.Trace = newTraceJSON(src.Trace)
+		if ds, err := diff.Parse(src.Diff); err == nil {
+			resp.Annotations = newAnnotationsJSON(annotate.Build(ds, src.Trace))
+		}
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// webHandler serves the embedded UI.
+func webHandler() http.Handler {
+	sub, err := fs.Sub(webFS, "web")
+	if err != nil {
+		panic("api: embedded web UI: " + err.Error())
+	}
+	return http.FileServerFS(sub)
+}
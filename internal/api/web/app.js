@@ -0,0 +1,650 @@
+// agrev web review UI. It loads the change from /api/source and reviews it
+// over the /api/ws protocol, the same one editor plugins use.
+"use strict";
+
+const RISKS = ["info", "low", "medium", "high", "critical"];
+const MARKS = { approved: "✓", rejected: "✗", partial: "◐", pending: "·" };
+
+const state = {
+  token: "",
+  source: null,
+  ws: null,
+  session: "",    // session ID, from ?session= when joining a shared review
+  reviewer: "",
+  files: [],      // from the "parsed" message
+  diffs: [],      // parsed hunks per file, in the same order
+  moves: [],      // blocks moved between files, from the "parsed" message
+  findings: [],
+  decisions: {},  // file index -> "approved" | "rejected"
+  hunks: {},      // "file:hunk" -> decision, where it differs from the file's
+  comments: [],
+  current: 0,
+  traceAll: false,
+  showGenerated: {}, // file index -> lockfile or generated file expanded
+};
+
+const $ = (id) => document.getElementById(id);
+
+// el builds an element. Text is always set with textContent, never HTML,
+// since diffs and traces are untrusted.
+function el(tag, attrs, ...children) {
+  const node = document.createElement(tag);
+  for (const [k, v] of Object.entries(attrs || {})) {
+    if (k === "class") node.className = v;
+    else if (k.startsWith("on")) node.addEventListener(k.slice(2), v);
+    else node.setAttribute(k, v);
+  }
+  for (const c of children) {
+    if (c == null) continue;
+    node.append(typeof c === "string" || typeof c === "number" ? String(c) : c);
+  }
+  return node;
+}
+
+function setStatus(text, error) {
+  $("status").textContent = text;
+  $("status").classList.toggle("error", !!error);
+}
+
+// --- Loading ---
+
+function initToken() {
+  const params = new URLSearchParams(location.search);
+  if (params.has("token")) {
+    sessionStorage.setItem("agrev-token", params.get("token"));
+    params.delete("token");
+    const qs = params.toString();
+    history.replaceState(null, "", location.pathname + (qs ? "?" + qs : ""));
+  }
+  state.token = sessionStorage.getItem("agrev-token") || "";
+  state.session = params.get("session") || "";
+  if (params.has("reviewer")) localStorage.setItem("agrev-reviewer", params.get("reviewer"));
+  state.reviewer = localStorage.getItem("agrev-reviewer") || "";
+}
+
+async function loadSource() {
+  const headers = state.token ? { Authorization: "Bearer " + state.token } : {};
+  const res = await fetch("api/source", { headers });
+  if (res.status === 401 || res.status === 403) {
+    const token = prompt("This agrev server requires an API token:");
+    if (!token) throw new Error("no API token");
+    state.token = token;
+    sessionStorage.setItem("agrev-token", token);
+    return loadSource();
+  }
+  const body = await res.json();
+  if (!res.ok) throw new Error(body.error || res.statusText);
+  return body;
+}
+
+function connect() {
+  const proto = location.protocol === "https:" ? "wss:" : "ws:";
+  const params = new URLSearchParams();
+  if (state.token) params.set("access_token", state.token);
+  if (state.session) params.set("session", state.session);
+  if (state.reviewer) params.set("reviewer", state.reviewer);
+  const url = `${proto}//${location.host}${location.pathname.replace(/[^/]*$/, "")}api/ws?${params}`;
+
+  const ws = new WebSocket(url);
+  state.ws = ws;
+  let opened = false;
+  ws.onopen = () => {
+    opened = true;
+    // Joining a shared session brings its diff and decisions with it.
+    if (state.session) return;
+    setStatus("analyzing…");
+    send("load_diff", { diff: state.source.diff, repo_dir: state.source.repo_dir });
+  };
+  ws.onmessage = (e) => receive(JSON.parse(e.data));
+  ws.onclose = () => setStatus(opened ? "disconnected" : "could not join the session; it may have expired", true);
+}
+
+// share offers a link that joins this review from another browser.
+function share() {
+  if (!state.session) return;
+  const url = new URL(location.href);
+  url.search = "?session=" + encodeURIComponent(state.session);
+  prompt("Share this link to review together (add &token=… if the server needs one):", url.toString());
+}
+
+function send(type, data) {
+  if (!state.ws || state.ws.readyState !== WebSocket.OPEN) {
+    setStatus("not connected", true);
+    return;
+  }
+  state.ws.send(JSON.stringify(data === undefined ? { type } : { type, data }));
+}
+
+function receive(msg) {
+  const d = msg.data;
+  switch (msg.type) {
+    case "joined":
+      state.session = d.session_id;
+      state.reviewer = d.reviewer;
+      $("share").disabled = false;
+      $("participants").textContent = d.participants.join(", ");
+      break;
+    case "participants":
+      $("participants").textContent = d.participants.join(", ");
+      if (d.joined && d.joined !== state.reviewer) setStatus(d.joined + " joined");
+      if (d.left) setStatus(d.left + " left");
+      break;
+    case "state":
+      state.decisions = {};
+      state.hunks = {};
+      d.files.forEach((f, i) => {
+        if (!f.hunks) {
+          if (f.decision !== "pending") state.decisions[i] = f.decision;
+          return;
+        }
+        f.hunks.forEach((h, j) => { if (h !== "pending") state.hunks[i + ":" + j] = h; });
+      });
+      state.comments = d.comments || [];
+      render();
+      break;
+    case "parsed":
+      // A new diff starts the review over, whoever in the session loaded it.
+      state.files = d.files || [];
+      state.moves = d.moves || [];
+      state.decisions = {};
+      state.hunks = {};
+      state.comments = [];
+      $("stats").textContent = `${d.stats.files} files, +${d.stats.added} -${d.stats.deleted}`;
+      // Hunks split before we joined differ from the ones in the source.
+      state.files.forEach((f, i) => {
+        const rows = state.diffs[i] || [];
+        if (f.hunks.length !== rows.filter((r) => r.kind === "hunk").length) loadRows(i);
+      });
+      render();
+      break;
+    case "analysis":
+      state.findings = d.findings || [];
+      $("risk").textContent = d.total ? `${d.max_risk} risk · ${d.total} findings` : "";
+      $("risk").className = "badge risk-" + d.max_risk;
+      setStatus("ready");
+      render();
+      break;
+    case "decision":
+      if (d.hunk_index != null) {
+        const key = d.file_index + ":" + d.hunk_index;
+        if (d.decision === (state.decisions[d.file_index] || "pending")) delete state.hunks[key];
+        else state.hunks[key] = d.decision;
+      } else {
+        if (d.decision === "pending") delete state.decisions[d.file_index];
+        else state.decisions[d.file_index] = d.decision;
+        // A file decision replaces its hunks' decisions, as on the server.
+        for (const key of Object.keys(state.hunks)) {
+          if (key.startsWith(d.file_index + ":")) delete state.hunks[key];
+        }
+      }
+      if (d.reviewer && d.reviewer !== state.reviewer) {
+        const f = state.files[d.file_index];
+        setStatus(`${d.reviewer}: ${d.decision} ${f ? f.name : ""}${d.note ? " — " + d.note : ""}`);
+      }
+      render();
+      break;
+    case "hunk_split": {
+      state.files[d.file_index] = d.file;
+      // Later hunks move up; the pieces keep the split hunk's decision.
+      const hunks = {};
+      for (const [key, v] of Object.entries(state.hunks)) {
+        const [fi, h] = key.split(":").map(Number);
+        if (fi !== d.file_index || h < d.hunk_index) hunks[key] = v;
+        else if (h > d.hunk_index) hunks[fi + ":" + (h + d.hunks - 1)] = v;
+        else for (let p = 0; p < d.hunks; p++) hunks[fi + ":" + (h + p)] = v;
+      }
+      state.hunks = hunks;
+      loadRows(d.file_index);
+      break;
+    }
+    case "comments":
+      state.comments = d || [];
+      render();
+      break;
+    case "summary":
+      showSummary(d);
+      break;
+    case "error":
+      setStatus(d.message, true);
+      break;
+  }
+}
+
+// --- Diff parsing ---
+
+// splitDiff splits a unified diff into per-file line arrays, in the order
+// the server's parser reports files.
+function splitDiff(text) {
+  const lines = text.replace(/\n$/, "").split("\n");
+  const git = lines.some((l) => l.startsWith("diff --git "));
+  const chunks = [];
+  let cur = null;
+  let oldLeft = 0, newLeft = 0; // lines left in the current hunk
+
+  for (let i = 0; i < lines.length; i++) {
+    const l = lines[i];
+    const inHunk = oldLeft > 0 || newLeft > 0;
+    const start = git
+      ? l.startsWith("diff --git ") && !inHunk
+      : l.startsWith("--- ") && !inHunk && (lines[i + 1] || "").startsWith("+++ ");
+    if (start) {
+      cur = [];
+      chunks.push(cur);
+    }
+    if (!cur) continue;
+    cur.push(l);
+
+    const m = !inHunk && l.match(/^@@ -\d+(?:,(\d+))? \+\d+(?:,(\d+))? @@/);
+    if (m) {
+      oldLeft = m[1] === undefined ? 1 : +m[1];
+      newLeft = m[2] === undefined ? 1 : +m[2];
+    } else if (inHunk) {
+      if (l.startsWith("-")) oldLeft--;
+      else if (l.startsWith("+")) newLeft--;
+      else if (!l.startsWith("\\")) { oldLeft--; newLeft--; }
+    }
+  }
+  return chunks.map(parseFileDiff);
+}
+
+// parseFileDiff turns one file's diff lines into display rows.
+function parseFileDiff(lines) {
+  const rows = [];
+  let oldNo = 0, newNo = 0, oldLeft = 0, newLeft = 0;
+  for (const l of lines) {
+    const inHunk = oldLeft > 0 || newLeft > 0;
+    const m = !inHunk && l.match(/^@@ -(\d+)(?:,(\d+))? \+(\d+)(?:,(\d+))? @@/);
+    if (m) {
+      oldNo = +m[1];
+      newNo = +m[3];
+      oldLeft = m[2] === undefined ? 1 : +m[2];
+      newLeft = m[4] === undefined ? 1 : +m[4];
+      rows.push({ kind: "hunk", text: l, hunk: rows.filter((r) => r.kind === "hunk").length });
+    } else if (l.startsWith("\\") && rows.length) {
+      rows.push({ kind: "meta", text: l });
+    } else if (!inHunk) {
+      continue; // headers: the file bar already names the file
+    } else if (l.startsWith("+")) {
+      rows.push({ kind: "add", text: l.slice(1), newNo: newNo++ });
+      newLeft--;
+    } else if (l.startsWith("-")) {
+      rows.push({ kind: "del", text: l.slice(1), oldNo: oldNo++ });
+      oldLeft--;
+    } else {
+      rows.push({ kind: "ctx", text: l.slice(1), oldNo: oldNo++, newNo: newNo++ });
+      oldLeft--;
+      newLeft--;
+    }
+  }
+  return rows;
+}
+
+// loadRows fetches a file's diff lines from the session, for files whose
+// hunks were split and no longer match the source diff.
+async function loadRows(i) {
+  const headers = state.token ? { Authorization: "Bearer " + state.token } : {};
+  try {
+    const res = await fetch(`api/sessions/${encodeURIComponent(state.session)}/files/${i}/lines`, { headers });
+    const body = await res.json();
+    if (!res.ok) throw new Error(body.error || res.statusText);
+    const rows = [];
+    for (const l of body.lines) {
+      switch (l.op) {
+        case "hunk": rows.push({ kind: "hunk", text: l.text, hunk: l.hunk }); break;
+        case "add": rows.push({ kind: "add", text: l.text, newNo: l.new_num }); break;
+        case "delete": rows.push({ kind: "del", text: l.text, oldNo: l.old_num }); break;
+        default: rows.push({ kind: "ctx", text: l.text, oldNo: l.old_num, newNo: l.new_num });
+      }
+      if (l.no_eol) rows.push({ kind: "meta", text: "\\ No newline at end of file" });
+    }
+    state.diffs[i] = rows;
+    render();
+  } catch (err) {
+    setStatus("could not reload the diff: " + err.message, true);
+  }
+}
+
+// --- Rendering ---
+
+function fileFindings(i) {
+  const f = state.files[i];
+  return f ? state.findings.filter((x) => x.file === f.name) : [];
+}
+
+function maxRisk(findings) {
+  let max = -1;
+  for (const f of findings) max = Math.max(max, RISKS.indexOf(f.risk));
+  return max < 0 ? "" : RISKS[max];
+}
+
+function hunkDecisionOf(i, h) {
+  return state.hunks[i + ":" + h] || state.decisions[i] || "pending";
+}
+
+// decisionOf is a file's overall decision: "partial" when its hunks were
+// decided differently.
+function decisionOf(i) {
+  const f = state.files[i];
+  const own = Object.keys(state.hunks).some((k) => k.startsWith(i + ":"));
+  if (!f || !own) return state.decisions[i] || "pending";
+  const seen = new Set(f.hunks.map((h) => hunkDecisionOf(i, h.index)));
+  return seen.size === 1 ? [...seen][0] : "partial";
+}
+
+function render() {
+  renderFiles();
+  renderViewer();
+  renderTrace();
+  const decided = state.files.filter((_, i) => decisionOf(i) !== "pending").length;
+  $("progress").textContent = state.files.length ? `${decided}/${state.files.length} reviewed` : "";
+}
+
+function renderFiles() {
+  const nav = $("files");
+  nav.replaceChildren();
+  state.files.forEach((f, i) => {
+    const d = decisionOf(i);
+    const risk = maxRisk(fileFindings(i));
+    const row = el("div", { class: "file" + (i === state.current ? " current" : "") + (f.generated ? " generated" : ""), title: f.name, onclick: () => select(i) },
+      el("span", { class: "mark " + d }, MARKS[d]),
+      el("span", { class: "name" }, f.name),
+      risk ? el("span", { class: "risk-" + risk }, "●") : null,
+      el("span", { class: "counts" }, `+${f.added_lines} -${f.deleted_lines}`));
+    nav.append(row);
+  });
+}
+
+function renderViewer() {
+  const f = state.files[state.current];
+  const hasFile = !!f;
+  for (const id of ["approve", "reject", "undo", "comment"]) $(id).disabled = !hasFile;
+  $("file-name").textContent = hasFile ? f.name : "";
+  const d = hasFile ? decisionOf(state.current) : "";
+  $("file-decision").textContent = d;
+  $("file-decision").className = "badge decision-" + d;
+
+  const findings = fileFindings(state.current);
+  const byLine = {};
+  const fileLevel = [];
+  for (const x of findings) {
+    if (x.line > 0) (byLine[x.line] = byLine[x.line] || []).push(x);
+    else fileLevel.push(x);
+  }
+  $("findings").replaceChildren(...fileLevel.map(findingNode));
+
+  const diffBox = $("diff");
+  diffBox.replaceChildren();
+  if (!hasFile) {
+    diffBox.append(el("div", { class: "empty" }, state.files.length ? "" : "No changes."));
+  } else {
+    const rows = state.diffs[state.current] || [];
+    if (f.is_submodule) {
+      const short = (h) => (h || "").slice(0, 7);
+      diffBox.append(el("div", { class: "empty" },
+        !f.old_commit ? `Submodule added at ${short(f.new_commit)}`
+          : !f.new_commit ? `Submodule removed, was at ${short(f.old_commit)}`
+          : `Submodule ${short(f.old_commit)} → ${short(f.new_commit)}`));
+    } else if (f.generated && !state.showGenerated[state.current]) {
+      const i = state.current;
+      diffBox.append(el("div", { class: "empty" }, `⋯ ${f.generated} (generated) `,
+        el("button", { onclick: () => { state.showGenerated[i] = true; renderViewer(); } }, "Show diff")));
+    } else if (!rows.length) {
+      diffBox.append(el("div", { class: "empty" }, "No text changes (binary, mode, or rename only)."));
+    } else {
+      const lineComments = {};
+      for (const c of state.comments) {
+        if (c.file === f.name && c.line) (lineComments[c.line] = lineComments[c.line] || []).push(c);
+      }
+      const table = el("table", { class: "diff" });
+      // Trace notes go above the first line they cover, or the file.
+      const anns = ((state.source && state.source.annotations) || {})[f.name] || [];
+      let nextAnn = 0;
+      for (; nextAnn < anns.length && !anns[nextAnn].start; nextAnn++) {
+        table.append(noteRow(annotationNode(anns[nextAnn]), "ann-" + anns[nextAnn].type));
+      }
+      let lastMove = null;
+      for (const r of rows) {
+        if (r.kind === "hunk") {
+          const hd = hunkDecisionOf(state.current, r.hunk);
+          const h = f.hunks[r.hunk];
+          table.append(el("tr", { class: "hunk" }, el("td", { colspan: 3 },
+            el("span", { class: "mark " + hd, title: hd }, MARKS[hd]), " ", r.text, " ",
+            el("button", { class: "hunk-action", title: "Approve hunk", onclick: () => decideHunk("approve", r.hunk) }, "✓"),
+            el("button", { class: "hunk-action", title: "Reject hunk", onclick: () => decideHunk("reject", r.hunk) }, "✗"),
+            el("button", { class: "hunk-action", title: "Undo hunk", onclick: () => decideHunk("undo", r.hunk) }, "↺"),
+            h && h.splittable
+              ? el("button", { class: "hunk-action", title: "Split hunk", onclick: () => decideHunk("split", r.hunk) }, "✂")
+              : null)));
+          continue;
+        }
+        if (r.kind === "meta") {
+          table.append(el("tr", { class: r.kind }, el("td", { colspan: 3 }, r.text)));
+          continue;
+        }
+        if (r.newNo && r.kind !== "del") {
+          for (; nextAnn < anns.length && anns[nextAnn].start <= r.newNo; nextAnn++) {
+            if (anns[nextAnn].end >= r.newNo) table.append(noteRow(annotationNode(anns[nextAnn]), "ann-" + anns[nextAnn].type));
+          }
+        }
+        const mv = moveAt(f, r);
+        if (mv && mv !== lastMove) table.append(noteRow(moveNode(mv, r.kind), "move"));
+        lastMove = mv;
+        const line = r.newNo || r.oldNo;
+        table.append(el("tr", { class: r.kind + (mv ? " moved" : "") },
+          el("td", { class: "num", title: "Comment on this line", onclick: () => comment(line) }, r.oldNo ?? ""),
+          el("td", { class: "num", title: "Comment on this line", onclick: () => comment(line) }, r.newNo ?? ""),
+          el("td", { class: "code" }, r.text)));
+        if (r.newNo && r.kind !== "del") {
+          for (const x of byLine[r.newNo] || []) table.append(noteRow(findingNode(x), "risk-" + x.risk));
+          delete byLine[r.newNo];
+          for (const c of lineComments[r.newNo] || []) table.append(noteRow(commentNode(c), "decision-pending"));
+          delete lineComments[r.newNo];
+        }
+      }
+      diffBox.append(table);
+      // Findings on lines outside the hunks still deserve a mention.
+      $("findings").append(...Object.values(byLine).flat().map(findingNode));
+    }
+  }
+
+  const fileComments = hasFile ? state.comments.filter((c) => c.file === f.name && !c.line) : [];
+  $("comments").replaceChildren(...fileComments.map(commentNode));
+}
+
+// moveAt returns the move a deleted or added row belongs to, if any.
+function moveAt(f, r) {
+  if (r.kind === "del") {
+    return state.moves.find((m) => m.from_file === f.old_name && r.oldNo >= m.from_start && r.oldNo <= m.from_end);
+  }
+  if (r.kind === "add") {
+    return state.moves.find((m) => m.to_file === f.new_name && r.newNo >= m.to_start && r.newNo <= m.to_end);
+  }
+  return undefined;
+}
+
+function moveNode(m, kind) {
+  return kind === "del"
+    ? el("div", {}, `↪ moved to ${m.to_file}:${m.to_start}-${m.to_end}`)
+    : el("div", {}, `↩ moved from ${m.from_file}:${m.from_start}-${m.from_end}`);
+}
+
+function noteRow(node, cls) {
+  return el("tr", { class: "note " + cls }, el("td", { colspan: 2 }), el("td", { class: "code" }, node));
+}
+
+function findingNode(x) {
+  return el("div", { class: "finding risk-" + x.risk },
+    `[${x.risk}] `, el("span", { class: "pass" }, x.pass + ": "),
+    x.rule ? el("a", { class: "rule", href: x.docs_url, target: "_blank", rel: "noopener" }, x.rule) : null, x.rule ? " " : "",
+    x.line ? `line ${x.line}: ` : "", x.message);
+}
+
+function annotationNode(a) {
+  const mark = a.type === "trace_link" ? "⤷ " : a.type === "risk" || a.type === "warning" ? "! " : "";
+  return el("div", { class: "annotation", title: a.step ? `trace step ${a.step}` : "" }, mark + a.message);
+}
+
+function commentNode(c) {
+  const title = c.time ? new Date(c.time).toLocaleString() : "";
+  return el("div", { class: "comment", title }, "💬 ", c.author ? el("b", {}, c.author + " ") : null,
+    c.line ? `line ${c.line}: ` : "", c.body);
+}
+
+// stepMatches reports whether a trace step touched the file; traces often
+// record absolute paths.
+function stepMatches(step, name) {
+  return step.file && (step.file === name || step.file.endsWith("/" + name));
+}
+
+function renderTrace() {
+  const t = state.source && state.source.trace;
+  const list = $("trace-steps");
+  list.replaceChildren();
+  if (!t) {
+    $("trace-summary").textContent = "No agent trace.";
+    return;
+  }
+  $("trace-summary").textContent = t.summary || "";
+  const f = state.files[state.current];
+  const steps = state.traceAll || !f ? t.steps : t.steps.filter((s) => stepMatches(s, f.name));
+  if (!steps.length) {
+    list.append(el("li", { class: "empty" }, "The agent's trace doesn't mention this file."));
+    return;
+  }
+  for (const s of steps) {
+    const when = s.timestamp ? new Date(s.timestamp).toLocaleTimeString() : "";
+    const li = el("li", { class: s.exit_code ? "failed" : "" },
+      el("span", { class: "when" }, when),
+      el("span", { class: "type type-" + s.type }, s.type),
+      el("span", { class: "summary" }, s.summary + (s.exit_code ? ` [exit ${s.exit_code}]` : "")));
+    const body = stepBody(s);
+    if (body) {
+      li.addEventListener("click", () => {
+        const open = li.querySelector("pre");
+        if (open) open.remove();
+        else li.append(el("pre", {}, body));
+      });
+    }
+    list.append(li);
+  }
+}
+
+function stepBody(s) {
+  if (s.type === "edit" && (s.old_string || s.new_string)) {
+    const lines = (text, mark) => (text ? text.replace(/\n$/, "").split("\n").map((l) => mark + l) : []);
+    return [...lines(s.old_string, "- "), ...lines(s.new_string, "+ ")].join("\n");
+  }
+  if (s.type === "bash") return "$ " + s.command + (s.output ? "\n" + s.output : "");
+  return s.detail || "";
+}
+
+function showSummary(d) {
+  const body = $("summary-body");
+  body.replaceChildren(
+    el("p", {}, `${d.approved} approved, ${d.rejected} rejected, ${d.partial} partial, ${d.pending} pending.`),
+    el("ul", {}, ...(d.files || []).map((f) =>
+      el("li", {}, el("span", { class: "mark " + f.decision }, MARKS[f.decision] + " "), f.name,
+        f.hunks ? el("span", { class: "dim" }, " (hunks: " + f.hunks.map((h) => MARKS[h]).join(" ") + ")") : null))));
+  if (d.comments && d.comments.length) {
+    body.append(el("h3", {}, "Comments"),
+      el("ul", {}, ...d.comments.map((c) => el("li", {}, `${c.file}${c.line ? ":" + c.line : ""}: ${c.author ? c.author + ": " : ""}${c.body}`))));
+  }
+  $("summary").showModal();
+}
+
+// --- Actions ---
+
+function select(i) {
+  if (i < 0 || i >= state.files.length) return;
+  state.current = i;
+  render();
+  $("viewer").scrollTop = 0;
+  const row = $("files").children[i];
+  if (row) row.scrollIntoView({ block: "nearest" });
+}
+
+// withNote asks why something is being rejected and adds the answer, if
+// any, to the decision.
+function withNote(type, data, what) {
+  if (type === "reject") {
+    const note = prompt(`Why reject ${what}? (optional)`);
+    if (note) data.note = note;
+  }
+  return data;
+}
+
+function decide(type) {
+  if (!state.files.length) return;
+  send(type, withNote(type, { file_index: state.current }, state.files[state.current].name));
+  // Move on after a decision, like the TUI.
+  if (type !== "undo" && state.current + 1 < state.files.length) select(state.current + 1);
+}
+
+function decideHunk(type, hunk) {
+  const what = `hunk ${hunk + 1} of ${state.files[state.current].name}`;
+  send(type, withNote(type, { file_index: state.current, hunk_index: hunk }, what));
+}
+
+function comment(line) {
+  const f = state.files[state.current];
+  if (!f) return;
+  const body = prompt(line ? `Comment on ${f.name}:${line}` : `Comment on ${f.name}`);
+  if (!body) return;
+  const data = { file_index: state.current, body };
+  if (line) data.line = line;
+  send("comment", data);
+}
+
+function toggleTrace() {
+  document.body.classList.toggle("no-trace");
+}
+
+function bind() {
+  $("approve").onclick = () => decide("approve");
+  $("reject").onclick = () => decide("reject");
+  $("undo").onclick = () => decide("undo");
+  $("comment").onclick = () => comment(0);
+  $("finish").onclick = () => send("finish");
+  $("share").onclick = share;
+  $("toggle-trace").onclick = toggleTrace;
+  $("trace-all").onchange = (e) => {
+    state.traceAll = e.target.checked;
+    renderTrace();
+  };
+
+  document.addEventListener("keydown", (e) => {
+    if (e.ctrlKey || e.metaKey || e.altKey || $("summary").open) return;
+    if (e.target instanceof HTMLInputElement) return;
+    switch (e.key) {
+      case "j": case "ArrowDown": select(state.current + 1); break;
+      case "k": case "ArrowUp": select(state.current - 1); break;
+      case "a": decide("approve"); break;
+      case "x": decide("reject"); break;
+      case "u": decide("undo"); break;
+      case "c": comment(0); break;
+      case "t": toggleTrace(); break;
+      case "f": send("finish"); break;
+      default: return;
+    }
+    e.preventDefault();
+  });
+}
+
+async function main() {
+  bind();
+  initToken();
+  try {
+    state.source = await loadSource();
+  } catch (err) {
+    setStatus("could not load the change: " + err.message, true);
+    return;
+  }
+  $("label").textContent = state.source.label || "working tree";
+  document.title = "agrev · " + (state.source.label || "working tree");
+  if (!state.source.trace) document.body.classList.add("no-trace");
+  state.diffs = splitDiff(state.source.diff);
+  render();
+  connect();
+}
+
+main();
@@ -0,0 +1,272 @@
+package api
+
+import (
+	"net/http"
+	"sync"
+
+	"github.com/aezell/agrev/internal/analysis"
+	"github.com/aezell/agrev/internal/annotate"
+	"github.com/aezell/agrev/internal/audit"
+	"github.com/aezell/agrev/internal/config"
+	"github.com/aezell/agrev/internal/diff"
+	"github.com/aezell/agrev/internal/model"
+	"github.com/aezell/agrev/internal/review"
+	savedsession "github.com/aezell/agrev/internal/session"
+	"github.com/aezell/agrev/internal/triage"
+)
+
+// workspace serves the /api/workspace endpoints, which an editor extension
+// uses to show agrev's view of the repository the server runs in next to
+// its files: the uncommitted changes, the findings on the open file, the
+// decisions of the saved review, and the trace steps that touched it.
+type workspace struct {
+	load func() (*Source, error)
+
+	// mu serializes updates to the saved review and guards the cache.
+	mu sync.Mutex
+
+	// The analysis of the last changes looked at, reused until they change,
+	// so switching between files doesn't run it again
+	analyzed string // session.Hash of the diff
+	results  *analysis.Results
+}
+
+type workspaceDiffResponse struct {
+	RepoDir string        `json:"repo_dir"`
+	Diff    string        `json:"diff"`
+	Files   []fileJSON    `json:"files"`
+	Stats   diffStatsJSON `json:"stats"`
+}
+
+type workspaceFindingsResponse struct {
+	File     string        `json:"file,omitempty"`
+	MaxRisk  string        `json:"max_risk" enum:"info,low,medium,high,critical"`
+	Findings []findingJSON `json:"findings"`
+
+	// Suppressed are the findings reviewers marked as false positives
+	Suppressed []findingJSON `json:"suppressed,omitempty"`
+}
+
+// workspaceDecisionRequest decides on a file, named by its path, or on one
+// of its hunks.
+type workspaceDecisionRequest struct {
+	File       string   `json:"file"`
+	HunkIndex  *int     `json:"hunk_index,omitempty"`
+	Decision   string   `json:"decision" enum:"approve,reject,undo"`
+	Note       string   `json:"note,omitempty"`       // why it was rejected
+	Conditions []string `json:"conditions,omitempty"` // follow-ups a whole file is approved on
+	Reviewer   string   `json:"reviewer,omitempty"`   // default: the token's name
+}
+
+type workspaceTraceResponse struct {
+	File        string           `json:"file"`
+	Source      string           `json:"source,omitempty"` // the agent; empty without a trace
+	Steps       []workspaceStep  `json:"steps"`
+	Annotations []annotationJSON `json:"annotations,omitempty"`
+}
+
+// workspaceStep is a trace step with its 1-based number in the trace, which
+// annotations' step refers to.
+type workspaceStep struct {
+	Step int `json:"step"`
+	stepJSON
+}
+
+// change loads the working tree's changes, or writes an error.
+func (ws *workspace) change(w http.ResponseWriter) (*Source, *diff.DiffSet, bool) {
+	src, err := ws.load()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return nil, nil, false
+	}
+	ds, err := diff.Parse(src.Diff)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return nil, nil, false
+	}
+	return src, ds, true
+}
+
+// fileIndex is the index in ds of the file named by its new or old path,
+// or -1.
+func fileIndex(ds *diff.DiffSet, name string) int {
+	for i, f := range ds.Files {
+		if f.Name() == name || f.OldName == name {
+			return i
+		}
+	}
+	return -1
+}
+
+func (s *Server) handleWorkspaceDiff(w http.ResponseWriter, r *http.Request) {
+	src, ds, ok := s.workspace.change(w)
+	if !ok {
+		return
+	}
+	resp := workspaceDiffResponse{RepoDir: src.RepoDir, Diff: src.Diff, Files: []fileJSON{}, Stats: newDiffStatsJSON(ds)}
+	for _, f := range ds.Files {
+		resp.Files = append(resp.Files, newFileJSON(f))
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (s *Server) handleWorkspaceFindings(w http.ResponseWriter, r *http.Request) {
+	ws := s.workspace
+	src, ds, ok := ws.change(w)
+	if !ok {
+		return
+	}
+	name := r.URL.Query().Get("file")
+
+	ws.mu.Lock()
+	results := ws.results
+	if hash := savedsession.Hash(src.Diff); hash != ws.analyzed {
+		// Passes such as blast_radius look across files, so the whole
+		// change is analyzed
+		var err error
+		results, err = analysis.RunContext(r.Context(), ds, src.RepoDir, nil)
+		if err != nil {
+			ws.mu.Unlock()
+			writeError(w, http.StatusServiceUnavailable, "analysis cancelled: "+err.Error())
+			return
+		}
+		if store, err := triage.Load(src.RepoDir); err == nil {
+			store.Apply(results)
+		}
+		ws.analyzed, ws.results = hash, results
+	}
+	ws.mu.Unlock()
+
+	resp := workspaceFindingsResponse{File: name, Findings: []findingJSON{}}
+	var kept analysis.Results
+	for _, f := range results.Findings {
+		if name == "" || f.File == name {
+			kept.Findings = append(kept.Findings, f)
+			resp.Findings = append(resp.Findings, newFindingJSON(f))
+		}
+	}
+	for _, f := range results.Suppressed {
+		if name == "" || f.File == name {
+			resp.Suppressed = append(resp.Suppressed, newFindingJSON(f))
+		}
+	}
+	resp.MaxRisk = kept.MaxRisk().String()
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// savedReview is the working tree's review: its changes with the decisions
+// and comments of the saved review restored onto the files they were made
+// on.
+func (ws *workspace) savedReview(w http.ResponseWriter) (*Source, *reviewSession, *savedsession.Session, bool) {
+	src, ds, ok := ws.change(w)
+	if !ok {
+		return nil, nil, nil, false
+	}
+	saved, err := savedsession.Load(src.RepoDir)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return nil, nil, nil, false
+	}
+	session := &reviewSession{review: review.New(ds, nil, nil), repoDir: src.RepoDir}
+	if saved != nil {
+		saved.Restore(session.review)
+	}
+	return src, session, saved, true
+}
+
+func (s *Server) handleWorkspaceDecisions(w http.ResponseWriter, r *http.Request) {
+	s.workspace.mu.Lock()
+	defer s.workspace.mu.Unlock()
+	_, session, _, ok := s.workspace.savedReview(w)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, session.stateResponse())
+}
+
+// handleWorkspaceDecide records a decision in the saved review and the
+// audit log, as the TUI would, and returns every file's decision.
+func (s *Server) handleWorkspaceDecide(w http.ResponseWriter, r *http.Request) {
+	var req workspaceDecisionRequest
+	if err := readJSON(r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request: "+err.Error())
+		return
+	}
+	ws := s.workspace
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	src, session, saved, ok := ws.savedReview(w)
+	if !ok {
+		return
+	}
+	msg := wsDecisionMsg{FileIndex: fileIndex(session.review.Diff, req.File), HunkIndex: req.HunkIndex, Note: req.Note, Conditions: req.Conditions}
+	if msg.FileIndex < 0 {
+		writeError(w, http.StatusNotFound, req.File+" has no uncommitted changes")
+		return
+	}
+	if m := session.checkTarget(msg); m != "" {
+		writeError(w, http.StatusBadRequest, m)
+		return
+	}
+
+	reviewer := req.Reviewer
+	if tok, ok := tokenFrom(r.Context()); ok && reviewer == "" {
+		reviewer = tok.Name
+	}
+	if cfg, err := config.Load(src.RepoDir); err != nil {
+		s.logger(r.Context()).Warn("loading config", "error", err)
+	} else if !cfg.NoAudit {
+		session.audit = audit.New(src.RepoDir, "api")
+		session.audit.Reviewer = reviewer
+	}
+	logAction := func(e audit.Event) {
+		if err := session.audit.Write(e); err != nil {
+			s.logger(r.Context()).Warn("writing audit log", "error", err)
+		}
+	}
+	switch req.Decision {
+	case "approve":
+		session.decide(msg, model.DecisionApproved, logAction)
+	case "reject":
+		session.decide(msg, model.DecisionRejected, logAction)
+	case "undo":
+		session.undo(msg, logAction)
+	default:
+		writeError(w, http.StatusBadRequest, "decision must be approve, reject, or undo")
+		return
+	}
+
+	if saved == nil {
+		saved = savedsession.New(session.review.Diff)
+	}
+	saved.Record(session.review)
+	if err := saved.Save(src.RepoDir); err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, session.stateResponse())
+}
+
+func (s *Server) handleWorkspaceTrace(w http.ResponseWriter, r *http.Request) {
+	name := r.URL.Query().Get("file")
+	if name == "" {
+		writeError(w, http.StatusBadRequest, "file is required")
+		return
+	}
+	src, ds, ok := s.workspace.change(w)
+	if !ok {
+		return
+	}
+	resp := workspaceTraceResponse{File: name, Steps: []workspaceStep{}}
+	if t := src.Trace; t != nil {
+		resp.Source = t.Source
+		steps := newTraceJSON(t).Steps
+		for i, step := range t.Steps {
+			if step.Touches(name) {
+				resp.Steps = append(resp.Steps, workspaceStep{Step: i + 1, stepJSON: steps[i]})
+			}
+		}
+		resp.Annotations = newAnnotationsJSON(annotate.Build(ds, t))[name]
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
@@ -1,14 +1,22 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
-	"log"
+	"log/slog"
 	"net/http"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/gorilla/websocket"
 	"github.com/aezell/agrev/internal/analysis"
+	"github.com/aezell/agrev/internal/audit"
+	"github.com/aezell/agrev/internal/config"
 	"github.com/aezell/agrev/internal/diff"
 	"github.com/aezell/agrev/internal/model"
+	"github.com/aezell/agrev/internal/review"
+	"github.com/aezell/agrev/internal/triage"
 )
 
 var upgrader = websocket.Upgrader{
@@ -25,18 +33,60 @@ const (
 	wsMsgApprove  = "approve"
 	wsMsgReject   = "reject"
 	wsMsgUndo     = "undo"
+	wsMsgComment  = "comment"
+	wsMsgSplit    = "split"
+	wsMsgTriage   = "triage"
+	wsMsgLabel    = "label"
+	wsMsgUnlabel  = "unlabel"
 	wsMsgFinish   = "finish"
 )
 
 // WebSocket message types to client.
 const (
-	wsMsgParsed   = "parsed"
-	wsMsgAnalysis = "analysis"
-	wsMsgDecision = "decision"
-	wsMsgSummary  = "summary"
-	wsMsgError    = "error"
+	wsMsgJoined       = "joined"
+	wsMsgParticipants = "participants"
+	wsMsgParsed       = "parsed"
+	wsMsgAnalysis     = "analysis"
+	wsMsgState        = "state"
+	wsMsgDecision     = "decision"
+	wsMsgSplitHunk    = "hunk_split"
+	wsMsgComments     = "comments"
+	wsMsgTriaged      = "finding_triaged"
+	wsMsgLabels       = "labels"
+	wsMsgSummary      = "summary"
+	wsMsgError        = "error"
 )
 
+// wsClientMessages and wsServerMessages give each message type's payload,
+// nil for none. They document the protocol in the OpenAPI spec.
+var wsClientMessages = map[string]any{
+	wsMsgLoadDiff: wsLoadDiff{},
+	wsMsgApprove:  wsDecisionMsg{},
+	wsMsgReject:   wsDecisionMsg{},
+	wsMsgUndo:     wsDecisionMsg{},
+	wsMsgComment:  wsCommentMsg{},
+	wsMsgSplit:    wsDecisionMsg{},
+	wsMsgTriage:   wsTriageMsg{},
+	wsMsgLabel:    wsLabelMsg{},
+	wsMsgUnlabel:  wsLabelMsg{},
+	wsMsgFinish:   nil,
+}
+
+var wsServerMessages = map[string]any{
+	wsMsgJoined:       wsJoinedResponse{},
+	wsMsgParticipants: wsParticipantsResponse{},
+	wsMsgParsed:       wsParsedResponse{},
+	wsMsgAnalysis:     wsAnalysisResponse{},
+	wsMsgState:        wsStateResponse{},
+	wsMsgDecision:     wsDecisionResponse{},
+	wsMsgSplitHunk:    wsSplitResponse{},
+	wsMsgComments:     []commentJSON{},
+	wsMsgTriaged:      wsTriageResponse{},
+	wsMsgLabels:       wsLabelsResponse{},
+	wsMsgSummary:      wsSummaryResponse{},
+	wsMsgError:        wsErrorResponse{},
+}
+
 // wsMessage is the envelope for WebSocket messages in both directions.
 type wsMessage struct {
 	Type string          `json:"type"`
@@ -50,68 +100,288 @@ type wsLoadDiff struct {
 	Skip    []string `json:"skip,omitempty"`
 }
 
-// wsDecisionMsg is the payload for approve/reject/undo messages.
+// wsDecisionMsg is the payload for approve/reject/undo messages. With a
+// hunk index the decision applies to that hunk only; without one it applies
+// to the whole file and replaces any hunk decisions in it. A rejection may
+// say why in Note.
 type wsDecisionMsg struct {
-	FileIndex int `json:"file_index"`
+	FileIndex int    `json:"file_index"`
+	HunkIndex *int   `json:"hunk_index,omitempty"`
+	Note      string `json:"note,omitempty"`
+
+	// Conditions are the follow-ups a whole file is approved on.
+	Conditions []string `json:"conditions,omitempty"`
+}
+
+// wsCommentMsg is the payload for "comment" messages.
+type wsCommentMsg struct {
+	FileIndex int    `json:"file_index"`
+	Line      int    `json:"line,omitempty"`
+	HunkIndex *int   `json:"hunk_index,omitempty"` // defaults to the hunk holding line
+	Body      string `json:"body"`
+}
+
+// wsTriageMsg is the payload for "triage" messages, which record what the
+// reviewer concluded about a finding in the repository the diff was loaded
+// with, so later runs suppress or down-rank it. "open" forgets it.
+type wsTriageMsg struct {
+	Fingerprint string `json:"fingerprint"`
+	State       string `json:"state" enum:"acknowledged,false_positive,fixed,open"`
+}
+
+// wsLabelMsg is the payload for "label" and "unlabel" messages, which
+// attach free-form labels to a file, or with a hunk index to one of its
+// hunks, or remove them. Labels are normalized as the TUI does: "needs
+// security review" becomes "needs-security-review".
+type wsLabelMsg struct {
+	FileIndex int      `json:"file_index"`
+	HunkIndex *int     `json:"hunk_index,omitempty"`
+	Labels    []string `json:"labels"`
+}
+
+// wsJoinedResponse is sent to a connection when it joins a session.
+type wsJoinedResponse struct {
+	SessionID    string   `json:"session_id"`
+	Reviewer     string   `json:"reviewer"`     // the name this connection's decisions and comments carry
+	Participants []string `json:"participants"` // everyone connected, in the order they joined
+}
+
+// wsParticipantsResponse is broadcast when a reviewer joins or leaves.
+type wsParticipantsResponse struct {
+	Participants []string `json:"participants"`
+	Joined       string   `json:"joined,omitempty"`
+	Left         string   `json:"left,omitempty"`
+}
+
+// wsStateResponse brings a joining connection up to date with the
+// decisions and comments made before it joined.
+type wsStateResponse struct {
+	Files    []wsFileDecision `json:"files"`
+	Comments []commentJSON    `json:"comments,omitempty"`
 }
 
 // wsParsedResponse is sent after a diff is loaded.
 type wsParsedResponse struct {
-	Files []fileJSON    `json:"files"`
-	Stats diffStatsJSON `json:"stats"`
+	SessionID string        `json:"session_id"`
+	Files     []fileJSON    `json:"files"`
+	Stats     diffStatsJSON `json:"stats"`
+	Moves     []moveJSON    `json:"moves"`
 }
 
 // wsAnalysisResponse is sent after analysis completes.
 type wsAnalysisResponse struct {
 	Summary  string        `json:"summary"`
-	MaxRisk  string        `json:"max_risk"`
+	MaxRisk  string        `json:"max_risk" enum:"info,low,medium,high,critical"`
 	Total    int           `json:"total"`
 	Findings []findingJSON `json:"findings"`
+
+	// Suppressed are the findings reviewers marked as false positives
+	Suppressed []findingJSON `json:"suppressed,omitempty"`
 }
 
-// wsDecisionResponse confirms a decision.
+// wsDecisionResponse announces a decision to everyone in the session.
 type wsDecisionResponse struct {
 	FileIndex int    `json:"file_index"`
-	Decision  string `json:"decision"`
+	HunkIndex *int   `json:"hunk_index,omitempty"`
+	Decision  string `json:"decision" enum:"approved,rejected,pending"`
+	Note      string `json:"note,omitempty"` // why it was rejected
+	Reviewer  string `json:"reviewer"`       // who made it
+
+	Conditions []string `json:"conditions,omitempty"` // follow-ups it was approved on
+}
+
+// wsSplitResponse announces that a hunk was split into Hunks smaller ones,
+// which take its place and its decision. Later hunks' indexes move up to
+// make room; File has the file's new hunks.
+type wsSplitResponse struct {
+	FileIndex int      `json:"file_index"`
+	HunkIndex int      `json:"hunk_index"`
+	Hunks     int      `json:"hunks"`
+	File      fileJSON `json:"file"`
+	Reviewer  string   `json:"reviewer"` // who split it
 }
 
 // wsSummaryResponse is sent when the review is finished.
 type wsSummaryResponse struct {
-	Approved int      `json:"approved"`
-	Rejected int      `json:"rejected"`
-	Pending  int      `json:"pending"`
+	Approved int              `json:"approved"`
+	Rejected int              `json:"rejected"`
+	Partial  int              `json:"partial"`
+	Pending  int              `json:"pending"`
 	Files    []wsFileDecision `json:"files"`
+	Comments []commentJSON    `json:"comments,omitempty"`
 }
 
+// wsTriageResponse announces that a finding was triaged.
+type wsTriageResponse struct {
+	Fingerprint string `json:"fingerprint"`
+	State       string `json:"state" enum:"acknowledged,false_positive,fixed,open"`
+	Reviewer    string `json:"reviewer"` // who triaged it
+}
+
+// wsLabelsResponse announces the labels now on a file or hunk after a
+// reviewer changed them.
+type wsLabelsResponse struct {
+	FileIndex int      `json:"file_index"`
+	HunkIndex *int     `json:"hunk_index,omitempty"`
+	Labels    []string `json:"labels"`
+	Reviewer  string   `json:"reviewer"` // who changed them
+}
+
+// wsErrorResponse reports a problem with a client message.
+type wsErrorResponse struct {
+	Message string `json:"message"`
+}
+
+// wsFileDecision is a file's outcome. A file with some hunks approved and
+// others rejected or pending is "partial"; Hunks then gives each hunk's.
 type wsFileDecision struct {
-	Name     string `json:"name"`
-	Decision string `json:"decision"`
+	Name      string   `json:"name"`
+	Decision  string   `json:"decision" enum:"approved,rejected,partial,pending"`
+	Note      string   `json:"note,omitempty"` // why the file was rejected
+	Hunks     []string `json:"hunks,omitempty"`
+	HunkNotes []string `json:"hunk_notes,omitempty"` // why each hunk was rejected, "" for none
+
+	Conditions []string `json:"conditions,omitempty"` // follow-ups the file was approved on
+
+	Labels     []string   `json:"labels,omitempty"`      // labels on the whole file
+	HunkLabels [][]string `json:"hunk_labels,omitempty"` // labels on each hunk, when any has some
+}
+
+// wsConn is a WebSocket connection with a logger carrying its request and
+// session IDs, and the context of its upgrade request, which is cancelled
+// when the server shuts down.
+type wsConn struct {
+	*websocket.Conn
+	log *slog.Logger
+	ctx context.Context
+
+	// wmu serializes writes, which come from the connection's own messages
+	// and from broadcasts by other participants.
+	wmu sync.Mutex
 }
 
-// reviewSession holds the state for a WebSocket review session.
+// wsWriteTimeout bounds each write, so one stalled participant can't hold
+// up a whole session for long.
+const wsWriteTimeout = 10 * time.Second
+
+// reviewSession holds the state for a WebSocket review session, shared by
+// every connection that joins it.
 type reviewSession struct {
-	ds        *diff.DiffSet
-	results   *analysis.Results
-	decisions map[int]model.ReviewDecision
+	id string
+
+	// mu guards the fields below, which the REST session endpoints read
+	// while the connections update them.
+	mu           sync.Mutex
+	participants []participant
+	joins        int // connections ever joined, for default reviewer names
+	created      time.Time
+	lastUsed     time.Time
+
+	review  *review.Session // nil until a diff is loaded
+	repoDir string          // the diff was loaded with, "" if none
+	triage  *triage.Store   // repoDir's triaged findings; nil without one
+	audit   *audit.Log      // repoDir's audit log; nil without one
+}
+
+// participant is a connection in a session and the reviewer using it.
+type participant struct {
+	conn     *wsConn
+	reviewer string
+}
+
+// logAction appends e, made by the reviewer on conn, to the audit log of
+// the repository the diff was loaded from, if any.
+func (s *reviewSession) logAction(conn *wsConn, e audit.Event) {
+	e.Reviewer = s.reviewer(conn)
+	if err := s.audit.Write(e); err != nil {
+		conn.log.Warn("writing audit log", "error", err)
+	}
 }
 
+// fileDecision summarizes a file's decision and, when hunks were decided
+// individually, each hunk's, with the notes on them.
+func (s *reviewSession) fileDecision(i int) wsFileDecision {
+	f := s.review.Diff.Files[i]
+	n := len(f.Fragments)
+	fd := wsFileDecision{
+		Name:     f.Name(),
+		Decision: s.review.Decisions.File(i, n).String(),
+		Note:     s.review.Decisions.Note(i, model.WholeFile),
+
+		Conditions: s.review.Decisions.Conditions[i],
+		Labels:     s.review.Decisions.Label(i, model.WholeFile),
+	}
+	for h := range n {
+		if len(s.review.Decisions.Label(i, h)) > 0 {
+			fd.HunkLabels = make([][]string, n)
+			for h := range n {
+				fd.HunkLabels[h] = s.review.Decisions.Label(i, h)
+			}
+			break
+		}
+	}
+	if !s.review.Decisions.HunksDecided(i) {
+		return fd
+	}
+	noted := false
+	for h := range n {
+		fd.Hunks = append(fd.Hunks, s.review.Decisions.Hunk(i, h).String())
+		fd.HunkNotes = append(fd.HunkNotes, s.review.Decisions.Note(i, h))
+		noted = noted || s.review.Decisions.Note(i, h) != ""
+	}
+	if !noted {
+		fd.HunkNotes = nil
+	}
+	return fd
+}
+
+// handleWebSocket opens a review session, or joins the one named by the
+// session query parameter so several reviewers can share it.
 func (s *Server) handleWebSocket(w http.ResponseWriter, r *http.Request) {
-	conn, err := upgrader.Upgrade(w, r, nil)
+	log := s.logger(r.Context())
+	var session *reviewSession
+	if id := r.URL.Query().Get("session"); id != "" {
+		if session = s.sessions.get(id); session == nil {
+			writeError(w, http.StatusNotFound, "no such session")
+			return
+		}
+	}
+
+	// The upgrader writes its own handshake response, so the request ID
+	// set by logRequests has to be passed along explicitly
+	ws, err := upgrader.Upgrade(w, r, http.Header{requestIDHeader: w.Header().Values(requestIDHeader)})
 	if err != nil {
-		log.Printf("websocket upgrade: %v", err)
+		log.Warn("websocket upgrade", "error", err)
+		return
+	}
+	defer ws.Close()
+
+	if session == nil {
+		session = s.sessions.create()
+	}
+	conn := &wsConn{Conn: ws, log: log.With("session_id", session.id), ctx: r.Context()}
+	untrack, ok := s.trackWebSocket(conn)
+	if !ok {
+		sendWSClose(conn)
 		return
 	}
-	defer conn.Close()
+	defer untrack()
 
-	session := &reviewSession{
-		decisions: make(map[int]model.ReviewDecision),
+	reviewer := r.URL.Query().Get("reviewer")
+	if tok, ok := tokenFrom(r.Context()); ok && reviewer == "" {
+		reviewer = tok.Name
 	}
+	session.join(conn, reviewer)
+	defer session.leave(conn)
+	conn.log = conn.log.With("reviewer", session.reviewer(conn))
+	conn.log.Info("websocket session opened")
+	defer conn.log.Info("websocket session closed")
 
 	for {
 		_, raw, err := conn.ReadMessage()
 		if err != nil {
 			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseNormalClosure) {
-				log.Printf("websocket read: %v", err)
+				conn.log.Warn("websocket read", "error", err)
 			}
 			return
 		}
@@ -122,6 +392,8 @@ func (s *Server) handleWebSocket(w http.ResponseWriter, r *http.Request) {
 			continue
 		}
 
+		session.mu.Lock()
+		session.lastUsed = time.Now()
 		switch msg.Type {
 		case wsMsgLoadDiff:
 			handleWSLoadDiff(conn, session, msg.Data)
@@ -131,15 +403,26 @@ func (s *Server) handleWebSocket(w http.ResponseWriter, r *http.Request) {
 			handleWSDecision(conn, session, msg.Data, model.DecisionRejected)
 		case wsMsgUndo:
 			handleWSUndo(conn, session, msg.Data)
+		case wsMsgComment:
+			handleWSComment(conn, session, msg.Data)
+		case wsMsgSplit:
+			handleWSSplit(conn, session, msg.Data)
+		case wsMsgTriage:
+			handleWSTriage(conn, session, msg.Data)
+		case wsMsgLabel:
+			handleWSLabel(conn, session, msg.Data, true)
+		case wsMsgUnlabel:
+			handleWSLabel(conn, session, msg.Data, false)
 		case wsMsgFinish:
 			handleWSFinish(conn, session)
 		default:
 			sendWSError(conn, "unknown message type: "+msg.Type)
 		}
+		session.mu.Unlock()
 	}
 }
 
-func handleWSLoadDiff(conn *websocket.Conn, session *reviewSession, data json.RawMessage) {
+func handleWSLoadDiff(conn *wsConn, session *reviewSession, data json.RawMessage) {
 	var req wsLoadDiff
 	if err := json.Unmarshal(data, &req); err != nil {
 		sendWSError(conn, "invalid load_diff data")
@@ -152,53 +435,77 @@ func handleWSLoadDiff(conn *websocket.Conn, session *reviewSession, data json.Ra
 		return
 	}
 
-	session.ds = ds
-	session.decisions = make(map[int]model.ReviewDecision)
-
-	// Send parsed response
-	nFiles, added, deleted := ds.Stats()
-	parsed := wsParsedResponse{
-		Stats: diffStatsJSON{Files: nFiles, Added: added, Deleted: deleted},
-	}
-	for _, f := range ds.Files {
-		parsed.Files = append(parsed.Files, fileJSON{
-			Name:         f.Name(),
-			OldName:      f.OldName,
-			NewName:      f.NewName,
-			IsNew:        f.IsNew,
-			IsDeleted:    f.IsDeleted,
-			IsRenamed:    f.IsRenamed,
-			AddedLines:   f.AddedLines,
-			DeletedLines: f.DeletedLines,
-			Fragments:    len(f.Fragments),
-		})
-	}
-	sendWSMessage(conn, wsMsgParsed, parsed)
+	// Loading a diff starts the review over for everyone in the session
+	session.review = review.New(ds, nil, nil)
+	session.repoDir, session.triage, session.audit = req.RepoDir, nil, nil
+	if req.RepoDir != "" {
+		if cfg, err := config.Load(req.RepoDir); err != nil {
+			conn.log.Warn("loading config", "error", err)
+		} else if !cfg.NoAudit {
+			session.audit = audit.New(req.RepoDir, "api")
+			session.audit.Range = session.review.CommitRange
+		}
+	}
+	session.broadcast(wsMsgParsed, session.parsedResponse())
 
 	// Run analysis
-	results := analysis.Run(ds, req.RepoDir, req.Skip)
-	session.results = results
+	results, err := analysis.RunContext(conn.ctx, ds, req.RepoDir, req.Skip)
+	if err != nil {
+		conn.log.Info("analysis cancelled", "error", err)
+		sendWSError(conn, "analysis cancelled: server shutting down")
+		return
+	}
+	if req.RepoDir != "" {
+		if session.triage, err = triage.Load(req.RepoDir); err != nil {
+			sendWSError(conn, err.Error())
+		}
+		session.triage.Apply(results)
+	}
+	session.review.SetResults(results)
 
-	analysisResp := wsAnalysisResponse{
+	analysisResp := newAnalysisResponse(results)
+	session.broadcast(wsMsgAnalysis, analysisResp)
+	conn.log.Info("diff loaded", "files", len(ds.Files), "findings", len(results.Findings), "max_risk", analysisResp.MaxRisk)
+}
+
+func (s *reviewSession) parsedResponse() wsParsedResponse {
+	parsed := wsParsedResponse{
+		SessionID: s.id,
+		Stats:     newDiffStatsJSON(s.review.Diff),
+		Moves:     movesJSON(s.review.Diff),
+	}
+	for _, f := range s.review.Diff.Files {
+		parsed.Files = append(parsed.Files, newFileJSON(f))
+	}
+	return parsed
+}
+
+func newAnalysisResponse(results *analysis.Results) wsAnalysisResponse {
+	resp := wsAnalysisResponse{
 		Summary: results.Summary(),
 		MaxRisk: results.MaxRisk().String(),
 		Total:   len(results.Findings),
 	}
 	for _, f := range results.Findings {
-		analysisResp.Findings = append(analysisResp.Findings, findingJSON{
-			Pass:     f.Pass,
-			File:     f.File,
-			Line:     f.Line,
-			Message:  f.Message,
-			Severity: severityStr(f.Severity),
-			Risk:     f.Risk.String(),
-		})
+		resp.Findings = append(resp.Findings, newFindingJSON(f))
+	}
+	for _, f := range results.Suppressed {
+		resp.Suppressed = append(resp.Suppressed, newFindingJSON(f))
+	}
+	return resp
+}
+
+// stateResponse gives every file's decision and the comments so far.
+func (s *reviewSession) stateResponse() wsStateResponse {
+	state := wsStateResponse{Files: []wsFileDecision{}, Comments: commentsJSON(s.review.Comments)}
+	for i := range s.review.Diff.Files {
+		state.Files = append(state.Files, s.fileDecision(i))
 	}
-	sendWSMessage(conn, wsMsgAnalysis, analysisResp)
+	return state
 }
 
-func handleWSDecision(conn *websocket.Conn, session *reviewSession, data json.RawMessage, decision model.ReviewDecision) {
-	if session.ds == nil {
+func handleWSDecision(conn *wsConn, session *reviewSession, data json.RawMessage, decision model.ReviewDecision) {
+	if session.review == nil {
 		sendWSError(conn, "no diff loaded")
 		return
 	}
@@ -208,27 +515,62 @@ func handleWSDecision(conn *websocket.Conn, session *reviewSession, data json.Ra
 		sendWSError(conn, "invalid decision data")
 		return
 	}
-
-	if req.FileIndex < 0 || req.FileIndex >= len(session.ds.Files) {
-		sendWSError(conn, "file_index out of range")
+	if msg := session.checkTarget(req); msg != "" {
+		sendWSError(conn, msg)
 		return
 	}
 
-	session.decisions[req.FileIndex] = decision
+	resp := session.decide(req, decision, func(e audit.Event) { session.logAction(conn, e) })
+	resp.Reviewer = session.reviewer(conn)
+	session.broadcast(wsMsgDecision, resp)
+}
 
-	decisionStr := "approved"
+// decide records decision on the file or hunk req names, logging what was
+// done with logAction, and returns the announcement of it, for the
+// reviewer to be filled in.
+func (s *reviewSession) decide(req wsDecisionMsg, decision model.ReviewDecision, logAction func(audit.Event)) wsDecisionResponse {
+	name := s.review.Diff.Files[req.FileIndex].Name()
+	action := audit.ActionApprove
+	if decision == model.DecisionRejected {
+		action = audit.ActionReject
+	}
+	hunk := model.WholeFile
+	if req.HunkIndex != nil {
+		hunk = *req.HunkIndex
+		s.review.Decisions.SetHunk(req.FileIndex, hunk, decision)
+	} else {
+		s.review.Decisions.SetFile(req.FileIndex, decision)
+	}
+	logAction(audit.Event{Action: action, File: name, Hunk: hunk + 1})
+	note := ""
 	if decision == model.DecisionRejected {
-		decisionStr = "rejected"
+		note = strings.TrimSpace(req.Note)
+		s.review.Decisions.SetNote(req.FileIndex, hunk, note)
+		if note != "" {
+			logAction(audit.Event{Action: audit.ActionNote, File: name, Hunk: hunk + 1, Detail: note})
+		}
+	}
+	if decision == model.DecisionApproved && req.HunkIndex == nil {
+		for _, c := range req.Conditions {
+			if c = strings.TrimSpace(c); c != "" {
+				s.review.Decisions.AddCondition(req.FileIndex, c)
+				logAction(audit.Event{Action: audit.ActionFollowUp, File: name, Detail: c})
+			}
+		}
 	}
 
-	sendWSMessage(conn, wsMsgDecision, wsDecisionResponse{
+	return wsDecisionResponse{
 		FileIndex: req.FileIndex,
-		Decision:  decisionStr,
-	})
+		HunkIndex: req.HunkIndex,
+		Decision:  decision.String(),
+		Note:      note,
+
+		Conditions: s.review.Decisions.Conditions[req.FileIndex],
+	}
 }
 
-func handleWSUndo(conn *websocket.Conn, session *reviewSession, data json.RawMessage) {
-	if session.ds == nil {
+func handleWSUndo(conn *wsConn, session *reviewSession, data json.RawMessage) {
+	if session.review == nil {
 		sendWSError(conn, "no diff loaded")
 		return
 	}
@@ -238,60 +580,297 @@ func handleWSUndo(conn *websocket.Conn, session *reviewSession, data json.RawMes
 		sendWSError(conn, "invalid undo data")
 		return
 	}
+	if msg := session.checkTarget(req); msg != "" {
+		sendWSError(conn, msg)
+		return
+	}
+
+	resp := session.undo(req, func(e audit.Event) { session.logAction(conn, e) })
+	resp.Reviewer = session.reviewer(conn)
+	session.broadcast(wsMsgDecision, resp)
+}
+
+// undo clears the decision on the file or hunk req names, as decide
+// records one.
+func (s *reviewSession) undo(req wsDecisionMsg, logAction func(audit.Event)) wsDecisionResponse {
+	// Undoing a hunk falls back to the file's decision; undoing a file
+	// resets the file and all its hunks.
+	decision, note := model.DecisionPending, ""
+	if req.HunkIndex != nil {
+		s.review.Decisions.ClearHunk(req.FileIndex, *req.HunkIndex)
+		decision = s.review.Decisions.Files[req.FileIndex]
+		note = s.review.Decisions.Note(req.FileIndex, model.WholeFile)
+	} else {
+		s.review.Decisions.ClearFile(req.FileIndex)
+	}
+	e := audit.Event{Action: audit.ActionUndo, File: s.review.Diff.Files[req.FileIndex].Name()}
+	if req.HunkIndex != nil {
+		e.Hunk = *req.HunkIndex + 1
+	}
+	logAction(e)
+
+	return wsDecisionResponse{
+		FileIndex: req.FileIndex,
+		HunkIndex: req.HunkIndex,
+		Decision:  decision.String(),
+		Note:      note,
+	}
+}
+
+// handleWSSplit splits a hunk at the unchanged lines between its runs of
+// changes, so they can be decided one by one.
+func handleWSSplit(conn *wsConn, session *reviewSession, data json.RawMessage) {
+	if session.review == nil {
+		sendWSError(conn, "no diff loaded")
+		return
+	}
+
+	var req wsDecisionMsg
+	if err := json.Unmarshal(data, &req); err != nil {
+		sendWSError(conn, "invalid split data")
+		return
+	}
+	if req.HunkIndex == nil {
+		sendWSError(conn, "hunk_index is required")
+		return
+	}
+	if msg := session.checkTarget(req); msg != "" {
+		sendWSError(conn, msg)
+		return
+	}
 
-	delete(session.decisions, req.FileIndex)
+	f := session.review.Diff.Files[req.FileIndex]
+	hunk := *req.HunkIndex
+	n := session.review.SplitHunk(req.FileIndex, hunk)
+	if n < 2 {
+		sendWSError(conn, "hunk can't be split")
+		return
+	}
 
-	sendWSMessage(conn, wsMsgDecision, wsDecisionResponse{
+	session.broadcast(wsMsgSplitHunk, wsSplitResponse{
 		FileIndex: req.FileIndex,
-		Decision:  "pending",
+		HunkIndex: hunk,
+		Hunks:     n,
+		File:      newFileJSON(f),
+		Reviewer:  session.reviewer(conn),
 	})
 }
 
-func handleWSFinish(conn *websocket.Conn, session *reviewSession) {
-	if session.ds == nil {
+// handleWSTriage records what the reviewer concluded about a finding in
+// the repository's .agrev/findings.json. The finding keeps its place and
+// risk in this session; later runs suppress or down-rank it.
+func handleWSTriage(conn *wsConn, session *reviewSession, data json.RawMessage) {
+	if session.review == nil {
 		sendWSError(conn, "no diff loaded")
 		return
 	}
+	if session.review.Results == nil {
+		sendWSError(conn, "no analysis results")
+		return
+	}
+
+	var req wsTriageMsg
+	if err := json.Unmarshal(data, &req); err != nil {
+		sendWSError(conn, "invalid triage data")
+		return
+	}
+	state, ok := model.ParseFindingState(req.State)
+	if !ok {
+		sendWSError(conn, "unknown finding state: "+req.State)
+		return
+	}
+	if session.triage == nil {
+		sendWSError(conn, "triaging findings needs the diff loaded with a repo_dir")
+		return
+	}
+
+	found := false
+	for _, list := range [][]analysis.Finding{session.review.Results.Findings, session.review.Results.Suppressed} {
+		for i := range list {
+			if list[i].Fingerprint() != req.Fingerprint {
+				continue
+			}
+			if !found {
+				session.triage.Set(list[i], state, session.reviewer(conn))
+				session.logAction(conn, audit.Event{Action: audit.ActionTriage, File: list[i].File, Line: list[i].Line, Detail: state.String() + ": " + list[i].Message})
+			}
+			list[i].State = state
+			found = true
+		}
+	}
+	if !found {
+		sendWSError(conn, "no finding with that fingerprint")
+		return
+	}
+	if err := session.triage.Save(session.repoDir); err != nil {
+		conn.log.Warn("saving triaged findings", "error", err)
+		sendWSError(conn, err.Error())
+		return
+	}
+
+	session.broadcast(wsMsgTriaged, wsTriageResponse{
+		Fingerprint: req.Fingerprint,
+		State:       state.String(),
+		Reviewer:    session.reviewer(conn),
+	})
+}
 
-	var approved, rejected, pending int
-	var files []wsFileDecision
+// handleWSLabel attaches labels to a file or hunk, or with add unset
+// removes them, and tells everyone in the session the labels now on it.
+func handleWSLabel(conn *wsConn, session *reviewSession, data json.RawMessage, add bool) {
+	if session.review == nil {
+		sendWSError(conn, "no diff loaded")
+		return
+	}
 
-	for i, f := range session.ds.Files {
-		fd := wsFileDecision{Name: f.Name()}
-		switch session.decisions[i] {
-		case model.DecisionApproved:
-			fd.Decision = "approved"
+	var req wsLabelMsg
+	if err := json.Unmarshal(data, &req); err != nil {
+		sendWSError(conn, "invalid label data")
+		return
+	}
+	if msg := session.checkTarget(wsDecisionMsg{FileIndex: req.FileIndex, HunkIndex: req.HunkIndex}); msg != "" {
+		sendWSError(conn, msg)
+		return
+	}
+	if len(req.Labels) == 0 {
+		sendWSError(conn, "labels are required")
+		return
+	}
+
+	hunk := model.WholeFile
+	if req.HunkIndex != nil {
+		hunk = *req.HunkIndex
+	}
+	d := session.review.Decisions
+	e := audit.Event{Action: audit.ActionLabel, File: session.review.Diff.Files[req.FileIndex].Name(), Hunk: hunk + 1}
+	if !add {
+		e.Action = audit.ActionUnlabel
+	}
+	for _, l := range req.Labels {
+		changed := false
+		if add {
+			changed = d.AddLabel(req.FileIndex, hunk, l)
+		} else {
+			changed = d.RemoveLabel(req.FileIndex, hunk, l)
+		}
+		if changed {
+			e.Detail = model.NormalizeLabel(l)
+			session.logAction(conn, e)
+		}
+	}
+
+	session.broadcast(wsMsgLabels, wsLabelsResponse{
+		FileIndex: req.FileIndex,
+		HunkIndex: req.HunkIndex,
+		Labels:    d.Label(req.FileIndex, hunk),
+		Reviewer:  session.reviewer(conn),
+	})
+}
+
+// checkTarget validates the file and hunk a decision refers to, returning
+// an error message or "".
+func (s *reviewSession) checkTarget(req wsDecisionMsg) string {
+	if req.FileIndex < 0 || req.FileIndex >= len(s.review.Diff.Files) {
+		return "file_index out of range"
+	}
+	if req.HunkIndex != nil && (*req.HunkIndex < 0 || *req.HunkIndex >= len(s.review.Diff.Files[req.FileIndex].Fragments)) {
+		return "hunk_index out of range"
+	}
+	return ""
+}
+
+func handleWSComment(conn *wsConn, session *reviewSession, data json.RawMessage) {
+	if session.review == nil {
+		sendWSError(conn, "no diff loaded")
+		return
+	}
+
+	var req wsCommentMsg
+	if err := json.Unmarshal(data, &req); err != nil {
+		sendWSError(conn, "invalid comment data")
+		return
+	}
+
+	if req.FileIndex < 0 || req.FileIndex >= len(session.review.Diff.Files) {
+		sendWSError(conn, "file_index out of range")
+		return
+	}
+	if req.Body == "" {
+		sendWSError(conn, "comment body is required")
+		return
+	}
+
+	c := newComment(session.review.Diff.Files[req.FileIndex], req.Line, req.Body, session.reviewer(conn))
+	if req.HunkIndex != nil {
+		if *req.HunkIndex < 0 || *req.HunkIndex >= len(session.review.Diff.Files[req.FileIndex].Fragments) {
+			sendWSError(conn, "hunk_index out of range")
+			return
+		}
+		c.Hunk = *req.HunkIndex + 1
+	}
+	session.review.Comments = append(session.review.Comments, c)
+	session.logAction(conn, audit.Event{Action: audit.ActionComment, File: c.File, Line: c.Line, Hunk: c.Hunk, Detail: c.Body})
+
+	session.broadcast(wsMsgComments, commentsJSON(session.review.Comments))
+}
+
+func handleWSFinish(conn *wsConn, session *reviewSession) {
+	if session.review == nil {
+		sendWSError(conn, "no diff loaded")
+		return
+	}
+
+	var approved, rejected, partial, pending int
+	state := session.stateResponse()
+	for _, f := range state.Files {
+		switch f.Decision {
+		case "approved":
 			approved++
-		case model.DecisionRejected:
-			fd.Decision = "rejected"
+		case "rejected":
 			rejected++
+		case "partial":
+			partial++
 		default:
-			fd.Decision = "pending"
 			pending++
 		}
-		files = append(files, fd)
 	}
 
+	conn.log.Info("review finished", "approved", approved, "rejected", rejected, "partial", partial, "pending", pending)
 	sendWSMessage(conn, wsMsgSummary, wsSummaryResponse{
 		Approved: approved,
 		Rejected: rejected,
+		Partial:  partial,
 		Pending:  pending,
-		Files:    files,
+		Files:    state.Files,
+		Comments: state.Comments,
 	})
 }
 
-func sendWSMessage(conn *websocket.Conn, msgType string, data any) {
+func sendWSMessage(conn *wsConn, msgType string, data any) {
 	raw, err := json.Marshal(data)
 	if err != nil {
-		log.Printf("ws marshal: %v", err)
+		conn.log.Error("websocket marshal", "type", msgType, "error", err)
 		return
 	}
 	msg := wsMessage{Type: msgType, Data: raw}
+	conn.wmu.Lock()
+	defer conn.wmu.Unlock()
+	conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
 	if err := conn.WriteJSON(msg); err != nil {
-		log.Printf("ws write: %v", err)
+		conn.log.Warn("websocket write", "type", msgType, "error", err)
+	}
+}
+
+// sendWSClose tells the client the server is going away. The read loop
+// ends when the client echoes the close frame.
+func sendWSClose(conn *wsConn) {
+	msg := websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutting down")
+	if err := conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(time.Second)); err != nil {
+		conn.log.Debug("websocket close", "error", err)
 	}
 }
 
-func sendWSError(conn *websocket.Conn, errMsg string) {
-	sendWSMessage(conn, wsMsgError, map[string]string{"message": errMsg})
+func sendWSError(conn *wsConn, errMsg string) {
+	conn.log.Debug("websocket client error", "message", errMsg)
+	sendWSMessage(conn, wsMsgError, wsErrorResponse{Message: errMsg})
 }
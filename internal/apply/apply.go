@@ -0,0 +1,342 @@
+// Package apply applies patches to a directory tree without git, for
+// systems that don't have it and for hunks edited during review, which need
+// to land exactly where they say.
+package apply
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/bluekeyes/go-gitdiff/gitdiff"
+	"github.com/aezell/agrev/internal/diff"
+)
+
+// Options control how a patch is applied.
+type Options struct {
+	// Fuzz is how many context lines at each end of a hunk may be ignored
+	// when the whole hunk doesn't match, as with patch -F. Zero requires
+	// every line to match, as git apply does.
+	Fuzz int
+
+	// DryRun checks that the patch applies without changing any files.
+	DryRun bool
+}
+
+// FileResult describes how a file was changed.
+type FileResult struct {
+	Path    string // after the change; empty when the file was deleted
+	OldPath string // before the change; empty when the file was created
+	Hunks   []HunkResult
+}
+
+// HunkResult records where a hunk was applied.
+type HunkResult struct {
+	Line   int // first line of the hunk in the file before the change
+	Offset int // lines away from where the patch placed it
+	Fuzz   int // context lines ignored at each end
+}
+
+// ConflictError reports a hunk that doesn't match the file it changes.
+type ConflictError struct {
+	File string
+	Hunk int // counting from 1
+	Line int // where the patch placed it
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("%s: hunk %d does not apply at line %d", e.File, e.Hunk, e.Line)
+}
+
+// file is the content of a path as the patch leaves it so far.
+type file struct {
+	data   []byte
+	mode   fs.FileMode
+	exists bool
+}
+
+// symlinkMode is the mode git records for a symbolic link.
+const symlinkMode = 0o120000
+
+// Apply applies the changes in files to the tree at dir. Every file is
+// patched in memory first, so a patch that doesn't apply anywhere changes
+// nothing. Binary, submodule, and symlink changes are refused, since a diff
+// doesn't carry what they need or they aren't files. Paths are resolved
+// within dir, so one leading out of it through a symlink is refused too.
+func Apply(dir string, files []*diff.File, opts Options) ([]FileResult, error) {
+	root, err := os.OpenRoot(dir)
+	if err != nil {
+		return nil, err
+	}
+	defer root.Close()
+
+	t := &tree{root: root, files: make(map[string]*file)}
+	var results []FileResult
+	for _, f := range files {
+		r, err := t.patch(f, opts.Fuzz)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, r)
+	}
+	if opts.DryRun {
+		return results, nil
+	}
+	if err := t.write(); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// tree tracks the files a patch touches, so a file changed twice sees the
+// first change.
+type tree struct {
+	root  *os.Root
+	files map[string]*file
+	order []string // paths in the order they were first touched
+}
+
+// get returns the current state of path, reading it from disk the first
+// time.
+func (t *tree) get(path string) (*file, error) {
+	if f, ok := t.files[path]; ok {
+		return f, nil
+	}
+	f := &file{mode: 0o644}
+	name := filepath.FromSlash(path)
+	info, err := t.root.Lstat(name)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+	case err != nil:
+		return nil, err
+	case !info.Mode().IsRegular():
+		return nil, fmt.Errorf("%s: not a regular file", path)
+	default:
+		if f.data, err = t.root.ReadFile(name); err != nil {
+			return nil, err
+		}
+		f.mode, f.exists = info.Mode().Perm(), true
+	}
+	t.files[path] = f
+	t.order = append(t.order, path)
+	return f, nil
+}
+
+// patch applies the changes to one file in memory.
+func (t *tree) patch(f *diff.File, fuzz int) (FileResult, error) {
+	name := f.Name()
+	switch {
+	case f.IsBinary:
+		return FileResult{}, fmt.Errorf("%s: binary changes can't be applied without their contents", name)
+	case f.IsSubmodule:
+		return FileResult{}, fmt.Errorf("%s: submodule changes can't be applied outside git", name)
+	case f.OldMode == symlinkMode || f.NewMode == symlinkMode:
+		return FileResult{}, fmt.Errorf("%s: symlink changes can't be applied outside git", name)
+	}
+	for _, p := range []string{f.OldName, f.NewName} {
+		if p != "" && !filepath.IsLocal(filepath.FromSlash(p)) {
+			return FileResult{}, fmt.Errorf("%s: path is outside the tree", p)
+		}
+	}
+
+	r := FileResult{OldPath: f.OldName, Path: f.NewName}
+	var src *file
+	if f.IsNew {
+		r.OldPath = ""
+		src = &file{mode: 0o644}
+		if dst, err := t.get(f.NewName); err != nil {
+			return r, err
+		} else if dst.exists {
+			return r, fmt.Errorf("%s: already exists", f.NewName)
+		}
+	} else {
+		var err error
+		if src, err = t.get(f.OldName); err != nil {
+			return r, err
+		}
+		if !src.exists {
+			return r, fmt.Errorf("%s: no such file", f.OldName)
+		}
+	}
+
+	lines := strings.SplitAfter(string(src.data), "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	out, hunks, err := applyHunks(lines, f.Fragments, fuzz)
+	if err != nil {
+		var conflict *ConflictError
+		if errors.As(err, &conflict) {
+			conflict.File = name
+		}
+		return r, err
+	}
+	r.Hunks = hunks
+
+	if f.IsDeleted {
+		if len(out) > 0 {
+			return r, fmt.Errorf("%s: has lines the patch doesn't delete", f.OldName)
+		}
+		r.Path = ""
+		src.data, src.exists = nil, false
+		return r, nil
+	}
+
+	// A rename or copy writes the result under the new name, and a copy
+	// leaves the original as it was
+	dst := src
+	if f.IsRenamed || f.IsCopy || f.IsNew {
+		if !f.IsNew {
+			if existing, err := t.get(f.NewName); err != nil {
+				return r, err
+			} else if existing.exists {
+				return r, fmt.Errorf("%s: already exists", f.NewName)
+			}
+		}
+		if f.IsRenamed {
+			src.exists = false
+		}
+		if dst, err = t.get(f.NewName); err != nil {
+			return r, err
+		}
+		dst.mode = src.mode
+	}
+	if f.NewMode != 0 && (f.IsNew || f.NewMode != f.OldMode) {
+		dst.mode = perm(f.NewMode)
+	}
+	dst.data, dst.exists = []byte(strings.Join(out, "")), true
+	return r, nil
+}
+
+// perm returns the permissions of a file with git mode m, which only
+// records whether it is executable.
+func perm(m fs.FileMode) fs.FileMode {
+	if m&0o111 != 0 {
+		return 0o755
+	}
+	return 0o644
+}
+
+// applyHunks applies frags to lines, which keep their line endings. Each
+// hunk is looked for where the patch places it, shifted by how far the
+// previous hunk moved, then further away in both directions.
+func applyHunks(lines []string, frags []*gitdiff.TextFragment, fuzz int) ([]string, []HunkResult, error) {
+	var out []string
+	var results []HunkResult
+	pos := 0    // lines of the original already copied or replaced
+	offset := 0 // how far the last hunk was from where the patch put it
+	for h, frag := range frags {
+		var old, new []string
+		for _, l := range frag.Lines {
+			if l.Op != gitdiff.OpAdd {
+				old = append(old, l.Line)
+			}
+			if l.Op != gitdiff.OpDelete {
+				new = append(new, l.Line)
+			}
+		}
+		lead, trail := contextAt(frag)
+
+		// A hunk without old lines inserts after line OldPosition
+		want := int(frag.OldPosition) - 1
+		if len(old) == 0 {
+			want++
+		}
+
+		found := false
+		for f := 0; f <= fuzz && !found; f++ {
+			l, t := min(f, lead), min(f, trail)
+			if f > 0 && (l+t == 0 || l+t >= len(old)) {
+				break // nothing left to ignore, or nothing left to match
+			}
+			at, ok := locate(lines, pos, want+offset+l, old[l:len(old)-t])
+			if !ok {
+				continue
+			}
+			out = append(out, lines[pos:at]...)
+			out = append(out, new[l:len(new)-t]...)
+			pos = at + len(old) - l - t
+			offset = at - l - want
+			results = append(results, HunkResult{Line: at - l + 1, Offset: offset, Fuzz: max(l, t)})
+			found = true
+		}
+		if !found {
+			return nil, nil, &ConflictError{Hunk: h + 1, Line: int(frag.OldPosition)}
+		}
+	}
+	return append(out, lines[pos:]...), results, nil
+}
+
+// contextAt counts the context lines at the start and end of a hunk.
+func contextAt(frag *gitdiff.TextFragment) (lead, trail int) {
+	for lead < len(frag.Lines) && frag.Lines[lead].Op == gitdiff.OpContext {
+		lead++
+	}
+	for trail < len(frag.Lines)-lead && frag.Lines[len(frag.Lines)-1-trail].Op == gitdiff.OpContext {
+		trail++
+	}
+	return lead, trail
+}
+
+// locate finds old in lines at or after pos, trying want first and then
+// each line either side of it, nearest first.
+func locate(lines []string, pos, want int, old []string) (int, bool) {
+	last := len(lines) - len(old)
+	if len(old) == 0 {
+		// Nothing to match: an insertion goes exactly where it says
+		return want, want >= pos && want <= len(lines)
+	}
+	for d := 0; want-d >= pos || want+d <= last; d++ {
+		for _, at := range []int{want - d, want + d} {
+			if at >= pos && at <= last && matches(lines[at:at+len(old)], old) {
+				return at, true
+			}
+			if d == 0 {
+				break
+			}
+		}
+	}
+	return 0, false
+}
+
+func matches(lines, old []string) bool {
+	for i := range old {
+		if lines[i] != old[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// write saves the patched files, removing deleted ones along with the
+// directories they leave empty.
+func (t *tree) write() error {
+	for _, path := range t.order {
+		f := t.files[path]
+		name := filepath.FromSlash(path)
+		if f.exists {
+			if err := t.root.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+				return err
+			}
+			if err := t.root.WriteFile(name, f.data, f.mode); err != nil {
+				return err
+			}
+			if err := t.root.Chmod(name, f.mode); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := t.root.Remove(name); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		for d := filepath.Dir(name); d != "."; d = filepath.Dir(d) {
+			if t.root.Remove(d) != nil {
+				break
+			}
+		}
+	}
+	return nil
+}
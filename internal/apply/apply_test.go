@@ -0,0 +1,325 @@
+package apply
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aezell/agrev/internal/diff"
+)
+
+func writeTree(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func readTree(t *testing.T, dir string) map[string]string {
+	t.Helper()
+	files := map[string]string{}
+	filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rel, _ := filepath.Rel(dir, path)
+		files[filepath.ToSlash(rel)] = string(data)
+		return nil
+	})
+	return files
+}
+
+func parse(t *testing.T, patch string) []*diff.File {
+	t.Helper()
+	ds, err := diff.Parse(patch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ds.Files
+}
+
+const patch = `diff --git a/main.go b/main.go
+index abc1234..def5678 100644
+--- a/main.go
++++ b/main.go
+@@ -1,4 +1,4 @@
+ package main
+
+ func main() {
+-	println("hello")
++	println("hello, world")
+diff --git a/old.txt b/old.txt
+deleted file mode 100644
+--- a/old.txt
++++ /dev/null
+@@ -1,2 +0,0 @@
+-one
+-two
+diff --git a/docs/new.md b/docs/new.md
+new file mode 100644
+--- /dev/null
++++ b/docs/new.md
+@@ -0,0 +1,2 @@
++# New
++no newline
+\ No newline at end of file
+diff --git a/a.txt b/lib/b.txt
+similarity index 80%
+rename from a.txt
+rename to lib/b.txt
+--- a/a.txt
++++ b/lib/b.txt
+@@ -1,3 +1,3 @@
+ x
+-y
++why
+ z
+`
+
+func TestApply(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		// Two lines the patch doesn't know about move main.go's hunk down
+		"main.go":      "// Code\n\npackage main\n\nfunc main() {\n\tprintln(\"hello\")\n}\n",
+		"old.txt":      "one\ntwo\n",
+		"a.txt":        "x\ny\nz\n",
+		"gone/only.go": "package gone\n",
+	})
+
+	results, err := Apply(dir, parse(t, patch+`diff --git a/gone/only.go b/gone/only.go
+deleted file mode 100644
+--- a/gone/only.go
++++ /dev/null
+@@ -1 +0,0 @@
+-package gone
+`), Options{})
+	if err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+	if h := results[0].Hunks; len(h) != 1 || h[0].Offset != 2 || h[0].Line != 3 || h[0].Fuzz != 0 {
+		t.Errorf("main.go hunks = %+v, want offset 2 at line 3", h)
+	}
+	if results[1].Path != "" || results[2].OldPath != "" {
+		t.Errorf("unexpected results %+v", results)
+	}
+
+	want := map[string]string{
+		"main.go":     "// Code\n\npackage main\n\nfunc main() {\n\tprintln(\"hello, world\")\n}\n",
+		"docs/new.md": "# New\nno newline",
+		"lib/b.txt":   "x\nwhy\nz\n",
+	}
+	if got := readTree(t, dir); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("tree after Apply:\n%v\nwant:\n%v", got, want)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "gone")); !os.IsNotExist(err) {
+		t.Errorf("directory emptied by the patch was kept: %v", err)
+	}
+}
+
+func TestApplyConflicts(t *testing.T) {
+	files := map[string]string{
+		"main.go": "package main\n\nfunc main() {\n\tprintln(\"bye\")\n",
+		"old.txt": "one\ntwo\n",
+		"a.txt":   "x\ny\nz\n",
+	}
+	dir := t.TempDir()
+	writeTree(t, dir, files)
+
+	// main.go doesn't match, so nothing is written, even where it would apply
+	_, err := Apply(dir, parse(t, patch), Options{})
+	var conflict *ConflictError
+	if !errors.As(err, &conflict) || conflict.File != "main.go" || conflict.Hunk != 1 || conflict.Line != 1 {
+		t.Fatalf("expected a conflict in main.go hunk 1, got %v", err)
+	}
+	if got := readTree(t, dir); fmt.Sprint(got) != fmt.Sprint(files) {
+		t.Errorf("a patch that doesn't apply changed the tree:\n%v", got)
+	}
+
+	cases := []struct {
+		name, patch, want string
+	}{
+		{"new file exists", "diff --git a/a.txt b/a.txt\nnew file mode 100644\n--- /dev/null\n+++ b/a.txt\n@@ -0,0 +1 @@\n+a\n", "a.txt: already exists"},
+		{"missing file", "diff --git a/nope.txt b/nope.txt\n--- a/nope.txt\n+++ b/nope.txt\n@@ -1 +1 @@\n-a\n+b\n", "nope.txt: no such file"},
+		{"deletion leaves lines", "diff --git a/a.txt b/a.txt\ndeleted file mode 100644\n--- a/a.txt\n+++ /dev/null\n@@ -1,2 +0,0 @@\n-x\n-y\n", "doesn't delete"},
+		{"outside the tree", "diff --git a/../evil b/../evil\nnew file mode 100644\n--- /dev/null\n+++ b/../evil\n@@ -0,0 +1 @@\n+a\n", "outside the tree"},
+		{"binary", "diff --git a/img.png b/img.png\nindex 1111111..2222222 100644\nBinary files a/img.png and b/img.png differ\n", "binary"},
+		{"symlink", "diff --git a/link b/link\nnew file mode 120000\n--- /dev/null\n+++ b/link\n@@ -0,0 +1 @@\n+a.txt\n\\ No newline at end of file\n", "symlink"},
+	}
+	for _, c := range cases {
+		if _, err := Apply(dir, parse(t, c.patch), Options{}); err == nil || !strings.Contains(err.Error(), c.want) {
+			t.Errorf("%s: got %v, want %q", c.name, err, c.want)
+		}
+	}
+}
+
+func TestApplyModesAndCopies(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{"run.sh": "echo hi\n", "a.txt": "x\ny\nz\n"})
+
+	results, err := Apply(dir, parse(t, `diff --git a/new.sh b/new.sh
+new file mode 100755
+--- /dev/null
++++ b/new.sh
+@@ -0,0 +1 @@
++echo new
+diff --git a/run.sh b/run.sh
+old mode 100644
+new mode 100755
+diff --git a/a.txt b/c.txt
+similarity index 80%
+copy from a.txt
+copy to c.txt
+--- a/a.txt
++++ b/c.txt
+@@ -1,3 +1,3 @@
+ x
+-y
++why
+ z
+`), Options{})
+	if err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+	if len(results) != 3 || results[2].OldPath != "a.txt" || results[2].Path != "c.txt" {
+		t.Errorf("unexpected results %+v", results)
+	}
+	for name, want := range map[string]os.FileMode{"new.sh": 0o755, "run.sh": 0o755, "a.txt": 0o644, "c.txt": 0o644} {
+		if info, err := os.Stat(filepath.Join(dir, name)); err != nil || info.Mode().Perm() != want {
+			t.Errorf("%s: mode %v (%v), want %v", name, info.Mode().Perm(), err, want)
+		}
+	}
+
+	// The copy is patched and the original left alone
+	want := map[string]string{"new.sh": "echo new\n", "run.sh": "echo hi\n", "a.txt": "x\ny\nz\n", "c.txt": "x\nwhy\nz\n"}
+	if got := readTree(t, dir); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("tree after Apply:\n%v\nwant:\n%v", got, want)
+	}
+}
+
+func TestApplySymlinkedParent(t *testing.T) {
+	dir, outside := t.TempDir(), t.TempDir()
+	writeTree(t, outside, map[string]string{"x.txt": "x\n"})
+	if err := os.Symlink(outside, filepath.Join(dir, "link")); err != nil {
+		t.Skipf("symlinks unsupported: %v", err)
+	}
+
+	for _, p := range []string{
+		"diff --git a/link/new.txt b/link/new.txt\nnew file mode 100644\n--- /dev/null\n+++ b/link/new.txt\n@@ -0,0 +1 @@\n+a\n",
+		"diff --git a/link/x.txt b/link/x.txt\n--- a/link/x.txt\n+++ b/link/x.txt\n@@ -1 +1 @@\n-x\n+y\n",
+	} {
+		if _, err := Apply(dir, parse(t, p), Options{}); err == nil || !strings.Contains(err.Error(), "escapes") {
+			t.Errorf("expected a write through the symlink to be refused, got %v", err)
+		}
+	}
+	if got := readTree(t, outside); fmt.Sprint(got) != fmt.Sprint(map[string]string{"x.txt": "x\n"}) {
+		t.Errorf("a patch wrote outside the tree: %v", got)
+	}
+}
+
+func TestApplyFuzzAndDryRun(t *testing.T) {
+	dir := t.TempDir()
+	// The last context line of the hunk has changed since the patch was made
+	writeTree(t, dir, map[string]string{"a.txt": "1\n2\n3\nfour\n5\n6\n7 changed\n"})
+	p := "diff --git a/a.txt b/a.txt\n--- a/a.txt\n+++ b/a.txt\n@@ -3,5 +3,5 @@\n 3\n-4\n+four\n 5\n 6\n 7\n"
+	p = strings.Replace(p, "-4\n+four\n", "-four\n+4\n", 1)
+
+	if _, err := Apply(dir, parse(t, p), Options{}); err == nil {
+		t.Fatal("expected a conflict without fuzz")
+	}
+	results, err := Apply(dir, parse(t, p), Options{Fuzz: 1, DryRun: true})
+	if err != nil {
+		t.Fatalf("Apply with fuzz: %v", err)
+	}
+	if h := results[0].Hunks[0]; h.Fuzz != 1 || h.Offset != 0 {
+		t.Errorf("hunk = %+v, want fuzz 1", h)
+	}
+	if got := readTree(t, dir)["a.txt"]; got != "1\n2\n3\nfour\n5\n6\n7 changed\n" {
+		t.Errorf("dry run changed the file:\n%s", got)
+	}
+
+	if _, err := Apply(dir, parse(t, p), Options{Fuzz: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if got := readTree(t, dir)["a.txt"]; got != "1\n2\n3\n4\n5\n6\n7 changed\n" {
+		t.Errorf("fuzzy apply gave:\n%s", got)
+	}
+}
+
+// TestApplyRoundTrip applies diffs of random edits to the files they were
+// made from, which must give the edited files exactly.
+func TestApplyRoundTrip(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	words := []string{"a", "b", "c", "", "}", "return"}
+	randomFile := func() string {
+		var b strings.Builder
+		for range rng.Intn(30) {
+			b.WriteString(words[rng.Intn(len(words))] + "\n")
+		}
+		s := b.String()
+		if s != "" && rng.Intn(5) == 0 {
+			s = strings.TrimSuffix(s, "\n")
+		}
+		return s
+	}
+	edit := func(s string) string {
+		lines := strings.SplitAfter(s, "\n")
+		for range rng.Intn(4) {
+			i := rng.Intn(len(lines) + 1)
+			switch rng.Intn(3) {
+			case 0:
+				lines = append(lines[:i], append([]string{words[rng.Intn(len(words))] + "\n"}, lines[i:]...)...)
+			case 1:
+				if i < len(lines) {
+					lines = append(lines[:i], lines[i+1:]...)
+				}
+			default:
+				if i < len(lines) && strings.HasSuffix(lines[i], "\n") {
+					lines[i] = "x" + lines[i]
+				}
+			}
+		}
+		return strings.Join(lines, "")
+	}
+
+	for iter := range 200 {
+		oldDir, newDir := t.TempDir(), t.TempDir()
+		oldFiles, newFiles := map[string]string{}, map[string]string{}
+		for i := range 3 {
+			name := fmt.Sprintf("d%d/f%d.txt", i%2, i)
+			content := randomFile()
+			if rng.Intn(6) > 0 {
+				oldFiles[name] = content
+			}
+			if rng.Intn(6) > 0 {
+				newFiles[name] = edit(content)
+			}
+		}
+		writeTree(t, oldDir, oldFiles)
+		writeTree(t, newDir, newFiles)
+
+		raw, err := diff.DiffDirs(oldDir, newDir, rng.Intn(4), false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := Apply(oldDir, parse(t, raw), Options{}); err != nil {
+			t.Fatalf("iteration %d: %v\n%s", iter, err, raw)
+		}
+		if got, want := readTree(t, oldDir), readTree(t, newDir); fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Fatalf("iteration %d: got %q, want %q\n%s", iter, got, want, raw)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+// Package audit keeps an append-only record of what reviewers did, in
+// .agrev/audit.jsonl: every decision, note, label, undo, edit, and comment,
+// with when it happened and who did it, so teams can show who approved
+// which agent-generated change. Unlike the saved session, which holds only
+// where the review stands, the log is never rewritten.
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/aezell/agrev/internal/history"
+)
+
+// FileName is the audit log inside history.Dir.
+const FileName = "audit.jsonl"
+
+// Actions recorded in the log.
+const (
+	ActionApprove  = "approve"
+	ActionReject   = "reject"
+	ActionNote     = "note"      // why a file or hunk was rejected
+	ActionFollowUp = "follow_up" // a follow-up an approval was given on
+	ActionUndo     = "undo"
+	ActionRedo     = "redo"
+	ActionComment  = "comment"
+	ActionEdit     = "edit" // the file was opened in an editor
+	ActionCheck    = "check"
+	ActionUncheck  = "uncheck"
+	ActionTriage   = "triage"
+	ActionLabel    = "label"
+	ActionUnlabel  = "unlabel"
+)
+
+// Event is one reviewer action.
+type Event struct {
+	Time     time.Time `json:"time"`
+	Reviewer string    `json:"reviewer"`
+	Source   string    `json:"source" enum:"tui,api,lsp,editor"`
+	Range    string    `json:"range,omitempty"` // the change under review
+	Action   string    `json:"action" enum:"approve,reject,note,follow_up,undo,redo,comment,edit,check,uncheck,triage,label,unlabel"`
+	File     string    `json:"file,omitempty"`
+	Hunk     int       `json:"hunk,omitempty"` // 1-based; 0 for the whole file
+	Line     int       `json:"line,omitempty"`
+	Bulk     bool      `json:"bulk,omitempty"`   // decided along with other files
+	Detail   string    `json:"detail,omitempty"` // the note, comment, label, or checklist item; what was undone; why a bulk decision was made
+}
+
+// Log appends events to a repository's audit log. A nil Log discards them.
+type Log struct {
+	repoDir  string
+	Source   string
+	Reviewer string // for events that don't name one
+	Range    string
+}
+
+// New returns the audit log of a repository, for events from source.
+func New(repoDir, source string) *Log {
+	return &Log{repoDir: repoDir, Source: source}
+}
+
+// Path returns the audit log of a repository.
+func Path(repoDir string) string {
+	return filepath.Join(repoDir, history.Dir, FileName)
+}
+
+// Write appends e, stamped with the time and the log's source, and its
+// reviewer and range unless e has them.
+func (l *Log) Write(e Event) error {
+	if l == nil {
+		return nil
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	e.Source = l.Source
+	if e.Reviewer == "" {
+		e.Reviewer = l.Reviewer
+	}
+	if e.Range == "" {
+		e.Range = l.Range
+	}
+
+	if _, err := history.MakeDir(l.repoDir); err != nil {
+		return err
+	}
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(Path(l.repoDir), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("opening audit log: %w", err)
+	}
+	defer f.Close()
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("writing audit log: %w", err)
+	}
+	return nil
+}
+
+// Load reads the repository's audit log in the order it was written. A
+// missing file yields no events; malformed lines are skipped.
+func Load(repoDir string) ([]Event, error) {
+	f, err := os.Open(Path(repoDir))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading audit log: %w", err)
+	}
+	defer f.Close()
+
+	var events []Event
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var e Event
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			continue
+		}
+		events = append(events, e)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading audit log: %w", err)
+	}
+	return events, nil
+}
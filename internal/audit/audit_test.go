@@ -0,0 +1,36 @@
+package audit
+
+import (
+	"testing"
+)
+
+func TestWriteLoad(t *testing.T) {
+	dir := t.TempDir()
+	if events, err := Load(dir); events != nil || err != nil {
+		t.Fatalf("expected no log, got %+v, %v", events, err)
+	}
+
+	l := New(dir, "tui")
+	l.Reviewer, l.Range = "Ada <ada@example.com>", "1a2b3c4..5d6e7f8"
+	if err := l.Write(Event{Action: ActionApprove, File: "auth.go"}); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	// Another process appends to the same log
+	if err := New(dir, "api").Write(Event{Action: ActionReject, File: "auth.go", Hunk: 2, Reviewer: "bob", Detail: "keep the check"}); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if err := (*Log)(nil).Write(Event{Action: ActionUndo}); err != nil {
+		t.Errorf("expected a nil log to discard events, got %v", err)
+	}
+
+	events, err := Load(dir)
+	if err != nil || len(events) != 2 {
+		t.Fatalf("expected two events, got %+v, %v", events, err)
+	}
+	if e := events[0]; e.Time.IsZero() || e.Source != "tui" || e.Reviewer != "Ada <ada@example.com>" || e.Range != "1a2b3c4..5d6e7f8" || e.Action != "approve" {
+		t.Errorf("unexpected first event %+v", e)
+	}
+	if e := events[1]; e.Source != "api" || e.Reviewer != "bob" || e.Hunk != 2 || e.Detail != "keep the check" {
+		t.Errorf("unexpected second event %+v", e)
+	}
+}
@@ -0,0 +1,132 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/aezell/agrev/internal/apply"
+	"github.com/aezell/agrev/internal/diff"
+)
+
+var applyCmd = &cobra.Command{
+	Use:   "apply <patch-file | ->",
+	Short: "Stage an approved patch in the git index",
+	Long: `Apply a patch written by 'agrev review --output-patch' to the git index
+with 'git apply --cached', so the approved changes are staged and ready to
+commit. Pass "-" to read the patch from stdin.
+
+With --worktree, or outside a git repository, the patch is applied to the
+files themselves without git. Hunks may have moved since the patch was
+made; --fuzz also lets context lines at their edges differ. If any hunk
+doesn't apply, no file is changed.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runApply,
+}
+
+func init() {
+	applyCmd.Flags().Bool("check", false, "only check that the patch applies cleanly")
+	applyCmd.Flags().Bool("worktree", false, "apply to the working tree instead of the index")
+	applyCmd.Flags().Int("fuzz", 0, "context lines at each end of a hunk that may differ (working tree only)")
+}
+
+func runApply(cmd *cobra.Command, args []string) error {
+	data, err := readPatch(cmd, args[0])
+	if err != nil {
+		return err
+	}
+
+	check, _ := cmd.Flags().GetBool("check")
+	worktree, _ := cmd.Flags().GetBool("worktree")
+	fuzz, _ := cmd.Flags().GetInt("fuzz")
+
+	ds, err := diff.Parse(string(data))
+	if err != nil {
+		return err
+	}
+	if len(ds.Files) == 0 {
+		return fmt.Errorf("%s contains no changes", args[0])
+	}
+
+	// Without git there is no index, only the working tree
+	repoDir, err := gitRepoRoot()
+	if err != nil {
+		if !worktree {
+			fmt.Fprintln(os.Stderr, "Not in a git repository; applying to the working tree")
+		}
+		worktree, repoDir = true, "."
+	}
+
+	if worktree {
+		if err := applyWorktree(repoDir, ds, check, fuzz); err != nil {
+			return err
+		}
+	} else {
+		if fuzz > 0 {
+			return fmt.Errorf("--fuzz needs --worktree")
+		}
+		if err := applyPatch(repoDir, string(data), check); err != nil {
+			return err
+		}
+	}
+
+	verb := "Staged"
+	switch {
+	case check:
+		verb = "Patch applies cleanly to"
+	case worktree:
+		verb = "Applied"
+	}
+	fmt.Fprintf(os.Stderr, "%s %d file(s)\n", verb, len(ds.Files))
+	return nil
+}
+
+// applyWorktree applies ds to the files under dir without git, noting
+// hunks that had to be moved or fuzzed to fit. With check, it only
+// verifies the patch would apply.
+func applyWorktree(dir string, ds *diff.DiffSet, check bool, fuzz int) error {
+	results, err := apply.Apply(dir, ds.Files, apply.Options{Fuzz: fuzz, DryRun: check})
+	if err != nil {
+		return err
+	}
+	for _, r := range results {
+		name := r.Path
+		if name == "" {
+			name = r.OldPath
+		}
+		for i, h := range r.Hunks {
+			if h.Offset != 0 || h.Fuzz != 0 {
+				fmt.Fprintf(os.Stderr, "%s: hunk %d applied at line %d (offset %d, fuzz %d)\n", name, i+1, h.Line, h.Offset, h.Fuzz)
+			}
+		}
+	}
+	return nil
+}
+
+// applyPatch stages patch in the git index. With check, it only verifies
+// the patch would apply.
+func applyPatch(repoDir, patch string, check bool) error {
+	args := []string{"--cached"}
+	if check {
+		args = append(args, "--check")
+	}
+	return diff.GitApply(repoDir, patch, args...)
+}
+
+// readPatch reads the patch file at path, or the command's input if path is
+// "-".
+func readPatch(cmd *cobra.Command, path string) ([]byte, error) {
+	if path == "-" {
+		data, err := io.ReadAll(cmd.InOrStdin())
+		if err != nil {
+			return nil, fmt.Errorf("reading stdin: %w", err)
+		}
+		return data, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading patch: %w", err)
+	}
+	return data, nil
+}
@@ -3,86 +3,332 @@ package cli
 import (
 	"encoding/json"
 	"fmt"
+	"io"
 	"os"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/aezell/agrev/internal/analysis"
+	"github.com/aezell/agrev/internal/config"
 	"github.com/aezell/agrev/internal/diff"
 	"github.com/aezell/agrev/internal/model"
+	"github.com/aezell/agrev/internal/notify"
+	"github.com/aezell/agrev/internal/policy"
+	"github.com/aezell/agrev/internal/report"
+	"github.com/aezell/agrev/internal/review"
+	"github.com/aezell/agrev/internal/sbom"
 )
 
 var checkCmd = &cobra.Command{
-	Use:   "check [commit-range]",
+	Use:   "check [commit-range | patch...]",
 	Short: "Run analysis and output a report (non-interactive)",
 	Long: `Run all analysis passes on the diff and output a structured report.
 Useful for CI, pre-commit hooks, and piping into other tools.
 
+With --per-commit, each commit of a range or patch series ('git log -p' or
+'git format-patch' output) is analyzed and reported on its own.
+
+Rules in .agrev/policy.yml that block the change or require files to be
+approved by hand are reported with the findings.
+
+With --ci github, in a GitHub Actions job, the pull request, merge group,
+or push that triggered the workflow is checked unless a range is given,
+with its commits fetched if the checkout lacks them. Findings are also
+annotated on the diff, the report goes into the job summary, and the
+max_risk and finding_count outputs are set for later steps.
+
 Exit codes:
   0 — clean, no issues found
   1 — warnings found
-  2 — high risk items found`,
-	Args: cobra.MaximumNArgs(1),
+  2 — high risk items found, or a policy rule blocks the change`,
+	Args: cobra.ArbitraryArgs,
 	RunE: runCheck,
 }
 
 func init() {
+	addSourceFlags(checkCmd)
 	checkCmd.Flags().StringP("trace", "t", "", "path to agent trace file")
-	checkCmd.Flags().StringP("format", "f", "text", "output format: text, json, markdown, html")
+	checkCmd.Flags().StringP("format", "f", "text", "output format: text, json, markdown, html, rdjson, sarif")
 	checkCmd.Flags().StringSlice("skip", nil, "analysis passes to skip")
+	addCoverageFlag(checkCmd)
+	checkCmd.Flags().String("post", "", "post findings as a review on this pull request (number or URL)")
+	checkCmd.Flags().Bool("per-commit", false, "report on each commit of a range or patch series separately (text and json only)")
+	checkCmd.Flags().String("ci", "", "also report to the CI system the check runs in: github")
+	checkCmd.Flags().String("sbom", "", "write the dependencies the diff adds to this file as a CycloneDX SBOM fragment")
 }
 
 func runCheck(cmd *cobra.Command, args []string) error {
 	contextLines := 3
 
-	raw, err := getDiff(args, contextLines)
+	ci, args, err := ciContext(cmd, args)
+	if err != nil {
+		return err
+	}
+	raw, rng, err := getDiff(cmd, args, contextLines)
 	if err != nil {
 		return err
 	}
 
 	if strings.TrimSpace(raw) == "" {
 		fmt.Println("No changes to check.")
-		return nil
+		return ci.noChanges()
 	}
 
 	ds, err := diff.Parse(raw)
 	if err != nil {
 		return fmt.Errorf("parsing diff: %w", err)
 	}
+	ds.Range = rng
 
 	if len(ds.Files) == 0 {
 		fmt.Println("No changes to check.")
-		return nil
+		return ci.noChanges()
 	}
 
-	skip, _ := cmd.Flags().GetStringSlice("skip")
-
 	repoDir, _ := gitRepoRoot()
-	results := analysis.Run(ds, repoDir, skip)
+	if path, _ := cmd.Flags().GetString("sbom"); path != "" {
+		if err := writeSBOM(path, ds, args, skipPasses(cmd, repoDir)); err != nil {
+			return err
+		}
+	}
+	if perCommit, _ := cmd.Flags().GetBool("per-commit"); perCommit {
+		if ci != nil {
+			return fmt.Errorf("--ci reports on the whole diff and can't be combined with --per-commit")
+		}
+		return checkCommits(cmd, args, raw, repoDir)
+	}
+	rs := review.New(ds, nil, analyze(cmd, ds, repoDir, skipPasses(cmd, repoDir)))
+	results := rs.Results
+	hits := policyNotices(ds, results, repoDir)
+
+	// Post before writing the report: text output exits with the risk code
+	if pr, _ := cmd.Flags().GetString("post"); pr != "" {
+		if err := postFindings(pr, results); err != nil {
+			return err
+		}
+	}
+	if ci != nil {
+		if err := ci.report(rs, hits); err != nil {
+			return err
+		}
+	}
+	if cfg, err := config.Load(repoDir); err == nil {
+		nFiles, added, deleted := ds.Stats()
+		notifyWebhooks(cfg, notify.Summary{
+			Command: "check", Subject: reviewRange(sessionSource{args: args}),
+			Files: nFiles, Added: added, Deleted: deleted, Results: results,
+			Report: &report.Report{Diff: ds, Results: results, Generated: time.Now()},
+		})
+	}
 
 	format, _ := cmd.Flags().GetString("format")
 	switch format {
 	case "json":
-		return outputJSON(results)
+		return outputJSON(rs, hits)
+	case "rdjson":
+		return outputRDJSON(results)
+	case "sarif":
+		return outputSARIF(results)
 	case "markdown":
-		return outputMarkdown(ds, results)
+		return outputMarkdown(rs, hits)
 	case "html":
-		return outputHTML(ds, results)
+		return outputHTML(rs, hits)
 	default:
-		return outputText(ds, results)
+		return outputText(rs, hits)
+	}
+}
+
+// checkCommits analyzes each commit in the changes named by args on its
+// own. The text report's exit code follows the riskiest commit.
+func checkCommits(cmd *cobra.Command, args []string, raw, repoDir string) error {
+	format, _ := cmd.Flags().GetString("format")
+	switch {
+	case format != "text" && format != "json":
+		return fmt.Errorf("--per-commit reports as text or json, not %s", format)
+	case cmd.Flags().Changed("post"):
+		return fmt.Errorf("--post reports on the whole diff and can't be combined with --per-commit")
+	}
+	commits, err := splitCommits(cmd, args, raw, 3)
+	if err != nil {
+		return err
+	}
+	if len(commits) == 0 {
+		return fmt.Errorf("--per-commit needs a commit range or a patch series with commit headers")
+	}
+
+	skip := skipPasses(cmd, repoDir)
+	reports := []jsonReport{}
+	maxRisk := model.RiskInfo
+	for _, c := range commits {
+		results := analyze(cmd, c.Diff, repoDir, skip)
+		maxRisk = max(maxRisk, results.MaxRisk())
+		if format == "json" {
+			r := newJSONReport(c.Diff, results)
+			r.Commit, r.Subject = c.Hash, c.Subject
+			reports = append(reports, r)
+			continue
+		}
+
+		nFiles, added, deleted := c.Diff.Stats()
+		fmt.Printf("commit %s %s\n", c.ShortHash(), c.Subject)
+		fmt.Printf("%d file(s) changed, +%d -%d\n", nFiles, added, deleted)
+		fmt.Printf("Analysis: %s\n\n", results.Summary())
+		printGenerated(c.Diff)
+		if len(results.Findings) == 0 {
+			fmt.Print("No issues found.\n\n")
+		} else {
+			printFindings(results)
+		}
+	}
+
+	// As for a single report, only text output sets the exit code
+	if format == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(reports)
 	}
+	exitForRisk(maxRisk)
+	return nil
 }
 
-func outputText(ds *diff.DiffSet, results *analysis.Results) error {
+func outputText(rs *review.Session, hits []policy.Hit) error {
+	ds, results := rs.Diff, rs.Results
 	nFiles, added, deleted := ds.Stats()
+	if ds.Head != "" {
+		fmt.Printf("Comparing %s\n", ds.Short())
+	}
 	fmt.Printf("%d file(s) changed, +%d -%d\n", nFiles, added, deleted)
 	fmt.Printf("Analysis: %s\n\n", results.Summary())
+	if bumps, ok := analysis.DependencyUpdate(ds, results); ok {
+		printBumps(bumps)
+	} else {
+		printGenerated(ds)
+	}
 
 	if len(results.Findings) == 0 {
 		fmt.Println("No issues found.")
+	} else {
+		printFindings(results)
+	}
+
+	if len(hits) > 0 {
+		fmt.Println("Policy:")
+		for _, h := range hits {
+			fmt.Printf("  %s %s: %s\n", policyIcon(h), ds.Files[h.File].Name(), h.Describe())
+		}
+		fmt.Println()
+	}
+
+	exitForPolicy(hits)
+	exitForRisk(results.MaxRisk())
+	return nil
+}
+
+// policyNotices evaluates the repository's .agrev/policy.yml on ds and
+// returns the hits check reports: blocks and required approvals. A policy
+// that can't be read is warned about and left out.
+func policyNotices(ds *diff.DiffSet, results *analysis.Results, repoDir string) []policy.Hit {
+	if repoDir == "" {
+		return nil
+	}
+	pol, err := policy.Load(repoDir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
 		return nil
 	}
+	var hits []policy.Hit
+	for _, h := range pol.Evaluate(ds, results) {
+		if h.Rule.Action != policy.ActionAutoApprove {
+			hits = append(hits, h)
+		}
+	}
+	return hits
+}
+
+func policyIcon(h policy.Hit) string {
+	if h.Rule.Action == policy.ActionBlock {
+		return "✗"
+	}
+	return "!"
+}
+
+// exitForPolicy exits with code 2 when a policy rule blocks the change.
+func exitForPolicy(hits []policy.Hit) {
+	if len(policy.Blocked(hits)) > 0 {
+		os.Exit(2)
+	}
+}
+
+// generatedSummaries summarizes each lockfile or other generated file in
+// a line, as the review shows them collapsed.
+func generatedSummaries(ds *diff.DiffSet) []string {
+	var out []string
+	for _, f := range ds.Files {
+		if f.Generated() {
+			out = append(out, f.GeneratedSummary())
+		}
+	}
+	return out
+}
+
+// printGenerated lists the generated files, if there are any, so their
+// changes are accounted for without being read line by line.
+func printGenerated(ds *diff.DiffSet) {
+	summaries := generatedSummaries(ds)
+	if len(summaries) == 0 {
+		return
+	}
+	fmt.Println("Generated files:")
+	for _, s := range summaries {
+		fmt.Printf("  %s\n", s)
+	}
+	fmt.Println()
+}
+
+// printBumps lists the packages a dependency update bumps, in place of
+// its lockfiles, with their version jumps, known vulnerabilities, and
+// changelogs.
+func printBumps(bumps []analysis.Bump) {
+	fmt.Printf("Dependency update: %d package(s)\n", len(bumps))
+	for _, b := range bumps {
+		line := fmt.Sprintf("  %s %s", b.Name, b.Versions())
+		if jump := b.Jump(); jump != "" && jump != "new" && jump != "removed" {
+			line += " (" + jump + ")"
+		}
+		if status := b.Status(); status != "" {
+			line += "; vulnerabilities: " + status
+		}
+		fmt.Println(line)
+		if b.Changelog != "" {
+			fmt.Printf("    %s\n", b.Changelog)
+		}
+	}
+	fmt.Println()
+}
 
+// writeBumpsMarkdown writes the packages a dependency update bumps as a
+// Markdown table.
+func writeBumpsMarkdown(w io.Writer, bumps []analysis.Bump) {
+	fmt.Fprintln(w, "| Package | Version | Jump | Vulnerabilities |")
+	fmt.Fprintln(w, "|---------|---------|------|-----------------|")
+	for _, b := range bumps {
+		name := "`" + b.Name + "`"
+		if b.Changelog != "" {
+			name = fmt.Sprintf("[`%s`](%s)", b.Name, b.Changelog)
+		}
+		status := b.Status()
+		if len(b.Vulnerabilities) > 0 {
+			status = "**" + status + "**"
+		}
+		fmt.Fprintf(w, "| %s | %s | %s | %s |\n", name, b.Versions(), b.Jump(), status)
+	}
+	fmt.Fprintln(w)
+}
+
+// printFindings lists findings grouped by file.
+func printFindings(results *analysis.Results) {
 	byFile := results.ByFile()
 	for file, findings := range byFile {
 		fmt.Printf("  %s\n", file)
@@ -92,86 +338,421 @@ func outputText(ds *diff.DiffSet, results *analysis.Results) error {
 			if f.Line > 0 {
 				loc = fmt.Sprintf(":%d", f.Line)
 			}
-			fmt.Printf("    %s [%s] %s%s: %s\n", icon, f.Pass, file, loc, f.Message)
+			if f.Scope != "" {
+				loc += " (in " + f.Scope + ")"
+			}
+			fmt.Printf("    %s [%s] %s%s: %s\n", icon, findingTag(f), file, loc, f.Message)
 		}
 		fmt.Println()
 	}
+}
 
-	// Set exit code
-	maxRisk := results.MaxRisk()
+// findingTag names a finding's pass and, for built-in checks, its rule,
+// e.g. "security AGV-SEC-003".
+func findingTag(f analysis.Finding) string {
+	if f.Rule == "" {
+		return f.Pass
+	}
+	return f.Pass + " " + f.Rule
+}
+
+// ruleLink is a finding's rule as a Markdown link to its documentation.
+func ruleLink(f analysis.Finding) string {
+	if url := f.DocsURL(); url != "" {
+		return fmt.Sprintf("[%s](%s)", f.Rule, url)
+	}
+	return f.Rule
+}
+
+// exitForRisk exits with the check command's code for maxRisk: 2 for high
+// risk, 1 for warnings. It returns when there is nothing to report.
+func exitForRisk(maxRisk model.RiskLevel) {
 	if maxRisk >= model.RiskHigh {
 		os.Exit(2)
 	} else if maxRisk >= model.RiskLow {
 		os.Exit(1)
 	}
+}
 
-	return nil
+// jsonReport is the report written by 'check --format json' and read back
+// by 'compare'.
+type jsonReport struct {
+	Commit   string        `json:"commit,omitempty"`  // with --per-commit, the commit reported on
+	Subject  string        `json:"subject,omitempty"` // and its subject line
+	Base     string        `json:"base,omitempty"`    // commits compared, when the diff came from git
+	Head     string        `json:"head,omitempty"`    // empty for the working tree
+	Summary  string        `json:"summary"`
+	MaxRisk  string        `json:"max_risk"`
+	Total    int           `json:"total"`
+	Findings []jsonFinding `json:"findings"`
+
+	Suppressed []jsonFinding `json:"suppressed,omitempty"` // findings reviewers marked as false positives
+
+	Generated []string `json:"generated,omitempty"` // one-line summaries of lockfiles and other generated files
+
+	Dependencies []jsonBump `json:"dependency_update,omitempty"` // the packages bumped, when the change only updates dependencies
+
+	Policy []jsonPolicyHit `json:"policy,omitempty"` // .agrev/policy.yml rules blocking the change or requiring approval
+}
+
+type jsonPolicyHit struct {
+	Rule    string `json:"rule"`
+	Action  string `json:"action"`
+	File    string `json:"file"`
+	Message string `json:"message"`
+}
+
+type jsonBump struct {
+	Ecosystem       string `json:"ecosystem"`
+	Name            string `json:"name"`
+	From            string `json:"from,omitempty"`
+	To              string `json:"to,omitempty"`
+	Jump            string `json:"jump,omitempty"`
+	File            string `json:"file"`
+	Line            int    `json:"line,omitempty"`
+	Changelog       string `json:"changelog,omitempty"`
+	Vulnerabilities string `json:"vulnerabilities,omitempty"` // the advisories found, "none known", or "not checked"
 }
 
-func outputJSON(results *analysis.Results) error {
-	type jsonFinding struct {
-		Pass     string `json:"pass"`
-		File     string `json:"file"`
-		Line     int    `json:"line,omitempty"`
-		Message  string `json:"message"`
-		Severity string `json:"severity"`
-		Risk     string `json:"risk"`
+type jsonFinding struct {
+	Pass     string `json:"pass"`
+	Rule     string `json:"rule,omitempty"`
+	DocsURL  string `json:"docs_url,omitempty"`
+	File     string `json:"file"`
+	Line     int    `json:"line,omitempty"`
+	Scope    string `json:"scope,omitempty"`
+	Message  string `json:"message"`
+	Severity string `json:"severity"`
+	Risk     string `json:"risk"`
+	State    string `json:"state,omitempty"` // acknowledged or fixed, as triaged by reviewers
+}
+
+func jsonFindings(findings []analysis.Finding) []jsonFinding {
+	out := []jsonFinding{}
+	for _, f := range findings {
+		out = append(out, jsonFinding{
+			Pass:     f.Pass,
+			Rule:     f.Rule,
+			DocsURL:  f.DocsURL(),
+			File:     f.File,
+			Line:     f.Line,
+			Scope:    f.Scope,
+			Message:  f.Message,
+			Severity: severityStr(f.Severity),
+			Risk:     f.Risk.String(),
+			State:    findingState(f),
+		})
 	}
+	return out
+}
 
-	type jsonOutput struct {
-		Summary  string        `json:"summary"`
-		MaxRisk  string        `json:"max_risk"`
-		Total    int           `json:"total"`
-		Findings []jsonFinding `json:"findings"`
+// findingState names a triaged finding's state, or "" for an open one.
+func findingState(f analysis.Finding) string {
+	if f.State == model.FindingOpen {
+		return ""
 	}
+	return f.State.String()
+}
 
-	out := jsonOutput{
+func newJSONReport(ds *diff.DiffSet, results *analysis.Results) jsonReport {
+	out := jsonReport{
+		Base:    ds.Base,
+		Head:    ds.Head,
 		Summary: results.Summary(),
 		MaxRisk: results.MaxRisk().String(),
 		Total:   len(results.Findings),
+
+		Generated: generatedSummaries(ds),
+	}
+	if len(results.Findings) > 0 {
+		out.Findings = jsonFindings(results.Findings)
 	}
+	if len(results.Suppressed) > 0 {
+		out.Suppressed = jsonFindings(results.Suppressed)
+	}
+	if bumps, ok := analysis.DependencyUpdate(ds, results); ok {
+		for _, b := range bumps {
+			out.Dependencies = append(out.Dependencies, jsonBump{
+				Ecosystem: b.Ecosystem, Name: b.Name, From: b.From, To: b.To, Jump: b.Jump(),
+				File: b.File, Line: b.Line, Changelog: b.Changelog, Vulnerabilities: b.Status(),
+			})
+		}
+	}
+	return out
+}
+
+func outputJSON(rs *review.Session, hits []policy.Hit) error {
+	ds, results := rs.Diff, rs.Results
+	report := newJSONReport(ds, results)
+	for _, h := range hits {
+		report.Policy = append(report.Policy, jsonPolicyHit{
+			Rule:    h.Rule.Name,
+			Action:  string(h.Rule.Action),
+			File:    ds.Files[h.File].Name(),
+			Message: h.Describe(),
+		})
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(report)
+}
+
+// rdjsonResult is a report in Reviewdog Diagnostic Format, which reviewdog
+// reads with -f=rdjson to post findings as inline PR comments.
+type rdjsonResult struct {
+	Source      rdjsonSource       `json:"source"`
+	Severity    string             `json:"severity,omitempty"`
+	Diagnostics []rdjsonDiagnostic `json:"diagnostics"`
+}
+
+type rdjsonSource struct {
+	Name string `json:"name"`
+	URL  string `json:"url,omitempty"`
+}
+
+type rdjsonDiagnostic struct {
+	Message  string         `json:"message"`
+	Location rdjsonLocation `json:"location"`
+	Severity string         `json:"severity"`
+	Source   rdjsonSource   `json:"source"`
+	Code     rdjsonCode     `json:"code"`
+}
+
+type rdjsonLocation struct {
+	Path  string       `json:"path"`
+	Range *rdjsonRange `json:"range,omitempty"`
+}
+
+type rdjsonRange struct {
+	Start rdjsonPosition `json:"start"`
+}
+
+type rdjsonPosition struct {
+	Line int `json:"line"`
+}
+
+type rdjsonCode struct {
+	Value string `json:"value"`
+	URL   string `json:"url,omitempty"`
+}
 
+func rdjsonReport(results *analysis.Results) rdjsonResult {
+	source := rdjsonSource{Name: "agrev", URL: "https://github.com/aezell/agrev"}
+	out := rdjsonResult{Source: source, Diagnostics: []rdjsonDiagnostic{}}
 	for _, f := range results.Findings {
-		out.Findings = append(out.Findings, jsonFinding{
-			Pass:     f.Pass,
-			File:     f.File,
-			Line:     f.Line,
-			Message:  f.Message,
-			Severity: severityStr(f.Severity),
-			Risk:     f.Risk.String(),
+		d := rdjsonDiagnostic{
+			Message:  fmt.Sprintf("[%s risk] %s", f.Risk, f.Message),
+			Location: rdjsonLocation{Path: f.File},
+			Severity: strings.ToUpper(severityStr(f.Severity)),
+			Source:   source,
+			Code:     rdjsonCode{Value: f.Pass, URL: f.DocsURL()},
+		}
+		if f.Rule != "" {
+			d.Code.Value = f.Rule
+		}
+		// Findings without a line attach to the whole file
+		if f.Line > 0 {
+			d.Location.Range = &rdjsonRange{Start: rdjsonPosition{Line: f.Line}}
+		}
+		out.Diagnostics = append(out.Diagnostics, d)
+	}
+	return out
+}
+
+func outputRDJSON(results *analysis.Results) error {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(rdjsonReport(results))
+}
+
+// sarifLog is a report in SARIF 2.1.0, which GitHub code scanning and other
+// static analysis dashboards read. Each built-in rule is described once in
+// the tool's rules, and results refer to their rule by ID.
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID               string         `json:"id"`
+	ShortDescription sarifMessage   `json:"shortDescription"`
+	HelpURI          string         `json:"helpUri"`
+	Properties       map[string]any `json:"properties"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID              string             `json:"ruleId"`
+	RuleIndex           *int               `json:"ruleIndex,omitempty"`
+	Level               string             `json:"level"`
+	Message             sarifMessage       `json:"message"`
+	Locations           []sarifLocation    `json:"locations"`
+	PartialFingerprints map[string]string  `json:"partialFingerprints"`
+	Properties          map[string]any     `json:"properties"`
+	Suppressions        []sarifSuppression `json:"suppressions,omitempty"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifact `json:"artifactLocation"`
+	Region           *sarifRegion  `json:"region,omitempty"`
+}
+
+type sarifArtifact struct {
+	URI string `json:"uri"`
+}
+
+type sarifRegion struct {
+	StartLine int `json:"startLine"`
+}
+
+type sarifSuppression struct {
+	Kind          string `json:"kind"`
+	Justification string `json:"justification,omitempty"`
+}
+
+func sarifReport(results *analysis.Results) sarifLog {
+	driver := sarifDriver{Name: "agrev", InformationURI: "https://github.com/aezell/agrev"}
+	index := make(map[string]int)
+	for i, r := range analysis.Rules {
+		index[r.ID] = i
+		driver.Rules = append(driver.Rules, sarifRule{
+			ID:               r.ID,
+			ShortDescription: sarifMessage{Text: r.Name},
+			HelpURI:          r.URL(),
+			Properties:       map[string]any{"pass": r.Pass},
 		})
 	}
 
+	run := sarifRun{Tool: sarifTool{Driver: driver}, Results: []sarifResult{}}
+	add := func(f analysis.Finding, suppressed bool) {
+		res := sarifResult{
+			RuleID:              f.Pass,
+			Level:               sarifLevel(f.Severity),
+			Message:             sarifMessage{Text: fmt.Sprintf("[%s risk] %s", f.Risk, f.Message)},
+			PartialFingerprints: map[string]string{"agrev/v1": f.Fingerprint()},
+			Properties:          map[string]any{"pass": f.Pass, "risk": f.Risk.String()},
+		}
+		if i, ok := index[f.Rule]; ok {
+			res.RuleID, res.RuleIndex = f.Rule, &i
+		} else if f.Rule != "" {
+			res.RuleID = f.Rule // e.g. a semgrep rule's
+		}
+		loc := sarifPhysicalLocation{ArtifactLocation: sarifArtifact{URI: f.File}}
+		if f.Line > 0 {
+			loc.Region = &sarifRegion{StartLine: f.Line}
+		}
+		res.Locations = []sarifLocation{{PhysicalLocation: loc}}
+		if suppressed {
+			res.Suppressions = []sarifSuppression{{Kind: "external", Justification: "marked as a false positive"}}
+		}
+		run.Results = append(run.Results, res)
+	}
+	for _, f := range results.Findings {
+		add(f, false)
+	}
+	for _, f := range results.Suppressed {
+		add(f, true)
+	}
+
+	return sarifLog{
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Version: "2.1.0",
+		Runs:    []sarifRun{run},
+	}
+}
+
+// sarifLevel maps a severity to SARIF's result levels.
+func sarifLevel(s model.Severity) string {
+	switch s {
+	case model.SeverityError:
+		return "error"
+	case model.SeverityWarning:
+		return "warning"
+	default:
+		return "note"
+	}
+}
+
+func outputSARIF(results *analysis.Results) error {
 	enc := json.NewEncoder(os.Stdout)
 	enc.SetIndent("", "  ")
-	return enc.Encode(out)
+	return enc.Encode(sarifReport(results))
+}
+
+func outputMarkdown(rs *review.Session, hits []policy.Hit) error {
+	writeMarkdown(os.Stdout, rs, hits)
+	return nil
 }
 
-func outputMarkdown(ds *diff.DiffSet, results *analysis.Results) error {
+// writeMarkdown writes the Markdown report to w.
+func writeMarkdown(w io.Writer, rs *review.Session, hits []policy.Hit) {
+	ds, results := rs.Diff, rs.Results
 	nFiles, added, deleted := ds.Stats()
-	fmt.Printf("## Analysis Report\n\n")
-	fmt.Printf("**%d file(s)** changed, **+%d** insertions, **-%d** deletions\n\n", nFiles, added, deleted)
-	fmt.Printf("**Risk:** %s | **Findings:** %d\n\n", results.MaxRisk(), len(results.Findings))
+	fmt.Fprintf(w, "## Analysis Report\n\n")
+	fmt.Fprintf(w, "**%d file(s)** changed, **+%d** insertions, **-%d** deletions\n\n", nFiles, added, deleted)
+	fmt.Fprintf(w, "**Risk:** %s | **Findings:** %d\n\n", results.MaxRisk(), len(results.Findings))
+	if bumps, ok := analysis.DependencyUpdate(ds, results); ok {
+		fmt.Fprintf(w, "**Dependency update:** %d package(s)\n\n", len(bumps))
+		writeBumpsMarkdown(w, bumps)
+	} else if summaries := generatedSummaries(ds); len(summaries) > 0 {
+		fmt.Fprint(w, "**Generated files:**\n\n")
+		for _, s := range summaries {
+			fmt.Fprintf(w, "- %s\n", s)
+		}
+		fmt.Fprintln(w)
+	}
+	if len(hits) > 0 {
+		fmt.Fprint(w, "**Policy:**\n\n")
+		for _, h := range hits {
+			fmt.Fprintf(w, "- %s `%s` — %s\n", h.Rule.Action, ds.Files[h.File].Name(), h.Describe())
+		}
+		fmt.Fprintln(w)
+	}
 
 	if len(results.Findings) == 0 {
-		fmt.Println("No issues found.")
-		return nil
+		fmt.Fprintln(w, "No issues found.")
+		return
 	}
 
-	fmt.Println("| Risk | Pass | File | Message |")
-	fmt.Println("|------|------|------|---------|")
+	fmt.Fprintln(w, "| Risk | Pass | Rule | File | Message |")
+	fmt.Fprintln(w, "|------|------|------|------|---------|")
 	for _, f := range results.Findings {
-		loc := f.File
+		loc := "`" + f.File + "`"
 		if f.Line > 0 {
-			loc = fmt.Sprintf("%s:%d", f.File, f.Line)
+			loc = fmt.Sprintf("`%s:%d`", f.File, f.Line)
 		}
-		fmt.Printf("| %s | %s | `%s` | %s |\n", f.Risk, f.Pass, loc, f.Message)
+		if f.Scope != "" {
+			loc += " in `" + f.Scope + "`"
+		}
+		fmt.Fprintf(w, "| %s | %s | %s | %s | %s |\n", f.Risk, f.Pass, ruleLink(f), loc, f.Message)
 	}
-
-	return nil
 }
 
-func outputHTML(ds *diff.DiffSet, results *analysis.Results) error {
+func outputHTML(rs *review.Session, hits []policy.Hit) error {
+	ds, results := rs.Diff, rs.Results
 	nFiles, added, deleted := ds.Stats()
 
 	fmt.Print(`<!DOCTYPE html>
@@ -194,8 +775,10 @@ func outputHTML(ds *diff.DiffSet, results *analysis.Results) error {
   tr:hover { background: #343746; }
   .pass { color: #bd93f9; }
   .file { color: #8be9fd; }
+  a { color: #ff79c6; }
   code { background: #343746; padding: 2px 6px; border-radius: 4px; font-size: 0.9em; }
   .clean { color: #50fa7b; font-size: 1.2em; }
+  .generated { color: #6272a4; }
   footer { margin-top: 32px; color: #6272a4; font-size: 0.85em; }
 </style>
 </head>
@@ -212,20 +795,61 @@ func outputHTML(ds *diff.DiffSet, results *analysis.Results) error {
 </div>
 `, nFiles, added, deleted, results.MaxRisk().String(), results.MaxRisk(), len(results.Findings))
 
+	if bumps, ok := analysis.DependencyUpdate(ds, results); ok {
+		fmt.Printf("<p>Dependency update: %d package(s)</p>\n", len(bumps))
+		fmt.Println(`<table>
+<thead><tr><th>Package</th><th>Version</th><th>Jump</th><th>Vulnerabilities</th></tr></thead>
+<tbody>`)
+		for _, b := range bumps {
+			name := "<code>" + htmlEscape(b.Name) + "</code>"
+			if b.Changelog != "" {
+				name = fmt.Sprintf(`<a href="%s">%s</a>`, htmlEscape(b.Changelog), name)
+			}
+			status := htmlEscape(b.Status())
+			if len(b.Vulnerabilities) > 0 {
+				status = `<span class="risk-high">` + status + `</span>`
+			}
+			fmt.Printf("<tr><td>%s</td><td>%s</td><td>%s</td><td>%s</td></tr>\n", name, htmlEscape(b.Versions()), b.Jump(), status)
+		}
+		fmt.Println(`</tbody></table>`)
+	} else if summaries := generatedSummaries(ds); len(summaries) > 0 {
+		fmt.Println(`<p class="generated">Generated files:`)
+		for _, s := range summaries {
+			fmt.Printf("<br><code>%s</code>\n", htmlEscape(s))
+		}
+		fmt.Println(`</p>`)
+	}
+	if len(hits) > 0 {
+		fmt.Println(`<p>Policy:`)
+		for _, h := range hits {
+			fmt.Printf("<br><span class=\"%s\">%s</span> <code>%s</code> %s\n",
+				policyClass(h), h.Rule.Action, htmlEscape(ds.Files[h.File].Name()), htmlEscape(h.Describe()))
+		}
+		fmt.Println(`</p>`)
+	}
+
 	if len(results.Findings) == 0 {
 		fmt.Println(`<p class="clean">No issues found.</p>`)
 	} else {
 		fmt.Println(`<table>
-<thead><tr><th>Risk</th><th>Pass</th><th>File</th><th>Message</th></tr></thead>
+<thead><tr><th>Risk</th><th>Pass</th><th>Rule</th><th>File</th><th>Message</th></tr></thead>
 <tbody>`)
 		for _, f := range results.Findings {
 			loc := f.File
 			if f.Line > 0 {
 				loc = fmt.Sprintf("%s:%d", f.File, f.Line)
 			}
+			loc = "<code>" + htmlEscape(loc) + "</code>"
+			if f.Scope != "" {
+				loc += " in <code>" + htmlEscape(f.Scope) + "</code>"
+			}
+			rule := htmlEscape(f.Rule)
+			if url := f.DocsURL(); url != "" {
+				rule = fmt.Sprintf(`<a href="%s">%s</a>`, htmlEscape(url), rule)
+			}
 			riskClass := "risk-" + f.Risk.String()
-			fmt.Printf(`<tr><td class="%s">%s</td><td class="pass">%s</td><td class="file"><code>%s</code></td><td>%s</td></tr>
-`, riskClass, f.Risk, f.Pass, loc, htmlEscape(f.Message))
+			fmt.Printf(`<tr><td class="%s">%s</td><td class="pass">%s</td><td>%s</td><td class="file">%s</td><td>%s</td></tr>
+`, riskClass, f.Risk, f.Pass, rule, loc, htmlEscape(f.Message))
 		}
 		fmt.Println(`</tbody></table>`)
 	}
@@ -234,17 +858,18 @@ func outputHTML(ds *diff.DiffSet, results *analysis.Results) error {
 </body>
 </html>`)
 
-	// Set exit code
-	maxRisk := results.MaxRisk()
-	if maxRisk >= model.RiskHigh {
-		os.Exit(2)
-	} else if maxRisk >= model.RiskLow {
-		os.Exit(1)
-	}
-
+	exitForPolicy(hits)
+	exitForRisk(results.MaxRisk())
 	return nil
 }
 
+func policyClass(h policy.Hit) string {
+	if h.Rule.Action == policy.ActionBlock {
+		return "risk-high"
+	}
+	return "risk-medium"
+}
+
 func htmlEscape(s string) string {
 	s = strings.ReplaceAll(s, "&", "&amp;")
 	s = strings.ReplaceAll(s, "<", "&lt;")
@@ -278,3 +903,21 @@ func severityStr(s model.Severity) string {
 		return "info"
 	}
 }
+
+// writeSBOM writes the dependencies the deps pass finds added in ds to path
+// as a CycloneDX SBOM fragment.
+func writeSBOM(path string, ds *diff.DiffSet, args []string, skip []string) error {
+	if slices.Contains(skip, "deps") {
+		return fmt.Errorf("--sbom lists what the deps pass finds, which is skipped")
+	}
+	bom := sbom.New(analysis.NewDependencies(ds), version, reviewRange(sessionSource{args: args}), time.Now())
+	data, err := json.MarshalIndent(bom, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("writing SBOM: %w", err)
+	}
+	fmt.Fprintf(os.Stderr, "Wrote %s: %d new dependency component(s)\n", path, len(bom.Components))
+	return nil
+}
@@ -0,0 +1,283 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/aezell/agrev/internal/analysis"
+	"github.com/aezell/agrev/internal/model"
+	"github.com/aezell/agrev/internal/policy"
+	"github.com/aezell/agrev/internal/review"
+)
+
+// githubActions is the job 'check --ci github' runs in, as described by the
+// environment the Actions runner sets.
+type githubActions struct {
+	Event string // the event that triggered the workflow, e.g. "pull_request"
+	PR    int    // the pull request's number, 0 outside pull request events
+	Base  string // commit the changes start from, empty when the event has none
+	Head  string // commit the changes end at
+
+	summary string // GITHUB_STEP_SUMMARY: the job summary, in Markdown
+	output  string // GITHUB_OUTPUT: the step's outputs
+}
+
+// ciContext reads the job's context for --ci, nil without it. With no
+// changes named by args or flags, it returns the job's commit range as
+// args, fetching its commits if the checkout lacks them.
+func ciContext(cmd *cobra.Command, args []string) (*githubActions, []string, error) {
+	name, _ := cmd.Flags().GetString("ci")
+	switch name {
+	case "":
+		return nil, args, nil
+	case "github":
+	default:
+		return nil, nil, fmt.Errorf("unknown CI system %q; agrev supports github", name)
+	}
+	g, err := githubContext(os.Getenv)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, f := range []string{"staged", "unstaged", "include-untracked", "base", "from", "to"} {
+		if cmd.Flags().Changed(f) {
+			return g, args, nil
+		}
+	}
+	if len(args) > 0 {
+		return g, args, nil
+	}
+	rng := g.Range()
+	if rng == "" {
+		return nil, nil, fmt.Errorf("can't tell what a %s event changed; give a commit range", g.Event)
+	}
+	repoDir, err := gitRepoRoot()
+	if err != nil {
+		return nil, nil, fmt.Errorf("not in a git repository (or git not installed): %w", err)
+	}
+	if err := g.fetchCommits(repoDir); err != nil {
+		return nil, nil, err
+	}
+	return g, []string{rng}, nil
+}
+
+// githubEvent holds the parts of the webhook payload at GITHUB_EVENT_PATH
+// that say what changed.
+type githubEvent struct {
+	PullRequest *struct {
+		Number int `json:"number"`
+		Base   struct {
+			SHA string `json:"sha"`
+		} `json:"base"`
+		Head struct {
+			SHA string `json:"sha"`
+		} `json:"head"`
+	} `json:"pull_request"`
+	MergeGroup *struct {
+		BaseSHA string `json:"base_sha"`
+		HeadSHA string `json:"head_sha"`
+	} `json:"merge_group"`
+	Before string `json:"before"` // push events
+	After  string `json:"after"`
+}
+
+// zeroSHA is the before commit of a push that created its branch.
+const zeroSHA = "0000000000000000000000000000000000000000"
+
+// githubContext reads the job's context from getenv (os.Getenv outside
+// tests) and the event payload it points to.
+func githubContext(getenv func(string) string) (*githubActions, error) {
+	path := getenv("GITHUB_EVENT_PATH")
+	if path == "" {
+		return nil, fmt.Errorf("--ci github runs in GitHub Actions, but GITHUB_EVENT_PATH is not set")
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading the event payload: %w", err)
+	}
+	var ev githubEvent
+	if err := json.Unmarshal(data, &ev); err != nil {
+		return nil, fmt.Errorf("parsing the event payload %s: %w", path, err)
+	}
+
+	g := &githubActions{
+		Event:   getenv("GITHUB_EVENT_NAME"),
+		summary: getenv("GITHUB_STEP_SUMMARY"),
+		output:  getenv("GITHUB_OUTPUT"),
+	}
+	switch {
+	case ev.PullRequest != nil:
+		g.PR = ev.PullRequest.Number
+		g.Base, g.Head = ev.PullRequest.Base.SHA, ev.PullRequest.Head.SHA
+	case ev.MergeGroup != nil:
+		g.Base, g.Head = ev.MergeGroup.BaseSHA, ev.MergeGroup.HeadSHA
+	case ev.After != "" && ev.Before != "" && ev.Before != zeroSHA:
+		g.Base, g.Head = ev.Before, ev.After
+	}
+	return g, nil
+}
+
+// Range is the commit range the event's changes span: a pull request or
+// merge group from its merge-base with the base branch, as GitHub shows
+// it, and a push from the commit before it. It is empty for events without
+// one, such as workflow_dispatch.
+func (g *githubActions) Range() string {
+	switch {
+	case g.Base == "" || g.Head == "":
+		return ""
+	case g.PR > 0 || g.Event == "merge_group":
+		return g.Base + "..." + g.Head
+	default:
+		return g.Base + ".." + g.Head
+	}
+}
+
+// fetchCommits makes the event's commits available in the checkout:
+// actions/checkout fetches only the commit being built by default, so
+// missing commits are fetched from origin, and a shallow clone is deepened
+// so their merge-base can be found.
+func (g *githubActions) fetchCommits(repoDir string) error {
+	git := func(args ...string) *exec.Cmd {
+		return exec.Command("git", append([]string{"-C", repoDir}, args...)...)
+	}
+	args := []string{"fetch", "--no-tags", "--quiet"}
+	out, _ := git("rev-parse", "--is-shallow-repository").Output()
+	shallow := strings.TrimSpace(string(out)) == "true"
+	if shallow {
+		args = append(args, "--unshallow")
+	}
+	var missing []string
+	for _, sha := range []string{g.Base, g.Head} {
+		if git("cat-file", "-e", sha+"^{commit}").Run() != nil {
+			missing = append(missing, sha)
+		}
+	}
+	if len(missing) == 0 && !shallow {
+		return nil
+	}
+	args = append(append(args, "origin"), missing...)
+	if out, err := git(args...).CombinedOutput(); err != nil {
+		return fmt.Errorf("fetching %s: %v: %s", g.Range(), err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// report publishes the check to the job: an annotation on each finding and
+// policy rule, the report in the job summary, and the max_risk and
+// finding_count outputs for later steps.
+func (g *githubActions) report(rs *review.Session, hits []policy.Hit) error {
+	writeAnnotations(os.Stderr, rs, hits)
+	if g.summary != "" {
+		if err := appendFile(g.summary, func(w io.Writer) { g.writeSummary(w, rs, hits) }); err != nil {
+			return fmt.Errorf("writing the job summary: %w", err)
+		}
+	}
+	if g.output != "" {
+		if err := appendFile(g.output, func(w io.Writer) { writeOutputs(w, rs.Results) }); err != nil {
+			return fmt.Errorf("setting the step's outputs: %w", err)
+		}
+	}
+	return nil
+}
+
+// noChanges sets the outputs of a check that found nothing to check. It
+// does nothing without --ci.
+func (g *githubActions) noChanges() error {
+	if g == nil || g.output == "" {
+		return nil
+	}
+	return appendFile(g.output, func(w io.Writer) { writeOutputs(w, &analysis.Results{}) })
+}
+
+// appendFile appends what write writes to the file at path, as the runner
+// expects of its summary and output files.
+func appendFile(path string, write func(io.Writer)) error {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	write(f)
+	return f.Close()
+}
+
+// writeSummary writes the job summary: what was compared, the findings
+// counted by risk, and the Markdown report.
+func (g *githubActions) writeSummary(w io.Writer, rs *review.Session, hits []policy.Hit) {
+	fmt.Fprint(w, "## agrev check\n\n")
+	switch {
+	case g.PR > 0:
+		fmt.Fprintf(w, "Pull request #%d, comparing `%s`\n\n", g.PR, rs.Diff.Short())
+	case rs.Diff.Head != "":
+		fmt.Fprintf(w, "Comparing `%s`\n\n", rs.Diff.Short())
+	}
+
+	counts := make(map[model.RiskLevel]int)
+	for _, f := range rs.Results.Findings {
+		counts[f.Risk]++
+	}
+	fmt.Fprintln(w, "| Risk | Findings |")
+	fmt.Fprintln(w, "|------|---------:|")
+	for r := model.RiskCritical; r >= model.RiskInfo; r-- {
+		fmt.Fprintf(w, "| %s | %d |\n", r, counts[r])
+	}
+	fmt.Fprintln(w)
+	writeMarkdown(w, rs, hits)
+	fmt.Fprintln(w)
+}
+
+// writeOutputs sets the step's outputs: the highest risk found and the
+// number of findings.
+func writeOutputs(w io.Writer, results *analysis.Results) {
+	fmt.Fprintf(w, "max_risk=%s\n", results.MaxRisk())
+	fmt.Fprintf(w, "finding_count=%d\n", len(results.Findings))
+}
+
+// writeAnnotations writes a workflow command for each finding, which the
+// runner shows on the line it is about in the pull request's diff: high
+// and critical risks as errors, medium and low as warnings, the rest as
+// notices. Policy blocks are errors and required approvals warnings.
+func writeAnnotations(w io.Writer, rs *review.Session, hits []policy.Hit) {
+	for _, f := range rs.Results.Findings {
+		level := "notice"
+		switch {
+		case f.Risk >= model.RiskHigh:
+			level = "error"
+		case f.Risk >= model.RiskLow:
+			level = "warning"
+		}
+		props := "file=" + escapeProperty(f.File)
+		if f.Line > 0 {
+			props += fmt.Sprintf(",line=%d", f.Line)
+		}
+		props += ",title=" + escapeProperty("agrev "+findingTag(f))
+		fmt.Fprintf(w, "::%s %s::%s\n", level, props, escapeData(fmt.Sprintf("[%s risk] %s", f.Risk, f.Message)))
+	}
+	for _, h := range hits {
+		level := "warning"
+		if h.Rule.Action == policy.ActionBlock {
+			level = "error"
+		}
+		props := "file=" + escapeProperty(rs.Diff.Files[h.File].Name()) + ",title=" + escapeProperty("agrev policy "+h.Rule.Name)
+		fmt.Fprintf(w, "::%s %s::%s\n", level, props, escapeData(h.Describe()))
+	}
+}
+
+// escapeData escapes a workflow command's message.
+func escapeData(s string) string {
+	s = strings.ReplaceAll(s, "%", "%25")
+	s = strings.ReplaceAll(s, "\r", "%0D")
+	return strings.ReplaceAll(s, "\n", "%0A")
+}
+
+// escapeProperty escapes a workflow command's property value, which also
+// can't hold the separators between properties.
+func escapeProperty(s string) string {
+	s = escapeData(s)
+	s = strings.ReplaceAll(s, ":", "%3A")
+	return strings.ReplaceAll(s, ",", "%2C")
+}
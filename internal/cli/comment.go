@@ -0,0 +1,316 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/aezell/agrev/internal/analysis"
+	"github.com/aezell/agrev/internal/diff"
+	"github.com/aezell/agrev/internal/model"
+	"github.com/aezell/agrev/internal/owners"
+	"github.com/aezell/agrev/internal/provider"
+	"github.com/aezell/agrev/internal/tui"
+)
+
+var commentCmd = &cobra.Command{
+	Use:   "comment <number | url>",
+	Short: "Post findings as a pull request review",
+	Long: `Analyze a pull request and post the findings as a review: one inline
+comment per flagged line, and a summary comment with the risk table.
+Findings on lines outside the diff are listed in the summary. Works with
+the same forges as 'agrev pr'; on Gerrit, findings become robot comments.
+
+With --review, the pull request opens in an interactive review first, and
+your decisions and line comments are posted along with the findings.
+
+Examples:
+  agrev comment 123
+  agrev comment --review https://github.com/aezell/agrev/pull/123
+  agrev comment 123 --dry-run      # print the review instead of posting`,
+	Args: cobra.ExactArgs(1),
+	RunE: runComment,
+}
+
+func init() {
+	addSessionFlags(commentCmd)
+	addProviderFlags(commentCmd)
+	commentCmd.Flags().Bool("review", false, "review the pull request interactively before posting")
+	commentCmd.Flags().String("event", "comment", "review type: comment, approve, request-changes")
+	commentCmd.Flags().Bool("dry-run", false, "print the review as JSON instead of posting it")
+	commentCmd.Flags().StringSlice("skip", nil, "analysis passes to skip")
+	addCoverageFlag(commentCmd)
+}
+
+func runComment(cmd *cobra.Command, args []string) error {
+	eventName, _ := cmd.Flags().GetString("event")
+	event, err := provider.ParseEvent(eventName)
+	if err != nil {
+		return err
+	}
+
+	p, err := loadPR(cmd, args[0])
+	if err != nil {
+		return err
+	}
+	ds, err := diff.Parse(p.change.Diff)
+	if err != nil {
+		return fmt.Errorf("parsing diff: %w", err)
+	}
+
+	var result *tui.ReviewResult
+	if interactive, _ := cmd.Flags().GetBool("review"); interactive {
+		s, err := reviewPR(cmd, p, false)
+		if err != nil || s == nil {
+			return err
+		}
+		result = s.result
+	}
+
+	results := analyze(cmd, ds, p.repoDir, skipPasses(cmd, p.repoDir))
+
+	review := buildReview(ds, results, result, loadOwners(p.repoDir))
+	review.Event = event
+
+	if dryRun, _ := cmd.Flags().GetBool("dry-run"); dryRun {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(review)
+	}
+	return postReview(p, review)
+}
+
+// postFindings posts check results as a review on the pull request named by
+// arg. Lines are matched against the pull request's own diff, since that is
+// what the forge anchors comments to.
+func postFindings(arg string, results *analysis.Results) error {
+	repoDir, _ := gitRepoRoot()
+	remote := originRemote(repoDir)
+	prov, err := provider.Detect("", arg, remote)
+	if err != nil {
+		return err
+	}
+	ref, err := prov.ParseRef(arg, defaultRepo(prov.Name(), remote))
+	if err != nil {
+		return err
+	}
+	c, err := prov.Fetch(ref)
+	if err != nil {
+		return err
+	}
+	ds, err := diff.Parse(c.Diff)
+	if err != nil {
+		return fmt.Errorf("parsing diff: %w", err)
+	}
+
+	review := buildReview(ds, results, nil, loadOwners(repoDir))
+	review.Event = provider.EventComment
+	return postReview(&pullRequest{provider: prov, change: c, repoDir: repoDir}, review)
+}
+
+func postReview(p *pullRequest, review provider.Review) error {
+	link, err := p.provider.PostReview(p.change, review)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Posted review with %d inline comment(s) on %s\n", len(review.Comments), p.change)
+	if link != "" {
+		fmt.Fprintln(os.Stderr, link)
+	}
+	return nil
+}
+
+// buildReview turns findings, and optionally the decisions and comments of
+// an interactive review, into a review to post. Findings on the same line
+// share one inline comment. co, if not nil, names the owners of the files in
+// the review body.
+func buildReview(ds *diff.DiffSet, results *analysis.Results, result *tui.ReviewResult, co *owners.File) provider.Review {
+	type anchor struct {
+		path string
+		line int
+		old  bool
+	}
+	var order []anchor
+	bodies := make(map[anchor][]string)
+	add := func(a anchor, body string) {
+		if _, ok := bodies[a]; !ok {
+			order = append(order, a)
+		}
+		bodies[a] = append(bodies[a], body)
+	}
+
+	var unplaced []analysis.Finding
+	for _, f := range results.Findings {
+		file := findFile(ds, f.File)
+		if file == nil || f.Line <= 0 {
+			unplaced = append(unplaced, f)
+			continue
+		}
+		if _, ok := file.Position(f.Line, file.IsDeleted); !ok {
+			unplaced = append(unplaced, f)
+			continue
+		}
+		add(anchor{commentPath(file), f.Line, file.IsDeleted}, fmt.Sprintf("**%s risk** · `%s` %s — %s", f.Risk, f.Pass, ruleLink(f), f.Message))
+	}
+
+	var unplacedComments []model.Comment
+	if result != nil {
+		for _, c := range result.Comments {
+			file := findFile(ds, c.File)
+			if file == nil || c.Line <= 0 {
+				unplacedComments = append(unplacedComments, c)
+				continue
+			}
+			// Comment lines are new-file lines except on deleted lines
+			old := false
+			if _, ok := file.Position(c.Line, false); !ok {
+				if _, ok := file.Position(c.Line, true); !ok {
+					unplacedComments = append(unplacedComments, c)
+					continue
+				}
+				old = true
+			}
+			add(anchor{commentPath(file), c.Line, old}, c.Body)
+		}
+	}
+
+	var review provider.Review
+	for _, a := range order {
+		review.Comments = append(review.Comments, provider.Comment{
+			Path: a.path,
+			Line: a.line,
+			Old:  a.old,
+			Body: strings.Join(bodies[a], "\n\n"),
+		})
+	}
+	review.Body = reviewSummary(ds, results, result, co, unplaced, unplacedComments)
+	return review
+}
+
+// reviewSummary renders the review's top-level comment.
+func reviewSummary(ds *diff.DiffSet, results *analysis.Results, result *tui.ReviewResult, co *owners.File, unplaced []analysis.Finding, comments []model.Comment) string {
+	var b strings.Builder
+	nFiles, added, deleted := ds.Stats()
+	b.WriteString("## agrev review\n\n")
+	fmt.Fprintf(&b, "**%d file(s)** changed, **+%d** **-%d** · **Risk:** %s · %s\n",
+		nFiles, added, deleted, results.MaxRisk(), results.Summary())
+
+	if len(results.Findings) > 0 {
+		counts := make(map[model.RiskLevel]map[string]int)
+		var passes []string
+		seen := make(map[string]bool)
+		for _, f := range results.Findings {
+			if counts[f.Risk] == nil {
+				counts[f.Risk] = make(map[string]int)
+			}
+			counts[f.Risk][f.Pass]++
+			if !seen[f.Pass] {
+				seen[f.Pass] = true
+				passes = append(passes, f.Pass)
+			}
+		}
+		sort.Strings(passes)
+
+		b.WriteString("\n| Risk | Findings | Passes |\n")
+		b.WriteString("|------|----------|--------|\n")
+		for _, level := range []model.RiskLevel{model.RiskCritical, model.RiskHigh, model.RiskMedium, model.RiskLow, model.RiskInfo} {
+			byPass := counts[level]
+			if len(byPass) == 0 {
+				continue
+			}
+			total := 0
+			var names []string
+			for _, p := range passes {
+				if n := byPass[p]; n > 0 {
+					total += n
+					names = append(names, fmt.Sprintf("%s (%d)", p, n))
+				}
+			}
+			fmt.Fprintf(&b, "| %s | %d | %s |\n", level, total, strings.Join(names, ", "))
+		}
+	}
+
+	if bumps, ok := analysis.DependencyUpdate(ds, results); ok {
+		b.WriteString("\n### Dependency update\n\n")
+		writeBumpsMarkdown(&b, bumps)
+	}
+
+	var owned []string
+	for _, f := range ds.Files {
+		if o := co.Of(f); len(o) > 0 {
+			owned = append(owned, fmt.Sprintf("- `%s` — %s\n", f.Name(), strings.Join(o, " ")))
+		}
+	}
+	if len(owned) > 0 {
+		b.WriteString("\n### Owners\n\n")
+		b.WriteString(strings.Join(owned, ""))
+	}
+
+	if len(unplaced) > 0 {
+		b.WriteString("\n### Findings outside the diff\n\n")
+		for _, f := range unplaced {
+			loc := f.File
+			if f.Line > 0 {
+				loc = fmt.Sprintf("%s:%d", f.File, f.Line)
+			}
+			fmt.Fprintf(&b, "- **%s** `%s` [%s] %s\n", f.Risk, loc, findingTag(f), f.Message)
+		}
+	}
+
+	if result != nil {
+		b.WriteString("\n### Decisions\n\n")
+		for i, f := range result.Files {
+			fmt.Fprintf(&b, "- %s `%s`", result.Decision(i), f.Name())
+			if note := result.Decisions.Note(i, model.WholeFile); note != "" {
+				b.WriteString(" — " + note)
+			}
+			b.WriteString("\n")
+			for _, c := range result.Decisions.Conditions[i] {
+				fmt.Fprintf(&b, "  - [ ] %s\n", c)
+			}
+			for h := range f.Fragments {
+				if note := result.Decisions.Note(i, h); note != "" {
+					fmt.Fprintf(&b, "  - hunk %d %s — %s\n", h+1, result.Decisions.Hunk(i, h), note)
+				}
+			}
+		}
+		if labels := result.Labels(); len(labels) > 0 {
+			b.WriteString("\n### Labels\n\n")
+			for _, l := range labels {
+				fmt.Fprintf(&b, "- `%s` — %s\n", l.Location(), l.Tags())
+			}
+		}
+		if len(comments) > 0 {
+			b.WriteString("\n### Comments\n\n")
+			for _, c := range comments {
+				loc := c.File
+				if c.Line > 0 {
+					loc = fmt.Sprintf("%s:%d", c.File, c.Line)
+				}
+				fmt.Fprintf(&b, "- `%s` — %s\n", loc, c.Body)
+			}
+		}
+	}
+	return b.String()
+}
+
+// findFile finds a diff file by path or display name.
+func findFile(ds *diff.DiffSet, name string) *diff.File {
+	for _, f := range ds.Files {
+		if f.NewName == name || f.OldName == name || f.Name() == name {
+			return f
+		}
+	}
+	return nil
+}
+
+// commentPath is the path GitHub expects for comments on a file.
+func commentPath(f *diff.File) string {
+	if f.IsDeleted {
+		return f.OldName
+	}
+	return f.NewName
+}
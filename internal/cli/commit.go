@@ -0,0 +1,149 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var commitCmd = &cobra.Command{
+	Use:   "commit",
+	Short: "Review uncommitted changes and commit the approved ones",
+	Long: `Open an interactive review of uncommitted changes, then stage the approved
+files and commit them with a generated message, opened in your editor first.
+
+The message gets a "Reviewed-with: agrev" trailer, plus "Agent-session: <id>"
+when an agent trace with a session ID is loaded and "Review-comments: <n>"
+when the review left comments, which the message body lists. Rejected and
+undecided changes stay in the working tree.
+
+Examples:
+  agrev commit                     # review, edit message, commit
+  agrev commit --amend             # fold approved changes into HEAD
+  agrev commit --no-edit           # commit with the generated message as-is`,
+	Args: cobra.NoArgs,
+	RunE: runCommit,
+}
+
+func init() {
+	addSessionFlags(commitCmd)
+	commitCmd.Flags().Bool("include-untracked", false, "include untracked files as new files")
+	commitCmd.Flags().Bool("amend", false, "amend the last commit instead of creating a new one")
+	commitCmd.Flags().Bool("no-edit", false, "commit without opening the message in an editor")
+	commitCmd.Flags().Bool("no-trailers", false, "don't add Reviewed-with, Agent-session, and Review-comments trailers")
+}
+
+func runCommit(cmd *cobra.Command, args []string) error {
+	repoDir, err := gitRepoRoot()
+	if err != nil {
+		return fmt.Errorf("not in a git repository (or git not installed): %w", err)
+	}
+
+	// Anything already staged would end up in the commit unreviewed
+	if err := exec.Command("git", "-C", repoDir, "diff", "--cached", "--quiet").Run(); err != nil {
+		return fmt.Errorf("the index already has staged changes; commit or unstage them first")
+	}
+
+	s, err := runSession(cmd, args, false)
+	if err != nil || s == nil {
+		return err
+	}
+
+	patch := s.result.GeneratePatch()
+	if patch == "" {
+		fmt.Fprintln(os.Stderr, "No approved changes — nothing committed.")
+		return nil
+	}
+	if err := applyPatch(repoDir, patch, false); err != nil {
+		return fmt.Errorf("staging approved changes: %w", err)
+	}
+
+	amend, _ := cmd.Flags().GetBool("amend")
+	msg := s.result.GenerateCommitMessage()
+	if amend {
+		// Keep the commit's own message; the trailers record the review
+		out, err := exec.Command("git", "-C", repoDir, "log", "-1", "--format=%B").Output()
+		if err != nil {
+			return fmt.Errorf("reading HEAD message: %w", err)
+		}
+		msg = strings.TrimSpace(string(out))
+	}
+
+	noTrailers, _ := cmd.Flags().GetBool("no-trailers")
+	if !noTrailers {
+		trailers := [][2]string{{"Reviewed-with", "agrev"}}
+		if t := s.review.Trace; t != nil && t.SessionID != "" {
+			trailers = append(trailers, [2]string{"Agent-session", t.SessionID})
+		}
+		if n := len(s.result.Comments); n > 0 {
+			trailers = append(trailers, [2]string{"Review-comments", strconv.Itoa(n)})
+		}
+		msg = addTrailers(msg, trailers)
+	}
+
+	msgFile, err := os.CreateTemp("", "agrev-commit-*.txt")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(msgFile.Name())
+	if _, err := msgFile.WriteString(msg + "\n"); err != nil {
+		msgFile.Close()
+		return err
+	}
+	msgFile.Close()
+
+	gitArgs := []string{"-C", repoDir, "commit", "--file", msgFile.Name()}
+	if noEdit, _ := cmd.Flags().GetBool("no-edit"); !noEdit {
+		gitArgs = append(gitArgs, "--edit")
+	}
+	if amend {
+		gitArgs = append(gitArgs, "--amend")
+	}
+	gitCmd := exec.Command("git", gitArgs...)
+	gitCmd.Stdin, gitCmd.Stdout, gitCmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := gitCmd.Run(); err != nil {
+		return fmt.Errorf("git commit failed; the approved changes are still staged: %w", err)
+	}
+	return nil
+}
+
+// addTrailers appends git trailers to msg, skipping any it already has.
+// Trailers join an existing trailer block or start a new paragraph.
+func addTrailers(msg string, trailers [][2]string) string {
+	msg = strings.TrimRight(msg, "\n")
+	lines := strings.Split(msg, "\n")
+
+	var add []string
+	for _, t := range trailers {
+		line := t[0] + ": " + t[1]
+		found := false
+		for _, l := range lines {
+			if l == line {
+				found = true
+				break
+			}
+		}
+		if !found {
+			add = append(add, line)
+		}
+	}
+	if len(add) == 0 {
+		return msg
+	}
+
+	last := lines[len(lines)-1]
+	if len(lines) > 1 && isTrailer(last) {
+		return msg + "\n" + strings.Join(add, "\n")
+	}
+	return msg + "\n\n" + strings.Join(add, "\n")
+}
+
+// isTrailer reports whether line looks like a "Token: value" git trailer.
+func isTrailer(line string) bool {
+	key, _, ok := strings.Cut(line, ": ")
+	return ok && key != "" && !strings.ContainsAny(key, " \t")
+}
@@ -0,0 +1,205 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/aezell/agrev/internal/analysis"
+	"github.com/aezell/agrev/internal/diff"
+	"github.com/aezell/agrev/internal/model"
+)
+
+var compareCmd = &cobra.Command{
+	Use:   "compare <baseline> [current]",
+	Short: "Compare two analysis runs",
+	Long: `Compare two 'agrev check --format json' reports and list the findings
+that are new, fixed, and persisting. Without a current report, the diff
+is analyzed now (the working tree, or --range).
+
+Findings match when their pass, file, and message agree, so line shifts
+from unrelated edits don't count as new findings. Use "-" to read a
+report from stdin.
+
+Examples:
+  agrev check main~1..main -f json > base.json
+  agrev compare base.json --range main..HEAD
+  agrev compare base.json head.json --format markdown --fail-on high`,
+	Args: cobra.RangeArgs(1, 2),
+	RunE: runCompare,
+}
+
+func init() {
+	addSourceFlags(compareCmd)
+	compareCmd.Flags().String("range", "", "commit range to analyze when no current report is given")
+	compareCmd.Flags().StringP("format", "f", "text", "output format: text, json, markdown")
+	compareCmd.Flags().String("fail-on", "", "exit 1 if there are new findings at or above this risk")
+	compareCmd.Flags().StringSlice("skip", nil, "analysis passes to skip when analyzing the diff")
+}
+
+func runCompare(cmd *cobra.Command, args []string) error {
+	var failOn model.RiskLevel
+	if name, _ := cmd.Flags().GetString("fail-on"); name != "" {
+		var ok bool
+		if failOn, ok = model.ParseRiskLevel(name); !ok {
+			return fmt.Errorf("invalid --fail-on %q (want info, low, medium, high, or critical)", name)
+		}
+	}
+
+	baseline, err := loadReport(args[0])
+	if err != nil {
+		return err
+	}
+
+	var current *analysis.Results
+	if len(args) == 2 {
+		if current, err = loadReport(args[1]); err != nil {
+			return err
+		}
+	} else {
+		var diffArgs []string
+		if r, _ := cmd.Flags().GetString("range"); r != "" {
+			diffArgs = []string{r}
+		}
+		raw, _, err := getDiff(cmd, diffArgs, 3)
+		if err != nil {
+			return err
+		}
+		ds, err := diff.Parse(raw)
+		if err != nil {
+			return fmt.Errorf("parsing diff: %w", err)
+		}
+		repoDir, _ := gitRepoRoot()
+		current = analysis.Run(ds, repoDir, skipPasses(cmd, repoDir))
+	}
+
+	c := analysis.Compare(baseline, current)
+
+	format, _ := cmd.Flags().GetString("format")
+	switch format {
+	case "json":
+		out := struct {
+			Summary    string        `json:"summary"`
+			New        []jsonFinding `json:"new"`
+			Fixed      []jsonFinding `json:"fixed"`
+			Persisting []jsonFinding `json:"persisting"`
+		}{c.Summary(), jsonFindings(c.New), jsonFindings(c.Fixed), jsonFindings(c.Persisting)}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(out); err != nil {
+			return err
+		}
+	case "markdown":
+		fmt.Print(compareMarkdown(c))
+	case "text":
+		printComparison(c)
+	default:
+		return fmt.Errorf("unknown format %q (want text, json, or markdown)", format)
+	}
+
+	if cmd.Flags().Changed("fail-on") && len((&analysis.Results{Findings: c.New}).ByRisk(failOn)) > 0 {
+		os.Exit(1)
+	}
+	return nil
+}
+
+// loadReport reads findings from an 'agrev check --format json' report.
+func loadReport(path string) (*analysis.Results, error) {
+	var data []byte
+	var err error
+	if path == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading report: %w", err)
+	}
+
+	var report jsonReport
+	if err := json.Unmarshal(data, &report); err != nil {
+		return nil, fmt.Errorf("parsing report %s: %w (want 'agrev check --format json' output)", path, err)
+	}
+
+	results := &analysis.Results{}
+	for _, f := range report.Findings {
+		risk, ok := model.ParseRiskLevel(f.Risk)
+		if !ok {
+			return nil, fmt.Errorf("parsing report %s: unknown risk %q", path, f.Risk)
+		}
+		results.Findings = append(results.Findings, analysis.Finding{
+			Pass:     f.Pass,
+			Rule:     f.Rule,
+			File:     f.File,
+			Line:     f.Line,
+			Message:  f.Message,
+			Severity: parseSeverity(f.Severity),
+			Risk:     risk,
+		})
+	}
+	return results, nil
+}
+
+func parseSeverity(s string) model.Severity {
+	switch s {
+	case "error":
+		return model.SeverityError
+	case "warning":
+		return model.SeverityWarning
+	default:
+		return model.SeverityInfo
+	}
+}
+
+func printComparison(c *analysis.Comparison) {
+	fmt.Println(c.Summary())
+	section := func(title, mark string, findings []analysis.Finding) {
+		if len(findings) == 0 {
+			return
+		}
+		fmt.Printf("\n%s (%d)\n", title, len(findings))
+		for _, f := range findings {
+			fmt.Printf("  %s %s %s\n", mark, riskIcon(f.Risk), f)
+		}
+	}
+	section("New", "+", c.New)
+	section("Fixed", "-", c.Fixed)
+	section("Persisting", " ", c.Persisting)
+}
+
+// compareMarkdown renders a comparison for a PR comment. Persisting
+// findings are folded away since they aren't the change's doing.
+func compareMarkdown(c *analysis.Comparison) string {
+	var b strings.Builder
+	b.WriteString("## agrev: findings compared to baseline\n\n")
+	fmt.Fprintf(&b, "%s.\n", c.Summary())
+
+	table := func(findings []analysis.Finding) {
+		b.WriteString("| Risk | Pass | File | Message |\n")
+		b.WriteString("|------|------|------|---------|\n")
+		for _, f := range findings {
+			loc := f.File
+			if f.Line > 0 {
+				loc = fmt.Sprintf("%s:%d", f.File, f.Line)
+			}
+			fmt.Fprintf(&b, "| %s | %s | `%s` | %s |\n", f.Risk, f.Pass, loc, f.Message)
+		}
+	}
+	if len(c.New) > 0 {
+		fmt.Fprintf(&b, "\n### New (%d)\n\n", len(c.New))
+		table(c.New)
+	}
+	if len(c.Fixed) > 0 {
+		fmt.Fprintf(&b, "\n### Fixed (%d)\n\n", len(c.Fixed))
+		table(c.Fixed)
+	}
+	if len(c.Persisting) > 0 {
+		fmt.Fprintf(&b, "\n<details><summary>Persisting (%d)</summary>\n\n", len(c.Persisting))
+		table(c.Persisting)
+		b.WriteString("\n</details>\n")
+	}
+	return b.String()
+}
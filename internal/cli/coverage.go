@@ -0,0 +1,54 @@
+package cli
+
+import (
+	"context"
+
+	"github.com/spf13/cobra"
+	"github.com/aezell/agrev/internal/analysis"
+	"github.com/aezell/agrev/internal/coverage"
+	"github.com/aezell/agrev/internal/diff"
+)
+
+// addCoverageFlag gives a command that analyzes changes the --coverage
+// flag.
+func addCoverageFlag(cmd *cobra.Command) {
+	cmd.Flags().String("coverage", "", "coverage report (Go cover profile, LCOV, or Cobertura XML) to flag added lines no test runs")
+}
+
+// loadCoverage reads the report --coverage names, if the command has the
+// flag, into the command's context for the coverage pass.
+func loadCoverage(cmd *cobra.Command, args []string) error {
+	if cmd.Flags().Lookup("coverage") == nil {
+		return nil
+	}
+	path, _ := cmd.Flags().GetString("coverage")
+	if path == "" {
+		return nil
+	}
+	p, err := coverage.Load(path)
+	if err != nil {
+		return err
+	}
+	cmd.SetContext(analysis.WithCoverage(commandContext(cmd), p))
+	return nil
+}
+
+// commandContext is the command's context, which is nil until it runs.
+func commandContext(cmd *cobra.Command) context.Context {
+	if ctx := cmd.Context(); ctx != nil {
+		return ctx
+	}
+	return context.Background()
+}
+
+// runAnalysis runs the analysis passes with the coverage report --coverage
+// gave.
+func runAnalysis(cmd *cobra.Command, ds *diff.DiffSet, repoDir string, skip []string) *analysis.Results {
+	results, err := analysis.RunContext(commandContext(cmd), ds, repoDir, skip)
+	if err != nil {
+		// RunContext fails only when the context is cancelled, which the
+		// commands' contexts aren't
+		return &analysis.Results{}
+	}
+	return results
+}
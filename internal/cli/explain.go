@@ -0,0 +1,121 @@
+package cli
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/aezell/agrev/internal/analysis"
+	"github.com/aezell/agrev/internal/config"
+	"github.com/aezell/agrev/internal/diff"
+	"github.com/aezell/agrev/internal/explain"
+)
+
+var explainCmd = &cobra.Command{
+	Use:   "explain <file>[:line] [commit-range]",
+	Short: "Explain a hunk and its findings with an LLM",
+	Long: `Send a hunk, the code around it, and its analysis findings to the LLM
+configured under 'explain' in .agrev.yml, and print the explanation and
+suggested fixes. With a line, only the hunk containing it is explained;
+otherwise every hunk in the file is.
+
+Explanations are opt-in: nothing is sent anywhere unless explain.endpoint
+is set. Use --prompt to see exactly what would be sent.
+
+Examples:
+  agrev explain internal/auth/token.go:42
+  agrev explain db/migrate.sql HEAD~3..HEAD
+  agrev explain main.go --prompt`,
+	Args: cobra.RangeArgs(1, 2),
+	RunE: runExplain,
+}
+
+func init() {
+	addSourceFlags(explainCmd)
+	explainCmd.Flags().Bool("prompt", false, "print the prompt instead of sending it")
+	explainCmd.Flags().StringSlice("skip", nil, "analysis passes to skip")
+}
+
+func runExplain(cmd *cobra.Command, args []string) error {
+	name, line := args[0], 0
+	if i := strings.LastIndex(name, ":"); i > 0 {
+		if n, err := strconv.Atoi(name[i+1:]); err == nil && n > 0 {
+			name, line = name[:i], n
+		}
+	}
+
+	raw, _, err := getDiff(cmd, args[1:], 3)
+	if err != nil {
+		return err
+	}
+	ds, err := diff.Parse(raw)
+	if err != nil {
+		return fmt.Errorf("parsing diff: %w", err)
+	}
+	f := findFile(ds, name)
+	if f == nil {
+		return fmt.Errorf("%s is not in the diff", name)
+	}
+	if len(f.Fragments) == 0 {
+		return fmt.Errorf("%s has no text changes to explain", name)
+	}
+
+	hunks := explainHunks(f, line)
+	if len(hunks) == 0 {
+		return fmt.Errorf("no hunk in %s contains line %d", name, line)
+	}
+
+	repoDir, _ := gitRepoRoot()
+	cfg, err := config.Load(repoDir)
+	if err != nil {
+		return err
+	}
+	onlyPrompt, _ := cmd.Flags().GetBool("prompt")
+	var client *explain.Client
+	if !onlyPrompt {
+		if client, err = explain.New(cfg.Explain); err != nil {
+			return err
+		}
+	}
+
+	findings := analysis.Run(ds, repoDir, skipPasses(cmd, repoDir)).ByFile()[f.Name()]
+	content, _ := diff.NewContent(repoDir, f) // context is best effort
+
+	for n, h := range hunks {
+		req := explain.NewRequest(f, h, content, findings)
+		if n > 0 {
+			fmt.Println()
+		}
+		if len(hunks) > 1 || line == 0 {
+			fmt.Printf("## %s, hunk %d/%d\n\n", f.Name(), h+1, len(f.Fragments))
+		}
+		if onlyPrompt {
+			fmt.Print(req.Prompt())
+			continue
+		}
+		text, err := client.Explain(req)
+		if err != nil {
+			return err
+		}
+		fmt.Println(text)
+	}
+	return nil
+}
+
+// explainHunks returns the hunks of f to explain: the one containing line
+// (in the new file, or the old one for deletions), or all of them when
+// line is 0.
+func explainHunks(f *diff.File, line int) []int {
+	var hunks []int
+	for i, frag := range f.Fragments {
+		start, count := frag.NewPosition, frag.NewLines
+		if f.IsDeleted {
+			start, count = frag.OldPosition, frag.OldLines
+		}
+		if line == 0 || (int64(line) >= start && int64(line) < start+count) {
+			hunks = append(hunks, i)
+		}
+	}
+	return hunks
+}
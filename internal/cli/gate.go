@@ -0,0 +1,252 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/aezell/agrev/internal/config"
+	"github.com/aezell/agrev/internal/diff"
+	"github.com/aezell/agrev/internal/gate"
+	"github.com/aezell/agrev/internal/model"
+	"github.com/aezell/agrev/internal/notify"
+	"github.com/aezell/agrev/internal/owners"
+	"github.com/aezell/agrev/internal/policy"
+	"github.com/aezell/agrev/internal/report"
+	"github.com/aezell/agrev/internal/review"
+)
+
+var gateCmd = &cobra.Command{
+	Use:   "gate [commit-range | patch...]",
+	Short: "Enforce a review policy on the diff (for CI)",
+	Long: `Run analysis on the diff and check the changes against the gate policy
+in .agrev.yml (or the file given with --policy), and the rules in
+.agrev/policy.yml if there are any:
+
+  gate:
+    max_risk: high              # highest finding risk allowed from any pass
+    passes:
+      security: medium          # per-pass overrides
+    forbidden_paths:
+      - ".github/workflows/*"
+    require_tests:
+      - paths: ["internal/**/*.go"]
+        tests: ["**/*_test.go"]
+    require_owner_approval: true  # files with CODEOWNERS owners must be
+                                  # approved one by one in the saved review
+
+Rules in .agrev/policy.yml block the change or require a file to be
+approved on its own in the saved review, by path, pass, and risk:
+
+  rules:
+    - name: schema-review
+      action: require_approval  # or block, or auto_approve (review only)
+      pass: schema
+      risk: medium              # findings at or above
+      paths: ["db/**"]
+  require_checklist: true       # the checklist in .agrev.yml must be checked
+                                # off in the saved review
+
+Exit codes:
+  0 — the change passes the policy
+  1 — policy violations found, or the gate could not run`,
+	Args: cobra.ArbitraryArgs,
+	RunE: runGate,
+}
+
+func init() {
+	addSourceFlags(gateCmd)
+	gateCmd.Flags().String("policy", "", "policy file (default .agrev.yml at the repository root)")
+	gateCmd.Flags().StringP("format", "f", "text", "output format: text, json")
+	gateCmd.Flags().StringSlice("skip", nil, "analysis passes to skip")
+	addCoverageFlag(gateCmd)
+	gateCmd.Flags().String("session", "", "saved review to check owner approvals against (default .agrev/session.json)")
+}
+
+func runGate(cmd *cobra.Command, args []string) error {
+	repoDir, _ := gitRepoRoot()
+
+	var pol *policy.Policy
+	if repoDir != "" {
+		var err error
+		if pol, err = policy.Load(repoDir); err != nil {
+			return err
+		}
+	}
+
+	policyPath, _ := cmd.Flags().GetString("policy")
+	explicit := policyPath != ""
+	if !explicit {
+		if repoDir == "" {
+			return fmt.Errorf("not in a git repository; pass --policy")
+		}
+		policyPath = filepath.Join(repoDir, config.FileName)
+	}
+	// The rules in .agrev/policy.yml can do without a gate section
+	if _, err := os.Stat(policyPath); err != nil && (explicit || pol == nil) {
+		return fmt.Errorf("reading policy: %w", err)
+	}
+	cfg, err := config.LoadFile(policyPath)
+	if err != nil {
+		return err
+	}
+	if cfg.Gate.IsZero() && pol == nil {
+		return fmt.Errorf("%s has no gate policy", policyPath)
+	}
+
+	raw, _, err := getDiff(cmd, args, 3)
+	if err != nil {
+		return err
+	}
+	ds, err := diff.Parse(raw)
+	if err != nil {
+		return fmt.Errorf("parsing diff: %w", err)
+	}
+
+	skip, _ := cmd.Flags().GetStringSlice("skip")
+	results := analyze(cmd, ds, repoDir, append(skip, cfg.Analysis.Skip...))
+
+	hits := pol.Evaluate(ds, results)
+	var review *gate.Review
+	var checklist []model.ChecklistItem
+	if cfg.Gate.RequireOwnerApproval || len(hits) > 0 || pol.RequiresChecklist() {
+		if review, checklist, err = gateReview(cmd, repoDir, ds, cfg.Gate.RequireOwnerApproval, cfg.Checklist); err != nil {
+			return err
+		}
+	}
+
+	violations, err := gate.Evaluate(cfg.Gate, ds, results, review)
+	if err != nil {
+		return err
+	}
+	if pol.RequiresChecklist() {
+		violations = append(violations, policy.ChecklistViolations(checklist)...)
+	}
+	if len(hits) > 0 {
+		violations = append(violations, policy.Violations(ds, hits, review.Decisions)...)
+		sort.SliceStable(violations, func(i, j int) bool {
+			return violations[i].File < violations[j].File
+		})
+	}
+
+	format, _ := cmd.Flags().GetString("format")
+	switch format {
+	case "json":
+		err = outputGateJSON(policyPath, ds, violations)
+	case "text":
+		outputGateText(ds, violations)
+	default:
+		return fmt.Errorf("unknown format %q (want text or json)", format)
+	}
+	if err != nil {
+		return err
+	}
+	nFiles, added, deleted := ds.Stats()
+	notifyWebhooks(cfg, notify.Summary{
+		Command: "gate", Subject: reviewRange(sessionSource{args: args}),
+		Files: nFiles, Added: added, Deleted: deleted, Results: results,
+		Report: &report.Report{Diff: ds, Results: results, Generated: time.Now()},
+		Gated:  true, Violations: len(violations),
+	})
+
+	if len(violations) > 0 {
+		os.Exit(1)
+	}
+	return nil
+}
+
+// gateReview loads the saved review's decisions on ds, for the rules
+// requiring approval, and with owners the CODEOWNERS rules. It also returns
+// the review checklist for ds, with the items the saved review checked off.
+func gateReview(cmd *cobra.Command, repoDir string, ds *diff.DiffSet, withOwners bool, items []config.ChecklistItem) (*gate.Review, []model.ChecklistItem, error) {
+	gr := &gate.Review{Decisions: model.NewDecisions()}
+	if withOwners && repoDir != "" {
+		co, err := owners.Load(repoDir)
+		if err != nil {
+			return nil, nil, err
+		}
+		gr.Owners = co
+	}
+	if withOwners && gr.Owners == nil {
+		fmt.Fprintln(os.Stderr, "Warning: require_owner_approval is set, but there is no CODEOWNERS file")
+	}
+
+	saved, err := loadSavedSession(cmd, repoDir)
+	if err != nil {
+		return nil, nil, err
+	}
+	rs := &review.Session{Diff: ds}
+	rs.SetChecklist(items)
+	if saved != nil {
+		saved.Restore(rs)
+		gr.Decisions = rs.Decisions
+	}
+	return gr, rs.Checklist, nil
+}
+
+func outputGateText(ds *diff.DiffSet, violations []gate.Violation) {
+	nFiles, added, deleted := ds.Stats()
+	fmt.Printf("%d file(s) changed, +%d -%d\n", nFiles, added, deleted)
+
+	if len(violations) == 0 {
+		fmt.Println("Gate passed.")
+		return
+	}
+
+	fmt.Printf("Gate failed: %d violation(s)\n\n", len(violations))
+	for _, v := range violations {
+		loc := v.File
+		if v.Line > 0 {
+			loc += fmt.Sprintf(":%d", v.Line)
+		}
+		if loc != "" {
+			loc += ": "
+		}
+		rule := v.Rule
+		switch {
+		case v.Policy != "":
+			rule += "/" + v.Policy
+		case v.Pass != "":
+			rule += "/" + v.Pass
+		}
+		fmt.Printf("  ✗ [%s] %s%s\n", rule, loc, v.Message)
+	}
+}
+
+func outputGateJSON(policyPath string, ds *diff.DiffSet, violations []gate.Violation) error {
+	type jsonViolation struct {
+		gate.Violation
+		Risk string `json:"risk,omitempty"`
+	}
+
+	out := struct {
+		Passed     bool            `json:"passed"`
+		Policy     string          `json:"policy"`
+		Files      int             `json:"files"`
+		Violations []jsonViolation `json:"violations"`
+	}{
+		Passed:     len(violations) == 0,
+		Policy:     policyPath,
+		Files:      len(ds.Files),
+		Violations: []jsonViolation{},
+	}
+	for _, v := range violations {
+		jv := jsonViolation{Violation: v}
+		if v.Rule == gate.RuleMaxRisk || v.Rule == gate.RulePolicy && v.Pass != "" {
+			jv.Risk = v.Risk.String()
+		}
+		out.Violations = append(out.Violations, jv)
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(out); err != nil {
+		return fmt.Errorf("encoding report: %w", err)
+	}
+	return nil
+}
+
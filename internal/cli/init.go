@@ -0,0 +1,118 @@
+package cli
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/aezell/agrev/internal/config"
+	"github.com/aezell/agrev/internal/model"
+)
+
+var initCmd = &cobra.Command{
+	Use:   "init",
+	Short: "Write a .agrev.yml tailored to this repository",
+	Long: `Inspect the repository (languages, package managers, CI, and agent
+tooling), ask a few questions, and write a commented .agrev.yml with
+analysis passes and an 'agrev gate' policy to match.
+
+Use --yes to accept the suggested answers without prompting.`,
+	Args: cobra.NoArgs,
+	RunE: runInit,
+}
+
+func init() {
+	initCmd.Flags().BoolP("yes", "y", false, "accept the defaults without prompting")
+	initCmd.Flags().Bool("force", false, "overwrite an existing .agrev.yml")
+	initCmd.Flags().Bool("stdout", false, "print the config instead of writing it")
+}
+
+func runInit(cmd *cobra.Command, args []string) error {
+	repoDir, err := gitRepoRoot()
+	if err != nil {
+		return fmt.Errorf("not in a git repository (or git not installed): %w", err)
+	}
+
+	toStdout, _ := cmd.Flags().GetBool("stdout")
+	path := filepath.Join(repoDir, config.FileName)
+	if force, _ := cmd.Flags().GetBool("force"); !force && !toStdout {
+		if _, err := os.Stat(path); err == nil {
+			return fmt.Errorf("%s already exists; use --force to overwrite it", config.FileName)
+		}
+	}
+
+	p, err := config.Inspect(repoDir)
+	if err != nil {
+		return err
+	}
+	describeProfile(p)
+
+	yes, _ := cmd.Flags().GetBool("yes")
+	in := bufio.NewReader(os.Stdin)
+	ask := func(question, def string) string {
+		if yes || !isTerminal(os.Stdin) {
+			return def
+		}
+		fmt.Fprintf(os.Stderr, "%s [%s] ", question, def)
+		answer, _ := in.ReadString('\n')
+		if answer = strings.TrimSpace(answer); answer != "" {
+			return answer
+		}
+		return def
+	}
+	askYes := func(question string, def bool) bool {
+		d := "y"
+		if !def {
+			d = "n"
+		}
+		answer := strings.ToLower(ask(question+" (y/n)", d))
+		return answer == "y" || answer == "yes"
+	}
+
+	opts := config.ScaffoldOptions{Theme: ask("TUI theme (dark, light, high-contrast)?", "dark")}
+	if opts.Theme == "dark" {
+		opts.Theme = "" // the default; leave it out
+	}
+	opts.Gate = askYes("Add a policy for 'agrev gate' in CI?", len(p.CI) > 0)
+	if opts.Gate {
+		for {
+			opts.MaxRisk = ask("Highest finding risk to allow (info, low, medium, high, critical)?", "high")
+			if _, ok := model.ParseRiskLevel(opts.MaxRisk); ok {
+				break
+			}
+			if yes {
+				return fmt.Errorf("invalid risk level %q", opts.MaxRisk)
+			}
+			fmt.Fprintf(os.Stderr, "Unknown risk level %q.\n", opts.MaxRisk)
+		}
+		opts.RequireTests = askYes("Require test changes alongside source changes?", true)
+	}
+
+	out := config.Scaffold(p, opts)
+	if toStdout {
+		fmt.Print(out)
+		return nil
+	}
+	if err := os.WriteFile(path, []byte(out), 0644); err != nil {
+		return fmt.Errorf("writing %s: %w", config.FileName, err)
+	}
+	fmt.Fprintf(os.Stderr, "Wrote %s\n", path)
+	return nil
+}
+
+// describeProfile prints what init found in the repository.
+func describeProfile(p *config.Profile) {
+	list := func(items []string) string {
+		if len(items) == 0 {
+			return "none found"
+		}
+		return strings.Join(items, ", ")
+	}
+	fmt.Fprintf(os.Stderr, "Languages:        %s\n", list(p.LanguageNames()))
+	fmt.Fprintf(os.Stderr, "Package managers: %s\n", list(p.PackageManagers))
+	fmt.Fprintf(os.Stderr, "CI:               %s\n", list(p.CI))
+	fmt.Fprintf(os.Stderr, "Agent tooling:    %s\n\n", list(p.Agents))
+}
@@ -0,0 +1,56 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/aezell/agrev/internal/audit"
+	"github.com/aezell/agrev/internal/config"
+	"github.com/aezell/agrev/internal/lsp"
+)
+
+var lspCmd = &cobra.Command{
+	Use:   "lsp [commit-range]",
+	Short: "Run a language server that shows findings in your editor",
+	Long: `Speak the Language Server Protocol on stdin and stdout, publishing the
+analysis findings on the working tree's changes (or a commit range) as
+diagnostics. Findings are analyzed again whenever a file is saved.
+
+Each diagnostic has code actions that mark its finding as a false positive
+or acknowledge it, recorded in .agrev/findings.json and the audit log as
+in the TUI.
+
+Point your editor's LSP client at 'agrev lsp', started in the repository.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runLSP,
+}
+
+func init() {
+	addSourceFlags(lspCmd)
+	lspCmd.Flags().StringSlice("skip", nil, "analysis passes to skip")
+}
+
+func runLSP(cmd *cobra.Command, args []string) error {
+	repoDir, err := gitRepoRoot()
+	if err != nil {
+		return fmt.Errorf("not in a git repository (or git not installed): %w", err)
+	}
+	s := &lsp.Server{
+		RepoDir: repoDir,
+		Version: version,
+		Skip:    skipPasses(cmd, repoDir),
+		Diff: func() (string, error) {
+			raw, _, err := getDiff(cmd, args, 3)
+			return raw, err
+		},
+		Author: gitUserName(repoDir),
+	}
+	if cfg, err := config.Load(repoDir); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	} else if !cfg.NoAudit {
+		s.Audit = audit.New(repoDir, "lsp")
+		s.Audit.Reviewer = gitIdentity(repoDir)
+	}
+	return s.Serve(os.Stdin, os.Stdout)
+}
@@ -0,0 +1,217 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/aezell/agrev/internal/provider"
+)
+
+var prCmd = &cobra.Command{
+	Use:   "pr <number | url>",
+	Short: "Review a pull request from GitHub, Bitbucket, or Gerrit",
+	Long: `Fetch a pull request's diff and metadata from the forge's API and open it
+in an interactive review. The forge is picked from the URL or the origin
+remote, or set with --provider:
+
+  github     token from GITHUB_TOKEN, GH_TOKEN, or 'gh auth token';
+             GITHUB_API_URL for GitHub Enterprise
+  bitbucket  BITBUCKET_TOKEN, or BITBUCKET_USERNAME and BITBUCKET_APP_PASSWORD
+  gerrit     GERRIT_URL, GERRIT_USERNAME, GERRIT_PASSWORD; changes are given
+             by number, Change-Id, or URL
+
+When the current repository has a remote for the pull request's repository,
+its head commit is fetched so full file contents are available for context
+expansion.
+
+Examples:
+  agrev pr 123                                 # PR in this repo's origin
+  agrev pr --repo aezell/agrev 123
+  agrev pr https://github.com/aezell/agrev/pull/123
+  agrev pr https://bitbucket.org/team/app/pull-requests/7
+  agrev pr https://review.example.com/c/project/+/4711`,
+	Args: cobra.ExactArgs(1),
+	RunE: runPR,
+}
+
+func init() {
+	addSessionFlags(prCmd)
+	addProviderFlags(prCmd)
+	prCmd.Flags().Bool("stat", false, "print diff stats and exit (non-interactive)")
+	prCmd.Flags().String("report", "", "write a markdown review report (decisions and comments) to file")
+}
+
+func runPR(cmd *cobra.Command, args []string) error {
+	p, err := loadPR(cmd, args[0])
+	if err != nil {
+		return err
+	}
+	stat, _ := cmd.Flags().GetBool("stat")
+	s, err := reviewPR(cmd, p, stat)
+	if err != nil || s == nil {
+		return err
+	}
+
+	reportPath, _ := cmd.Flags().GetString("report")
+	if reportPath != "" {
+		if err := os.WriteFile(reportPath, []byte(s.result.GenerateReport()), 0644); err != nil {
+			return fmt.Errorf("writing report: %w", err)
+		}
+		fmt.Fprintf(os.Stderr, "Report written to %s\n", reportPath)
+	}
+	return nil
+}
+
+// addProviderFlags registers the flags that select a forge and repository.
+func addProviderFlags(c *cobra.Command) {
+	c.Flags().StringP("repo", "R", "", "repository as owner/name, or the Gerrit project (default: from the origin remote)")
+	c.Flags().String("provider", "", "forge: "+strings.Join(provider.Names(), ", ")+" (default: detected from the URL or remote)")
+}
+
+// pullRequest is a change fetched for review.
+type pullRequest struct {
+	provider provider.Provider
+	change   *provider.Change
+	repoDir  string // local repository, if any
+}
+
+// loadPR fetches the pull request or change named by arg, resolving bare
+// numbers against the --repo flag or the local repository.
+func loadPR(cmd *cobra.Command, arg string) (*pullRequest, error) {
+	repoDir, _ := gitRepoRoot()
+	remote := originRemote(repoDir)
+
+	name, _ := cmd.Flags().GetString("provider")
+	p, err := provider.Detect(name, arg, remote)
+	if err != nil {
+		return nil, err
+	}
+
+	repo, _ := cmd.Flags().GetString("repo")
+	if repo == "" {
+		repo = defaultRepo(p.Name(), remote)
+	}
+	ref, err := p.ParseRef(arg, repo)
+	if err != nil {
+		return nil, err
+	}
+	c, err := p.Fetch(ref)
+	if err != nil {
+		return nil, err
+	}
+
+	fmt.Fprintf(os.Stderr, "%s: %s\n", c, c.Title)
+	if c.Source != "" {
+		fmt.Fprintf(os.Stderr, "  %s wants to merge %s into %s (%s)\n", c.Author, c.Source, c.Target, shortSHA(c.HeadSHA))
+	} else {
+		fmt.Fprintf(os.Stderr, "  by %s, for %s (%s)\n", c.Author, c.Target, shortSHA(c.HeadSHA))
+	}
+	return &pullRequest{provider: p, change: c, repoDir: repoDir}, nil
+}
+
+// reviewPR opens an interactive review of a fetched pull request.
+func reviewPR(cmd *cobra.Command, p *pullRequest, stat bool) (*session, error) {
+	if watch, _ := cmd.Flags().GetBool("watch"); watch {
+		return nil, fmt.Errorf("--watch cannot be used with a pull request")
+	}
+
+	// A local trace belongs to the working tree, not to someone's PR
+	if !cmd.Flags().Changed("trace") {
+		cmd.Flags().Set("no-trace", "true")
+	}
+
+	src := sessionSource{label: p.change.Label, repoDir: "-", stat: stat}
+	if p.repoDir != "" && !stat && fetchPRHead(p.repoDir, p.change) {
+		src.repoDir = p.repoDir
+	}
+	return reviewDiff(cmd, p.change.Diff, src)
+}
+
+// defaultRepo guesses the repository for bare pull request numbers: from
+// the CI environment, otherwise from the local remote.
+func defaultRepo(providerName, remote string) string {
+	env := map[string]string{
+		"github":    "GITHUB_REPOSITORY",
+		"bitbucket": "BITBUCKET_REPO_FULL_NAME",
+	}
+	if r := os.Getenv(env[providerName]); env[providerName] != "" && r != "" {
+		return r
+	}
+	if repo, ok := provider.RepoFromRemote(remote); ok {
+		return repo
+	}
+	return ""
+}
+
+// originRemote returns the URL of the origin remote, or of the first remote
+// if there is no origin.
+func originRemote(repoDir string) string {
+	if repoDir == "" {
+		return ""
+	}
+	remotes := gitRemotes(repoDir)
+	if u, ok := remotes["origin"]; ok {
+		return u
+	}
+	for _, u := range remotes {
+		return u
+	}
+	return ""
+}
+
+// gitRemotes maps remote names to their fetch URLs.
+func gitRemotes(repoDir string) map[string]string {
+	out, err := exec.Command("git", "-C", repoDir, "remote", "-v").Output()
+	if err != nil {
+		return nil
+	}
+	remotes := make(map[string]string)
+	for _, line := range strings.Split(string(out), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 3 && fields[2] == "(fetch)" {
+			remotes[fields[0]] = fields[1]
+		}
+	}
+	return remotes
+}
+
+// fetchPRHead makes the change's head commit available locally, fetching
+// it from a matching remote if needed. It reports whether the commit is
+// present.
+func fetchPRHead(repoDir string, c *provider.Change) bool {
+	hasCommit := func() bool {
+		return exec.Command("git", "-C", repoDir, "cat-file", "-e", c.HeadSHA+"^{commit}").Run() == nil
+	}
+	if c.HeadSHA == "" {
+		return false
+	}
+	if hasCommit() {
+		return true
+	}
+	if c.FetchRef == "" {
+		return false
+	}
+
+	for name, u := range gitRemotes(repoDir) {
+		if !c.MatchesRemote(u) {
+			continue
+		}
+		fmt.Fprintf(os.Stderr, "Fetching %s from %s...\n", shortSHA(c.HeadSHA), name)
+		if err := exec.Command("git", "-C", repoDir, "fetch", "--quiet", name, c.FetchRef).Run(); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: could not fetch %s: %v\n", c.FetchRef, err)
+			return false
+		}
+		return hasCommit()
+	}
+	return false
+}
+
+func shortSHA(sha string) string {
+	if len(sha) > 7 {
+		return sha[:7]
+	}
+	return sha
+}
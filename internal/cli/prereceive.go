@@ -0,0 +1,255 @@
+package cli
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/aezell/agrev/internal/analysis"
+	"github.com/aezell/agrev/internal/config"
+	"github.com/aezell/agrev/internal/diff"
+	"github.com/aezell/agrev/internal/gate"
+	"github.com/aezell/agrev/internal/history"
+	"github.com/aezell/agrev/internal/model"
+	"github.com/aezell/agrev/internal/notify"
+	"github.com/aezell/agrev/internal/policy"
+	"github.com/aezell/agrev/internal/report"
+)
+
+var preReceiveCmd = &cobra.Command{
+	Use:   "pre-receive",
+	Short: "Enforce the review policy on pushes (for a git server hook)",
+	Long: `Check pushed changes against the gate policy in .agrev.yml and the block
+rules in .agrev/policy.yml, and reject the push if they are violated. Run
+it from the pre-receive hook of the repository on the git server:
+
+  #!/bin/sh
+  exec agrev pre-receive
+
+Git gives the hook a line "<old> <new> <ref>" on stdin for each ref pushed.
+A branch is diffed against its old tip, and a new branch against its
+merge-base with HEAD. Deleted refs, and refs not matching --refs (by
+default every branch), are let through.
+
+The policy is read from HEAD, the default branch as it was before the
+push, so a push can't loosen the policy it is checked against; --policy
+names a gate policy file on the server instead. A repository without a
+policy accepts every push.
+
+There is no working tree on the server, so the passes that need one find
+nothing, and the checks that need a saved review, require_owner_approval,
+require_approval rules and require_checklist, are left to 'agrev gate'.
+
+Exit codes:
+  0 — the pushed changes pass the policy
+  1 — policy violations found, and the push is rejected`,
+	Args: cobra.NoArgs,
+	RunE: runPreReceive,
+}
+
+func init() {
+	preReceiveCmd.Flags().String("policy", "", "gate policy file on the server (default .agrev.yml on HEAD)")
+	preReceiveCmd.Flags().StringSlice("refs", []string{"refs/heads/**"}, "refs to check, as globs")
+	preReceiveCmd.Flags().StringSlice("skip", nil, "analysis passes to skip")
+}
+
+// refUpdate is a ref a push updates, as git gives it to the pre-receive
+// hook.
+type refUpdate struct {
+	old, new, ref string
+}
+
+// readRefUpdates reads the hook's "<old> <new> <ref>" lines.
+func readRefUpdates(r io.Reader) ([]refUpdate, error) {
+	var updates []refUpdate
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		fields := strings.Fields(sc.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("malformed ref update %q (want \"<old> <new> <ref>\")", sc.Text())
+		}
+		updates = append(updates, refUpdate{old: fields[0], new: fields[1], ref: fields[2]})
+	}
+	return updates, sc.Err()
+}
+
+// isZeroOID reports whether id is git's all-zero object ID, which stands
+// for a ref that doesn't exist on one side of the update.
+func isZeroOID(id string) bool {
+	return strings.Trim(id, "0") == ""
+}
+
+func runPreReceive(cmd *cobra.Command, args []string) error {
+	out, err := exec.Command("git", "rev-parse", "--absolute-git-dir").Output()
+	if err != nil {
+		return fmt.Errorf("not in a git repository: %w", err)
+	}
+	gitDir := strings.TrimSpace(string(out))
+
+	cfg, pol, err := pushPolicy(cmd, gitDir)
+	if err != nil {
+		return err
+	}
+	updates, err := readRefUpdates(cmd.InOrStdin())
+	if err != nil {
+		return err
+	}
+	if cfg.Gate.IsZero() && pol == nil {
+		fmt.Println("agrev: no gate policy; push not checked")
+		return nil
+	}
+
+	globs, _ := cmd.Flags().GetStringSlice("refs")
+	skip, _ := cmd.Flags().GetStringSlice("skip")
+	skip = append(skip, cfg.Analysis.Skip...)
+	rejected := 0
+	for _, u := range updates {
+		if isZeroOID(u.new) || !matchesRef(globs, u.ref) {
+			continue
+		}
+		ds, results, violations, err := checkRefUpdate(cmd, gitDir, cfg, pol, skip, u)
+		if err != nil {
+			return err
+		}
+		if ds == nil {
+			continue
+		}
+
+		fmt.Printf("agrev: %s (%s)\n", u.ref, ds.Range.Short())
+		outputGateText(ds, violations)
+		nFiles, added, deleted := ds.Stats()
+		notifyWebhooks(cfg, notify.Summary{
+			Command: "pre-receive", Subject: u.ref + " " + ds.Range.Short(),
+			Files: nFiles, Added: added, Deleted: deleted, Results: results,
+			Report: &report.Report{Diff: ds, Results: results, Generated: time.Now()},
+			Gated:  true, Violations: len(violations),
+		})
+		if len(violations) > 0 {
+			rejected++
+		}
+	}
+
+	if rejected > 0 {
+		fmt.Printf("\nagrev: push rejected: %d ref(s) violate the review policy. Fix the changes and push again.\n", rejected)
+		os.Exit(1)
+	}
+	return nil
+}
+
+// checkRefUpdate diffs a pushed ref and checks the change against the
+// gate policy and the block rules of pol. ds is nil if the ref changes
+// nothing.
+func checkRefUpdate(cmd *cobra.Command, gitDir string, cfg *config.Config, pol *policy.Policy, skip []string, u refUpdate) (*diff.DiffSet, *analysis.Results, []gate.Violation, error) {
+	base, err := pushBase(gitDir, u)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	raw, err := diff.GitDiff(gitDir, "-U3", base, u.new)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	ds, err := diff.Parse(raw)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("parsing diff of %s: %w", u.ref, err)
+	}
+	ds.Range = diff.Range{Base: base, Head: u.new}
+	if len(ds.Files) == 0 {
+		return nil, nil, nil, nil
+	}
+
+	results := runAnalysis(cmd, ds, "", skip)
+	violations, err := gate.Evaluate(cfg.Gate, ds, results, nil)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	// Only blocks: approvals are given in a review the server doesn't see
+	hits := policy.Blocked(pol.Evaluate(ds, results))
+	if len(hits) > 0 {
+		violations = append(violations, policy.Violations(ds, hits, model.NewDecisions())...)
+		sort.SliceStable(violations, func(i, j int) bool {
+			return violations[i].File < violations[j].File
+		})
+	}
+	return ds, results, violations, nil
+}
+
+// pushPolicy reads the gate policy from --policy, or else from .agrev.yml
+// on HEAD, and the rules of .agrev/policy.yml on HEAD. Either may be
+// missing; the policy is then empty or nil.
+func pushPolicy(cmd *cobra.Command, gitDir string) (*config.Config, *policy.Policy, error) {
+	var pol *policy.Policy
+	policyFile := path.Join(history.Dir, policy.FileName)
+	if data, ok, err := gitBlob(gitDir, "HEAD", policyFile); err != nil {
+		return nil, nil, err
+	} else if ok {
+		if pol, err = policy.Parse(data); err != nil {
+			return nil, nil, fmt.Errorf("%s: %w", policyFile, err)
+		}
+	}
+
+	if policyPath, _ := cmd.Flags().GetString("policy"); policyPath != "" {
+		if _, err := os.Stat(policyPath); err != nil {
+			return nil, nil, fmt.Errorf("reading policy: %w", err)
+		}
+		cfg, err := config.LoadFile(policyPath)
+		return cfg, pol, err
+	}
+	data, ok, err := gitBlob(gitDir, "HEAD", config.FileName)
+	if err != nil || !ok {
+		return &config.Config{}, pol, err
+	}
+	cfg, err := config.Parse(data, config.FileName)
+	return cfg, pol, err
+}
+
+// gitBlob reads the file at path in rev. ok is false if rev doesn't exist,
+// as in an empty repository, or doesn't have the file.
+func gitBlob(gitDir, rev, path string) (data []byte, ok bool, err error) {
+	if exec.Command("git", "--git-dir", gitDir, "cat-file", "-e", rev+":"+path).Run() != nil {
+		return nil, false, nil
+	}
+	data, err = exec.Command("git", "--git-dir", gitDir, "cat-file", "blob", rev+":"+path).Output()
+	if err != nil {
+		return nil, false, fmt.Errorf("reading %s:%s: %w", rev, path, err)
+	}
+	return data, true, nil
+}
+
+// pushBase is what a ref update is diffed against: the ref's old tip, or
+// for a new ref its merge-base with HEAD, or the empty tree when it has
+// none.
+func pushBase(gitDir string, u refUpdate) (string, error) {
+	if !isZeroOID(u.old) {
+		return u.old, nil
+	}
+	if out, err := exec.Command("git", "--git-dir", gitDir, "merge-base", "HEAD", u.new).Output(); err == nil {
+		return strings.TrimSpace(string(out)), nil
+	}
+	hash := exec.Command("git", "--git-dir", gitDir, "hash-object", "-t", "tree", "--stdin")
+	hash.Stdin = strings.NewReader("")
+	out, err := hash.Output()
+	if err != nil {
+		return "", fmt.Errorf("hashing the empty tree: %w", err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// matchesRef reports whether ref matches one of the globs.
+func matchesRef(globs []string, ref string) bool {
+	for _, g := range globs {
+		if gate.Match(g, ref) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,156 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/aezell/agrev/internal/diff"
+)
+
+// remoteBranch is a branch fetched from a remote and checked out in a
+// temporary worktree, so it can be reviewed without touching the current
+// checkout.
+type remoteBranch struct {
+	ref      string // e.g. "origin/feature-x"
+	base     string // what it's compared with, e.g. "origin/main"
+	worktree string
+	repoDir  string
+}
+
+// fetchRemoteBranch fetches ref, a remote-tracking branch such as
+// "origin/feature-x", into the repository at repoDir and checks it out in a
+// temporary worktree. It is compared with base, or when that is empty with
+// the remote's default branch, which is fetched too. Call remove when done.
+func fetchRemoteBranch(repoDir, ref, base string) (*remoteBranch, error) {
+	remote, branch := splitRemoteRef(repoDir, ref)
+	if remote == "" {
+		return nil, fmt.Errorf("%s doesn't start with the name of a remote; give one such as origin/%s", ref, ref)
+	}
+	refspecs := []string{"+refs/heads/" + branch + ":refs/remotes/" + ref}
+	if base == "" {
+		def, err := remoteDefaultBranch(repoDir, remote)
+		if err != nil {
+			return nil, err
+		}
+		base = remote + "/" + def
+		refspecs = append(refspecs, "+refs/heads/"+def+":refs/remotes/"+base)
+	}
+
+	fmt.Fprintf(os.Stderr, "Fetching %s...\n", ref)
+	args := append([]string{"-C", repoDir, "fetch", "--quiet", remote}, refspecs...)
+	if out, err := exec.Command("git", args...).CombinedOutput(); err != nil {
+		return nil, fmt.Errorf("fetching %s: %s", ref, strings.TrimSpace(string(out)))
+	}
+
+	dir, err := os.MkdirTemp("", "agrev-remote-")
+	if err != nil {
+		return nil, err
+	}
+	if out, err := exec.Command("git", "-C", repoDir, "worktree", "add", "--quiet", "--detach", dir, ref).CombinedOutput(); err != nil {
+		os.RemoveAll(dir)
+		return nil, fmt.Errorf("checking out %s: %s", ref, strings.TrimSpace(string(out)))
+	}
+	return &remoteBranch{ref: ref, base: base, worktree: dir, repoDir: repoDir}, nil
+}
+
+// rangeArg is the commit range the review covers: the branch's commits
+// since its merge-base with the base.
+func (b *remoteBranch) rangeArg() string {
+	return b.base + "..." + b.ref
+}
+
+// remove deletes the temporary worktree.
+func (b *remoteBranch) remove() {
+	if err := exec.Command("git", "-C", b.repoDir, "worktree", "remove", "--force", b.worktree).Run(); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: could not remove the worktree %s: %v\n", b.worktree, err)
+		os.RemoveAll(b.worktree)
+		exec.Command("git", "-C", b.repoDir, "worktree", "prune").Run()
+	}
+}
+
+// splitRemoteRef splits ref into a remote of the repository at repoDir and
+// a branch on it. Remote names may hold slashes, so the longest match
+// wins. The remote is empty if none matches.
+func splitRemoteRef(repoDir, ref string) (remote, branch string) {
+	for name := range gitRemotes(repoDir) {
+		if strings.HasPrefix(ref, name+"/") && len(name) > len(remote) && len(ref) > len(name)+1 {
+			remote, branch = name, ref[len(name)+1:]
+		}
+	}
+	return remote, branch
+}
+
+// remoteDefaultBranch returns the branch a remote's HEAD points to: from
+// the local refs/remotes/<remote>/HEAD that 'git clone' sets, or asking the
+// remote.
+func remoteDefaultBranch(repoDir, remote string) (string, error) {
+	out, err := exec.Command("git", "-C", repoDir, "symbolic-ref", "--quiet", "--short", "refs/remotes/"+remote+"/HEAD").Output()
+	if err == nil {
+		if branch, ok := strings.CutPrefix(strings.TrimSpace(string(out)), remote+"/"); ok {
+			return branch, nil
+		}
+	}
+	out, err = exec.Command("git", "-C", repoDir, "ls-remote", "--symref", remote, "HEAD").Output()
+	if err == nil {
+		for _, line := range strings.Split(string(out), "\n") {
+			// ref: refs/heads/main	HEAD
+			if rest, ok := strings.CutPrefix(line, "ref: refs/heads/"); ok {
+				if branch, _, ok := strings.Cut(rest, "\t"); ok {
+					return branch, nil
+				}
+			}
+		}
+	}
+	return "", fmt.Errorf("could not tell the default branch of %s; name the branch to compare with using --base", remote)
+}
+
+// reviewRemote reviews a branch on a remote, fetched and checked out in a
+// temporary worktree, against its merge-base with the remote's default
+// branch (or --base). Decisions are kept in the current repository.
+func reviewRemote(cmd *cobra.Command, args []string, ref string, stat bool) (*session, error) {
+	if len(args) > 0 {
+		return nil, fmt.Errorf("--remote names the branch to review and can't be combined with %s", args[0])
+	}
+	for _, name := range []string{"watch", "stage", "staged", "unstaged", "include-untracked"} {
+		if v, _ := cmd.Flags().GetBool(name); v {
+			return nil, fmt.Errorf("--remote reviews a fetched branch and can't be combined with --%s", name)
+		}
+	}
+	if from, to := diffDirs(cmd); from != "" || to != "" {
+		return nil, fmt.Errorf("--remote reviews a fetched branch and can't be combined with --from and --to")
+	}
+	repoDir, err := gitRepoRoot()
+	if err != nil {
+		return nil, fmt.Errorf("not in a git repository (or git not installed): %w", err)
+	}
+
+	// --base names what to compare with; the range below takes its place
+	base, _ := cmd.Flags().GetString("base")
+	cmd.Flags().Set("base", "")
+	b, err := fetchRemoteBranch(repoDir, ref, base)
+	if err != nil {
+		return nil, err
+	}
+	defer b.remove()
+
+	// A local trace belongs to the working tree, not to the branch
+	if !cmd.Flags().Changed("trace") {
+		cmd.Flags().Set("no-trace", "true")
+	}
+
+	contextLines, _ := cmd.Flags().GetInt("context")
+	var extra []string
+	if ws, _ := cmd.Flags().GetBool("ignore-whitespace"); ws {
+		extra = append(extra, diff.IgnoreWhitespace)
+	}
+	raw, rng, err := diff.GitDiffRange(repoDir, b.rangeArg(), contextLines, extra...)
+	if err != nil {
+		return nil, err
+	}
+	fmt.Fprintf(os.Stderr, "Reviewing %s against %s\n", ref, b.base)
+	src := sessionSource{args: []string{b.rangeArg()}, stat: stat, label: ref, rng: rng, repoDir: b.worktree, checkout: b.worktree}
+	return reviewDiff(cmd, raw, src)
+}
@@ -0,0 +1,121 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/aezell/agrev/internal/config"
+	"github.com/aezell/agrev/internal/diff"
+	"github.com/aezell/agrev/internal/report"
+	"github.com/aezell/agrev/internal/review"
+	savedsession "github.com/aezell/agrev/internal/session"
+	"github.com/aezell/agrev/internal/tui"
+)
+
+var reportCmd = &cobra.Command{
+	Use:   "report [commit-range | patch...]",
+	Short: "Write a review report combining trace, analysis, and decisions",
+	Long: `Write a single review report for the changes: the agent trace summary,
+the analysis findings, and the decisions, rejection notes and comments of
+the review saved in .agrev/session.json. The report is markdown or a
+standalone HTML page, suitable for attaching to a pull request or keeping
+as an audit record. The HTML page also holds every file's diff, syntax
+highlighted with the findings inline, and the trace's timeline, in
+collapsible sections, for readers who won't run agrev themselves.
+
+Saved decisions and comments are only reported on files whose changes are
+the ones they were made on; files that changed since show as pending.`,
+	Args: cobra.ArbitraryArgs,
+	RunE: runReport,
+}
+
+func init() {
+	addSourceFlags(reportCmd)
+	reportCmd.Flags().StringP("trace", "t", "", "path to agent trace file")
+	reportCmd.Flags().Bool("no-trace", false, "skip trace auto-detection")
+	reportCmd.Flags().StringP("format", "f", "markdown", "output format: markdown, html")
+	reportCmd.Flags().StringP("output", "o", "", "write the report to this file instead of stdout")
+	reportCmd.Flags().StringSlice("skip", nil, "analysis passes to skip")
+	addCoverageFlag(reportCmd)
+	reportCmd.Flags().String("session", "", "saved review to report on (default .agrev/session.json)")
+}
+
+func runReport(cmd *cobra.Command, args []string) error {
+	format, _ := cmd.Flags().GetString("format")
+	if format != "markdown" && format != "html" {
+		return fmt.Errorf("unknown format %q (want markdown or html)", format)
+	}
+
+	raw, rng, err := getDiff(cmd, args, 3)
+	if err != nil {
+		return err
+	}
+	if strings.TrimSpace(raw) == "" {
+		fmt.Println("No changes to report on.")
+		return nil
+	}
+	ds, err := diff.Parse(raw)
+	if err != nil {
+		return fmt.Errorf("parsing diff: %w", err)
+	}
+	ds.Range = rng
+
+	repoDir, _ := gitRepoRoot()
+	t, _ := loadTrace(cmd)
+	rs := review.New(ds, t, analyze(cmd, ds, repoDir, skipPasses(cmd, repoDir)))
+	rep := &report.Report{
+		Diff:      ds,
+		Trace:     t,
+		Results:   rs.Results,
+		Generated: time.Now(),
+	}
+
+	saved, err := loadSavedSession(cmd, repoDir)
+	if err != nil {
+		return err
+	}
+	if saved != nil {
+		if saved.DiffHash != savedsession.Hash(ds.Raw) {
+			fmt.Fprintln(os.Stderr, "Note: the changes differ from the saved review's; files changed since show as pending.")
+		}
+		if cfg, err := config.Load(repoDir); err == nil {
+			rs.SetChecklist(cfg.Checklist)
+		}
+		saved.Restore(rs)
+		rep.Review = &tui.ReviewResult{Decisions: rs.Decisions, Files: ds.Files, Comments: rs.Comments, Checklist: rs.Checklist}
+		rep.Review.Owners = loadOwners(repoDir)
+	}
+
+	out := rep.Markdown()
+	if format == "html" {
+		out = rep.HTML()
+	}
+	if path, _ := cmd.Flags().GetString("output"); path != "" {
+		if err := os.WriteFile(path, []byte(out), 0o644); err != nil {
+			return fmt.Errorf("writing report: %w", err)
+		}
+		fmt.Fprintf(os.Stderr, "Wrote %s\n", path)
+		return nil
+	}
+	fmt.Print(out)
+	return nil
+}
+
+// loadSavedSession reads the session named by --session, or else the
+// repository's saved one. It returns nil when there is none.
+func loadSavedSession(cmd *cobra.Command, repoDir string) (*savedsession.Session, error) {
+	if path, _ := cmd.Flags().GetString("session"); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("reading session: %w", err)
+		}
+		return savedsession.Parse(data)
+	}
+	if repoDir == "" {
+		return nil, nil
+	}
+	return savedsession.Load(repoDir)
+}
@@ -0,0 +1,93 @@
+package cli
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/aezell/agrev/internal/analysis"
+	"github.com/aezell/agrev/internal/diff"
+)
+
+// reviewRepos runs an interactive review of the changes in the
+// repositories at dirs together, each diffed as args name, with its files
+// listed under the repository's directory, e.g. "api/main.go". See
+// runSession.
+func reviewRepos(cmd *cobra.Command, args, dirs []string, stat bool) (*session, error) {
+	if from, to := diffDirs(cmd); from != "" || to != "" {
+		return nil, fmt.Errorf("--repo diffs each repository with git and can't be combined with --from and --to")
+	}
+	if isPatchArgs(args) {
+		return nil, fmt.Errorf("--repo diffs each repository with git and can't be used with patch files")
+	}
+	roots := make([]string, len(dirs))
+	for i, d := range dirs {
+		root, err := gitRepoRootIn(d)
+		if err != nil {
+			return nil, fmt.Errorf("%s is not a git repository (or git not installed): %w", d, err)
+		}
+		roots[i] = root
+	}
+	root, repos, err := diff.Repos(roots)
+	if err != nil {
+		return nil, err
+	}
+
+	contextLines, _ := cmd.Flags().GetInt("context")
+	ds, ar, err := loadRepos(cmd, repos, args, contextLines)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, len(repos))
+	for i, r := range repos {
+		names[i] = r.Name
+	}
+	src := sessionSource{args: args, stat: stat, repoDir: root, label: strings.Join(names, " + "), repos: repos}
+	return reviewDiffSet(cmd, ds, ar, src)
+}
+
+// loadRepos diffs each repository as args name and analyzes its changes
+// with its own settings and triaged findings, then combines them into one
+// diff, with files and findings listed under their repository's name.
+func loadRepos(cmd *cobra.Command, repos []diff.Repo, args []string, contextLines int) (*diff.DiffSet, *analysis.Results, error) {
+	sets := make([]*diff.DiffSet, len(repos))
+	results := make([]*analysis.Results, len(repos))
+	files := make([]map[string]*diff.File, len(repos))
+	for i, r := range repos {
+		raw, rng, err := getRepoDiff(cmd, r.Dir, args, contextLines)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: %w", r.Name, err)
+		}
+		ds, err := diff.Parse(raw)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: parsing diff: %w", r.Name, err)
+		}
+		ds.Range = rng
+		results[i] = analysis.Run(ds, r.Dir, skipPasses(cmd, r.Dir))
+		loadTriage(r.Dir).Apply(results[i])
+		files[i] = make(map[string]*diff.File, len(ds.Files))
+		for _, f := range ds.Files {
+			files[i][f.Name()] = f
+		}
+		sets[i] = ds
+	}
+
+	// Combining renames the files, so findings are renamed after them
+	ds := diff.Combine(repos, sets)
+	ar := &analysis.Results{}
+	rename := func(i int, fs []analysis.Finding) []analysis.Finding {
+		for j, fin := range fs {
+			if f := files[i][fin.File]; f != nil {
+				fs[j].File = f.Name()
+			} else {
+				fs[j].File = repos[i].Name + "/" + fin.File
+			}
+		}
+		return fs
+	}
+	for i := range repos {
+		ar.Findings = append(ar.Findings, rename(i, results[i].Findings)...)
+		ar.Suppressed = append(ar.Suppressed, rename(i, results[i].Suppressed)...)
+	}
+	return ds, ar, nil
+}
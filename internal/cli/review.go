@@ -1,68 +1,274 @@
 package cli
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/aezell/agrev/internal/analysis"
+	"github.com/aezell/agrev/internal/audit"
+	"github.com/aezell/agrev/internal/config"
 	"github.com/aezell/agrev/internal/diff"
+	"github.com/aezell/agrev/internal/explain"
+	"github.com/aezell/agrev/internal/history"
+	"github.com/aezell/agrev/internal/notify"
+	"github.com/aezell/agrev/internal/owners"
+	"github.com/aezell/agrev/internal/policy"
+	"github.com/aezell/agrev/internal/report"
+	"github.com/aezell/agrev/internal/review"
+	savedsession "github.com/aezell/agrev/internal/session"
 	"github.com/aezell/agrev/internal/trace"
+	"github.com/aezell/agrev/internal/triage"
 	"github.com/aezell/agrev/internal/tui"
 )
 
 var reviewCmd = &cobra.Command{
-	Use:   "review [commit-range]",
+	Use:   "review [commit-range | patch...]",
 	Short: "Open an interactive review session",
 	Long: `Open an interactive TUI for reviewing changes. By default, reviews
-uncommitted changes against HEAD. Optionally specify a commit range.
+uncommitted changes against HEAD. Optionally specify a commit range, or
+one or more patch files to review together.
 
 Examples:
   agrev review                     # working tree vs HEAD
   agrev review HEAD~1..HEAD        # last commit
   agrev review main...HEAD         # branch vs main
+  agrev review fix.patch           # a patch file
+  agrev review 00*.patch           # a 'git format-patch' series
+  agrev review series.mbox         # its commits, one at a time
+  agrev review --from a --to b     # two directories, without git
+  agrev review --repo api --repo web   # two repositories in one session
+  agrev review --remote origin/agent/fix-42   # a pushed branch, without checking it out
   git diff | agrev review -        # pipe any diff`,
-	Args: cobra.MaximumNArgs(1),
+	Args: cobra.ArbitraryArgs,
 	RunE: runReview,
 }
 
 func init() {
-	reviewCmd.Flags().StringP("trace", "t", "", "path to agent trace file")
-	reviewCmd.Flags().Bool("no-trace", false, "skip trace auto-detection")
-	reviewCmd.Flags().IntP("context", "C", 3, "lines of context around changes")
+	addSessionFlags(reviewCmd)
+	addSourceFlags(reviewCmd)
 	reviewCmd.Flags().Bool("stat", false, "print diff stats and exit (non-interactive)")
 	reviewCmd.Flags().StringP("output-patch", "o", "", "write approved changes as patch to file")
 	reviewCmd.Flags().Bool("commit-msg", false, "print a suggested commit message after review")
+	reviewCmd.Flags().String("report", "", "write a markdown review report (decisions and comments) to file")
+	reviewCmd.Flags().Bool("stage", false, "stage approved changes in the git index after review")
+	reviewCmd.Flags().StringArray("repo", nil, "review the changes in several repositories together; repeat for each")
+	reviewCmd.Flags().String("remote", "", "fetch a branch such as origin/feature-x and review it against the default branch, without checking it out")
+	addCoverageFlag(reviewCmd)
+}
+
+// addSessionFlags registers the flags shared by commands that run an
+// interactive review session.
+func addSessionFlags(c *cobra.Command) {
+	c.Flags().StringP("trace", "t", "", "path to agent trace file")
+	c.Flags().Bool("no-trace", false, "skip trace auto-detection")
+	c.Flags().IntP("context", "C", 3, "lines of context around changes")
+	c.Flags().Bool("watch", false, "reload the diff when the working tree changes")
+	c.Flags().Bool("queue", false, "review one file at a time, highest risk first")
+	c.Flags().String("theme", "", "TUI theme: dark, light, high-contrast, or a custom theme from .agrev.yml")
+	c.Flags().Bool("approve-whitespace", false, "start with files that only change whitespace approved")
+	c.Flags().Bool("semantic", false, "start with the declaration summary shown above each diff")
+	c.Flags().Bool("no-resume", false, "start over instead of resuming the saved review of the same changes")
 }
 
 func runReview(cmd *cobra.Command, args []string) error {
+	stat, _ := cmd.Flags().GetBool("stat")
+	if stage, _ := cmd.Flags().GetBool("stage"); stage {
+		if from, _ := diffDirs(cmd); from != "" {
+			return fmt.Errorf("--stage needs a git repository; write the approved changes with -o instead")
+		}
+	}
+	s, err := runSession(cmd, args, stat)
+	if err != nil || s == nil {
+		return err
+	}
+	result, repoDir := s.result, s.repoDir
+	repos := s.review.Diff.Repos
+
+	// Output patch if requested, one per repository when reviewing several
+	patchPath, _ := cmd.Flags().GetString("output-patch")
+	if patchPath != "" && len(repos) > 0 {
+		for _, r := range repos {
+			if err := writePatch(repoPatchPath(patchPath, r), result.ForRepo(r)); err != nil {
+				return err
+			}
+		}
+	} else if patchPath != "" {
+		if err := writePatch(patchPath, result); err != nil {
+			return err
+		}
+	}
+
+	// Write review report if requested
+	reportPath, _ := cmd.Flags().GetString("report")
+	if reportPath != "" {
+		if err := os.WriteFile(reportPath, []byte(result.GenerateReport()), 0644); err != nil {
+			return fmt.Errorf("writing report: %w", err)
+		}
+		fmt.Fprintf(os.Stderr, "Report written to %s\n", reportPath)
+	}
+
+	if len(repos) > 0 {
+		for _, r := range repos {
+			if err := stageApproved(cmd, args, r.Dir, result.ForRepo(r)); err != nil {
+				return fmt.Errorf("%s: %w", r.Name, err)
+			}
+		}
+	} else if err := stageApproved(cmd, args, repoDir, result); err != nil {
+		return err
+	}
+
+	// Print commit message if requested, headed by the repository's name
+	// when reviewing several
+	commitMsg, _ := cmd.Flags().GetBool("commit-msg")
+	if commitMsg && len(repos) > 0 {
+		first := true
+		for _, r := range repos {
+			msg := result.ForRepo(r).GenerateCommitMessage()
+			if msg == "" {
+				continue
+			}
+			if !first {
+				fmt.Println()
+			}
+			fmt.Printf("==> %s <==\n%s\n", r.Name, msg)
+			first = false
+		}
+	} else if commitMsg {
+		msg := result.GenerateCommitMessage()
+		if msg != "" {
+			fmt.Println(msg)
+		}
+	}
+
+	return nil
+}
+
+// writePatch writes the approved changes of result as a patch to path.
+func writePatch(path string, result *tui.ReviewResult) error {
+	patch := result.GeneratePatch()
+	if patch == "" {
+		fmt.Fprintf(os.Stderr, "No approved files — no patch written to %s.\n", path)
+		return nil
+	}
+	if err := os.WriteFile(path, []byte(patch), 0644); err != nil {
+		return fmt.Errorf("writing patch: %w", err)
+	}
+	fmt.Fprintf(os.Stderr, "Patch written to %s\n", path)
+	return nil
+}
+
+// repoPatchPath names the patch of one of several repositories reviewed
+// together after the one asked for, e.g. "out.api.patch" for "out.patch".
+func repoPatchPath(path string, r diff.Repo) string {
+	ext := filepath.Ext(path)
+	return strings.TrimSuffix(path, ext) + "." + strings.ReplaceAll(r.Name, "/", "-") + ext
+}
+
+// session is the outcome of an interactive review.
+type session struct {
+	result  *tui.ReviewResult
+	repoDir string
+	review  *review.Session // the diff, trace, and findings reviewed
+}
+
+// runSession loads the diff named by args, runs the interactive review, and
+// returns its outcome. It returns nil when there is nothing to review or the
+// session ended without a result. With stat, it prints diff stats instead.
+func runSession(cmd *cobra.Command, args []string, stat bool) (*session, error) {
 	contextLines, _ := cmd.Flags().GetInt("context")
 
-	raw, err := getDiff(args, contextLines)
+	watch, _ := cmd.Flags().GetBool("watch")
+	if watch && slices.Contains(args, "-") {
+		return nil, fmt.Errorf("--watch cannot be used with a diff from stdin")
+	}
+
+	if dirs, _ := cmd.Flags().GetStringArray("repo"); len(dirs) > 0 {
+		return reviewRepos(cmd, args, dirs, stat)
+	}
+	if ref, _ := cmd.Flags().GetString("remote"); ref != "" {
+		return reviewRemote(cmd, args, ref, stat)
+	}
+
+	raw, rng, err := getDiff(cmd, args, contextLines)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	src := sessionSource{args: args, stat: stat, rng: rng}
+	if from, to := diffDirs(cmd); to != "" {
+		// The changed files are read from the directory, not a repository
+		src.repoDir, src.label = to, from+" → "+to
 	}
+	return reviewDiff(cmd, raw, src)
+}
+
+// sessionSource describes where a session's diff came from.
+type sessionSource struct {
+	// args are the review arguments (a commit range, patch files, or "-"),
+	// used to split ranges into commits and to reload the diff in watch
+	// mode.
+	args []string
+	stat bool
+
+	// repoDir, when set, is the repository file contents are read from,
+	// overriding the current one. "-" disables reading file contents.
+	repoDir string
 
-	if strings.TrimSpace(raw) == "" {
+	// label identifies the change in the status bar, e.g. "PR #12".
+	label string
+
+	// checkout, when set, holds the changed files the analysis reads
+	// instead of the current repository's, such as the temporary worktree
+	// of a remote branch. State is still kept in the current repository.
+	checkout string
+
+	// rng is the commits the diff compares, when git computed it.
+	rng diff.Range
+
+	// repos are the repositories reviewed together, each diffed as args
+	// name; empty when reviewing one. See reviewRepos.
+	repos []diff.Repo
+}
+
+// reviewDiff runs an interactive review of raw. See runSession.
+func reviewDiff(cmd *cobra.Command, raw string, src sessionSource) (*session, error) {
+	watch, _ := cmd.Flags().GetBool("watch")
+
+	// In watch mode an empty diff is fine: changes may be on their way
+	if strings.TrimSpace(raw) == "" && !watch {
 		fmt.Println("No changes to review.")
-		return nil
+		return nil, nil
 	}
 
 	ds, err := diff.Parse(raw)
 	if err != nil {
-		return fmt.Errorf("parsing diff: %w", err)
+		return nil, fmt.Errorf("parsing diff: %w", err)
 	}
+	ds.Range = src.rng
+	return reviewDiffSet(cmd, ds, nil, src)
+}
+
+// reviewDiffSet runs an interactive review of ds, with the findings of ar,
+// or when it is nil of running the analysis. See runSession.
+func reviewDiffSet(cmd *cobra.Command, ds *diff.DiffSet, ar *analysis.Results, src sessionSource) (*session, error) {
+	args, stat := src.args, src.stat
+	contextLines, _ := cmd.Flags().GetInt("context")
+	watch, _ := cmd.Flags().GetBool("watch")
+	raw := ds.Raw
 
-	if len(ds.Files) == 0 {
+	if len(ds.Files) == 0 && !watch {
 		fmt.Println("No changes to review.")
-		return nil
+		return nil, nil
 	}
 
-	stat, _ := cmd.Flags().GetBool("stat")
 	if stat {
-		return printStat(ds)
+		return nil, printStat(ds)
 	}
 
 	// Load trace
@@ -72,48 +278,307 @@ func runReview(cmd *cobra.Command, args []string) error {
 			traceSource, len(t.Steps), len(t.FilesChanged))
 	}
 
-	// Run analysis
+	// Run analysis. Several repositories reviewed together keep the
+	// session's state in the first, and were analyzed one by one.
 	repoDir, _ := gitRepoRoot()
-	ar := analysis.Run(ds, repoDir, nil)
+	if len(src.repos) > 0 {
+		repoDir = src.repos[0].Dir
+	}
+	skip := skipPasses(cmd, repoDir)
+	var triaged *triage.Store
+	if ar == nil {
+		analyzeDir := repoDir
+		if src.checkout != "" {
+			analyzeDir = src.checkout
+		}
+		triaged = loadTriage(repoDir)
+		ar = runAnalysis(cmd, ds, analyzeDir, skip)
+		triaged.Apply(ar)
+	}
 	if len(ar.Findings) > 0 {
 		fmt.Fprintf(os.Stderr, "Analysis: %s\n", ar.Summary())
 	}
 
-	result, err := tui.Run(ds, t, ar)
+	// Select theme: flag overrides config
+	cfg, err := config.Load(repoDir)
 	if err != nil {
-		return err
+		return nil, err
 	}
-
-	if result == nil {
-		return nil
+	themeName, _ := cmd.Flags().GetString("theme")
+	if themeName == "" {
+		themeName = cfg.Theme
+	}
+	if err := tui.SetTheme(themeName, cfg.Themes); err != nil {
+		return nil, err
 	}
 
-	// Output patch if requested
-	patchPath, _ := cmd.Flags().GetString("output-patch")
-	if patchPath != "" {
-		patch := result.GeneratePatch()
-		if patch != "" {
-			if err := os.WriteFile(patchPath, []byte(patch), 0644); err != nil {
-				return fmt.Errorf("writing patch: %w", err)
+	queue, _ := cmd.Flags().GetBool("queue")
+	approveWS, _ := cmd.Flags().GetBool("approve-whitespace")
+	semantic, _ := cmd.Flags().GetBool("semantic")
+	opts := tui.Options{RepoDir: repoDir, Queue: queue, Label: src.label, ApproveWhitespace: approveWS, Semantic: semantic, Author: gitUserName(repoDir)}
+	switch src.repoDir {
+	case "":
+	case "-":
+		opts.RepoDir = ""
+	default:
+		opts.RepoDir = src.repoDir
+	}
+	if len(src.repos) > 0 {
+		// Commits are split within one repository only
+	} else if commits, err := splitCommits(cmd, args, raw, contextLines); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: could not split the changes into commits: %v\n", err)
+	} else if len(commits) > 1 {
+		opts.Commits = commits
+	}
+	if client, err := explain.New(cfg.Explain); err == nil {
+		opts.Explain = client.Explain
+	} else if !errors.Is(err, explain.ErrDisabled) {
+		fmt.Fprintf(os.Stderr, "Warning: explanations unavailable: %v\n", err)
+	}
+	opts.Owners = loadOwners(opts.RepoDir)
+	opts.Triage = triaged
+	if repoDir != "" && !cfg.NoAudit {
+		opts.Audit = audit.New(repoDir, "tui")
+		opts.Audit.Reviewer = gitIdentity(repoDir)
+		opts.Audit.Range = reviewRange(src)
+	}
+	if repoDir != "" {
+		if opts.Policy, err = policy.Load(repoDir); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		}
+	}
+	if watch {
+		opts.Reload = func(prev string) (*diff.DiffSet, *analysis.Results, error) {
+			if len(src.repos) > 0 {
+				ds, ar, err := loadRepos(cmd, src.repos, args, contextLines)
+				if err != nil || ds.Raw == prev {
+					return nil, nil, err
+				}
+				return ds, ar, nil
+			}
+			raw, rng, err := getDiff(cmd, args, contextLines)
+			if err != nil || raw == prev {
+				return nil, nil, err
 			}
-			fmt.Fprintf(os.Stderr, "Patch written to %s\n", patchPath)
+			ds, err := diff.Parse(raw)
+			if err != nil {
+				return nil, nil, fmt.Errorf("parsing diff: %w", err)
+			}
+			ds.Range = rng
+			ar := runAnalysis(cmd, ds, repoDir, skip)
+			triaged.Apply(ar)
+			return ds, ar, nil
+		}
+	}
+
+	// Pick up where a saved review left off, on the files that haven't
+	// changed since
+	var saved *savedsession.Session
+	if repoDir != "" {
+		if saved, err = savedsession.Load(repoDir); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: could not load the saved review: %v\n", err)
+		}
+	}
+	rs := review.New(ds, t, ar)
+	rs.SetChecklist(cfg.Checklist)
+	if noResume, _ := cmd.Flags().GetBool("no-resume"); saved != nil && !noResume {
+		saved.Restore(rs)
+		decisions := len(rs.Decisions.Files) + len(rs.Decisions.Hunks)
+		if decisions+len(rs.Comments) > 0 {
+			fmt.Fprintf(os.Stderr, "Resumed the saved review: %d decision(s), %d comment(s)\n", decisions, len(rs.Comments))
 		} else {
-			fmt.Fprintln(os.Stderr, "No approved files — no patch written.")
+			saved = nil // nothing carried over, so this is a new review
 		}
+	} else {
+		saved = nil
 	}
 
-	// Print commit message if requested
-	commitMsg, _ := cmd.Flags().GetBool("commit-msg")
-	if commitMsg {
-		msg := result.GenerateCommitMessage()
-		if msg != "" {
-			fmt.Println(msg)
+	start := time.Now()
+	result, err := tui.Run(rs, opts)
+	if err != nil || result == nil {
+		return nil, err
+	}
+	if repoDir != "" {
+		if saved == nil {
+			saved = savedsession.New(rs.Diff)
+		}
+		saved.Record(rs)
+		if err := saved.Save(repoDir); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: could not save the review: %v\n", err)
+		}
+		if result.Triaged {
+			if err := triaged.Save(repoDir); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: could not save the triaged findings: %v\n", err)
+			}
+		}
+	}
+	if repoDir != "" && !cfg.NoHistory {
+		rec := historyRecord(cmd.Name(), src, rs, result, time.Since(start))
+		if err := history.Append(repoDir, rec); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: could not record review history: %v\n", err)
+		}
+	}
+	nFiles, added, deleted := rs.Diff.Stats()
+	notifyWebhooks(cfg, notify.Summary{
+		Command: "review", Subject: reviewRange(src),
+		Files: nFiles, Added: added, Deleted: deleted, Results: rs.Results,
+		Report:   &report.Report{Diff: rs.Diff, Trace: rs.Trace, Results: rs.Results, Review: result, Generated: time.Now()},
+		Reviewed: true,
+		Approved: len(result.ApprovedFiles()), Rejected: len(result.RejectedFiles()), Pending: len(result.PendingFiles()),
+	})
+	return &session{result: result, repoDir: repoDir, review: rs}, nil
+}
+
+// historyRecord summarizes a finished review for the history store.
+func historyRecord(command string, src sessionSource, rs *review.Session, result *tui.ReviewResult, elapsed time.Duration) history.Record {
+	rec := history.Record{
+		Time:     time.Now(),
+		Command:  command,
+		Range:    reviewRange(src),
+		Duration: elapsed.Round(time.Second).Seconds(),
+		Files:    len(result.Files),
+		Approved: len(result.ApprovedFiles()),
+		Rejected: len(result.RejectedFiles()),
+		Pending:  len(result.PendingFiles()),
+		Comments: len(result.Comments),
+	}
+	for _, f := range result.Files {
+		rec.Added += f.AddedLines
+		rec.Deleted += f.DeletedLines
+	}
+	if t := rs.Trace; t != nil {
+		rec.Agent, rec.Session = t.Source, t.SessionID
+	}
+	if ar := rs.Results; ar != nil && len(ar.Findings) > 0 {
+		rec.MaxRisk = ar.MaxRisk().String()
+		rec.Findings = make(map[string]int)
+		for _, f := range ar.Findings {
+			rec.Findings[f.Pass]++
+		}
+	}
+	return rec
+}
+
+// reviewRange names what a review is of: the PR label, the commit range or
+// patch files given, stdin, or the working tree.
+func reviewRange(src sessionSource) string {
+	switch {
+	case src.label != "":
+		return src.label
+	case len(src.args) == 1 && src.args[0] == "-":
+		return "stdin"
+	case len(src.args) > 0:
+		return strings.Join(src.args, " ")
+	}
+	return "working tree"
+}
+
+// stageApproved applies the approved changes to the git index when --stage
+// is set. Interactive reviews of the working tree offer to do so instead.
+func stageApproved(cmd *cobra.Command, args []string, repoDir string, result *tui.ReviewResult) error {
+	var text, binary int
+	for _, f := range result.ApprovedFiles() {
+		if f.IsBinary {
+			binary++
+		} else {
+			text++
 		}
 	}
+	if from, _ := diffDirs(cmd); text == 0 || repoDir == "" || from != "" {
+		return nil
+	}
+
+	stage, _ := cmd.Flags().GetBool("stage")
+	if !stage && len(args) == 0 && isTerminal(os.Stdin) {
+		stage = confirm(fmt.Sprintf("Stage %d approved file(s) in the git index? [y/N] ", text))
+	}
+	if !stage {
+		return nil
+	}
 
+	if err := applyPatch(repoDir, result.GeneratePatch(), false); err != nil {
+		return fmt.Errorf("staging approved changes: %w", err)
+	}
+	fmt.Fprintf(os.Stderr, "Staged %d approved file(s)\n", text)
+	if binary > 0 {
+		fmt.Fprintf(os.Stderr, "Warning: %d approved binary file(s) not staged; use git add\n", binary)
+	}
 	return nil
 }
 
+// isTerminal reports whether f is an interactive terminal.
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+// confirm asks a yes/no question on stderr and reads the answer from stdin.
+func confirm(question string) bool {
+	fmt.Fprint(os.Stderr, question)
+	var answer string
+	fmt.Scanln(&answer)
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
+
+// skipPasses returns the analysis passes to skip: those named by the --skip
+// flag, if the command has one, plus those skipped in .agrev.yml.
+func skipPasses(cmd *cobra.Command, repoDir string) []string {
+	var skip []string
+	if cmd.Flags().Lookup("skip") != nil {
+		skip, _ = cmd.Flags().GetStringSlice("skip")
+	}
+	cfg, err := config.Load(repoDir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		return skip
+	}
+	return append(skip, cfg.Analysis.Skip...)
+}
+
+// loadOwners reads the repository's CODEOWNERS file, if it has one. A file
+// that can't be read is warned about and left out.
+func loadOwners(repoDir string) *owners.File {
+	if repoDir == "" {
+		return nil
+	}
+	co, err := owners.Load(repoDir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
+	return co
+}
+
+// loadTriage reads what reviewers concluded about findings in earlier
+// runs. A file that can't be read is warned about and left out.
+func loadTriage(repoDir string) *triage.Store {
+	if repoDir == "" {
+		return nil
+	}
+	store, err := triage.Load(repoDir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
+	return store
+}
+
+// notifyWebhooks posts s to the webhooks configured in cfg, and emails it
+// to the addresses configured there. Failures are
+// warned about rather than failing the command.
+func notifyWebhooks(cfg *config.Config, s notify.Summary) {
+	for _, err := range notify.Send(cfg.Notify, s) {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
+}
+
+// analyze runs the analysis passes not skipped, then suppresses or
+// down-ranks the findings reviewers triaged in earlier runs.
+func analyze(cmd *cobra.Command, ds *diff.DiffSet, repoDir string, skip []string) *analysis.Results {
+	results := runAnalysis(cmd, ds, repoDir, skip)
+	loadTriage(repoDir).Apply(results)
+	return results
+}
+
 func loadTrace(cmd *cobra.Command) (*trace.Trace, string) {
 	noTrace, _ := cmd.Flags().GetBool("no-trace")
 	if noTrace {
@@ -149,29 +614,216 @@ func loadTrace(cmd *cobra.Command) (*trace.Trace, string) {
 	return nil, ""
 }
 
-func getDiff(args []string, contextLines int) (string, error) {
-	// Read from stdin if "-" is passed
-	if len(args) == 1 && args[0] == "-" {
-		data, err := os.ReadFile("/dev/stdin")
-		if err != nil {
-			return "", fmt.Errorf("reading stdin: %w", err)
+// addSourceFlags registers the flags choosing which changes a command looks
+// at when it isn't given patches or a range. getDiff reads them.
+func addSourceFlags(c *cobra.Command) {
+	c.Flags().Bool("staged", false, "only changes staged in the index")
+	c.Flags().Bool("unstaged", false, "only changes not yet staged")
+	c.Flags().Bool("include-untracked", false, "include untracked files as new files")
+	c.Flags().BoolP("ignore-whitespace", "w", false, "ignore changes in whitespace when diffing")
+	c.Flags().String("base", "", "compare a revision (HEAD by default) with its merge-base with this branch, as base...HEAD")
+	c.Flags().String("from", "", "directory holding the original files, compared with --to without git")
+	c.Flags().String("to", "", "directory holding the changed files, compared with --from")
+}
+
+// getDiff returns the diff named by args: patch files or "-" for stdin, a
+// commit range, or by default uncommitted changes against HEAD, narrowed by
+// the command's source flags if it has them. --from and --to compare two
+// directories instead. It also returns the commits the
+// diff compares, when git computed it.
+func getDiff(cmd *cobra.Command, args []string, contextLines int) (string, diff.Range, error) {
+	return getRepoDiff(cmd, "", args, contextLines)
+}
+
+// getRepoDiff is getDiff in the repository at dir, or the current one when
+// dir is empty.
+func getRepoDiff(cmd *cobra.Command, dir string, args []string, contextLines int) (string, diff.Range, error) {
+	flag := func(name string) bool {
+		if cmd == nil || cmd.Flags().Lookup(name) == nil {
+			return false
 		}
-		return string(data), nil
+		v, _ := cmd.Flags().GetBool(name)
+		return v
+	}
+	staged, unstaged, untracked := flag("staged"), flag("unstaged"), flag("include-untracked")
+	var extra []string
+	if flag("ignore-whitespace") {
+		extra = append(extra, diff.IgnoreWhitespace)
+	}
+	if staged && unstaged {
+		return "", diff.Range{}, fmt.Errorf("--staged and --unstaged together are the default; use neither")
+	}
+	if from, to := diffDirs(cmd); from != "" || to != "" {
+		base, _ := cmd.Flags().GetString("base")
+		switch {
+		case from == "" || to == "":
+			return "", diff.Range{}, fmt.Errorf("--from and --to compare two directories and must be used together")
+		case len(args) > 0:
+			return "", diff.Range{}, fmt.Errorf("--from and --to compare two directories and can't be combined with %s", args[0])
+		case staged || unstaged || untracked || base != "":
+			return "", diff.Range{}, fmt.Errorf("--from and --to compare two directories and can't be combined with --staged, --unstaged, --include-untracked, or --base")
+		}
+		raw, err := diff.DiffDirs(from, to, contextLines, extra != nil)
+		return raw, diff.Range{}, err
+	}
+	args, err := rangeArgs(cmd, args)
+	if err != nil {
+		return "", diff.Range{}, err
+	}
+	if (staged || unstaged || untracked) && len(args) > 0 {
+		return "", diff.Range{}, fmt.Errorf("--staged, --unstaged, and --include-untracked select uncommitted changes and can't be combined with %s", args[0])
+	}
+
+	// Patch files (and "-" for stdin) are concatenated
+	if isPatchArgs(args) {
+		if extra != nil {
+			return "", diff.Range{}, fmt.Errorf("--ignore-whitespace needs git to compute the diff and can't be used with patch files")
+		}
+		raw, err := readPatches(cmd, args)
+		return raw, diff.Range{}, err
+	}
+	if len(args) > 1 {
+		return "", diff.Range{}, fmt.Errorf("expected a commit range or patch files, but %s is not a file", firstNonFile(args))
 	}
 
 	// Find repo root
-	repoDir, err := gitRepoRoot()
+	repoDir, err := gitRepoRootIn(dir)
 	if err != nil {
-		return "", fmt.Errorf("not in a git repository (or git not installed): %w", err)
+		if dir != "" {
+			return "", diff.Range{}, fmt.Errorf("%s is not a git repository (or git not installed): %w", dir, err)
+		}
+		return "", diff.Range{}, fmt.Errorf("not in a git repository (or git not installed): %w", err)
 	}
 
 	if len(args) == 1 {
 		// Explicit commit range
-		return diff.GitDiffRange(repoDir, args[0], contextLines)
+		return diff.GitDiffRange(repoDir, args[0], contextLines, extra...)
+	}
+
+	// Default: working tree vs HEAD. The unstaged diff starts from the
+	// index rather than a commit.
+	var raw string
+	var rng diff.Range
+	if !unstaged {
+		rng, _ = diff.ResolveRange(repoDir, "HEAD") // fails before the first commit
+	}
+	switch {
+	case staged:
+		raw, err = diff.GitDiffStaged(repoDir, contextLines, extra...)
+	case unstaged:
+		raw, err = diff.GitDiffUnstaged(repoDir, contextLines, extra...)
+	default:
+		raw, err = diff.GitDiffHead(repoDir, contextLines, extra...)
+	}
+	if err != nil || !untracked {
+		return raw, rng, err
+	}
+	new, err := diff.GitDiffUntracked(repoDir, contextLines, extra...)
+	if err != nil {
+		return "", diff.Range{}, err
+	}
+	return raw + new, rng, nil
+}
+
+// diffDirs returns the --from and --to directories, empty unless the command
+// compares directories.
+func diffDirs(cmd *cobra.Command) (from, to string) {
+	if cmd == nil || cmd.Flags().Lookup("from") == nil {
+		return "", ""
+	}
+	from, _ = cmd.Flags().GetString("from")
+	to, _ = cmd.Flags().GetString("to")
+	return from, to
+}
+
+// rangeArgs applies the --base flag to args: with it, the single revision
+// in args (HEAD by default) is compared with its merge-base with the base,
+// as "base...rev".
+func rangeArgs(cmd *cobra.Command, args []string) ([]string, error) {
+	if cmd == nil || cmd.Flags().Lookup("base") == nil {
+		return args, nil
+	}
+	base, _ := cmd.Flags().GetString("base")
+	switch {
+	case base == "":
+		return args, nil
+	case len(args) > 1 || isPatchArgs(args):
+		return nil, fmt.Errorf("--base compares a revision with its merge-base and can't be used with patch files")
+	case len(args) == 1 && strings.Contains(args[0], ".."):
+		return nil, fmt.Errorf("--base takes the place of a range; give a single revision instead of %s", args[0])
+	}
+	for _, name := range []string{"staged", "unstaged", "include-untracked"} {
+		if v, _ := cmd.Flags().GetBool(name); v {
+			return nil, fmt.Errorf("--base compares commits and can't be combined with --%s", name)
+		}
+	}
+	head := "HEAD"
+	if len(args) == 1 {
+		head = args[0]
+	}
+	return []string{base + "..." + head}, nil
+}
+
+// splitCommits returns the commits in the changes named by args, oldest
+// first: those of a commit range, or those of a patch series as written by
+// 'git log -p' or 'git format-patch', parsed from raw. Other changes have no
+// commits.
+func splitCommits(cmd *cobra.Command, args []string, raw string, contextLines int) ([]diff.Commit, error) {
+	if isPatchArgs(args) {
+		return diff.ParseCommits(raw)
+	}
+	revs, err := rangeArgs(cmd, args)
+	if err != nil || len(revs) != 1 || !strings.Contains(revs[0], "..") {
+		return nil, err
+	}
+	repoDir, err := gitRepoRoot()
+	if err != nil {
+		return nil, err
+	}
+	var extra []string
+	if ws, _ := cmd.Flags().GetBool("ignore-whitespace"); ws {
+		extra = append(extra, diff.IgnoreWhitespace)
+	}
+	return diff.GitCommits(repoDir, revs[0], contextLines, extra...)
+}
+
+// isPatchArgs reports whether args name patch files or "-" for stdin rather
+// than a commit range.
+func isPatchArgs(args []string) bool {
+	return len(args) > 0 && firstNonFile(args) == ""
+}
+
+// firstNonFile returns the first argument that is neither "-" nor a regular
+// file, or "" if there is none.
+func firstNonFile(args []string) string {
+	for _, arg := range args {
+		if arg == "-" {
+			continue
+		}
+		if info, err := os.Stat(arg); err != nil || !info.Mode().IsRegular() {
+			return arg
+		}
 	}
+	return ""
+}
 
-	// Default: HEAD vs parent
-	return diff.GitDiffHead(repoDir, contextLines)
+// readPatches reads and concatenates patch files, "-" meaning the command's
+// input. Mail headers and commit messages between patches, as in 'git
+// format-patch' output or a mailing list mbox, are skipped when the diff is
+// parsed.
+func readPatches(cmd *cobra.Command, args []string) (string, error) {
+	var b strings.Builder
+	for _, arg := range args {
+		data, err := readPatch(cmd, arg)
+		if err != nil {
+			return "", err
+		}
+		b.Write(data)
+		if len(data) > 0 && data[len(data)-1] != '\n' {
+			b.WriteByte('\n')
+		}
+	}
+	return b.String(), nil
 }
 
 func printStat(ds *diff.DiffSet) error {
@@ -188,11 +840,61 @@ func printStat(ds *diff.DiffSet) error {
 		}
 		fmt.Printf("  %s %-50s +%-4d -%d\n", status, f.Name(), f.AddedLines, f.DeletedLines)
 	}
+	printGroupStats("By language", ds.StatsByLanguage(), added+deleted)
+	printGroupStats("By directory", ds.StatsByDir(), added+deleted)
 	return nil
 }
 
+// printGroupStats prints a stats breakdown, with each group's share of the
+// lines changed.
+func printGroupStats(title string, groups []diff.GroupStats, total int) {
+	fmt.Printf("\n%s:\n", title)
+	for _, g := range groups {
+		fmt.Printf("  %-52s +%-4d -%-4d %3d%%  %d file(s)\n", g.Name, g.Added, g.Deleted, g.Percent(total), g.Files)
+	}
+}
+
+// gitUserName returns the configured git user.name, or "" if there is none.
+func gitUserName(repoDir string) string {
+	return gitConfig(repoDir, "user.name")
+}
+
+// gitIdentity names the reviewer as git names a commit's author, e.g.
+// "Ada Lovelace <ada@example.com>", for the audit log. It falls back to
+// $USER when git has no name configured.
+func gitIdentity(repoDir string) string {
+	name := gitUserName(repoDir)
+	if name == "" {
+		name = os.Getenv("USER")
+	}
+	if email := gitConfig(repoDir, "user.email"); email != "" {
+		return strings.TrimSpace(name + " <" + email + ">")
+	}
+	return name
+}
+
+// gitConfig returns a git config value, or "" if it isn't set.
+func gitConfig(repoDir, key string) string {
+	args := []string{"config", key}
+	if repoDir != "" {
+		args = append([]string{"-C", repoDir}, args...)
+	}
+	out, err := exec.Command("git", args...).Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
+
 func gitRepoRoot() (string, error) {
+	return gitRepoRootIn("")
+}
+
+// gitRepoRootIn returns the root of the repository holding dir, or the
+// current directory when dir is empty.
+func gitRepoRootIn(dir string) (string, error) {
 	cmd := exec.Command("git", "rev-parse", "--show-toplevel")
+	cmd.Dir = dir
 	out, err := cmd.Output()
 	if err != nil {
 		return "", err
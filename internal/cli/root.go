@@ -1,7 +1,11 @@
 package cli
 
 import (
+	"fmt"
+	"os"
+
 	"github.com/spf13/cobra"
+	"github.com/aezell/agrev/internal/api"
 )
 
 var rootCmd = &cobra.Command{
@@ -9,7 +13,34 @@ var rootCmd = &cobra.Command{
 	Short: "Agent Review — code review tool for AI-generated changes",
 	Long: `agrev is an opinionated code review tool for changes generated by AI coding agents.
 It combines diff review with agent trace analysis, static analysis, and interactive
-review actions to help you understand, assess, and selectively approve changes.`,
+review actions to help you understand, assess, and selectively approve changes.
+
+With --editor-server, agrev instead speaks JSON-RPC on stdin and stdout for
+editor plugins to review the working tree's changes inside the editor; see
+the README for the protocol.`,
+	Args: cobra.NoArgs,
+	RunE: runRoot,
+
+	PersistentPreRunE: loadCoverage,
+}
+
+func runRoot(cmd *cobra.Command, args []string) error {
+	if serve, _ := cmd.Flags().GetBool("editor-server"); !serve {
+		return cmd.Help()
+	}
+	repoDir, err := gitRepoRoot()
+	if err != nil {
+		return fmt.Errorf("not in a git repository (or git not installed): %w", err)
+	}
+	return api.ServeEditor(os.Stdin, os.Stdout, api.EditorOptions{
+		RepoDir: repoDir,
+		Diff: func() (string, error) {
+			raw, _, err := getDiff(cmd, nil, 3)
+			return raw, err
+		},
+		Skip:     skipPasses(cmd, repoDir),
+		Reviewer: gitIdentity(repoDir),
+	})
 }
 
 func Execute() error {
@@ -17,9 +48,23 @@ func Execute() error {
 }
 
 func init() {
+	rootCmd.Flags().Bool("editor-server", false, "serve the editor protocol on stdin and stdout")
+	rootCmd.AddCommand(initCmd)
 	rootCmd.AddCommand(reviewCmd)
+	rootCmd.AddCommand(applyCmd)
+	rootCmd.AddCommand(commitCmd)
+	rootCmd.AddCommand(prCmd)
+	rootCmd.AddCommand(commentCmd)
+	rootCmd.AddCommand(explainCmd)
+	rootCmd.AddCommand(lspCmd)
 	rootCmd.AddCommand(summaryCmd)
+	rootCmd.AddCommand(traceCmd)
 	rootCmd.AddCommand(checkCmd)
+	rootCmd.AddCommand(reportCmd)
+	rootCmd.AddCommand(compareCmd)
+	rootCmd.AddCommand(gateCmd)
+	rootCmd.AddCommand(preReceiveCmd)
+	rootCmd.AddCommand(statsCmd)
 	rootCmd.AddCommand(serveCmd)
 	rootCmd.AddCommand(versionCmd)
 }
@@ -1,7 +1,22 @@
 package cli
 
 import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/aezell/agrev/internal/analysis"
+	"github.com/aezell/agrev/internal/diff"
+	"github.com/aezell/agrev/internal/model"
+	"github.com/aezell/agrev/internal/owners"
+	"github.com/aezell/agrev/internal/review"
+	"github.com/aezell/agrev/internal/tui"
 )
 
 func TestRootCommandHasSubcommands(t *testing.T) {
@@ -11,7 +26,7 @@ func TestRootCommandHasSubcommands(t *testing.T) {
 		names[c.Name()] = true
 	}
 
-	for _, want := range []string{"review", "summary", "check", "serve", "version"} {
+	for _, want := range []string{"init", "review", "apply", "commit", "pr", "comment", "explain", "summary", "trace", "check", "compare", "gate", "stats", "serve", "version"} {
 		if !names[want] {
 			t.Errorf("root command missing subcommand %q", want)
 		}
@@ -42,6 +57,18 @@ func TestReviewCommandHasOutputPatch(t *testing.T) {
 	}
 }
 
+func TestReviewCommandHasTheme(t *testing.T) {
+	if reviewCmd.Flags().Lookup("theme") == nil {
+		t.Fatal("review command missing --theme flag")
+	}
+}
+
+func TestReviewCommandHasQueue(t *testing.T) {
+	if reviewCmd.Flags().Lookup("queue") == nil {
+		t.Fatal("review command missing --queue flag")
+	}
+}
+
 func TestHTMLEscape(t *testing.T) {
 	tests := []struct {
 		input, want string
@@ -60,3 +87,618 @@ func TestHTMLEscape(t *testing.T) {
 		}
 	}
 }
+
+func TestAddTrailers(t *testing.T) {
+	trailers := [][2]string{{"Reviewed-with", "agrev"}, {"Agent-session", "abc-123"}}
+	tests := []struct {
+		msg, want string
+	}{
+		{"Update main.go", "Update main.go\n\nReviewed-with: agrev\nAgent-session: abc-123"},
+		{"Fix bug\n\nLonger body.\n", "Fix bug\n\nLonger body.\n\nReviewed-with: agrev\nAgent-session: abc-123"},
+		{"Fix bug\n\nSigned-off-by: A <a@example.com>", "Fix bug\n\nSigned-off-by: A <a@example.com>\nReviewed-with: agrev\nAgent-session: abc-123"},
+		{"Fix bug\n\nReviewed-with: agrev", "Fix bug\n\nReviewed-with: agrev\nAgent-session: abc-123"},
+	}
+	for _, tt := range tests {
+		if got := addTrailers(tt.msg, trailers); got != tt.want {
+			t.Errorf("addTrailers(%q) = %q, want %q", tt.msg, got, tt.want)
+		}
+	}
+}
+
+func TestRDJSONReport(t *testing.T) {
+	results := &analysis.Results{Findings: []analysis.Finding{
+		{Pass: "security", File: "auth.go", Line: 12, Message: "token compare", Severity: model.SeverityError, Risk: model.RiskHigh},
+		{Pass: "deps", Rule: analysis.RuleNewDependency, File: "go.mod", Message: "new dependency", Severity: model.SeverityWarning, Risk: model.RiskMedium},
+	}}
+
+	out := rdjsonReport(results)
+	if out.Source.Name != "agrev" || len(out.Diagnostics) != 2 {
+		t.Fatalf("unexpected report: %+v", out)
+	}
+
+	d := out.Diagnostics[0]
+	if d.Severity != "ERROR" || d.Code.Value != "security" || d.Location.Path != "auth.go" {
+		t.Errorf("unexpected diagnostic: %+v", d)
+	}
+	if d.Location.Range == nil || d.Location.Range.Start.Line != 12 {
+		t.Errorf("expected range at line 12, got %+v", d.Location.Range)
+	}
+	if d.Message != "[high risk] token compare" {
+		t.Errorf("unexpected message %q", d.Message)
+	}
+	if d := out.Diagnostics[1]; d.Severity != "WARNING" || d.Location.Range != nil ||
+		d.Code.Value != "AGV-DEP-001" || d.Code.URL != analysis.DocsURL+"#agv-dep-001" {
+		t.Errorf("expected file-level warning with its rule, got %+v", d)
+	}
+}
+
+func TestSARIFReport(t *testing.T) {
+	results := &analysis.Results{
+		Findings: []analysis.Finding{
+			{Pass: "security", Rule: analysis.RuleSQL, File: "db.go", Line: 4, Message: "raw query", Severity: model.SeverityWarning, Risk: model.RiskHigh},
+			{Pass: "custom", File: "x.go", Message: "something", Risk: model.RiskLow},
+		},
+		Suppressed: []analysis.Finding{
+			{Pass: "anti_patterns", Rule: analysis.RuleTodo, File: "util.go", Line: 7, Message: "TODO left in", Severity: model.SeverityWarning},
+		},
+	}
+
+	out := sarifReport(results)
+	if out.Version != "2.1.0" || len(out.Runs) != 1 {
+		t.Fatalf("unexpected log: %+v", out)
+	}
+	run := out.Runs[0]
+	if len(run.Tool.Driver.Rules) != len(analysis.Rules) || len(run.Results) != 3 {
+		t.Fatalf("expected every rule described and three results, got %+v", run)
+	}
+
+	r := run.Results[0]
+	if r.RuleID != "AGV-SEC-003" || r.RuleIndex == nil || run.Tool.Driver.Rules[*r.RuleIndex].ID != r.RuleID {
+		t.Errorf("expected the result to point at its rule, got %+v", r)
+	}
+	if r.Level != "warning" || r.Locations[0].PhysicalLocation.Region.StartLine != 4 || r.Properties["risk"] != "high" {
+		t.Errorf("unexpected result %+v", r)
+	}
+	if r := run.Results[1]; r.RuleID != "custom" || r.RuleIndex != nil || r.Level != "note" ||
+		r.Locations[0].PhysicalLocation.Region != nil {
+		t.Errorf("expected a file-level note under its pass, got %+v", r)
+	}
+	if r := run.Results[2]; len(r.Suppressions) != 1 || r.Suppressions[0].Kind != "external" {
+		t.Errorf("expected the false positive reported as suppressed, got %+v", r)
+	}
+}
+
+func TestGitHubActions(t *testing.T) {
+	dir := t.TempDir()
+	event := func(payload string) func(string) string {
+		path := filepath.Join(dir, "event.json")
+		if err := os.WriteFile(path, []byte(payload), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		env := map[string]string{
+			"GITHUB_EVENT_PATH":   path,
+			"GITHUB_EVENT_NAME":   "pull_request",
+			"GITHUB_STEP_SUMMARY": filepath.Join(dir, "summary.md"),
+			"GITHUB_OUTPUT":       filepath.Join(dir, "output"),
+		}
+		return func(k string) string { return env[k] }
+	}
+
+	g, err := githubContext(event(`{"number": 7, "pull_request": {"number": 7, "base": {"sha": "aaa"}, "head": {"sha": "bbb"}}}`))
+	if err != nil || g.PR != 7 || g.Range() != "aaa...bbb" {
+		t.Fatalf("expected the pull request's range, got %+v (%v)", g, err)
+	}
+	if g, _ := githubContext(event(`{"before": "aaa", "after": "bbb"}`)); g.Range() != "aaa..bbb" {
+		t.Errorf("expected a push's range, got %q", g.Range())
+	}
+	if g, _ := githubContext(event(`{"before": "` + zeroSHA + `", "after": "bbb"}`)); g.Range() != "" {
+		t.Errorf("expected no range for a new branch, got %q", g.Range())
+	}
+	if _, err := githubContext(func(string) string { return "" }); err == nil {
+		t.Error("expected an error outside GitHub Actions")
+	}
+
+	ds, _ := diff.Parse("diff --git a/auth.go b/auth.go\n--- a/auth.go\n+++ b/auth.go\n@@ -1 +1,2 @@\n package auth\n+var key = 1\n")
+	ds.Base, ds.Head = "aaa", "bbb"
+	rs := &review.Session{Diff: ds, Results: &analysis.Results{Findings: []analysis.Finding{
+		{Pass: "security", Rule: analysis.RuleSQL, File: "auth.go", Line: 2, Message: "50% of keys,\nmaybe", Risk: model.RiskHigh},
+		{Pass: "custom", File: "auth.go", Message: "note", Risk: model.RiskInfo},
+	}}}
+	var ann strings.Builder
+	writeAnnotations(&ann, rs, nil)
+	if want := "::error file=auth.go,line=2,title=agrev security AGV-SEC-003::[high risk] 50%25 of keys,%0Amaybe\n::notice file=auth.go,title=agrev custom::[info risk] note\n"; ann.String() != want {
+		t.Errorf("unexpected annotations:\n%s", ann.String())
+	}
+
+	if err := g.report(rs, nil); err != nil {
+		t.Fatal(err)
+	}
+	summary, _ := os.ReadFile(g.summary)
+	if !strings.Contains(string(summary), "Pull request #7") || !strings.Contains(string(summary), "| high | 1 |") ||
+		!strings.Contains(string(summary), "## Analysis Report") {
+		t.Errorf("unexpected job summary:\n%s", summary)
+	}
+	if out, _ := os.ReadFile(g.output); string(out) != "max_risk=high\nfinding_count=2\n" {
+		t.Errorf("unexpected outputs %q", out)
+	}
+}
+
+func TestBuildReview(t *testing.T) {
+	ds, err := diff.Parse(`diff --git a/auth.go b/auth.go
+index abc1234..def5678 100644
+--- a/auth.go
++++ b/auth.go
+@@ -1,2 +1,3 @@
+ package auth
++var token = "secret"
+ func Check() {}
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	results := &analysis.Results{Findings: []analysis.Finding{
+		{Pass: "security", File: "auth.go", Line: 2, Message: "hardcoded token", Risk: model.RiskHigh},
+		{Pass: "anti_patterns", File: "auth.go", Line: 2, Message: "global var", Risk: model.RiskLow},
+		{Pass: "blast_radius", File: "auth.go", Line: 40, Message: "many callers", Risk: model.RiskMedium},
+	}}
+	result := &tui.ReviewResult{
+		Files:     ds.Files,
+		Decisions: model.Decisions{
+			Files: map[int]model.ReviewDecision{0: model.DecisionRejected},
+			Notes: map[model.HunkKey]string{{File: 0, Hunk: model.WholeFile}: "leaks the token"},
+		},
+		Comments:  []model.Comment{{File: "auth.go", Line: 2, Body: "use the vault"}},
+	}
+
+	co, err := owners.Parse(strings.NewReader("*.go @org/security\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	review := buildReview(ds, results, result, co)
+	if len(review.Comments) != 1 {
+		t.Fatalf("expected findings on one line to share a comment, got %+v", review.Comments)
+	}
+	c := review.Comments[0]
+	if c.Path != "auth.go" || c.Line != 2 || c.Old {
+		t.Errorf("unexpected anchor %s:%d", c.Path, c.Line)
+	}
+	for _, want := range []string{"hardcoded token", "global var", "use the vault"} {
+		if !strings.Contains(c.Body, want) {
+			t.Errorf("inline comment missing %q: %q", want, c.Body)
+		}
+	}
+	for _, want := range []string{"| high | 1 | security (1) |", "Findings outside the diff", "auth.go:40", "rejected `auth.go` — leaks the token", "### Owners\n\n- `auth.go` — @org/security"} {
+		if !strings.Contains(review.Body, want) {
+			t.Errorf("summary missing %q:\n%s", want, review.Body)
+		}
+	}
+}
+
+func TestParseSince(t *testing.T) {
+	now := time.Date(2026, 3, 31, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		in   string
+		want time.Time
+	}{
+		{"", time.Time{}},
+		{"2026-03-01", time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)},
+		{"30d", time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)},
+		{"2w", time.Date(2026, 3, 17, 12, 0, 0, 0, time.UTC)},
+		{"12h", time.Date(2026, 3, 31, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		got, err := parseSince(tt.in, now)
+		if err != nil || !got.Equal(tt.want) {
+			t.Errorf("parseSince(%q) = %v, %v; want %v", tt.in, got, err, tt.want)
+		}
+	}
+	if _, err := parseSince("last week", now); err == nil {
+		t.Error("expected error for unparseable --since")
+	}
+}
+
+func TestGetDiffPatchFiles(t *testing.T) {
+	dir := t.TempDir()
+	first := filepath.Join(dir, "0001-add-a.patch")
+	second := filepath.Join(dir, "0002-add-b.patch")
+	// A format-patch mail, without a trailing newline to check concatenation
+	os.WriteFile(first, []byte(`From 1234 Mon Sep 17 00:00:00 2001
+From: Dev <dev@example.com>
+Subject: [PATCH 1/2] Add a
+
+---
+diff --git a/a.txt b/a.txt
+new file mode 100644
+--- /dev/null
++++ b/a.txt
+@@ -0,0 +1 @@
++a`), 0644)
+	os.WriteFile(second, []byte(`diff --git a/b.txt b/b.txt
+new file mode 100644
+--- /dev/null
++++ b/b.txt
+@@ -0,0 +1 @@
++b
+`), 0644)
+
+	raw, _, err := getDiff(nil, []string{first, second}, 3)
+	if err != nil {
+		t.Fatalf("getDiff failed: %v", err)
+	}
+	ds, err := diff.Parse(raw)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if len(ds.Files) != 2 || ds.Files[0].Name() != "a.txt" || ds.Files[1].Name() != "b.txt" {
+		t.Errorf("expected both patches' files, got %d", len(ds.Files))
+	}
+
+	// "-" is the command's input, not /dev/stdin
+	cmd := &cobra.Command{}
+	cmd.SetIn(strings.NewReader("diff --git a/c.txt b/c.txt\nnew file mode 100644\n--- /dev/null\n+++ b/c.txt\n@@ -0,0 +1 @@\n+c\n"))
+	raw, _, err = getDiff(cmd, []string{first, "-"}, 3)
+	if err != nil {
+		t.Fatalf("getDiff with stdin failed: %v", err)
+	}
+	if ds, err = diff.Parse(raw); err != nil || len(ds.Files) != 2 || ds.Files[1].Name() != "c.txt" {
+		t.Errorf("expected the patch file and stdin's, got %v", err)
+	}
+
+	if _, _, err := getDiff(nil, []string{first, "HEAD~1..HEAD"}, 3); err == nil || !strings.Contains(err.Error(), "HEAD~1..HEAD is not a file") {
+		t.Errorf("expected an error mixing patches and a range, got %v", err)
+	}
+}
+
+func TestSplitCommitsPatchSeries(t *testing.T) {
+	dir := t.TempDir()
+	var paths []string
+	for i, name := range []string{"a", "b"} {
+		path := filepath.Join(dir, fmt.Sprintf("000%d-add-%s.patch", i+1, name))
+		os.WriteFile(path, []byte(fmt.Sprintf(`From %040d Mon Sep 17 00:00:00 2001
+From: Dev <dev@example.com>
+Date: Tue, 3 Mar 2026 10:00:0%d +0000
+Subject: [PATCH %d/2] Add %s
+
+Why %s is needed.
+---
+ %s.txt | 1 +
+
+diff --git a/%s.txt b/%s.txt
+new file mode 100644
+--- /dev/null
++++ b/%s.txt
+@@ -0,0 +1 @@
++%s
+`, i+1, i, i+1, name, name, name, name, name, name, name)), 0644)
+		paths = append(paths, path)
+	}
+
+	raw, _, err := getDiff(nil, paths, 3)
+	if err != nil {
+		t.Fatalf("getDiff failed: %v", err)
+	}
+	commits, err := splitCommits(nil, paths, raw, 3)
+	if err != nil {
+		t.Fatalf("splitCommits failed: %v", err)
+	}
+	if len(commits) != 2 {
+		t.Fatalf("expected 2 commits, got %d", len(commits))
+	}
+	for i, name := range []string{"a", "b"} {
+		c := commits[i]
+		if c.Subject != "Add "+name || c.Body != "Why "+name+" is needed." || c.Author != "Dev <dev@example.com>" {
+			t.Errorf("commit %d = %+v", i, c)
+		}
+		if len(c.Diff.Files) != 1 || c.Diff.Files[0].Name() != name+".txt" {
+			t.Errorf("commit %d should add %s.txt", i, name)
+		}
+	}
+}
+
+func TestRangeArgs(t *testing.T) {
+	newCmd := func(flags ...string) *cobra.Command {
+		c := &cobra.Command{Use: "test"}
+		addSourceFlags(c)
+		if err := c.ParseFlags(flags); err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+
+	tests := []struct {
+		flags []string
+		args  []string
+		want  string
+		err   string
+	}{
+		{nil, []string{"HEAD~1..HEAD"}, "HEAD~1..HEAD", ""},
+		{[]string{"--base", "main"}, nil, "main...HEAD", ""},
+		{[]string{"--base", "main"}, []string{"feature"}, "main...feature", ""},
+		{[]string{"--base", "main"}, []string{"a..b"}, "", "takes the place of a range"},
+		{[]string{"--base", "main", "--staged"}, nil, "", "--staged"},
+	}
+	for _, tt := range tests {
+		got, err := rangeArgs(newCmd(tt.flags...), tt.args)
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("rangeArgs(%v, %v): expected error containing %q, got %v", tt.flags, tt.args, tt.err, err)
+			}
+			continue
+		}
+		if err != nil || len(got) != 1 || got[0] != tt.want {
+			t.Errorf("rangeArgs(%v, %v) = %v, %v; want %s", tt.flags, tt.args, got, err, tt.want)
+		}
+	}
+}
+
+func TestGetDiffDirs(t *testing.T) {
+	root := t.TempDir()
+	from, to := filepath.Join(root, "a"), filepath.Join(root, "b")
+	os.Mkdir(from, 0755)
+	os.Mkdir(to, 0755)
+	os.WriteFile(filepath.Join(from, "f.txt"), []byte("one\n"), 0644)
+	os.WriteFile(filepath.Join(to, "f.txt"), []byte("two\n"), 0644)
+
+	newCmd := func(flags ...string) *cobra.Command {
+		c := &cobra.Command{Use: "test"}
+		addSourceFlags(c)
+		if err := c.ParseFlags(flags); err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+
+	raw, _, err := getDiff(newCmd("--from", from, "--to", to), nil, 3)
+	if err != nil {
+		t.Fatalf("getDiff failed: %v", err)
+	}
+	if !strings.Contains(raw, "-one\n+two\n") {
+		t.Errorf("unexpected diff:\n%s", raw)
+	}
+
+	if _, _, err := getDiff(newCmd("--from", from), nil, 3); err == nil || !strings.Contains(err.Error(), "used together") {
+		t.Errorf("expected an error for --from without --to, got %v", err)
+	}
+	if _, _, err := getDiff(newCmd("--from", from, "--to", to, "--staged"), nil, 3); err == nil {
+		t.Error("expected an error combining --from with --staged")
+	}
+	if _, _, err := getDiff(newCmd("--from", from, "--to", to), []string{"HEAD~1..HEAD"}, 3); err == nil {
+		t.Error("expected an error combining --from with a range")
+	}
+}
+
+func TestLoadRepos(t *testing.T) {
+	root := t.TempDir()
+	var dirs []string
+	for _, name := range []string{"api", "web"} {
+		dir := filepath.Join(root, name)
+		os.Mkdir(dir, 0755)
+		git := func(args ...string) {
+			t.Helper()
+			c := exec.Command("git", args...)
+			c.Dir = dir
+			c.Env = append(os.Environ(),
+				"GIT_AUTHOR_NAME=Test", "GIT_AUTHOR_EMAIL=test@example.com",
+				"GIT_COMMITTER_NAME=Test", "GIT_COMMITTER_EMAIL=test@example.com")
+			if out, err := c.CombinedOutput(); err != nil {
+				t.Skipf("git %v: %v\n%s", args, err, out)
+			}
+		}
+		git("init", "-q")
+		os.WriteFile(filepath.Join(dir, "main.go"), []byte("package main\n"), 0644)
+		git("add", ".")
+		git("commit", "-q", "-m", "base")
+		os.WriteFile(filepath.Join(dir, "main.go"), []byte("package main\n\n// TODO: finish "+name+"\n"), 0644)
+		dirs = append(dirs, dir)
+	}
+	_, repos, err := diff.Repos(dirs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := &cobra.Command{Use: "test"}
+	addSourceFlags(c)
+	ds, ar, err := loadRepos(c, repos, nil, 3)
+	if err != nil {
+		t.Fatalf("loadRepos failed: %v", err)
+	}
+	if len(ds.Files) != 2 || ds.Files[0].Name() != "api/main.go" || ds.Files[1].Name() != "web/main.go" {
+		t.Fatalf("expected each repository's file under its name, got %d files", len(ds.Files))
+	}
+	byFile := ar.ByFile()
+	if len(byFile["api/main.go"]) == 0 || len(byFile["web/main.go"]) == 0 {
+		t.Errorf("expected findings listed under the repositories, got %+v", ar.Findings)
+	}
+
+	if _, _, err := loadRepos(c, []diff.Repo{{Name: "gone", Dir: filepath.Join(root, "gone")}}, nil, 3); err == nil || !strings.HasPrefix(err.Error(), "gone: ") {
+		t.Errorf("expected an error naming the repository, got %v", err)
+	}
+	if got := repoPatchPath("out/approved.patch", repos[0]); got != "out/approved.api.patch" {
+		t.Errorf("unexpected patch path %q", got)
+	}
+}
+
+func TestFetchRemoteBranch(t *testing.T) {
+	root := t.TempDir()
+	upstream, clone := filepath.Join(root, "upstream"), filepath.Join(root, "clone")
+	git := func(dir string, args ...string) {
+		t.Helper()
+		c := exec.Command("git", args...)
+		c.Dir = dir
+		c.Env = append(os.Environ(),
+			"GIT_AUTHOR_NAME=Test", "GIT_AUTHOR_EMAIL=test@example.com",
+			"GIT_COMMITTER_NAME=Test", "GIT_COMMITTER_EMAIL=test@example.com")
+		if out, err := c.CombinedOutput(); err != nil {
+			t.Skipf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	os.Mkdir(upstream, 0755)
+	git(upstream, "init", "-q", "-b", "trunk")
+	os.WriteFile(filepath.Join(upstream, "main.go"), []byte("package main\n"), 0644)
+	git(upstream, "add", ".")
+	git(upstream, "commit", "-q", "-m", "base")
+	git(root, "clone", "-q", upstream, clone)
+
+	// Pushed after the clone, so only fetching finds it
+	git(upstream, "checkout", "-q", "-b", "agent/fix")
+	os.WriteFile(filepath.Join(upstream, "main.go"), []byte("package main\n\n// TODO: fix\n"), 0644)
+	git(upstream, "commit", "-q", "-am", "fix")
+	git(upstream, "checkout", "-q", "trunk")
+	// Without the HEAD 'git clone' records, the remote is asked
+	git(clone, "symbolic-ref", "--delete", "refs/remotes/origin/HEAD")
+
+	if remote, _ := splitRemoteRef(clone, "upstream/agent/fix"); remote != "" {
+		t.Errorf("expected no remote matched, got %q", remote)
+	}
+	if _, err := fetchRemoteBranch(clone, "agent/fix", ""); err == nil {
+		t.Error("expected a branch without a remote refused")
+	}
+
+	b, err := fetchRemoteBranch(clone, "origin/agent/fix", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.base != "origin/trunk" || b.rangeArg() != "origin/trunk...origin/agent/fix" {
+		t.Errorf("expected the default branch as the base, got %q", b.base)
+	}
+	if data, _ := os.ReadFile(filepath.Join(b.worktree, "main.go")); !strings.Contains(string(data), "TODO: fix") {
+		t.Errorf("expected the branch checked out in the worktree, got %q", data)
+	}
+	raw, _, err := diff.GitDiffRange(clone, b.rangeArg(), 3)
+	if err != nil || !strings.Contains(raw, "+// TODO: fix") {
+		t.Errorf("expected the branch's change, got %q, %v", raw, err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(clone, "main.go")); strings.Contains(string(data), "TODO") {
+		t.Error("expected the checkout left alone")
+	}
+	b.remove()
+	if _, err := os.Stat(b.worktree); !os.IsNotExist(err) {
+		t.Errorf("expected the worktree removed, got %v", err)
+	}
+}
+
+func TestLoadCoverage(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cover.out")
+	if err := os.WriteFile(path, []byte("mode: set\nexample.com/app/app.go:3.14,5.2 1 0\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	ds, err := diff.Parse("diff --git a/app.go b/app.go\nnew file mode 100644\n--- /dev/null\n+++ b/app.go\n@@ -0,0 +1,5 @@\n+package app\n+\n+func f() int {\n+\treturn 1\n+}\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := &cobra.Command{Use: "check"}
+	addCoverageFlag(cmd)
+	if err := loadCoverage(cmd, nil); err != nil {
+		t.Fatal(err)
+	}
+	if findings := runAnalysis(cmd, ds, "", nil).ByFile()["app.go"]; len(findings) != 0 {
+		t.Errorf("expected no coverage findings without --coverage, got %v", findings)
+	}
+
+	cmd.Flags().Set("coverage", path)
+	if err := loadCoverage(cmd, nil); err != nil {
+		t.Fatal(err)
+	}
+	var uncovered []analysis.Finding
+	for _, f := range runAnalysis(cmd, ds, "", nil).Findings {
+		if f.Pass == "coverage" {
+			uncovered = append(uncovered, f)
+		}
+	}
+	if len(uncovered) != 1 || uncovered[0].Line != 3 || uncovered[0].Message != "Added lines 3-5 aren't covered by tests" {
+		t.Errorf("unexpected coverage findings %v", uncovered)
+	}
+
+	cmd.Flags().Set("coverage", filepath.Join(t.TempDir(), "missing.out"))
+	if err := loadCoverage(cmd, nil); err == nil {
+		t.Error("expected a missing report to be an error")
+	}
+}
+
+func TestPreReceive(t *testing.T) {
+	dir := t.TempDir()
+	git := func(args ...string) string {
+		t.Helper()
+		c := exec.Command("git", args...)
+		c.Dir = dir
+		c.Env = append(os.Environ(),
+			"GIT_AUTHOR_NAME=Test", "GIT_AUTHOR_EMAIL=test@example.com",
+			"GIT_COMMITTER_NAME=Test", "GIT_COMMITTER_EMAIL=test@example.com")
+		out, err := c.CombinedOutput()
+		if err != nil {
+			t.Skipf("git %v: %v\n%s", args, err, out)
+		}
+		return strings.TrimSpace(string(out))
+	}
+	git("init", "-q", "-b", "main")
+	os.WriteFile(filepath.Join(dir, ".agrev.yml"), []byte("gate:\n  forbidden_paths: [\"secrets/*\"]\n"), 0644)
+	os.WriteFile(filepath.Join(dir, "main.go"), []byte("package main\n"), 0644)
+	git("add", ".")
+	git("commit", "-q", "-m", "base")
+	base := git("rev-parse", "HEAD")
+
+	git("checkout", "-q", "-b", "leak")
+	os.MkdirAll(filepath.Join(dir, "secrets"), 0755)
+	os.WriteFile(filepath.Join(dir, "secrets", "key.txt"), []byte("hunter2\n"), 0644)
+	// A push can't lift the policy it is checked against
+	os.WriteFile(filepath.Join(dir, ".agrev.yml"), []byte("gate: {}\n"), 0644)
+	git("add", ".")
+	git("commit", "-q", "-m", "leak")
+	leak := git("rev-parse", "HEAD")
+	git("checkout", "-q", "main")
+	gitDir := filepath.Join(dir, ".git")
+
+	updates, err := readRefUpdates(strings.NewReader(base + " " + leak + " refs/heads/main\n\n" + strings.Repeat("0", 40) + " " + leak + " refs/heads/leak\n"))
+	if err != nil || len(updates) != 2 || updates[1].ref != "refs/heads/leak" {
+		t.Fatalf("unexpected ref updates %+v, %v", updates, err)
+	}
+	if _, err := readRefUpdates(strings.NewReader("abc refs/heads/main\n")); err == nil {
+		t.Error("expected a malformed line to be an error")
+	}
+
+	cmd := &cobra.Command{Use: "pre-receive"}
+	cmd.Flags().String("policy", "", "")
+	cfg, pol, err := pushPolicy(cmd, gitDir)
+	if err != nil || pol != nil || len(cfg.Gate.ForbiddenPaths) != 1 {
+		t.Fatalf("expected the gate policy on HEAD, got %+v, %v, %v", cfg.Gate, pol, err)
+	}
+	for _, u := range updates {
+		ds, _, violations, err := checkRefUpdate(cmd, gitDir, cfg, pol, nil, u)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ds == nil || len(violations) != 1 || violations[0].File != "secrets/key.txt" {
+			t.Errorf("%s: expected the forbidden file rejected, got %+v", u.ref, violations)
+		}
+	}
+
+	ds, _, _, err := checkRefUpdate(cmd, gitDir, cfg, pol, nil, refUpdate{old: leak, new: leak, ref: "refs/heads/leak"})
+	if err != nil || ds != nil {
+		t.Errorf("expected a ref that changes nothing skipped, got %v, %v", ds, err)
+	}
+	if !matchesRef([]string{"refs/heads/**"}, "refs/heads/agent/fix") || matchesRef([]string{"refs/heads/**"}, "refs/tags/v1") {
+		t.Error("expected only branches matched by default")
+	}
+
+	cmd.Flags().Set("policy", filepath.Join(dir, "missing.yml"))
+	if _, _, err := pushPolicy(cmd, gitDir); err == nil {
+		t.Error("expected a missing --policy file to be an error")
+	}
+}
+
+func TestReadPatchStdin(t *testing.T) {
+	cmd := &cobra.Command{}
+	cmd.SetIn(strings.NewReader("diff --git a/a.txt b/a.txt\n"))
+	data, err := readPatch(cmd, "-")
+	if err != nil {
+		t.Fatalf("readPatch failed: %v", err)
+	}
+	if string(data) != "diff --git a/a.txt b/a.txt\n" {
+		t.Errorf("expected the command's input, got %q", data)
+	}
+	if _, err := readPatch(cmd, filepath.Join(t.TempDir(), "missing.patch")); err == nil || !strings.Contains(err.Error(), "reading patch") {
+		t.Errorf("expected an error for a missing patch, got %v", err)
+	}
+}
@@ -1,36 +1,209 @@
 package cli
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/aezell/agrev/internal/api"
+	"github.com/aezell/agrev/internal/config"
 )
 
 var serveCmd = &cobra.Command{
-	Use:   "serve",
+	Use:   "serve [--web [commit-range | patch...]]",
 	Short: "Start the HTTP API server",
 	Long: `Start an HTTP server exposing the agrev analysis engine.
 
 Endpoints:
-  GET  /health       — Health check
-  POST /api/analyze  — Run analysis on a diff
-  POST /api/parse    — Parse a diff into structured files
-  POST /api/summary  — Generate summary from agent trace
-  GET  /api/ws       — WebSocket for interactive review sessions`,
+  GET  /health            — Health check
+  GET  /api/openapi.json  — OpenAPI 3 document for the API
+  POST /api/analyze       — Run analysis on a diff
+  POST /api/analyze/batch — Run analysis on several diffs or commits
+  POST /api/parse         — Parse a diff into structured files
+  POST /api/summary       — Generate summary from agent trace
+  GET  /api/ws            — WebSocket for interactive review sessions
+                            (?session=<id> joins a shared one)
+  POST /api/sessions/{id}/patch           — Approved changes of a session as a patch
+  POST /api/sessions/{id}/commit-message  — Commit message for the approved changes
+  GET  /api/sessions/{id}/files/{index}/lines — Rendered diff lines with syntax tokens
+  GET  /api/workspace/diff      — Uncommitted changes of the repository
+  GET  /api/workspace/findings  — Their findings (?file=<path> for one file)
+  GET  /api/workspace/decisions — Decisions of the saved review on them
+  POST /api/workspace/decisions — Approve, reject, or undo a file or hunk
+  GET  /api/workspace/trace     — Trace steps and notes on a file (?file=<path>)
+
+The /api/workspace endpoints are for editor extensions, and are served
+when started in a git repository. Decisions are kept in its
+.agrev/session.json, which 'agrev review' resumes.
+
+With --grpc-port, the agrev.v1.Agrev gRPC service (Parse, Analyze,
+AnalyzeStream, LoadTrace, ApplyDecisions) is served on that port too; see
+internal/api/agrevpb/agrev.proto.
+
+When tokens are configured, /api routes require an "Authorization: Bearer
+<token>" header. Read tokens may call analyze, parse, and summary; write
+tokens may also open review sessions. Tokens come from serve.tokens in
+.agrev.yml, AGREV_API_TOKEN (write), and AGREV_API_READ_TOKEN (read).
+
+With --web, a browser review UI is served at / for the given commit range
+or patches (the working tree by default), reloaded on each page load. Open
+http://127.0.0.1:6142/?token=<token> when tokens are configured.
+
+On SIGINT or SIGTERM the server drains in-flight requests, closes WebSocket
+sessions, and cancels analyses still running after --shutdown-timeout.`,
+	Args: cobra.ArbitraryArgs,
 	RunE: runServe,
 }
 
 func init() {
 	serveCmd.Flags().StringP("addr", "a", "127.0.0.1", "address to listen on")
 	serveCmd.Flags().IntP("port", "p", 6142, "port to listen on")
+	serveCmd.Flags().Int("grpc-port", 0, "also serve the gRPC API on this port (0 disables it)")
+	serveCmd.Flags().String("log-format", "text", "log format: text, json")
+	serveCmd.Flags().Duration("shutdown-timeout", 10*time.Second, "how long to drain requests and sessions on SIGINT/SIGTERM")
+	serveCmd.Flags().Bool("web", false, "serve the browser review UI at /")
+	serveCmd.Flags().StringP("trace", "t", "", "path to agent trace file for the web UI")
+	serveCmd.Flags().Bool("no-trace", false, "skip trace auto-detection for the web UI")
+	addSourceFlags(serveCmd)
 }
 
 func runServe(cmd *cobra.Command, args []string) error {
 	addr, _ := cmd.Flags().GetString("addr")
 	port, _ := cmd.Flags().GetInt("port")
+	logFormat, _ := cmd.Flags().GetString("log-format")
+
+	var logger *slog.Logger
+	switch logFormat {
+	case "text":
+		logger = slog.New(slog.NewTextHandler(os.Stderr, nil))
+	case "json":
+		logger = slog.New(slog.NewJSONHandler(os.Stderr, nil))
+	default:
+		return fmt.Errorf("unknown log format %q (want text or json)", logFormat)
+	}
+
+	repoDir, _ := gitRepoRoot()
+	cfg, err := config.Load(repoDir)
+	if err != nil {
+		return err
+	}
+	tokens, err := apiTokens(cfg.Serve)
+	if err != nil {
+		return err
+	}
+	if len(tokens) == 0 {
+		if ip := net.ParseIP(addr); ip == nil || !ip.IsLoopback() {
+			fmt.Fprintf(os.Stderr, "Warning: serving on %s without authentication; set AGREV_API_TOKEN or serve.tokens\n", addr)
+		}
+	}
+
+	opts := api.Options{Tokens: tokens, Version: version, Logger: logger}
+	if repoDir != "" {
+		opts.Workspace = func() (*api.Source, error) {
+			raw, _, err := getRepoDiff(cmd, repoDir, nil, 3)
+			if err != nil {
+				return nil, err
+			}
+			t, _ := loadTrace(cmd)
+			return &api.Source{Diff: raw, RepoDir: repoDir, Trace: t}, nil
+		}
+	}
+	if web, _ := cmd.Flags().GetBool("web"); web {
+		if slices.Contains(args, "-") {
+			return fmt.Errorf("--web reloads the diff on each page load and can't read it from stdin")
+		}
+		opts.Source = func() (*api.Source, error) {
+			raw, _, err := getDiff(cmd, args, 3)
+			if err != nil {
+				return nil, err
+			}
+			t, _ := loadTrace(cmd)
+			return &api.Source{Label: strings.Join(args, " "), Diff: raw, RepoDir: repoDir, Trace: t}, nil
+		}
+		host := addr
+		if ip := net.ParseIP(addr); ip != nil && ip.IsUnspecified() {
+			host = "127.0.0.1"
+		}
+		fmt.Fprintf(os.Stderr, "Review in your browser at http://%s/\n", net.JoinHostPort(host, fmt.Sprint(port)))
+	} else if len(args) > 0 {
+		return fmt.Errorf("a commit range or patch is only used with --web")
+	}
+
+	listen := net.JoinHostPort(addr, fmt.Sprint(port))
+	srv := api.New(listen, opts)
+
+	ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	errc := make(chan error, 2)
+	go func() { errc <- srv.ListenAndServe() }()
+	servers := 1
+	if grpcPort, _ := cmd.Flags().GetInt("grpc-port"); grpcPort != 0 {
+		go func() { errc <- srv.ListenAndServeGRPC(net.JoinHostPort(addr, fmt.Sprint(grpcPort))) }()
+		servers++
+	}
+
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+	}
+	// A second signal kills the process as usual
+	stop()
+
+	timeout, _ := cmd.Flags().GetDuration("shutdown-timeout")
+	logger.Info("shutting down", "timeout", timeout)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil && !errors.Is(err, context.DeadlineExceeded) {
+		return err
+	}
+	for range servers {
+		if err := <-errc; err != nil && !errors.Is(err, http.ErrServerClosed) {
+			return err
+		}
+	}
+	return nil
+}
 
-	listen := fmt.Sprintf("%s:%d", addr, port)
-	srv := api.New(listen)
-	return srv.ListenAndServe()
+// apiTokens collects the API tokens from the config and environment.
+func apiTokens(cfg config.ServeConfig) ([]api.Token, error) {
+	var tokens []api.Token
+	for i, tc := range cfg.Tokens {
+		name := tc.Name
+		if name == "" {
+			name = fmt.Sprintf("#%d", i+1)
+		}
+		scope, ok := api.ParseScope(tc.Scope)
+		if !ok {
+			return nil, fmt.Errorf("serve.tokens %s: invalid scope %q (want read or write)", name, tc.Scope)
+		}
+		value := tc.Token
+		if tc.TokenEnv != "" {
+			if value = os.Getenv(tc.TokenEnv); value == "" {
+				return nil, fmt.Errorf("serve.tokens %s: $%s is not set", name, tc.TokenEnv)
+			}
+		}
+		if value == "" {
+			return nil, fmt.Errorf("serve.tokens %s: set token or token_env", name)
+		}
+		tokens = append(tokens, api.Token{Name: name, Value: value, Scope: scope})
+	}
+	if v := os.Getenv("AGREV_API_TOKEN"); v != "" {
+		tokens = append(tokens, api.Token{Name: "AGREV_API_TOKEN", Value: v, Scope: api.ScopeWrite})
+	}
+	if v := os.Getenv("AGREV_API_READ_TOKEN"); v != "" {
+		tokens = append(tokens, api.Token{Name: "AGREV_API_READ_TOKEN", Value: v, Scope: api.ScopeRead})
+	}
+	return tokens, nil
 }
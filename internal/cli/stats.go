@@ -0,0 +1,176 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/aezell/agrev/internal/history"
+	"github.com/aezell/agrev/internal/triage"
+)
+
+var statsCmd = &cobra.Command{
+	Use:   "stats",
+	Short: "Show statistics from past reviews",
+	Long: `Summarize the reviews recorded in .agrev/history.jsonl: approval rates,
+the most-flagged analysis passes, and per-agent trends by week. Findings
+reviewers acknowledged or marked as false positives or fixed
+(.agrev/findings.json) are counted separately, per pass.
+
+Every interactive review (review, pr, commit, comment --review) is
+recorded unless .agrev.yml sets no_history: true.
+
+Examples:
+  agrev stats                      # all recorded reviews
+  agrev stats --since 30d          # last 30 days
+  agrev stats --since 2026-01-01 --format json`,
+	Args: cobra.NoArgs,
+	RunE: runStats,
+}
+
+func init() {
+	statsCmd.Flags().String("since", "", "only include reviews since a date (YYYY-MM-DD) or age (e.g. 30d, 8w, 12h)")
+	statsCmd.Flags().StringP("format", "f", "text", "output format: text, json")
+	statsCmd.Flags().Int("weeks", 8, "weeks of per-agent trend to show")
+}
+
+func runStats(cmd *cobra.Command, args []string) error {
+	repoDir, err := gitRepoRoot()
+	if err != nil {
+		return fmt.Errorf("not in a git repository (or git not installed): %w", err)
+	}
+
+	sinceFlag, _ := cmd.Flags().GetString("since")
+	since, err := parseSince(sinceFlag, time.Now())
+	if err != nil {
+		return err
+	}
+
+	records, err := history.Load(repoDir)
+	if err != nil {
+		return err
+	}
+	stats := history.Summarize(records, since)
+	triaged := loadTriage(repoDir).Summarize(since)
+
+	format, _ := cmd.Flags().GetString("format")
+	switch format {
+	case "json":
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(struct {
+			history.Stats
+			Triaged triage.Summary `json:"triaged"`
+		}{stats, triaged})
+	case "text":
+		weeks, _ := cmd.Flags().GetInt("weeks")
+		printStats(stats, since, weeks)
+		printTriaged(triaged)
+		return nil
+	default:
+		return fmt.Errorf("unknown format %q (want text or json)", format)
+	}
+}
+
+// parseSince parses a date or an age relative to now. Empty means all time.
+func parseSince(s string, now time.Time) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", s, now.Location()); err == nil {
+		return t, nil
+	}
+	if len(s) > 1 {
+		n, err := strconv.Atoi(s[:len(s)-1])
+		if err == nil && n >= 0 {
+			switch s[len(s)-1] {
+			case 'h':
+				return now.Add(-time.Duration(n) * time.Hour), nil
+			case 'd':
+				return now.AddDate(0, 0, -n), nil
+			case 'w':
+				return now.AddDate(0, 0, -7*n), nil
+			}
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid --since %q: want YYYY-MM-DD or an age like 30d, 8w, 12h", s)
+}
+
+func printStats(s history.Stats, since time.Time, weeks int) {
+	if s.Reviews == 0 {
+		fmt.Println("No reviews recorded yet.")
+		return
+	}
+
+	period := "all time"
+	if !since.IsZero() {
+		period = "since " + since.Format("2006-01-02")
+	}
+	fmt.Printf("%d review(s) %s · %d file(s) · %s reviewing\n",
+		s.Reviews, period, s.Files, time.Duration(s.Duration)*time.Second)
+	fmt.Printf("Approval rate: %s (%d approved, %d rejected, %d pending)\n",
+		formatRate(s.Approved, s.Rejected), s.Approved, s.Rejected, s.Pending)
+	fmt.Printf("Comments: %d · Findings: %d\n", s.Comments, s.Findings)
+
+	if len(s.Passes) > 0 {
+		fmt.Println("\nMost-flagged passes")
+		for _, p := range s.Passes {
+			fmt.Printf("  %-15s %5d\n", p.Pass, p.Count)
+		}
+	}
+
+	fmt.Println("\nBy agent")
+	for _, a := range s.Agents {
+		name := a.Agent
+		if name == "" {
+			name = "(no trace)"
+		}
+		fmt.Printf("  %-14s %4d review(s)  approval %-4s  %.1f findings/review\n",
+			name, a.Reviews, formatRate(a.Approved, a.Rejected), float64(a.Findings)/float64(a.Reviews))
+
+		shown := a.Weeks
+		if weeks >= 0 && len(shown) > weeks {
+			shown = shown[len(shown)-weeks:]
+		}
+		for _, w := range shown {
+			rate := history.ApprovalRate(w.Approved, w.Rejected)
+			fmt.Printf("    week of %s  %3d review(s)  approval %-4s  %s\n",
+				w.Start.Format("2006-01-02"), w.Reviews, formatRate(w.Approved, w.Rejected), rateBar(rate, 20))
+		}
+	}
+}
+
+// printTriaged reports the findings reviewers triaged, if any.
+func printTriaged(t triage.Summary) {
+	if len(t.Passes) == 0 {
+		return
+	}
+	fmt.Printf("\nTriaged findings: %d acknowledged, %d false positive(s), %d fixed\n", t.Acknowledged, t.FalsePositives, t.Fixed)
+	fmt.Printf("  %-15s %5s %5s %5s\n", "", "ack", "false", "fixed")
+	for _, p := range t.Passes {
+		fmt.Printf("  %-15s %5d %5d %5d\n", p.Pass, p.Acknowledged, p.FalsePositives, p.Fixed)
+	}
+}
+
+// formatRate renders an approval rate as a percentage, or "-" if nothing
+// was decided.
+func formatRate(approved, rejected int) string {
+	rate := history.ApprovalRate(approved, rejected)
+	if rate < 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%.0f%%", rate*100)
+}
+
+// rateBar draws a rate in [0, 1] as a bar of the given width.
+func rateBar(rate float64, width int) string {
+	if rate < 0 {
+		return ""
+	}
+	filled := int(rate*float64(width) + 0.5)
+	return strings.Repeat("█", filled) + strings.Repeat("░", width-filled)
+}
@@ -3,25 +3,67 @@ package cli
 import (
 	"fmt"
 	"os"
+	"strings"
 
 	"github.com/spf13/cobra"
+	"github.com/aezell/agrev/internal/analysis"
+	"github.com/aezell/agrev/internal/config"
+	"github.com/aezell/agrev/internal/diff"
+	"github.com/aezell/agrev/internal/explain"
+	"github.com/aezell/agrev/internal/issues"
+	"github.com/aezell/agrev/internal/provider"
 	"github.com/aezell/agrev/internal/trace"
 )
 
 var summaryCmd = &cobra.Command{
-	Use:   "summary",
+	Use:   "summary [commit-range | patch...]",
 	Short: "Generate a PR description from agent trace",
 	Long: `Parse the agent conversation trace and generate a summary suitable
-for use as a pull request description.`,
+for use as a pull request description.
+
+Given a commit range or a patch series ('git log -p' or 'git format-patch'
+output), the summary also lists each commit with the lines it changed.
+
+Issues the agent was asked about or the commit messages mention, Jira keys
+such as PROJ-123 and GitHub issues such as #456, are listed and linked as
+set up under issues in .agrev.yml. With --verify-issues, each is looked up
+to check it exists and is still open.
+
+With --llm, the description is written by the LLM configured under explain
+in .agrev.yml instead, from what the agent was asked, the commands it ran,
+the diff's stats, and the analysis findings, in Intent, Changes, Risks, and
+Test evidence sections. Without a commit range the diff is the working
+tree's. If no LLM is configured or it can't be reached, the usual summary
+is printed. Use --prompt to see exactly what would be sent.`,
+	Args: cobra.ArbitraryArgs,
 	RunE: runSummary,
 }
 
 func init() {
 	summaryCmd.Flags().StringP("trace", "t", "", "path to agent trace file")
 	summaryCmd.Flags().StringP("format", "f", "markdown", "output format: markdown, text")
+	summaryCmd.Flags().Bool("verify-issues", false, "check that the issues referred to exist and are open")
+	summaryCmd.Flags().Bool("llm", false, "compose the description with the LLM configured under explain")
+	summaryCmd.Flags().Bool("prompt", false, "with --llm, print the prompt instead of sending it")
 }
 
 func runSummary(cmd *cobra.Command, args []string) error {
+	var commits []diff.Commit
+	var raw string
+	if len(args) > 0 {
+		var err error
+		raw, _, err = getDiff(cmd, args, 3)
+		if err != nil {
+			return err
+		}
+		if commits, err = splitCommits(cmd, args, raw, 3); err != nil {
+			return err
+		}
+		if len(commits) == 0 {
+			return fmt.Errorf("no commits in %s", strings.Join(args, " "))
+		}
+	}
+
 	tracePath, _ := cmd.Flags().GetString("trace")
 
 	var t *trace.Trace
@@ -32,25 +74,188 @@ func runSummary(cmd *cobra.Command, args []string) error {
 		if err != nil {
 			return fmt.Errorf("loading trace: %w", err)
 		}
-	} else {
-		// Auto-detect
-		repoDir, repoErr := gitRepoRoot()
-		if repoErr != nil {
+	} else if repoDir, repoErr := gitRepoRoot(); repoErr != nil {
+		// Commits make a summary on their own
+		if commits == nil {
 			return fmt.Errorf("not in a git repository; use --trace to specify trace file: %w", repoErr)
 		}
+	} else {
+		// Auto-detect
 		t, err = trace.DetectAndLoad(repoDir)
 		if err != nil {
 			return fmt.Errorf("detecting trace: %w", err)
 		}
 	}
 
-	if t == nil {
+	if t == nil && commits == nil {
 		fmt.Fprintln(os.Stderr, "No agent trace found. Use --trace to specify a trace file.")
 		return nil
 	}
 
-	fmt.Fprintf(os.Stderr, "Source: %s (%d steps, %d files)\n\n", t.Source, len(t.Steps), len(t.FilesChanged))
-	fmt.Print(t.Summary)
+	format, _ := cmd.Flags().GetString("format")
+	llm, _ := cmd.Flags().GetBool("llm")
+	onlyPrompt, _ := cmd.Flags().GetBool("prompt")
+	if onlyPrompt && !llm {
+		return fmt.Errorf("--prompt shows what --llm sends; use them together")
+	}
+	if t != nil {
+		fmt.Fprintf(os.Stderr, "Source: %s (%d steps, %d files)\n\n", t.Source, len(t.Steps), len(t.FilesChanged))
+	}
+	described := false
+	if llm {
+		d := describeChange(cmd, t, raw, commits)
+		if onlyPrompt {
+			fmt.Print(d.Prompt())
+			return nil
+		}
+		if text, err := composeDescription(d); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v; writing the usual summary instead\n", err)
+		} else {
+			fmt.Println(text)
+			described = true
+		}
+	}
+	if t != nil && !described {
+		fmt.Print(t.Summary)
+	}
+	if commits != nil {
+		if t != nil || described {
+			fmt.Println()
+		}
+		printCommitList(commits, format == "markdown")
+	}
 
+	verify, _ := cmd.Flags().GetBool("verify-issues")
+	if found := findIssues(t, commits, verify); len(found) > 0 {
+		fmt.Println()
+		printIssues(found, format == "markdown")
+	}
 	return nil
 }
+
+// describeChange gathers what an LLM writes a description from: the
+// trace, the diff (raw, or the working tree's when there is none), and its
+// findings. The diff and its findings are best effort.
+func describeChange(cmd *cobra.Command, t *trace.Trace, raw string, commits []diff.Commit) explain.Description {
+	repoDir, _ := gitRepoRoot()
+	if raw == "" && repoDir != "" {
+		var err error
+		if raw, _, err = getDiff(cmd, nil, 3); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		}
+	}
+	var ds *diff.DiffSet
+	var findings []analysis.Finding
+	if raw != "" {
+		var err error
+		if ds, err = diff.Parse(raw); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		} else {
+			findings = analyze(cmd, ds, repoDir, skipPasses(cmd, repoDir)).Findings
+		}
+	}
+	return explain.NewDescription(t, ds, commits, findings)
+}
+
+// composeDescription has the LLM configured under explain write the
+// description.
+func composeDescription(d explain.Description) (string, error) {
+	repoDir, _ := gitRepoRoot()
+	cfg, err := config.Load(repoDir)
+	if err != nil {
+		return "", err
+	}
+	client, err := explain.New(cfg.Explain)
+	if err != nil {
+		return "", err
+	}
+	return client.Describe(d)
+}
+
+// findIssues returns the issues referred to in the trace's user messages
+// and the commit messages, looked up in their trackers when verify is set.
+// Issues that can't be found, or are closed, are warned about.
+func findIssues(t *trace.Trace, commits []diff.Commit, verify bool) []issues.Issue {
+	var texts []string
+	if t != nil {
+		for _, s := range t.StepsOfType(trace.StepUserMessage) {
+			texts = append(texts, s.Detail)
+		}
+	}
+	for _, c := range commits {
+		texts = append(texts, c.Subject, c.Body)
+	}
+
+	repoDir, _ := gitRepoRoot()
+	cfg, err := config.Load(repoDir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		cfg = &config.Config{}
+	}
+	repo := ""
+	if remote := originRemote(repoDir); remote != "" {
+		if p, err := provider.Detect("", "", remote); err == nil && p.Name() == "github" {
+			repo = defaultRepo("github", remote)
+		}
+	}
+	tracker := issues.New(cfg.Issues, repo)
+	found := tracker.Find(texts...)
+	if !verify {
+		return found
+	}
+	for i := range found {
+		if err := tracker.Verify(&found[i]); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		} else if found[i].State == "closed" {
+			fmt.Fprintf(os.Stderr, "Warning: %s is closed\n", found[i].Key)
+		}
+	}
+	return found
+}
+
+// printIssues lists the issues referred to, linked where their tracker is
+// known, with their titles and closed ones marked once looked up.
+func printIssues(found []issues.Issue, markdown bool) {
+	if markdown {
+		fmt.Print("## Issues\n\n")
+	} else {
+		fmt.Print("Issues:\n")
+	}
+	for _, is := range found {
+		line := is.Key
+		if markdown && is.URL != "" {
+			line = fmt.Sprintf("[%s](%s)", is.Key, is.URL)
+		}
+		if is.Title != "" {
+			line += ": " + is.Title
+		}
+		if is.State == "closed" {
+			line += " (closed)"
+		}
+		if markdown {
+			fmt.Printf("- %s\n", line)
+		} else if is.URL != "" {
+			fmt.Printf("  %s <%s>\n", line, is.URL)
+		} else {
+			fmt.Printf("  %s\n", line)
+		}
+	}
+}
+
+// printCommitList lists commits oldest first, each with its stats.
+func printCommitList(commits []diff.Commit, markdown bool) {
+	if markdown {
+		fmt.Print("## Commits\n\n")
+	} else {
+		fmt.Print("Commits:\n")
+	}
+	for _, c := range commits {
+		files, added, deleted := c.Diff.Stats()
+		stats := fmt.Sprintf("+%d -%d, %d file(s)", added, deleted, files)
+		if markdown {
+			fmt.Printf("- `%s` %s (%s)\n", c.ShortHash(), c.Subject, stats)
+		} else {
+			fmt.Printf("  %s %s (%s)\n", c.ShortHash(), c.Subject, stats)
+		}
+	}
+}
@@ -0,0 +1,331 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/aezell/agrev/internal/trace"
+)
+
+var traceCmd = &cobra.Command{
+	Use:   "trace",
+	Short: "Inspect agent traces without opening the TUI",
+	Long: `List the agent trace sessions found for this repository, print a trace
+as a timeline, or summarize what the agent did.
+
+A session is chosen by its number in 'agrev trace ls', a session ID (or
+prefix), or a path to a trace file. Without one, the trace 'agrev review'
+would pick is used.`,
+}
+
+var traceLsCmd = &cobra.Command{
+	Use:   "ls",
+	Short: "List detected trace sessions",
+	Args:  cobra.NoArgs,
+	RunE:  runTraceLs,
+}
+
+var traceShowCmd = &cobra.Command{
+	Use:   "show [session]",
+	Short: "Print a trace as a timeline",
+	Args:  cobra.MaximumNArgs(1),
+	RunE:  runTraceShow,
+}
+
+var traceStatsCmd = &cobra.Command{
+	Use:   "stats [session]",
+	Short: "Print step, duration, and file statistics for a trace",
+	Args:  cobra.MaximumNArgs(1),
+	RunE:  runTraceStats,
+}
+
+func init() {
+	traceShowCmd.Flags().StringSlice("type", nil, "only show these step types (user, plan, reasoning, read, write, edit, bash, result)")
+	traceShowCmd.Flags().String("file", "", "only show steps touching paths containing this")
+	traceShowCmd.Flags().Bool("full", false, "print each step's full content")
+	traceStatsCmd.Flags().StringP("format", "f", "text", "output format: text, json")
+
+	traceCmd.AddCommand(traceLsCmd)
+	traceCmd.AddCommand(traceShowCmd)
+	traceCmd.AddCommand(traceStatsCmd)
+}
+
+func runTraceLs(cmd *cobra.Command, args []string) error {
+	repoDir, err := gitRepoRoot()
+	if err != nil {
+		return fmt.Errorf("not in a git repository (or git not installed): %w", err)
+	}
+	sessions := trace.Sessions(repoDir)
+	if len(sessions) == 0 {
+		fmt.Println("No agent traces found.")
+		return nil
+	}
+
+	// Mark the trace a review would load by default
+	def, _ := trace.Detect(repoDir)
+	fmt.Printf("  %3s  %-16s  %-11s  %8s  %s\n", "#", "MODIFIED", "FORMAT", "SIZE", "SESSION")
+	for i, s := range sessions {
+		mark := " "
+		if s.Path == def {
+			mark = "*"
+		}
+		fmt.Printf("%s %3d  %-16s  %-11s  %8s  %s\n",
+			mark, i+1, s.ModTime.Format("2006-01-02 15:04"), s.Format, formatSize(s.Size), s.ID())
+	}
+	return nil
+}
+
+func runTraceShow(cmd *cobra.Command, args []string) error {
+	t, err := resolveTrace(args)
+	if err != nil {
+		return err
+	}
+
+	types, _ := cmd.Flags().GetStringSlice("type")
+	file, _ := cmd.Flags().GetString("file")
+	full, _ := cmd.Flags().GetBool("full")
+	for _, name := range types {
+		if _, ok := parseStepType(name); !ok {
+			return fmt.Errorf("unknown step type %q", name)
+		}
+	}
+
+	fmt.Println(traceHeader(t))
+	fmt.Println()
+	for _, s := range t.Steps {
+		if len(types) > 0 && !matchesStepType(s.Type, types) {
+			continue
+		}
+		if file != "" && !strings.Contains(s.FilePath, file) {
+			continue
+		}
+		fmt.Println(formatStep(t, s))
+		if full {
+			if body := stepBody(s); body != "" {
+				for _, line := range strings.Split(strings.TrimRight(body, "\n"), "\n") {
+					fmt.Printf("        │ %s\n", line)
+				}
+				fmt.Println()
+			}
+		}
+	}
+	return nil
+}
+
+func runTraceStats(cmd *cobra.Command, args []string) error {
+	t, err := resolveTrace(args)
+	if err != nil {
+		return err
+	}
+	s := t.Stats()
+
+	format, _ := cmd.Flags().GetString("format")
+	switch format {
+	case "json":
+		type jsonType struct {
+			Type     string  `json:"type"`
+			Count    int     `json:"count"`
+			Duration float64 `json:"duration"`
+		}
+		type jsonFile struct {
+			Path   string `json:"path"`
+			Reads  int    `json:"reads"`
+			Writes int    `json:"writes"`
+			Edits  int    `json:"edits"`
+		}
+		out := struct {
+			Source         string     `json:"source"`
+			Session        string     `json:"session,omitempty"`
+			Steps          int        `json:"steps"`
+			Duration       float64    `json:"duration"`
+			Commands       int        `json:"commands"`
+			FailedCommands int        `json:"failed_commands"`
+			Types          []jsonType `json:"types"`
+			Files          []jsonFile `json:"files"`
+		}{
+			Source: t.Source, Session: t.SessionID, Steps: s.Steps, Duration: s.Duration.Seconds(),
+			Commands: s.Commands, FailedCommands: s.FailedCommands,
+			Types: []jsonType{}, Files: []jsonFile{},
+		}
+		for _, ts := range s.Types {
+			out.Types = append(out.Types, jsonType{ts.Type.String(), ts.Count, ts.Duration.Seconds()})
+		}
+		for _, f := range s.Files {
+			out.Files = append(out.Files, jsonFile{f.Path, f.Reads, f.Writes, f.Edits})
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(out)
+	case "text":
+	default:
+		return fmt.Errorf("unknown format %q (want text or json)", format)
+	}
+
+	fmt.Println(traceHeader(t))
+	fmt.Printf("%d command(s), %d failed; %d file(s) touched\n", s.Commands, s.FailedCommands, len(s.Files))
+
+	fmt.Printf("\n  %-10s %6s  %10s\n", "STEP TYPE", "COUNT", "TIME")
+	for _, ts := range s.Types {
+		dur := "-"
+		if ts.Duration > 0 {
+			dur = ts.Duration.Round(time.Second).String()
+		}
+		fmt.Printf("  %-10s %6d  %10s\n", ts.Type, ts.Count, dur)
+	}
+
+	if len(s.Files) > 0 {
+		fmt.Printf("\n  %5s %6s %5s  %s\n", "READS", "WRITES", "EDITS", "FILE")
+		for _, f := range s.Files {
+			fmt.Printf("  %5d %6d %5d  %s\n", f.Reads, f.Writes, f.Edits, f.Path)
+		}
+	}
+	return nil
+}
+
+// resolveTrace loads the session named by args: a number from 'trace ls',
+// a session ID prefix, or a file path. Without args it loads the trace a
+// review would detect.
+func resolveTrace(args []string) (*trace.Trace, error) {
+	if len(args) == 1 {
+		if info, err := os.Stat(args[0]); err == nil && !info.IsDir() {
+			return loadTraceFile(args[0], "")
+		}
+	}
+
+	repoDir, err := gitRepoRoot()
+	if err != nil {
+		return nil, fmt.Errorf("not in a git repository (or git not installed): %w", err)
+	}
+	if len(args) == 0 {
+		path, format := trace.Detect(repoDir)
+		if path == "" {
+			return nil, fmt.Errorf("no agent trace found; see 'agrev trace ls'")
+		}
+		return loadTraceFile(path, format)
+	}
+
+	sessions := trace.Sessions(repoDir)
+	if n, err := strconv.Atoi(args[0]); err == nil {
+		if n < 1 || n > len(sessions) {
+			return nil, fmt.Errorf("no session #%d; 'agrev trace ls' lists %d", n, len(sessions))
+		}
+		return loadTraceFile(sessions[n-1].Path, sessions[n-1].Format)
+	}
+
+	var found []trace.Session
+	for _, s := range sessions {
+		if strings.HasPrefix(s.ID(), args[0]) {
+			found = append(found, s)
+		}
+	}
+	switch len(found) {
+	case 0:
+		return nil, fmt.Errorf("no trace session or file matches %q", args[0])
+	case 1:
+		return loadTraceFile(found[0].Path, found[0].Format)
+	default:
+		return nil, fmt.Errorf("%q matches %d sessions; use more of the ID", args[0], len(found))
+	}
+}
+
+func loadTraceFile(path, format string) (*trace.Trace, error) {
+	t, err := trace.Load(path, format)
+	if err != nil {
+		return nil, fmt.Errorf("loading trace %s: %w", path, err)
+	}
+	return t, nil
+}
+
+// traceHeader describes a trace in one line.
+func traceHeader(t *trace.Trace) string {
+	parts := []string{t.Source + " trace"}
+	if t.SessionID != "" {
+		parts[0] += " " + t.SessionID
+	}
+	s := t.Stats()
+	if !t.StartTime.IsZero() {
+		when := t.StartTime.Local().Format("2006-01-02 15:04")
+		if s.Duration > 0 {
+			when += fmt.Sprintf(" (%s)", s.Duration.Round(time.Second))
+		}
+		parts = append(parts, when)
+	}
+	parts = append(parts, fmt.Sprintf("%d step(s)", s.Steps), fmt.Sprintf("%d file(s) changed", len(t.FilesChanged)))
+	return strings.Join(parts, " · ")
+}
+
+// formatStep renders a step as one timeline row: time, offset from the
+// start, type, and summary.
+func formatStep(t *trace.Trace, s trace.Step) string {
+	when, offset := "--:--:--", ""
+	if !s.Timestamp.IsZero() {
+		when = s.Timestamp.Local().Format("15:04:05")
+		if !t.StartTime.IsZero() {
+			offset = "+" + s.Timestamp.Sub(t.StartTime).Round(time.Second).String()
+		}
+	}
+
+	summary := s.Summary
+	if s.Type == trace.StepBash && s.ExitCode != 0 {
+		summary = fmt.Sprintf("%s  [exit %d]", summary, s.ExitCode)
+	}
+	return fmt.Sprintf("  %s  %-8s  %-9s  %s", when, offset, s.Type, strings.ReplaceAll(summary, "\n", " "))
+}
+
+// stepBody is the full content --full prints under a step.
+func stepBody(s trace.Step) string {
+	switch {
+	case s.Type == trace.StepFileEdit && (s.OldString != "" || s.NewString != ""):
+		var b strings.Builder
+		for _, l := range strings.Split(strings.TrimRight(s.OldString, "\n"), "\n") {
+			b.WriteString("- " + l + "\n")
+		}
+		for _, l := range strings.Split(strings.TrimRight(s.NewString, "\n"), "\n") {
+			b.WriteString("+ " + l + "\n")
+		}
+		return b.String()
+	case s.Type == trace.StepBash:
+		out := "$ " + s.Command
+		if s.Output != "" {
+			out += "\n" + s.Output
+		}
+		return out
+	case s.Detail != s.Summary:
+		return s.Detail
+	}
+	return ""
+}
+
+func parseStepType(name string) (trace.StepType, bool) {
+	for st := trace.StepPlan; st <= trace.StepUserMessage; st++ {
+		if st.String() == name {
+			return st, true
+		}
+	}
+	return 0, false
+}
+
+func matchesStepType(st trace.StepType, names []string) bool {
+	for _, name := range names {
+		if st.String() == name {
+			return true
+		}
+	}
+	return false
+}
+
+// formatSize formats a byte count for display.
+func formatSize(n int64) string {
+	switch {
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1f KB", float64(n)/(1<<10))
+	}
+	return fmt.Sprintf("%d B", n)
+}
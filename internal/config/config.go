@@ -0,0 +1,506 @@
+// Package config loads per-repository agrev settings from .agrev.yml.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/mail"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+	"github.com/aezell/agrev/internal/model"
+)
+
+// FileName is the config file looked up at the repository root.
+const FileName = ".agrev.yml"
+
+// Config holds settings read from .agrev.yml. The zero value is the default
+// configuration.
+type Config struct {
+	// Theme names the TUI theme: a built-in (dark, light, high-contrast) or
+	// one of the custom Themes below.
+	Theme string `yaml:"theme"`
+
+	// Themes defines custom themes by name.
+	Themes map[string]ThemeConfig `yaml:"themes"`
+
+	// Analysis configures the analysis passes.
+	Analysis AnalysisConfig `yaml:"analysis"`
+
+	// NoHistory turns off recording reviews in .agrev/history.jsonl.
+	NoHistory bool `yaml:"no_history"`
+
+	// NoAudit turns off logging reviewer actions in .agrev/audit.jsonl.
+	NoAudit bool `yaml:"no_audit"`
+
+	// Gate is the policy enforced by 'agrev gate'.
+	Gate GatePolicy `yaml:"gate"`
+
+	// Explain configures LLM explanations of hunks and findings. They are
+	// off unless an endpoint is set.
+	Explain ExplainConfig `yaml:"explain"`
+
+	// Serve configures 'agrev serve'.
+	Serve ServeConfig `yaml:"serve"`
+
+	// Checklist lists what reviewers confirm before finishing a review.
+	Checklist []ChecklistItem `yaml:"checklist"`
+
+	// Notify lists chat webhooks and email recipients told how each check,
+	// gate, and review turned out.
+	Notify []NotifyConfig `yaml:"notify"`
+
+	// Issues configures linking the issues a change refers to.
+	Issues IssuesConfig `yaml:"issues"`
+}
+
+// IssuesConfig says where the issues a change refers to live: Jira keys
+// such as PROJ-123, and GitHub issues such as #456.
+type IssuesConfig struct {
+	// JiraURL is the Jira site keys link to, e.g.
+	// "https://example.atlassian.net". Without it, keys are listed unlinked.
+	JiraURL string `yaml:"jira_url"`
+
+	// JiraProjects are the project keys to look for. By default any key
+	// is, except for names like UTF-8 and SHA-256.
+	JiraProjects []string `yaml:"jira_projects"`
+
+	// JiraUserEnv and JiraTokenEnv name the environment variables holding
+	// the Jira account's email and API token, for checking issues exist.
+	JiraUserEnv  string `yaml:"jira_user_env"`
+	JiraTokenEnv string `yaml:"jira_token_env"`
+
+	// GitHub is the repository ("owner/name") bare #123 references are
+	// in. It defaults to the origin remote's, when that is on GitHub.
+	GitHub string `yaml:"github"`
+}
+
+// NotifyConfig is a Slack or Discord incoming webhook to post summaries
+// to, or an email address list to send reports to.
+type NotifyConfig struct {
+	// Type is the chat service, "slack" or "discord", or "email".
+	Type string `yaml:"type"`
+
+	// URL is the webhook URL. Webhook URLs are secrets, so URLEnv is
+	// usually the better choice.
+	URL string `yaml:"url"`
+
+	// URLEnv names the environment variable holding the webhook URL. A
+	// webhook whose variable is unset is skipped, so local runs stay quiet.
+	URLEnv string `yaml:"url_env"`
+
+	// On lists the commands to post about: check, gate, pre-receive, and
+	// review (the default is all of them).
+	On []string `yaml:"on"`
+
+	// MinRisk posts only when the highest finding risk is at least this.
+	// Failed gates and reviews with rejections are always posted.
+	MinRisk string `yaml:"min_risk"`
+
+	// ReportURL is linked from each message, with $VARIABLES expanded, e.g.
+	// "$GITHUB_SERVER_URL/$GITHUB_REPOSITORY/actions/runs/$GITHUB_RUN_ID".
+	ReportURL string `yaml:"report_url"`
+
+	// To and From are an email's recipients and sender.
+	To   []string `yaml:"to"`
+	From string   `yaml:"from"`
+
+	// SMTP is the mail server emails are sent through.
+	SMTP SMTPConfig `yaml:"smtp"`
+
+	// Format is the report an email carries after a check or gate: "html"
+	// (the default, with the Markdown report as its plain-text part) or
+	// "markdown".
+	Format string `yaml:"format"`
+}
+
+// SMTPConfig is a mail server to send email through.
+type SMTPConfig struct {
+	// Host and Port are the server's address. Port defaults to 587, where
+	// the connection is upgraded with STARTTLS if the server offers it;
+	// port 465 is TLS from the start.
+	Host string `yaml:"host"`
+	Port int    `yaml:"port"`
+
+	// Username and PasswordEnv log in to the server, if it needs it.
+	// PasswordEnv names the environment variable holding the password. An
+	// email whose variable is unset is skipped, as webhooks are.
+	Username    string `yaml:"username"`
+	PasswordEnv string `yaml:"password_env"`
+}
+
+// LinterConfig is an external linter, such as golangci-lint, eslint, or
+// ruff, whose reports on the changed lines become findings of the lint
+// pass.
+type LinterConfig struct {
+	// Name identifies the linter; its findings' rules are "<name>/<rule>",
+	// e.g. "eslint/no-unused-vars".
+	Name string `yaml:"name"`
+
+	// Command runs the linter, e.g. [ruff, check, --output-format, json].
+	// The changed files it lints are added to the end.
+	Command []string `yaml:"command"`
+
+	// Format is how the linter reports: checkstyle, eslint (eslint's
+	// JSON), ruff (ruff's JSON), or golangci-lint (golangci-lint's JSON).
+	Format string `yaml:"format"`
+
+	// Files are globs of the changed files to lint, e.g. ["*.py"]; a glob
+	// without a slash matches file names at any depth. Default: every
+	// changed file.
+	Files []string `yaml:"files"`
+
+	// WholeRepo runs Command as it is, without the changed files, for
+	// linters that take packages rather than files. Only reports on the
+	// changed files are kept.
+	WholeRepo bool `yaml:"whole_repo"`
+
+	// AllLines keeps reports anywhere in the changed files, not only on
+	// lines the change adds.
+	AllLines bool `yaml:"all_lines"`
+}
+
+// LinterFormats are the report formats the lint pass reads.
+var LinterFormats = []string{"checkstyle", "eslint", "ruff", "golangci-lint"}
+
+func (l LinterConfig) validate() error {
+	if l.Name == "" {
+		return errors.New("name is required")
+	}
+	if len(l.Command) == 0 {
+		return errors.New("command is required")
+	}
+	if !slices.Contains(LinterFormats, l.Format) {
+		return fmt.Errorf("unknown format %q (want %s)", l.Format, strings.Join(LinterFormats, ", "))
+	}
+	for _, g := range l.Files {
+		if _, err := path.Match(g, ""); err != nil {
+			return fmt.Errorf("files: bad glob %q", g)
+		}
+	}
+	return nil
+}
+
+// NotifyCommands are the commands a webhook can be told about.
+var NotifyCommands = []string{"check", "gate", "pre-receive", "review"}
+
+// EmailFormats are the report formats an email can carry.
+var EmailFormats = []string{"html", "markdown"}
+
+func (n NotifyConfig) validate() error {
+	switch n.Type {
+	case "slack", "discord":
+		if (n.URL == "") == (n.URLEnv == "") {
+			return fmt.Errorf("set one of url and url_env")
+		}
+	case "email":
+		if len(n.To) == 0 || n.From == "" || n.SMTP.Host == "" {
+			return fmt.Errorf("to, from, and smtp.host are required")
+		}
+		for _, addr := range append([]string{n.From}, n.To...) {
+			if _, err := mail.ParseAddress(addr); err != nil {
+				return fmt.Errorf("bad address %q", addr)
+			}
+		}
+		if n.SMTP.Port < 0 || n.SMTP.Port > 65535 {
+			return fmt.Errorf("bad smtp.port %d", n.SMTP.Port)
+		}
+		if (n.SMTP.Username == "") != (n.SMTP.PasswordEnv == "") {
+			return fmt.Errorf("set both or neither of smtp.username and smtp.password_env")
+		}
+		if n.Format != "" && !slices.Contains(EmailFormats, n.Format) {
+			return fmt.Errorf("unknown format %q (want %s)", n.Format, strings.Join(EmailFormats, ", "))
+		}
+	case "":
+		return fmt.Errorf("type is required (slack, discord, or email)")
+	default:
+		return fmt.Errorf("unknown type %q (want slack, discord, or email)", n.Type)
+	}
+	for _, c := range n.On {
+		if !slices.Contains(NotifyCommands, c) {
+			return fmt.Errorf("unknown command %q in on (want %s)", c, strings.Join(NotifyCommands, ", "))
+		}
+	}
+	if _, ok := model.ParseRiskLevel(n.MinRisk); n.MinRisk != "" && !ok {
+		return fmt.Errorf("unknown min_risk %q", n.MinRisk)
+	}
+	return nil
+}
+
+// ChecklistItem is one entry of the review checklist. Without Paths it is
+// on every review; with them, only on reviews changing a matching file.
+type ChecklistItem struct {
+	// Item is what the reviewer confirms, e.g. "confirm the backfill plan".
+	Item string `yaml:"item"`
+
+	// Paths are globs, as in the gate's forbidden_paths.
+	Paths []string `yaml:"paths"`
+}
+
+// ServeConfig configures the HTTP API server.
+type ServeConfig struct {
+	// Tokens are the bearer tokens the API accepts. With none (and neither
+	// AGREV_API_TOKEN nor AGREV_API_READ_TOKEN set) the API is open.
+	Tokens []TokenConfig `yaml:"tokens"`
+}
+
+// TokenConfig is one API token. Prefer TokenEnv so the secret stays out of
+// the config file.
+type TokenConfig struct {
+	// Name identifies the token in error messages, e.g. "ci".
+	Name string `yaml:"name"`
+
+	// Token is the secret itself.
+	Token string `yaml:"token"`
+
+	// TokenEnv names an environment variable holding the secret.
+	TokenEnv string `yaml:"token_env"`
+
+	// Scope is "read" (the default: analyze, parse, summary) or "write"
+	// (also review sessions).
+	Scope string `yaml:"scope"`
+}
+
+// AnalysisConfig configures the analysis passes.
+type AnalysisConfig struct {
+	// Skip names passes to never run, like the --skip flag.
+	Skip []string `yaml:"skip"`
+
+	// Overrides change the risk or severity of findings, e.g. to treat
+	// TODOs as info or new dependencies as high. A finding takes the first
+	// override it matches.
+	Overrides []Override `yaml:"overrides"`
+
+	// Semgrep configures the semgrep pass.
+	Semgrep SemgrepConfig `yaml:"semgrep"`
+
+	// Linters are the external linters the lint pass runs on the changed
+	// files.
+	Linters []LinterConfig `yaml:"linters"`
+
+	// IaC configures the iac pass.
+	IaC IaCConfig `yaml:"iac"`
+}
+
+// IaCConfig configures the iac pass, which runs an infrastructure-as-code
+// scanner on changed Terraform, Kubernetes, and Dockerfiles.
+type IaCConfig struct {
+	// Scanner is the scanner to run: trivy, checkov, or tfsec. Default:
+	// the first of them installed.
+	Scanner string `yaml:"scanner"`
+}
+
+// IaCScanners are the scanners the iac pass can run, in the order it
+// looks for them.
+var IaCScanners = []string{"trivy", "checkov", "tfsec"}
+
+// SemgrepConfig configures the semgrep pass, which runs the repository's
+// semgrep rules on the changed files.
+type SemgrepConfig struct {
+	// Config lists the rules, each given to semgrep as a --config: a file
+	// or directory in the repository, or a registry ruleset such as
+	// "p/golang". Default: .semgrep.yml, .semgrep.yaml, or .semgrep/,
+	// whichever the repository has.
+	Config []string `yaml:"config"`
+
+	// AllLines reports matches anywhere in the changed files, not only
+	// those on lines the change adds.
+	AllLines bool `yaml:"all_lines"`
+}
+
+// Override remaps the risk and/or severity of the findings it matches.
+type Override struct {
+	// Rule is the ID of the rule whose findings match, e.g.
+	// "AGV-ANT-003". Empty matches every rule.
+	Rule string `yaml:"rule"`
+
+	// Pass is the pass whose findings match, e.g. "deps". Empty matches
+	// every pass.
+	Pass string `yaml:"pass"`
+
+	// Match is a regular expression on the finding's message, e.g.
+	// "TODO|FIXME". Empty matches every message.
+	Match string `yaml:"match"`
+
+	// Risk is the risk to give matching findings: info, low, medium,
+	// high, or critical. Empty keeps their risk.
+	Risk string `yaml:"risk"`
+
+	// Severity is the severity to give them: info, warning, or error.
+	// Empty keeps their severity.
+	Severity string `yaml:"severity"`
+
+	match *regexp.Regexp
+}
+
+// Matches reports whether the override applies to a finding of the rule
+// and pass with the message.
+func (o *Override) Matches(rule, pass, message string) bool {
+	if o.Rule != "" && !strings.EqualFold(o.Rule, rule) {
+		return false
+	}
+	if o.Pass != "" && o.Pass != pass {
+		return false
+	}
+	if o.Match == "" {
+		return true
+	}
+	if o.match == nil {
+		re, err := regexp.Compile(o.Match)
+		if err != nil {
+			return false
+		}
+		o.match = re
+	}
+	return o.match.MatchString(message)
+}
+
+func (o *Override) validate() error {
+	if o.Risk == "" && o.Severity == "" {
+		return errors.New("sets neither risk nor severity")
+	}
+	if _, ok := model.ParseRiskLevel(o.Risk); o.Risk != "" && !ok {
+		return fmt.Errorf("unknown risk %q", o.Risk)
+	}
+	if _, ok := model.ParseSeverity(o.Severity); o.Severity != "" && !ok {
+		return fmt.Errorf("unknown severity %q", o.Severity)
+	}
+	if o.Match != "" {
+		re, err := regexp.Compile(o.Match)
+		if err != nil {
+			return fmt.Errorf("match: %w", err)
+		}
+		o.match = re
+	}
+	return nil
+}
+
+// ExplainConfig points 'agrev explain' and the TUI's explain key at an LLM.
+type ExplainConfig struct {
+	// Endpoint is the API URL, e.g.
+	// "https://api.openai.com/v1/chat/completions". Empty disables explain.
+	Endpoint string `yaml:"endpoint"`
+
+	// API is the request format: "openai" (chat completions, the default,
+	// which local servers like Ollama also speak) or "anthropic" (messages).
+	API string `yaml:"api"`
+
+	// Model is the model name sent with each request.
+	Model string `yaml:"model"`
+
+	// APIKeyEnv names the environment variable holding the API key, so the
+	// key itself never lives in the config file.
+	APIKeyEnv string `yaml:"api_key_env"`
+
+	// MaxTokens caps the length of an explanation (default 1024).
+	MaxTokens int `yaml:"max_tokens"`
+}
+
+// ThemeConfig describes a custom theme as overrides on top of a built-in one.
+type ThemeConfig struct {
+	// Base is the built-in theme to start from (default "dark").
+	Base string `yaml:"base"`
+
+	// Chroma is the syntax highlighting style name, e.g. "github" or "monokai".
+	Chroma string `yaml:"chroma"`
+
+	// Colors maps palette names (red, green, fg, bg, ...) to hex colors.
+	Colors map[string]string `yaml:"colors"`
+}
+
+// GatePolicy describes what a change must satisfy to pass 'agrev gate'.
+// Risk levels are named: info, low, medium, high, critical.
+type GatePolicy struct {
+	// MaxRisk is the highest finding risk allowed from any pass not listed
+	// in Passes. Empty means findings alone never fail the gate.
+	MaxRisk string `yaml:"max_risk"`
+
+	// Passes sets the highest allowed risk per analysis pass, e.g.
+	// {"security": "medium"}.
+	Passes map[string]string `yaml:"passes"`
+
+	// ForbiddenPaths are globs ("**" matches across directories) for files
+	// that must not be changed.
+	ForbiddenPaths []string `yaml:"forbidden_paths"`
+
+	// RequireTests lists rules requiring test changes alongside source changes.
+	RequireTests []TestRule `yaml:"require_tests"`
+
+	// RequireOwnerApproval requires every changed file that CODEOWNERS
+	// assigns owners to be approved in the saved review on its own, not
+	// along with other files as part of a change group or as whitespace-only.
+	RequireOwnerApproval bool `yaml:"require_owner_approval"`
+}
+
+// TestRule requires that a change touching any file matching Paths also
+// touches a file matching Tests.
+type TestRule struct {
+	Paths []string `yaml:"paths"`
+	Tests []string `yaml:"tests"`
+}
+
+// IsZero reports whether the policy has no rules.
+func (p GatePolicy) IsZero() bool {
+	return p.MaxRisk == "" && len(p.Passes) == 0 && len(p.ForbiddenPaths) == 0 && len(p.RequireTests) == 0 && !p.RequireOwnerApproval
+}
+
+// Load reads the config file from repoDir. A missing file is not an error and
+// yields the default configuration.
+func Load(repoDir string) (*Config, error) {
+	if repoDir == "" {
+		return &Config{}, nil
+	}
+	return LoadFile(filepath.Join(repoDir, FileName))
+}
+
+// LoadFile reads a config file from an explicit path. A missing file yields
+// the default configuration.
+func LoadFile(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return &Config{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading config: %w", err)
+	}
+	return Parse(data, path)
+}
+
+// Parse decodes and checks a config file's contents. path names the file
+// in errors.
+func Parse(data []byte, path string) (*Config, error) {
+	var cfg Config
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	for i := range cfg.Analysis.Overrides {
+		if err := cfg.Analysis.Overrides[i].validate(); err != nil {
+			return nil, fmt.Errorf("%s: analysis override %d: %w", path, i+1, err)
+		}
+	}
+	if sc := cfg.Analysis.IaC.Scanner; sc != "" && !slices.Contains(IaCScanners, sc) {
+		return nil, fmt.Errorf("%s: unknown iac scanner %q (want %s)", path, sc, strings.Join(IaCScanners, ", "))
+	}
+	for i, l := range cfg.Analysis.Linters {
+		if err := l.validate(); err != nil {
+			return nil, fmt.Errorf("%s: linter %d: %w", path, i+1, err)
+		}
+	}
+	for i, c := range cfg.Checklist {
+		if strings.TrimSpace(c.Item) == "" {
+			return nil, fmt.Errorf("%s: checklist item %d is empty", path, i+1)
+		}
+	}
+	for i, n := range cfg.Notify {
+		if err := n.validate(); err != nil {
+			return nil, fmt.Errorf("%s: notify %d: %w", path, i+1, err)
+		}
+	}
+	return &cfg, nil
+}
@@ -0,0 +1,311 @@
+package config
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadMissing(t *testing.T) {
+	cfg, err := Load(t.TempDir())
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if cfg.Theme != "" || len(cfg.Themes) != 0 {
+		t.Errorf("expected default config, got %+v", cfg)
+	}
+}
+
+func TestLoadThemes(t *testing.T) {
+	dir := t.TempDir()
+	data := `theme: solar
+themes:
+  solar:
+    base: light
+    chroma: solarized-light
+    colors:
+      red: "#dc322f"
+      bg: "#fdf6e3"
+`
+	if err := os.WriteFile(filepath.Join(dir, FileName), []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := Load(dir)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if cfg.Theme != "solar" {
+		t.Errorf("expected theme solar, got %q", cfg.Theme)
+	}
+	solar, ok := cfg.Themes["solar"]
+	if !ok {
+		t.Fatal("expected custom theme solar")
+	}
+	if solar.Base != "light" || solar.Chroma != "solarized-light" {
+		t.Errorf("unexpected theme %+v", solar)
+	}
+	if solar.Colors["red"] != "#dc322f" {
+		t.Errorf("expected red override, got %q", solar.Colors["red"])
+	}
+}
+
+func TestLoadInvalid(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, FileName), []byte("theme: [unclosed"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(dir); err == nil {
+		t.Error("expected error for invalid YAML")
+	}
+}
+
+func TestLoadGatePolicy(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "policy.yml")
+	data := `gate:
+  max_risk: high
+  passes:
+    security: medium
+  forbidden_paths:
+    - "db/migrations/**"
+  require_tests:
+    - paths: ["internal/**/*.go"]
+      tests: ["**/*_test.go"]
+`
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := LoadFile(path)
+	if err != nil {
+		t.Fatalf("LoadFile failed: %v", err)
+	}
+	g := cfg.Gate
+	if g.IsZero() {
+		t.Fatal("expected a gate policy")
+	}
+	if g.MaxRisk != "high" || g.Passes["security"] != "medium" {
+		t.Errorf("unexpected risk limits: %+v", g)
+	}
+	if len(g.ForbiddenPaths) != 1 || len(g.RequireTests) != 1 || g.RequireTests[0].Tests[0] != "**/*_test.go" {
+		t.Errorf("unexpected path rules: %+v", g)
+	}
+}
+
+func TestInspectAndScaffold(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"go.mod":                   "module example.com/x\n",
+		"main.go":                  "package main\n",
+		"main_test.go":             "package main\n",
+		"web/app.ts":               "export {}\n",
+		".github/workflows/ci.yml": "on: push\n",
+		"CLAUDE.md":                "# notes\n",
+	}
+	for name, data := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, args := range [][]string{{"init", "-q"}, {"add", "-A"}} {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+
+	p, err := Inspect(dir)
+	if err != nil {
+		t.Fatalf("Inspect failed: %v", err)
+	}
+	if langs := p.LanguageNames(); len(langs) != 2 || langs[0] != "Go" {
+		t.Errorf("expected Go then TypeScript, got %v", langs)
+	}
+	if len(p.PackageManagers) != 1 || len(p.CI) != 1 || len(p.Agents) != 1 || p.HasSQL {
+		t.Errorf("unexpected profile: %+v", p)
+	}
+
+	out := Scaffold(p, ScaffoldOptions{Theme: "light", Gate: true, MaxRisk: "medium", RequireTests: true})
+	path := filepath.Join(dir, FileName)
+	if err := os.WriteFile(path, []byte(out), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := Load(dir)
+	if err != nil {
+		t.Fatalf("scaffolded config doesn't load: %v\n%s", err, out)
+	}
+	if cfg.Theme != "light" || cfg.Gate.MaxRisk != "medium" || cfg.Gate.Passes["security"] != "low" {
+		t.Errorf("unexpected config: %+v", cfg)
+	}
+	if len(cfg.Analysis.Skip) != 1 || cfg.Analysis.Skip[0] != "schema" {
+		t.Errorf("expected schema pass skipped, got %v", cfg.Analysis.Skip)
+	}
+	if len(cfg.Gate.ForbiddenPaths) != 1 || len(cfg.Gate.RequireTests) != 2 {
+		t.Errorf("unexpected gate rules: %+v", cfg.Gate)
+	}
+}
+
+func TestLoadOverrides(t *testing.T) {
+	dir := t.TempDir()
+	data := `analysis:
+  overrides:
+    - pass: anti_patterns
+      match: "^Agent left (TODO|FIXME)"
+      risk: info
+`
+	if err := os.WriteFile(filepath.Join(dir, FileName), []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := Load(dir)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	o := &cfg.Analysis.Overrides[0]
+	if !o.Matches("AGV-ANT-003", "anti_patterns", "Agent left TODO marker: # TODO") || o.Matches("", "deps", "Agent left TODO marker") ||
+		o.Matches("AGV-ANT-002", "anti_patterns", "Commented-out code") {
+		t.Errorf("unexpected matching for %+v", o)
+	}
+
+	for _, bad := range []string{
+		"analysis: {overrides: [{pass: deps}]}",
+		"analysis: {overrides: [{risk: severe}]}",
+		"analysis: {overrides: [{severity: fatal}]}",
+		"analysis: {overrides: [{match: '(', risk: low}]}",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, FileName), []byte(bad), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := Load(dir); err == nil {
+			t.Errorf("expected %q to be refused", bad)
+		}
+	}
+}
+
+func TestLoadChecklist(t *testing.T) {
+	dir := t.TempDir()
+	data := `checklist:
+  - item: ran it locally
+  - item: confirm the backfill plan
+    paths: ["migrations/**"]
+`
+	if err := os.WriteFile(filepath.Join(dir, FileName), []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := Load(dir)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if len(cfg.Checklist) != 2 || cfg.Checklist[1].Item != "confirm the backfill plan" || cfg.Checklist[1].Paths[0] != "migrations/**" {
+		t.Errorf("unexpected checklist %+v", cfg.Checklist)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, FileName), []byte("checklist: [{paths: [a]}]"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(dir); err == nil {
+		t.Error("expected an empty checklist item to be refused")
+	}
+}
+
+func TestLoadNotify(t *testing.T) {
+	dir := t.TempDir()
+	data := `notify:
+  - type: slack
+    url_env: AGREV_SLACK_WEBHOOK
+    on: [check, gate]
+    min_risk: high
+  - type: email
+    to: [reviews@example.com]
+    from: "agrev <ci@example.com>"
+    smtp: {host: smtp.example.com, username: ci, password_env: AGREV_SMTP_PASSWORD}
+`
+	if err := os.WriteFile(filepath.Join(dir, FileName), []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := Load(dir)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if len(cfg.Notify) != 2 || cfg.Notify[0].URLEnv != "AGREV_SLACK_WEBHOOK" || len(cfg.Notify[0].On) != 2 {
+		t.Errorf("unexpected notify %+v", cfg.Notify)
+	}
+	if email := cfg.Notify[1]; email.SMTP.Host != "smtp.example.com" || email.SMTP.PasswordEnv != "AGREV_SMTP_PASSWORD" || len(email.To) != 1 {
+		t.Errorf("unexpected email %+v", email)
+	}
+
+	for _, bad := range []string{
+		"notify: [{url: https://example.com}]",
+		"notify: [{type: teams, url: https://example.com}]",
+		"notify: [{type: slack}]",
+		"notify: [{type: discord, url: https://example.com, on: [commit]}]",
+		"notify: [{type: discord, url: https://example.com, min_risk: severe}]",
+		"notify: [{type: email, to: [a@example.com], from: b@example.com}]",
+		"notify: [{type: email, to: [not an address], from: b@example.com, smtp: {host: smtp.example.com}}]",
+		"notify: [{type: email, to: [a@example.com], from: b@example.com, smtp: {host: smtp.example.com, username: ci}}]",
+		"notify: [{type: email, to: [a@example.com], from: b@example.com, smtp: {host: smtp.example.com}, format: pdf}]",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, FileName), []byte(bad), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := Load(dir); err == nil {
+			t.Errorf("expected %q to be refused", bad)
+		}
+	}
+}
+
+func TestLoadLinters(t *testing.T) {
+	dir := t.TempDir()
+	data := `analysis:
+  linters:
+    - name: ruff
+      command: [ruff, check, --output-format, json]
+      format: ruff
+      files: ["*.py"]
+`
+	if err := os.WriteFile(filepath.Join(dir, FileName), []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := Load(dir)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if l := cfg.Analysis.Linters; len(l) != 1 || l[0].Name != "ruff" || len(l[0].Command) != 4 || l[0].Files[0] != "*.py" {
+		t.Errorf("unexpected linters %+v", l)
+	}
+
+	for _, bad := range []string{
+		"analysis: {linters: [{command: [ruff], format: ruff}]}",
+		"analysis: {linters: [{name: ruff, format: ruff}]}",
+		"analysis: {linters: [{name: ruff, command: [ruff], format: junit}]}",
+		"analysis: {linters: [{name: ruff, command: [ruff], format: ruff, files: ['[']}]}",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, FileName), []byte(bad), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := Load(dir); err == nil {
+			t.Errorf("expected %q to be refused", bad)
+		}
+	}
+}
+
+func TestLoadIaCScanner(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, FileName), []byte("analysis: {iac: {scanner: checkov}}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if cfg, err := Load(dir); err != nil || cfg.Analysis.IaC.Scanner != "checkov" {
+		t.Errorf("unexpected config %+v, %v", cfg, err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, FileName), []byte("analysis: {iac: {scanner: kics}}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(dir); err == nil {
+		t.Error("expected an unknown scanner to be refused")
+	}
+}
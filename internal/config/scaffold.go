@@ -0,0 +1,264 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Profile describes what Inspect found in a repository.
+type Profile struct {
+	// Languages maps language names to how many tracked files use them.
+	Languages map[string]int
+
+	// PackageManagers lists detected dependency manifests, e.g. "go modules".
+	PackageManagers []string
+
+	// CI lists detected CI systems, e.g. "GitHub Actions".
+	CI []string
+
+	// Agents lists detected coding agent tooling, e.g. "Claude Code".
+	Agents []string
+
+	// HasSQL is set when the repository has SQL files or migrations.
+	HasSQL bool
+}
+
+// language describes how to recognize a language and its test files.
+type language struct {
+	name       string
+	extensions []string
+	sources    []string // globs for source files
+	tests      []string // globs for test files
+}
+
+var languages = []language{
+	{"Go", []string{".go"}, []string{"**/*.go"}, []string{"**/*_test.go"}},
+	{"Python", []string{".py"}, []string{"**/*.py"}, []string{"**/test_*.py", "**/*_test.py", "tests/**"}},
+	{"TypeScript", []string{".ts", ".tsx"}, []string{"**/*.ts", "**/*.tsx"}, []string{"**/*.test.ts", "**/*.test.tsx", "**/*.spec.ts", "**/__tests__/**"}},
+	{"JavaScript", []string{".js", ".jsx", ".mjs"}, []string{"**/*.js", "**/*.jsx", "**/*.mjs"}, []string{"**/*.test.js", "**/*.spec.js", "**/__tests__/**"}},
+	{"Rust", []string{".rs"}, []string{"src/**/*.rs"}, []string{"tests/**", "src/**/tests.rs"}},
+	{"Ruby", []string{".rb"}, []string{"**/*.rb"}, []string{"spec/**", "test/**"}},
+	{"Java", []string{".java"}, []string{"src/main/**"}, []string{"src/test/**"}},
+}
+
+// Files or directories that signal tooling, and what they mean.
+var (
+	packageMarkers = [][2]string{
+		{"go.mod", "go modules"},
+		{"package.json", "npm"},
+		{"Cargo.toml", "cargo"},
+		{"pyproject.toml", "pip"},
+		{"requirements.txt", "pip"},
+		{"Gemfile", "bundler"},
+		{"pom.xml", "maven"},
+		{"build.gradle", "gradle"},
+		{"build.gradle.kts", "gradle"},
+	}
+	ciMarkers = [][2]string{
+		{".github/workflows", "GitHub Actions"},
+		{".gitlab-ci.yml", "GitLab CI"},
+		{".circleci", "CircleCI"},
+		{"Jenkinsfile", "Jenkins"},
+		{"bitbucket-pipelines.yml", "Bitbucket Pipelines"},
+	}
+	agentMarkers = [][2]string{
+		{"CLAUDE.md", "Claude Code"},
+		{".claude", "Claude Code"},
+		{".aider.chat.history.md", "Aider"},
+		{".aider.conf.yml", "Aider"},
+		{".cursorrules", "Cursor"},
+		{".cursor", "Cursor"},
+		{"AGENTS.md", "Codex"},
+		{".agent-trace.jsonl", "generic agent trace"},
+	}
+)
+
+// Inspect looks at a repository's tracked files and top-level markers to
+// guess its languages, package managers, CI, and agent tooling.
+func Inspect(repoDir string) (*Profile, error) {
+	out, err := exec.Command("git", "-C", repoDir, "ls-files").Output()
+	if err != nil {
+		return nil, fmt.Errorf("listing files: %w", err)
+	}
+
+	p := &Profile{Languages: make(map[string]int)}
+	for _, name := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		ext := strings.ToLower(filepath.Ext(name))
+		for _, lang := range languages {
+			for _, e := range lang.extensions {
+				if ext == e {
+					p.Languages[lang.name]++
+				}
+			}
+		}
+		if ext == ".sql" || strings.Contains(name, "migrations/") {
+			p.HasSQL = true
+		}
+	}
+
+	exists := func(rel string) bool {
+		_, err := os.Stat(filepath.Join(repoDir, rel))
+		return err == nil
+	}
+	detect := func(markers [][2]string) []string {
+		var found []string
+		for _, m := range markers {
+			if exists(m[0]) && !contains(found, m[1]) {
+				found = append(found, m[1])
+			}
+		}
+		return found
+	}
+	p.PackageManagers = detect(packageMarkers)
+	p.CI = detect(ciMarkers)
+	p.Agents = detect(agentMarkers)
+	return p, nil
+}
+
+// LanguageNames returns the detected languages, most files first.
+func (p *Profile) LanguageNames() []string {
+	var names []string
+	for name := range p.Languages {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if p.Languages[names[i]] != p.Languages[names[j]] {
+			return p.Languages[names[i]] > p.Languages[names[j]]
+		}
+		return names[i] < names[j]
+	})
+	return names
+}
+
+// ScaffoldOptions are the answers that shape a generated config.
+type ScaffoldOptions struct {
+	Theme        string // TUI theme; empty leaves the default
+	Gate         bool   // include a gate policy
+	MaxRisk      string // gate max_risk
+	RequireTests bool   // require test changes for the detected languages
+}
+
+// Scaffold renders a commented .agrev.yml tailored to the profile.
+func Scaffold(p *Profile, opts ScaffoldOptions) string {
+	var b strings.Builder
+	b.WriteString("# agrev configuration, generated by 'agrev init'.\n")
+	if langs := p.LanguageNames(); len(langs) > 0 {
+		fmt.Fprintf(&b, "# Detected: %s", strings.Join(langs, ", "))
+		if len(p.PackageManagers) > 0 {
+			fmt.Fprintf(&b, "; %s", strings.Join(p.PackageManagers, ", "))
+		}
+		if len(p.CI) > 0 {
+			fmt.Fprintf(&b, "; CI: %s", strings.Join(p.CI, ", "))
+		}
+		if len(p.Agents) > 0 {
+			fmt.Fprintf(&b, "; agents: %s", strings.Join(p.Agents, ", "))
+		}
+		b.WriteString("\n")
+	}
+	b.WriteString("\n")
+
+	if opts.Theme != "" {
+		b.WriteString("# TUI theme: dark, light, high-contrast, or a custom theme\n")
+		fmt.Fprintf(&b, "theme: %s\n\n", opts.Theme)
+	}
+
+	// Passes with nothing to look at only add noise
+	var skip []string
+	if len(p.PackageManagers) == 0 {
+		skip = append(skip, "deps")
+	}
+	if !p.HasSQL {
+		skip = append(skip, "schema")
+	}
+	b.WriteString("analysis:\n")
+	b.WriteString("  # Passes: security, deps, deleted, schema, anti_patterns, blast_radius, osv, semgrep, lint, gitleaks, iac, coverage\n")
+	if len(skip) > 0 {
+		fmt.Fprintf(&b, "  skip: [%s]\n", strings.Join(skip, ", "))
+	} else {
+		b.WriteString("  skip: []\n")
+	}
+
+	if !opts.Gate {
+		return b.String()
+	}
+
+	maxRisk := opts.MaxRisk
+	if maxRisk == "" {
+		maxRisk = "high"
+	}
+	b.WriteString("\n# Policy enforced by 'agrev gate' in CI\n")
+	b.WriteString("gate:\n")
+	fmt.Fprintf(&b, "  max_risk: %s\n", maxRisk)
+	b.WriteString("  passes:\n")
+	fmt.Fprintf(&b, "    security: %s\n", lowerRisk(maxRisk))
+
+	var forbidden []string
+	if contains(p.CI, "GitHub Actions") {
+		forbidden = append(forbidden, ".github/workflows/*")
+	}
+	if contains(p.CI, "GitLab CI") {
+		forbidden = append(forbidden, ".gitlab-ci.yml")
+	}
+	if contains(p.CI, "Bitbucket Pipelines") {
+		forbidden = append(forbidden, "bitbucket-pipelines.yml")
+	}
+	if len(forbidden) > 0 {
+		b.WriteString("  # Agents shouldn't change CI; drop entries you do want reviewed normally\n")
+		b.WriteString("  forbidden_paths:\n")
+		for _, f := range forbidden {
+			fmt.Fprintf(&b, "    - %q\n", f)
+		}
+	}
+
+	if opts.RequireTests {
+		var rules []language
+		for _, name := range p.LanguageNames() {
+			for _, lang := range languages {
+				if lang.name == name {
+					rules = append(rules, lang)
+				}
+			}
+		}
+		if len(rules) > 0 {
+			b.WriteString("  require_tests:\n")
+			for _, lang := range rules {
+				fmt.Fprintf(&b, "    - paths: [%s]  # %s\n", quoteList(lang.sources), lang.name)
+				fmt.Fprintf(&b, "      tests: [%s]\n", quoteList(lang.tests))
+			}
+		}
+	}
+	return b.String()
+}
+
+// lowerRisk returns the risk level one below name, keeping security
+// stricter than everything else.
+func lowerRisk(name string) string {
+	levels := []string{"info", "low", "medium", "high", "critical"}
+	for i, l := range levels {
+		if l == name && i > 0 {
+			return levels[i-1]
+		}
+	}
+	return name
+}
+
+func quoteList(items []string) string {
+	quoted := make([]string, len(items))
+	for i, s := range items {
+		quoted[i] = fmt.Sprintf("%q", s)
+	}
+	return strings.Join(quoted, ", ")
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
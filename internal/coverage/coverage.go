@@ -0,0 +1,182 @@
+// Package coverage reads test coverage reports: Go cover profiles, LCOV
+// tracefiles, and Cobertura XML, as written by go test -coverprofile,
+// most JavaScript tools, and coverage.py and most JVM tools.
+package coverage
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Profile is the line coverage a report records, by file.
+type Profile struct {
+	// Format is the report's format: "go", "lcov", or "cobertura".
+	Format string
+
+	// files maps each file, as the report names it, to whether each of its
+	// instrumented lines ran.
+	files map[string]map[int]bool
+}
+
+// Load reads the coverage report at path, telling its format from its
+// content.
+func Load(path string) (*Profile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	p, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return p, nil
+}
+
+// Parse reads a coverage report in any of the formats.
+func Parse(data []byte) (*Profile, error) {
+	trimmed := bytes.TrimSpace(data)
+	switch {
+	case bytes.HasPrefix(trimmed, []byte("mode:")):
+		return parseGo(trimmed)
+	case bytes.HasPrefix(trimmed, []byte("<")):
+		return parseCobertura(trimmed)
+	case bytes.HasPrefix(trimmed, []byte("TN:")) || bytes.HasPrefix(trimmed, []byte("SF:")):
+		return parseLCOV(trimmed)
+	}
+	return nil, fmt.Errorf("not a Go cover profile, LCOV tracefile, or Cobertura report")
+}
+
+// Lines returns whether each instrumented line of the file ran, given the
+// file's path in the repository. Reports name files by absolute path or,
+// in Go's case, by import path, so a file the report names with name as a
+// suffix matches. ok is false if the report doesn't cover the file.
+func (p *Profile) Lines(name string) (lines map[int]bool, ok bool) {
+	if lines, ok := p.files[name]; ok {
+		return lines, true
+	}
+	// The shortest of the matches, so the result doesn't depend on map order
+	match := ""
+	for f := range p.files {
+		if strings.HasSuffix(f, "/"+name) && (match == "" || len(f) < len(match)) {
+			match = f
+		}
+	}
+	if match == "" {
+		return nil, false
+	}
+	return p.files[match], true
+}
+
+// record notes that a line of file ran, or didn't. A line any report entry
+// says ran is covered: Go's blocks share the lines where they meet.
+func (p *Profile) record(file string, line int, covered bool) {
+	if p.files == nil {
+		p.files = make(map[string]map[int]bool)
+	}
+	file = filepath.ToSlash(file)
+	if p.files[file] == nil {
+		p.files[file] = make(map[int]bool)
+	}
+	p.files[file][line] = p.files[file][line] || covered
+}
+
+// parseGo reads a cover profile: a mode line, then a line per block,
+// "file.go:startLine.startCol,endLine.endCol statements count".
+func parseGo(data []byte) (*Profile, error) {
+	p := &Profile{Format: "go"}
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	sc.Buffer(nil, 1<<20)
+	sc.Scan() // the mode line
+	for n := 2; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" {
+			continue
+		}
+		file, block, ok := strings.Cut(line, ":")
+		fields := strings.Fields(block)
+		if !ok || len(fields) != 3 {
+			return nil, fmt.Errorf("line %d: malformed block %q", n, line)
+		}
+		start, end, ok := strings.Cut(fields[0], ",")
+		if !ok {
+			return nil, fmt.Errorf("line %d: malformed block %q", n, line)
+		}
+		startLine, err1 := strconv.Atoi(strings.Split(start, ".")[0])
+		endLine, err2 := strconv.Atoi(strings.Split(end, ".")[0])
+		count, err3 := strconv.Atoi(fields[2])
+		if err1 != nil || err2 != nil || err3 != nil {
+			return nil, fmt.Errorf("line %d: malformed block %q", n, line)
+		}
+		for l := startLine; l <= endLine; l++ {
+			p.record(file, l, count > 0)
+		}
+	}
+	return p, sc.Err()
+}
+
+// parseLCOV reads an LCOV tracefile's line data: "SF:<file>" starts a
+// file's record and "DA:<line>,<count>" gives a line's execution count.
+func parseLCOV(data []byte) (*Profile, error) {
+	p := &Profile{Format: "lcov"}
+	file := ""
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	sc.Buffer(nil, 1<<20)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		switch {
+		case strings.HasPrefix(line, "SF:"):
+			file = strings.TrimPrefix(line, "SF:")
+		case line == "end_of_record":
+			file = ""
+		case strings.HasPrefix(line, "DA:"):
+			fields := strings.Split(strings.TrimPrefix(line, "DA:"), ",")
+			if file == "" || len(fields) < 2 {
+				return nil, fmt.Errorf("line %d: malformed %q", n, line)
+			}
+			num, err1 := strconv.Atoi(fields[0])
+			count, err2 := strconv.Atoi(fields[1])
+			if err1 != nil || err2 != nil {
+				return nil, fmt.Errorf("line %d: malformed %q", n, line)
+			}
+			p.record(file, num, count > 0)
+		}
+	}
+	return p, sc.Err()
+}
+
+// parseCobertura reads a Cobertura report. Class file names are relative
+// to the report's first source directory.
+func parseCobertura(data []byte) (*Profile, error) {
+	var report struct {
+		XMLName xml.Name `xml:"coverage"`
+		Sources []string `xml:"sources>source"`
+		Classes []struct {
+			Filename string `xml:"filename,attr"`
+			Lines    []struct {
+				Number int `xml:"number,attr"`
+				Hits   int `xml:"hits,attr"`
+			} `xml:"lines>line"`
+		} `xml:"packages>package>classes>class"`
+	}
+	if err := xml.Unmarshal(data, &report); err != nil {
+		return nil, fmt.Errorf("reading Cobertura XML: %w", err)
+	}
+	p := &Profile{Format: "cobertura"}
+	for _, c := range report.Classes {
+		file := filepath.ToSlash(c.Filename)
+		if len(report.Sources) > 0 && !path.IsAbs(file) {
+			file = path.Join(filepath.ToSlash(strings.TrimSpace(report.Sources[0])), file)
+		}
+		for _, l := range c.Lines {
+			p.record(file, l.Number, l.Hits > 0)
+		}
+	}
+	return p, nil
+}
@@ -0,0 +1,94 @@
+package coverage
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseGo(t *testing.T) {
+	p, err := Parse([]byte(`mode: set
+github.com/example/app/internal/auth/auth.go:10.30,12.2 1 1
+github.com/example/app/internal/auth/auth.go:12.2,15.3 2 0
+github.com/example/app/internal/auth/auth.go:20.1,21.2 1 0
+github.com/example/app/cmd/app/main.go:5.13,7.2 1 0
+github.com/example/app/main.go:5.13,7.2 1 1
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines, ok := p.Lines("internal/auth/auth.go")
+	if !ok || p.Format != "go" {
+		t.Fatalf("expected the file to be found by its path in the repository, got %v", p.files)
+	}
+	// Line 12 is in a block that ran and one that didn't
+	for line, want := range map[int]bool{10: true, 12: true, 13: false, 15: false, 21: false} {
+		if covered, ok := lines[line]; !ok || covered != want {
+			t.Errorf("line %d: covered = %v, %v; want %v", line, covered, ok, want)
+		}
+	}
+	if _, ok := lines[17]; ok {
+		t.Error("expected line 17 to be uninstrumented")
+	}
+	if lines, ok := p.Lines("main.go"); !ok || !lines[6] {
+		t.Errorf("expected main.go at the root to match the module's main.go, not cmd/app's; got %v", lines)
+	}
+	if _, ok := p.Lines("uth.go"); ok {
+		t.Error("expected only whole path elements to match")
+	}
+
+	if _, err := Parse([]byte("mode: set\nauth.go:10.30 1\n")); err == nil {
+		t.Error("expected a malformed block to be an error")
+	}
+}
+
+func TestParseLCOV(t *testing.T) {
+	p, err := Parse([]byte(`TN:
+SF:/home/ci/app/src/index.js
+DA:1,4
+DA:2,0
+BRDA:2,0,0,-
+end_of_record
+SF:/home/ci/app/src/util.js
+DA:7,1
+end_of_record
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines, ok := p.Lines("src/index.js")
+	if !ok || p.Format != "lcov" || !lines[1] || lines[2] || len(lines) != 2 {
+		t.Errorf("unexpected lines %v, %v", lines, ok)
+	}
+	if _, ok := p.Lines("src/other.js"); ok {
+		t.Error("expected a file the report doesn't have not to match")
+	}
+}
+
+func TestParseCobertura(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "coverage.xml")
+	report := `<?xml version="1.0" ?>
+<coverage version="7.4" line-rate="0.5">
+  <sources><source>/home/ci/app</source></sources>
+  <packages><package name="app"><classes>
+    <class name="views.py" filename="app/views.py">
+      <lines><line number="3" hits="1"/><line number="4" hits="0" branch="true" condition-coverage="50% (1/2)"/></lines>
+    </class>
+  </classes></package></packages>
+</coverage>`
+	if err := os.WriteFile(path, []byte(report), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	p, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines, ok := p.Lines("app/views.py")
+	if !ok || p.Format != "cobertura" || !lines[3] || lines[4] {
+		t.Errorf("unexpected lines %v, %v", lines, ok)
+	}
+
+	if _, err := Parse([]byte("not a coverage report")); err == nil {
+		t.Error("expected an unknown format to be an error")
+	}
+}
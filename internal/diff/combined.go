@@ -0,0 +1,297 @@
+package diff
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/bluekeyes/go-gitdiff/gitdiff"
+)
+
+// CombinedFragment is a hunk of a combined diff, the format git uses for
+// merge commits (git diff -c or --cc): each line shows its change against
+// every parent at once.
+type CombinedFragment struct {
+	OldPositions []int64 // first line in each parent
+	OldLines     []int64
+	NewPosition  int64
+	NewLines     int64
+	Lines        []CombinedLine
+}
+
+// CombinedLine is a line of a combined diff. Ops holds its change against
+// each parent, as in the prefix columns of git's output: OpAdd when the merge
+// result has the line and that parent doesn't, OpDelete when the parent had
+// it and the result doesn't, and OpContext otherwise. A line with any
+// OpDelete is not in the result, and OpContext then means that parent didn't
+// have it either.
+type CombinedLine struct {
+	Ops  []gitdiff.LineOp
+	Line string // including its newline, if it has one
+}
+
+// Removed reports whether the line was dropped from the merge result.
+func (l CombinedLine) Removed() bool {
+	for _, op := range l.Ops {
+		if op == gitdiff.OpDelete {
+			return true
+		}
+	}
+	return false
+}
+
+// isCombinedHeader reports whether line starts a file in a combined diff.
+func isCombinedHeader(line string) bool {
+	return strings.HasPrefix(line, "diff --cc ") || strings.HasPrefix(line, "diff --combined ")
+}
+
+// parseFiles parses raw into files. go-gitdiff doesn't understand combined
+// diffs and skips them, so those files are cut out and parsed here, keeping
+// their place among the others.
+func parseFiles(raw string) ([]*File, error) {
+	var files []*File
+	var plain, combined strings.Builder
+	flushPlain := func() error {
+		if plain.Len() == 0 {
+			return nil
+		}
+		parsed, _, err := gitdiff.Parse(strings.NewReader(plain.String()))
+		if err != nil {
+			return err
+		}
+		for _, f := range parsed {
+			files = append(files, newFile(f))
+		}
+		plain.Reset()
+		return nil
+	}
+	flushCombined := func() error {
+		if combined.Len() == 0 {
+			return nil
+		}
+		f, err := parseCombined(combined.String())
+		if err != nil {
+			return err
+		}
+		files = append(files, f)
+		combined.Reset()
+		return nil
+	}
+
+	inCombined := false
+	for _, line := range strings.SplitAfter(raw, "\n") {
+		switch {
+		case isCombinedHeader(line):
+			if err := flushPlain(); err != nil {
+				return nil, err
+			}
+			if err := flushCombined(); err != nil {
+				return nil, err
+			}
+			inCombined = true
+		case strings.HasPrefix(line, "diff --git "), strings.HasPrefix(line, "commit "):
+			if err := flushCombined(); err != nil {
+				return nil, err
+			}
+			inCombined = false
+		}
+		if inCombined {
+			combined.WriteString(line)
+		} else {
+			plain.WriteString(line)
+		}
+	}
+	if err := flushCombined(); err != nil {
+		return nil, err
+	}
+	if err := flushPlain(); err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+// parseCombined parses one file of a combined diff. Its Fragments hold the
+// changes against the first parent, which is what a merge brought into the
+// branch it was made on, so everything that reads ordinary diffs works on
+// it; Combined keeps the full picture.
+func parseCombined(section string) (*File, error) {
+	lines := strings.SplitAfter(section, "\n")
+	header := strings.TrimRight(lines[0], "\n")
+	name := strings.TrimPrefix(strings.TrimPrefix(header, "diff --cc "), "diff --combined ")
+	name, err := unquoteName(name)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", header, err)
+	}
+	f := &File{OldName: name, NewName: name}
+
+	i := 1
+	for ; i < len(lines) && !strings.HasPrefix(lines[i], "@@@"); i++ {
+		line := strings.TrimRight(lines[i], "\n")
+		switch {
+		case strings.HasPrefix(line, "index "):
+			oids, _, _ := strings.Cut(strings.TrimPrefix(line, "index "), " ")
+			old, new, _ := strings.Cut(oids, "..")
+			f.OldOID, _, _ = strings.Cut(old, ",")
+			f.NewOID = new
+		case strings.HasPrefix(line, "new file mode "):
+			f.IsNew = true
+		case strings.HasPrefix(line, "deleted file mode "):
+			f.IsDeleted = true
+		case strings.HasPrefix(line, "Binary files "):
+			f.IsBinary = true
+		}
+	}
+
+	for i < len(lines) && strings.HasPrefix(lines[i], "@@@") {
+		frag, n, err := parseCombinedFragment(lines[i:])
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		f.Combined = append(f.Combined, frag)
+		f.Parents = len(frag.OldPositions)
+		i += n
+	}
+	for _, frag := range f.Combined {
+		tf := frag.firstParent()
+		f.Fragments = append(f.Fragments, tf)
+		f.AddedLines += int(tf.LinesAdded)
+		f.DeletedLines += int(tf.LinesDeleted)
+	}
+	return f, nil
+}
+
+// parseCombinedFragment parses a hunk starting at lines[0], its
+// "@@@ -a,b -c,d +e,f @@@" header, and returns it with the number of lines
+// it took up.
+func parseCombinedFragment(lines []string) (*CombinedFragment, int, error) {
+	header := strings.TrimRight(lines[0], "\n")
+	marker := strings.Repeat("@", strings.IndexFunc(header, func(r rune) bool { return r != '@' }))
+	ranges, _, ok := strings.Cut(strings.TrimPrefix(header, marker+" "), " "+marker)
+	fields := strings.Fields(ranges)
+	parents := len(marker) - 1
+	if !ok || parents < 2 || len(fields) != parents+1 {
+		return nil, 0, fmt.Errorf("malformed combined hunk header %q", header)
+	}
+
+	frag := &CombinedFragment{}
+	for k, field := range fields {
+		sign := "-"
+		if k == parents {
+			sign = "+"
+		}
+		start, count, err := parseRange(strings.TrimPrefix(field, sign))
+		if err != nil || !strings.HasPrefix(field, sign) {
+			return nil, 0, fmt.Errorf("malformed combined hunk header %q", header)
+		}
+		if k == parents {
+			frag.NewPosition, frag.NewLines = start, count
+		} else {
+			frag.OldPositions = append(frag.OldPositions, start)
+			frag.OldLines = append(frag.OldLines, count)
+		}
+	}
+
+	// Read lines until every side's count is used up
+	oldLeft := append([]int64(nil), frag.OldLines...)
+	newLeft := frag.NewLines
+	remaining := func() bool {
+		for _, n := range oldLeft {
+			if n > 0 {
+				return true
+			}
+		}
+		return newLeft > 0
+	}
+	// A "\ No newline at end of file" marker applies to the line before
+	noNewline := func() {
+		if n := len(frag.Lines); n > 0 {
+			frag.Lines[n-1].Line = strings.TrimSuffix(frag.Lines[n-1].Line, "\n")
+		}
+	}
+	i := 1
+	for ; i < len(lines) && remaining(); i++ {
+		text := lines[i]
+		if text == "" {
+			break // end of input
+		}
+		if strings.HasPrefix(text, `\`) {
+			noNewline()
+			continue
+		}
+		if text == "\n" {
+			text = strings.Repeat(" ", parents) + text // a blank context line with its spaces trimmed
+		}
+		if len(text) < parents {
+			return nil, 0, fmt.Errorf("hunk %q ends early", header)
+		}
+		l := CombinedLine{Ops: make([]gitdiff.LineOp, parents), Line: text[parents:]}
+		for k := range parents {
+			switch text[k] {
+			case '+':
+				l.Ops[k] = gitdiff.OpAdd
+			case '-':
+				l.Ops[k] = gitdiff.OpDelete
+			case ' ':
+			default:
+				return nil, 0, fmt.Errorf("unexpected line %q in hunk %q", strings.TrimRight(text, "\n"), header)
+			}
+		}
+		removed := l.Removed()
+		for k, op := range l.Ops {
+			if op == gitdiff.OpDelete || (!removed && op == gitdiff.OpContext) {
+				oldLeft[k]--
+			}
+		}
+		if !removed {
+			newLeft--
+		}
+		frag.Lines = append(frag.Lines, l)
+	}
+	if remaining() {
+		return nil, 0, fmt.Errorf("hunk %q ends early", header)
+	}
+	if i < len(lines) && strings.HasPrefix(lines[i], `\`) {
+		noNewline()
+		i++
+	}
+	return frag, i, nil
+}
+
+// parseRange parses "start,count" from a hunk header; count defaults to 1.
+func parseRange(s string) (start, count int64, err error) {
+	startText, countText, hasCount := strings.Cut(s, ",")
+	if start, err = strconv.ParseInt(startText, 10, 64); err != nil {
+		return 0, 0, err
+	}
+	if !hasCount {
+		return start, 1, nil
+	}
+	count, err = strconv.ParseInt(countText, 10, 64)
+	return start, count, err
+}
+
+// firstParent converts the hunk to an ordinary one against the first
+// parent. Lines only the other parents had are left out.
+func (cf *CombinedFragment) firstParent() *gitdiff.TextFragment {
+	tf := &gitdiff.TextFragment{
+		OldPosition: cf.OldPositions[0],
+		NewPosition: cf.NewPosition,
+	}
+	for _, l := range cf.Lines {
+		op := l.Ops[0]
+		if op == gitdiff.OpContext && l.Removed() {
+			continue
+		}
+		tf.Lines = append(tf.Lines, gitdiff.Line{Op: op, Line: l.Line})
+	}
+	recount(tf)
+	return tf
+}
+
+// unquoteName undoes git's quoting of a path with unusual characters.
+func unquoteName(name string) (string, error) {
+	if !strings.HasPrefix(name, `"`) {
+		return name, nil
+	}
+	return strconv.Unquote(name)
+}
@@ -0,0 +1,172 @@
+package diff
+
+import (
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/bluekeyes/go-gitdiff/gitdiff"
+)
+
+// Commit is a single commit within a reviewed range, with its own diff.
+type Commit struct {
+	Hash    string
+	Author  string // "Name <email>"
+	Date    time.Time
+	Subject string
+	Body    string
+	Diff    *DiffSet
+}
+
+// ShortHash returns the abbreviated commit hash.
+func (c Commit) ShortHash() string {
+	if len(c.Hash) > 7 {
+		return c.Hash[:7]
+	}
+	return c.Hash
+}
+
+// GitCommits returns the commits in commitRange, oldest first, each with its
+// diff against its first parent. The range is resolved as for GitDiffRange,
+// so "main...HEAD" lists only the branch's own commits. Merges are included
+// only when they change something neither parent had, like a conflict
+// resolution, and carry git's combined diff.
+func GitCommits(repoDir, commitRange string, contextLines int, extra ...string) ([]Commit, error) {
+	rng, err := ResolveRange(repoDir, commitRange)
+	if err != nil {
+		return nil, err
+	}
+	head := rng.Head
+	if head == "" {
+		head = "HEAD"
+	}
+	out, err := git(repoDir, "rev-list", "--reverse", rng.Base+".."+head)
+	if err != nil {
+		return nil, err
+	}
+
+	var commits []Commit
+	for _, hash := range strings.Fields(out) {
+		c, err := gitCommit(repoDir, hash, contextLines, extra)
+		if err != nil {
+			return nil, err
+		}
+		if len(c.Diff.Files) == 0 {
+			continue // nothing to review, as in a clean merge
+		}
+		commits = append(commits, c)
+	}
+	return commits, nil
+}
+
+func gitCommit(repoDir, hash string, contextLines int, extra []string) (Commit, error) {
+	meta, err := git(repoDir, "show", "-s", "--format=%H%x00%an <%ae>%x00%aI%x00%s%x00%b", hash)
+	if err != nil {
+		return Commit{}, err
+	}
+	fields := strings.SplitN(meta, "\x00", 5)
+	if len(fields) < 5 {
+		return Commit{}, fmt.Errorf("unexpected git show output for %s", hash)
+	}
+
+	c := Commit{
+		Hash:    fields[0],
+		Author:  fields[1],
+		Subject: fields[3],
+		Body:    strings.TrimSpace(fields[4]),
+	}
+	c.Date, _ = time.Parse(time.RFC3339, fields[2])
+
+	args := append([]string{"show", "--format=", "--no-color"}, diffArgs(contextLines, extra, hash)...)
+	raw, err := git(repoDir, args...)
+	if err != nil {
+		return Commit{}, err
+	}
+	c.Diff, err = Parse(raw)
+	if err != nil {
+		return Commit{}, fmt.Errorf("commit %s: %w", c.ShortHash(), err)
+	}
+	return c, nil
+}
+
+// commitStartRe matches the line each commit starts with in 'git log -p'
+// output and in 'git format-patch' mail.
+var commitStartRe = regexp.MustCompile(`^(commit [0-9a-f]{7,64}\b|From [0-9a-f]{40,64} )`)
+
+// ParseCommits parses a series of commits with their messages, as written by
+// 'git log -p' or 'git format-patch' (one mbox or several files joined), in
+// the order they appear. Commits without changes, like clean merges in git
+// log, are left out, as are diffs before the first commit header.
+func ParseCommits(raw string) ([]Commit, error) {
+	raw = normalizePatch(raw)
+	var starts []int
+	offset := 0
+	for _, line := range strings.SplitAfter(raw, "\n") {
+		if commitStartRe.MatchString(line) {
+			starts = append(starts, offset)
+		}
+		offset += len(line)
+	}
+
+	var commits []Commit
+	for i, start := range starts {
+		end := len(raw)
+		if i+1 < len(starts) {
+			end = starts[i+1]
+		}
+		c, err := parseCommit(raw[start:end])
+		if err != nil {
+			return nil, err
+		}
+		if len(c.Diff.Files) > 0 {
+			commits = append(commits, c)
+		}
+	}
+	return commits, nil
+}
+
+// parseCommit parses one commit's header, message, and diff.
+func parseCommit(text string) (Commit, error) {
+	header := text
+	for _, start := range []string{"\ndiff --git ", "\ndiff --cc ", "\ndiff --combined "} {
+		if i := strings.Index(text, start); i >= 0 && i < len(header) {
+			header = text[:i]
+		}
+	}
+	h, err := gitdiff.ParsePatchHeader(header)
+	if err != nil {
+		first, _, _ := strings.Cut(header, "\n")
+		return Commit{}, fmt.Errorf("%s: %w", strings.TrimSpace(first), err)
+	}
+
+	c := Commit{
+		Hash:    h.SHA,
+		Date:    h.AuthorDate,
+		Subject: h.Title,
+		Body:    strings.TrimSpace(h.Body),
+	}
+	if h.Author != nil {
+		c.Author = h.Author.String()
+	}
+	c.Diff, err = Parse(text[len(header):])
+	if err != nil {
+		return Commit{}, fmt.Errorf("commit %s: %w", c.ShortHash(), err)
+	}
+	return c, nil
+}
+
+// git runs a git subcommand in repoDir and returns its stdout.
+func git(repoDir string, args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = repoDir
+	out, err := cmd.Output()
+	if err != nil {
+		if ee, ok := err.(*exec.ExitError); ok && len(ee.Stderr) > 0 {
+			return "", fmt.Errorf("git %s: %s", args[0], strings.TrimSpace(string(ee.Stderr)))
+		}
+		return "", fmt.Errorf("git %s: %w", args[0], err)
+	}
+	return string(out), nil
+}
@@ -0,0 +1,110 @@
+package diff
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/bluekeyes/go-gitdiff/gitdiff"
+)
+
+// NewContent returns the post-change content of f as lines. It reads the blob
+// named in the diff's index line from the repository, falling back to the
+// working tree copy when the blob is not in the object store (as with
+// uncommitted changes).
+func NewContent(repoDir string, f *File) ([]string, error) {
+	if f.IsBinary {
+		return nil, fmt.Errorf("%s is binary", f.NewName)
+	}
+	if f.IsSubmodule {
+		return nil, fmt.Errorf("%s is a submodule", f.NewName)
+	}
+	data, err := NewBytes(repoDir, f)
+	if err != nil {
+		return nil, err
+	}
+	return SplitLines(string(data)), nil
+}
+
+// NewBytes returns the raw post-change content of f, read the same way as
+// NewContent.
+func NewBytes(repoDir string, f *File) ([]byte, error) {
+	if f.IsDeleted {
+		return nil, fmt.Errorf("%s was deleted", f.OldName)
+	}
+	if repoDir == "" {
+		return nil, fmt.Errorf("no repository to read %s from", f.NewName)
+	}
+
+	if out, err := catBlob(repoDir, f.NewOID); err == nil {
+		return out, nil
+	}
+
+	data, err := os.ReadFile(filepath.Join(repoDir, f.NewName))
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", f.NewName, err)
+	}
+	return data, nil
+}
+
+// OldBytes returns the raw pre-change content of f from the object store.
+func OldBytes(repoDir string, f *File) ([]byte, error) {
+	if f.IsNew {
+		return nil, fmt.Errorf("%s is new", f.NewName)
+	}
+	if repoDir == "" {
+		return nil, fmt.Errorf("no repository to read %s from", f.OldName)
+	}
+	out, err := catBlob(repoDir, f.OldOID)
+	if err != nil {
+		return nil, fmt.Errorf("reading old %s: %w", f.OldName, err)
+	}
+	return out, nil
+}
+
+// Versions returns f's content before and after the change, nil for a side
+// that doesn't exist. Added and deleted files, whose hunks hold every line,
+// don't need the repository.
+func Versions(repoDir string, f *File) (old, new []byte, err error) {
+	if !f.IsNew {
+		if old, err = OldBytes(repoDir, f); err != nil {
+			if !f.IsDeleted {
+				return nil, nil, err
+			}
+			old = []byte(strings.Join(sideLines(f, gitdiff.OpAdd), ""))
+		}
+	}
+	if !f.IsDeleted {
+		if new, err = NewBytes(repoDir, f); err != nil {
+			if !f.IsNew {
+				return nil, nil, err
+			}
+			new = []byte(strings.Join(sideLines(f, gitdiff.OpDelete), ""))
+		}
+	}
+	return old, new, nil
+}
+
+// catBlob reads a blob by (possibly abbreviated) object ID.
+func catBlob(repoDir, oid string) ([]byte, error) {
+	if strings.Trim(oid, "0") == "" {
+		return nil, fmt.Errorf("no blob id")
+	}
+	cmd := exec.Command("git", "cat-file", "blob", oid)
+	cmd.Dir = repoDir
+	return cmd.Output()
+}
+
+// SplitLines splits text into lines without their line endings.
+func SplitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	lines := strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+	for i, l := range lines {
+		lines[i] = strings.TrimSuffix(l, "\r")
+	}
+	return lines
+}
@@ -2,12 +2,14 @@
 package diff
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
 	"strings"
 
 	"github.com/bluekeyes/go-gitdiff/gitdiff"
+	"github.com/aezell/agrev/internal/model"
 )
 
 // File represents a single file in a diff with its parsed fragments.
@@ -17,10 +19,41 @@ type File struct {
 	IsNew      bool
 	IsDeleted  bool
 	IsRenamed  bool
+	IsCopy     bool // NewName is a copy of OldName, with the hunks applied
 	IsBinary   bool
 	Fragments  []*gitdiff.TextFragment
 	AddedLines int
 	DeletedLines int
+
+	// Abbreviated blob hashes from the diff's index line, if present
+	OldOID string
+	NewOID string
+
+	// Git's modes for the file before and after, such as 0o100755 for an
+	// executable, when the diff gives them. They differ on a mode change,
+	// which may come without any hunks.
+	OldMode os.FileMode
+	NewMode os.FileMode
+
+	// For renames, the percentage of lines the two versions share, from
+	// git's similarity index when it has one. Paired is set on renames the
+	// diff showed as a deleted and a new file, found by pairRenames.
+	Similarity int
+	Paired     bool
+
+	// Submodule pointer changes: the commits the submodule pointed at before
+	// and after, empty when it was added or removed. Dirty means the new side
+	// has uncommitted changes inside the submodule.
+	IsSubmodule bool
+	OldCommit   string
+	NewCommit   string
+	Dirty       bool
+
+	// For a merge's combined diff, the number of parents and the hunks as
+	// git printed them, against every parent. Fragments then hold the
+	// changes against the first parent.
+	Parents  int
+	Combined []*CombinedFragment
 }
 
 // Name returns the display name for the file.
@@ -40,10 +73,77 @@ func (f *File) Name() string {
 	return f.OldName
 }
 
+// Position maps a line number to its position in the file's diff: the
+// count of lines below the first hunk header, with later hunk headers
+// counted too. This is how GitHub anchors review comments. Line is in the
+// new file, or in the old file when old is set (for deleted lines). It
+// reports false if the line is not part of the diff.
+func (f *File) Position(line int, old bool) (int, bool) {
+	pos := 0
+	for i, frag := range f.Fragments {
+		if i > 0 {
+			pos++ // hunk header
+		}
+		oldLine, newLine := int(frag.OldPosition), int(frag.NewPosition)
+		for _, l := range frag.Lines {
+			pos++
+			switch l.Op {
+			case gitdiff.OpContext:
+				if (old && oldLine == line) || (!old && newLine == line) {
+					return pos, true
+				}
+				oldLine++
+				newLine++
+			case gitdiff.OpAdd:
+				if !old && newLine == line {
+					return pos, true
+				}
+				newLine++
+			case gitdiff.OpDelete:
+				if old && oldLine == line {
+					return pos, true
+				}
+				oldLine++
+			}
+		}
+	}
+	return 0, false
+}
+
+// HunkAt returns the 1-based number of the hunk holding line, looking at
+// new-file lines first and then old-file ones (for deleted lines). It
+// returns 0 if no hunk holds it.
+func (f *File) HunkAt(line int) int {
+	if line <= 0 {
+		return 0
+	}
+	for i, frag := range f.Fragments {
+		if start := int(frag.NewPosition); line >= start && line < start+int(frag.NewLines) {
+			return i + 1
+		}
+	}
+	for i, frag := range f.Fragments {
+		if start := int(frag.OldPosition); line >= start && line < start+int(frag.OldLines) {
+			return i + 1
+		}
+	}
+	return 0
+}
+
 // DiffSet holds the parsed diff for all files.
 type DiffSet struct {
 	Files []*File
 	Raw   string // the raw unified diff text
+
+	// Blocks of code deleted from one file and added to another
+	Moves []model.Move
+
+	// The commits the diff compares, when it came from git
+	Range
+
+	// The repositories a combined diff spans, each file listed under its
+	// repository's name; empty for a single repository. See Combine.
+	Repos []Repo
 }
 
 // Stats returns aggregate statistics.
@@ -56,45 +156,65 @@ func (ds *DiffSet) Stats() (files, added, deleted int) {
 	return
 }
 
-// Parse reads a unified diff string and returns a DiffSet.
+// Parse reads a unified diff string and returns a DiffSet. Combined diffs
+// of merge commits are understood too, and a deleted file that reappears,
+// mostly unchanged, under another name is shown as a rename. A patch saved
+// with CRLF line endings is read as it was before.
 func Parse(raw string) (*DiffSet, error) {
-	parsed, _, err := gitdiff.Parse(strings.NewReader(raw))
+	raw = normalizePatch(raw)
+	files, err := parseFiles(raw)
 	if err != nil {
 		return nil, fmt.Errorf("parsing diff: %w", err)
 	}
 
-	ds := &DiffSet{Raw: raw}
-	for _, f := range parsed {
-		df := &File{
-			IsNew:     f.IsNew,
-			IsDeleted: f.IsDelete,
-			IsRenamed: f.IsRename,
-			IsBinary:  f.IsBinary,
-		}
+	if files, err = pairRenames(files); err != nil {
+		return nil, fmt.Errorf("parsing diff: %w", err)
+	}
 
-		if f.OldName != "" {
-			df.OldName = f.OldName
-		}
-		if f.NewName != "" {
-			df.NewName = f.NewName
-		}
+	ds := &DiffSet{Files: files, Raw: raw}
+	for _, f := range ds.Files {
+		f.fillScopes(nil)
+	}
+	ds.Moves = detectMoves(ds.Files)
 
-		for _, frag := range f.TextFragments {
-			df.Fragments = append(df.Fragments, frag)
-			for _, line := range frag.Lines {
-				switch line.Op {
-				case gitdiff.OpAdd:
-					df.AddedLines++
-				case gitdiff.OpDelete:
-					df.DeletedLines++
-				}
-			}
-		}
+	return ds, nil
+}
 
-		ds.Files = append(ds.Files, df)
+// newFile converts a file parsed by go-gitdiff.
+func newFile(f *gitdiff.File) *File {
+	df := &File{
+		OldName:   f.OldName,
+		NewName:   f.NewName,
+		IsNew:     f.IsNew,
+		IsDeleted: f.IsDelete,
+		IsRenamed: f.IsRename,
+		IsCopy:    f.IsCopy,
+		IsBinary:  f.IsBinary,
+		OldOID:    f.OldOIDPrefix,
+		NewOID:    f.NewOIDPrefix,
+		OldMode:   f.OldMode,
+		NewMode:   f.NewMode,
+	}
+	if f.IsRename {
+		df.Similarity = f.Score
+	}
+	if df.NewMode == 0 && !df.IsDeleted {
+		df.NewMode = df.OldMode // from the index line, which gives it once
 	}
 
-	return ds, nil
+	parseSubmodule(df, f)
+	for _, frag := range f.TextFragments {
+		df.Fragments = append(df.Fragments, frag)
+		for _, line := range frag.Lines {
+			switch line.Op {
+			case gitdiff.OpAdd:
+				df.AddedLines++
+			case gitdiff.OpDelete:
+				df.DeletedLines++
+			}
+		}
+	}
+	return df
 }
 
 // GitDiff runs `git diff` with the given arguments and returns the raw output.
@@ -112,12 +232,93 @@ func GitDiff(repoDir string, args ...string) (string, error) {
 	return string(out), nil
 }
 
-// GitDiffHead returns the diff of the working tree against HEAD.
-func GitDiffHead(repoDir string, contextLines int) (string, error) {
-	return GitDiff(repoDir, fmt.Sprintf("-U%d", contextLines), "HEAD")
+// IgnoreWhitespace is the git diff option that drops whitespace-only
+// changes. Pass it as an extra argument to the GitDiff helpers.
+const IgnoreWhitespace = "--ignore-all-space"
+
+// diffArgs returns the git diff arguments for contextLines of context and
+// the caller's extra options, followed by rest.
+func diffArgs(contextLines int, extra []string, rest ...string) []string {
+	args := append([]string{fmt.Sprintf("-U%d", contextLines)}, extra...)
+	return append(args, rest...)
+}
+
+// GitDiffHead returns the diff of the working tree against HEAD. Extra
+// arguments, like IgnoreWhitespace, are passed to git diff.
+func GitDiffHead(repoDir string, contextLines int, extra ...string) (string, error) {
+	return GitDiff(repoDir, diffArgs(contextLines, extra, "HEAD")...)
+}
+
+// GitDiffStaged returns the diff of the index against HEAD: what would be
+// committed next.
+func GitDiffStaged(repoDir string, contextLines int, extra ...string) (string, error) {
+	return GitDiff(repoDir, diffArgs(contextLines, extra, "--cached")...)
+}
+
+// GitDiffUnstaged returns the diff of the working tree against the index.
+func GitDiffUnstaged(repoDir string, contextLines int, extra ...string) (string, error) {
+	return GitDiff(repoDir, diffArgs(contextLines, extra)...)
+}
+
+// GitDiffUntracked synthesizes new-file diffs for untracked files that
+// aren't ignored, so work git doesn't know about yet can be reviewed.
+func GitDiffUntracked(repoDir string, contextLines int, extra ...string) (string, error) {
+	cmd := exec.Command("git", "ls-files", "--others", "--exclude-standard", "-z")
+	cmd.Dir = repoDir
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("listing untracked files: %w", err)
+	}
+
+	var b strings.Builder
+	for _, name := range strings.Split(string(out), "\x00") {
+		if name == "" {
+			continue
+		}
+		args := append([]string{"diff", "--no-index"}, diffArgs(contextLines, extra, "--", "/dev/null", name)...)
+		cmd := exec.Command("git", args...)
+		cmd.Dir = repoDir
+		patch, err := cmd.Output()
+		// --no-index exits 1 when the files differ, which they always do here
+		var exit *exec.ExitError
+		if err != nil && !(errors.As(err, &exit) && exit.ExitCode() == 1) {
+			return "", fmt.Errorf("git diff %s: %w", name, err)
+		}
+		b.Write(patch)
+	}
+	return b.String(), nil
+}
+
+// GitDiffRange returns the diff for a commit range like "main...HEAD", and
+// the commits it compares. See ResolveRange.
+func GitDiffRange(repoDir string, commitRange string, contextLines int, extra ...string) (string, Range, error) {
+	rng, err := ResolveRange(repoDir, commitRange)
+	if err != nil {
+		return "", Range{}, err
+	}
+	revs := []string{rng.Base}
+	if rng.Head != "" {
+		revs = append(revs, rng.Head)
+	}
+	raw, err := GitDiff(repoDir, diffArgs(contextLines, extra, revs...)...)
+	return raw, rng, err
 }
 
-// GitDiffRange returns the diff for a commit range like "main...HEAD".
-func GitDiffRange(repoDir string, commitRange string, contextLines int) (string, error) {
-	return GitDiff(repoDir, fmt.Sprintf("-U%d", contextLines), commitRange)
+// GitApply runs `git apply` with the given arguments, reading the patch from
+// stdin. Errors include git's explanation of what failed to apply.
+func GitApply(repoDir, patch string, args ...string) error {
+	cmdArgs := append([]string{"apply"}, args...)
+	cmd := exec.Command("git", cmdArgs...)
+	cmd.Dir = repoDir
+	cmd.Stdin = strings.NewReader(patch)
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return fmt.Errorf("git apply: %s", msg)
+		}
+		return fmt.Errorf("git apply: %w", err)
+	}
+	return nil
 }
@@ -1,7 +1,14 @@
 package diff
 
 import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
 	"testing"
+
+	"github.com/bluekeyes/go-gitdiff/gitdiff"
 )
 
 const sampleDiff = `diff --git a/hello.go b/hello.go
@@ -89,3 +96,1447 @@ func TestParseEmpty(t *testing.T) {
 		t.Errorf("expected 0 files, got %d", len(ds.Files))
 	}
 }
+
+func TestIntralineDiff(t *testing.T) {
+	oldLine := `	println("hello")`
+	newLine := `	println("hello world")`
+
+	oldSpans, newSpans := IntralineDiff(oldLine, newLine)
+	if len(oldSpans) != 0 {
+		t.Errorf("expected no changed spans on old line, got %v", oldSpans)
+	}
+	if len(newSpans) != 1 {
+		t.Fatalf("expected 1 changed span on new line, got %v", newSpans)
+	}
+	if got := newLine[newSpans[0].Start:newSpans[0].End]; got != " world" {
+		t.Errorf("expected changed text %q, got %q", " world", got)
+	}
+}
+
+func TestIntralineDiffWordChange(t *testing.T) {
+	oldLine := "return a + b"
+	newLine := "return a - b"
+
+	oldSpans, newSpans := IntralineDiff(oldLine, newLine)
+	if len(oldSpans) != 1 || oldLine[oldSpans[0].Start:oldSpans[0].End] != "+" {
+		t.Errorf("unexpected old spans %v", oldSpans)
+	}
+	if len(newSpans) != 1 || newLine[newSpans[0].Start:newSpans[0].End] != "-" {
+		t.Errorf("unexpected new spans %v", newSpans)
+	}
+}
+
+func TestIntralineDiffUnrelated(t *testing.T) {
+	oldSpans, newSpans := IntralineDiff("func foo() {", "// completely different text")
+	if oldSpans != nil || newSpans != nil {
+		t.Errorf("expected no emphasis for rewritten line, got %v %v", oldSpans, newSpans)
+	}
+}
+
+func TestNewContent(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "hello.go"), []byte("package main\r\n\nfunc main() {}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// No blob hash in the object store: falls back to the working tree
+	lines, err := NewContent(dir, &File{NewName: "hello.go", NewOID: "e69de29"})
+	if err != nil {
+		t.Fatalf("NewContent failed: %v", err)
+	}
+	if len(lines) != 3 || lines[0] != "package main" || lines[2] != "func main() {}" {
+		t.Errorf("unexpected lines %q", lines)
+	}
+
+	if _, err := NewContent(dir, &File{OldName: "gone.go", IsDeleted: true}); err == nil {
+		t.Error("expected error for deleted file")
+	}
+}
+
+func TestGitCommits(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	dir := t.TempDir()
+	run := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		cmd.Env = append(os.Environ(),
+			"GIT_AUTHOR_NAME=Test", "GIT_AUTHOR_EMAIL=test@example.com",
+			"GIT_COMMITTER_NAME=Test", "GIT_COMMITTER_EMAIL=test@example.com")
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	write := func(name, content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	run("init", "-q")
+	write("a.txt", "one\n")
+	run("add", ".")
+	run("commit", "-q", "-m", "base")
+	write("a.txt", "one\ntwo\n")
+	run("commit", "-q", "-am", "Add two", "-m", "Longer explanation.")
+	write("b.txt", "new\n")
+	run("add", ".")
+	run("commit", "-q", "-m", "Add b")
+
+	commits, err := GitCommits(dir, "HEAD~2..HEAD", 3)
+	if err != nil {
+		t.Fatalf("GitCommits failed: %v", err)
+	}
+	if len(commits) != 2 {
+		t.Fatalf("expected 2 commits, got %d", len(commits))
+	}
+
+	first := commits[0]
+	if first.Subject != "Add two" || first.Body != "Longer explanation." {
+		t.Errorf("unexpected message %q / %q", first.Subject, first.Body)
+	}
+	if first.Author != "Test <test@example.com>" || first.Date.IsZero() || len(first.ShortHash()) != 7 {
+		t.Errorf("unexpected metadata %+v", first)
+	}
+	if len(first.Diff.Files) != 1 || first.Diff.Files[0].Name() != "a.txt" {
+		t.Errorf("expected first commit to change a.txt")
+	}
+	if len(commits[1].Diff.Files) != 1 || !commits[1].Diff.Files[0].IsNew {
+		t.Errorf("expected second commit to add b.txt")
+	}
+}
+
+const positionDiff = `diff --git a/main.go b/main.go
+index abc1234..def5678 100644
+--- a/main.go
++++ b/main.go
+@@ -1,4 +1,4 @@
+ package main
+-var a = 1
++var a = 2
+ var b = 3
+ var c = 4
+@@ -10,2 +10,3 @@ func f() {
+ 	x()
++	y()
+ }
+`
+
+func TestPosition(t *testing.T) {
+	ds, err := Parse(positionDiff)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := ds.Files[0]
+
+	tests := []struct {
+		line int
+		old  bool
+		want int
+		ok   bool
+	}{
+		{1, false, 1, true},  // context
+		{2, true, 2, true},   // deleted line
+		{2, false, 3, true},  // added line
+		{4, false, 5, true},  // last context of first hunk
+		{11, false, 8, true}, // second hunk counts its header
+		{7, false, 0, false}, // between hunks
+	}
+	for _, tt := range tests {
+		got, ok := f.Position(tt.line, tt.old)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("f.Position(%d, old=%v) = %d, %v; want %d, %v", tt.line, tt.old, got, ok, tt.want, tt.ok)
+		}
+	}
+
+	for line, want := range map[int]int{0: 0, 2: 1, 7: 0, 12: 2} {
+		if got := f.HunkAt(line); got != want {
+			t.Errorf("f.HunkAt(%d) = %d, want %d", line, got, want)
+		}
+	}
+}
+
+func TestGitDiffSources(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	dir := t.TempDir()
+	run := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		cmd.Env = append(os.Environ(),
+			"GIT_AUTHOR_NAME=Test", "GIT_AUTHOR_EMAIL=test@example.com",
+			"GIT_COMMITTER_NAME=Test", "GIT_COMMITTER_EMAIL=test@example.com")
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	write := func(name, content string) {
+		t.Helper()
+		if err := os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	names := func(raw string, err error) []string {
+		t.Helper()
+		if err != nil {
+			t.Fatal(err)
+		}
+		ds, err := Parse(raw)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var out []string
+		for _, f := range ds.Files {
+			out = append(out, f.Name())
+		}
+		return out
+	}
+
+	run("init", "-q")
+	write("staged.txt", "one\n")
+	write("unstaged.txt", "one\n")
+	write(".gitignore", "*.log\n")
+	run("add", ".")
+	run("commit", "-q", "-m", "base")
+	write("staged.txt", "one\ntwo\n")
+	run("add", "staged.txt")
+	write("unstaged.txt", "one\ntwo\n")
+	write("pkg/new.go", "package pkg\n")
+	write("debug.log", "ignored\n")
+
+	if got := names(GitDiffStaged(dir, 3)); len(got) != 1 || got[0] != "staged.txt" {
+		t.Errorf("staged: got %v", got)
+	}
+	if got := names(GitDiffUnstaged(dir, 3)); len(got) != 1 || got[0] != "unstaged.txt" {
+		t.Errorf("unstaged: got %v", got)
+	}
+
+	write("unstaged.txt", "  one\ntwo\n")
+	if got := names(GitDiffUnstaged(dir, 3)); len(got) != 1 {
+		t.Errorf("unstaged: expected the reindent to show, got %v", got)
+	}
+	if got := names(GitDiffHead(dir, 3, IgnoreWhitespace)); len(got) != 2 || got[1] != "unstaged.txt" {
+		t.Errorf("ignoring whitespace: expected only the added line, got %v", got)
+	}
+
+	raw, err := GitDiffUntracked(dir, 3)
+	ds, _ := Parse(raw)
+	if err != nil || len(ds.Files) != 1 {
+		t.Fatalf("untracked: expected one file, got %q, %v", raw, err)
+	}
+	if f := ds.Files[0]; f.Name() != "pkg/new.go" || !f.IsNew || f.AddedLines != 1 {
+		t.Errorf("untracked: expected pkg/new.go as a new file, got %+v", f)
+	}
+}
+
+func TestWhitespaceOnly(t *testing.T) {
+	tests := []struct {
+		name string
+		hunk string
+		want bool
+	}{
+		{"reindent", "-\tx := 1\n+    x := 1\n", true},
+		{"trailing space", "-x := 1  \n+x := 1\n", true},
+		{"blank line", " x := 1\n+\n", true},
+		{"rewrap", "-f(a, b)\n+f(a,\n+\tb)\n", true},
+		{"edit", "-x := 1\n+x := 2\n", false},
+		{"space inside word", "-return x\n+returnx\n", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var oldN, newN int
+			for _, l := range strings.SplitAfter(tt.hunk, "\n") {
+				if l == "" {
+					continue
+				}
+				if l[0] != '+' {
+					oldN++
+				}
+				if l[0] != '-' {
+					newN++
+				}
+			}
+			raw := fmt.Sprintf("diff --git a/a.go b/a.go\n--- a/a.go\n+++ b/a.go\n@@ -1,%d +1,%d @@\n%s", oldN, newN, tt.hunk)
+			ds, err := Parse(raw)
+			if err != nil {
+				t.Fatal(err)
+			}
+			f := ds.Files[0]
+			if got := WhitespaceOnly(f.Fragments[0]); got != tt.want {
+				t.Errorf("WhitespaceOnly = %v, want %v", got, tt.want)
+			}
+			if got := f.WhitespaceOnly(); got != tt.want {
+				t.Errorf("File.WhitespaceOnly = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGenerated(t *testing.T) {
+	raw := `diff --git a/go.sum b/go.sum
+--- a/go.sum
++++ b/go.sum
+@@ -1,4 +1,4 @@
+-example.com/a v1.0.0 h1:old=
+-example.com/a v1.0.0/go.mod h1:old=
++example.com/a v1.1.0 h1:new=
++example.com/a v1.1.0/go.mod h1:new=
+ example.com/b v0.2.0 h1:same=
+ example.com/b v0.2.0/go.mod h1:same=
+diff --git a/ui/__snapshots__/app.test.js.snap b/ui/__snapshots__/app.test.js.snap
+--- a/ui/__snapshots__/app.test.js.snap
++++ b/ui/__snapshots__/app.test.js.snap
+@@ -1,2 +1,3 @@
+ exports[` + "`App renders 1`" + `] = ` + "`" + `
+-<div />
++<div>
++</div>
+diff --git a/api/api.pb.go b/api/api.pb.go
+new file mode 100644
+--- /dev/null
++++ b/api/api.pb.go
+@@ -0,0 +1,3 @@
++// Code generated by protoc-gen-go. DO NOT EDIT.
++
++package api
+diff --git a/main.go b/main.go
+--- a/main.go
++++ b/main.go
+@@ -1,1 +1,1 @@
+-// TODO: Code generated by hand. DO NOT EDIT.
++package main
+`
+	ds, err := Parse(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"go.sum: 2 entries changed",
+		"ui/__snapshots__/app.test.js.snap: +2 -1 lines",
+		"api/api.pb.go: 3 lines added",
+		"",
+	}
+	for i, f := range ds.Files {
+		if f.Generated() != (want[i] != "") {
+			t.Errorf("%s: Generated = %v", f.Name(), f.Generated())
+			continue
+		}
+		if want[i] != "" {
+			if got := f.GeneratedSummary(); got != want[i] {
+				t.Errorf("GeneratedSummary = %q, want %q", got, want[i])
+			}
+		}
+	}
+}
+
+func TestDecodeText(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		text string
+		enc  string
+		ok   bool
+	}{
+		{"utf-8", []byte("héllo\n"), "héllo\n", EncodingUTF8, true},
+		{"utf-8 bom", []byte("\xef\xbb\xbfhi\n"), "hi\n", EncodingUTF8, true},
+		{"latin-1", []byte("h\xe9llo\n"), "héllo\n", EncodingLatin1, true},
+		{"utf-16le bom", []byte("\xff\xfeh\x00\xe9\x00\n\x00"), "hé\n", EncodingUTF16LE, true},
+		{"utf-16be bom", []byte("\xfe\xff\x00h\x00\xe9\x00\n"), "hé\n", EncodingUTF16BE, true},
+		{"utf-16le no bom", []byte("a\x00b\x00c\x00\r\x00\n\x00"), "abc\r\n", EncodingUTF16LE, true},
+		{"binary", []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR"), "", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			text, enc, ok := DecodeText(tt.data)
+			if text != tt.text || enc != tt.enc || ok != tt.ok {
+				t.Errorf("DecodeText = %q, %q, %v; want %q, %q, %v", text, enc, ok, tt.text, tt.enc, tt.ok)
+			}
+		})
+	}
+	if got := DisplayText("caf\xe9"); got != "café" {
+		t.Errorf("DisplayText = %q, want café", got)
+	}
+}
+
+func TestParseCRLFPatch(t *testing.T) {
+	// A patch saved with CRLF endings; b.txt itself has CRLF endings, so
+	// its lines end in CR CR LF
+	raw := strings.ReplaceAll("diff --git a/a.txt b/a.txt\n--- a/a.txt\n+++ b/a.txt\n@@ -1,2 +1,2 @@\n one\n-two\n+three\n", "\n", "\r\n") +
+		strings.ReplaceAll("diff --git a/b.txt b/b.txt\n--- a/b.txt\n+++ b/b.txt\n@@ -1 +1 @@\n-x\r\n+y\r\n", "\n", "\r\n")
+	ds, err := Parse(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ds.Files) != 2 || ds.Files[0].Name() != "a.txt" || ds.Files[1].Name() != "b.txt" {
+		t.Fatalf("expected a.txt and b.txt, got %d files", len(ds.Files))
+	}
+	if got := ds.Files[0].Fragments[0].Lines[2].Line; got != "three\n" {
+		t.Errorf("a.txt line = %q, want LF ending", got)
+	}
+	if got := ds.Files[1].Fragments[0].Lines[1].Line; got != "y\r\n" {
+		t.Errorf("b.txt line = %q, want CRLF ending", got)
+	}
+
+	// A file's own CRLF endings in an ordinary patch stay as they are
+	ds, err = Parse("diff --git a/c.txt b/c.txt\n--- a/c.txt\n+++ b/c.txt\n@@ -1 +1 @@\n-x\r\n+x\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	from, to, ok := ds.Files[0].LineEndingChange()
+	if !ok || from != EndingCRLF || to != EndingLF {
+		t.Errorf("LineEndingChange = %q, %q, %v; want CRLF to LF", from, to, ok)
+	}
+}
+
+func TestDecoded(t *testing.T) {
+	utf16 := func(s string) []byte {
+		out := []byte{0xff, 0xfe}
+		for _, r := range s {
+			out = append(out, byte(r), 0)
+		}
+		return out
+	}
+	ds, err := Parse("diff --git a/res.rc b/res.rc\nindex 1111111..2222222 100644\nBinary files a/res.rc and b/res.rc differ\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := ds.Files[0]
+	decoded, enc, ok := Decoded(f, utf16("one\r\ntwo\r\n"), utf16("one\r\nthree\r\n"))
+	if !ok || enc != EncodingUTF16LE {
+		t.Fatalf("Decoded = %v, %q; want UTF-16LE", ok, enc)
+	}
+	if decoded.AddedLines != 1 || decoded.DeletedLines != 1 || decoded.Fragments[0].Lines[2].Line != "three\r\n" {
+		t.Errorf("expected two -> three, got +%d -%d", decoded.AddedLines, decoded.DeletedLines)
+	}
+
+	if _, enc, ok := Decoded(f, utf16("one\n"), []byte("one\n")); !ok || enc != "UTF-16LE → UTF-8" {
+		t.Errorf("expected the change of encoding, got %q", enc)
+	}
+	if _, _, ok := Decoded(f, []byte("one\n"), []byte("two\n")); ok {
+		t.Error("expected text marked binary by attributes to stay binary")
+	}
+}
+
+const movedDiff = `diff --git a/old.go b/old.go
+--- a/old.go
++++ b/old.go
+@@ -1,9 +1,3 @@
+ package old
+ 
+-func helper(a, b int) int {
+-	sum := a + b
+-
+-	return sum
+-}
+-
+ func keep() {}
+diff --git a/new.go b/new.go
+--- a/new.go
++++ b/new.go
+@@ -1,2 +1,9 @@
+ package new
+ 
++// helper moved here
++func helper(a, b int) int {
++    sum := a + b
++
++    return sum
++}
++
+`
+
+func TestDetectMoves(t *testing.T) {
+	ds, err := Parse(movedDiff)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ds.Moves) != 1 {
+		t.Fatalf("expected one move, got %+v", ds.Moves)
+	}
+	mv := ds.Moves[0]
+	if mv.FromFile != "old.go" || mv.From.Start != 3 || mv.From.End != 7 {
+		t.Errorf("unexpected source %s:%d-%d", mv.FromFile, mv.From.Start, mv.From.End)
+	}
+	if mv.ToFile != "new.go" || mv.To.Start != 4 || mv.To.End != 8 {
+		t.Errorf("unexpected destination %s:%d-%d", mv.ToFile, mv.To.Start, mv.To.End)
+	}
+
+	if _, ok := ds.MoveAt(ds.Files[0], 5, true); !ok {
+		t.Error("expected the blank line inside the block to count as moved")
+	}
+	if _, ok := ds.MoveAt(ds.Files[1], 3, false); ok {
+		t.Error("expected the new comment not to count as moved")
+	}
+}
+
+func TestDetectMovesIgnoresTrivialBlocks(t *testing.T) {
+	raw := `diff --git a/a.go b/a.go
+--- a/a.go
++++ b/a.go
+@@ -1,4 +1,1 @@
+ x
+-	}
+-}
+-}
+diff --git a/b.go b/b.go
+--- a/b.go
++++ b/b.go
+@@ -1,1 +1,4 @@
+ y
++	}
++}
++}
+`
+	ds, err := Parse(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ds.Moves) != 0 {
+		t.Errorf("expected closing braces not to count as a move, got %+v", ds.Moves)
+	}
+}
+
+func TestParseSubmodule(t *testing.T) {
+	tests := []struct {
+		name          string
+		diff          string
+		old, new      string
+		dirty, module bool
+	}{
+		{"bump", `diff --git a/lib b/lib
+index f016375..5f6b995 160000
+--- a/lib
++++ b/lib
+@@ -1 +1 @@
+-Subproject commit f0163751f6e5426c5d700848e735d09a79f11dc0
++Subproject commit 5f6b99566e8141c66ab73afae25f966fded1fff6
+`, "f0163751f6e5426c5d700848e735d09a79f11dc0", "5f6b99566e8141c66ab73afae25f966fded1fff6", false, true},
+		{"added", `diff --git a/lib b/lib
+new file mode 160000
+index 0000000..5f6b995
+--- /dev/null
++++ b/lib
+@@ -0,0 +1 @@
++Subproject commit 5f6b99566e8141c66ab73afae25f966fded1fff6
+`, "", "5f6b99566e8141c66ab73afae25f966fded1fff6", false, true},
+		{"dirty", `diff --git a/lib b/lib
+--- a/lib
++++ b/lib
+@@ -1 +1 @@
+-Subproject commit 5f6b99566e8141c66ab73afae25f966fded1fff6
++Subproject commit 5f6b99566e8141c66ab73afae25f966fded1fff6-dirty
+`, "5f6b99566e8141c66ab73afae25f966fded1fff6", "5f6b99566e8141c66ab73afae25f966fded1fff6", true, true},
+		{"regular file", `diff --git a/notes.txt b/notes.txt
+--- a/notes.txt
++++ b/notes.txt
+@@ -1 +1 @@
+-Subproject commit is a line git writes
++Subproject commits are pointers
+`, "", "", false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ds, err := Parse(tt.diff)
+			if err != nil {
+				t.Fatal(err)
+			}
+			f := ds.Files[0]
+			if f.IsSubmodule != tt.module || f.OldCommit != tt.old || f.NewCommit != tt.new || f.Dirty != tt.dirty {
+				t.Errorf("got submodule=%v old=%q new=%q dirty=%v", f.IsSubmodule, f.OldCommit, f.NewCommit, f.Dirty)
+			}
+		})
+	}
+}
+
+func TestSubmoduleLog(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	dir := t.TempDir()
+	run := func(dir string, args ...string) string {
+		t.Helper()
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		cmd.Env = append(os.Environ(),
+			"GIT_AUTHOR_NAME=Test", "GIT_AUTHOR_EMAIL=test@example.com",
+			"GIT_COMMITTER_NAME=Test", "GIT_COMMITTER_EMAIL=test@example.com")
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+		return strings.TrimSpace(string(out))
+	}
+
+	sub := filepath.Join(dir, "sub")
+	run(dir, "init", "-q", sub)
+	run(sub, "commit", "-q", "--allow-empty", "-m", "first")
+	run(sub, "commit", "-q", "--allow-empty", "-m", "second")
+	run(sub, "commit", "-q", "--allow-empty", "-m", "third")
+
+	repo := filepath.Join(dir, "repo")
+	run(dir, "init", "-q", repo)
+	run(repo, "-c", "protocol.file.allow=always", "submodule", "add", "-q", sub, "lib")
+	run(repo, "commit", "-q", "-m", "add lib")
+	run(filepath.Join(repo, "lib"), "checkout", "-q", "HEAD~2")
+
+	raw, err := GitDiffHead(repo, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ds, err := Parse(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ds.Files) != 1 || !ds.Files[0].IsSubmodule {
+		t.Fatalf("expected one submodule change, got %q", raw)
+	}
+
+	log, err := SubmoduleLog(repo, ds.Files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(log) != 2 || !strings.HasPrefix(log[0], "< ") || !strings.HasSuffix(log[0], "third") {
+		t.Errorf("expected the two dropped commits, got %q", log)
+	}
+}
+
+func TestResolveRange(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	dir := t.TempDir()
+	git := func(args ...string) string {
+		t.Helper()
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		cmd.Env = append(os.Environ(),
+			"GIT_AUTHOR_NAME=Test", "GIT_AUTHOR_EMAIL=test@example.com",
+			"GIT_COMMITTER_NAME=Test", "GIT_COMMITTER_EMAIL=test@example.com")
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+		return strings.TrimSpace(string(out))
+	}
+	write := func(name, content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// main moves on after feature branches off it
+	git("init", "-q", "-b", "main")
+	write("a.txt", "one\n")
+	git("add", ".")
+	git("commit", "-q", "-m", "base")
+	base := git("rev-parse", "HEAD")
+	git("checkout", "-q", "-b", "feature")
+	write("b.txt", "feature\n")
+	git("add", ".")
+	git("commit", "-q", "-m", "Add b")
+	write("b.txt", "feature\nmore\n")
+	git("commit", "-q", "-am", "Extend b")
+	feature := git("rev-parse", "HEAD")
+	git("checkout", "-q", "main")
+	write("a.txt", "one\nupstream\n")
+	git("commit", "-q", "-am", "Upstream change")
+	main := git("rev-parse", "HEAD")
+
+	tests := []struct {
+		spec       string
+		base, head string
+	}{
+		{"main...feature", base, feature},
+		{"main..feature", main, feature},
+		{"...feature", base, feature},
+		{"feature^!", git("rev-parse", "feature^"), feature},
+		{"feature", feature, ""},
+	}
+	for _, tt := range tests {
+		r, err := ResolveRange(dir, tt.spec)
+		if err != nil {
+			t.Errorf("ResolveRange(%q): %v", tt.spec, err)
+			continue
+		}
+		if r.Base != tt.base || r.Head != tt.head {
+			t.Errorf("ResolveRange(%q) = %+v, want %s..%s", tt.spec, r, tt.base, tt.head)
+		}
+	}
+	if _, err := ResolveRange(dir, "main...nonexistent"); err == nil {
+		t.Error("expected an error for an unknown revision")
+	}
+
+	r, _ := ResolveRange(dir, "main...feature")
+	if got, want := r.Short(), base[:7]+".."+feature[:7]; got != want {
+		t.Errorf("Short() = %q, want %q", got, want)
+	}
+
+	// Three dots leave out what main gained since the branch point
+	raw, _, err := GitDiffRange(dir, "main...feature", 3)
+	if err != nil {
+		t.Fatalf("GitDiffRange failed: %v", err)
+	}
+	ds, err := Parse(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ds.Files) != 1 || ds.Files[0].Name() != "b.txt" {
+		t.Errorf("expected only b.txt in main...feature, got %d files", len(ds.Files))
+	}
+	commits, err := GitCommits(dir, "main...feature", 3)
+	if err != nil {
+		t.Fatalf("GitCommits failed: %v", err)
+	}
+	if len(commits) != 2 || commits[0].Subject != "Add b" || commits[1].Subject != "Extend b" {
+		t.Errorf("expected the two feature commits, got %d", len(commits))
+	}
+}
+
+func TestDiffDirs(t *testing.T) {
+	root := t.TempDir()
+	oldDir, newDir := filepath.Join(root, "old"), filepath.Join(root, "new")
+	write := func(dir, name, content string, mode os.FileMode) {
+		t.Helper()
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), mode); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(oldDir, "same.txt", "unchanged\n", 0644)
+	write(newDir, "same.txt", "unchanged\n", 0644)
+	write(oldDir, "src/main.go", "package main\n\nfunc main() {\n\tprintln(1)\n}\n", 0644)
+	write(newDir, "src/main.go", "package main\n\nfunc main() {\n\tprintln(2)\n}\n", 0644)
+	write(oldDir, "gone.txt", "bye\n", 0644)
+	write(newDir, "run.sh", "#!/bin/sh\necho hi", 0755)
+	write(newDir, "logo.png", "\x89PNG\x00\x01", 0644)
+	write(oldDir, "fmt.go", "a\n  b\n", 0644)
+	write(newDir, "fmt.go", "a\n\tb\n", 0644)
+	write(newDir, ".git/HEAD", "ref: refs/heads/main\n", 0644)
+
+	raw, err := DiffDirs(oldDir, newDir, 3, false)
+	if err != nil {
+		t.Fatalf("DiffDirs failed: %v", err)
+	}
+	ds, err := Parse(raw)
+	if err != nil {
+		t.Fatalf("Parse failed: %v\n%s", err, raw)
+	}
+	byName := make(map[string]*File)
+	for _, f := range ds.Files {
+		byName[f.Name()] = f
+	}
+	if len(ds.Files) != 5 {
+		t.Fatalf("expected 5 changed files, got %d:\n%s", len(ds.Files), raw)
+	}
+	if f := byName["src/main.go"]; f == nil || f.AddedLines != 1 || f.DeletedLines != 1 || f.Fragments[0].NewPosition != 1 {
+		t.Errorf("unexpected diff for src/main.go:\n%s", raw)
+	}
+	if f := byName["gone.txt"]; f == nil || !f.IsDeleted {
+		t.Error("expected gone.txt to be deleted")
+	}
+	if f := byName["run.sh"]; f == nil || !f.IsNew || f.AddedLines != 2 {
+		t.Error("expected run.sh to be a new two-line file")
+	}
+	if !strings.Contains(raw, "new file mode 100755\n") || !strings.Contains(raw, "\\ No newline at end of file") {
+		t.Errorf("expected an executable mode and a missing-newline marker:\n%s", raw)
+	}
+	if f := byName["logo.png"]; f == nil || !f.IsBinary {
+		t.Error("expected logo.png to be binary")
+	}
+	// The blob IDs match git's, so the content can be found in a repository
+	if f := byName["gone.txt"]; f != nil && f.OldOID != "b023018" {
+		t.Errorf("expected git's blob ID for gone.txt, got %s", f.OldOID)
+	}
+
+	raw, err = DiffDirs(oldDir, newDir, 3, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(raw, "fmt.go") {
+		t.Errorf("expected whitespace-only fmt.go to be left out:\n%s", raw)
+	}
+
+	if _, err := DiffDirs(oldDir, filepath.Join(root, "missing"), 3, false); err == nil {
+		t.Error("expected an error for a missing directory")
+	}
+}
+
+func TestLineDiff(t *testing.T) {
+	tests := []struct {
+		a, b           string
+		deleted, added int
+	}{
+		{"abc", "abc", 0, 0},
+		{"", "abc", 0, 3},
+		{"abc", "", 3, 0},
+		{"abcabba", "cbabac", 3, 2}, // the example from Myers' paper
+		{"xaxbx", "yaybyy", 3, 4},
+	}
+	for _, tt := range tests {
+		deleted, added := lineDiff(strings.Split(tt.a, ""), strings.Split(tt.b, ""), func(s string) string { return s })
+		var d, a int
+		for _, v := range deleted {
+			if v {
+				d++
+			}
+		}
+		for _, v := range added {
+			if v {
+				a++
+			}
+		}
+		if d != tt.deleted || a != tt.added {
+			t.Errorf("lineDiff(%q, %q): %d deleted, %d added; want %d and %d", tt.a, tt.b, d, a, tt.deleted, tt.added)
+		}
+	}
+}
+
+const combinedDiff = `diff --git a/README b/README
+index 1111111..2222222 100644
+--- a/README
++++ b/README
+@@ -1 +1 @@
+-old
++new
+diff --cc f.go
+index 0d15633,4621df6..850f58e
+--- a/f.go
++++ b/f.go
+@@@ -1,5 -1,5 +1,5 @@@
+  a
+- B-main
+ -B-side
+++B-merged
+  c
+  d
+ -e
+ +E
+diff --combined "sp\303\251cial.txt"
+index 1234567,89abcde..fedcba9
+--- "a/sp\303\251cial.txt"
++++ "b/sp\303\251cial.txt"
+@@@ -1 -1 +1 @@@
+- x
+ -y
+++z
+\ No newline at end of file
+`
+
+func TestParseCombined(t *testing.T) {
+	ds, err := Parse(combinedDiff)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if len(ds.Files) != 3 {
+		t.Fatalf("expected 3 files, got %d", len(ds.Files))
+	}
+	if ds.Files[0].Name() != "README" || ds.Files[0].Parents != 0 {
+		t.Errorf("expected the ordinary diff first, got %s", ds.Files[0].Name())
+	}
+
+	f := ds.Files[1]
+	if f.Name() != "f.go" || f.Parents != 2 || len(f.Combined) != 1 || f.OldOID != "0d15633" || f.NewOID != "850f58e" {
+		t.Fatalf("unexpected combined file %+v", f)
+	}
+	cf := f.Combined[0]
+	if len(cf.Lines) != 8 || cf.OldPositions[1] != 1 || cf.NewLines != 5 {
+		t.Errorf("unexpected combined hunk %+v", cf)
+	}
+	if ops := cf.Lines[2].Ops; ops[0] != gitdiff.OpContext || ops[1] != gitdiff.OpDelete || !cf.Lines[2].Removed() {
+		t.Errorf("expected B-side to be removed from the second parent, got %v", ops)
+	}
+	if ops := cf.Lines[3].Ops; ops[0] != gitdiff.OpAdd || ops[1] != gitdiff.OpAdd || cf.Lines[3].Removed() {
+		t.Errorf("expected B-merged to be new against both parents, got %v", ops)
+	}
+
+	// Against the first parent: B-main became B-merged, and E came from
+	// the second parent
+	frag := f.Fragments[0]
+	var got []string
+	for _, l := range frag.Lines {
+		got = append(got, l.Op.String()+strings.TrimSuffix(l.Line, "\n"))
+	}
+	want := []string{" a", "-B-main", "+B-merged", " c", " d", " E"}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("first-parent lines = %q, want %q", got, want)
+	}
+	if frag.OldLines != 5 || frag.NewLines != 5 || f.AddedLines != 1 || f.DeletedLines != 1 {
+		t.Errorf("unexpected first-parent counts %+v", frag)
+	}
+
+	f = ds.Files[2]
+	if f.Name() != "spécial.txt" || len(f.Fragments) != 1 {
+		t.Fatalf("unexpected quoted combined file %q", f.Name())
+	}
+	if last := f.Combined[0].Lines[2]; last.Line != "z" {
+		t.Errorf("expected the last line without its newline, got %q", last.Line)
+	}
+
+	if _, err := Parse("diff --cc f\n--- a/f\n+++ b/f\n@@@ -1,2 -1,2 +1,2 @@@\n  a\n"); err == nil {
+		t.Error("expected an error for a truncated hunk")
+	}
+}
+
+func TestGitCommitsMerge(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	dir := t.TempDir()
+	run := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		cmd.Env = append(os.Environ(),
+			"GIT_AUTHOR_NAME=Test", "GIT_AUTHOR_EMAIL=test@example.com",
+			"GIT_COMMITTER_NAME=Test", "GIT_COMMITTER_EMAIL=test@example.com")
+		cmd.CombinedOutput() // the conflicting merge fails on purpose
+	}
+	write := func(name, content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	run("init", "-q", "-b", "main")
+	write("f.txt", "a\nb\nc\n")
+	run("add", ".")
+	run("commit", "-q", "-m", "base")
+	run("checkout", "-q", "-b", "side")
+	write("f.txt", "a\nside\nc\n")
+	run("commit", "-q", "-am", "Side change")
+	run("checkout", "-q", "main")
+	write("f.txt", "a\nmain\nc\n")
+	run("commit", "-q", "-am", "Main change")
+	run("merge", "-q", "side")
+	write("f.txt", "a\nresolved\nc\n")
+	run("commit", "-q", "-am", "Merge side")
+
+	commits, err := GitCommits(dir, "HEAD~1..HEAD", 3)
+	if err != nil {
+		t.Fatalf("GitCommits failed: %v", err)
+	}
+	if len(commits) != 2 {
+		t.Fatalf("expected the side commit and the merge, got %d commits", len(commits))
+	}
+	merge := commits[1]
+	if merge.Subject != "Merge side" || len(merge.Diff.Files) != 1 || merge.Diff.Files[0].Parents != 2 {
+		t.Fatalf("expected the merge with a combined diff, got %+v", merge)
+	}
+	if f := merge.Diff.Files[0]; f.AddedLines != 1 || f.DeletedLines != 1 {
+		t.Errorf("expected main → resolved against the first parent, got +%d -%d", f.AddedLines, f.DeletedLines)
+	}
+}
+
+func TestScopes(t *testing.T) {
+	// Git names the function above the hunk, but the change is in the one
+	// starting in its leading context
+	const raw = `diff --git a/main.go b/main.go
+--- a/main.go
++++ b/main.go
+@@ -10,5 +10,5 @@ func helper() {
+ 
+ func (s *Server) Start(
+ 	ctx context.Context,
+ ) error {
+-	return nil
++	return s.listen(ctx)
+@@ -30 +30,2 @@ func (s *Server) Stop() {
+ var x = 1
++var y = 2
+`
+	ds, err := Parse(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := ds.Files[0]
+	if got := f.Fragments[0].Comment; got != "func (s *Server) Start(" {
+		t.Errorf("expected the multi-line signature's first line, got %q", got)
+	}
+	if got := f.Fragments[1].Comment; got != "func (s *Server) Stop() {" {
+		t.Errorf("expected git's context kept for a top-level change, got %q", got)
+	}
+	if got := f.ScopeAt(14); got != "func (s *Server) Start(" {
+		t.Errorf("ScopeAt(14) = %q", got)
+	}
+	if got := f.ScopeAt(31); got != "" {
+		t.Errorf("expected no scope for a top-level line, got %q", got)
+	}
+
+	// With the old content, a method is found even when git named its class
+	py := &File{OldName: "app.py", NewName: "app.py", Fragments: []*gitdiff.TextFragment{{
+		OldPosition: 5, OldLines: 2, NewPosition: 5, NewLines: 2, Comment: "class Cart:",
+		Lines: []gitdiff.Line{
+			{Op: gitdiff.OpContext, Line: "        total = 0\n"},
+			{Op: gitdiff.OpDelete, Line: "        return total\n"},
+			{Op: gitdiff.OpAdd, Line: "        return round(total, 2)\n"},
+		},
+	}}}
+	py.fillScopes([]string{
+		"class Cart:",
+		"    def __init__(self):",
+		"        self.items = []",
+		"    def total(self):",
+		"        total = 0",
+		"        return total",
+	})
+	if got := py.Fragments[0].Comment; got != "def total(self):" {
+		t.Errorf("expected the method, got %q", got)
+	}
+	if got := py.ScopeAt(6); got != "def total(self)" {
+		t.Errorf("expected the method without its colon, got %q", got)
+	}
+
+	// Directory diffs get the same headers
+	root := t.TempDir()
+	for dir, body := range map[string]string{"a": "\treturn 1\n", "b": "\treturn 2\n"} {
+		os.Mkdir(filepath.Join(root, dir), 0755)
+		os.WriteFile(filepath.Join(root, dir, "x.go"), []byte("package x\n\nfunc One() int {\n"+body+"}\n"), 0644)
+	}
+	out, err := DiffDirs(filepath.Join(root, "a"), filepath.Join(root, "b"), 0, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out, "@@ -4 +4 @@ func One() int {\n") {
+		t.Errorf("expected the function in the hunk header:\n%s", out)
+	}
+}
+
+func TestStatsBy(t *testing.T) {
+	ds := &DiffSet{Files: []*File{
+		{OldName: "web/dist/app.js", NewName: "web/dist/app.js", AddedLines: 90, DeletedLines: 10},
+		{OldName: "web/dist/vendor.js", NewName: "web/dist/vendor.js", IsNew: true, AddedLines: 50},
+		{OldName: "cmd/main.go", NewName: "cmd/main.go", AddedLines: 8, DeletedLines: 2},
+		{OldName: "old.go", NewName: "old.go", IsDeleted: true, DeletedLines: 4},
+		{OldName: "LICENSE.unknownext", NewName: "LICENSE.unknownext", AddedLines: 1},
+	}}
+
+	langs := ds.StatsByLanguage()
+	want := []GroupStats{
+		{Name: "JavaScript", Files: 2, Added: 140, Deleted: 10},
+		{Name: "Go", Files: 2, Added: 8, Deleted: 6},
+		{Name: "Other", Files: 1, Added: 1},
+	}
+	if fmt.Sprint(langs) != fmt.Sprint(want) {
+		t.Errorf("StatsByLanguage() = %v, want %v", langs, want)
+	}
+
+	dirs := ds.StatsByDir()
+	want = []GroupStats{
+		{Name: "web/dist", Files: 2, Added: 140, Deleted: 10},
+		{Name: "cmd", Files: 1, Added: 8, Deleted: 2},
+		{Name: ".", Files: 2, Added: 1, Deleted: 4},
+	}
+	if fmt.Sprint(dirs) != fmt.Sprint(want) {
+		t.Errorf("StatsByDir() = %v, want %v", dirs, want)
+	}
+}
+
+func TestSplitFragment(t *testing.T) {
+	const old = "package main\n\nfunc one() int {\n\treturn 1\n}\n\nfunc two() int {\n\treturn 2\n}\n"
+	ds, err := Parse(`diff --git a/m.go b/m.go
+index abc1234..def5678 100644
+--- a/m.go
++++ b/m.go
+@@ -1,9 +1,10 @@
+ package main
+ 
+ func one() int {
+-	return 1
++	// ten
++	return 10
+ }
+ 
+ func two() int {
+-	return 2
++	return 20
+ }
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := ds.Files[0]
+	frag := f.Fragments[0]
+
+	pieces := SplitFragment(frag)
+	if len(pieces) != 2 {
+		t.Fatalf("got %d pieces, want 2", len(pieces))
+	}
+	for i, want := range []string{"@@ -1,7 +1,8 @@", "@@ -5,5 +6,5 @@"} {
+		if got := pieces[i].Header(); !strings.HasPrefix(got, want) {
+			t.Errorf("piece %d header = %q, want %q", i, got, want)
+		}
+	}
+	if n := len(SplitFragment(pieces[0])); n != 1 {
+		t.Errorf("a single run of changes split into %d", n)
+	}
+
+	// Each selection of pieces applies, and gives the changes it holds
+	apply := func(frags []*gitdiff.TextFragment) string {
+		var b strings.Builder
+		if err := gitdiff.Apply(&b, strings.NewReader(old), &gitdiff.File{TextFragments: frags}); err != nil {
+			t.Fatalf("applying %d hunk(s): %v", len(frags), err)
+		}
+		return b.String()
+	}
+	cases := []struct {
+		keep       []int
+		want       string
+		newHeaders []string
+	}{
+		{[]int{0}, "package main\n\nfunc one() int {\n\t// ten\n\treturn 10\n}\n\nfunc two() int {\n\treturn 2\n}\n", []string{"@@ -1,7 +1,8 @@"}},
+		{[]int{1}, "package main\n\nfunc one() int {\n\treturn 1\n}\n\nfunc two() int {\n\treturn 20\n}\n", []string{"@@ -5,5 +5,5 @@"}},
+		{[]int{0, 1}, "package main\n\nfunc one() int {\n\t// ten\n\treturn 10\n}\n\nfunc two() int {\n\treturn 20\n}\n", []string{"@@ -1,9 +1,10 @@"}},
+	}
+	for _, c := range cases {
+		var selected []*gitdiff.TextFragment
+		for _, k := range c.keep {
+			selected = append(selected, pieces[k])
+		}
+		joined := JoinFragments(selected)
+		if got := apply(joined); got != c.want {
+			t.Errorf("keeping %v gave:\n%s\nwant:\n%s", c.keep, got, c.want)
+		}
+		var headers []string
+		for _, j := range joined {
+			headers = append(headers, fmt.Sprintf("@@ -%d,%d +%d,%d @@", j.OldPosition, j.OldLines, j.NewPosition, j.NewLines))
+		}
+		if fmt.Sprint(headers) != fmt.Sprint(c.newHeaders) {
+			t.Errorf("keeping %v gave headers %v, want %v", c.keep, headers, c.newHeaders)
+		}
+	}
+	if pieces[1].NewPosition != 6 {
+		t.Errorf("JoinFragments changed its input: NewPosition = %d", pieces[1].NewPosition)
+	}
+
+	if n := f.SplitHunk(0); n != 2 || len(f.Fragments) != 2 {
+		t.Fatalf("SplitHunk = %d, leaving %d hunks", n, len(f.Fragments))
+	}
+	if f.Fragments[1].Comment != "func two() int {" {
+		t.Errorf("second hunk's scope = %q", f.Fragments[1].Comment)
+	}
+	if n := f.SplitHunk(1); n != 1 {
+		t.Errorf("splitting an unsplittable hunk = %d, want 1", n)
+	}
+	if n := f.SplitHunk(5); n != 0 {
+		t.Errorf("splitting a missing hunk = %d, want 0", n)
+	}
+}
+
+func TestParseCommits(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	dir := t.TempDir()
+	git := func(args ...string) string {
+		t.Helper()
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		cmd.Env = append(os.Environ(),
+			"GIT_AUTHOR_NAME=Test", "GIT_AUTHOR_EMAIL=test@example.com",
+			"GIT_COMMITTER_NAME=Test", "GIT_COMMITTER_EMAIL=test@example.com")
+		out, err := cmd.Output()
+		if err != nil {
+			t.Fatalf("git %v: %v", args, err)
+		}
+		return string(out)
+	}
+	write := func(name, content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	git("init", "-q")
+	write("a.txt", "one\n")
+	git("add", ".")
+	git("commit", "-q", "-m", "base")
+	write("a.txt", "one\ntwo\n")
+	git("commit", "-q", "-am", "Add two", "-m", "Longer explanation.\n\nFrom the second paragraph.")
+	write("b.txt", "new\n")
+	git("add", ".")
+	git("commit", "-q", "-m", "Add b")
+
+	want, err := GitCommits(dir, "HEAD~2..HEAD", 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, format := range [][]string{
+		{"log", "-p", "--reverse", "HEAD~2..HEAD"},
+		{"log", "-p", "--reverse", "--decorate", "--abbrev-commit", "HEAD~2..HEAD"},
+		{"format-patch", "--stdout", "HEAD~2..HEAD"},
+	} {
+		commits, err := ParseCommits(git(format...))
+		if err != nil {
+			t.Fatalf("%v: %v", format, err)
+		}
+		if len(commits) != len(want) {
+			t.Fatalf("%v: got %d commits, want %d", format, len(commits), len(want))
+		}
+		for i, c := range commits {
+			w := want[i]
+			if !strings.HasPrefix(w.Hash, c.Hash) || c.Author != w.Author || !c.Date.Equal(w.Date) ||
+				c.Subject != w.Subject || c.Body != w.Body {
+				t.Errorf("%v: commit %d = %+v, want %+v", format, i, c, w)
+			}
+			if len(c.Diff.Files) != 1 || c.Diff.Files[0].Name() != w.Diff.Files[0].Name() {
+				t.Errorf("%v: commit %d changes the wrong files", format, i)
+			}
+		}
+	}
+
+	// A plain diff names no commits
+	if commits, err := ParseCommits(sampleDiff); err != nil || len(commits) != 0 {
+		t.Errorf("ParseCommits(plain diff) = %d commits, %v", len(commits), err)
+	}
+}
+
+func TestPairRenames(t *testing.T) {
+	var old, new strings.Builder
+	for i := 1; i <= 10; i++ {
+		fmt.Fprintf(&old, "line %d\n", i)
+		if i == 4 || i == 8 {
+			fmt.Fprintf(&new, "changed %d\n", i)
+		} else {
+			fmt.Fprintf(&new, "line %d\n", i)
+		}
+	}
+	whole := func(op byte, s string) string {
+		var b strings.Builder
+		for _, l := range strings.SplitAfter(strings.TrimSuffix(s, "\n"), "\n") {
+			b.WriteString(string(op) + strings.TrimSuffix(l, "\n") + "\n")
+		}
+		return b.String()
+	}
+	raw := "diff --git a/old/util.go b/old/util.go\ndeleted file mode 100644\nindex 1111111..0000000\n--- a/old/util.go\n+++ /dev/null\n@@ -1,10 +0,0 @@\n" + whole('-', old.String()) +
+		"diff --git a/gone.txt b/gone.txt\ndeleted file mode 100644\n--- a/gone.txt\n+++ /dev/null\n@@ -1,2 +0,0 @@\n-nothing\n-alike\n" +
+		"diff --git a/unrelated.txt b/unrelated.txt\nnew file mode 100644\n--- /dev/null\n+++ b/unrelated.txt\n@@ -0,0 +1,2 @@\n+something\n+else\n" +
+		"diff --git a/new/util.go b/new/util.go\nnew file mode 100644\nindex 0000000..2222222\n--- /dev/null\n+++ b/new/util.go\n@@ -0,0 +1,10 @@\n" + whole('+', new.String())
+
+	ds, err := Parse(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, f := range ds.Files {
+		names = append(names, f.Name())
+	}
+	if want := []string{"old/util.go → new/util.go", "gone.txt", "unrelated.txt"}; fmt.Sprint(names) != fmt.Sprint(want) {
+		t.Fatalf("files = %q, want %q", names, want)
+	}
+
+	f := ds.Files[0]
+	if !f.IsRenamed || !f.Paired || f.IsNew || f.IsDeleted || f.Similarity != 80 {
+		t.Errorf("pair = %+v, want an 80%% rename", f)
+	}
+	if f.OldOID != "1111111" || f.NewOID != "2222222" || f.AddedLines != 2 || f.DeletedLines != 2 {
+		t.Errorf("pair = %+v, want both blobs and 2 lines changed", f)
+	}
+	var b strings.Builder
+	if err := gitdiff.Apply(&b, strings.NewReader(old.String()), &gitdiff.File{TextFragments: f.Fragments}); err != nil {
+		t.Fatal(err)
+	}
+	if b.String() != new.String() {
+		t.Errorf("the pair's hunks give:\n%s", b.String())
+	}
+	if len(ds.Moves) != 0 {
+		t.Errorf("the paired files were also reported as moves: %+v", ds.Moves)
+	}
+
+	// Renames git found keep its similarity index
+	ds, err = Parse("diff --git a/a.txt b/b.txt\nsimilarity index 90%\nrename from a.txt\nrename to b.txt\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f := ds.Files[0]; f.Similarity != 90 || f.Paired {
+		t.Errorf("git rename = %+v, want similarity 90", f)
+	}
+}
+
+func TestDeclChanges(t *testing.T) {
+	oldGo := `package config
+
+import "os"
+
+// Config holds the settings.
+type Config struct {
+	Name    string
+	Retries int
+	Debug   bool ` + "`json:\"debug\"`" + `
+}
+
+type Loader interface {
+	Load() (*Config, error)
+}
+
+const defaultName = "agrev"
+
+func parseFoo(s string) int {
+	return len(s)
+}
+
+func (c *Config) Validate() error {
+	return nil
+}
+
+func unused() {}
+`
+	newGo := `package config
+
+import (
+	"os"
+	"time"
+)
+
+// Config holds the settings, now documented differently.
+type Config struct {
+	Name    string
+	Debug   bool ` + "`json:\"debug,omitempty\"`" + `
+	Timeout time.Duration
+}
+
+type Loader interface {
+	Load() (*Config, error)
+	Close() error
+}
+
+const defaultName = "agrev"
+
+func parseFoo(s string) int {
+	// Count bytes
+	return len(s) + 1
+}
+
+func (c *Config) Validate(strict bool) error {
+	return nil
+}
+
+func added() {}
+`
+	changes, ok := DeclChanges("config.go", []byte(oldGo), []byte(newGo))
+	if !ok {
+		t.Fatal("Go not supported")
+	}
+	var got []string
+	for _, c := range changes {
+		got = append(got, c.String())
+	}
+	want := []string{
+		`import "time" added`,
+		"type Config modified: field Debug changed, field Timeout added, field Retries removed",
+		"type Loader modified: method Close added",
+		"function parseFoo modified: body changed",
+		"method Config.Validate modified: signature changed",
+		"function added added",
+		"function unused removed",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Go changes:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	// Comments and formatting alone change nothing
+	reformatted := strings.ReplaceAll(oldGo, "return len(s)", "return len( s ) // why")
+	if changes, _ := DeclChanges("config.go", []byte(oldGo), []byte(reformatted)); len(changes) != 0 {
+		t.Errorf("reformatting reported %v", changes)
+	}
+
+	oldPy := `class Server:
+    def start(self):
+        self.running = True
+
+    def stop(self):
+        self.running = False
+
+def helper(x):
+    return x
+`
+	newPy := `class Server:
+    def start(self, port):
+        self.running = True
+
+    def stop(self):
+        self.running  =  False
+        self.port = None
+
+def main():
+    Server().start(80)
+`
+	changes, _ = DeclChanges("server.py", []byte(oldPy), []byte(newPy))
+	got = nil
+	for _, c := range changes {
+		got = append(got, c.String())
+	}
+	want = []string{
+		"method Server.start modified: signature changed",
+		"method Server.stop modified: body changed",
+		"function main added",
+		"function helper removed",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Python changes:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	// A new file's declarations are all added; unknown languages aren't read
+	if changes, _ := DeclChanges("server.py", nil, []byte(oldPy)); len(changes) != 4 || changes[0].String() != "class Server added" {
+		t.Errorf("new file: %v", changes)
+	}
+	if _, ok := DeclChanges("notes.txt", nil, []byte("x")); ok {
+		t.Error("expected plain text to be unsupported")
+	}
+}
+
+func TestCombineRepos(t *testing.T) {
+	root := t.TempDir()
+	api, web := filepath.Join(root, "api"), filepath.Join(root, "services", "web")
+	got, repos, err := Repos([]string{api, web})
+	if err != nil {
+		t.Fatalf("Repos failed: %v", err)
+	}
+	if got != root || len(repos) != 2 || repos[0].Name != "api" || repos[1].Name != "services/web" || repos[1].Dir != web {
+		t.Fatalf("unexpected root %q and repos %+v", got, repos)
+	}
+	if _, repos, _ := Repos([]string{api}); repos[0].Name != "api" {
+		t.Errorf("expected a single repository named after its directory, got %+v", repos)
+	}
+	if _, _, err := Repos([]string{api, api + "/"}); err == nil {
+		t.Error("expected an error for a repository given twice")
+	}
+	if _, _, err := Repos([]string{root, api}); err == nil {
+		t.Error("expected an error for nested repositories")
+	}
+
+	one, _ := Parse(sampleDiff)
+	two, _ := Parse(sampleDiff)
+	ds := Combine(repos, []*DiffSet{one, two})
+	if len(ds.Files) != 4 || ds.Files[0].Name() != "api/hello.go" || ds.Files[3].Name() != "services/web/readme.md" {
+		t.Fatalf("expected the files listed under their repositories, got %d files", len(ds.Files))
+	}
+	if f := ds.Files[0]; f.OldName != "" || !f.IsNew {
+		t.Errorf("expected a new file to keep its empty old name, got %+v", f)
+	}
+
+	r, name, ok := ds.Repo("services/web/readme.md")
+	if !ok || r.Name != "services/web" || name != "readme.md" {
+		t.Errorf("unexpected repository %+v, %q, %v", r, name, ok)
+	}
+	f, ok := InRepo(ds.Files[3], r)
+	if !ok || f.Name() != "readme.md" || ds.Files[3].Name() != "services/web/readme.md" {
+		t.Errorf("expected a renamed copy, got %+v", f)
+	}
+	if _, ok := InRepo(ds.Files[0], r); ok {
+		t.Error("expected api/hello.go to be outside services/web")
+	}
+}
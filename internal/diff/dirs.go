@@ -0,0 +1,496 @@
+package diff
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Git's file modes, as written in diff headers.
+const (
+	modeFile    = 0o100644
+	modeExec    = 0o100755
+	modeSymlink = 0o120000
+)
+
+// dirEntry is a file found under one of the directories being compared.
+type dirEntry struct {
+	path string // on disk
+	mode int
+}
+
+// DiffDirs compares two directory trees and returns a unified diff in git's
+// format, without needing git or a repository: for reviewing agent output
+// from a sandbox or an exported workspace. Paths in the diff are relative to
+// each directory, .git directories are skipped, and renames show up as a
+// deletion and an addition. With ignoreWhitespace, lines that differ only in
+// whitespace compare equal, as with git diff -w.
+func DiffDirs(oldDir, newDir string, contextLines int, ignoreWhitespace bool) (string, error) {
+	oldFiles, err := walkDir(oldDir)
+	if err != nil {
+		return "", err
+	}
+	newFiles, err := walkDir(newDir)
+	if err != nil {
+		return "", err
+	}
+
+	var names []string
+	for name := range oldFiles {
+		names = append(names, name)
+	}
+	for name := range newFiles {
+		if _, ok := oldFiles[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+
+	var b strings.Builder
+	for _, name := range names {
+		oldEntry, inOld := oldFiles[name]
+		newEntry, inNew := newFiles[name]
+		var oldData, newData []byte
+		if inOld {
+			if oldData, err = readEntry(oldEntry); err != nil {
+				return "", err
+			}
+		}
+		if inNew {
+			if newData, err = readEntry(newEntry); err != nil {
+				return "", err
+			}
+		}
+
+		switch {
+		case !inNew:
+			writeFileDiff(&b, name, oldEntry.mode, 0, oldData, nil, contextLines, ignoreWhitespace)
+		case !inOld:
+			writeFileDiff(&b, name, 0, newEntry.mode, nil, newData, contextLines, ignoreWhitespace)
+		case (oldEntry.mode == modeSymlink) != (newEntry.mode == modeSymlink):
+			// A file replaced by a symlink (or the reverse) can't be one diff
+			writeFileDiff(&b, name, oldEntry.mode, 0, oldData, nil, contextLines, ignoreWhitespace)
+			writeFileDiff(&b, name, 0, newEntry.mode, nil, newData, contextLines, ignoreWhitespace)
+		default:
+			writeFileDiff(&b, name, oldEntry.mode, newEntry.mode, oldData, newData, contextLines, ignoreWhitespace)
+		}
+	}
+	return b.String(), nil
+}
+
+// walkDir lists the regular files and symlinks under dir by slash-separated
+// path relative to it.
+func walkDir(dir string) (map[string]dirEntry, error) {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("%s is not a directory", dir)
+	}
+
+	files := make(map[string]dirEntry)
+	err = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if d.Name() == ".git" && path != dir {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+
+		var mode int
+		switch {
+		case d.Type()&fs.ModeSymlink != 0:
+			mode = modeSymlink
+		case d.Type().IsRegular():
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			mode = modeFile
+			if info.Mode()&0o111 != 0 {
+				mode = modeExec
+			}
+		default:
+			return nil // sockets, devices, and the like
+		}
+		files[filepath.ToSlash(rel)] = dirEntry{path: path, mode: mode}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", dir, err)
+	}
+	return files, nil
+}
+
+// readEntry returns a file's content, or a symlink's target as git stores it.
+func readEntry(e dirEntry) ([]byte, error) {
+	if e.mode == modeSymlink {
+		target, err := os.Readlink(e.path)
+		if err != nil {
+			return nil, err
+		}
+		return []byte(filepath.ToSlash(target)), nil
+	}
+	return os.ReadFile(e.path)
+}
+
+// writeFileDiff writes the diff for one file, the way git diff would. A zero
+// oldMode means the file is new, a zero newMode that it was deleted.
+func writeFileDiff(b *strings.Builder, name string, oldMode, newMode int, oldData, newData []byte, contextLines int, ignoreWhitespace bool) {
+	modeChanged := oldMode != 0 && newMode != 0 && oldMode != newMode
+	if oldMode != 0 && newMode != 0 && !modeChanged && bytes.Equal(oldData, newData) {
+		return
+	}
+
+	binary := isBinary(oldData) || isBinary(newData)
+	var hunks string
+	if !binary {
+		hunks = unifiedHunks(name, splitLinesKeepEnds(oldData), splitLinesKeepEnds(newData), contextLines, ignoreWhitespace)
+		if hunks == "" && oldMode != 0 && newMode != 0 && !modeChanged {
+			return // only whitespace changed, and that's being ignored
+		}
+	}
+
+	oldName, newName := quoteName("a/"+name), quoteName("b/"+name)
+	fmt.Fprintf(b, "diff --git %s %s\n", oldName, newName)
+	switch {
+	case oldMode == 0:
+		fmt.Fprintf(b, "new file mode %o\n", newMode)
+	case newMode == 0:
+		fmt.Fprintf(b, "deleted file mode %o\n", oldMode)
+	case modeChanged:
+		fmt.Fprintf(b, "old mode %o\nnew mode %o\n", oldMode, newMode)
+	}
+	if bytes.Equal(oldData, newData) && oldMode != 0 && newMode != 0 {
+		return // a mode change alone
+	}
+
+	oldOID, newOID := "0000000", "0000000"
+	if oldMode != 0 {
+		oldOID = blobOID(oldData)
+	}
+	if newMode != 0 {
+		newOID = blobOID(newData)
+	}
+	if oldMode == newMode {
+		fmt.Fprintf(b, "index %s..%s %o\n", oldOID, newOID, newMode)
+	} else {
+		fmt.Fprintf(b, "index %s..%s\n", oldOID, newOID)
+	}
+
+	if oldMode == 0 {
+		oldName = "/dev/null"
+	}
+	if newMode == 0 {
+		newName = "/dev/null"
+	}
+	switch {
+	case binary:
+		fmt.Fprintf(b, "Binary files %s and %s differ\n", oldName, newName)
+	case hunks != "":
+		fmt.Fprintf(b, "--- %s%s\n+++ %s%s\n", oldName, nameTab(oldName), newName, nameTab(newName))
+		b.WriteString(hunks)
+	}
+}
+
+// blobOID returns the abbreviated object ID git would give data as a blob.
+func blobOID(data []byte) string {
+	h := sha1.New()
+	fmt.Fprintf(h, "blob %d\x00", len(data))
+	h.Write(data)
+	return hex.EncodeToString(h.Sum(nil))[:7]
+}
+
+// isBinary uses git's heuristic: a NUL byte in the first 8000 bytes.
+func isBinary(data []byte) bool {
+	return bytes.IndexByte(data[:min(len(data), 8000)], 0) >= 0
+}
+
+// quoteName quotes a path the way git does when it holds quotes,
+// backslashes, control characters, or non-ASCII bytes.
+func quoteName(name string) string {
+	if !strings.ContainsFunc(name, func(r rune) bool { return r < ' ' || r == '"' || r == '\\' || r >= 0x7f }) {
+		return name
+	}
+	var b strings.Builder
+	b.WriteByte('"')
+	for i := 0; i < len(name); i++ {
+		switch c := name[i]; {
+		case c == '"' || c == '\\':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case c == '\t':
+			b.WriteString(`\t`)
+		case c == '\n':
+			b.WriteString(`\n`)
+		case c < ' ' || c >= 0x7f:
+			fmt.Fprintf(&b, `\%03o`, c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// nameTab returns the tab git puts after ---/+++ names containing a space,
+// so the name's end is unambiguous.
+func nameTab(name string) string {
+	if strings.Contains(name, " ") && !strings.HasPrefix(name, `"`) {
+		return "\t"
+	}
+	return ""
+}
+
+// splitLinesKeepEnds splits data into lines, each keeping its newline. Only
+// the last line can lack one.
+func splitLinesKeepEnds(data []byte) []string {
+	var lines []string
+	for len(data) > 0 {
+		i := bytes.IndexByte(data, '\n') + 1
+		if i == 0 {
+			i = len(data)
+		}
+		lines = append(lines, string(data[:i]))
+		data = data[i:]
+	}
+	return lines
+}
+
+// unifiedHunks diffs two of name's lines and formats the changes as unified
+// diff hunks with contextLines lines of context, each headed by the scope
+// its first change is in. It returns "" when the files compare equal.
+func unifiedHunks(name string, a, b []string, contextLines int, ignoreWhitespace bool) string {
+	key := func(line string) string { return line }
+	if ignoreWhitespace {
+		key = func(line string) string { return strings.Join(strings.Fields(line), "") }
+	}
+	deleted, added := lineDiff(a, b, key)
+
+	// The edit script: deletions before additions within each change
+	type edit struct {
+		op   byte // ' ', '-', or '+'
+		i, j int  // lines of a and b before this edit
+	}
+	var edits []edit
+	for i, j := 0, 0; i < len(a) || j < len(b); {
+		switch {
+		case i < len(a) && deleted[i]:
+			edits = append(edits, edit{'-', i, j})
+			i++
+		case j < len(b) && added[j]:
+			edits = append(edits, edit{'+', i, j})
+			j++
+		default:
+			edits = append(edits, edit{' ', i, j})
+			i++
+			j++
+		}
+	}
+
+	var out strings.Builder
+	for start := 0; start < len(edits); {
+		if edits[start].op == ' ' {
+			start++
+			continue
+		}
+		// Extend the hunk while the next change is close enough that
+		// their context would touch
+		end := start + 1
+		for k := end; k < len(edits); k++ {
+			if edits[k].op != ' ' {
+				if k-end > 2*contextLines {
+					break
+				}
+				end = k + 1
+			}
+		}
+		from, to := max(start-contextLines, 0), min(end+contextLines, len(edits))
+
+		var oldLen, newLen int
+		for _, e := range edits[from:to] {
+			if e.op != '+' {
+				oldLen++
+			}
+			if e.op != '-' {
+				newLen++
+			}
+		}
+		first := edits[from]
+		fmt.Fprintf(&out, "@@ -%s +%s @@", hunkRange(first.i, oldLen), hunkRange(first.j, newLen))
+		if isScope := scopeMatcher(name); isScope != nil {
+			for _, e := range edits[start:end] {
+				if e.op == ' ' {
+					continue
+				}
+				line := b[e.j]
+				if e.op == '-' {
+					line = a[e.i]
+				}
+				if !blank(line) {
+					if scope, _ := innermostScope(isScope, a[:e.i], indentOf(line)); scope != "" {
+						out.WriteString(" " + scope)
+					}
+					break
+				}
+			}
+		}
+		out.WriteByte('\n')
+		for _, e := range edits[from:to] {
+			// Context comes from the new side, which matters only when
+			// whitespace is ignored
+			var line string
+			if e.op == '-' {
+				line = a[e.i]
+			} else {
+				line = b[e.j]
+			}
+			out.WriteByte(e.op)
+			out.WriteString(line)
+			if !strings.HasSuffix(line, "\n") {
+				out.WriteString("\n\\ No newline at end of file\n")
+			}
+		}
+		start = to
+	}
+	return out.String()
+}
+
+// hunkRange formats one side of a hunk header: the first line and the
+// number of lines, or for an empty side the line before it.
+func hunkRange(before, n int) string {
+	switch n {
+	case 0:
+		return fmt.Sprintf("%d,0", before)
+	case 1:
+		return fmt.Sprint(before + 1)
+	}
+	return fmt.Sprintf("%d,%d", before+1, n)
+}
+
+// lineDiff finds a shortest edit script between a and b with Myers'
+// linear-space algorithm, comparing lines by key. It reports which lines of
+// a are deleted and which lines of b are added.
+func lineDiff(a, b []string, key func(string) string) (deleted, added []bool) {
+	// Compare small integers rather than strings
+	ids := make(map[string]int)
+	intern := func(lines []string) []int {
+		out := make([]int, len(lines))
+		for i, l := range lines {
+			k := key(l)
+			id, ok := ids[k]
+			if !ok {
+				id = len(ids)
+				ids[k] = id
+			}
+			out[i] = id
+		}
+		return out
+	}
+	d := &differ{a: intern(a), b: intern(b), deleted: make([]bool, len(a)), added: make([]bool, len(b))}
+	d.compare(0, len(a), 0, len(b))
+	return d.deleted, d.added
+}
+
+type differ struct {
+	a, b           []int
+	deleted, added []bool
+}
+
+// compare diffs a[aLo:aHi] with b[bLo:bHi], splitting the problem at the
+// middle snake of an optimal path until one side is empty.
+func (d *differ) compare(aLo, aHi, bLo, bHi int) {
+	for aLo < aHi && bLo < bHi && d.a[aLo] == d.b[bLo] {
+		aLo++
+		bLo++
+	}
+	for aLo < aHi && bLo < bHi && d.a[aHi-1] == d.b[bHi-1] {
+		aHi--
+		bHi--
+	}
+	switch {
+	case aLo == aHi:
+		for j := bLo; j < bHi; j++ {
+			d.added[j] = true
+		}
+	case bLo == bHi:
+		for i := aLo; i < aHi; i++ {
+			d.deleted[i] = true
+		}
+	default:
+		// With common ends trimmed and both sides non-empty, at least two
+		// edits remain, so each half is strictly smaller
+		x, y, u, v := d.middleSnake(aLo, aHi, bLo, bHi)
+		d.compare(aLo, x, bLo, y)
+		d.compare(u, aHi, v, bHi)
+	}
+}
+
+// middleSnake runs the search forward from the start and backward from the
+// end until the two meet, returning the start (x, y) and end (u, v) of the
+// diagonal run where they do.
+func (d *differ) middleSnake(aLo, aHi, bLo, bHi int) (x, y, u, v int) {
+	n, m := aHi-aLo, bHi-bLo
+	delta := n - m
+	odd := delta%2 != 0
+	limit := (n + m + 1) / 2
+	off := limit + 1
+	// Furthest x reached on each diagonal k = x - y, forward and backward
+	// (the backward search counts from the end)
+	vf := make([]int, 2*limit+3)
+	vb := make([]int, 2*limit+3)
+
+	for D := 0; D <= limit; D++ {
+		for k := -D; k <= D; k += 2 {
+			var x int
+			if k == -D || (k != D && vf[off+k-1] < vf[off+k+1]) {
+				x = vf[off+k+1]
+			} else {
+				x = vf[off+k-1] + 1
+			}
+			y := x - k
+			x0, y0 := x, y
+			for x < n && y < m && d.a[aLo+x] == d.b[bLo+y] {
+				x++
+				y++
+			}
+			vf[off+k] = x
+			if kb := delta - k; odd && kb >= -(D-1) && kb <= D-1 && x+vb[off+kb] >= n {
+				return aLo + x0, bLo + y0, aLo + x, bLo + y
+			}
+		}
+		for k := -D; k <= D; k += 2 {
+			var x int
+			if k == -D || (k != D && vb[off+k-1] < vb[off+k+1]) {
+				x = vb[off+k+1]
+			} else {
+				x = vb[off+k-1] + 1
+			}
+			y := x - k
+			x0, y0 := x, y
+			for x < n && y < m && d.a[aHi-1-x] == d.b[bHi-1-y] {
+				x++
+				y++
+			}
+			vb[off+k] = x
+			if kf := delta - k; !odd && kf >= -D && kf <= D && x+vf[off+kf] >= n {
+				return aHi - x, bHi - y, aHi - x0, bHi - y0
+			}
+		}
+	}
+	panic("diff: no middle snake")
+}
@@ -0,0 +1,217 @@
+package diff
+
+import (
+	"bytes"
+	"encoding/binary"
+	"slices"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"github.com/bluekeyes/go-gitdiff/gitdiff"
+)
+
+// Text encodings DecodeText recognizes.
+const (
+	EncodingUTF8    = "UTF-8"
+	EncodingUTF16LE = "UTF-16LE"
+	EncodingUTF16BE = "UTF-16BE"
+	EncodingLatin1  = "Latin-1"
+)
+
+// DecodeText returns data as UTF-8 text along with the encoding it was in.
+// UTF-16 is recognized by its byte order mark, or failing that by ASCII
+// text's alternating NUL bytes, and anything else that isn't valid UTF-8 is
+// read as Latin-1. It returns false for binary data.
+func DecodeText(data []byte) (string, string, bool) {
+	if enc := utf16Encoding(data); enc != "" {
+		if len(data)%2 != 0 {
+			return "", "", false
+		}
+		var order binary.ByteOrder = binary.LittleEndian
+		if enc == EncodingUTF16BE {
+			order = binary.BigEndian
+		}
+		units := make([]uint16, len(data)/2)
+		for i := range units {
+			units[i] = order.Uint16(data[2*i:])
+		}
+		text := strings.TrimPrefix(string(utf16.Decode(units)), "\ufeff")
+		if strings.ContainsRune(text, 0) {
+			return "", "", false
+		}
+		return text, enc, true
+	}
+	if bytes.IndexByte(data, 0) >= 0 {
+		return "", "", false
+	}
+	if utf8.Valid(data) {
+		return string(bytes.TrimPrefix(data, []byte("\ufeff"))), EncodingUTF8, true
+	}
+	return latin1(data), EncodingLatin1, true
+}
+
+// utf16Encoding returns EncodingUTF16LE or EncodingUTF16BE if data looks
+// like UTF-16, or "" if it doesn't.
+func utf16Encoding(data []byte) string {
+	switch {
+	case bytes.HasPrefix(data, []byte{0xff, 0xfe}):
+		return EncodingUTF16LE
+	case bytes.HasPrefix(data, []byte{0xfe, 0xff}):
+		return EncodingUTF16BE
+	}
+
+	// Without a byte order mark, ASCII characters leave every other byte NUL
+	sample := data[:min(len(data), 512)&^1]
+	if len(sample) < 4 {
+		return ""
+	}
+	var even, odd int
+	for i := 0; i < len(sample); i += 2 {
+		if sample[i] == 0 {
+			even++
+		}
+		if sample[i+1] == 0 {
+			odd++
+		}
+	}
+	half := len(sample) / 2
+	switch {
+	case odd*10 >= half*9 && even == 0:
+		return EncodingUTF16LE
+	case even*10 >= half*9 && odd == 0:
+		return EncodingUTF16BE
+	}
+	return ""
+}
+
+// DisplayText returns s as valid UTF-8 for display. Text that isn't UTF-8
+// is read as Latin-1, the usual encoding of older source files; the diff
+// itself keeps the original bytes.
+func DisplayText(s string) string {
+	if utf8.ValidString(s) {
+		return s
+	}
+	return latin1([]byte(s))
+}
+
+func latin1(data []byte) string {
+	runes := make([]rune, len(data))
+	for i, b := range data {
+		runes[i] = rune(b)
+	}
+	return string(runes)
+}
+
+// Line ending styles, as LineEndings reports them.
+const (
+	EndingLF    = "LF"
+	EndingCRLF  = "CRLF"
+	EndingMixed = "mixed"
+)
+
+// LineEndings names the line ending style of lines, which keep their
+// endings: EndingLF, EndingCRLF, EndingMixed, or "" when no line has one.
+func LineEndings(lines []string) string {
+	var lf, crlf int
+	for _, l := range lines {
+		switch {
+		case strings.HasSuffix(l, "\r\n"):
+			crlf++
+		case strings.HasSuffix(l, "\n"):
+			lf++
+		}
+	}
+	switch {
+	case lf > 0 && crlf > 0:
+		return EndingMixed
+	case crlf > 0:
+		return EndingCRLF
+	case lf > 0:
+		return EndingLF
+	}
+	return ""
+}
+
+// LineEndingChange reports whether f converts its lines wholesale from one
+// line ending style to the other, and between which. The lines the diff
+// shows must all use one style before and the other after; a context line,
+// which is the same on both sides, rules that out.
+func (f *File) LineEndingChange() (from, to string, ok bool) {
+	if f.IsNew || f.IsDeleted || f.IsBinary || len(f.Fragments) == 0 {
+		return "", "", false
+	}
+	from = LineEndings(sideLines(f, gitdiff.OpAdd))
+	to = LineEndings(sideLines(f, gitdiff.OpDelete))
+	if from == to || from == "" || to == "" || from == EndingMixed || to == EndingMixed {
+		return "", "", false
+	}
+	return from, to, true
+}
+
+// normalizePatch undoes a patch's own conversion to CRLF line endings, as
+// when it was saved or mailed on Windows: with the diff --git line ending
+// in CR LF, every CR LF loses its CR. Lines of CRLF files end in CR CR LF
+// in such a patch, so they keep theirs. Patches whose header ends in LF are
+// left alone, whatever the line endings of the files in them.
+func normalizePatch(raw string) string {
+	for _, marker := range []string{"diff --git ", "\n--- "} {
+		i := strings.Index(raw, marker)
+		if i < 0 {
+			continue
+		}
+		end := strings.IndexByte(raw[i+1:], '\n')
+		if end > 0 && raw[i+end] == '\r' {
+			return strings.ReplaceAll(raw, "\r\n", "\n")
+		}
+		return raw
+	}
+	return raw
+}
+
+// Decoded returns binary file f as a text file when its versions, old and
+// new (nil for a side that doesn't exist), are text git didn't recognize,
+// like UTF-16. The returned file's hunks are the changes between the
+// decoded versions, and enc names their encoding, as in "UTF-16LE" or,
+// when it changed, "UTF-16LE → UTF-8".
+func Decoded(f *File, old, new []byte) (decoded *File, enc string, ok bool) {
+	if !f.IsBinary {
+		return nil, "", false
+	}
+	var encs []string
+	var sides [2][]string
+	for i, data := range [][]byte{old, new} {
+		if data == nil {
+			continue
+		}
+		text, e, ok := DecodeText(data)
+		if !ok {
+			return nil, "", false
+		}
+		if len(encs) == 0 || encs[0] != e {
+			encs = append(encs, e)
+		}
+		sides[i] = strings.SplitAfter(text, "\n")
+		if sides[i][len(sides[i])-1] == "" {
+			sides[i] = sides[i][:len(sides[i])-1]
+		}
+	}
+	// Other text is binary only when .gitattributes says so
+	if !slices.ContainsFunc(encs, func(e string) bool { return strings.HasPrefix(e, "UTF-16") }) {
+		return nil, "", false
+	}
+
+	decoded = &File{
+		OldName:   f.OldName,
+		NewName:   f.NewName,
+		IsNew:     f.IsNew,
+		IsDeleted: f.IsDeleted,
+		IsRenamed: f.IsRenamed,
+		OldOID:    f.OldOID,
+		NewOID:    f.NewOID,
+	}
+	if err := decoded.setHunks(sides[0], sides[1]); err != nil {
+		return nil, "", false
+	}
+	return decoded, strings.Join(encs, " → "), true
+}
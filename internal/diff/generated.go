@@ -0,0 +1,120 @@
+package diff
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/bluekeyes/go-gitdiff/gitdiff"
+)
+
+// lockfiles are the dependency lockfiles package managers write, by name.
+var lockfiles = map[string]bool{
+	"go.sum":              true,
+	"go.work.sum":         true,
+	"package-lock.json":   true,
+	"npm-shrinkwrap.json": true,
+	"yarn.lock":           true,
+	"pnpm-lock.yaml":      true,
+	"bun.lockb":           true,
+	"Cargo.lock":          true,
+	"Pipfile.lock":        true,
+	"poetry.lock":         true,
+	"uv.lock":             true,
+	"Gemfile.lock":        true,
+	"composer.lock":       true,
+	"mix.lock":            true,
+	"flake.lock":          true,
+}
+
+// generatedSuffixes are the endings of other machine-written files: test
+// snapshots and minified bundles.
+var generatedSuffixes = []string{".snap", ".min.js", ".min.css", ".js.map", ".css.map"}
+
+// generatedMarkerRe matches the comment marking generated Go code, and the
+// like in other languages, which by convention comes near the top.
+var generatedMarkerRe = regexp.MustCompile(`^\s*(//|#|/\*|--)\s*Code generated .* DO NOT EDIT\.?`)
+
+// generatedMarkerLines is how far into a file the marker is looked for.
+const generatedMarkerLines = 5
+
+// Generated reports whether f is a lockfile or other machine-generated file,
+// whose changes are shown collapsed to a one-line summary. That is decided
+// by its name, or by a "Code generated ... DO NOT EDIT." comment at the top
+// when the diff shows it.
+func (f *File) Generated() bool {
+	if f.IsBinary || f.IsSubmodule {
+		return false
+	}
+	base := path.Base(f.Name())
+	if lockfiles[base] {
+		return true
+	}
+	for _, s := range generatedSuffixes {
+		if strings.HasSuffix(base, s) {
+			return true
+		}
+	}
+	for _, frag := range f.Fragments {
+		if frag.NewPosition > 1 {
+			break
+		}
+		n := 0
+		for _, l := range frag.Lines {
+			if l.Op == gitdiff.OpDelete {
+				continue
+			}
+			if generatedMarkerRe.MatchString(l.Line) {
+				return true
+			}
+			if n++; n == generatedMarkerLines {
+				break
+			}
+		}
+	}
+	return false
+}
+
+// GeneratedSummary describes the changes to a generated file in a line, as
+// in "go.sum: 48 entries changed". Entries are counted where the format
+// makes them a line each; other files count lines.
+func (f *File) GeneratedSummary() string {
+	name := f.Name()
+	if path.Base(name) == "go.sum" || path.Base(name) == "go.work.sum" {
+		return fmt.Sprintf("%s: %s changed", name, plural(goSumEntries(f), "entry", "entries"))
+	}
+	switch {
+	case f.IsNew:
+		return fmt.Sprintf("%s: %s added", name, plural(f.AddedLines, "line", "lines"))
+	case f.IsDeleted:
+		return fmt.Sprintf("%s: %s deleted", name, plural(f.DeletedLines, "line", "lines"))
+	}
+	return fmt.Sprintf("%s: +%d -%d lines", name, f.AddedLines, f.DeletedLines)
+}
+
+// goSumEntries counts the module versions whose go.sum lines changed. A
+// version's two lines, for its tree and its go.mod, are one entry.
+func goSumEntries(f *File) int {
+	entries := make(map[string]bool)
+	for _, frag := range f.Fragments {
+		for _, l := range frag.Lines {
+			if l.Op == gitdiff.OpContext {
+				continue
+			}
+			fields := strings.Fields(l.Line)
+			if len(fields) < 2 {
+				continue
+			}
+			entries[fields[0]+" "+strings.TrimSuffix(fields[1], "/go.mod")] = true
+		}
+	}
+	return len(entries)
+}
+
+func plural(n int, one, many string) string {
+	if n == 1 {
+		return "1 " + one
+	}
+	return fmt.Sprintf("%d %s", n, many)
+}
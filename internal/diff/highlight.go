@@ -9,6 +9,15 @@ import (
 	"github.com/alecthomas/chroma/v2/styles"
 )
 
+// highlightStyle is the chroma style used for token colors.
+var highlightStyle = "dracula"
+
+// SetHighlightStyle selects the chroma style used by HighlightLines. Unknown
+// names fall back to chroma's default style.
+func SetHighlightStyle(name string) {
+	highlightStyle = name
+}
+
 // HighlightedLine represents a line with syntax-highlighted tokens.
 type HighlightedLine struct {
 	Tokens []Token
@@ -17,7 +26,7 @@ type HighlightedLine struct {
 // Token is a syntax-highlighted chunk of text.
 type Token struct {
 	Text  string
-	Color string // ANSI color string, empty for default
+	Color string // hex color like "#ff79c6", empty for default
 }
 
 // Plain returns the concatenated plain text of all tokens.
@@ -32,7 +41,26 @@ func (hl HighlightedLine) Plain() string {
 // HighlightLines applies syntax highlighting to source lines for a given filename.
 // Returns one HighlightedLine per input line.
 func HighlightLines(filename string, lines []string) []HighlightedLine {
-	lexer := lexerForFile(filename)
+	return highlight(lexerForFile(filename), highlightStyle, lines)
+}
+
+// HighlightLinesStyle is HighlightLines with an explicit chroma style
+// rather than the one set by SetHighlightStyle.
+func HighlightLinesStyle(filename, style string, lines []string) []HighlightedLine {
+	return highlight(lexerForFile(filename), style, lines)
+}
+
+// HighlightSource is HighlightLines for a language name such as "go" or
+// "python", as given on a markdown code fence, rather than a file name.
+func HighlightSource(lang string, lines []string) []HighlightedLine {
+	lexer := lexers.Get(lang)
+	if lexer != nil {
+		lexer = chroma.Coalesce(lexer)
+	}
+	return highlight(lexer, highlightStyle, lines)
+}
+
+func highlight(lexer chroma.Lexer, styleName string, lines []string) []HighlightedLine {
 	if lexer == nil {
 		return plainLines(lines)
 	}
@@ -43,7 +71,7 @@ func HighlightLines(filename string, lines []string) []HighlightedLine {
 		return plainLines(lines)
 	}
 
-	style := styles.Get("dracula")
+	style := styles.Get(styleName)
 	if style == nil {
 		style = styles.Fallback
 	}
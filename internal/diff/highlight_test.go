@@ -41,3 +41,22 @@ func TestHighlightLinesUnknownLanguage(t *testing.T) {
 		t.Errorf("expected plain passthrough, got %q", highlighted[0].Plain())
 	}
 }
+
+func TestSetHighlightStyle(t *testing.T) {
+	defer SetHighlightStyle("dracula")
+
+	lines := []string{"package main"}
+	dark := HighlightLines("main.go", lines)[0].Tokens[0].Color
+
+	SetHighlightStyle("github")
+	light := HighlightLines("main.go", lines)[0].Tokens[0].Color
+
+	if dark == "" || light == "" || dark == light {
+		t.Errorf("expected different keyword colors per style, got %q and %q", dark, light)
+	}
+
+	SetHighlightStyle("dracula")
+	if got := HighlightLinesStyle("main.go", "github", lines)[0].Tokens[0].Color; got != light {
+		t.Errorf("HighlightLinesStyle: expected the github color %q, got %q", light, got)
+	}
+}
@@ -0,0 +1,139 @@
+package diff
+
+import "unicode"
+
+// Span is a half-open byte range [Start, End) within a line.
+type Span struct {
+	Start int
+	End   int
+}
+
+// Limits beyond which word-level diffing is skipped: very long lines are
+// expensive to compare, and mostly rewritten lines gain nothing from emphasis.
+const (
+	maxIntralineTokens  = 400
+	maxIntralineChanged = 0.6
+)
+
+// IntralineDiff compares a deleted line with the added line that replaced it
+// and returns the byte ranges that differ in each. Lines are compared word by
+// word, so a one-character edit emphasizes the whole word containing it.
+// Both results are nil when the lines are identical, too long, or so different
+// that emphasis would cover most of them.
+func IntralineDiff(oldLine, newLine string) (oldSpans, newSpans []Span) {
+	if oldLine == newLine {
+		return nil, nil
+	}
+
+	a := tokenize(oldLine)
+	b := tokenize(newLine)
+	if len(a) > maxIntralineTokens || len(b) > maxIntralineTokens {
+		return nil, nil
+	}
+
+	// Longest common subsequence over tokens
+	n, m := len(a), len(b)
+	lcs := make([][]int, n+1)
+	for i := range lcs {
+		lcs[i] = make([]int, m+1)
+	}
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			if a[i].text == b[j].text {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	// Walk the table, collecting tokens absent from the common subsequence
+	var oldChanged, newChanged []token
+	i, j := 0, 0
+	for i < n || j < m {
+		switch {
+		case i < n && j < m && a[i].text == b[j].text:
+			i++
+			j++
+		case j < m && (i == n || lcs[i][j+1] >= lcs[i+1][j]):
+			newChanged = append(newChanged, b[j])
+			j++
+		default:
+			oldChanged = append(oldChanged, a[i])
+			i++
+		}
+	}
+
+	if changedRatio(oldChanged, len(oldLine)) > maxIntralineChanged &&
+		changedRatio(newChanged, len(newLine)) > maxIntralineChanged {
+		return nil, nil
+	}
+	return mergeSpans(oldChanged), mergeSpans(newChanged)
+}
+
+type token struct {
+	text  string
+	start int
+}
+
+// tokenize splits s into words (letters, digits, underscores), whitespace
+// runs, and single punctuation characters.
+func tokenize(s string) []token {
+	var tokens []token
+	start := 0
+	var kind int
+	for i, r := range s {
+		k := runeKind(r)
+		if i > start && (k != kind || k == kindPunct) {
+			tokens = append(tokens, token{text: s[start:i], start: start})
+			start = i
+		}
+		kind = k
+	}
+	if start < len(s) {
+		tokens = append(tokens, token{text: s[start:], start: start})
+	}
+	return tokens
+}
+
+const (
+	kindWord = iota
+	kindSpace
+	kindPunct
+)
+
+func runeKind(r rune) int {
+	switch {
+	case r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r):
+		return kindWord
+	case unicode.IsSpace(r):
+		return kindSpace
+	default:
+		return kindPunct
+	}
+}
+
+func changedRatio(changed []token, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	n := 0
+	for _, t := range changed {
+		n += len(t.text)
+	}
+	return float64(n) / float64(total)
+}
+
+// mergeSpans converts changed tokens to spans, joining adjacent ones.
+func mergeSpans(tokens []token) []Span {
+	var spans []Span
+	for _, t := range tokens {
+		end := t.start + len(t.text)
+		if len(spans) > 0 && spans[len(spans)-1].End == t.start {
+			spans[len(spans)-1].End = end
+			continue
+		}
+		spans = append(spans, Span{Start: t.start, End: end})
+	}
+	return spans
+}
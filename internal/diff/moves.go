@@ -0,0 +1,146 @@
+package diff
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/bluekeyes/go-gitdiff/gitdiff"
+	"github.com/aezell/agrev/internal/model"
+)
+
+// A move must span at least minMoveLines lines with a letter or digit, so
+// runs of closing braces don't count.
+const minMoveLines = 3
+
+// movedLine is a non-blank deleted or added line, with its whitespace
+// collapsed for comparison.
+type movedLine struct {
+	file *File
+	num  int // old line number for deletions, new for additions
+	text string
+	used bool // already part of a move
+}
+
+// detectMoves finds blocks deleted from one file and added to another.
+// Lines are compared with whitespace collapsed and blank lines skipped, so
+// reindented code still counts as moved. Each line belongs to at most one
+// move; longer matches are taken first within each deleted block.
+func detectMoves(files []*File) []model.Move {
+	var deleted, added [][]*movedLine // runs of consecutive changes
+	for _, f := range files {
+		if f.IsBinary {
+			continue
+		}
+		for _, frag := range f.Fragments {
+			oldNum, newNum := int(frag.OldPosition), int(frag.NewPosition)
+			var del, add []*movedLine
+			flush := func() {
+				if len(del) > 0 {
+					deleted = append(deleted, del)
+				}
+				if len(add) > 0 {
+					added = append(added, add)
+				}
+				del, add = nil, nil
+			}
+			for _, l := range frag.Lines {
+				text := strings.Join(strings.Fields(l.Line), " ")
+				switch l.Op {
+				case gitdiff.OpContext:
+					flush()
+					oldNum++
+					newNum++
+				case gitdiff.OpDelete:
+					if text != "" {
+						del = append(del, &movedLine{file: f, num: oldNum, text: text})
+					}
+					oldNum++
+				case gitdiff.OpAdd:
+					if text != "" {
+						add = append(add, &movedLine{file: f, num: newNum, text: text})
+					}
+					newNum++
+				}
+			}
+			flush()
+		}
+	}
+
+	// Where each added line starts a possible match
+	type position struct{ run, i int }
+	index := make(map[string][]position)
+	for r, run := range added {
+		for i, l := range run {
+			index[l.text] = append(index[l.text], position{r, i})
+		}
+	}
+
+	var moves []model.Move
+	for _, run := range deleted {
+		for i := 0; i < len(run); {
+			if run[i].used {
+				i++
+				continue
+			}
+			var best []*movedLine
+			var bestLen int
+			for _, p := range index[run[i].text] {
+				to := added[p.run]
+				if to[p.i].file == run[i].file {
+					continue
+				}
+				n := 0
+				for i+n < len(run) && p.i+n < len(to) &&
+					!run[i+n].used && !to[p.i+n].used && run[i+n].text == to[p.i+n].text {
+					n++
+				}
+				if n > bestLen {
+					best, bestLen = to[p.i:p.i+n], n
+				}
+			}
+			if significantLines(run[i:i+bestLen]) < minMoveLines {
+				i++
+				continue
+			}
+
+			from := run[i : i+bestLen]
+			for k := range bestLen {
+				from[k].used, best[k].used = true, true
+			}
+			moves = append(moves, model.Move{
+				FromFile: from[0].file.OldName,
+				From:     model.LineRange{Start: from[0].num, End: from[bestLen-1].num},
+				ToFile:   best[0].file.NewName,
+				To:       model.LineRange{Start: best[0].num, End: best[bestLen-1].num},
+			})
+			i += bestLen
+		}
+	}
+	return moves
+}
+
+// significantLines counts the lines holding a letter or digit.
+func significantLines(lines []*movedLine) int {
+	n := 0
+	for _, l := range lines {
+		if strings.IndexFunc(l.text, func(r rune) bool { return unicode.IsLetter(r) || unicode.IsDigit(r) }) >= 0 {
+			n++
+		}
+	}
+	return n
+}
+
+// MoveAt returns the move covering a line of f: a deleted line in the old
+// file when old is set, an added line in the new file otherwise.
+func (ds *DiffSet) MoveAt(f *File, line int, old bool) (model.Move, bool) {
+	for _, mv := range ds.Moves {
+		name, file, r := f.NewName, mv.ToFile, mv.To
+		if old {
+			name, file, r = f.OldName, mv.FromFile, mv.From
+		}
+		if file == name && line >= r.Start && line <= r.End {
+			return mv, true
+		}
+	}
+	return model.Move{}, false
+}
@@ -0,0 +1,77 @@
+package diff
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Range is a commit range resolved to the commits a diff compares.
+type Range struct {
+	Base string // full SHA the diff starts from
+	Head string // full SHA it ends at; empty for the working tree
+}
+
+// ResolveRange resolves a revision range the way git diff reads it, with
+// both ends made explicit. "A...B" compares B with its merge-base with A,
+// so the diff holds only the changes made on B's side since it forked, not
+// whatever landed on A meanwhile. "A..B" compares A with B directly, and
+// "C^!" compares commit C with its first parent. A single revision is
+// compared with the working tree. A missing side defaults to HEAD.
+func ResolveRange(repoDir, spec string) (Range, error) {
+	orHead := func(rev string) string {
+		if rev == "" {
+			return "HEAD"
+		}
+		return rev
+	}
+
+	if left, right, ok := strings.Cut(spec, "..."); ok {
+		head, err := resolveCommit(repoDir, orHead(right))
+		if err != nil {
+			return Range{}, err
+		}
+		base, err := git(repoDir, "merge-base", "--end-of-options", orHead(left), head)
+		if err != nil {
+			return Range{}, fmt.Errorf("no merge-base for %s: %w", spec, err)
+		}
+		return Range{Base: strings.TrimSpace(base), Head: head}, nil
+	}
+
+	var left, right string
+	if l, r, ok := strings.Cut(spec, ".."); ok {
+		left, right = orHead(l), orHead(r)
+	} else if c, ok := strings.CutSuffix(spec, "^!"); ok {
+		left, right = c+"^", c
+	} else {
+		base, err := resolveCommit(repoDir, spec)
+		return Range{Base: base}, err
+	}
+	base, err := resolveCommit(repoDir, left)
+	if err != nil {
+		return Range{}, err
+	}
+	head, err := resolveCommit(repoDir, right)
+	if err != nil {
+		return Range{}, err
+	}
+	return Range{Base: base, Head: head}, nil
+}
+
+// resolveCommit returns the full SHA of the commit rev names.
+func resolveCommit(repoDir, rev string) (string, error) {
+	out, err := git(repoDir, "rev-parse", "--verify", "--quiet", "--end-of-options", rev+"^{commit}")
+	if err != nil {
+		return "", fmt.Errorf("unknown revision %q", rev)
+	}
+	return strings.TrimSpace(out), nil
+}
+
+// Short abbreviates the range as "base..head", or just the base when the
+// head is the working tree.
+func (r Range) Short() string {
+	short := func(hash string) string { return hash[:min(len(hash), 7)] }
+	if r.Head == "" {
+		return short(r.Base)
+	}
+	return short(r.Base) + ".." + short(r.Head)
+}
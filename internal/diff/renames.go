@@ -0,0 +1,172 @@
+package diff
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/bluekeyes/go-gitdiff/gitdiff"
+)
+
+// minRenameSimilarity is the share of lines, in percent, a deleted and a new
+// file must have in common to be shown as a rename. It matches git's
+// default for diff -M.
+const minRenameSimilarity = 50
+
+// renameContext is the context around the changes between the two versions
+// of a paired rename.
+const renameContext = 3
+
+// pairRenames finds whole files deleted and added again elsewhere, which a
+// diff without rename detection (or an agent that rewrote a file under a new
+// name) shows as two unrelated files. Each deleted file is paired with the
+// new file sharing the most lines with it, if they share at least
+// minRenameSimilarity percent, and the pair is replaced by a rename whose
+// hunks are the changes between the two. The rename takes the place of
+// whichever of the pair came first.
+func pairRenames(files []*File) ([]*File, error) {
+	var deleted, added []int
+	for i, f := range files {
+		switch {
+		case f.IsBinary || f.IsSubmodule || f.Combined != nil:
+		case f.IsDeleted && f.DeletedLines > 0:
+			deleted = append(deleted, i)
+		case f.IsNew && f.AddedLines > 0:
+			added = append(added, i)
+		}
+	}
+	if len(deleted) == 0 || len(added) == 0 {
+		return files, nil
+	}
+
+	content := make(map[int][]string)
+	for _, i := range deleted {
+		content[i] = sideLines(files[i], gitdiff.OpAdd)
+	}
+	for _, i := range added {
+		content[i] = sideLines(files[i], gitdiff.OpDelete)
+	}
+
+	type candidate struct {
+		del, add int
+		score    int
+		sameBase bool
+	}
+	var candidates []candidate
+	for _, d := range deleted {
+		counts := make(map[string]int)
+		for _, l := range content[d] {
+			counts[l]++
+		}
+		for _, a := range added {
+			longest := max(len(content[d]), len(content[a]))
+			if min(len(content[d]), len(content[a]))*100 < minRenameSimilarity*longest {
+				continue // too different in size to share enough lines
+			}
+			left := make(map[string]int, len(counts))
+			shared := 0
+			for _, l := range content[a] {
+				if left[l] < counts[l] {
+					left[l]++
+					shared++
+				}
+			}
+			if score := shared * 100 / longest; score >= minRenameSimilarity {
+				sameBase := path.Base(files[d].OldName) == path.Base(files[a].NewName)
+				candidates = append(candidates, candidate{d, a, score, sameBase})
+			}
+		}
+	}
+
+	// Best matches first; a file keeps the same name when it moves, so
+	// that breaks ties
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if candidates[i].score != candidates[j].score {
+			return candidates[i].score > candidates[j].score
+		}
+		return candidates[i].sameBase && !candidates[j].sameBase
+	})
+	pairs := make(map[int]*File) // by the index the rename goes at
+	used := make(map[int]bool)
+	for _, c := range candidates {
+		if used[c.del] || used[c.add] {
+			continue
+		}
+		used[c.del], used[c.add] = true, true
+		f, err := renamePair(files[c.del], files[c.add], content[c.del], content[c.add], c.score)
+		if err != nil {
+			return nil, err
+		}
+		pairs[min(c.del, c.add)] = f
+	}
+	if len(pairs) == 0 {
+		return files, nil
+	}
+
+	out := make([]*File, 0, len(files)-len(pairs))
+	for i, f := range files {
+		switch {
+		case pairs[i] != nil:
+			out = append(out, pairs[i])
+		case !used[i]:
+			out = append(out, f)
+		}
+	}
+	return out, nil
+}
+
+// sideLines returns the lines of one side of a file's diff, keeping their
+// newlines: the old side when skip is OpAdd, the new side when it is
+// OpDelete.
+func sideLines(f *File, skip gitdiff.LineOp) []string {
+	var lines []string
+	for _, frag := range f.Fragments {
+		for _, l := range frag.Lines {
+			if l.Op != skip {
+				lines = append(lines, l.Line)
+			}
+		}
+	}
+	return lines
+}
+
+// renamePair returns the rename of del to add, with the changes between
+// their contents as its hunks.
+func renamePair(del, add *File, oldLines, newLines []string, score int) (*File, error) {
+	f := &File{
+		OldName:    del.OldName,
+		NewName:    add.NewName,
+		IsRenamed:  true,
+		OldOID:     del.OldOID,
+		NewOID:     add.NewOID,
+		Similarity: score,
+		Paired:     true,
+	}
+	if err := f.setHunks(oldLines, newLines); err != nil {
+		return nil, fmt.Errorf("diffing %s against %s: %v", add.NewName, del.OldName, err)
+	}
+	return f, nil
+}
+
+// setHunks makes the changes from oldLines to newLines, which keep their
+// line endings, f's hunks.
+func (f *File) setHunks(oldLines, newLines []string) error {
+	hunks := unifiedHunks(f.Name(), oldLines, newLines, renameContext, false)
+	if hunks == "" {
+		return nil
+	}
+	parsed, _, err := gitdiff.Parse(strings.NewReader("--- a/file\n+++ b/file\n" + hunks))
+	if err != nil {
+		return err
+	}
+	if len(parsed) != 1 {
+		return fmt.Errorf("%d files in the hunks", len(parsed))
+	}
+	f.Fragments = parsed[0].TextFragments
+	for _, frag := range f.Fragments {
+		f.AddedLines += int(frag.LinesAdded)
+		f.DeletedLines += int(frag.LinesDeleted)
+	}
+	return nil
+}
@@ -0,0 +1,120 @@
+package diff
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// Repo is one of the repositories a combined diff spans.
+type Repo struct {
+	Name string // the directory its files are listed under, e.g. "api"
+	Dir  string // its root on disk
+}
+
+// Repos names the repositories rooted at dirs by their paths relative to
+// the closest directory holding all of them, which it returns too. A file
+// "name/path" of their combined diff is then found on disk under root. A
+// single repository is named after its directory.
+func Repos(dirs []string) (root string, repos []Repo, err error) {
+	if len(dirs) == 0 {
+		return "", nil, fmt.Errorf("no repositories given")
+	}
+	var abs []string
+	for _, d := range dirs {
+		a, err := filepath.Abs(d)
+		if err != nil {
+			return "", nil, err
+		}
+		abs = append(abs, filepath.Clean(a))
+	}
+	root = filepath.Dir(abs[0])
+	for _, a := range abs[1:] {
+		for !within(root, filepath.Dir(a)) {
+			root = filepath.Dir(root)
+		}
+	}
+
+	seen := make(map[string]string)
+	for i, a := range abs {
+		rel, err := filepath.Rel(root, a)
+		if err != nil {
+			return "", nil, err
+		}
+		name := filepath.ToSlash(rel)
+		if prev, ok := seen[name]; ok {
+			return "", nil, fmt.Errorf("repository %s is given twice (as %s and %s)", name, prev, dirs[i])
+		}
+		seen[name] = dirs[i]
+		repos = append(repos, Repo{Name: name, Dir: a})
+	}
+	for _, r := range repos {
+		for _, o := range repos {
+			if r.Name != o.Name && strings.HasPrefix(o.Name, r.Name+"/") {
+				return "", nil, fmt.Errorf("repository %s is inside %s; review them separately", o.Name, r.Name)
+			}
+		}
+	}
+	return root, repos, nil
+}
+
+// within reports whether path is dir or below it.
+func within(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// Combine merges the diffs of several repositories, sets[i] holding the
+// changes in repos[i], into one diff that lists each file under its
+// repository's name, as "api/main.go". The files are renamed in place.
+// Moves are found again, so code moved from one repository to another shows
+// up as well.
+func Combine(repos []Repo, sets []*DiffSet) *DiffSet {
+	ds := &DiffSet{Repos: repos}
+	var raw strings.Builder
+	for i, r := range repos {
+		for _, f := range sets[i].Files {
+			if f.OldName != "" {
+				f.OldName = r.Name + "/" + f.OldName
+			}
+			if f.NewName != "" {
+				f.NewName = r.Name + "/" + f.NewName
+			}
+			ds.Files = append(ds.Files, f)
+		}
+		// The raw text is only compared and hashed, never parsed again
+		fmt.Fprintf(&raw, "# %s\n%s", r.Name, sets[i].Raw)
+	}
+	ds.Raw = raw.String()
+	ds.Moves = detectMoves(ds.Files)
+	return ds
+}
+
+// Repo returns the repository of a combined diff that a file name is listed
+// under, and the name within it. It reports false for diffs of a single
+// repository, and for names under none of them.
+func (ds *DiffSet) Repo(name string) (Repo, string, bool) {
+	for _, r := range ds.Repos {
+		if rest, ok := strings.CutPrefix(name, r.Name+"/"); ok {
+			return r, rest, true
+		}
+	}
+	return Repo{}, "", false
+}
+
+// InRepo returns a copy of f named as in repository r, or false if f isn't
+// one of r's files.
+func InRepo(f *File, r Repo) (*File, bool) {
+	prefix := r.Name + "/"
+	name := f.NewName
+	if name == "" {
+		name = f.OldName
+	}
+	if !strings.HasPrefix(name, prefix) {
+		return nil, false
+	}
+	c := *f
+	c.OldName = strings.TrimPrefix(c.OldName, prefix)
+	c.NewName = strings.TrimPrefix(c.NewName, prefix)
+	return &c, true
+}
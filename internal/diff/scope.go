@@ -0,0 +1,269 @@
+package diff
+
+import (
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/bluekeyes/go-gitdiff/gitdiff"
+)
+
+// maxScopeLen caps scope text, as git does for hunk header context.
+const maxScopeLen = 80
+
+// scopePatterns match lines that open a function, method, or type, by
+// language. Lines are matched with leading whitespace removed.
+var scopePatterns = map[string][]*regexp.Regexp{
+	"go": {
+		regexp.MustCompile(`^func\b`),
+		regexp.MustCompile(`^type\s+\w+.*\b(struct|interface)\b`),
+	},
+	"python": {
+		regexp.MustCompile(`^(async\s+)?def\s+\w+`),
+		regexp.MustCompile(`^class\s+\w+`),
+	},
+	"js": {
+		regexp.MustCompile(`^(export\s+)?(default\s+)?(async\s+)?function\b`),
+		regexp.MustCompile(`^(export\s+)?(default\s+)?(abstract\s+)?class\s+\w+`),
+		regexp.MustCompile(`^(export\s+)?(interface|enum|namespace)\s+\w+`),
+		regexp.MustCompile(`^(export\s+)?(const|let|var)\s+\w+\s*(:[^=]+)?=\s*(async\s+)?(function\b|\([^)]*\)[^=]*=>|\w+\s*=>)`),
+		regexp.MustCompile(`^((public|private|protected|static|async|override|readonly|get|set)\s+)*\w+\s*(<[^>]*>)?\([^)]*\)\s*(:[^{]+)?\{\s*$`),
+	},
+	"ruby": {
+		regexp.MustCompile(`^def\s+`),
+		regexp.MustCompile(`^(class|module)\s+\w+`),
+	},
+	"rust": {
+		regexp.MustCompile(`^(pub(\([\w:]+\))?\s+)?((const|async|unsafe|extern\s+"\w+")\s+)*fn\s+\w+`),
+		regexp.MustCompile(`^(pub(\([\w:]+\))?\s+)?(struct|enum|trait|union|mod)\s+\w+`),
+		regexp.MustCompile(`^(unsafe\s+)?impl\b`),
+	},
+	"java": {
+		regexp.MustCompile(`^((public|private|protected|internal|static|final|abstract|sealed|open|data|partial)\s+)*(class|interface|enum|record|struct|object)\s+\w+`),
+		regexp.MustCompile(`^((public|private|protected|internal|static|final|abstract|override|virtual|async|synchronized|suspend|open)\s+)+[\w<>\[\],.? ]*\b\w+\s*\(`),
+		regexp.MustCompile(`^(\w+\s+)*fun\s+`),
+	},
+	"c": {
+		regexp.MustCompile(`^(class|struct|namespace|enum|union)\s+\w+[^;]*$`),
+		regexp.MustCompile(`^[A-Za-z_][\w\s\*&:<>,~]*\b[\w~]+\s*\([^;]*$`),
+	},
+	"php": {
+		regexp.MustCompile(`^((public|private|protected|static|final|abstract)\s+)*function\s+\w+`),
+		regexp.MustCompile(`^((final|abstract|readonly)\s+)*(class|interface|trait|enum)\s+\w+`),
+	},
+	"swift": {
+		regexp.MustCompile(`^((public|private|fileprivate|internal|open|static|final|override|mutating|@\w+)\s+)*func\s+`),
+		regexp.MustCompile(`^((public|private|fileprivate|internal|open|final)\s+)*(class|struct|enum|protocol|extension|actor)\s+\w+`),
+	},
+	"elixir": {
+		regexp.MustCompile(`^(def|defp|defmacro|defmacrop|defmodule|defprotocol|defimpl)\s+`),
+	},
+}
+
+// scopeLanguage returns the scopePatterns key for name's extension.
+func scopeLanguage(name string) string {
+	switch strings.ToLower(path.Ext(name)) {
+	case ".go":
+		return "go"
+	case ".py":
+		return "python"
+	case ".js", ".jsx", ".mjs", ".cjs", ".ts", ".tsx":
+		return "js"
+	case ".rb":
+		return "ruby"
+	case ".rs":
+		return "rust"
+	case ".java", ".kt", ".kts", ".cs", ".scala":
+		return "java"
+	case ".c", ".h", ".cc", ".cpp", ".cxx", ".hpp":
+		return "c"
+	case ".php":
+		return "php"
+	case ".swift":
+		return "swift"
+	case ".ex", ".exs":
+		return "elixir"
+	}
+	return ""
+}
+
+// Control-flow keywords that look like calls to the looser patterns.
+var scopeKeywords = map[string]bool{
+	"if": true, "for": true, "while": true, "switch": true, "catch": true,
+	"return": true, "else": true, "do": true, "try": true, "sizeof": true,
+}
+
+// scopeMatcher returns a function reporting whether a (trimmed) line opens a
+// scope in name's language, or nil if the language isn't known.
+func scopeMatcher(name string) func(string) bool {
+	patterns := scopePatterns[scopeLanguage(name)]
+	if patterns == nil {
+		return nil
+	}
+	return func(line string) bool {
+		if word, _, _ := strings.Cut(line, "("); scopeKeywords[strings.TrimSpace(word)] {
+			return false
+		}
+		for _, re := range patterns {
+			if re.MatchString(line) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// indentOf measures a line's leading whitespace, counting a tab as one.
+func indentOf(line string) int {
+	return len(line) - len(strings.TrimLeft(line, " \t"))
+}
+
+// blank reports whether a line holds only whitespace.
+func blank(line string) bool {
+	return strings.TrimSpace(line) == ""
+}
+
+// innermostScope looks back through lines, nearest last, for the definition
+// enclosing a line indented by indent: the closest one indented less.
+// Less-indented lines that aren't definitions are blocks like loops, which
+// narrow the search; it gives up with closed set when it reaches a top-level
+// line that isn't one, since the line is then outside any scope before it.
+// Lines starting with a closing bracket end a multi-line signature and are
+// passed over.
+func innermostScope(isScope func(string) bool, lines []string, indent int) (scope string, closed bool) {
+	for i := len(lines) - 1; i >= 0 && indent > 0; i-- {
+		l := strings.TrimRight(lines[i], "\r\n")
+		if blank(l) || indentOf(l) >= indent {
+			continue
+		}
+		t := strings.TrimSpace(l)
+		if isScope(t) {
+			return scopeText(t), false
+		}
+		if strings.HasPrefix(t, ")") || strings.HasPrefix(t, "]") {
+			continue
+		}
+		indent = indentOf(l)
+	}
+	return "", indent == 0
+}
+
+// scopeText shortens a definition line for a hunk header.
+func scopeText(line string) string {
+	line = strings.TrimSpace(line)
+	if r := []rune(line); len(r) > maxScopeLen {
+		line = strings.TrimSpace(string(r[:maxScopeLen])) + "…"
+	}
+	return line
+}
+
+// firstChange finds the first changed line of a hunk that isn't blank,
+// returning its index and indentation, or -1 if there is none.
+func firstChange(frag *gitdiff.TextFragment) (int, int) {
+	for i, l := range frag.Lines {
+		if l.Op != gitdiff.OpContext && !blank(l.Line) {
+			return i, indentOf(l.Line)
+		}
+	}
+	return -1, 0
+}
+
+// fillScopes sets each hunk's Comment to the function or type its first
+// change lies in, found in old, the file's content before the change. With
+// old nil, only the hunk's own leading context is searched. Hunks whose
+// scope isn't found keep the context git gave them.
+func (f *File) fillScopes(old []string) {
+	isScope := scopeMatcher(f.OldName)
+	if isScope == nil || f.IsNew || f.IsBinary {
+		return
+	}
+	for _, frag := range f.Fragments {
+		i, indent := firstChange(frag)
+		if i < 0 {
+			continue
+		}
+		// The old lines before the first change
+		var before []string
+		if old != nil {
+			n := int(frag.OldPosition) - 1
+			for _, l := range frag.Lines[:i] {
+				if l.Op != gitdiff.OpAdd {
+					n++
+				}
+			}
+			before = old[:max(0, min(n, len(old)))]
+		} else {
+			for _, l := range frag.Lines[:i] {
+				if l.Op != gitdiff.OpAdd {
+					before = append(before, l.Line)
+				}
+			}
+		}
+		if scope, _ := innermostScope(isScope, before, indent); scope != "" {
+			frag.Comment = scope
+		}
+	}
+}
+
+// FillScopes improves the hunk headers of ds using each file's content
+// before the change, read from repoDir. Git's own guess is the nearest
+// unindented line above a hunk, which for a method is its class; this
+// finds the method, in languages it knows. Files it can't read are left
+// alone.
+func FillScopes(ds *DiffSet, repoDir string) {
+	if repoDir == "" {
+		return
+	}
+	for _, f := range ds.Files {
+		if len(f.Fragments) == 0 || f.IsNew || f.IsBinary || f.IsSubmodule || scopeMatcher(f.OldName) == nil {
+			continue
+		}
+		data, err := OldBytes(repoDir, f)
+		if err != nil {
+			continue
+		}
+		f.fillScopes(SplitLines(string(data)))
+	}
+}
+
+// ScopeAt returns the function or type enclosing a line of f's new
+// content, as far as its hunks show: the innermost definition above the
+// line within its hunk, or else the hunk's header context, without the
+// brace or colon opening its body. It returns "" for lines outside the
+// hunks and lines at the top level.
+func (f *File) ScopeAt(line int) string {
+	return strings.TrimSpace(strings.TrimRight(f.scopeAt(line), "{:"))
+}
+
+func (f *File) scopeAt(line int) string {
+	for _, frag := range f.Fragments {
+		start := int(frag.NewPosition)
+		if line < start || line >= start+int(frag.NewLines) {
+			continue
+		}
+		var before []string
+		n := start
+		for _, l := range frag.Lines {
+			if l.Op == gitdiff.OpDelete {
+				continue
+			}
+			if n == line {
+				if blank(l.Line) {
+					return frag.Comment
+				}
+				isScope := scopeMatcher(f.NewName)
+				if isScope == nil {
+					return frag.Comment
+				}
+				scope, closed := innermostScope(isScope, before, indentOf(l.Line))
+				if scope == "" && !closed {
+					scope = frag.Comment
+				}
+				return scope
+			}
+			before = append(before, l.Line)
+			n++
+		}
+	}
+	return ""
+}
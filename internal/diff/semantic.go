@@ -0,0 +1,391 @@
+package diff
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/printer"
+	gotoken "go/token"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/bluekeyes/go-gitdiff/gitdiff"
+)
+
+// DeclChange is a declaration added, removed, or modified between two
+// versions of a file.
+type DeclChange struct {
+	Kind    string   // "function", "method", "type", "class", "const", "import", ...
+	Name    string   // qualified by its type or class, as in "Server.Start"
+	Change  string   // "added", "removed", or "modified"
+	Details []string // for modifications, what changed: "signature changed", "field Timeout added"
+}
+
+func (c DeclChange) String() string {
+	s := c.Kind + " " + c.Name + " " + c.Change
+	if len(c.Details) > 0 {
+		s += ": " + strings.Join(c.Details, ", ")
+	}
+	return s
+}
+
+// decl is one declaration found in a version of a file. Text is what it
+// compares by, with whitespace and, for Go, comments left out; parts are
+// the members, like struct fields, whose own changes are reported.
+type decl struct {
+	kind, name string
+	sig, text  string
+	parts      map[string]string
+	partKind   string
+	partOrder  []string
+}
+
+// DeclChanges compares the declarations in two versions of name: functions,
+// methods, types and their fields, classes, and so on. Go files are parsed
+// with go/parser; other languages are read by indentation, with the same
+// patterns that find hunk scopes, so a function counts as modified when any
+// line of it changed other than in whitespace. It returns false for
+// languages it doesn't know. Either version may be nil, for an added or
+// deleted file.
+func DeclChanges(name string, old, new []byte) ([]DeclChange, bool) {
+	parse := func(src []byte) []decl { return indentDecls(name, src) }
+	if scopeLanguage(name) == "go" {
+		parse = func(src []byte) []decl {
+			if decls, err := goDecls(src); err == nil {
+				return decls
+			}
+			return indentDecls(name, src) // not valid Go yet; read it as text
+		}
+	} else if scopeMatcher(name) == nil {
+		return nil, false
+	}
+	return compareDecls(parse(old), parse(new)), true
+}
+
+// compareDecls reports what changed between two lists of declarations: the
+// new version's additions and modifications in its order, then removals.
+func compareDecls(old, new []decl) []DeclChange {
+	oldByKey := make(map[string]decl)
+	for _, d := range old {
+		oldByKey[d.kind+" "+d.name] = d
+	}
+	seen := make(map[string]bool)
+	var changes []DeclChange
+	for _, d := range new {
+		key := d.kind + " " + d.name
+		seen[key] = true
+		o, ok := oldByKey[key]
+		switch {
+		case !ok:
+			changes = append(changes, DeclChange{Kind: d.kind, Name: d.name, Change: "added"})
+		case o.text != d.text || o.sig != d.sig:
+			changes = append(changes, DeclChange{Kind: d.kind, Name: d.name, Change: "modified", Details: declDetails(o, d)})
+		}
+	}
+	for _, d := range old {
+		if !seen[d.kind+" "+d.name] {
+			changes = append(changes, DeclChange{Kind: d.kind, Name: d.name, Change: "removed"})
+		}
+	}
+	return changes
+}
+
+// declDetails says what changed in a modified declaration.
+func declDetails(old, new decl) []string {
+	var details []string
+	if old.sig != new.sig {
+		details = append(details, "signature changed")
+	}
+	if old.parts != nil || new.parts != nil {
+		for _, p := range new.partOrder {
+			o, ok := old.parts[p]
+			switch {
+			case !ok:
+				details = append(details, fmt.Sprintf("%s %s added", new.partKind, p))
+			case o != new.parts[p]:
+				details = append(details, fmt.Sprintf("%s %s changed", new.partKind, p))
+			}
+		}
+		for _, p := range old.partOrder {
+			if _, ok := new.parts[p]; !ok {
+				details = append(details, fmt.Sprintf("%s %s removed", old.partKind, p))
+			}
+		}
+		return details
+	}
+	if old.text != new.text {
+		details = append(details, "body changed")
+	}
+	return details
+}
+
+// goDecls lists the top-level declarations of a Go file, with struct
+// fields and interface methods as their parts. Comments don't count.
+func goDecls(src []byte) ([]decl, error) {
+	if src == nil {
+		return nil, nil
+	}
+	fset := gotoken.NewFileSet()
+	file, err := parser.ParseFile(fset, "", src, parser.SkipObjectResolution)
+	if err != nil {
+		return nil, err
+	}
+	text := func(n ast.Node) string {
+		if n == nil {
+			return ""
+		}
+		var b bytes.Buffer
+		printer.Fprint(&b, fset, n)
+		return b.String()
+	}
+
+	var decls []decl
+	for _, d := range file.Decls {
+		switch d := d.(type) {
+		case *ast.FuncDecl:
+			fd := decl{kind: "function", name: d.Name.Name, text: text(d.Body)}
+			if d.Recv != nil && len(d.Recv.List) > 0 {
+				fd.kind, fd.name = "method", receiverType(d.Recv.List[0].Type)+"."+d.Name.Name
+				fd.sig = text(d.Recv)
+			}
+			fd.sig += text(d.Type)
+			decls = append(decls, fd)
+
+		case *ast.GenDecl:
+			for _, spec := range d.Specs {
+				switch s := spec.(type) {
+				case *ast.ImportSpec:
+					path, _ := strconv.Unquote(s.Path.Value)
+					decls = append(decls, decl{kind: "import", name: strconv.Quote(path), text: text(s)})
+				case *ast.TypeSpec:
+					td := decl{kind: "type", name: s.Name.Name, sig: text(s.TypeParams)}
+					switch t := s.Type.(type) {
+					case *ast.StructType:
+						td.partKind = "field"
+						td.parts, td.partOrder = goFields(t.Fields, text)
+					case *ast.InterfaceType:
+						td.partKind = "method"
+						td.parts, td.partOrder = goFields(t.Methods, text)
+					default:
+						td.text = text(s.Type)
+					}
+					for _, p := range td.partOrder {
+						td.text += p + " " + td.parts[p] + "\n"
+					}
+					decls = append(decls, td)
+				case *ast.ValueSpec:
+					kind := "var"
+					if d.Tok == gotoken.CONST {
+						kind = "const"
+					}
+					for _, n := range s.Names {
+						if n.Name != "_" {
+							decls = append(decls, decl{kind: kind, name: n.Name, text: text(s)})
+						}
+					}
+				}
+			}
+		}
+	}
+	return decls, nil
+}
+
+// goFields lists the fields of a struct or the methods of an interface by
+// name, each with its type and tag. Embedded ones go by their type.
+func goFields(fields *ast.FieldList, text func(ast.Node) string) (map[string]string, []string) {
+	parts := make(map[string]string)
+	var order []string
+	add := func(name, typ string) {
+		if _, dup := parts[name]; !dup {
+			order = append(order, name)
+		}
+		parts[name] = typ
+	}
+	for _, f := range fields.List {
+		typ := text(f.Type)
+		if f.Tag != nil {
+			typ += " " + f.Tag.Value
+		}
+		if len(f.Names) == 0 {
+			add(typ, typ)
+		}
+		for _, n := range f.Names {
+			add(n.Name, typ)
+		}
+	}
+	return parts, order
+}
+
+// receiverType names a method's receiver type without pointers or type
+// parameters.
+func receiverType(expr ast.Expr) string {
+	switch t := expr.(type) {
+	case *ast.StarExpr:
+		return receiverType(t.X)
+	case *ast.IndexExpr:
+		return receiverType(t.X)
+	case *ast.IndexListExpr:
+		return receiverType(t.X)
+	case *ast.Ident:
+		return t.Name
+	}
+	return "?"
+}
+
+// declKeywordRe finds the kind and name of a declaration line naming its
+// kind, like "class Foo" or "pub struct Config".
+var declKeywordRe = regexp.MustCompile(`\b(class|struct|interface|enum|trait|union|mod|module|namespace|protocol|extension|actor|record|object|impl|defmodule|defprotocol|defimpl)\s+([\w.:$]+)`)
+
+// declNameRes find the name of any other declaration line, in order.
+var declNameRes = []*regexp.Regexp{
+	regexp.MustCompile(`^func\s+(?:\([^)]*\)\s*)?(\w+)`),
+	regexp.MustCompile(`\b(?:const|let|var)\s+([\w$]+)`),
+	regexp.MustCompile(`\bdef\w*\s+(?:self\.)?([\w.?!]+)`),
+	regexp.MustCompile(`([\w$~]+)\s*(?:<[^>]*>)?\s*\(`),
+}
+
+// indentDecls lists the declarations in src that the scope patterns for
+// name recognize. Each runs until the next line indented no deeper, plus a
+// closing line like "}" or "end". Nested ones are named after the one
+// enclosing them, and their lines don't count towards it.
+func indentDecls(name string, src []byte) []decl {
+	isScope := scopeMatcher(name)
+	if isScope == nil || src == nil {
+		return nil
+	}
+	lines := SplitLines(string(src))
+
+	type open struct {
+		index  int // into decls
+		indent int
+		end    int // last line, inclusive
+	}
+	var decls []decl
+	var stack []open
+	counts := make(map[string]int)
+	for i := 0; i < len(lines); i++ {
+		for len(stack) > 0 && stack[len(stack)-1].end < i {
+			stack = stack[:len(stack)-1]
+		}
+		t := strings.TrimSpace(lines[i])
+		if t == "" || !isScope(t) {
+			if len(stack) > 0 {
+				d := &decls[stack[len(stack)-1].index]
+				d.text += strings.Join(strings.Fields(t), " ") + "\n"
+			}
+			continue
+		}
+
+		indent := indentOf(lines[i])
+		end := declEnd(lines, i, indent)
+		kind, declName := declKindName(t)
+		if len(stack) > 0 {
+			parent := &decls[stack[len(stack)-1].index]
+			if kind == "function" && parent.kind != "function" {
+				kind = "method"
+			}
+			declName = parent.name + "." + declName
+		}
+		// Overloads and redefinitions get told apart by their order
+		key := kind + " " + declName
+		if counts[key]++; counts[key] > 1 {
+			declName = fmt.Sprintf("%s#%d", declName, counts[key])
+		}
+		decls = append(decls, decl{kind: kind, name: declName, sig: strings.Join(strings.Fields(t), " ")})
+		stack = append(stack, open{index: len(decls) - 1, indent: indent, end: end})
+	}
+	return decls
+}
+
+// declEnd returns the last line of the declaration starting at line i.
+func declEnd(lines []string, i, indent int) int {
+	end := i
+	for j := i + 1; j < len(lines); j++ {
+		if blank(lines[j]) {
+			continue
+		}
+		if indentOf(lines[j]) > indent {
+			end = j
+			continue
+		}
+		t := strings.TrimSpace(lines[j])
+		switch {
+		case strings.HasPrefix(t, ")") || strings.HasPrefix(t, "]"):
+			end = j // the rest of a long signature
+			continue
+		case strings.HasPrefix(t, "}") || t == "end" || strings.HasPrefix(t, "end "):
+			return j
+		}
+		return end
+	}
+	return end
+}
+
+// declKindName guesses what a declaration line declares and its name.
+func declKindName(line string) (kind, name string) {
+	if m := declKeywordRe.FindStringSubmatch(line); m != nil && !strings.Contains(line[:strings.Index(line, m[0])], "(") {
+		kind = m[1]
+		switch kind {
+		case "mod", "defmodule":
+			kind = "module"
+		case "defprotocol":
+			kind = "protocol"
+		case "defimpl":
+			kind = "impl"
+		}
+		return kind, strings.TrimRight(m[2], ":") // a Python class's colon
+	}
+	for _, re := range declNameRes {
+		if m := re.FindStringSubmatch(line); m != nil {
+			return "function", m[1]
+		}
+	}
+	return "function", scopeText(line)
+}
+
+// identRe matches the identifiers Symbols collects; shorter ones are too
+// common to say anything.
+var identRe = regexp.MustCompile(`[A-Za-z_$][\w$]{2,}`)
+
+// Symbols returns the names of the declarations f's changes touch, those
+// declared on changed lines and those enclosing its hunks, and the
+// identifiers its added lines use. A file using a name another declares is
+// likely part of the same change.
+func (f *File) Symbols() (declared, used []string) {
+	isScope := scopeMatcher(f.Name())
+	seenDecl := make(map[string]bool)
+	declare := func(line string) {
+		_, name := declKindName(strings.TrimSpace(line))
+		name = name[strings.LastIndexAny(name, ".:")+1:]
+		if identRe.FindString(name) == name && !seenDecl[name] {
+			seenDecl[name] = true
+			declared = append(declared, name)
+		}
+	}
+	seenUse := make(map[string]bool)
+	for _, frag := range f.Fragments {
+		if frag.Comment != "" && isScope != nil && isScope(strings.TrimSpace(frag.Comment)) {
+			declare(frag.Comment)
+		}
+		for _, l := range frag.Lines {
+			if l.Op == gitdiff.OpContext {
+				continue
+			}
+			if isScope != nil && isScope(strings.TrimSpace(l.Line)) {
+				declare(l.Line)
+			}
+			if l.Op != gitdiff.OpAdd {
+				continue
+			}
+			for _, id := range identRe.FindAllString(l.Line, -1) {
+				if !seenUse[id] {
+					seenUse[id] = true
+					used = append(used, id)
+				}
+			}
+		}
+	}
+	return declared, used
+}
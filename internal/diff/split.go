@@ -0,0 +1,176 @@
+package diff
+
+import (
+	"github.com/bluekeyes/go-gitdiff/gitdiff"
+)
+
+// SplitFragment splits a hunk into smaller ones, one for each run of
+// changed lines, as git add -p does. The context between two runs goes in
+// both hunks, so each applies on its own. A hunk with a single run of
+// changes comes back alone.
+func SplitFragment(frag *gitdiff.TextFragment) []*gitdiff.TextFragment {
+	// The runs of changes, as [start, end) indexes into frag.Lines
+	var runs [][2]int
+	for i := 0; i < len(frag.Lines); {
+		if frag.Lines[i].Op == gitdiff.OpContext {
+			i++
+			continue
+		}
+		start := i
+		for i < len(frag.Lines) && frag.Lines[i].Op != gitdiff.OpContext {
+			i++
+		}
+		runs = append(runs, [2]int{start, i})
+	}
+	if len(runs) < 2 {
+		return []*gitdiff.TextFragment{frag}
+	}
+
+	// Line numbers of each line on both sides
+	oldNum, newNum := frag.OldPosition, frag.NewPosition
+	oldAt := make([]int64, len(frag.Lines))
+	newAt := make([]int64, len(frag.Lines))
+	for i, l := range frag.Lines {
+		oldAt[i], newAt[i] = oldNum, newNum
+		if l.Op != gitdiff.OpAdd {
+			oldNum++
+		}
+		if l.Op != gitdiff.OpDelete {
+			newNum++
+		}
+	}
+
+	pieces := make([]*gitdiff.TextFragment, len(runs))
+	for k := range runs {
+		start, end := 0, len(frag.Lines)
+		if k > 0 {
+			start = runs[k-1][1]
+		}
+		if k < len(runs)-1 {
+			end = runs[k+1][0]
+		}
+		pieces[k] = &gitdiff.TextFragment{
+			Comment:     frag.Comment,
+			OldPosition: oldAt[start],
+			NewPosition: newAt[start],
+			Lines:       append([]gitdiff.Line(nil), frag.Lines[start:end]...),
+		}
+		recount(pieces[k])
+	}
+	return pieces
+}
+
+// JoinFragments prepares a selection of a file's hunks, in order, to be
+// written as a patch. Hunks split from the same one share context, which
+// git apply rejects, so overlapping hunks are merged; and the new-side
+// positions are recomputed as if only the selected hunks were applied. The
+// hunks passed in are left unchanged.
+func JoinFragments(frags []*gitdiff.TextFragment) []*gitdiff.TextFragment {
+	var out []*gitdiff.TextFragment
+	var offset int64 // lines the selected hunks so far added, less those they deleted
+	for _, frag := range frags {
+		if n := len(out); n > 0 {
+			prev := out[n-1]
+			if overlap := prev.OldPosition + prev.OldLines - frag.OldPosition; overlap > 0 {
+				// Skip the lines prev already covers
+				i := 0
+				for ; i < len(frag.Lines) && overlap > 0; i++ {
+					if frag.Lines[i].Op != gitdiff.OpAdd {
+						overlap--
+					}
+				}
+				prev.Lines = append(prev.Lines, frag.Lines[i:]...)
+				recount(prev)
+				offset += frag.LinesAdded - frag.LinesDeleted
+				continue
+			}
+		}
+
+		joined := *frag
+		joined.Lines = append([]gitdiff.Line(nil), frag.Lines...)
+		// An empty side's position names the line before the hunk
+		first := frag.OldPosition
+		if frag.OldLines == 0 {
+			first++
+		}
+		joined.NewPosition = first + offset
+		if frag.NewLines == 0 {
+			joined.NewPosition--
+		}
+		out = append(out, &joined)
+		offset += frag.LinesAdded - frag.LinesDeleted
+	}
+	return out
+}
+
+// SplitHunk splits hunk i of f in place with SplitFragment and returns the
+// number of hunks it became. Hunks after the first get the scope their own
+// changes are in. Merges' combined diffs aren't split, since their
+// Combined hunks would no longer line up.
+func (f *File) SplitHunk(i int) int {
+	if i < 0 || i >= len(f.Fragments) || f.Combined != nil {
+		return 0
+	}
+	frag := f.Fragments[i]
+	pieces := SplitFragment(frag)
+	if len(pieces) == 1 {
+		return 1
+	}
+
+	// Look for each later piece's scope in the old lines of the hunk
+	// before its changes
+	if isScope := scopeMatcher(f.OldName); isScope != nil {
+		var before []string
+		k := 0
+		for j, l := range frag.Lines {
+			if l.Op != gitdiff.OpContext && (j == 0 || frag.Lines[j-1].Op == gitdiff.OpContext) {
+				if c, indent := firstChange(pieces[k]); k > 0 && c >= 0 {
+					scope, closed := innermostScope(isScope, before, indent)
+					if scope == "" && !closed {
+						scope = frag.Comment
+					}
+					pieces[k].Comment = scope
+				}
+				k++
+			}
+			if l.Op != gitdiff.OpAdd {
+				before = append(before, l.Line)
+			}
+		}
+	}
+
+	frags := make([]*gitdiff.TextFragment, 0, len(f.Fragments)+len(pieces)-1)
+	frags = append(frags, f.Fragments[:i]...)
+	frags = append(frags, pieces...)
+	f.Fragments = append(frags, f.Fragments[i+1:]...)
+	return len(pieces)
+}
+
+// recount sets a hunk's line counts from its lines.
+func recount(tf *gitdiff.TextFragment) {
+	tf.OldLines, tf.NewLines = 0, 0
+	tf.LinesAdded, tf.LinesDeleted = 0, 0
+	tf.LeadingContext, tf.TrailingContext = 0, 0
+	for _, l := range tf.Lines {
+		switch l.Op {
+		case gitdiff.OpAdd:
+			tf.LinesAdded++
+			tf.NewLines++
+		case gitdiff.OpDelete:
+			tf.LinesDeleted++
+			tf.OldLines++
+		default:
+			tf.OldLines++
+			tf.NewLines++
+		}
+	}
+	for _, l := range tf.Lines {
+		if l.Op != gitdiff.OpContext {
+			break
+		}
+		tf.LeadingContext++
+	}
+	for k := len(tf.Lines) - 1; k >= 0 && tf.Lines[k].Op == gitdiff.OpContext; k-- {
+		tf.TrailingContext++
+	}
+}
@@ -0,0 +1,80 @@
+package diff
+
+import (
+	"path"
+	"sort"
+)
+
+// GroupStats totals the files in one group of a stats breakdown.
+type GroupStats struct {
+	Name    string
+	Files   int
+	Added   int
+	Deleted int
+}
+
+// Lines returns the number of lines the group changed.
+func (g GroupStats) Lines() int {
+	return g.Added + g.Deleted
+}
+
+// Percent returns the group's share of total lines changed, rounded.
+func (g GroupStats) Percent(total int) int {
+	if total == 0 {
+		return 0
+	}
+	return (g.Lines()*100 + total/2) / total
+}
+
+// StatsByLanguage breaks the stats down by each file's language, as named
+// by the syntax highlighter; files it doesn't recognize count as "Other".
+// Groups come most lines changed first.
+func (ds *DiffSet) StatsByLanguage() []GroupStats {
+	return ds.statsBy(func(f *File) string {
+		if lexer := lexerForFile(f.path()); lexer != nil {
+			return lexer.Config().Name
+		}
+		return "Other"
+	})
+}
+
+// StatsByDir breaks the stats down by the directory holding each file, "."
+// for the top level. Groups come most lines changed first.
+func (ds *DiffSet) StatsByDir() []GroupStats {
+	return ds.statsBy(func(f *File) string {
+		return path.Dir(f.path())
+	})
+}
+
+func (ds *DiffSet) statsBy(key func(*File) string) []GroupStats {
+	index := make(map[string]int)
+	var groups []GroupStats
+	for _, f := range ds.Files {
+		name := key(f)
+		i, ok := index[name]
+		if !ok {
+			i = len(groups)
+			index[name] = i
+			groups = append(groups, GroupStats{Name: name})
+		}
+		groups[i].Files++
+		groups[i].Added += f.AddedLines
+		groups[i].Deleted += f.DeletedLines
+	}
+	sort.SliceStable(groups, func(i, j int) bool {
+		if groups[i].Lines() != groups[j].Lines() {
+			return groups[i].Lines() > groups[j].Lines()
+		}
+		return groups[i].Name < groups[j].Name
+	})
+	return groups
+}
+
+// path returns the file's path after the change, or before it for a
+// deleted file.
+func (f *File) path() string {
+	if f.IsDeleted || f.NewName == "" {
+		return f.OldName
+	}
+	return f.NewName
+}
@@ -0,0 +1,70 @@
+package diff
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/bluekeyes/go-gitdiff/gitdiff"
+)
+
+// gitlinkMode is the mode git records for a submodule.
+const gitlinkMode = 0o160000
+
+// subprojectRe matches the single line git diffs for a submodule. The
+// -dirty suffix marks a working tree with uncommitted changes.
+var subprojectRe = regexp.MustCompile(`^Subproject commit ([0-9a-f]+)(-dirty)?\s*$`)
+
+// parseSubmodule marks df as a submodule if f changes one, recording the
+// commits it pointed at before and after. Patches without mode lines are
+// recognized by their content.
+func parseSubmodule(df *File, f *gitdiff.File) {
+	gitlink := f.OldMode == gitlinkMode || f.NewMode == gitlinkMode
+	if !gitlink && len(f.TextFragments) != 1 {
+		return
+	}
+
+	var oldCommit, newCommit string
+	var dirty bool
+	for _, frag := range f.TextFragments {
+		for _, l := range frag.Lines {
+			m := subprojectRe.FindStringSubmatch(l.Line)
+			if m == nil {
+				if gitlink {
+					continue
+				}
+				return
+			}
+			switch l.Op {
+			case gitdiff.OpDelete:
+				oldCommit = m[1]
+			case gitdiff.OpAdd:
+				newCommit, dirty = m[1], m[2] != ""
+			}
+		}
+	}
+	if oldCommit == "" && newCommit == "" {
+		return
+	}
+	df.IsSubmodule = true
+	df.OldCommit, df.NewCommit, df.Dirty = oldCommit, newCommit, dirty
+}
+
+// SubmoduleLog lists the commits between a submodule's old and new commits,
+// one "> hash subject" line for each commit gained and "< hash subject" for
+// each one dropped. It needs the submodule checked out in repoDir with both
+// commits fetched.
+func SubmoduleLog(repoDir string, f *File) ([]string, error) {
+	if !f.IsSubmodule || f.OldCommit == "" || f.NewCommit == "" || f.OldCommit == f.NewCommit {
+		return nil, nil
+	}
+	if repoDir == "" {
+		return nil, fmt.Errorf("no repository to read %s from", f.NewName)
+	}
+	out, err := git(filepath.Join(repoDir, f.NewName), "log", "--left-right", "--format=%m %h %s", f.OldCommit+"..."+f.NewCommit)
+	if err != nil {
+		return nil, err
+	}
+	return strings.Split(strings.TrimRight(out, "\n"), "\n"), nil
+}
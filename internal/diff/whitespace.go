@@ -0,0 +1,43 @@
+package diff
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/bluekeyes/go-gitdiff/gitdiff"
+)
+
+// WhitespaceOnly reports whether a hunk changes nothing but whitespace: its
+// deleted and added lines hold the same words in the same order. That covers
+// reindentation, trailing spaces, blank lines, and rewrapped lines, but not
+// whitespace added or removed inside a word, which can change meaning. Hunks
+// without changed lines don't count.
+func WhitespaceOnly(frag *gitdiff.TextFragment) bool {
+	var old, new []string
+	changed := false
+	for _, l := range frag.Lines {
+		switch l.Op {
+		case gitdiff.OpDelete:
+			old = append(old, strings.Fields(l.Line)...)
+			changed = true
+		case gitdiff.OpAdd:
+			new = append(new, strings.Fields(l.Line)...)
+			changed = true
+		}
+	}
+	return changed && slices.Equal(old, new)
+}
+
+// WhitespaceOnly reports whether every hunk of a modified file changes only
+// whitespace. New, deleted, renamed, and binary files never do.
+func (f *File) WhitespaceOnly() bool {
+	if f.IsNew || f.IsDeleted || f.IsRenamed || f.IsBinary || len(f.Fragments) == 0 {
+		return false
+	}
+	for _, frag := range f.Fragments {
+		if !WhitespaceOnly(frag) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,126 @@
+package explain
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aezell/agrev/internal/analysis"
+	"github.com/aezell/agrev/internal/diff"
+	"github.com/aezell/agrev/internal/trace"
+)
+
+// maxCommandOutput is how much of a command's output is sent as evidence.
+const maxCommandOutput = 400
+
+// Description is what a pull request description is written from: what
+// the agent was asked, what it did, what changed, and what analysis found.
+type Description struct {
+	Asked    []string // the user's messages to the agent, in order
+	Summary  string   // the trace's own summary
+	Commands []trace.Step
+	Diff     *diff.DiffSet // nil when there is no diff at hand
+	Commits  []diff.Commit
+	Findings []analysis.Finding
+}
+
+// NewDescription gathers a description from the trace t, which may be nil,
+// and the diff, its commits, and its findings.
+func NewDescription(t *trace.Trace, ds *diff.DiffSet, commits []diff.Commit, findings []analysis.Finding) Description {
+	d := Description{Diff: ds, Commits: commits, Findings: findings}
+	if t != nil {
+		d.Summary = t.Summary
+		for _, s := range t.StepsOfType(trace.StepUserMessage) {
+			d.Asked = append(d.Asked, s.Detail)
+		}
+		d.Commands = t.StepsOfType(trace.StepBash)
+	}
+	return d
+}
+
+const describePrompt = `You write pull request descriptions for code changes made by an AI coding
+agent. Use only the facts you are given. Write markdown with exactly these
+sections: "## Intent" (what was asked for and why, in a sentence or two),
+"## Changes" (bullets grouped by area, naming the files that matter),
+"## Risks" (the analysis findings worth a reviewer's attention and anything
+else that looks risky; say so if there are none), and "## Test evidence"
+(the tests and checks the agent ran and whether they passed; say plainly
+if nothing was run). Be concise; don't invent tests, issues, or motives.`
+
+// Prompt renders the description's facts as the user message sent to the
+// model.
+func (d Description) Prompt() string {
+	var b strings.Builder
+	if len(d.Asked) > 0 {
+		b.WriteString("What the agent was asked:\n")
+		for _, m := range d.Asked {
+			fmt.Fprintf(&b, "- %s\n", oneLine(m, 1000))
+		}
+		b.WriteString("\n")
+	}
+	if d.Summary != "" {
+		fmt.Fprintf(&b, "The agent's session, summarized:\n%s\n\n", strings.TrimSpace(d.Summary))
+	}
+
+	if d.Diff != nil {
+		files, added, deleted := d.Diff.Stats()
+		fmt.Fprintf(&b, "Files changed (%d, +%d -%d):\n", files, added, deleted)
+		for _, f := range d.Diff.Files {
+			fmt.Fprintf(&b, "- %s (+%d -%d)\n", f.Name(), f.AddedLines, f.DeletedLines)
+		}
+		b.WriteString("\n")
+	}
+	if len(d.Commits) > 0 {
+		b.WriteString("Commits:\n")
+		for _, c := range d.Commits {
+			fmt.Fprintf(&b, "- %s %s\n", c.ShortHash(), c.Subject)
+		}
+		b.WriteString("\n")
+	}
+
+	if len(d.Findings) > 0 {
+		b.WriteString("Analysis findings:\n")
+		for _, f := range d.Findings {
+			fmt.Fprintf(&b, "- (%s risk) %s\n", f.Risk, f)
+		}
+	} else {
+		b.WriteString("Analysis findings: none.\n")
+	}
+	b.WriteString("\n")
+
+	if len(d.Commands) > 0 {
+		b.WriteString("Commands the agent ran, with exit codes:\n")
+		for _, s := range d.Commands {
+			fmt.Fprintf(&b, "- `%s` (exit %d)\n", oneLine(s.Command, 200), s.ExitCode)
+			if out := strings.TrimSpace(s.Output); out != "" {
+				fmt.Fprintf(&b, "  output: %s\n", lastBytes(strings.Join(strings.Fields(out), " "), maxCommandOutput))
+			}
+		}
+	} else {
+		b.WriteString("Commands the agent ran: none recorded.\n")
+	}
+	b.WriteString("\nWrite the pull request description.\n")
+	return b.String()
+}
+
+// Describe sends the description's facts and returns the pull request
+// description the model writes, as markdown.
+func (c *Client) Describe(d Description) (string, error) {
+	return c.complete(describePrompt, d.Prompt())
+}
+
+// oneLine joins s onto one line, cut to n bytes.
+func oneLine(s string, n int) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if len(s) > n {
+		s = strings.ToValidUTF8(s[:n], "") + "…"
+	}
+	return s
+}
+
+// lastBytes is the last n bytes of s, where a command's verdict usually is.
+func lastBytes(s string, n int) string {
+	if len(s) > n {
+		return "…" + strings.ToValidUTF8(s[len(s)-n:], "")
+	}
+	return s
+}
@@ -0,0 +1,253 @@
+// Package explain asks a configured LLM to explain a hunk and the findings
+// on it, for 'agrev explain' and the TUI's explain key.
+package explain
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aezell/agrev/internal/analysis"
+	"github.com/aezell/agrev/internal/config"
+	"github.com/aezell/agrev/internal/diff"
+)
+
+// ErrDisabled is returned by New when no endpoint is configured.
+var ErrDisabled = errors.New("explanations are off; set explain.endpoint in .agrev.yml to enable them")
+
+// contextLines is how many lines of the file around a hunk are sent.
+const contextLines = 20
+
+const defaultMaxTokens = 1024
+
+// Client sends explanation requests to an LLM endpoint.
+type Client struct {
+	Endpoint  string
+	API       string // "openai" or "anthropic"
+	Model     string
+	APIKey    string
+	MaxTokens int
+	HTTP      *http.Client
+}
+
+// New returns a client for cfg, or ErrDisabled if cfg has no endpoint.
+func New(cfg config.ExplainConfig) (*Client, error) {
+	if cfg.Endpoint == "" {
+		return nil, ErrDisabled
+	}
+	c := &Client{
+		Endpoint:  cfg.Endpoint,
+		API:       cfg.API,
+		Model:     cfg.Model,
+		MaxTokens: cfg.MaxTokens,
+		HTTP:      &http.Client{Timeout: 2 * time.Minute},
+	}
+	switch c.API {
+	case "":
+		c.API = "openai"
+	case "openai", "anthropic":
+	default:
+		return nil, fmt.Errorf("unknown explain.api %q (want openai or anthropic)", c.API)
+	}
+	if c.MaxTokens <= 0 {
+		c.MaxTokens = defaultMaxTokens
+	}
+	if cfg.APIKeyEnv != "" {
+		c.APIKey = os.Getenv(cfg.APIKeyEnv)
+		if c.APIKey == "" {
+			return nil, fmt.Errorf("explain.api_key_env names %s, which is not set", cfg.APIKeyEnv)
+		}
+	}
+	return c, nil
+}
+
+// Request is what gets explained: one hunk, the file around it, and the
+// findings on it.
+type Request struct {
+	File     string
+	Hunk     string // the hunk in unified diff form
+	Context  []string
+	Start    int // line number of Context[0]
+	Findings []analysis.Finding
+}
+
+// NewRequest builds a request for hunk i of f. content is the file's new
+// content for surrounding context; it may be nil. Findings outside the hunk
+// are dropped, except file-level ones.
+func NewRequest(f *diff.File, i int, content []string, findings []analysis.Finding) Request {
+	frag := f.Fragments[i]
+	r := Request{File: f.Name(), Hunk: frag.String()}
+
+	first := int(frag.NewPosition)
+	last := first + int(frag.NewLines) - 1
+	// A new file's only hunk is the whole file already
+	if len(content) > 0 && !f.IsNew {
+		from := max(first-contextLines, 1)
+		to := min(last+contextLines, len(content))
+		if from <= to {
+			r.Context = content[from-1 : to]
+			r.Start = from
+		}
+	}
+
+	oldFirst := int(frag.OldPosition)
+	oldLast := oldFirst + int(frag.OldLines) - 1
+	for _, fin := range findings {
+		inNew := fin.Line >= first && fin.Line <= last
+		// Findings on deleted files point at old line numbers
+		inOld := f.IsDeleted && fin.Line >= oldFirst && fin.Line <= oldLast
+		if fin.Line == 0 || inNew || inOld {
+			r.Findings = append(r.Findings, fin)
+		}
+	}
+	return r
+}
+
+const systemPrompt = `You help a developer review code changes written by an AI coding agent.
+Explain what the change does and why it might have been made, in plain
+language. For each analysis finding, say whether it looks like a real
+problem and, if so, how to fix it. Be concise and concrete; use short
+markdown paragraphs or bullets and quote code only when it helps.`
+
+// Prompt renders the request as the user message sent to the model.
+func (r Request) Prompt() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "File: %s\n\n", r.File)
+	b.WriteString("Hunk under review:\n```diff\n")
+	b.WriteString(strings.TrimSuffix(r.Hunk, "\n"))
+	b.WriteString("\n```\n")
+
+	if len(r.Context) > 0 {
+		fmt.Fprintf(&b, "\nThe file after the change, lines %d-%d:\n```\n", r.Start, r.Start+len(r.Context)-1)
+		for i, line := range r.Context {
+			fmt.Fprintf(&b, "%5d  %s\n", r.Start+i, line)
+		}
+		b.WriteString("```\n")
+	}
+
+	if len(r.Findings) > 0 {
+		b.WriteString("\nAnalysis findings:\n")
+		for _, f := range r.Findings {
+			fmt.Fprintf(&b, "- (%s risk) %s\n", f.Risk, f)
+		}
+		b.WriteString("\nExplain the change, then address each finding and suggest a remediation where one is needed.\n")
+	} else {
+		b.WriteString("\nExplain the change and point out anything a reviewer should double-check.\n")
+	}
+	return b.String()
+}
+
+// Explain sends the request and returns the model's explanation as markdown.
+func (c *Client) Explain(r Request) (string, error) {
+	return c.complete(systemPrompt, r.Prompt())
+}
+
+// complete sends the system and user prompts and returns the model's
+// answer.
+func (c *Client) complete(system, prompt string) (string, error) {
+	var body any
+	if c.API == "anthropic" {
+		body = map[string]any{
+			"model":      c.Model,
+			"max_tokens": c.MaxTokens,
+			"system":     system,
+			"messages":   []map[string]string{{"role": "user", "content": prompt}},
+		}
+	} else {
+		body = map[string]any{
+			"model":      c.Model,
+			"max_tokens": c.MaxTokens,
+			"messages": []map[string]string{
+				{"role": "system", "content": system},
+				{"role": "user", "content": prompt},
+			},
+		}
+	}
+
+	data, err := c.post(body)
+	if err != nil {
+		return "", err
+	}
+
+	var text string
+	if c.API == "anthropic" {
+		var resp struct {
+			Content []struct {
+				Type string `json:"type"`
+				Text string `json:"text"`
+			} `json:"content"`
+		}
+		if err := json.Unmarshal(data, &resp); err != nil {
+			return "", fmt.Errorf("parsing response: %w", err)
+		}
+		for _, part := range resp.Content {
+			if part.Type == "text" {
+				text += part.Text
+			}
+		}
+	} else {
+		var resp struct {
+			Choices []struct {
+				Message struct {
+					Content string `json:"content"`
+				} `json:"message"`
+			} `json:"choices"`
+		}
+		if err := json.Unmarshal(data, &resp); err != nil {
+			return "", fmt.Errorf("parsing response: %w", err)
+		}
+		if len(resp.Choices) > 0 {
+			text = resp.Choices[0].Message.Content
+		}
+	}
+
+	if text = strings.TrimSpace(text); text == "" {
+		return "", fmt.Errorf("explain endpoint returned no text")
+	}
+	return text, nil
+}
+
+func (c *Client) post(body any) ([]byte, error) {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest("POST", c.Endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.API == "anthropic" {
+		req.Header.Set("anthropic-version", "2023-06-01")
+		if c.APIKey != "" {
+			req.Header.Set("x-api-key", c.APIKey)
+		}
+	} else if c.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.APIKey)
+	}
+
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("explain: %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg := strings.TrimSpace(string(data))
+		if len(msg) > 200 {
+			msg = msg[:200] + "..."
+		}
+		return nil, fmt.Errorf("explain: %s: %s", resp.Status, msg)
+	}
+	return data, nil
+}
@@ -0,0 +1,178 @@
+package explain
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/aezell/agrev/internal/analysis"
+	"github.com/aezell/agrev/internal/config"
+	"github.com/aezell/agrev/internal/diff"
+	"github.com/aezell/agrev/internal/model"
+	"github.com/aezell/agrev/internal/trace"
+)
+
+const testDiff = `diff --git a/auth.go b/auth.go
+index abc1234..def5678 100644
+--- a/auth.go
++++ b/auth.go
+@@ -2,3 +2,4 @@ package auth
+
+ func check(token string) bool {
+-	return token == secret
++	log.Println("token", token)
++	return token == secret
+@@ -20,2 +21,2 @@ func other() {
+-	a()
++	b()
+ }
+`
+
+func TestNewRequest(t *testing.T) {
+	ds, err := diff.Parse(testDiff)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := ds.Files[0]
+	content := make([]string, 40)
+	for i := range content {
+		content[i] = "line"
+	}
+	findings := []analysis.Finding{
+		{Pass: "security", File: "auth.go", Line: 4, Message: "logs a token", Risk: model.RiskHigh},
+		{Pass: "anti_patterns", File: "auth.go", Line: 21, Message: "renamed call"},
+		{Pass: "blast_radius", File: "auth.go", Message: "check has 12 references"},
+	}
+
+	r := NewRequest(f, 0, content, findings)
+	if !strings.HasPrefix(r.Hunk, "@@ -2,3 +2,4 @@") || !strings.Contains(r.Hunk, "+\tlog.Println") {
+		t.Errorf("unexpected hunk:\n%s", r.Hunk)
+	}
+	if r.Start != 1 || len(r.Context) != 25 {
+		t.Errorf("expected context lines 1-25, got %d from %d", len(r.Context), r.Start)
+	}
+	if len(r.Findings) != 2 || r.Findings[0].Pass != "security" || r.Findings[1].Pass != "blast_radius" {
+		t.Errorf("expected the hunk's finding and the file-level one, got %+v", r.Findings)
+	}
+
+	prompt := r.Prompt()
+	for _, want := range []string{"File: auth.go", "```diff", "    4  line", "(high risk) [security] auth.go:4: logs a token"} {
+		if !strings.Contains(prompt, want) {
+			t.Errorf("prompt missing %q:\n%s", want, prompt)
+		}
+	}
+}
+
+func TestNewDisabled(t *testing.T) {
+	if _, err := New(config.ExplainConfig{}); !errors.Is(err, ErrDisabled) {
+		t.Errorf("expected ErrDisabled, got %v", err)
+	}
+	if _, err := New(config.ExplainConfig{Endpoint: "http://x", API: "gopher"}); err == nil {
+		t.Error("expected error for unknown api")
+	}
+	t.Setenv("AGREV_TEST_KEY", "")
+	if _, err := New(config.ExplainConfig{Endpoint: "http://x", APIKeyEnv: "AGREV_TEST_KEY"}); err == nil {
+		t.Error("expected error for unset api key variable")
+	}
+}
+
+func TestExplain(t *testing.T) {
+	var got struct {
+		Model    string `json:"model"`
+		System   string `json:"system"`
+		Messages []struct {
+			Role    string `json:"role"`
+			Content string `json:"content"`
+		} `json:"messages"`
+	}
+	var header http.Header
+	var reply string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header
+		json.NewDecoder(r.Body).Decode(&got)
+		w.Write([]byte(reply))
+	}))
+	defer srv.Close()
+
+	req := Request{File: "a.go", Hunk: "@@ -1 +1 @@\n-a\n+b\n"}
+
+	t.Setenv("AGREV_TEST_KEY", "sk-test")
+	c, err := New(config.ExplainConfig{Endpoint: srv.URL, Model: "gpt-test", APIKeyEnv: "AGREV_TEST_KEY"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	reply = `{"choices":[{"message":{"role":"assistant","content":"Renames a to b."}}]}`
+	text, err := c.Explain(req)
+	if err != nil || text != "Renames a to b." {
+		t.Fatalf("openai: got %q, %v", text, err)
+	}
+	if header.Get("Authorization") != "Bearer sk-test" || got.Model != "gpt-test" {
+		t.Errorf("openai: unexpected request: %v %+v", header, got)
+	}
+	if len(got.Messages) != 2 || got.Messages[0].Role != "system" || !strings.Contains(got.Messages[1].Content, "File: a.go") {
+		t.Errorf("openai: unexpected messages: %+v", got.Messages)
+	}
+
+	c, err = New(config.ExplainConfig{Endpoint: srv.URL, API: "anthropic", Model: "claude-test", APIKeyEnv: "AGREV_TEST_KEY"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	reply = `{"content":[{"type":"text","text":"Renames "},{"type":"text","text":"a to b."}]}`
+	text, err = c.Explain(req)
+	if err != nil || text != "Renames a to b." {
+		t.Fatalf("anthropic: got %q, %v", text, err)
+	}
+	if header.Get("x-api-key") != "sk-test" || header.Get("anthropic-version") == "" || got.System == "" {
+		t.Errorf("anthropic: unexpected request: %v %+v", header, got)
+	}
+
+	reply = `{"content":[]}`
+	if _, err := c.Explain(req); err == nil {
+		t.Error("expected error for an empty explanation")
+	}
+}
+
+func TestDescribe(t *testing.T) {
+	var got struct {
+		System   string `json:"system"`
+		Messages []struct {
+			Content string `json:"content"`
+		} `json:"messages"`
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&got)
+		w.Write([]byte(`{"content":[{"type":"text","text":"## Intent\nFix the parser."}]}`))
+	}))
+	defer srv.Close()
+
+	ds, err := diff.Parse("diff --git a/a.go b/a.go\n--- a/a.go\n+++ b/a.go\n@@ -1 +1,2 @@\n-a\n+b\n+c\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := &trace.Trace{Summary: "Fixed the parser.", Steps: []trace.Step{
+		{Type: trace.StepUserMessage, Detail: "Fix the\nparser"},
+		{Type: trace.StepBash, Command: "go test ./...", ExitCode: 1, Output: "--- FAIL: TestParse"},
+	}}
+	d := NewDescription(tr, ds, nil, []analysis.Finding{{Pass: "security", File: "a.go", Line: 1, Message: "bad", Risk: model.RiskHigh}})
+	prompt := d.Prompt()
+	for _, want := range []string{"- Fix the parser\n", "- a.go (+2 -1)", "(high risk) [security] a.go:1: bad", "`go test ./...` (exit 1)", "output: --- FAIL: TestParse"} {
+		if !strings.Contains(prompt, want) {
+			t.Errorf("expected %q in the prompt:\n%s", want, prompt)
+		}
+	}
+
+	c, err := New(config.ExplainConfig{Endpoint: srv.URL, API: "anthropic"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	text, err := c.Describe(d)
+	if err != nil || text != "## Intent\nFix the parser." {
+		t.Fatalf("got %q, %v", text, err)
+	}
+	if !strings.Contains(got.System, "## Test evidence") || got.Messages[0].Content != prompt {
+		t.Errorf("unexpected request %+v", got)
+	}
+}
@@ -0,0 +1,243 @@
+// Package gate evaluates a diff and its analysis findings against a
+// repository's gate policy, for use as a CI check.
+package gate
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/aezell/agrev/internal/analysis"
+	"github.com/aezell/agrev/internal/config"
+	"github.com/aezell/agrev/internal/diff"
+	"github.com/aezell/agrev/internal/model"
+	"github.com/aezell/agrev/internal/owners"
+)
+
+// Rule names reported in violations.
+const (
+	RuleMaxRisk       = "max_risk"
+	RuleForbiddenPath = "forbidden_path"
+	RuleRequireTests  = "require_tests"
+	RuleOwnerApproval = "owner_approval"
+	RulePolicy        = "policy"
+)
+
+// Violation is a single policy failure.
+type Violation struct {
+	Rule    string          `json:"rule"`
+	Policy  string          `json:"policy,omitempty"` // the .agrev/policy.yml rule, for RulePolicy
+	Pass    string          `json:"pass,omitempty"`
+	File    string          `json:"file,omitempty"`
+	Line    int             `json:"line,omitempty"`
+	Message string          `json:"message"`
+	Risk    model.RiskLevel `json:"-"`
+}
+
+// Review is what the owner approval rule checks the diff against: who owns
+// its files, and how the saved review decided them.
+type Review struct {
+	Owners    *owners.File    // nil if the repository has no CODEOWNERS
+	Decisions model.Decisions // by index into the diff's files
+}
+
+// Evaluate checks ds and its findings, and the review of it if the policy
+// requires owner approval, against p and returns the violations, ordered by
+// file. review may be nil. It fails only if the policy itself is invalid.
+func Evaluate(p config.GatePolicy, ds *diff.DiffSet, results *analysis.Results, review *Review) ([]Violation, error) {
+	defaultMax, limits, err := riskLimits(p)
+	if err != nil {
+		return nil, err
+	}
+
+	var violations []Violation
+
+	for _, f := range results.Findings {
+		limit, ok := limits[f.Pass]
+		if !ok {
+			if defaultMax == nil {
+				continue
+			}
+			limit = *defaultMax
+		}
+		if f.Risk > limit {
+			violations = append(violations, Violation{
+				Rule:    RuleMaxRisk,
+				Pass:    f.Pass,
+				File:    f.File,
+				Line:    f.Line,
+				Message: fmt.Sprintf("%s risk exceeds allowed %s: %s", f.Risk, limit, f.Message),
+				Risk:    f.Risk,
+			})
+		}
+	}
+
+	for _, f := range ds.Files {
+		for _, name := range FilePaths(f) {
+			if pattern, ok := matchAny(p.ForbiddenPaths, name); ok {
+				violations = append(violations, Violation{
+					Rule:    RuleForbiddenPath,
+					File:    name,
+					Message: fmt.Sprintf("changes to %s are forbidden", pattern),
+				})
+				break
+			}
+		}
+	}
+
+	for _, rule := range p.RequireTests {
+		var untested []string
+		testsChanged := false
+		for _, f := range ds.Files {
+			name := f.NewName
+			if f.IsDeleted {
+				continue
+			}
+			if _, ok := matchAny(rule.Tests, name); ok {
+				testsChanged = true
+				continue
+			}
+			if _, ok := matchAny(rule.Paths, name); ok {
+				untested = append(untested, name)
+			}
+		}
+		if len(untested) == 0 || testsChanged {
+			continue
+		}
+		msg := fmt.Sprintf("source changed without tests matching %s", strings.Join(rule.Tests, ", "))
+		if len(untested) > 1 {
+			msg += fmt.Sprintf(" (%d files)", len(untested))
+		}
+		violations = append(violations, Violation{
+			Rule:    RuleRequireTests,
+			File:    untested[0],
+			Message: msg,
+		})
+	}
+
+	if p.RequireOwnerApproval && review != nil {
+		violations = append(violations, ownerApprovals(ds, review)...)
+	}
+
+	sort.SliceStable(violations, func(i, j int) bool {
+		return violations[i].File < violations[j].File
+	})
+	return violations, nil
+}
+
+// ownerApprovals reports the owned files of ds that the review didn't
+// approve one by one.
+func ownerApprovals(ds *diff.DiffSet, review *Review) []Violation {
+	var violations []Violation
+	for i, f := range ds.Files {
+		owners := review.Owners.Of(f)
+		if len(owners) == 0 {
+			continue
+		}
+		why := Unapproved(review.Decisions, i, len(f.Fragments))
+		if why == "" {
+			continue
+		}
+		violations = append(violations, Violation{
+			Rule:    RuleOwnerApproval,
+			File:    f.Name(),
+			Message: fmt.Sprintf("owned by %s; %s", strings.Join(owners, ", "), why),
+		})
+	}
+	return violations
+}
+
+// Unapproved says why a file with the given number of hunks doesn't count
+// as approved on its own, or returns "" if it does.
+func Unapproved(d model.Decisions, file, hunks int) string {
+	switch d.File(file, hunks) {
+	case model.DecisionApproved:
+		if d.Bulk[file] {
+			return "approved only in bulk, not on its own"
+		}
+		return ""
+	case model.DecisionPartial:
+		return "only partly approved"
+	case model.DecisionRejected:
+		return "rejected in the review"
+	default:
+		return "not approved in the review"
+	}
+}
+
+// riskLimits parses the policy's risk names.
+func riskLimits(p config.GatePolicy) (*model.RiskLevel, map[string]model.RiskLevel, error) {
+	var defaultMax *model.RiskLevel
+	if p.MaxRisk != "" {
+		r, ok := model.ParseRiskLevel(p.MaxRisk)
+		if !ok {
+			return nil, nil, fmt.Errorf("gate policy: unknown risk level %q for max_risk", p.MaxRisk)
+		}
+		defaultMax = &r
+	}
+	limits := make(map[string]model.RiskLevel, len(p.Passes))
+	for pass, name := range p.Passes {
+		r, ok := model.ParseRiskLevel(name)
+		if !ok {
+			return nil, nil, fmt.Errorf("gate policy: unknown risk level %q for pass %s", name, pass)
+		}
+		limits[pass] = r
+	}
+	return defaultMax, limits, nil
+}
+
+// FilePaths returns the paths a file change touches: both sides of a rename.
+func FilePaths(f *diff.File) []string {
+	switch {
+	case f.IsDeleted:
+		return []string{f.OldName}
+	case f.IsRenamed && f.OldName != f.NewName:
+		return []string{f.OldName, f.NewName}
+	default:
+		return []string{f.NewName}
+	}
+}
+
+// matchAny returns the first pattern matching name.
+func matchAny(patterns []string, name string) (string, bool) {
+	for _, p := range patterns {
+		if Match(p, name) {
+			return p, true
+		}
+	}
+	return "", false
+}
+
+// Match reports whether name matches the glob pattern. A "**" segment
+// matches any number of directories, and a pattern without a slash is
+// matched against the base name, so "*.sql" matches at any depth.
+func Match(pattern, name string) bool {
+	if !strings.Contains(pattern, "/") {
+		ok, _ := path.Match(pattern, path.Base(name))
+		return ok
+	}
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+func matchSegments(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			rest := pattern[1:]
+			for i := 0; i <= len(name); i++ {
+				if matchSegments(rest, name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], name[0]); !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
@@ -0,0 +1,156 @@
+package gate
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/aezell/agrev/internal/analysis"
+	"github.com/aezell/agrev/internal/config"
+	"github.com/aezell/agrev/internal/diff"
+	"github.com/aezell/agrev/internal/model"
+	"github.com/aezell/agrev/internal/owners"
+)
+
+const gateDiff = `diff --git a/internal/store/store.go b/internal/store/store.go
+index abc1234..def5678 100644
+--- a/internal/store/store.go
++++ b/internal/store/store.go
+@@ -1,3 +1,4 @@
+ package store
+
++var debug = true
+ func Open() {}
+diff --git a/db/migrations/001_init.sql b/db/migrations/001_init.sql
+new file mode 100644
+index 0000000..1111111
+--- /dev/null
++++ b/db/migrations/001_init.sql
+@@ -0,0 +1 @@
++DROP TABLE users;
+`
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		pattern, name string
+		want          bool
+	}{
+		{"*.sql", "db/migrations/001_init.sql", true},
+		{"db/**", "db/migrations/001_init.sql", true},
+		{"db/*", "db/migrations/001_init.sql", false},
+		{"**/*_test.go", "internal/store/store_test.go", true},
+		{"**/*_test.go", "store_test.go", true},
+		{"internal/**/*.go", "internal/store/store.go", true},
+		{"internal/**/*.go", "cmd/main.go", false},
+		{".github/workflows/*", ".github/workflows/ci.yml", true},
+	}
+	for _, tt := range tests {
+		if got := Match(tt.pattern, tt.name); got != tt.want {
+			t.Errorf("Match(%q, %q) = %v, want %v", tt.pattern, tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	ds, err := diff.Parse(gateDiff)
+	if err != nil {
+		t.Fatal(err)
+	}
+	results := &analysis.Results{Findings: []analysis.Finding{
+		{Pass: "schema", File: "db/migrations/001_init.sql", Line: 1, Message: "drops table", Risk: model.RiskCritical},
+		{Pass: "security", File: "internal/store/store.go", Line: 3, Message: "debug flag", Risk: model.RiskMedium},
+		{Pass: "anti_patterns", File: "internal/store/store.go", Line: 3, Message: "global var", Risk: model.RiskLow},
+	}}
+
+	policy := config.GatePolicy{
+		MaxRisk:        "high",
+		Passes:         map[string]string{"security": "low"},
+		ForbiddenPaths: []string{"db/migrations/**"},
+		RequireTests: []config.TestRule{
+			{Paths: []string{"internal/**/*.go"}, Tests: []string{"**/*_test.go"}},
+		},
+	}
+
+	violations, err := Evaluate(policy, ds, results, nil)
+	if err != nil {
+		t.Fatalf("Evaluate failed: %v", err)
+	}
+
+	got := make(map[string]int)
+	for _, v := range violations {
+		got[v.Rule]++
+	}
+	want := map[string]int{RuleMaxRisk: 2, RuleForbiddenPath: 1, RuleRequireTests: 1}
+	for rule, n := range want {
+		if got[rule] != n {
+			t.Errorf("expected %d %s violations, got %d: %+v", n, rule, got[rule], violations)
+		}
+	}
+	for _, v := range violations {
+		if v.Pass == "anti_patterns" {
+			t.Errorf("low-risk finding under max_risk high should pass: %+v", v)
+		}
+	}
+}
+
+func TestEvaluateTestsSatisfied(t *testing.T) {
+	ds, err := diff.Parse(gateDiff + `diff --git a/internal/store/store_test.go b/internal/store/store_test.go
+index abc1234..def5678 100644
+--- a/internal/store/store_test.go
++++ b/internal/store/store_test.go
+@@ -1 +1,2 @@
+ package store
++// covered
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	policy := config.GatePolicy{RequireTests: []config.TestRule{
+		{Paths: []string{"internal/**/*.go"}, Tests: []string{"**/*_test.go"}},
+	}}
+	violations, err := Evaluate(policy, ds, &analysis.Results{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(violations) != 0 {
+		t.Errorf("expected no violations, got %+v", violations)
+	}
+}
+
+func TestEvaluateInvalidPolicy(t *testing.T) {
+	ds := &diff.DiffSet{}
+	if _, err := Evaluate(config.GatePolicy{MaxRisk: "severe"}, ds, &analysis.Results{}, nil); err == nil {
+		t.Error("expected error for unknown risk level")
+	}
+}
+
+func TestEvaluateOwnerApproval(t *testing.T) {
+	ds, err := diff.Parse(gateDiff)
+	if err != nil {
+		t.Fatal(err)
+	}
+	co, err := owners.Parse(strings.NewReader("/internal/ @org/core\n*.sql @org/dba @ada\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	review := &Review{Owners: co, Decisions: model.NewDecisions()}
+	review.Decisions.SetFile(0, model.DecisionApproved)
+	review.Decisions.SetBulk(1, model.DecisionApproved)
+
+	policy := config.GatePolicy{RequireOwnerApproval: true}
+	violations, err := Evaluate(policy, ds, &analysis.Results{}, review)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(violations) != 1 || violations[0].Rule != RuleOwnerApproval || violations[0].File != "db/migrations/001_init.sql" ||
+		violations[0].Message != "owned by @org/dba, @ada; approved only in bulk, not on its own" {
+		t.Fatalf("expected the bulk-approved migration to fail, got %+v", violations)
+	}
+
+	review.Decisions.SetFile(1, model.DecisionApproved)
+	if violations, _ := Evaluate(policy, ds, &analysis.Results{}, review); len(violations) != 0 {
+		t.Errorf("expected explicit approvals to pass, got %+v", violations)
+	}
+	if violations, _ := Evaluate(policy, ds, &analysis.Results{}, &Review{Owners: co}); len(violations) != 2 {
+		t.Errorf("expected both owned files to fail without a review, got %+v", violations)
+	}
+}
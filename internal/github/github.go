@@ -0,0 +1,209 @@
+// Package github fetches pull requests from the GitHub REST API.
+package github
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultBaseURL is the public GitHub API. GITHUB_API_URL overrides it for
+// GitHub Enterprise.
+const DefaultBaseURL = "https://api.github.com"
+
+// PullRequest holds the pull request metadata agrev uses.
+type PullRequest struct {
+	Number  int    `json:"number"`
+	Title   string `json:"title"`
+	Body    string `json:"body"`
+	State   string `json:"state"`
+	HTMLURL string `json:"html_url"`
+	User    struct {
+		Login string `json:"login"`
+	} `json:"user"`
+	Head Branch `json:"head"`
+	Base Branch `json:"base"`
+}
+
+// Branch is one side of a pull request.
+type Branch struct {
+	Ref string `json:"ref"`
+	SHA string `json:"sha"`
+}
+
+// Issue holds the issue metadata agrev uses. Pull requests are issues too.
+type Issue struct {
+	Number  int    `json:"number"`
+	Title   string `json:"title"`
+	State   string `json:"state"` // "open" or "closed"
+	HTMLURL string `json:"html_url"`
+}
+
+// Ref identifies a pull request.
+type Ref struct {
+	Owner  string
+	Repo   string
+	Number int
+}
+
+func (r Ref) String() string {
+	return fmt.Sprintf("%s/%s#%d", r.Owner, r.Repo, r.Number)
+}
+
+// ParseRef parses a pull request given as a number, "owner/repo#123", or a
+// URL like https://github.com/owner/repo/pull/123. A bare number needs repo
+// ("owner/name").
+func ParseRef(arg, repo string) (Ref, error) {
+	arg = strings.TrimSpace(arg)
+
+	if strings.Contains(arg, "://") {
+		u, err := url.Parse(arg)
+		if err != nil {
+			return Ref{}, fmt.Errorf("invalid pull request URL: %w", err)
+		}
+		parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+		if len(parts) < 4 || (parts[2] != "pull" && parts[2] != "pulls") {
+			return Ref{}, fmt.Errorf("%s is not a pull request URL", arg)
+		}
+		n, err := strconv.Atoi(parts[3])
+		if err != nil || n <= 0 {
+			return Ref{}, fmt.Errorf("%s is not a pull request URL", arg)
+		}
+		return Ref{Owner: parts[0], Repo: parts[1], Number: n}, nil
+	}
+
+	if r, num, ok := strings.Cut(arg, "#"); ok && r != "" {
+		repo, arg = r, num
+	}
+	n, err := strconv.Atoi(strings.TrimPrefix(arg, "#"))
+	if err != nil || n <= 0 {
+		return Ref{}, fmt.Errorf("invalid pull request %q: want a number or URL", arg)
+	}
+	owner, name, ok := strings.Cut(repo, "/")
+	if !ok || owner == "" || name == "" || strings.Contains(name, "/") {
+		return Ref{}, fmt.Errorf("repository %q must be owner/name", repo)
+	}
+	return Ref{Owner: owner, Repo: name, Number: n}, nil
+}
+
+// Token finds an API token in GITHUB_TOKEN or GH_TOKEN, falling back to the
+// gh CLI's stored login. An empty token means unauthenticated requests.
+func Token() string {
+	for _, env := range []string{"GITHUB_TOKEN", "GH_TOKEN"} {
+		if t := os.Getenv(env); t != "" {
+			return t
+		}
+	}
+	out, err := exec.Command("gh", "auth", "token").Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// Client is a minimal GitHub API client.
+type Client struct {
+	BaseURL string
+	Token   string
+	HTTP    *http.Client
+}
+
+// NewClient returns a client for the API at GITHUB_API_URL (or the public
+// API) authenticated with Token.
+func NewClient() *Client {
+	base := os.Getenv("GITHUB_API_URL")
+	if base == "" {
+		base = DefaultBaseURL
+	}
+	return &Client{
+		BaseURL: strings.TrimRight(base, "/"),
+		Token:   Token(),
+		HTTP:    &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// PullRequest fetches a pull request's metadata.
+func (c *Client) PullRequest(ref Ref) (*PullRequest, error) {
+	body, err := c.get(ref, "application/vnd.github+json")
+	if err != nil {
+		return nil, err
+	}
+	var pr PullRequest
+	if err := json.Unmarshal(body, &pr); err != nil {
+		return nil, fmt.Errorf("decoding pull request: %w", err)
+	}
+	return &pr, nil
+}
+
+// Diff fetches a pull request's diff against its base.
+func (c *Client) Diff(ref Ref) (string, error) {
+	body, err := c.get(ref, "application/vnd.github.diff")
+	if err != nil {
+		return "", err
+	}
+	return string(body), nil
+}
+
+// Issue fetches the issue or pull request numbered as ref is.
+func (c *Client) Issue(ref Ref) (*Issue, error) {
+	body, err := c.fetch(ref, "issues", "application/vnd.github+json")
+	if err != nil {
+		return nil, err
+	}
+	var issue Issue
+	if err := json.Unmarshal(body, &issue); err != nil {
+		return nil, fmt.Errorf("decoding issue: %w", err)
+	}
+	return &issue, nil
+}
+
+func (c *Client) get(ref Ref, accept string) ([]byte, error) {
+	return c.fetch(ref, "pulls", accept)
+}
+
+// fetch gets ref from the API's collection of pulls or issues.
+func (c *Client) fetch(ref Ref, collection, accept string) ([]byte, error) {
+	u := fmt.Sprintf("%s/repos/%s/%s/%s/%d", c.BaseURL,
+		url.PathEscape(ref.Owner), url.PathEscape(ref.Repo), collection, ref.Number)
+	req, err := http.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", accept)
+	req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+	if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetching %s: %w", ref, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", ref, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		var apiErr struct {
+			Message string `json:"message"`
+		}
+		json.Unmarshal(body, &apiErr)
+		if apiErr.Message == "" {
+			apiErr.Message = resp.Status
+		}
+		if (resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusUnauthorized) && c.Token == "" {
+			apiErr.Message += " (no token found; set GITHUB_TOKEN or run 'gh auth login')"
+		}
+		return nil, fmt.Errorf("fetching %s: %s", ref, apiErr.Message)
+	}
+	return body, nil
+}
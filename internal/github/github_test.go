@@ -0,0 +1,112 @@
+package github
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestParseRef(t *testing.T) {
+	tests := []struct {
+		arg, repo string
+		want      Ref
+	}{
+		{"123", "aezell/agrev", Ref{"aezell", "agrev", 123}},
+		{"#7", "aezell/agrev", Ref{"aezell", "agrev", 7}},
+		{"octo/cat#42", "", Ref{"octo", "cat", 42}},
+		{"https://github.com/octo/cat/pull/42", "", Ref{"octo", "cat", 42}},
+		{"https://github.com/octo/cat/pull/42/files", "aezell/agrev", Ref{"octo", "cat", 42}},
+	}
+	for _, tt := range tests {
+		got, err := ParseRef(tt.arg, tt.repo)
+		if err != nil {
+			t.Errorf("ParseRef(%q, %q) failed: %v", tt.arg, tt.repo, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseRef(%q, %q) = %+v, want %+v", tt.arg, tt.repo, got, tt.want)
+		}
+	}
+
+	for _, bad := range [][2]string{
+		{"123", ""},
+		{"abc", "octo/cat"},
+		{"https://github.com/octo/cat/issues/42", ""},
+		{"0", "octo/cat"},
+	} {
+		if _, err := ParseRef(bad[0], bad[1]); err == nil {
+			t.Errorf("ParseRef(%q, %q) should fail", bad[0], bad[1])
+		}
+	}
+}
+
+func TestClientFetch(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/repos/octo/cat/pulls/42" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.Header.Get("Accept") == "application/vnd.github.diff" {
+			w.Write([]byte("diff --git a/x b/x\n"))
+			return
+		}
+		w.Write([]byte(`{"number":42,"title":"Add cat","user":{"login":"octo"},"head":{"ref":"feature","sha":"abc123"},"base":{"ref":"main","sha":"def456"}}`))
+	}))
+	defer srv.Close()
+
+	c := &Client{BaseURL: srv.URL, Token: "secret", HTTP: srv.Client()}
+	ref := Ref{"octo", "cat", 42}
+
+	pr, err := c.PullRequest(ref)
+	if err != nil {
+		t.Fatalf("PullRequest failed: %v", err)
+	}
+	if pr.Title != "Add cat" || pr.User.Login != "octo" || pr.Head.SHA != "abc123" || pr.Base.Ref != "main" {
+		t.Errorf("unexpected pull request: %+v", pr)
+	}
+
+	d, err := c.Diff(ref)
+	if err != nil {
+		t.Fatalf("Diff failed: %v", err)
+	}
+	if d != "diff --git a/x b/x\n" {
+		t.Errorf("unexpected diff %q", d)
+	}
+
+	if _, err := c.PullRequest(Ref{"octo", "dog", 1}); err == nil {
+		t.Error("expected error for missing pull request")
+	}
+}
+
+func TestCreateReview(t *testing.T) {
+	var got Review
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/repos/octo/cat/pulls/42/reviews" {
+			http.NotFound(w, r)
+			return
+		}
+		json.NewDecoder(r.Body).Decode(&got)
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"html_url":"https://github.com/octo/cat/pull/42#pullrequestreview-1"}`))
+	}))
+	defer srv.Close()
+
+	c := &Client{BaseURL: srv.URL, HTTP: srv.Client()}
+	review := Review{CommitID: "abc123", Body: "summary", Event: "COMMENT",
+		Comments: []ReviewComment{{Path: "main.go", Position: 3, Body: "risky"}}}
+	link, err := c.CreateReview(Ref{"octo", "cat", 42}, review)
+	if err != nil {
+		t.Fatalf("CreateReview failed: %v", err)
+	}
+	if link == "" {
+		t.Error("expected review URL")
+	}
+	if got.CommitID != "abc123" || len(got.Comments) != 1 || got.Comments[0].Position != 3 {
+		t.Errorf("unexpected payload: %+v", got)
+	}
+}
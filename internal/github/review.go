@@ -0,0 +1,65 @@
+package github
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+)
+
+// Review is a pull request review: a summary body plus inline comments.
+type Review struct {
+	CommitID string          `json:"commit_id,omitempty"`
+	Body     string          `json:"body"`
+	Event    string          `json:"event"`
+	Comments []ReviewComment `json:"comments,omitempty"`
+}
+
+// ReviewComment is an inline comment at a position in a file's diff.
+type ReviewComment struct {
+	Path     string `json:"path"`
+	Position int    `json:"position"`
+	Body     string `json:"body"`
+}
+
+// CreateReview submits a review on the pull request and returns its URL.
+func (c *Client) CreateReview(ref Ref, review Review) (string, error) {
+	payload, err := json.Marshal(review)
+	if err != nil {
+		return "", err
+	}
+	u := fmt.Sprintf("%s/repos/%s/%s/pulls/%d/reviews", c.BaseURL,
+		url.PathEscape(ref.Owner), url.PathEscape(ref.Repo), ref.Number)
+	req, err := http.NewRequest(http.MethodPost, u, bytes.NewReader(payload))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+	if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("posting review on %s: %w", ref, err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	var out struct {
+		HTMLURL string `json:"html_url"`
+		Message string `json:"message"`
+	}
+	json.Unmarshal(body, &out)
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		if out.Message == "" {
+			out.Message = resp.Status
+		}
+		return "", fmt.Errorf("posting review on %s: %s", ref, out.Message)
+	}
+	return out.HTMLURL, nil
+}
@@ -0,0 +1,370 @@
+// Package group clusters the files of a diff into change groups by intent,
+// so related changes can be reviewed and approved together.
+package group
+
+import (
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/aezell/agrev/internal/analysis"
+	"github.com/aezell/agrev/internal/diff"
+	"github.com/aezell/agrev/internal/model"
+	"github.com/aezell/agrev/internal/trace"
+)
+
+// maxLabel is the longest label taken from a user message, in bytes. Longer
+// messages are cut at a word.
+const maxLabel = 60
+
+// maxLabelDirs is how many directories a directory group's label names.
+const maxLabelDirs = 3
+
+// intent is what the user asked for in one message of a trace, with the
+// edits the agent made to each file of the diff before the next message.
+type intent struct {
+	label, text string
+	edits       map[int]int // file index -> edits
+}
+
+// Build clusters the files of ds into change groups. With a trace, files the
+// agent edited after the same user message go together, labelled with that
+// message; a file edited for several messages goes with the one it was
+// edited for most. Files the trace doesn't account for join the group of a
+// file whose declarations they use, or that uses theirs, and the rest are
+// grouped by directory. A group depends on the groups declaring names it
+// uses. Risk is the highest finding risk among a group's files; results and
+// t may be nil.
+func Build(ds *diff.DiffSet, t *trace.Trace, results *analysis.Results) []model.ChangeGroup {
+	if len(ds.Files) == 0 {
+		return nil
+	}
+	intents := traceIntents(ds, t)
+
+	u := newUnion(len(ds.Files))
+	first := make(map[int]int) // intent -> its first file
+	for i := range ds.Files {
+		best := 0
+		for k, in := range intents {
+			if n := in.edits[i]; n > best {
+				u.intent[i], best = k, n
+			}
+		}
+		if k := u.intent[i]; k >= 0 {
+			if f, ok := first[k]; ok {
+				u.join(f, i)
+			} else {
+				first[k] = i
+			}
+		}
+	}
+
+	// Shared symbols: a name only one file declares links the files using it
+	// to that one
+	edges := symbolEdges(ds)
+	var joined []edge
+	for _, e := range edges {
+		if u.join(e.user, e.decl) {
+			joined = append(joined, e)
+		}
+	}
+
+	// Directories, for what is left
+	byDir := make(map[string]int)
+	for i, f := range ds.Files {
+		if u.intent[u.find(i)] >= 0 {
+			continue
+		}
+		dir := path.Dir(filePath(f))
+		if j, ok := byDir[dir]; ok {
+			u.join(j, i)
+		} else {
+			byDir[dir] = i
+		}
+	}
+
+	// Groups of user messages first, in the order they were asked, then the
+	// others in diff order
+	index := make(map[int]int) // root -> group
+	var members [][]int
+	add := func(i int) {
+		r := u.find(i)
+		g, ok := index[r]
+		if !ok {
+			g = len(members)
+			index[r] = g
+			members = append(members, nil)
+		}
+		members[g] = append(members[g], i)
+	}
+	for k := range intents {
+		for i := range ds.Files {
+			if u.intent[u.find(i)] == k {
+				add(i)
+			}
+		}
+	}
+	for i := range ds.Files {
+		if u.intent[u.find(i)] < 0 {
+			add(i)
+		}
+	}
+
+	groups := make([]model.ChangeGroup, len(members))
+	for g, files := range members {
+		cg := model.ChangeGroup{ID: fmt.Sprintf("g%d", g+1)}
+		for _, i := range files {
+			cg.Files = append(cg.Files, ds.Files[i].Name())
+		}
+		if k := u.intent[u.find(files[0])]; k >= 0 {
+			cg.Label, cg.Intent = intents[k].label, intents[k].text
+		} else if name := sharedSymbol(joined, u, files[0]); name != "" {
+			cg.Label, cg.Intent = "Changes around "+name, "files sharing "+name
+		} else {
+			cg.Label = dirLabel(ds, files)
+			cg.Intent = "files in the same directory"
+		}
+		cg.Risk = maxRisk(results, cg.Files)
+		groups[g] = cg
+	}
+
+	for _, e := range edges {
+		from, to := index[u.find(e.user)], index[u.find(e.decl)]
+		if from != to && !slices.Contains(groups[from].DependsOn, groups[to].ID) {
+			groups[from].DependsOn = append(groups[from].DependsOn, groups[to].ID)
+		}
+	}
+	return groups
+}
+
+// traceIntents splits t at its user messages, counting the edits made to
+// each file of ds after each. Edits before the first message go under the
+// agent's first plan, if it has one.
+func traceIntents(ds *diff.DiffSet, t *trace.Trace) []intent {
+	if t == nil {
+		return nil
+	}
+	files := fileIndex(ds)
+	var intents []intent
+	current := -1
+	plan := ""
+	for _, s := range t.Steps {
+		switch s.Type {
+		case trace.StepUserMessage:
+			text := strings.TrimSpace(s.Detail)
+			if text == "" {
+				text = strings.TrimSpace(s.Summary)
+			}
+			intents = append(intents, intent{label: label(text), text: text, edits: make(map[int]int)})
+			current = len(intents) - 1
+		case trace.StepPlan:
+			if plan == "" {
+				plan = strings.TrimSpace(s.Summary)
+			}
+		case trace.StepFileWrite, trace.StepFileEdit:
+			i := files(s.FilePath)
+			if i < 0 {
+				continue
+			}
+			if current < 0 {
+				intents = append(intents, intent{label: label(plan), text: plan, edits: make(map[int]int)})
+				current = 0
+			}
+			intents[current].edits[i]++
+		}
+	}
+	return intents
+}
+
+// fileIndex returns a function finding the file of ds a trace path names:
+// the file whose path it ends with, or failing that the only file with its
+// base name. It returns -1 when there is none.
+func fileIndex(ds *diff.DiffSet) func(string) int {
+	byBase := make(map[string]int)
+	for i, f := range ds.Files {
+		base := path.Base(filePath(f))
+		if _, dup := byBase[base]; dup {
+			byBase[base] = -1
+		} else {
+			byBase[base] = i
+		}
+	}
+	return func(p string) int {
+		if p == "" {
+			return -1
+		}
+		p = strings.ReplaceAll(p, `\`, "/")
+		for i, f := range ds.Files {
+			for _, name := range []string{f.NewName, f.OldName} {
+				if name != "" && (p == name || strings.HasSuffix(p, "/"+name)) {
+					return i
+				}
+			}
+		}
+		if i, ok := byBase[path.Base(p)]; ok {
+			return i
+		}
+		return -1
+	}
+}
+
+// filePath is the path a file has after the change, or had before it for a
+// deleted file.
+func filePath(f *diff.File) string {
+	if f.IsDeleted || f.NewName == "" {
+		return f.OldName
+	}
+	return f.NewName
+}
+
+// edge links a file using a name to the file declaring it.
+type edge struct {
+	user, decl int
+	name       string
+}
+
+// symbolEdges finds the files using a name that exactly one other file of
+// ds declares. Names declared in several files, like String or main, link
+// nothing.
+func symbolEdges(ds *diff.DiffSet) []edge {
+	declaredBy := make(map[string]int)
+	used := make([][]string, len(ds.Files))
+	for i, f := range ds.Files {
+		if f.Generated() {
+			continue
+		}
+		var declared []string
+		declared, used[i] = f.Symbols()
+		for _, name := range declared {
+			if _, dup := declaredBy[name]; dup {
+				declaredBy[name] = -1
+			} else {
+				declaredBy[name] = i
+			}
+		}
+	}
+	var edges []edge
+	for i, names := range used {
+		for _, name := range names {
+			if d, ok := declaredBy[name]; ok && d >= 0 && d != i {
+				edges = append(edges, edge{user: i, decl: d, name: name})
+			}
+		}
+	}
+	return edges
+}
+
+// sharedSymbol returns the first name that joined the group of file i, or
+// "" if none did.
+func sharedSymbol(joined []edge, u *union, i int) string {
+	r := u.find(i)
+	for _, e := range joined {
+		if u.find(e.user) == r {
+			return e.name
+		}
+	}
+	return ""
+}
+
+// label makes a group label of a user message: its first line, cut to
+// maxLabel at a word, capitalized and without closing punctuation.
+func label(msg string) string {
+	line, _, _ := strings.Cut(strings.TrimSpace(msg), "\n")
+	line = strings.TrimRight(strings.TrimSpace(line), ".!:;,")
+	if line == "" {
+		return "Agent changes"
+	}
+	if len(line) > maxLabel {
+		cut := strings.LastIndexByte(line[:maxLabel], ' ')
+		if cut <= 0 {
+			cut = maxLabel
+			for !utf8.RuneStart(line[cut]) {
+				cut--
+			}
+		}
+		line = strings.TrimRight(line[:cut], " ,;:") + "…"
+	}
+	r, size := utf8.DecodeRuneInString(line)
+	return string(unicode.ToUpper(r)) + line[size:]
+}
+
+// dirLabel names the directories of a group's files.
+func dirLabel(ds *diff.DiffSet, files []int) string {
+	var dirs []string
+	for _, i := range files {
+		dir := path.Dir(filePath(ds.Files[i]))
+		if dir == "." {
+			dir = "the top level"
+		} else {
+			dir += "/"
+		}
+		if !slices.Contains(dirs, dir) {
+			dirs = append(dirs, dir)
+		}
+	}
+	if len(dirs) > maxLabelDirs {
+		dirs = append(dirs[:maxLabelDirs], "…")
+	}
+	if dirs[0] == "the top level" && len(dirs) == 1 {
+		return "Changes at the top level"
+	}
+	return "Changes in " + strings.Join(dirs, ", ")
+}
+
+// maxRisk is the highest risk of the findings on files.
+func maxRisk(results *analysis.Results, files []string) model.RiskLevel {
+	risk := model.RiskInfo
+	if results == nil {
+		return risk
+	}
+	for _, f := range results.Findings {
+		if f.Risk > risk && slices.Contains(files, f.File) {
+			risk = f.Risk
+		}
+	}
+	return risk
+}
+
+// union is a union-find over file indexes in which each set belongs to at
+// most one intent: sets of two different intents are never joined.
+type union struct {
+	parent []int
+	intent []int // for roots: the set's intent, or -1
+}
+
+func newUnion(n int) *union {
+	u := &union{parent: make([]int, n), intent: make([]int, n)}
+	for i := range u.parent {
+		u.parent[i], u.intent[i] = i, -1
+	}
+	return u
+}
+
+func (u *union) find(i int) int {
+	for u.parent[i] != i {
+		u.parent[i] = u.parent[u.parent[i]]
+		i = u.parent[i]
+	}
+	return i
+}
+
+// join merges the sets of a and b and reports whether they are now one.
+func (u *union) join(a, b int) bool {
+	ra, rb := u.find(a), u.find(b)
+	if ra == rb {
+		return true
+	}
+	ia, ib := u.intent[ra], u.intent[rb]
+	if ia >= 0 && ib >= 0 && ia != ib {
+		return false
+	}
+	if rb < ra {
+		ra, rb = rb, ra
+	}
+	u.parent[rb] = ra
+	u.intent[ra] = max(ia, ib)
+	return true
+}
@@ -0,0 +1,120 @@
+package group
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/aezell/agrev/internal/analysis"
+	"github.com/aezell/agrev/internal/diff"
+	"github.com/aezell/agrev/internal/model"
+	"github.com/aezell/agrev/internal/trace"
+)
+
+const groupDiff = `diff --git a/middleware/ratelimit.go b/middleware/ratelimit.go
+new file mode 100644
+--- /dev/null
++++ b/middleware/ratelimit.go
+@@ -0,0 +1,3 @@
++package middleware
++
++func RateLimit(next Handler) Handler { return next }
+diff --git a/server/routes.go b/server/routes.go
+--- a/server/routes.go
++++ b/server/routes.go
+@@ -1,3 +1,3 @@
+ package server
+
+-var h = base
++var h = RateLimit(base)
+diff --git a/docs/a.md b/docs/a.md
+--- a/docs/a.md
++++ b/docs/a.md
+@@ -1 +1 @@
+-old
++new
+diff --git a/docs/b.md b/docs/b.md
+--- a/docs/b.md
++++ b/docs/b.md
+@@ -1 +1 @@
+-old
++new
+`
+
+func parse(t *testing.T) *diff.DiffSet {
+	t.Helper()
+	ds, err := diff.Parse(groupDiff)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	return ds
+}
+
+func TestBuildWithoutTrace(t *testing.T) {
+	ds := parse(t)
+	results := &analysis.Results{Findings: []analysis.Finding{
+		{File: "server/routes.go", Risk: model.RiskHigh},
+	}}
+	groups := Build(ds, nil, results)
+	if len(groups) != 2 {
+		t.Fatalf("expected 2 groups, got %d: %+v", len(groups), groups)
+	}
+
+	code := groups[0]
+	if !slices.Equal(code.Files, []string{"middleware/ratelimit.go", "server/routes.go"}) {
+		t.Errorf("expected the files sharing RateLimit together, got %v", code.Files)
+	}
+	if code.Label != "Changes around RateLimit" {
+		t.Errorf("unexpected label %q", code.Label)
+	}
+	if code.Risk != model.RiskHigh {
+		t.Errorf("expected the group to take its files' highest risk, got %s", code.Risk)
+	}
+
+	docs := groups[1]
+	if !slices.Equal(docs.Files, []string{"docs/a.md", "docs/b.md"}) {
+		t.Errorf("expected the docs grouped by directory, got %v", docs.Files)
+	}
+	if docs.Label != "Changes in docs/" {
+		t.Errorf("unexpected label %q", docs.Label)
+	}
+}
+
+func TestBuildFromTrace(t *testing.T) {
+	ds := parse(t)
+	tr := &trace.Trace{Steps: []trace.Step{
+		{Type: trace.StepUserMessage, Detail: "add rate limiting middleware."},
+		{Type: trace.StepFileWrite, FilePath: "/repo/middleware/ratelimit.go"},
+		{Type: trace.StepUserMessage, Detail: "Wire it into the routes"},
+		{Type: trace.StepFileEdit, FilePath: "/repo/server/routes.go"},
+		{Type: trace.StepFileEdit, FilePath: "/repo/server/routes.go"},
+	}}
+	groups := Build(ds, tr, nil)
+	if len(groups) != 3 {
+		t.Fatalf("expected 3 groups, got %d: %+v", len(groups), groups)
+	}
+	if groups[0].Label != "Add rate limiting middleware" || !slices.Equal(groups[0].Files, []string{"middleware/ratelimit.go"}) {
+		t.Errorf("unexpected first group %+v", groups[0])
+	}
+	if groups[1].Label != "Wire it into the routes" || !slices.Equal(groups[1].Files, []string{"server/routes.go"}) {
+		t.Errorf("unexpected second group %+v", groups[1])
+	}
+	if !slices.Equal(groups[1].DependsOn, []string{groups[0].ID}) {
+		t.Errorf("expected the routes to depend on the middleware, got %v", groups[1].DependsOn)
+	}
+	if groups[2].Label != "Changes in docs/" {
+		t.Errorf("expected the untraced docs grouped by directory, got %q", groups[2].Label)
+	}
+}
+
+func TestLabel(t *testing.T) {
+	tests := []struct{ msg, want string }{
+		{"fix the flaky test.\nIt fails on CI", "Fix the flaky test"},
+		{"", "Agent changes"},
+		{"please refactor the configuration loader so that it reads environment variables first", "Please refactor the configuration loader so that it reads…"},
+	}
+	for _, tt := range tests {
+		if got := label(tt.msg); got != tt.want {
+			t.Errorf("label(%q) = %q, want %q", tt.msg, got, tt.want)
+		}
+	}
+}
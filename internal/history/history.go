@@ -0,0 +1,240 @@
+// Package history records completed reviews in .agrev/history.jsonl and
+// summarizes them for 'agrev stats'.
+package history
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// Dir is the per-repository directory agrev keeps local state in.
+const Dir = ".agrev"
+
+// FileName is the history file inside Dir.
+const FileName = "history.jsonl"
+
+// Record is one completed review.
+type Record struct {
+	Time     time.Time `json:"time"`
+	Command  string    `json:"command"`         // review, pr, commit, comment
+	Range    string    `json:"range"`           // commit range, PR label, or "working tree"
+	Agent    string    `json:"agent,omitempty"` // trace source, e.g. "claude-code"
+	Session  string    `json:"session,omitempty"`
+	Duration float64   `json:"duration"` // seconds spent in the review
+
+	Files    int `json:"files"`
+	Added    int `json:"added"`
+	Deleted  int `json:"deleted"`
+	Approved int `json:"approved"`
+	Rejected int `json:"rejected"`
+	Pending  int `json:"pending"`
+	Comments int `json:"comments"`
+
+	MaxRisk  string         `json:"max_risk,omitempty"`
+	Findings map[string]int `json:"findings,omitempty"` // count per pass
+}
+
+// Path returns the history file for a repository.
+func Path(repoDir string) string {
+	return filepath.Join(repoDir, Dir, FileName)
+}
+
+// MakeDir creates the repository's .agrev/ directory if needed, with a
+// .gitignore so local state stays out of commits, and returns its path.
+func MakeDir(repoDir string) (string, error) {
+	dir := filepath.Join(repoDir, Dir)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("creating %s: %w", Dir, err)
+	}
+	ignore := filepath.Join(dir, ".gitignore")
+	if _, err := os.Stat(ignore); errors.Is(err, fs.ErrNotExist) {
+		os.WriteFile(ignore, []byte("*\n"), 0644)
+	}
+	return dir, nil
+}
+
+// Append adds a record to the repository's history, creating .agrev/ if
+// needed.
+func Append(repoDir string, r Record) error {
+	if _, err := MakeDir(repoDir); err != nil {
+		return err
+	}
+
+	line, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(Path(repoDir), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("opening history: %w", err)
+	}
+	defer f.Close()
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("writing history: %w", err)
+	}
+	return nil
+}
+
+// Load reads the repository's history, oldest first. A missing file yields
+// no records; malformed lines are skipped.
+func Load(repoDir string) ([]Record, error) {
+	f, err := os.Open(Path(repoDir))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading history: %w", err)
+	}
+	defer f.Close()
+
+	var records []Record
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var r Record
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			continue
+		}
+		records = append(records, r)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading history: %w", err)
+	}
+	sort.SliceStable(records, func(i, j int) bool { return records[i].Time.Before(records[j].Time) })
+	return records, nil
+}
+
+// Stats summarizes a set of records.
+type Stats struct {
+	Reviews  int     `json:"reviews"`
+	Files    int     `json:"files"`
+	Approved int     `json:"approved"`
+	Rejected int     `json:"rejected"`
+	Pending  int     `json:"pending"`
+	Comments int     `json:"comments"`
+	Findings int     `json:"findings"`
+	Duration float64 `json:"duration"` // total seconds
+
+	// Passes lists passes by how many findings they produced, most first.
+	Passes []PassCount `json:"passes"`
+
+	// Agents breaks the stats down per agent ("" for reviews without a
+	// trace), each with weekly buckets for trends.
+	Agents []AgentStats `json:"agents"`
+}
+
+// PassCount is the number of findings a pass produced.
+type PassCount struct {
+	Pass  string `json:"pass"`
+	Count int    `json:"count"`
+}
+
+// AgentStats summarizes one agent's reviews.
+type AgentStats struct {
+	Agent    string   `json:"agent"`
+	Reviews  int      `json:"reviews"`
+	Approved int      `json:"approved"`
+	Rejected int      `json:"rejected"`
+	Findings int      `json:"findings"`
+	Weeks    []Period `json:"weeks"`
+}
+
+// Period summarizes the reviews in one week, starting on Monday.
+type Period struct {
+	Start    time.Time `json:"start"`
+	Reviews  int       `json:"reviews"`
+	Approved int       `json:"approved"`
+	Rejected int       `json:"rejected"`
+	Findings int       `json:"findings"`
+}
+
+// ApprovalRate is the share of decided files that were approved, or -1 if
+// none were decided.
+func ApprovalRate(approved, rejected int) float64 {
+	if approved+rejected == 0 {
+		return -1
+	}
+	return float64(approved) / float64(approved+rejected)
+}
+
+// Summarize computes statistics over records made at or after since.
+func Summarize(records []Record, since time.Time) Stats {
+	var s Stats
+	passes := make(map[string]int)
+	agents := make(map[string]*AgentStats)
+
+	for _, r := range records {
+		if r.Time.Before(since) {
+			continue
+		}
+		findings := 0
+		for pass, n := range r.Findings {
+			passes[pass] += n
+			findings += n
+		}
+
+		s.Reviews++
+		s.Files += r.Files
+		s.Approved += r.Approved
+		s.Rejected += r.Rejected
+		s.Pending += r.Pending
+		s.Comments += r.Comments
+		s.Findings += findings
+		s.Duration += r.Duration
+
+		a := agents[r.Agent]
+		if a == nil {
+			a = &AgentStats{Agent: r.Agent}
+			agents[r.Agent] = a
+		}
+		a.Reviews++
+		a.Approved += r.Approved
+		a.Rejected += r.Rejected
+		a.Findings += findings
+
+		week := weekStart(r.Time)
+		if n := len(a.Weeks); n == 0 || !a.Weeks[n-1].Start.Equal(week) {
+			a.Weeks = append(a.Weeks, Period{Start: week})
+		}
+		w := &a.Weeks[len(a.Weeks)-1]
+		w.Reviews++
+		w.Approved += r.Approved
+		w.Rejected += r.Rejected
+		w.Findings += findings
+	}
+
+	for pass, n := range passes {
+		s.Passes = append(s.Passes, PassCount{pass, n})
+	}
+	sort.Slice(s.Passes, func(i, j int) bool {
+		if s.Passes[i].Count != s.Passes[j].Count {
+			return s.Passes[i].Count > s.Passes[j].Count
+		}
+		return s.Passes[i].Pass < s.Passes[j].Pass
+	})
+
+	for _, a := range agents {
+		s.Agents = append(s.Agents, *a)
+	}
+	sort.Slice(s.Agents, func(i, j int) bool {
+		if s.Agents[i].Reviews != s.Agents[j].Reviews {
+			return s.Agents[i].Reviews > s.Agents[j].Reviews
+		}
+		return s.Agents[i].Agent < s.Agents[j].Agent
+	})
+	return s
+}
+
+// weekStart returns midnight on the Monday of t's week, in t's location.
+func weekStart(t time.Time) time.Time {
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	offset := (int(day.Weekday()) + 6) % 7 // days since Monday
+	return day.AddDate(0, 0, -offset)
+}
@@ -0,0 +1,73 @@
+package history
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestAppendAndLoad(t *testing.T) {
+	dir := t.TempDir()
+
+	records, err := Load(dir)
+	if err != nil || len(records) != 0 {
+		t.Fatalf("expected empty history, got %v, %v", records, err)
+	}
+
+	later := Record{Time: time.Date(2026, 3, 4, 10, 0, 0, 0, time.UTC), Command: "review", Range: "HEAD~1..HEAD", Approved: 2}
+	earlier := Record{Time: time.Date(2026, 3, 2, 10, 0, 0, 0, time.UTC), Command: "pr", Range: "PR #7", Rejected: 1}
+	for _, r := range []Record{later, earlier} {
+		if err := Append(dir, r); err != nil {
+			t.Fatalf("Append failed: %v", err)
+		}
+	}
+
+	if data, err := os.ReadFile(filepath.Join(dir, Dir, ".gitignore")); err != nil || string(data) != "*\n" {
+		t.Errorf("expected .agrev/.gitignore, got %q, %v", data, err)
+	}
+
+	records, err = Load(dir)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if len(records) != 2 || records[0].Range != "PR #7" || records[1].Approved != 2 {
+		t.Errorf("expected records oldest first, got %+v", records)
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2026, 3, d, 12, 0, 0, 0, time.UTC) }
+	records := []Record{
+		{Time: day(1), Agent: "aider", Approved: 1, Findings: map[string]int{"deps": 1}},
+		{Time: day(3), Agent: "claude-code", Approved: 3, Rejected: 1, Comments: 2, Findings: map[string]int{"security": 2}},
+		{Time: day(4), Agent: "claude-code", Approved: 1, Rejected: 1, Findings: map[string]int{"security": 1, "deps": 1}},
+		{Time: day(10), Agent: "claude-code", Approved: 2, Duration: 90},
+	}
+
+	s := Summarize(records, day(2))
+	if s.Reviews != 3 || s.Approved != 6 || s.Rejected != 2 || s.Findings != 4 || s.Comments != 2 {
+		t.Errorf("unexpected totals: %+v", s)
+	}
+	if len(s.Passes) != 2 || s.Passes[0] != (PassCount{"security", 3}) {
+		t.Errorf("expected security most flagged, got %+v", s.Passes)
+	}
+	if len(s.Agents) != 1 || s.Agents[0].Agent != "claude-code" {
+		t.Fatalf("expected only claude-code since day 2, got %+v", s.Agents)
+	}
+
+	weeks := s.Agents[0].Weeks
+	if len(weeks) != 2 {
+		t.Fatalf("expected two weeks, got %+v", weeks)
+	}
+	// March 3 and 4, 2026 fall in the week starting Monday March 2
+	if !weeks[0].Start.Equal(time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)) || weeks[0].Reviews != 2 {
+		t.Errorf("unexpected first week: %+v", weeks[0])
+	}
+	if rate := ApprovalRate(weeks[1].Approved, weeks[1].Rejected); rate != 1 {
+		t.Errorf("expected 100%% approval in second week, got %v", rate)
+	}
+	if ApprovalRate(0, 0) != -1 {
+		t.Error("expected -1 approval rate with no decisions")
+	}
+}
@@ -0,0 +1,208 @@
+// Package issues finds the tracker issues a change refers to, Jira keys
+// such as PROJ-123 and GitHub issues such as #456, in what the agent was
+// asked and in commit messages, links them, and checks that they exist
+// and are open.
+package issues
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aezell/agrev/internal/config"
+	"github.com/aezell/agrev/internal/github"
+)
+
+// Issue is a reference to an issue.
+type Issue struct {
+	Key    string // as shown: "PROJ-123", "#456", or "octo/cat#456"
+	Jira   bool
+	Repo   string // for GitHub issues, "owner/name"; "" when unknown
+	Number int    // for GitHub issues
+	URL    string // the issue's page, "" when its tracker isn't known
+
+	// Title and State are filled in by Verify. State is "open" or
+	// "closed".
+	Title string
+	State string
+}
+
+// Tracker finds and checks issues as configured.
+type Tracker struct {
+	cfg       config.IssuesConfig
+	repo      string         // the GitHub repository bare #123 references are in
+	GitHubURL string         // the GitHub site issues link to
+	GitHub    *github.Client // created on first use, as it may ask gh for a token
+	HTTP      *http.Client
+}
+
+// New returns a tracker for cfg. Bare GitHub references are in repo
+// ("owner/name") unless cfg names another; "" leaves them unlinked.
+func New(cfg config.IssuesConfig, repo string) *Tracker {
+	if cfg.GitHub != "" {
+		repo = cfg.GitHub
+	}
+	site := os.Getenv("GITHUB_SERVER_URL")
+	if site == "" {
+		site = "https://github.com"
+	}
+	return &Tracker{
+		cfg:       cfg,
+		repo:      repo,
+		GitHubURL: strings.TrimRight(site, "/"),
+		HTTP:      &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+var (
+	jiraKey     = regexp.MustCompile(`\b([A-Z][A-Z0-9_]+)-([1-9][0-9]*)\b`)
+	githubIssue = regexp.MustCompile(`(?:^|[\s(\[,;:])(?:([\w.-]+/[\w.-]+))?#([1-9][0-9]*)\b`)
+)
+
+// notJira are prefixes of names that look like Jira keys but aren't,
+// skipped unless a project is configured by that name.
+var notJira = []string{"UTF", "SHA", "ISO", "RFC", "CVE", "GHSA", "AGV", "HTTP", "TLS", "MD", "PEP", "ECMA", "IEEE"}
+
+// Find returns the issues texts refer to, each once, in the order they
+// first appear.
+func (t *Tracker) Find(texts ...string) []Issue {
+	var out []Issue
+	seen := make(map[string]bool)
+	add := func(is Issue) {
+		id := is.Key
+		if !is.Jira {
+			id = is.Repo + "#" + strconv.Itoa(is.Number)
+		}
+		if !seen[id] {
+			seen[id] = true
+			out = append(out, is)
+		}
+	}
+	for _, text := range texts {
+		type match struct {
+			at int
+			is Issue
+		}
+		var found []match
+		for _, m := range jiraKey.FindAllStringSubmatchIndex(text, -1) {
+			// Part of a longer name, as in AGV-SEC-003
+			if m[0] > 0 && text[m[0]-1] == '-' || m[1] < len(text) && text[m[1]] == '-' {
+				continue
+			}
+			project, key := text[m[2]:m[3]], text[m[0]:m[1]]
+			if !t.jiraProject(project) {
+				continue
+			}
+			is := Issue{Key: key, Jira: true}
+			if t.cfg.JiraURL != "" {
+				is.URL = strings.TrimRight(t.cfg.JiraURL, "/") + "/browse/" + key
+			}
+			found = append(found, match{m[0], is})
+		}
+		for _, m := range githubIssue.FindAllStringSubmatchIndex(text, -1) {
+			n, _ := strconv.Atoi(text[m[4]:m[5]])
+			is := Issue{Key: "#" + text[m[4]:m[5]], Repo: t.repo, Number: n}
+			if m[2] >= 0 {
+				is.Repo = text[m[2]:m[3]]
+				is.Key = is.Repo + is.Key
+			}
+			if is.Repo != "" {
+				is.URL = fmt.Sprintf("%s/%s/issues/%d", t.GitHubURL, is.Repo, n)
+			}
+			found = append(found, match{m[0], is})
+		}
+		slices.SortStableFunc(found, func(a, b match) int { return a.at - b.at })
+		for _, m := range found {
+			add(m.is)
+		}
+	}
+	return out
+}
+
+func (t *Tracker) jiraProject(name string) bool {
+	if len(t.cfg.JiraProjects) > 0 {
+		return slices.Contains(t.cfg.JiraProjects, name)
+	}
+	return !slices.Contains(notJira, name)
+}
+
+// Verify looks the issue up in its tracker, filling in its title and
+// state. It fails when the issue doesn't exist or can't be looked up.
+func (t *Tracker) Verify(is *Issue) error {
+	if is.Jira {
+		return t.verifyJira(is)
+	}
+	owner, name, ok := strings.Cut(is.Repo, "/")
+	if !ok {
+		return fmt.Errorf("%s: don't know which repository it's in; set issues.github in .agrev.yml", is.Key)
+	}
+	if t.GitHub == nil {
+		t.GitHub = github.NewClient()
+	}
+	issue, err := t.GitHub.Issue(github.Ref{Owner: owner, Repo: name, Number: is.Number})
+	if err != nil {
+		return fmt.Errorf("%s: %w", is.Key, err)
+	}
+	is.Title, is.State = issue.Title, issue.State
+	if issue.HTMLURL != "" {
+		is.URL = issue.HTMLURL
+	}
+	return nil
+}
+
+func (t *Tracker) verifyJira(is *Issue) error {
+	if t.cfg.JiraURL == "" {
+		return fmt.Errorf("%s: can't look it up without issues.jira_url in .agrev.yml", is.Key)
+	}
+	u := fmt.Sprintf("%s/rest/api/2/issue/%s?fields=summary,status", strings.TrimRight(t.cfg.JiraURL, "/"), url.PathEscape(is.Key))
+	req, err := http.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if t.cfg.JiraUserEnv != "" || t.cfg.JiraTokenEnv != "" {
+		req.SetBasicAuth(os.Getenv(t.cfg.JiraUserEnv), os.Getenv(t.cfg.JiraTokenEnv))
+	}
+	resp, err := t.HTTP.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s: %w", is.Key, err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("%s: %w", is.Key, err)
+	}
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return fmt.Errorf("%s: no such issue (or no access to it)", is.Key)
+	case resp.StatusCode != http.StatusOK:
+		return fmt.Errorf("%s: %s", is.Key, resp.Status)
+	}
+
+	var issue struct {
+		Fields struct {
+			Summary string `json:"summary"`
+			Status  struct {
+				StatusCategory struct {
+					Key string `json:"key"`
+				} `json:"statusCategory"`
+			} `json:"status"`
+		} `json:"fields"`
+	}
+	if err := json.Unmarshal(body, &issue); err != nil {
+		return fmt.Errorf("%s: decoding issue: %w", is.Key, err)
+	}
+	is.Title, is.State = issue.Fields.Summary, "open"
+	if issue.Fields.Status.StatusCategory.Key == "done" {
+		is.State = "closed"
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package issues
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/aezell/agrev/internal/config"
+	"github.com/aezell/agrev/internal/github"
+)
+
+func TestFind(t *testing.T) {
+	tr := New(config.IssuesConfig{JiraURL: "https://example.atlassian.net/"}, "octo/cat")
+	tr.GitHubURL = "https://github.com"
+	got := tr.Find(
+		"Fix PROJ-123 and #45: the UTF-8 decoder (see AGV-SEC-003, color #fff)",
+		"Fixes octo/cat#45 and octo/dog#7 (PROJ-123)",
+	)
+	var keys []string
+	for _, is := range got {
+		keys = append(keys, is.Key)
+	}
+	if strings.Join(keys, " ") != "PROJ-123 #45 octo/dog#7" {
+		t.Fatalf("unexpected issues %q", keys)
+	}
+	if got[0].URL != "https://example.atlassian.net/browse/PROJ-123" || got[1].URL != "https://github.com/octo/cat/issues/45" {
+		t.Errorf("unexpected links %+v", got)
+	}
+
+	tr = New(config.IssuesConfig{JiraProjects: []string{"OPS"}}, "")
+	got = tr.Find("OPS-9 and PROJ-1, #3")
+	if len(got) != 2 || got[0].Key != "OPS-9" || got[0].URL != "" || got[1].Key != "#3" || got[1].URL != "" {
+		t.Errorf("expected only the configured project, unlinked, got %+v", got)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/rest/api/2/issue/PROJ-1":
+			if user, token, _ := r.BasicAuth(); user != "me@example.com" || token != "secret" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			w.Write([]byte(`{"fields": {"summary": "Rate limit the API", "status": {"statusCategory": {"key": "done"}}}}`))
+		case "/repos/octo/cat/issues/45":
+			w.Write([]byte(`{"number": 45, "title": "Crash on empty input", "state": "open", "html_url": "https://github.com/octo/cat/issues/45"}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	t.Setenv("AGREV_TEST_JIRA_USER", "me@example.com")
+	t.Setenv("AGREV_TEST_JIRA_TOKEN", "secret")
+
+	tr := New(config.IssuesConfig{JiraURL: srv.URL, JiraUserEnv: "AGREV_TEST_JIRA_USER", JiraTokenEnv: "AGREV_TEST_JIRA_TOKEN"}, "octo/cat")
+	tr.GitHub = &github.Client{BaseURL: srv.URL, HTTP: srv.Client()}
+	found := tr.Find("PROJ-1 #45 PROJ-2")
+
+	if err := tr.Verify(&found[0]); err != nil || found[0].Title != "Rate limit the API" || found[0].State != "closed" {
+		t.Errorf("unexpected Jira issue %+v (%v)", found[0], err)
+	}
+	if err := tr.Verify(&found[1]); err != nil || found[1].Title != "Crash on empty input" || found[1].State != "open" {
+		t.Errorf("unexpected GitHub issue %+v (%v)", found[1], err)
+	}
+	if err := tr.Verify(&found[2]); err == nil || !strings.Contains(err.Error(), "no such issue") {
+		t.Errorf("expected a missing issue reported, got %v", err)
+	}
+}
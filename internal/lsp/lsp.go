@@ -0,0 +1,386 @@
+// Package lsp is a minimal Language Server for 'agrev lsp'. It publishes
+// the analysis findings on the repository's changes as diagnostics, and
+// offers code actions that triage them, so any editor with an LSP client
+// shows agrev's results inline.
+package lsp
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+
+	"github.com/aezell/agrev/internal/analysis"
+	"github.com/aezell/agrev/internal/audit"
+	"github.com/aezell/agrev/internal/diff"
+	"github.com/aezell/agrev/internal/jsonrpc"
+	"github.com/aezell/agrev/internal/model"
+	"github.com/aezell/agrev/internal/triage"
+)
+
+// TriageCommand is the command the code actions run: its arguments are a
+// finding's fingerprint and the state to give it.
+const TriageCommand = "agrev.triage"
+
+// Server answers one editor.
+type Server struct {
+	RepoDir string
+	Version string
+	Skip    []string               // analysis passes to skip
+	Diff    func() (string, error) // the changes to diagnose, as a unified diff
+	Author  string                 // who triages findings
+	Audit   *audit.Log             // where triage is recorded; nil for nowhere
+
+	conn      *jsonrpc.Conn
+	results   *analysis.Results
+	published map[string]bool // documents with diagnostics, by URI
+	shutdown  bool
+}
+
+// Serve answers the editor's messages on r and w until it exits. It
+// returns an error if the editor exits without shutting the server down
+// first, or the stream breaks.
+func (s *Server) Serve(r io.Reader, w io.Writer) error {
+	s.conn = jsonrpc.NewConn(r, w)
+	s.published = make(map[string]bool)
+	for {
+		m, err := s.conn.Read()
+		if err == io.EOF {
+			return errors.New("the editor closed the connection without exiting")
+		}
+		var rpcErr *jsonrpc.Error
+		if errors.As(err, &rpcErr) {
+			s.conn.Reply(nil, nil, rpcErr)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if m.Method == "exit" {
+			if !s.shutdown {
+				return errors.New("the editor exited without shutting the server down")
+			}
+			return nil
+		}
+		if m.Method == "" {
+			continue // a response; the server sends no requests
+		}
+		result, err := s.handle(m)
+		if m.IsNotification() {
+			if err != nil {
+				s.logMessage(err.Error())
+			}
+			continue
+		}
+		if err := s.conn.Reply(m.ID, result, err); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *Server) handle(m *jsonrpc.Message) (any, error) {
+	switch m.Method {
+	case "initialize":
+		return s.initialize(), nil
+	case "initialized", "textDocument/didSave":
+		return nil, s.publish()
+	case "textDocument/codeAction":
+		var p codeActionParams
+		if err := json.Unmarshal(m.Params, &p); err != nil {
+			return nil, jsonrpc.Errorf(jsonrpc.CodeInvalidParams, "%v", err)
+		}
+		return s.codeActions(p), nil
+	case "workspace/executeCommand":
+		var p executeCommandParams
+		if err := json.Unmarshal(m.Params, &p); err != nil {
+			return nil, jsonrpc.Errorf(jsonrpc.CodeInvalidParams, "%v", err)
+		}
+		return nil, s.executeCommand(p)
+	case "shutdown":
+		s.shutdown = true
+		return nil, nil
+	}
+	if m.IsNotification() {
+		// didOpen, didChange, $/cancelRequest and the like: findings come
+		// from the files on disk, so there is nothing to do until a save
+		return nil, nil
+	}
+	return nil, jsonrpc.Errorf(jsonrpc.CodeMethodNotFound, "%s is not supported", m.Method)
+}
+
+func (s *Server) initialize() any {
+	return map[string]any{
+		"capabilities": map[string]any{
+			"textDocumentSync": map[string]any{
+				"openClose": true,
+				"change":    0, // none: only saved files are analyzed
+				"save":      true,
+			},
+			"codeActionProvider":     map[string]any{"codeActionKinds": []string{"quickfix"}},
+			"executeCommandProvider": map[string]any{"commands": []string{TriageCommand}},
+		},
+		"serverInfo": map[string]any{"name": "agrev", "version": s.Version},
+	}
+}
+
+// analyze runs the analysis on the changes, then suppresses or down-ranks
+// the findings triaged in .agrev/findings.json.
+func (s *Server) analyze() error {
+	raw, err := s.Diff()
+	if err != nil {
+		return err
+	}
+	ds, err := diff.Parse(raw)
+	if err != nil {
+		return err
+	}
+	results := analysis.Run(ds, s.RepoDir, s.Skip)
+	store, err := triage.Load(s.RepoDir)
+	if err != nil {
+		return err
+	}
+	store.Apply(results)
+	s.results = results
+	return nil
+}
+
+// publish analyzes the changes again and publishes each file's findings,
+// clearing the diagnostics of files that no longer have any.
+func (s *Server) publish() error {
+	if err := s.analyze(); err != nil {
+		return fmt.Errorf("agrev: %w", err)
+	}
+	byURI := make(map[string][]diagnostic)
+	for _, f := range s.results.Findings {
+		uri := s.uri(f.File)
+		byURI[uri] = append(byURI[uri], newDiagnostic(f))
+	}
+	for uri := range s.published {
+		if _, ok := byURI[uri]; !ok {
+			byURI[uri] = []diagnostic{}
+		}
+	}
+	s.published = make(map[string]bool)
+	for uri, diags := range byURI {
+		if len(diags) > 0 {
+			s.published[uri] = true
+		}
+		if err := s.conn.Notify("textDocument/publishDiagnostics", publishDiagnosticsParams{URI: uri, Diagnostics: diags}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// codeActions offers to triage each finding in the range.
+func (s *Server) codeActions(p codeActionParams) []codeAction {
+	if s.results == nil {
+		return nil
+	}
+	name, ok := s.file(p.TextDocument.URI)
+	if !ok {
+		return nil
+	}
+	actions := []codeAction{}
+	for _, f := range s.results.Findings {
+		line := max(f.Line-1, 0)
+		if f.File != name || line < p.Range.Start.Line || line > p.Range.End.Line {
+			continue
+		}
+		diags := []diagnostic{newDiagnostic(f)}
+		for _, st := range []model.FindingState{model.FindingFalsePositive, model.FindingAcknowledged} {
+			if f.State == st {
+				continue
+			}
+			title := "agrev: mark as false positive"
+			if st == model.FindingAcknowledged {
+				title = "agrev: acknowledge"
+			}
+			actions = append(actions, codeAction{
+				Title:       fmt.Sprintf("%s (%s)", title, f.Message),
+				Kind:        "quickfix",
+				Diagnostics: diags,
+				Command:     &command{Title: title, Command: TriageCommand, Arguments: []any{f.Fingerprint(), st.String()}},
+			})
+		}
+	}
+	return actions
+}
+
+// executeCommand records what the editor's user concluded about a finding
+// in .agrev/findings.json, and publishes the findings again so it takes
+// effect.
+func (s *Server) executeCommand(p executeCommandParams) error {
+	if p.Command != TriageCommand {
+		return jsonrpc.Errorf(jsonrpc.CodeInvalidParams, "unknown command %q", p.Command)
+	}
+	var fingerprint, stateName string
+	if len(p.Arguments) != 2 || json.Unmarshal(p.Arguments[0], &fingerprint) != nil || json.Unmarshal(p.Arguments[1], &stateName) != nil {
+		return jsonrpc.Errorf(jsonrpc.CodeInvalidParams, "%s takes a finding's fingerprint and a state", TriageCommand)
+	}
+	state, ok := model.ParseFindingState(stateName)
+	if !ok {
+		return jsonrpc.Errorf(jsonrpc.CodeInvalidParams, "unknown finding state %q", stateName)
+	}
+	if s.results == nil {
+		return errors.New("no analysis results yet")
+	}
+
+	store, err := triage.Load(s.RepoDir)
+	if err != nil {
+		return err
+	}
+	found := false
+	for _, f := range slices.Concat(s.results.Findings, s.results.Suppressed) {
+		if f.Fingerprint() == fingerprint {
+			store.Set(f, state, s.Author)
+			if err := s.Audit.Write(audit.Event{Action: audit.ActionTriage, File: f.File, Line: f.Line, Detail: state.String() + ": " + f.Message}); err != nil {
+				s.logMessage(fmt.Sprintf("audit log: %v", err))
+			}
+			found = true
+			break
+		}
+	}
+	if !found {
+		return jsonrpc.Errorf(jsonrpc.CodeInvalidParams, "no finding with that fingerprint")
+	}
+	if err := store.Save(s.RepoDir); err != nil {
+		return err
+	}
+	return s.publish()
+}
+
+// logMessage shows an error in the editor's log.
+func (s *Server) logMessage(msg string) {
+	s.conn.Notify("window/logMessage", map[string]any{"type": 1, "message": msg})
+}
+
+// uri is the document URI of a file in the diff.
+func (s *Server) uri(name string) string {
+	path := filepath.ToSlash(filepath.Join(s.RepoDir, name))
+	if runtime.GOOS == "windows" {
+		path = "/" + path
+	}
+	return (&url.URL{Scheme: "file", Path: path}).String()
+}
+
+// file is the diff's name for the document at uri, if it is in the
+// repository.
+func (s *Server) file(uri string) (string, bool) {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "file" {
+		return "", false
+	}
+	path := u.Path
+	if runtime.GOOS == "windows" {
+		path = strings.TrimPrefix(path, "/")
+	}
+	rel, err := filepath.Rel(s.RepoDir, filepath.FromSlash(path))
+	if err != nil || strings.HasPrefix(rel, "..") {
+		// The editor may name it by a path through a symlink
+		real, err := filepath.EvalSymlinks(filepath.FromSlash(path))
+		if err != nil {
+			return "", false
+		}
+		if rel, err = filepath.Rel(s.RepoDir, real); err != nil || strings.HasPrefix(rel, "..") {
+			return "", false
+		}
+	}
+	return filepath.ToSlash(rel), true
+}
+
+type position struct {
+	Line      int `json:"line"`
+	Character int `json:"character"`
+}
+
+type lspRange struct {
+	Start position `json:"start"`
+	End   position `json:"end"`
+}
+
+type diagnostic struct {
+	Range           lspRange         `json:"range"`
+	Severity        int              `json:"severity"`
+	Code            string           `json:"code,omitempty"`
+	CodeDescription *codeDescription `json:"codeDescription,omitempty"`
+	Source          string           `json:"source"`
+	Message         string           `json:"message"`
+	Data            diagnosticData   `json:"data"`
+}
+
+type codeDescription struct {
+	Href string `json:"href"`
+}
+
+type diagnosticData struct {
+	Fingerprint string `json:"fingerprint"`
+}
+
+// Diagnostic severities
+const (
+	severityError       = 1
+	severityWarning     = 2
+	severityInformation = 3
+)
+
+// newDiagnostic is the diagnostic for f, over its whole line (the first
+// line for file-level findings): high and critical risks are errors,
+// medium and low warnings, the rest information, as 'agrev check --ci'
+// annotates them.
+func newDiagnostic(f analysis.Finding) diagnostic {
+	line := max(f.Line-1, 0)
+	d := diagnostic{
+		Range:    lspRange{Start: position{Line: line}, End: position{Line: line + 1}},
+		Severity: severityInformation,
+		Code:     f.Rule,
+		Source:   "agrev",
+		Message:  fmt.Sprintf("%s [%s, %s risk]", f.Message, f.Pass, f.Risk),
+		Data:     diagnosticData{Fingerprint: f.Fingerprint()},
+	}
+	switch {
+	case f.Risk >= model.RiskHigh:
+		d.Severity = severityError
+	case f.Risk >= model.RiskLow:
+		d.Severity = severityWarning
+	}
+	if u := f.DocsURL(); u != "" {
+		d.CodeDescription = &codeDescription{Href: u}
+	}
+	return d
+}
+
+type publishDiagnosticsParams struct {
+	URI         string       `json:"uri"`
+	Diagnostics []diagnostic `json:"diagnostics"`
+}
+
+type codeActionParams struct {
+	TextDocument struct {
+		URI string `json:"uri"`
+	} `json:"textDocument"`
+	Range lspRange `json:"range"`
+}
+
+type codeAction struct {
+	Title       string       `json:"title"`
+	Kind        string       `json:"kind"`
+	Diagnostics []diagnostic `json:"diagnostics"`
+	Command     *command     `json:"command"`
+}
+
+type command struct {
+	Title     string `json:"title"`
+	Command   string `json:"command"`
+	Arguments []any  `json:"arguments"`
+}
+
+type executeCommandParams struct {
+	Command   string            `json:"command"`
+	Arguments []json.RawMessage `json:"arguments"`
+}
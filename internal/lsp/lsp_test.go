@@ -0,0 +1,143 @@
+package lsp
+
+import (
+	"encoding/json"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aezell/agrev/internal/jsonrpc"
+	"github.com/aezell/agrev/internal/model"
+	"github.com/aezell/agrev/internal/triage"
+)
+
+const testDiff = `diff --git a/util.go b/util.go
+index abc1234..def5678 100644
+--- a/util.go
++++ b/util.go
+@@ -1,3 +1,4 @@
+ package util
+
++// TODO: handle the error
+ func F() {}
+`
+
+// editor drives a server as an editor's LSP client would.
+type editor struct {
+	t    *testing.T
+	conn *jsonrpc.Conn
+	done chan error
+	id   int
+}
+
+func startServer(t *testing.T, s *Server) *editor {
+	toServer, fromEditor := io.Pipe()
+	fromServer, toEditor := io.Pipe()
+	e := &editor{t: t, conn: jsonrpc.NewConn(fromServer, fromEditor), done: make(chan error, 1)}
+	go func() {
+		e.done <- s.Serve(toServer, toEditor)
+		toEditor.Close()
+	}()
+	return e
+}
+
+// call sends a request, and returns its response and the notifications
+// sent before it.
+func (e *editor) call(method string, params any) (*jsonrpc.Message, []*jsonrpc.Message) {
+	e.id++
+	data, _ := json.Marshal(params)
+	id, _ := json.Marshal(e.id)
+	// The pipes don't buffer, so the server may be writing to us already
+	go e.conn.Write(&jsonrpc.Message{ID: id, Method: method, Params: data})
+	var notes []*jsonrpc.Message
+	for {
+		m, err := e.conn.Read()
+		if err != nil {
+			e.t.Fatal(err)
+		}
+		if m.Method == "" {
+			return m, notes
+		}
+		notes = append(notes, m)
+	}
+}
+
+func (e *editor) notify(method string, params any) {
+	if err := e.conn.Notify(method, params); err != nil {
+		e.t.Fatal(err)
+	}
+}
+
+func TestServer(t *testing.T) {
+	dir := t.TempDir()
+	s := &Server{RepoDir: dir, Diff: func() (string, error) { return testDiff, nil }, Author: "ada"}
+	e := startServer(t, s)
+
+	resp, _ := e.call("initialize", map[string]any{"rootUri": s.uri("")})
+	if !strings.Contains(string(resp.Result), TriageCommand) {
+		t.Errorf("expected the triage command in the capabilities, got %s", resp.Result)
+	}
+	e.notify("initialized", map[string]any{})
+	// A request after the notification collects what it published
+	resp, notes := e.call("textDocument/hover", map[string]any{})
+	if resp.Error == nil || resp.Error.Code != jsonrpc.CodeMethodNotFound {
+		t.Errorf("expected hover to be unsupported, got %+v", resp)
+	}
+	if len(notes) != 1 || notes[0].Method != "textDocument/publishDiagnostics" {
+		t.Fatalf("expected diagnostics published, got %+v", notes)
+	}
+	var published publishDiagnosticsParams
+	json.Unmarshal(notes[0].Params, &published)
+	if published.URI != "file://"+filepath.ToSlash(filepath.Join(dir, "util.go")) || len(published.Diagnostics) != 1 {
+		t.Fatalf("unexpected diagnostics %+v", published)
+	}
+	diag := published.Diagnostics[0]
+	if diag.Range.Start.Line != 2 || diag.Severity != severityWarning || diag.Source != "agrev" || !strings.Contains(diag.Message, "TODO") {
+		t.Errorf("unexpected diagnostic %+v", diag)
+	}
+
+	resp, _ = e.call("textDocument/codeAction", map[string]any{
+		"textDocument": map[string]any{"uri": published.URI},
+		"range":        lspRange{Start: position{Line: 2}, End: position{Line: 2, Character: 5}},
+		"context":      map[string]any{"diagnostics": published.Diagnostics},
+	})
+	var actions []codeAction
+	json.Unmarshal(resp.Result, &actions)
+	if len(actions) != 2 || actions[0].Command.Command != TriageCommand {
+		t.Fatalf("expected false positive and acknowledge actions, got %s", resp.Result)
+	}
+
+	resp, notes = e.call("workspace/executeCommand", actions[0].Command)
+	if resp.Error != nil {
+		t.Fatal(resp.Error)
+	}
+	if len(notes) != 1 {
+		t.Fatalf("expected the diagnostics published again, got %+v", notes)
+	}
+	json.Unmarshal(notes[0].Params, &published)
+	if len(published.Diagnostics) != 0 {
+		t.Errorf("expected the false positive cleared, got %+v", published.Diagnostics)
+	}
+	store, err := triage.Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(store.Findings) != 1 || store.State(diag.Data.Fingerprint) != model.FindingFalsePositive || store.Findings[0].Author != "ada" {
+		t.Errorf("expected the finding triaged as a false positive, got %+v", store.Findings)
+	}
+
+	e.call("shutdown", nil)
+	e.notify("exit", nil)
+	if err := <-e.done; err != nil {
+		t.Errorf("expected a clean exit, got %v", err)
+	}
+}
+
+func TestExitWithoutShutdown(t *testing.T) {
+	e := startServer(t, &Server{RepoDir: t.TempDir()})
+	e.notify("exit", nil)
+	if err := <-e.done; err == nil {
+		t.Error("expected an error exiting without a shutdown")
+	}
+}
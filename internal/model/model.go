@@ -1,6 +1,12 @@
 // Package model defines the core data types shared across agrev.
 package model
 
+import (
+	"slices"
+	"strings"
+	"time"
+)
+
 // RiskLevel categorizes the risk of a change.
 type RiskLevel int
 
@@ -29,6 +35,16 @@ func (r RiskLevel) String() string {
 	}
 }
 
+// ParseRiskLevel converts a risk name such as "high" back to a RiskLevel.
+func ParseRiskLevel(s string) (RiskLevel, bool) {
+	for r := RiskInfo; r <= RiskCritical; r++ {
+		if r.String() == s {
+			return r, true
+		}
+	}
+	return RiskInfo, false
+}
+
 // Severity for annotations.
 type Severity int
 
@@ -38,6 +54,30 @@ const (
 	SeverityError
 )
 
+func (s Severity) String() string {
+	switch s {
+	case SeverityInfo:
+		return "info"
+	case SeverityWarning:
+		return "warning"
+	case SeverityError:
+		return "error"
+	default:
+		return "unknown"
+	}
+}
+
+// ParseSeverity converts a severity name such as "warning" back to a
+// Severity.
+func ParseSeverity(s string) (Severity, bool) {
+	for sev := SeverityInfo; sev <= SeverityError; sev++ {
+		if sev.String() == s {
+			return sev, true
+		}
+	}
+	return SeverityInfo, false
+}
+
 // AnnotationType categorizes an annotation.
 type AnnotationType int
 
@@ -48,18 +88,44 @@ const (
 	AnnotationRisk
 )
 
+func (t AnnotationType) String() string {
+	switch t {
+	case AnnotationWarning:
+		return "warning"
+	case AnnotationInfo:
+		return "info"
+	case AnnotationTraceLink:
+		return "trace_link"
+	case AnnotationRisk:
+		return "risk"
+	default:
+		return "unknown"
+	}
+}
+
 // LineRange identifies a range of lines in a file.
 type LineRange struct {
 	Start int
 	End   int
 }
 
-// Annotation is a piece of metadata attached to a line or range.
+// Move is a block of code deleted from one file and added, unchanged but
+// for whitespace, to another.
+type Move struct {
+	FromFile string
+	From     LineRange // lines in the old version of FromFile
+	ToFile   string
+	To       LineRange // lines in the new version of ToFile
+}
+
+// Annotation is a piece of metadata attached to a line or range. A zero
+// Range attaches it to the whole file.
 type Annotation struct {
 	Type     AnnotationType
-	Range    LineRange
+	Range    LineRange // lines in the new version of the file
 	Message  string
 	Severity Severity
+	Step     int // for trace links, the 1-based trace step linked to
 }
 
 // ReviewDecision records the reviewer's decision for a change group.
@@ -70,8 +136,389 @@ const (
 	DecisionApproved
 	DecisionRejected
 	DecisionEdited
+
+	// DecisionPartial is only ever derived, never recorded: the decision of
+	// a file whose hunks were decided differently.
+	DecisionPartial
+)
+
+func (d ReviewDecision) String() string {
+	switch d {
+	case DecisionApproved:
+		return "approved"
+	case DecisionRejected:
+		return "rejected"
+	case DecisionEdited:
+		return "edited"
+	case DecisionPartial:
+		return "partial"
+	default:
+		return "pending"
+	}
+}
+
+// ParseReviewDecision converts a decision name such as "approved" back to
+// a ReviewDecision.
+func ParseReviewDecision(s string) (ReviewDecision, bool) {
+	for d := DecisionPending; d <= DecisionPartial; d++ {
+		if d.String() == s {
+			return d, true
+		}
+	}
+	return DecisionPending, false
+}
+
+// FindingState is what reviewers concluded about an analysis finding.
+type FindingState int
+
+const (
+	FindingOpen FindingState = iota
+	FindingAcknowledged
+	FindingFalsePositive
+	FindingFixed
 )
 
+func (s FindingState) String() string {
+	switch s {
+	case FindingAcknowledged:
+		return "acknowledged"
+	case FindingFalsePositive:
+		return "false_positive"
+	case FindingFixed:
+		return "fixed"
+	default:
+		return "open"
+	}
+}
+
+// ParseFindingState converts a state name such as "false_positive" back to
+// a FindingState.
+func ParseFindingState(s string) (FindingState, bool) {
+	for st := FindingOpen; st <= FindingFixed; st++ {
+		if st.String() == s {
+			return st, true
+		}
+	}
+	return FindingOpen, false
+}
+
+// HunkKey identifies a hunk by its file's index in the diff and its own
+// index among the file's hunks.
+type HunkKey struct {
+	File, Hunk int
+}
+
+// WholeFile is the hunk index of a note on a whole file rather than one of
+// its hunks.
+const WholeFile = -1
+
+// Decisions holds a review's decisions. A decision on a file covers all of
+// its hunks; a decision on a hunk overrides its file's for that hunk alone.
+type Decisions struct {
+	Files map[int]ReviewDecision
+	Hunks map[HunkKey]ReviewDecision
+
+	// Notes holds the reasons given for decisions, such as why a file was
+	// rejected. A note on a whole file has Hunk set to WholeFile. Deciding a
+	// file or hunk again drops its note.
+	Notes map[HunkKey]string
+
+	// Bulk marks files decided along with others at once, such as a whole
+	// change group, rather than one by one. Deciding the file again, or any
+	// of its hunks, clears the mark.
+	Bulk map[int]bool
+
+	// Conditions holds the follow-ups a file was approved on, such as "add
+	// tests for the parser", in the order they were given. Deciding the
+	// file again, or any of its hunks, drops them.
+	Conditions map[int][]string
+
+	// Labels holds the free-form labels reviewers attached to files (with
+	// Hunk set to WholeFile) and hunks for triage, such as
+	// "needs-security-review", sorted. Unlike notes, they outlast the
+	// decisions on the file.
+	Labels map[HunkKey][]string
+}
+
+// NewDecisions returns an empty set of decisions.
+func NewDecisions() Decisions {
+	return Decisions{
+		Files:      make(map[int]ReviewDecision),
+		Hunks:      make(map[HunkKey]ReviewDecision),
+		Notes:      make(map[HunkKey]string),
+		Bulk:       make(map[int]bool),
+		Conditions: make(map[int][]string),
+		Labels:     make(map[HunkKey][]string),
+	}
+}
+
+// Clone returns a copy of d that can be changed independently.
+func (d Decisions) Clone() Decisions {
+	c := NewDecisions()
+	for k, v := range d.Files {
+		c.Files[k] = v
+	}
+	for k, v := range d.Hunks {
+		c.Hunks[k] = v
+	}
+	for k, v := range d.Notes {
+		c.Notes[k] = v
+	}
+	for k, v := range d.Bulk {
+		c.Bulk[k] = v
+	}
+	for k, v := range d.Conditions {
+		c.Conditions[k] = slices.Clone(v)
+	}
+	for k, v := range d.Labels {
+		c.Labels[k] = slices.Clone(v)
+	}
+	return c
+}
+
+// Select returns the decisions on the given files, renumbered by their
+// position in files.
+func (d Decisions) Select(files []int) Decisions {
+	c := NewDecisions()
+	for i, f := range files {
+		if v, ok := d.Files[f]; ok {
+			c.Files[i] = v
+		}
+		if d.Bulk[f] {
+			c.Bulk[i] = true
+		}
+		if v := d.Conditions[f]; len(v) > 0 {
+			c.Conditions[i] = slices.Clone(v)
+		}
+		for k, v := range d.Hunks {
+			if k.File == f {
+				c.Hunks[HunkKey{i, k.Hunk}] = v
+			}
+		}
+		for k, v := range d.Notes {
+			if k.File == f {
+				c.Notes[HunkKey{i, k.Hunk}] = v
+			}
+		}
+		for k, v := range d.Labels {
+			if k.File == f {
+				c.Labels[HunkKey{i, k.Hunk}] = slices.Clone(v)
+			}
+		}
+	}
+	return c
+}
+
+// SetFile decides a whole file, replacing any decisions on its hunks.
+func (d Decisions) SetFile(file int, dec ReviewDecision) {
+	d.Files[file] = dec
+	d.clearHunks(file)
+	delete(d.Bulk, file)
+	delete(d.Conditions, file)
+}
+
+// SetBulk decides a whole file as one of several decided at once.
+func (d Decisions) SetBulk(file int, dec ReviewDecision) {
+	d.SetFile(file, dec)
+	d.Bulk[file] = true
+}
+
+// SetHunk decides one hunk of a file.
+func (d Decisions) SetHunk(file, hunk int, dec ReviewDecision) {
+	d.Hunks[HunkKey{file, hunk}] = dec
+	delete(d.Notes, HunkKey{file, hunk})
+	delete(d.Bulk, file)
+	delete(d.Conditions, file)
+}
+
+// ClearFile removes the decisions on a file and all its hunks.
+func (d Decisions) ClearFile(file int) {
+	delete(d.Files, file)
+	d.clearHunks(file)
+	delete(d.Bulk, file)
+	delete(d.Conditions, file)
+}
+
+// ClearHunk removes a hunk's own decision, so it takes its file's again.
+func (d Decisions) ClearHunk(file, hunk int) {
+	delete(d.Hunks, HunkKey{file, hunk})
+	delete(d.Notes, HunkKey{file, hunk})
+}
+
+// clearHunks removes the decisions on a file's hunks, and the notes on the
+// file and its hunks.
+func (d Decisions) clearHunks(file int) {
+	for k := range d.Hunks {
+		if k.File == file {
+			delete(d.Hunks, k)
+		}
+	}
+	for k := range d.Notes {
+		if k.File == file {
+			delete(d.Notes, k)
+		}
+	}
+}
+
+// SetNote records why a hunk, or with hunk set to WholeFile a whole file,
+// was decided as it was. An empty note removes it.
+func (d Decisions) SetNote(file, hunk int, note string) {
+	if note == "" {
+		delete(d.Notes, HunkKey{file, hunk})
+		return
+	}
+	d.Notes[HunkKey{file, hunk}] = note
+}
+
+// AddCondition records a follow-up required of a file approved on
+// condition. Empty conditions are ignored.
+func (d Decisions) AddCondition(file int, condition string) {
+	if condition == "" {
+		return
+	}
+	d.Conditions[file] = append(d.Conditions[file], condition)
+}
+
+// NormalizeLabel returns label as it is stored: without surrounding space
+// or a leading "#", and with inner runs of space made into a "-", so
+// "needs security review" becomes "needs-security-review".
+func NormalizeLabel(label string) string {
+	return strings.Join(strings.Fields(strings.TrimPrefix(strings.TrimSpace(label), "#")), "-")
+}
+
+// AddLabel attaches a label to a hunk, or with hunk set to WholeFile to a
+// whole file. It reports whether the label is new there; empty labels are
+// ignored.
+func (d Decisions) AddLabel(file, hunk int, label string) bool {
+	label = NormalizeLabel(label)
+	k := HunkKey{file, hunk}
+	pos, found := slices.BinarySearch(d.Labels[k], label)
+	if label == "" || found {
+		return false
+	}
+	d.Labels[k] = slices.Insert(slices.Clone(d.Labels[k]), pos, label)
+	return true
+}
+
+// RemoveLabel removes a label from a hunk or, with hunk set to WholeFile, a
+// whole file. It reports whether the label was there.
+func (d Decisions) RemoveLabel(file, hunk int, label string) bool {
+	label = NormalizeLabel(label)
+	k := HunkKey{file, hunk}
+	pos, found := slices.BinarySearch(d.Labels[k], label)
+	if !found {
+		return false
+	}
+	if len(d.Labels[k]) == 1 {
+		delete(d.Labels, k)
+	} else {
+		d.Labels[k] = slices.Delete(slices.Clone(d.Labels[k]), pos, pos+1)
+	}
+	return true
+}
+
+// Label returns the labels on a hunk, or with hunk set to WholeFile on a
+// whole file.
+func (d Decisions) Label(file, hunk int) []string {
+	return d.Labels[HunkKey{file, hunk}]
+}
+
+// FileLabels returns the labels on a file and any of its hunks, sorted.
+func (d Decisions) FileLabels(file int) []string {
+	var labels []string
+	for k, v := range d.Labels {
+		if k.File == file {
+			labels = append(labels, v...)
+		}
+	}
+	slices.Sort(labels)
+	return slices.Compact(labels)
+}
+
+// Note returns the note on a hunk, or with hunk set to WholeFile on a whole
+// file.
+func (d Decisions) Note(file, hunk int) string {
+	return d.Notes[HunkKey{file, hunk}]
+}
+
+// Hunk returns the decision in effect for a hunk: its own, or else its
+// file's.
+func (d Decisions) Hunk(file, hunk int) ReviewDecision {
+	if dec, ok := d.Hunks[HunkKey{file, hunk}]; ok {
+		return dec
+	}
+	return d.Files[file]
+}
+
+// HunksDecided reports whether any hunk of file has a decision of its own.
+func (d Decisions) HunksDecided(file int) bool {
+	for k := range d.Hunks {
+		if k.File == file {
+			return true
+		}
+	}
+	return false
+}
+
+// File derives the decision of a file with the given number of hunks: its
+// own when none of its hunks has one, else the decision its hunks share, or
+// DecisionPartial when they differ.
+func (d Decisions) File(file, hunks int) ReviewDecision {
+	if hunks == 0 || !d.HunksDecided(file) {
+		return d.Files[file]
+	}
+	dec := d.Hunk(file, 0)
+	for h := 1; h < hunks; h++ {
+		if d.Hunk(file, h) != dec {
+			return DecisionPartial
+		}
+	}
+	return dec
+}
+
+// Decided reports whether every hunk of a file with the given number of
+// hunks has a decision in effect.
+func (d Decisions) Decided(file, hunks int) bool {
+	if hunks == 0 || !d.HunksDecided(file) {
+		return d.Files[file] != DecisionPending
+	}
+	for h := range hunks {
+		if d.Hunk(file, h) == DecisionPending {
+			return false
+		}
+	}
+	return true
+}
+
+// SplitHunk records that a file's hunk was split into n hunks in its place:
+// the pieces take the hunk's own decision, note, and labels, if it had them,
+// and the indexes of later hunks move up.
+func (d Decisions) SplitHunk(file, hunk, n int) {
+	splitHunk(d.Hunks, file, hunk, n)
+	splitHunk(d.Notes, file, hunk, n)
+	splitHunk(d.Labels, file, hunk, n)
+}
+
+func splitHunk[V any](m map[HunkKey]V, file, hunk, n int) {
+	moved := make(map[HunkKey]V)
+	for k, v := range m {
+		if k.File != file || k.Hunk < hunk {
+			continue
+		}
+		delete(m, k)
+		if k.Hunk > hunk {
+			moved[HunkKey{file, k.Hunk + n - 1}] = v
+			continue
+		}
+		for p := range n {
+			moved[HunkKey{file, hunk + p}] = v
+		}
+	}
+	for k, v := range moved {
+		m[k] = v
+	}
+}
+
 // ChangeGroup clusters related hunks by intent.
 type ChangeGroup struct {
 	ID        string
@@ -83,8 +530,42 @@ type ChangeGroup struct {
 	DependsOn []string // other group IDs
 }
 
-// ReviewSession is the top-level container for a review.
+// ReviewSession is the state of a review: what it compares, how the
+// changed files are grouped, and what reviewers decided and said about
+// them. Decisions are by index into the files of the diff under review;
+// review.Session pairs it with that diff.
 type ReviewSession struct {
-	CommitRange string
+	CommitRange string // e.g. "1a2b3c4..5d6e7f8"; empty for the working tree or a patch
 	Groups      []ChangeGroup
+	Decisions   Decisions
+	Comments    []Comment
+	Checklist   []ChecklistItem
+}
+
+// ChecklistItem is something the reviewer confirms before finishing a
+// review, such as "migrations: confirm the backfill plan".
+type ChecklistItem struct {
+	Text string
+	Done bool
+}
+
+// ChecklistLeft counts the checklist items not yet done.
+func ChecklistLeft(items []ChecklistItem) int {
+	n := 0
+	for _, c := range items {
+		if !c.Done {
+			n++
+		}
+	}
+	return n
+}
+
+// Comment is a reviewer note attached to a line of a file in the diff.
+type Comment struct {
+	File   string
+	Line   int // line number in the new file (old file for deleted lines)
+	Hunk   int // 1-based hunk of the file's diff holding Line; 0 for file-level comments
+	Body   string
+	Author string    // reviewer who wrote it; empty if unknown
+	Time   time.Time // when it was written; zero if unknown
 }
@@ -22,3 +22,147 @@ func TestRiskLevelString(t *testing.T) {
 		}
 	}
 }
+
+func TestParseRiskLevel(t *testing.T) {
+	for r := RiskInfo; r <= RiskCritical; r++ {
+		got, ok := ParseRiskLevel(r.String())
+		if !ok || got != r {
+			t.Errorf("ParseRiskLevel(%q) = %v, %v", r.String(), got, ok)
+		}
+	}
+	if _, ok := ParseRiskLevel("severe"); ok {
+		t.Error("expected unknown risk name to fail")
+	}
+}
+
+func TestDecisions(t *testing.T) {
+	d := NewDecisions()
+	d.SetFile(0, DecisionApproved)
+	d.SetHunk(1, 0, DecisionApproved)
+	d.SetHunk(1, 2, DecisionRejected)
+
+	if got := d.File(0, 3); got != DecisionApproved {
+		t.Errorf("File(0) = %s, want approved", got)
+	}
+	if got := d.File(1, 3); got != DecisionPartial {
+		t.Errorf("File(1) = %s, want partial", got)
+	}
+	if d.Decided(1, 3) {
+		t.Error("expected file 1 undecided while hunk 1 is pending")
+	}
+	d.SetHunk(1, 1, DecisionRejected)
+	if !d.Decided(1, 3) {
+		t.Error("expected file 1 decided once every hunk is")
+	}
+
+	// Hunks agreeing make the file's decision theirs
+	d.SetHunk(1, 0, DecisionRejected)
+	if got := d.File(1, 3); got != DecisionRejected {
+		t.Errorf("File(1) = %s, want rejected", got)
+	}
+
+	// A file decision replaces its hunks', notes and all
+	d.SetNote(1, 2, "too broad")
+	d.SetFile(1, DecisionApproved)
+	if d.HunksDecided(1) || d.File(1, 3) != DecisionApproved || d.Note(1, 2) != "" {
+		t.Errorf("expected file decision to replace hunk decisions, got %+v", d)
+	}
+
+	d.SetFile(0, DecisionRejected)
+	d.SetNote(0, WholeFile, "not needed")
+	if d.Note(0, WholeFile) != "not needed" {
+		t.Errorf("expected a note on file 0, got %+v", d.Notes)
+	}
+	d.SetHunk(0, 0, DecisionApproved)
+	if d.Note(0, WholeFile) != "not needed" {
+		t.Error("expected a hunk decision to leave the file's note")
+	}
+
+	// Deciding a file on its own, or a hunk of it, clears the bulk mark
+	d.SetBulk(2, DecisionApproved)
+	d.SetBulk(3, DecisionApproved)
+	if !d.Bulk[2] || d.File(2, 1) != DecisionApproved || !d.Clone().Bulk[3] {
+		t.Errorf("expected files 2 and 3 approved in bulk, got %+v", d)
+	}
+	d.SetFile(2, DecisionApproved)
+	d.SetHunk(3, 0, DecisionApproved)
+	if d.Bulk[2] || d.Bulk[3] {
+		t.Errorf("expected explicit decisions to clear the bulk mark, got %v", d.Bulk)
+	}
+
+	// Conditions go with the approval they were given on
+	d.SetFile(4, DecisionApproved)
+	d.AddCondition(4, "add tests for the parser")
+	d.AddCondition(4, "")
+	d.AddCondition(4, "document the flag")
+	c := d.Clone()
+	c.AddCondition(4, "only in the clone")
+	if got := d.Conditions[4]; len(got) != 2 || got[1] != "document the flag" {
+		t.Errorf("unexpected conditions %q", got)
+	}
+	d.SetHunk(4, 0, DecisionApproved)
+	if len(d.Conditions[4]) != 0 || len(c.Conditions[4]) != 3 {
+		t.Errorf("expected deciding a hunk to drop the conditions, got %v / %v", d.Conditions, c.Conditions)
+	}
+
+	s := c.Select([]int{4, 0})
+	if s.File(0, 1) != DecisionApproved || len(s.Conditions[0]) != 3 || s.Hunk(1, 0) != DecisionApproved || s.Note(1, WholeFile) != "not needed" {
+		t.Errorf("expected files 4 and 0 renumbered, got %+v", s)
+	}
+}
+
+func TestLabels(t *testing.T) {
+	d := NewDecisions()
+	if !d.AddLabel(0, WholeFile, " #needs security review ") || d.AddLabel(0, WholeFile, "needs-security-review") || d.AddLabel(0, WholeFile, " ") {
+		t.Fatalf("expected one label added, got %v", d.Labels)
+	}
+	d.AddLabel(0, 1, "refactor-only")
+	d.AddLabel(0, 1, "needs-security-review")
+	c := d.Clone()
+	d.SetFile(0, DecisionApproved)
+	if got := d.FileLabels(0); len(got) != 2 || got[0] != "needs-security-review" || got[1] != "refactor-only" {
+		t.Errorf("expected the labels to outlast the decision, got %v", got)
+	}
+
+	d.SplitHunk(0, 1, 2)
+	if got := d.Label(0, 2); len(got) != 2 {
+		t.Errorf("expected the pieces to take the labels, got %v", d.Labels)
+	}
+	if !d.RemoveLabel(0, 1, "refactor-only") || d.RemoveLabel(0, 1, "refactor-only") || len(d.Label(0, 2)) != 2 {
+		t.Errorf("expected one piece's label removed, got %v", d.Labels)
+	}
+	d.RemoveLabel(0, WholeFile, "needs-security-review")
+	if _, ok := d.Labels[HunkKey{0, WholeFile}]; ok || len(c.Label(0, WholeFile)) != 1 {
+		t.Errorf("expected the emptied entry gone and the clone untouched, got %v / %v", d.Labels, c.Labels)
+	}
+}
+
+func TestDecisionsSplitHunk(t *testing.T) {
+	d := NewDecisions()
+	d.SetHunk(0, 0, DecisionApproved)
+	d.SetHunk(0, 1, DecisionRejected)
+	d.SetHunk(0, 2, DecisionApproved)
+	d.SetHunk(1, 1, DecisionRejected)
+	d.SetNote(0, 1, "no")
+
+	d.SplitHunk(0, 1, 3)
+	if d.Note(0, 1) != "no" || d.Note(0, 3) != "no" || d.Note(0, 4) != "" {
+		t.Errorf("expected the pieces to take the note, got %+v", d.Notes)
+	}
+	want := map[HunkKey]ReviewDecision{
+		{0, 0}: DecisionApproved,
+		{0, 1}: DecisionRejected,
+		{0, 2}: DecisionRejected,
+		{0, 3}: DecisionRejected,
+		{0, 4}: DecisionApproved,
+		{1, 1}: DecisionRejected,
+	}
+	if len(d.Hunks) != len(want) {
+		t.Fatalf("expected %d hunk decisions, got %+v", len(want), d.Hunks)
+	}
+	for k, dec := range want {
+		if d.Hunks[k] != dec {
+			t.Errorf("hunk %+v = %s, want %s", k, d.Hunks[k], dec)
+		}
+	}
+}
@@ -0,0 +1,223 @@
+package notify
+
+import (
+	"bytes"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aezell/agrev/internal/analysis"
+	"github.com/aezell/agrev/internal/config"
+	"github.com/aezell/agrev/internal/model"
+)
+
+// Email is one configured list of recipients and the mail server to send
+// to them through.
+type Email struct {
+	To        []string
+	From      string
+	Host      string
+	Port      int
+	Username  string
+	Password  string
+	Format    string // "html" or "markdown"
+	On        []string
+	MinRisk   model.RiskLevel
+	ReportURL string // linked from emails without a report, empty for none
+	Timeout   time.Duration
+}
+
+// NewEmail returns the email cfg describes, or nil if its password is in an
+// environment variable that isn't set.
+func NewEmail(cfg config.NotifyConfig) *Email {
+	e := &Email{
+		To:        cfg.To,
+		From:      cfg.From,
+		Host:      cfg.SMTP.Host,
+		Port:      cfg.SMTP.Port,
+		Username:  cfg.SMTP.Username,
+		Format:    cfg.Format,
+		On:        cfg.On,
+		ReportURL: os.ExpandEnv(cfg.ReportURL),
+		Timeout:   30 * time.Second,
+	}
+	if cfg.SMTP.PasswordEnv != "" {
+		if e.Password = os.Getenv(cfg.SMTP.PasswordEnv); e.Password == "" {
+			return nil
+		}
+	}
+	if e.Port == 0 {
+		e.Port = 587
+	}
+	if e.Format == "" {
+		e.Format = "html"
+	}
+	if len(e.On) == 0 {
+		e.On = config.NotifyCommands
+	}
+	e.MinRisk, _ = model.ParseRiskLevel(cfg.MinRisk)
+	return e
+}
+
+// Wants reports whether the email is sent about s, as Webhook.Wants does.
+func (e *Email) Wants(s Summary) bool {
+	return wants(e.On, e.MinRisk, s)
+}
+
+// Send emails s, with its report if it has one.
+func (e *Email) Send(s Summary) error {
+	msg, err := e.message(s, time.Now())
+	if err == nil {
+		err = e.send(msg)
+	}
+	if err != nil {
+		return fmt.Errorf("email to %s: %w", strings.Join(e.To, ", "), err)
+	}
+	return nil
+}
+
+// message is the email about s: the Markdown report, with the HTML report
+// as its alternative unless the format is markdown. A summary without a
+// report is sent as the plain text of a chat message.
+func (e *Email) message(s Summary, date time.Time) ([]byte, error) {
+	from, err := mail.ParseAddress(e.From)
+	if err != nil {
+		return nil, err
+	}
+	to := make([]string, len(e.To))
+	for i, addr := range e.To {
+		a, err := mail.ParseAddress(addr)
+		if err != nil {
+			return nil, err
+		}
+		to[i] = a.String()
+	}
+
+	text, html := plainText(e, s), ""
+	if s.Report != nil {
+		text = s.Report.Markdown()
+		if e.Format != "markdown" {
+			html = s.Report.HTML()
+		}
+	}
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", from)
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", Headline(s)))
+	fmt.Fprintf(&b, "Date: %s\r\n", date.Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	if html == "" {
+		b.WriteString("Content-Type: text/plain; charset=utf-8\r\nContent-Transfer-Encoding: quoted-printable\r\n\r\n")
+		err := writeQuotedPrintable(&b, text)
+		return b.Bytes(), err
+	}
+
+	mw := multipart.NewWriter(&b)
+	fmt.Fprintf(&b, "Content-Type: multipart/alternative; boundary=%q\r\n\r\n", mw.Boundary())
+	for _, part := range []struct{ typ, body string }{{"text/plain", text}, {"text/html", html}} {
+		w, err := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.typ + "; charset=utf-8"},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		if err := writeQuotedPrintable(w, part.body); err != nil {
+			return nil, err
+		}
+	}
+	if err := mw.Close(); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// plainText is the body of an email without a report.
+func plainText(e *Email, s Summary) string {
+	body := append([]string{Headline(s), ""}, lines(s, func(f analysis.Finding) string {
+		return fmt.Sprintf("- %s %s %s", f.Risk, location(f), f.Message)
+	})...)
+	if e.ReportURL != "" {
+		body = append(body, "", "Full report: "+e.ReportURL)
+	}
+	return strings.Join(body, "\n") + "\n"
+}
+
+func writeQuotedPrintable(w io.Writer, s string) error {
+	qp := quotedprintable.NewWriter(w)
+	if _, err := qp.Write([]byte(strings.ReplaceAll(s, "\n", "\r\n"))); err != nil {
+		return err
+	}
+	return qp.Close()
+}
+
+// send delivers msg through the mail server: over TLS on port 465, and
+// otherwise upgraded with STARTTLS if the server offers it.
+func (e *Email) send(msg []byte) error {
+	addr := net.JoinHostPort(e.Host, strconv.Itoa(e.Port))
+	dialer := &net.Dialer{Timeout: e.Timeout}
+	var conn net.Conn
+	var err error
+	if e.Port == 465 {
+		conn, err = tls.DialWithDialer(dialer, "tcp", addr, &tls.Config{ServerName: e.Host})
+	} else {
+		conn, err = dialer.Dial("tcp", addr)
+	}
+	if err != nil {
+		return err
+	}
+	conn.SetDeadline(time.Now().Add(e.Timeout))
+
+	c, err := smtp.NewClient(conn, e.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+	if ok, _ := c.Extension("STARTTLS"); ok && e.Port != 465 {
+		if err := c.StartTLS(&tls.Config{ServerName: e.Host}); err != nil {
+			return err
+		}
+	}
+	if e.Username != "" {
+		// PlainAuth refuses to send the password unencrypted, except to
+		// localhost
+		if err := c.Auth(smtp.PlainAuth("", e.Username, e.Password, e.Host)); err != nil {
+			return err
+		}
+	}
+
+	from, _ := mail.ParseAddress(e.From)
+	if err := c.Mail(from.Address); err != nil {
+		return err
+	}
+	for _, addr := range e.To {
+		to, _ := mail.ParseAddress(addr)
+		if err := c.Rcpt(to.Address); err != nil {
+			return err
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
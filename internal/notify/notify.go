@@ -0,0 +1,291 @@
+// Package notify posts how a check, gate, push, or review turned out to the
+// Slack and Discord incoming webhooks configured under notify in
+// .agrev.yml, and emails it, with the analysis report, to the addresses
+// configured there.
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/aezell/agrev/internal/analysis"
+	"github.com/aezell/agrev/internal/config"
+	"github.com/aezell/agrev/internal/model"
+	"github.com/aezell/agrev/internal/report"
+)
+
+// maxFindings is how many of the riskiest findings a message lists.
+const maxFindings = 5
+
+// Summary is what a message says about a finished command.
+type Summary struct {
+	Command string // "check", "gate", "pre-receive", or "review"
+	Subject string // what was looked at, e.g. "main...feature" or "working tree"
+
+	Files, Added, Deleted int
+	Results               *analysis.Results
+
+	// Report is the full report, which emails carry; nil for none.
+	Report *report.Report
+
+	// Reviewed is set for reviews, which count their decisions.
+	Reviewed                    bool
+	Approved, Rejected, Pending int
+
+	// Gated is set for gates, which pass unless they found violations.
+	Gated      bool
+	Violations int
+}
+
+// Webhook is one configured webhook.
+type Webhook struct {
+	Type      string // "slack" or "discord"
+	URL       string
+	On        []string
+	MinRisk   model.RiskLevel
+	ReportURL string // linked from each message, empty for none
+	HTTP      *http.Client
+}
+
+// New returns the webhook cfg describes, or nil if its URL is in an
+// environment variable that isn't set.
+func New(cfg config.NotifyConfig) *Webhook {
+	w := &Webhook{
+		Type:      cfg.Type,
+		URL:       cfg.URL,
+		On:        cfg.On,
+		ReportURL: os.ExpandEnv(cfg.ReportURL),
+		HTTP:      &http.Client{Timeout: 10 * time.Second},
+	}
+	if cfg.URLEnv != "" {
+		if w.URL = os.Getenv(cfg.URLEnv); w.URL == "" {
+			return nil
+		}
+	}
+	if len(w.On) == 0 {
+		w.On = config.NotifyCommands
+	}
+	w.MinRisk, _ = model.ParseRiskLevel(cfg.MinRisk)
+	return w
+}
+
+// Wants reports whether the webhook is told about s: the command is one
+// it is on, and its findings are risky enough or it went badly.
+func (w *Webhook) Wants(s Summary) bool {
+	return wants(w.On, w.MinRisk, s)
+}
+
+func wants(on []string, minRisk model.RiskLevel, s Summary) bool {
+	switch {
+	case !slices.Contains(on, s.Command):
+		return false
+	case s.Gated && s.Violations > 0, s.Reviewed && s.Rejected > 0:
+		return true
+	case minRisk == model.RiskInfo:
+		return true
+	}
+	return s.Results != nil && len(s.Results.Findings) > 0 && s.Results.MaxRisk() >= minRisk
+}
+
+// Send posts s to the webhook.
+func (w *Webhook) Send(s Summary) error {
+	var body any
+	switch w.Type {
+	case "slack":
+		body = w.slack(s)
+	case "discord":
+		body = w.discord(s)
+	default:
+		return fmt.Errorf("unknown webhook type %q", w.Type)
+	}
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	resp, err := w.HTTP.Post(w.URL, "application/json", bytes.NewReader(payload))
+	if ue := (*url.Error)(nil); errors.As(err, &ue) {
+		// Leave out the URL, which is a secret
+		err = ue.Err
+	}
+	if err != nil {
+		return fmt.Errorf("%s webhook: %w", w.Type, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 200))
+		return fmt.Errorf("%s webhook: %s: %s", w.Type, resp.Status, strings.TrimSpace(string(data)))
+	}
+	return nil
+}
+
+// notifier is a webhook or an email.
+type notifier interface {
+	Wants(Summary) bool
+	Send(Summary) error
+}
+
+// Send posts or emails s to each notifier in cfgs that wants it, and
+// returns what went wrong.
+func Send(cfgs []config.NotifyConfig, s Summary) []error {
+	var errs []error
+	for _, cfg := range cfgs {
+		var n notifier
+		if cfg.Type == "email" {
+			if e := NewEmail(cfg); e != nil {
+				n = e
+			}
+		} else if w := New(cfg); w != nil {
+			n = w
+		}
+		if n != nil && n.Wants(s) {
+			if err := n.Send(s); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errs
+}
+
+// Headline sums s up in a line, e.g. "agrev check of main...feature:
+// high risk, 4 findings".
+func Headline(s Summary) string {
+	out := fmt.Sprintf("agrev %s of %s: ", s.Command, s.Subject)
+	var parts []string
+	switch {
+	case s.Gated && s.Violations > 0:
+		parts = append(parts, fmt.Sprintf("failed with %d violation(s)", s.Violations))
+	case s.Gated:
+		parts = append(parts, "passed")
+	case s.Reviewed:
+		parts = append(parts, fmt.Sprintf("%d approved, %d rejected, %d pending", s.Approved, s.Rejected, s.Pending))
+	}
+	if s.Results != nil && len(s.Results.Findings) > 0 {
+		parts = append(parts, fmt.Sprintf("%s risk, %d finding(s)", s.Results.MaxRisk(), len(s.Results.Findings)))
+	} else if !s.Reviewed && !s.Gated {
+		parts = append(parts, "no issues found")
+	}
+	return out + strings.Join(parts, "; ")
+}
+
+// lines is the body of a message: the size of the change, the findings
+// by risk, and the riskiest of them, each formatted by finding. Only
+// findings quote text from the change.
+func lines(s Summary, finding func(analysis.Finding) string) []string {
+	out := []string{fmt.Sprintf("%d file(s) changed, +%d -%d", s.Files, s.Added, s.Deleted)}
+	if s.Results == nil {
+		return out
+	}
+	out = append(out, "Findings: "+s.Results.Summary())
+	findings := slices.Clone(s.Results.Findings)
+	slices.SortStableFunc(findings, func(a, b analysis.Finding) int { return int(b.Risk) - int(a.Risk) })
+	for i, f := range findings {
+		if i == maxFindings {
+			out = append(out, fmt.Sprintf("…and %d more", len(findings)-maxFindings))
+			break
+		}
+		out = append(out, finding(f))
+	}
+	return out
+}
+
+func location(f analysis.Finding) string {
+	if f.Line > 0 {
+		return fmt.Sprintf("%s:%d", f.File, f.Line)
+	}
+	return f.File
+}
+
+// color is the message's accent: red for high risk or a failure, yellow
+// for medium, green otherwise.
+func color(s Summary) int {
+	risk := model.RiskInfo
+	if s.Results != nil {
+		risk = s.Results.MaxRisk()
+	}
+	switch {
+	case risk >= model.RiskHigh, s.Gated && s.Violations > 0, s.Reviewed && s.Rejected > 0:
+		return 0xff5555
+	case risk >= model.RiskMedium:
+		return 0xf1fa8c
+	default:
+		return 0x50fa7b
+	}
+}
+
+type slackMessage struct {
+	Text        string            `json:"text"`
+	Attachments []slackAttachment `json:"attachments"`
+}
+
+type slackAttachment struct {
+	Color string `json:"color"`
+	Text  string `json:"text"`
+}
+
+// slackEscape escapes the characters Slack's mrkdwn reserves.
+var slackEscape = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+func (w *Webhook) slack(s Summary) slackMessage {
+	body := lines(s, func(f analysis.Finding) string {
+		return fmt.Sprintf("• *%s* `%s` %s", f.Risk, slackEscape.Replace(location(f)), slackEscape.Replace(f.Message))
+	})
+	if w.ReportURL != "" {
+		body = append(body, fmt.Sprintf("<%s|Full report>", w.ReportURL))
+	}
+	return slackMessage{
+		Text:        slackEscape.Replace(Headline(s)),
+		Attachments: []slackAttachment{{Color: fmt.Sprintf("#%06x", color(s)), Text: strings.Join(body, "\n")}},
+	}
+}
+
+type discordMessage struct {
+	Username string         `json:"username"`
+	Embeds   []discordEmbed `json:"embeds"`
+}
+
+type discordEmbed struct {
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	URL         string `json:"url,omitempty"`
+	Color       int    `json:"color"`
+}
+
+// Discord's limits on an embed's title and description
+const (
+	discordTitle       = 256
+	discordDescription = 4096
+)
+
+func (w *Webhook) discord(s Summary) discordMessage {
+	body := lines(s, func(f analysis.Finding) string {
+		return fmt.Sprintf("• **%s** `%s` %s", f.Risk, location(f), f.Message)
+	})
+	if w.ReportURL != "" {
+		body = append(body, fmt.Sprintf("[Full report](%s)", w.ReportURL))
+	}
+	return discordMessage{
+		Username: "agrev",
+		Embeds: []discordEmbed{{
+			Title:       truncate(Headline(s), discordTitle),
+			Description: truncate(strings.Join(body, "\n"), discordDescription),
+			URL:         w.ReportURL,
+			Color:       color(s),
+		}},
+	}
+}
+
+func truncate(s string, n int) string {
+	if r := []rune(s); len(r) > n {
+		return string(r[:n-1]) + "…"
+	}
+	return s
+}
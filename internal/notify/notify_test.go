@@ -0,0 +1,235 @@
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/mail"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aezell/agrev/internal/analysis"
+	"github.com/aezell/agrev/internal/config"
+	"github.com/aezell/agrev/internal/diff"
+	"github.com/aezell/agrev/internal/model"
+	"github.com/aezell/agrev/internal/report"
+)
+
+func testSummary() Summary {
+	var findings []analysis.Finding
+	for i := range 6 {
+		findings = append(findings, analysis.Finding{Pass: "anti_patterns", File: "util.go", Line: i + 1, Message: "TODO left in", Risk: model.RiskLow})
+	}
+	findings = append(findings, analysis.Finding{Pass: "security", File: "auth.go", Line: 12, Message: "compares <token> & key", Risk: model.RiskHigh})
+	return Summary{
+		Command: "check", Subject: "main...feature",
+		Files: 3, Added: 40, Deleted: 2,
+		Results: &analysis.Results{Findings: findings},
+	}
+}
+
+func TestHeadline(t *testing.T) {
+	s := testSummary()
+	if got := Headline(s); got != "agrev check of main...feature: high risk, 7 finding(s)" {
+		t.Errorf("unexpected check headline %q", got)
+	}
+	s.Command, s.Reviewed, s.Approved, s.Rejected, s.Pending = "review", true, 2, 1, 0
+	if got := Headline(s); got != "agrev review of main...feature: 2 approved, 1 rejected, 0 pending; high risk, 7 finding(s)" {
+		t.Errorf("unexpected review headline %q", got)
+	}
+	if got := Headline(Summary{Command: "gate", Subject: "HEAD", Gated: true}); got != "agrev gate of HEAD: passed" {
+		t.Errorf("unexpected gate headline %q", got)
+	}
+}
+
+func TestWants(t *testing.T) {
+	w := New(config.NotifyConfig{Type: "slack", URL: "http://example.com", On: []string{"check", "gate"}, MinRisk: "critical"})
+	s := testSummary()
+	if w.Wants(s) {
+		t.Error("expected high risk to fall short of critical")
+	}
+	if !w.Wants(Summary{Command: "gate", Gated: true, Violations: 1}) {
+		t.Error("expected a failed gate posted whatever its risk")
+	}
+	if w.Wants(Summary{Command: "review", Reviewed: true, Rejected: 1}) {
+		t.Error("expected reviews left out")
+	}
+	if New(config.NotifyConfig{Type: "slack", URLEnv: "AGREV_TEST_UNSET_WEBHOOK"}) != nil {
+		t.Error("expected a webhook with its variable unset skipped")
+	}
+}
+
+func TestSend(t *testing.T) {
+	var got map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("unexpected content type %q", r.Header.Get("Content-Type"))
+		}
+		json.NewDecoder(r.Body).Decode(&got)
+	}))
+	defer srv.Close()
+	t.Setenv("AGREV_TEST_WEBHOOK", srv.URL)
+	t.Setenv("AGREV_TEST_RUN", "42")
+
+	s := testSummary()
+	cfgs := []config.NotifyConfig{{Type: "slack", URLEnv: "AGREV_TEST_WEBHOOK", ReportURL: "https://ci.example.com/runs/$AGREV_TEST_RUN"}}
+	if errs := Send(cfgs, s); len(errs) > 0 {
+		t.Fatal(errs)
+	}
+	text := got["attachments"].([]any)[0].(map[string]any)["text"].(string)
+	lines := strings.Split(text, "\n")
+	if lines[2] != "• *high* `auth.go:12` compares &lt;token&gt; &amp; key" {
+		t.Errorf("expected the riskiest finding first, escaped, got %q", lines[2])
+	}
+	if len(lines) != 9 || lines[7] != "…and 2 more" || lines[8] != "<https://ci.example.com/runs/42|Full report>" {
+		t.Errorf("expected five findings and the report link, got:\n%s", text)
+	}
+
+	cfgs[0].Type = "discord"
+	if errs := Send(cfgs, s); len(errs) > 0 {
+		t.Fatal(errs)
+	}
+	embed := got["embeds"].([]any)[0].(map[string]any)
+	if embed["title"] != Headline(s) || embed["color"] != float64(0xff5555) || embed["url"] != "https://ci.example.com/runs/42" {
+		t.Errorf("unexpected embed %+v", embed)
+	}
+
+	srv.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "invalid_token", http.StatusForbidden)
+	})
+	if errs := Send(cfgs, s); len(errs) != 1 || !strings.Contains(errs[0].Error(), "invalid_token") {
+		t.Errorf("expected the webhook's error, got %v", errs)
+	}
+}
+
+// smtpServer accepts one message on a local port and returns its address
+// and the channel the message arrives on.
+func smtpServer(t *testing.T) (string, <-chan string) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skip(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	got := make(chan string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		tp := textproto.NewConn(conn)
+		tp.PrintfLine("220 localhost ready")
+		var data []string
+		for {
+			line, err := tp.ReadLine()
+			if err != nil {
+				return
+			}
+			switch verb := strings.ToUpper(strings.Fields(line + " ")[0]); verb {
+			case "EHLO":
+				tp.PrintfLine("250-localhost\r\n250 AUTH PLAIN")
+			case "AUTH":
+				tp.PrintfLine("235 ok")
+			case "DATA":
+				tp.PrintfLine("354 go ahead")
+				lines, _ := tp.ReadDotLines()
+				data = append(data, strings.Join(lines, "\r\n"))
+				tp.PrintfLine("250 queued")
+			case "QUIT":
+				tp.PrintfLine("221 bye")
+				got <- strings.Join(data, "")
+				return
+			default:
+				tp.PrintfLine("250 ok")
+			}
+		}
+	}()
+	return ln.Addr().String(), got
+}
+
+func TestSendEmail(t *testing.T) {
+	addr, got := smtpServer(t)
+	host, port, _ := net.SplitHostPort(addr)
+	portNum, _ := strconv.Atoi(port)
+	t.Setenv("AGREV_TEST_SMTP_PASSWORD", "secret")
+
+	ds, err := diff.Parse("diff --git a/auth.go b/auth.go\n--- a/auth.go\n+++ b/auth.go\n@@ -1,1 +1,2 @@\n package auth\n+// TODO\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := testSummary()
+	s.Report = &report.Report{Diff: ds, Results: s.Results, Generated: time.Now()}
+	cfgs := []config.NotifyConfig{{
+		Type: "email", To: []string{"Reviews <reviews@example.com>"}, From: "ci@example.com",
+		SMTP: config.SMTPConfig{Host: host, Port: portNum, Username: "ci", PasswordEnv: "AGREV_TEST_SMTP_PASSWORD"},
+	}}
+	if errs := Send(cfgs, s); len(errs) > 0 {
+		t.Fatal(errs)
+	}
+
+	msg, err := mail.ReadMessage(strings.NewReader(<-got))
+	if err != nil {
+		t.Fatal(err)
+	}
+	subject, _ := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	if subject != Headline(s) || msg.Header.Get("To") != `"Reviews" <reviews@example.com>` {
+		t.Errorf("unexpected headers %v", msg.Header)
+	}
+	mediaType, params, _ := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if mediaType != "multipart/alternative" {
+		t.Fatalf("expected a multipart message, got %q", mediaType)
+	}
+	mr := multipart.NewReader(msg.Body, params["boundary"])
+	var types []string
+	for {
+		p, err := mr.NextPart()
+		if err != nil {
+			break
+		}
+		body, _ := io.ReadAll(p) // decoded from quoted-printable
+		types = append(types, strings.Split(p.Header.Get("Content-Type"), ";")[0])
+		if !strings.Contains(string(body), "auth.go") {
+			t.Errorf("expected the %s report to list the findings, got:\n%s", types[len(types)-1], body)
+		}
+	}
+	if strings.Join(types, ",") != "text/plain,text/html" {
+		t.Errorf("expected Markdown and HTML parts, got %v", types)
+	}
+
+	t.Setenv("AGREV_TEST_SMTP_PASSWORD", "")
+	if NewEmail(cfgs[0]) != nil {
+		t.Error("expected an email with its password variable unset skipped")
+	}
+}
+
+func TestEmailWithoutReport(t *testing.T) {
+	e := NewEmail(config.NotifyConfig{Type: "email", To: []string{"a@example.com"}, From: "b@example.com", SMTP: config.SMTPConfig{Host: "smtp.example.com"}, ReportURL: "https://ci.example.com/runs/1"})
+	if e.Port != 587 || e.Format != "html" {
+		t.Errorf("unexpected defaults %+v", e)
+	}
+	raw, err := e.message(testSummary(), time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.Header.Get("Content-Type") != "text/plain; charset=utf-8" || msg.Header.Get("Date") != "Thu, 02 Jan 2025 03:04:05 +0000" {
+		t.Errorf("unexpected headers %v", msg.Header)
+	}
+	body, _ := io.ReadAll(quotedprintable.NewReader(msg.Body))
+	if !strings.Contains(string(body), "- high auth.go:12 compares <token> & key") || !strings.HasSuffix(string(body), "Full report: https://ci.example.com/runs/1\r\n") {
+		t.Errorf("unexpected body:\n%s", body)
+	}
+}
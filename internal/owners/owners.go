@@ -0,0 +1,156 @@
+// Package owners reads a repository's CODEOWNERS file and looks up who owns
+// a changed path.
+package owners
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/aezell/agrev/internal/diff"
+)
+
+// Paths are where a CODEOWNERS file is looked for, in the order GitHub
+// looks.
+var Paths = []string{".github/CODEOWNERS", "CODEOWNERS", "docs/CODEOWNERS"}
+
+// Rule is one line of a CODEOWNERS file.
+type Rule struct {
+	Pattern string
+	Owners  []string // e.g. "@org/team", "@user", "user@example.com"
+	Line    int
+}
+
+// File is a parsed CODEOWNERS file.
+type File struct {
+	Path  string // relative to the repository root
+	Rules []Rule
+}
+
+// Load reads the repository's CODEOWNERS file. A missing file yields nil.
+func Load(repoDir string) (*File, error) {
+	for _, p := range Paths {
+		f, err := os.Open(filepath.Join(repoDir, p))
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %w", p, err)
+		}
+		defer f.Close()
+		co, err := Parse(f)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", p, err)
+		}
+		co.Path = p
+		return co, nil
+	}
+	return nil, nil
+}
+
+// Parse reads CODEOWNERS rules. Blank lines, comments, and GitLab section
+// headers ("[Docs]") are skipped; a pattern with no owners is kept, since it
+// clears the owners of the paths it matches.
+func Parse(r io.Reader) (*File, error) {
+	co := &File{}
+	sc := bufio.NewScanner(r)
+	for n := 1; sc.Scan(); n++ {
+		line := sc.Text()
+		if i := strings.Index(line, " #"); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") || strings.HasPrefix(fields[0], "[") {
+			continue
+		}
+		co.Rules = append(co.Rules, Rule{Pattern: fields[0], Owners: fields[1:], Line: n})
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	return co, nil
+}
+
+// Owners returns the owners of name, a slash-separated path relative to the
+// repository root. As on GitHub, the last matching rule wins. A nil File
+// owns nothing.
+func (co *File) Owners(name string) []string {
+	if co == nil {
+		return nil
+	}
+	for i := len(co.Rules) - 1; i >= 0; i-- {
+		if Match(co.Rules[i].Pattern, name) {
+			return co.Rules[i].Owners
+		}
+	}
+	return nil
+}
+
+// Of returns the owners of a changed file: those of its path, or of both
+// paths of a rename, each once. A nil File owns nothing.
+func (co *File) Of(f *diff.File) []string {
+	paths := []string{f.NewName}
+	switch {
+	case f.IsDeleted:
+		paths = []string{f.OldName}
+	case f.IsRenamed && f.OldName != f.NewName:
+		paths = []string{f.OldName, f.NewName}
+	}
+	var owners []string
+	for _, p := range paths {
+		for _, o := range co.Owners(p) {
+			if !slices.Contains(owners, o) {
+				owners = append(owners, o)
+			}
+		}
+	}
+	return owners
+}
+
+// Match reports whether a CODEOWNERS pattern matches name. Patterns follow
+// gitignore: one starting with or containing a slash is anchored at the
+// repository root, any other matches at any depth, and a pattern naming a
+// directory covers everything in it. "*" stays within a directory; "**"
+// crosses them.
+func Match(pattern, name string) bool {
+	pattern, dir := strings.CutSuffix(pattern, "/")
+	anchored := strings.Contains(pattern, "/")
+	segs := strings.Split(strings.TrimPrefix(pattern, "/"), "/")
+	if !anchored {
+		segs = append([]string{"**"}, segs...)
+	}
+	// "docs/*" owns the files directly in docs/, not those further down
+	if dir || !strings.Contains(segs[len(segs)-1], "*") {
+		segs = append(segs, "**")
+	}
+	return matchSegments(segs, strings.Split(name, "/"))
+}
+
+func matchSegments(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			rest := pattern[1:]
+			for i := 0; i <= len(name); i++ {
+				if matchSegments(rest, name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], name[0]); !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
@@ -0,0 +1,82 @@
+package owners
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/aezell/agrev/internal/diff"
+)
+
+const codeowners = `# Default owners
+*           @org/core
+
+*.md        @org/docs   # docs anywhere
+/internal/auth/ @org/security
+docs/*      @ada
+build       @ops
+/vendor/
+`
+
+func TestOwners(t *testing.T) {
+	co, err := Parse(strings.NewReader(codeowners))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if len(co.Rules) != 6 || co.Rules[1].Line != 4 {
+		t.Fatalf("unexpected rules %+v", co.Rules)
+	}
+
+	tests := []struct {
+		name string
+		want []string
+	}{
+		{"main.go", []string{"@org/core"}},
+		{"pkg/README.md", []string{"@org/docs"}},
+		{"internal/auth/token.go", []string{"@org/security"}},
+		{"internal/auth/sub/key.go", []string{"@org/security"}},
+		{"pkg/internal/auth/token.go", []string{"@org/core"}}, // anchored at the root
+		{"docs/intro.txt", []string{"@ada"}},
+		{"docs/api/intro.txt", []string{"@org/core"}}, // docs/* stays in docs/
+		{"tools/build/run.sh", []string{"@ops"}},
+		{"vendor/lib/lib.go", nil}, // no owners clears them
+	}
+	for _, tt := range tests {
+		if got := co.Owners(tt.name); !slices.Equal(got, tt.want) {
+			t.Errorf("Owners(%q) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	renamed := &diff.File{OldName: "docs/old.txt", NewName: "internal/auth/new.go", IsRenamed: true}
+	if got := co.Of(renamed); !slices.Equal(got, []string{"@ada", "@org/security"}) {
+		t.Errorf("expected a rename owned by both sides' owners, got %v", got)
+	}
+
+	var none *File
+	if got := none.Owners("main.go"); got != nil {
+		t.Errorf("expected a nil File to own nothing, got %v", got)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	if co, err := Load(dir); co != nil || err != nil {
+		t.Fatalf("expected no CODEOWNERS, got %+v, %v", co, err)
+	}
+
+	if err := os.MkdirAll(filepath.Join(dir, ".github"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, ".github", "CODEOWNERS"), []byte("*.go @gophers\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	co, err := Load(dir)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if co.Path != ".github/CODEOWNERS" || !slices.Equal(co.Owners("cmd/main.go"), []string{"@gophers"}) {
+		t.Errorf("unexpected CODEOWNERS %+v", co)
+	}
+}
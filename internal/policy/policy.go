@@ -0,0 +1,315 @@
+// Package policy reads the review policy in .agrev/policy.yml: rules that
+// block a change, require files to be approved by hand, or approve them
+// without review, depending on their paths and findings, and whether the
+// review checklist must be completed. 'agrev gate' and
+// 'agrev check' enforce it, and the review TUI shows it as hints.
+package policy
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+	"github.com/aezell/agrev/internal/analysis"
+	"github.com/aezell/agrev/internal/diff"
+	"github.com/aezell/agrev/internal/gate"
+	"github.com/aezell/agrev/internal/history"
+	"github.com/aezell/agrev/internal/model"
+)
+
+// FileName is the policy file inside history.Dir.
+const FileName = "policy.yml"
+
+// Action is what a rule does to the files it applies to.
+type Action string
+
+const (
+	// ActionBlock fails the gate and check.
+	ActionBlock Action = "block"
+
+	// ActionRequireApproval fails the gate unless the saved review approved
+	// the file on its own, not in bulk.
+	ActionRequireApproval Action = "require_approval"
+
+	// ActionAutoApprove approves the file when the review starts, unless
+	// another rule applies to it.
+	ActionAutoApprove Action = "auto_approve"
+)
+
+// Policy is a set of rules. Every rule that applies to a file takes effect.
+type Policy struct {
+	Rules []Rule `yaml:"rules"`
+
+	// RequireChecklist requires every item of the review checklist in
+	// .agrev.yml to be checked off before the review is finished.
+	RequireChecklist bool `yaml:"require_checklist"`
+}
+
+// RequiresChecklist reports whether the review checklist must be completed.
+// A nil Policy doesn't require it.
+func (p *Policy) RequiresChecklist() bool {
+	return p != nil && p.RequireChecklist
+}
+
+// Rule applies its action to the files matching Paths, if set, that have a
+// finding matching Pass and Risk, if either is set.
+type Rule struct {
+	// Name identifies the rule in hints and violations.
+	Name string `yaml:"name"`
+
+	Action Action `yaml:"action"`
+
+	// Paths are globs, as in the gate's forbidden_paths.
+	Paths []string `yaml:"paths"`
+
+	// Pass names the analysis pass whose findings the rule is about.
+	Pass string `yaml:"pass"`
+
+	// Risk is the lowest finding risk the rule applies to.
+	Risk string `yaml:"risk"`
+
+	// Message explains the rule to the reviewer. Empty describes it by its
+	// action.
+	Message string `yaml:"message"`
+
+	risk model.RiskLevel // Risk, parsed
+}
+
+// Path returns the policy file of a repository.
+func Path(repoDir string) string {
+	return filepath.Join(repoDir, history.Dir, FileName)
+}
+
+// Load reads the repository's policy. A missing file yields nil.
+func Load(repoDir string) (*Policy, error) {
+	data, err := os.ReadFile(Path(repoDir))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading policy: %w", err)
+	}
+	p, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filepath.Join(history.Dir, FileName), err)
+	}
+	return p, nil
+}
+
+// Parse decodes a policy and checks its rules.
+func Parse(data []byte) (*Policy, error) {
+	var p Policy
+	if err := yaml.Unmarshal(data, &p); err != nil {
+		return nil, fmt.Errorf("parsing policy: %w", err)
+	}
+	for i := range p.Rules {
+		r := &p.Rules[i]
+		if r.Name == "" {
+			r.Name = fmt.Sprintf("rule %d", i+1)
+		}
+		switch r.Action {
+		case ActionBlock, ActionRequireApproval:
+		case ActionAutoApprove:
+			if r.Pass != "" || r.Risk != "" {
+				return nil, fmt.Errorf("%s: auto_approve rules match paths only", r.Name)
+			}
+		default:
+			return nil, fmt.Errorf("%s: unknown action %q (want block, require_approval, or auto_approve)", r.Name, r.Action)
+		}
+		if len(r.Paths) == 0 && r.Pass == "" && r.Risk == "" {
+			return nil, fmt.Errorf("%s: a rule needs paths, pass, or risk", r.Name)
+		}
+		if r.Risk != "" {
+			risk, ok := model.ParseRiskLevel(r.Risk)
+			if !ok {
+				return nil, fmt.Errorf("%s: unknown risk level %q", r.Name, r.Risk)
+			}
+			r.risk = risk
+		}
+	}
+	return &p, nil
+}
+
+// Hit is a rule applying to one file of a diff.
+type Hit struct {
+	Rule     *Rule
+	File     int                // index into the diff's files
+	Findings []analysis.Finding // those the rule applies for, when it is about findings
+}
+
+// Describe explains the hit in a line, e.g. "schema-review: requires
+// approval (high schema finding)".
+func (h Hit) Describe() string {
+	what := h.Rule.Message
+	if what == "" {
+		switch h.Rule.Action {
+		case ActionBlock:
+			what = "blocks the change"
+		case ActionRequireApproval:
+			what = "requires approval"
+		case ActionAutoApprove:
+			what = "approved automatically"
+		}
+	}
+	if len(h.Findings) > 0 {
+		f := h.Findings[0]
+		what += fmt.Sprintf(" (%s %s finding", f.Risk, f.Pass)
+		if n := len(h.Findings); n > 1 {
+			what += fmt.Sprintf(" and %d more", n-1)
+		}
+		what += ")"
+	}
+	return h.Rule.Name + ": " + what
+}
+
+// Match returns the rules that apply to f, given its findings, along with
+// the findings each applies for.
+func (p *Policy) Match(f *diff.File, findings []analysis.Finding) []Hit {
+	if p == nil {
+		return nil
+	}
+	var hits []Hit
+	for i := range p.Rules {
+		r := &p.Rules[i]
+		if len(r.Paths) > 0 && !matchPaths(r.Paths, f) {
+			continue
+		}
+		hit := Hit{Rule: r}
+		if r.Pass != "" || r.Risk != "" {
+			for _, fd := range findings {
+				if (r.Pass == "" || fd.Pass == r.Pass) && fd.Risk >= r.risk {
+					hit.Findings = append(hit.Findings, fd)
+				}
+			}
+			if len(hit.Findings) == 0 {
+				continue
+			}
+		}
+		hits = append(hits, hit)
+	}
+	return hits
+}
+
+// Evaluate returns the rules applying to each file of ds, in file order.
+// results may be nil. A nil Policy has no rules.
+func (p *Policy) Evaluate(ds *diff.DiffSet, results *analysis.Results) []Hit {
+	if p == nil {
+		return nil
+	}
+	var byFile map[string][]analysis.Finding
+	if results != nil {
+		byFile = results.ByFile()
+	}
+	var hits []Hit
+	for i, f := range ds.Files {
+		var findings []analysis.Finding
+		for _, name := range gate.FilePaths(f) {
+			findings = append(findings, byFile[name]...)
+		}
+		for _, h := range p.Match(f, findings) {
+			h.File = i
+			hits = append(hits, h)
+		}
+	}
+	return hits
+}
+
+// AutoApproved returns the files an auto_approve rule applies to that no
+// other rule does, in file order.
+func AutoApproved(hits []Hit) []int {
+	auto := make(map[int]bool)
+	var files []int
+	for _, h := range hits {
+		if h.Rule.Action == ActionAutoApprove && !auto[h.File] {
+			auto[h.File] = true
+			files = append(files, h.File)
+		}
+	}
+	var out []int
+	for _, i := range files {
+		if !overridden(hits, i) {
+			out = append(out, i)
+		}
+	}
+	return out
+}
+
+// overridden reports whether a block or require_approval rule applies to
+// file i.
+func overridden(hits []Hit, i int) bool {
+	for _, h := range hits {
+		if h.File == i && h.Rule.Action != ActionAutoApprove {
+			return true
+		}
+	}
+	return false
+}
+
+// Blocked returns the hits of block rules.
+func Blocked(hits []Hit) []Hit {
+	var out []Hit
+	for _, h := range hits {
+		if h.Rule.Action == ActionBlock {
+			out = append(out, h)
+		}
+	}
+	return out
+}
+
+// Violations turns the hits on ds into gate violations: every block, and
+// every require_approval on a file the review's decisions don't approve on
+// its own.
+func Violations(ds *diff.DiffSet, hits []Hit, decisions model.Decisions) []gate.Violation {
+	var violations []gate.Violation
+	for _, h := range hits {
+		f := ds.Files[h.File]
+		v := gate.Violation{Rule: gate.RulePolicy, Policy: h.Rule.Name, File: f.Name(), Message: h.Describe()}
+		if len(h.Findings) > 0 {
+			v.Pass, v.Line, v.Risk = h.Findings[0].Pass, h.Findings[0].Line, h.Findings[0].Risk
+		}
+		switch h.Rule.Action {
+		case ActionBlock:
+		case ActionRequireApproval:
+			why := gate.Unapproved(decisions, h.File, len(f.Fragments))
+			if why == "" {
+				continue
+			}
+			v.Message += "; " + why
+		default:
+			continue
+		}
+		violations = append(violations, v)
+	}
+	return violations
+}
+
+// ChecklistViolations turns the checklist items not checked off into gate
+// violations, for a policy requiring the checklist.
+func ChecklistViolations(items []model.ChecklistItem) []gate.Violation {
+	var violations []gate.Violation
+	for _, c := range items {
+		if !c.Done {
+			violations = append(violations, gate.Violation{
+				Rule:    gate.RulePolicy,
+				Policy:  "require_checklist",
+				Message: "checklist item not checked off: " + c.Text,
+			})
+		}
+	}
+	return violations
+}
+
+// matchPaths reports whether any of f's paths match a glob.
+func matchPaths(globs []string, f *diff.File) bool {
+	for _, name := range gate.FilePaths(f) {
+		for _, g := range globs {
+			if gate.Match(g, name) {
+				return true
+			}
+		}
+	}
+	return false
+}
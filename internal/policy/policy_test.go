@@ -0,0 +1,156 @@
+package policy
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/aezell/agrev/internal/analysis"
+	"github.com/aezell/agrev/internal/diff"
+	"github.com/aezell/agrev/internal/gate"
+	"github.com/aezell/agrev/internal/model"
+)
+
+const testPolicy = `rules:
+  - name: schema-review
+    action: require_approval
+    pass: schema
+  - name: no-critical
+    action: block
+    risk: critical
+  - name: docs
+    action: auto_approve
+    paths: ["**/*.md", "docs/**"]
+`
+
+const testDiff = `diff --git a/db/001_init.sql b/db/001_init.sql
+new file mode 100644
+--- /dev/null
++++ b/db/001_init.sql
+@@ -0,0 +1 @@
++DROP TABLE users;
+diff --git a/README.md b/README.md
+--- a/README.md
++++ b/README.md
+@@ -1 +1 @@
+-old
++new
+diff --git a/docs/schema.md b/docs/schema.md
+--- a/docs/schema.md
++++ b/docs/schema.md
+@@ -1 +1 @@
+-old
++new
+`
+
+func setup(t *testing.T) (*Policy, *diff.DiffSet, []Hit) {
+	t.Helper()
+	p, err := Parse([]byte(testPolicy))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	ds, err := diff.Parse(testDiff)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	results := &analysis.Results{Findings: []analysis.Finding{
+		{Pass: "schema", File: "db/001_init.sql", Line: 1, Message: "drops table", Risk: model.RiskCritical},
+		{Pass: "schema", File: "docs/schema.md", Line: 1, Message: "mentions DDL", Risk: model.RiskLow},
+	}}
+	return p, ds, p.Evaluate(ds, results)
+}
+
+func TestEvaluate(t *testing.T) {
+	_, _, hits := setup(t)
+
+	var got []string
+	for _, h := range hits {
+		got = append(got, fmt.Sprintf("%s@%d", h.Rule.Name, h.File))
+	}
+	want := []string{"schema-review@0", "no-critical@0", "docs@1", "schema-review@2", "docs@2"}
+	if !slices.Equal(got, want) {
+		t.Errorf("hits = %v, want %v", got, want)
+	}
+	if d := hits[1].Describe(); d != "no-critical: blocks the change (critical schema finding)" {
+		t.Errorf("unexpected description %q", d)
+	}
+
+	// The schema finding keeps docs/schema.md from being approved unseen
+	if auto := AutoApproved(hits); !slices.Equal(auto, []int{1}) {
+		t.Errorf("AutoApproved = %v, want [1]", auto)
+	}
+	if blocked := Blocked(hits); len(blocked) != 1 || blocked[0].File != 0 {
+		t.Errorf("unexpected blocks %+v", blocked)
+	}
+}
+
+func TestViolations(t *testing.T) {
+	_, ds, hits := setup(t)
+	decisions := model.NewDecisions()
+	decisions.SetFile(0, model.DecisionApproved)
+	decisions.SetBulk(2, model.DecisionApproved)
+
+	violations := Violations(ds, hits, decisions)
+	if len(violations) != 2 {
+		t.Fatalf("expected a block and an unapproved schema doc, got %+v", violations)
+	}
+	if v := violations[0]; v.Rule != gate.RulePolicy || v.Policy != "no-critical" || v.Risk != model.RiskCritical || v.Line != 1 {
+		t.Errorf("unexpected block %+v", v)
+	}
+	if v := violations[1]; v.Policy != "schema-review" || v.File != "docs/schema.md" ||
+		!strings.HasSuffix(v.Message, "approved only in bulk, not on its own") {
+		t.Errorf("unexpected approval violation %+v", v)
+	}
+}
+
+func TestChecklistViolations(t *testing.T) {
+	p, err := Parse([]byte("require_checklist: true\n"))
+	if err != nil || !p.RequiresChecklist() {
+		t.Fatalf("expected the checklist required, got %+v, %v", p, err)
+	}
+	if (*Policy)(nil).RequiresChecklist() {
+		t.Error("expected no policy to require no checklist")
+	}
+
+	violations := ChecklistViolations([]model.ChecklistItem{
+		{Text: "ran it locally", Done: true},
+		{Text: "confirm the backfill plan"},
+	})
+	if len(violations) != 1 || violations[0].Policy != "require_checklist" ||
+		violations[0].Message != "checklist item not checked off: confirm the backfill plan" {
+		t.Errorf("unexpected violations %+v", violations)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, bad := range []string{
+		"rules: [{action: ship, paths: [a]}]",
+		"rules: [{action: block}]",
+		"rules: [{action: block, risk: severe}]",
+		"rules: [{action: auto_approve, pass: schema}]",
+	} {
+		if _, err := Parse([]byte(bad)); err == nil {
+			t.Errorf("expected %q to be refused", bad)
+		}
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	if p, err := Load(dir); p != nil || err != nil {
+		t.Fatalf("expected no policy, got %+v, %v", p, err)
+	}
+	if err := os.MkdirAll(filepath.Dir(Path(dir)), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(Path(dir), []byte(testPolicy), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	p, err := Load(dir)
+	if err != nil || len(p.Rules) != 3 {
+		t.Fatalf("unexpected policy %+v, %v", p, err)
+	}
+}
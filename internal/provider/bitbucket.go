@@ -0,0 +1,206 @@
+package provider
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/aezell/agrev/internal/diff"
+)
+
+// Bitbucket reviews Bitbucket Cloud pull requests.
+type Bitbucket struct {
+	BaseURL string
+
+	// Token is an access token; otherwise Username and AppPassword are
+	// used for basic auth.
+	Token       string
+	Username    string
+	AppPassword string
+}
+
+// NewBitbucket returns a Bitbucket Cloud provider configured from
+// BITBUCKET_TOKEN, or BITBUCKET_USERNAME and BITBUCKET_APP_PASSWORD.
+// BITBUCKET_API_URL overrides the API location.
+func NewBitbucket() *Bitbucket {
+	base := os.Getenv("BITBUCKET_API_URL")
+	if base == "" {
+		base = "https://api.bitbucket.org/2.0"
+	}
+	return &Bitbucket{
+		BaseURL:     strings.TrimRight(base, "/"),
+		Token:       os.Getenv("BITBUCKET_TOKEN"),
+		Username:    os.Getenv("BITBUCKET_USERNAME"),
+		AppPassword: os.Getenv("BITBUCKET_APP_PASSWORD"),
+	}
+}
+
+func (b *Bitbucket) Name() string { return "bitbucket" }
+
+// ParseRef accepts a number, "workspace/repo#12", or a URL like
+// https://bitbucket.org/workspace/repo/pull-requests/12.
+func (b *Bitbucket) ParseRef(arg, repo string) (Ref, error) {
+	arg = strings.TrimSpace(arg)
+	if strings.Contains(arg, "://") {
+		u, err := url.Parse(arg)
+		if err != nil {
+			return Ref{}, fmt.Errorf("invalid pull request URL: %w", err)
+		}
+		parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+		if len(parts) < 4 || parts[2] != "pull-requests" {
+			return Ref{}, fmt.Errorf("%s is not a Bitbucket pull request URL", arg)
+		}
+		arg, repo = parts[3], parts[0]+"/"+parts[1]
+	} else if r, num, ok := strings.Cut(arg, "#"); ok && r != "" {
+		arg, repo = num, r
+	}
+
+	if n, err := strconv.Atoi(strings.TrimPrefix(arg, "#")); err != nil || n <= 0 {
+		return Ref{}, fmt.Errorf("invalid pull request %q: want a number or URL", arg)
+	}
+	if ws, name, ok := strings.Cut(repo, "/"); !ok || ws == "" || name == "" {
+		return Ref{}, fmt.Errorf("repository %q must be workspace/repo", repo)
+	}
+	return Ref{Repo: repo, ID: strings.TrimPrefix(arg, "#")}, nil
+}
+
+type bitbucketPR struct {
+	ID     int    `json:"id"`
+	Title  string `json:"title"`
+	Author struct {
+		DisplayName string `json:"display_name"`
+	} `json:"author"`
+	Source      bitbucketEndpoint `json:"source"`
+	Destination bitbucketEndpoint `json:"destination"`
+	Links       struct {
+		HTML struct {
+			Href string `json:"href"`
+		} `json:"html"`
+	} `json:"links"`
+}
+
+type bitbucketEndpoint struct {
+	Branch struct {
+		Name string `json:"name"`
+	} `json:"branch"`
+	Commit struct {
+		Hash string `json:"hash"`
+	} `json:"commit"`
+	Repository struct {
+		FullName string `json:"full_name"`
+	} `json:"repository"`
+}
+
+func (b *Bitbucket) Fetch(ref Ref) (*Change, error) {
+	data, err := request(http.MethodGet, b.prURL(ref, ""), nil, b.auth)
+	if err != nil {
+		return nil, fmt.Errorf("fetching %s#%s: %w", ref.Repo, ref.ID, err)
+	}
+	var pr bitbucketPR
+	if err := json.Unmarshal(data, &pr); err != nil {
+		return nil, fmt.Errorf("decoding pull request: %w", err)
+	}
+	raw, err := request(http.MethodGet, b.prURL(ref, "/diff"), nil, b.auth)
+	if err != nil {
+		return nil, fmt.Errorf("fetching diff for %s#%s: %w", ref.Repo, ref.ID, err)
+	}
+
+	c := &Change{
+		Ref:     ref,
+		Label:   "PR #" + ref.ID,
+		Title:   pr.Title,
+		Author:  pr.Author.DisplayName,
+		Source:  pr.Source.Branch.Name,
+		Target:  pr.Destination.Branch.Name,
+		URL:     pr.Links.HTML.Href,
+		HeadSHA: pr.Source.Commit.Hash,
+		Host:    "bitbucket.org",
+		Diff:    string(raw),
+	}
+	// Bitbucket has no pull request refs; the branch works unless it's a fork
+	if strings.EqualFold(pr.Source.Repository.FullName, ref.Repo) {
+		c.FetchRef = "refs/heads/" + pr.Source.Branch.Name
+	}
+	return c, nil
+}
+
+// PostReview posts each inline comment, then the body as a general
+// comment, and finally approves or requests changes if asked to.
+func (b *Bitbucket) PostReview(c *Change, r Review) (string, error) {
+	ds, err := diff.Parse(c.Diff)
+	if err != nil {
+		return "", fmt.Errorf("parsing diff: %w", err)
+	}
+
+	type inline struct {
+		Path string `json:"path"`
+		To   int    `json:"to,omitempty"`
+		From int    `json:"from,omitempty"`
+	}
+	type comment struct {
+		Content struct {
+			Raw string `json:"raw"`
+		} `json:"content"`
+		Inline *inline `json:"inline,omitempty"`
+	}
+
+	var outside []Comment
+	for _, cm := range r.Comments {
+		f := findFile(ds, cm.Path)
+		if f == nil {
+			outside = append(outside, cm)
+			continue
+		}
+		if _, ok := f.Position(cm.Line, cm.Old); !ok {
+			outside = append(outside, cm)
+			continue
+		}
+		var body comment
+		body.Content.Raw = cm.Body
+		body.Inline = &inline{Path: cm.Path}
+		if cm.Old {
+			body.Inline.From = cm.Line
+		} else {
+			body.Inline.To = cm.Line
+		}
+		if _, err := request(http.MethodPost, b.prURL(c.Ref, "/comments"), body, b.auth); err != nil {
+			return "", fmt.Errorf("posting comment on %s: %w", cm.Path, err)
+		}
+	}
+
+	var summary comment
+	summary.Content.Raw = r.Body + formatOutside(outside)
+	if _, err := request(http.MethodPost, b.prURL(c.Ref, "/comments"), summary, b.auth); err != nil {
+		return "", fmt.Errorf("posting review summary: %w", err)
+	}
+
+	switch r.Event {
+	case EventApprove:
+		_, err = request(http.MethodPost, b.prURL(c.Ref, "/approve"), nil, b.auth)
+	case EventRequestChanges:
+		_, err = request(http.MethodPost, b.prURL(c.Ref, "/request-changes"), nil, b.auth)
+	}
+	if err != nil {
+		return "", fmt.Errorf("setting review status: %w", err)
+	}
+	return c.URL, nil
+}
+
+func (b *Bitbucket) prURL(ref Ref, suffix string) string {
+	ws, name, _ := strings.Cut(ref.Repo, "/")
+	return fmt.Sprintf("%s/repositories/%s/%s/pullrequests/%s%s", b.BaseURL,
+		url.PathEscape(ws), url.PathEscape(name), url.PathEscape(ref.ID), suffix)
+}
+
+func (b *Bitbucket) auth(req *http.Request) {
+	switch {
+	case b.Token != "":
+		req.Header.Set("Authorization", "Bearer "+b.Token)
+	case b.Username != "":
+		req.SetBasicAuth(b.Username, b.AppPassword)
+	}
+}
@@ -0,0 +1,244 @@
+package provider
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/aezell/agrev/internal/diff"
+)
+
+// Gerrit reviews Gerrit changes, posting findings as robot comments.
+type Gerrit struct {
+	// BaseURL is the Gerrit server, e.g. https://review.example.com.
+	BaseURL string
+
+	// Username and Password are HTTP credentials from the Gerrit settings
+	// page. Without them only public changes can be read.
+	Username string
+	Password string
+}
+
+// NewGerrit returns a Gerrit provider configured from GERRIT_URL,
+// GERRIT_USERNAME, and GERRIT_PASSWORD. A change URL passed to ParseRef
+// sets the server too.
+func NewGerrit() *Gerrit {
+	return &Gerrit{
+		BaseURL:  strings.TrimRight(os.Getenv("GERRIT_URL"), "/"),
+		Username: os.Getenv("GERRIT_USERNAME"),
+		Password: os.Getenv("GERRIT_PASSWORD"),
+	}
+}
+
+func (g *Gerrit) Name() string { return "gerrit" }
+
+// ParseRef accepts a change number, a Change-Id (I1234abcd...), or a URL
+// like https://review.example.com/c/project/+/12345. repo, if set, is the
+// project.
+func (g *Gerrit) ParseRef(arg, repo string) (Ref, error) {
+	arg = strings.TrimSpace(arg)
+	if strings.Contains(arg, "://") {
+		u, err := url.Parse(arg)
+		if err != nil {
+			return Ref{}, fmt.Errorf("invalid change URL: %w", err)
+		}
+		// Old-style URLs keep the path in the fragment: /#/c/12345/
+		path := u.Path
+		if strings.HasPrefix(u.Fragment, "/c/") {
+			path = u.Fragment
+		}
+		prefix, rest, ok := strings.Cut(path, "/c/")
+		if !ok {
+			return Ref{}, fmt.Errorf("%s is not a Gerrit change URL", arg)
+		}
+		g.BaseURL = strings.TrimRight(u.Scheme+"://"+u.Host+prefix, "/")
+		rest = strings.Trim(rest, "/")
+		if project, change, ok := strings.Cut(rest, "/+/"); ok {
+			repo = project
+			rest = change
+		}
+		arg, _, _ = strings.Cut(rest, "/") // drop the patch set
+	}
+
+	if arg == "" || !(isChangeNumber(arg) || isChangeID(arg)) {
+		return Ref{}, fmt.Errorf("invalid change %q: want a number, Change-Id, or URL", arg)
+	}
+	return Ref{Repo: repo, ID: arg}, nil
+}
+
+func isChangeNumber(s string) bool {
+	n, err := strconv.Atoi(s)
+	return err == nil && n > 0
+}
+
+func isChangeID(s string) bool {
+	if len(s) != 41 || s[0] != 'I' {
+		return false
+	}
+	for _, c := range s[1:] {
+		if !strings.ContainsRune("0123456789abcdef", c) {
+			return false
+		}
+	}
+	return true
+}
+
+type gerritChange struct {
+	Project         string `json:"project"`
+	Branch          string `json:"branch"`
+	Topic           string `json:"topic"`
+	Subject         string `json:"subject"`
+	Number          int    `json:"_number"`
+	CurrentRevision string `json:"current_revision"`
+	Owner           struct {
+		Name     string `json:"name"`
+		Username string `json:"username"`
+	} `json:"owner"`
+	Revisions map[string]struct {
+		Number int    `json:"_number"`
+		Ref    string `json:"ref"`
+	} `json:"revisions"`
+}
+
+func (g *Gerrit) Fetch(ref Ref) (*Change, error) {
+	if g.BaseURL == "" {
+		return nil, fmt.Errorf("no Gerrit server: set GERRIT_URL or pass a change URL")
+	}
+	data, err := g.get(fmt.Sprintf("/changes/%s?o=CURRENT_REVISION&o=DETAILED_ACCOUNTS", g.changeID(ref)))
+	if err != nil {
+		return nil, fmt.Errorf("fetching change %s: %w", ref.ID, err)
+	}
+	var ch gerritChange
+	if err := json.Unmarshal(data, &ch); err != nil {
+		return nil, fmt.Errorf("decoding change: %w", err)
+	}
+	rev, ok := ch.Revisions[ch.CurrentRevision]
+	if !ok {
+		return nil, fmt.Errorf("change %s has no current revision", ref.ID)
+	}
+
+	ref = Ref{Repo: ch.Project, ID: strconv.Itoa(ch.Number)}
+	patch, err := g.get(fmt.Sprintf("/changes/%s/revisions/%s/patch", g.changeID(ref), ch.CurrentRevision))
+	if err != nil {
+		return nil, fmt.Errorf("fetching patch for change %s: %w", ref.ID, err)
+	}
+	raw, err := base64.StdEncoding.DecodeString(string(bytes.TrimSpace(patch)))
+	if err != nil {
+		return nil, fmt.Errorf("decoding patch: %w", err)
+	}
+
+	author := ch.Owner.Name
+	if author == "" {
+		author = ch.Owner.Username
+	}
+	host := ""
+	if u, err := url.Parse(g.BaseURL); err == nil {
+		host = u.Hostname()
+	}
+	return &Change{
+		Ref:      ref,
+		Label:    fmt.Sprintf("Change %d/%d", ch.Number, rev.Number),
+		Title:    ch.Subject,
+		Author:   author,
+		Source:   ch.Topic,
+		Target:   ch.Branch,
+		URL:      fmt.Sprintf("%s/c/%s/+/%d", g.BaseURL, ch.Project, ch.Number),
+		HeadSHA:  ch.CurrentRevision,
+		FetchRef: rev.Ref,
+		Host:     host,
+		Diff:     string(raw),
+		Revision: ch.CurrentRevision,
+	}, nil
+}
+
+// PostReview posts the body as a change message and the inline comments as
+// robot comments, so Gerrit shows them apart from human review. approve and
+// request-changes vote Code-Review +1 and -1.
+func (g *Gerrit) PostReview(c *Change, r Review) (string, error) {
+	if g.Username == "" {
+		return "", fmt.Errorf("posting to Gerrit needs GERRIT_USERNAME and GERRIT_PASSWORD")
+	}
+	ds, err := diff.Parse(c.Diff)
+	if err != nil {
+		return "", fmt.Errorf("parsing diff: %w", err)
+	}
+
+	type robotComment struct {
+		RobotID    string `json:"robot_id"`
+		RobotRunID string `json:"robot_run_id"`
+		Line       int    `json:"line"`
+		Side       string `json:"side,omitempty"`
+		Message    string `json:"message"`
+	}
+	input := struct {
+		Message       string                    `json:"message"`
+		Tag           string                    `json:"tag"`
+		Labels        map[string]int            `json:"labels,omitempty"`
+		RobotComments map[string][]robotComment `json:"robot_comments,omitempty"`
+	}{
+		Tag:           "autogenerated:agrev",
+		RobotComments: make(map[string][]robotComment),
+	}
+
+	var outside []Comment
+	for _, cm := range r.Comments {
+		if findFile(ds, cm.Path) == nil || cm.Line <= 0 {
+			outside = append(outside, cm)
+			continue
+		}
+		rc := robotComment{RobotID: "agrev", RobotRunID: c.Revision, Line: cm.Line, Message: cm.Body}
+		if cm.Old {
+			rc.Side = "PARENT"
+		}
+		input.RobotComments[cm.Path] = append(input.RobotComments[cm.Path], rc)
+	}
+	input.Message = r.Body + formatOutside(outside)
+
+	switch r.Event {
+	case EventApprove:
+		input.Labels = map[string]int{"Code-Review": 1}
+	case EventRequestChanges:
+		input.Labels = map[string]int{"Code-Review": -1}
+	}
+
+	u := fmt.Sprintf("%s/a/changes/%s/revisions/%s/review", g.BaseURL, g.changeID(c.Ref), c.Revision)
+	if _, err := request(http.MethodPost, u, input, g.auth); err != nil {
+		return "", fmt.Errorf("posting review on change %s: %w", c.Ref.ID, err)
+	}
+	return c.URL, nil
+}
+
+// changeID formats a change for API paths, qualified by project when known
+// since bare numbers can be ambiguous across projects.
+func (g *Gerrit) changeID(ref Ref) string {
+	if ref.Repo != "" && isChangeNumber(ref.ID) {
+		return url.PathEscape(ref.Repo + "~" + ref.ID)
+	}
+	return url.PathEscape(ref.ID)
+}
+
+// get reads from the REST API, authenticated when credentials are set, and
+// strips the )]}' line Gerrit prefixes JSON responses with.
+func (g *Gerrit) get(path string) ([]byte, error) {
+	base := g.BaseURL
+	if g.Username != "" {
+		base += "/a"
+	}
+	data, err := request(http.MethodGet, base+path, nil, g.auth)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.TrimPrefix(data, []byte(")]}'\n")), nil
+}
+
+func (g *Gerrit) auth(req *http.Request) {
+	if g.Username != "" {
+		req.SetBasicAuth(g.Username, g.Password)
+	}
+}
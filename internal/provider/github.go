@@ -0,0 +1,134 @@
+package provider
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+
+	"github.com/aezell/agrev/internal/diff"
+	"github.com/aezell/agrev/internal/github"
+)
+
+// GitHub reviews GitHub pull requests.
+type GitHub struct {
+	Client *github.Client
+}
+
+// NewGitHub returns a GitHub provider using the API token from the
+// environment or the gh CLI.
+func NewGitHub() *GitHub {
+	return &GitHub{Client: github.NewClient()}
+}
+
+func (g *GitHub) Name() string { return "github" }
+
+func (g *GitHub) ParseRef(arg, repo string) (Ref, error) {
+	r, err := github.ParseRef(arg, repo)
+	if err != nil {
+		return Ref{}, err
+	}
+	return Ref{Repo: r.Owner + "/" + r.Repo, ID: strconv.Itoa(r.Number)}, nil
+}
+
+func (g *GitHub) Fetch(ref Ref) (*Change, error) {
+	r, err := g.ref(ref)
+	if err != nil {
+		return nil, err
+	}
+	pr, err := g.Client.PullRequest(r)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := g.Client.Diff(r)
+	if err != nil {
+		return nil, err
+	}
+
+	host := "github.com"
+	if u, err := url.Parse(pr.HTMLURL); err == nil && u.Host != "" {
+		host = u.Hostname()
+	}
+	return &Change{
+		Ref:      ref,
+		Label:    "PR #" + ref.ID,
+		Title:    pr.Title,
+		Author:   pr.User.Login,
+		Source:   pr.Head.Ref,
+		Target:   pr.Base.Ref,
+		URL:      pr.HTMLURL,
+		HeadSHA:  pr.Head.SHA,
+		FetchRef: fmt.Sprintf("refs/pull/%d/head", r.Number),
+		Host:     host,
+		Diff:     raw,
+	}, nil
+}
+
+// PostReview submits one GitHub review. Inline comments are anchored by
+// diff position; any that fall outside the diff are added to the body.
+func (g *GitHub) PostReview(c *Change, r Review) (string, error) {
+	ref, err := g.ref(c.Ref)
+	if err != nil {
+		return "", err
+	}
+	ds, err := diff.Parse(c.Diff)
+	if err != nil {
+		return "", fmt.Errorf("parsing diff: %w", err)
+	}
+
+	review := github.Review{CommitID: c.HeadSHA, Body: r.Body}
+	switch r.Event {
+	case EventApprove:
+		review.Event = "APPROVE"
+	case EventRequestChanges:
+		review.Event = "REQUEST_CHANGES"
+	default:
+		review.Event = "COMMENT"
+	}
+
+	var outside []Comment
+	for _, cm := range r.Comments {
+		pos, ok := 0, false
+		if f := findFile(ds, cm.Path); f != nil {
+			pos, ok = f.Position(cm.Line, cm.Old)
+		}
+		if !ok {
+			outside = append(outside, cm)
+			continue
+		}
+		review.Comments = append(review.Comments, github.ReviewComment{
+			Path:     cm.Path,
+			Position: pos,
+			Body:     cm.Body,
+		})
+	}
+	review.Body += formatOutside(outside)
+
+	return g.Client.CreateReview(ref, review)
+}
+
+func (g *GitHub) ref(ref Ref) (github.Ref, error) {
+	return github.ParseRef(ref.ID, ref.Repo)
+}
+
+// findFile finds a diff file by its old or new path.
+func findFile(ds *diff.DiffSet, path string) *diff.File {
+	for _, f := range ds.Files {
+		if f.NewName == path || f.OldName == path {
+			return f
+		}
+	}
+	return nil
+}
+
+// formatOutside renders comments that could not be placed inline, for
+// appending to a review body.
+func formatOutside(comments []Comment) string {
+	if len(comments) == 0 {
+		return ""
+	}
+	s := "\n### Comments outside the diff\n\n"
+	for _, c := range comments {
+		s += fmt.Sprintf("- `%s:%d` — %s\n", c.Path, c.Line, c.Body)
+	}
+	return s
+}
@@ -0,0 +1,272 @@
+// Package provider abstracts the code forges agrev reviews changes from:
+// GitHub pull requests, Bitbucket Cloud pull requests, and Gerrit changes.
+package provider
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Provider fetches changes from a forge and posts reviews back to it.
+type Provider interface {
+	// Name is the provider's key in Providers.
+	Name() string
+
+	// ParseRef parses a change given as a number, ID, or web URL. Bare
+	// numbers are looked up in repo.
+	ParseRef(arg, repo string) (Ref, error)
+
+	// Fetch loads a change's metadata and diff.
+	Fetch(ref Ref) (*Change, error)
+
+	// PostReview publishes a review of the change and returns its URL, if
+	// the forge provides one.
+	PostReview(c *Change, r Review) (string, error)
+}
+
+// Providers maps provider names to constructors. Adding a forge means
+// implementing Provider and registering it here.
+var Providers = map[string]func() Provider{
+	"github":    func() Provider { return NewGitHub() },
+	"bitbucket": func() Provider { return NewBitbucket() },
+	"gerrit":    func() Provider { return NewGerrit() },
+}
+
+// Names returns the registered provider names, sorted.
+func Names() []string {
+	var names []string
+	for name := range Providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Get returns the named provider.
+func Get(name string) (Provider, error) {
+	newProvider, ok := Providers[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown provider %q (want %s)", name, strings.Join(Names(), ", "))
+	}
+	return newProvider(), nil
+}
+
+// Detect picks the provider for a change argument: an explicit name wins,
+// then the host of a URL argument, then the host of the local remote.
+// GitHub is the default.
+func Detect(name, arg, remoteURL string) (Provider, error) {
+	if name != "" {
+		return Get(name)
+	}
+	if strings.Contains(arg, "://") {
+		if p := fromURL(arg); p != "" {
+			return Get(p)
+		}
+	}
+	if remoteURL != "" {
+		if p := fromURL(remoteURL); p != "" {
+			return Get(p)
+		}
+	}
+	return Get("github")
+}
+
+// fromURL guesses the provider from a change or remote URL.
+func fromURL(raw string) string {
+	host := remoteHost(raw)
+	switch {
+	case host == "bitbucket.org":
+		return "bitbucket"
+	case host == "github.com":
+		return "github"
+	case strings.Contains(raw, "/+/") || strings.Contains(host, "gerrit") || strings.Contains(host, "review"):
+		return "gerrit"
+	}
+	return ""
+}
+
+// Ref identifies a change on a forge.
+type Ref struct {
+	// Repo is "owner/name" (GitHub), "workspace/repo" (Bitbucket), or the
+	// project name (Gerrit).
+	Repo string
+
+	// ID is the pull request number, or a Gerrit change number or Change-Id.
+	ID string
+}
+
+// Change is a pull request or change fetched for review.
+type Change struct {
+	Ref    Ref
+	Label  string // short name for the status bar, e.g. "PR #42"
+	Title  string
+	Author string
+	Source string // branch being merged
+	Target string // branch merged into
+	URL    string
+
+	// HeadSHA is the commit under review; reviews are anchored to it.
+	HeadSHA string
+
+	// FetchRef is a ref on the forge the head commit can be fetched from,
+	// e.g. "refs/pull/42/head", or empty if there is none.
+	FetchRef string
+
+	// Host is the forge's git host, used to find a matching local remote.
+	Host string
+
+	// Diff is the change's unified diff against its target.
+	Diff string
+
+	// Revision is provider-specific state needed to post a review, e.g. the
+	// Gerrit patch set.
+	Revision string
+}
+
+// String returns a readable name for the change, e.g. "octo/cat#42".
+func (c *Change) String() string {
+	return c.Ref.Repo + "#" + c.Ref.ID
+}
+
+// Review is a forge-neutral review: a summary body plus inline comments.
+type Review struct {
+	Body     string    `json:"body"`
+	Event    Event     `json:"event"`
+	Comments []Comment `json:"comments,omitempty"`
+}
+
+// Event is the verdict a review carries.
+type Event string
+
+const (
+	EventComment        Event = "comment"
+	EventApprove        Event = "approve"
+	EventRequestChanges Event = "request-changes"
+)
+
+// ParseEvent validates an event name.
+func ParseEvent(name string) (Event, error) {
+	switch e := Event(name); e {
+	case EventComment, EventApprove, EventRequestChanges:
+		return e, nil
+	}
+	return "", fmt.Errorf("unknown review event %q (want comment, approve, or request-changes)", name)
+}
+
+// Comment is an inline comment on a line of a changed file.
+type Comment struct {
+	Path string `json:"path"`
+	Line int    `json:"line"`
+	Old  bool   `json:"old,omitempty"` // Line is in the old file, i.e. on a deleted line
+	Body string `json:"body"`
+}
+
+// MatchesRemote reports whether a git remote URL points at the change's
+// repository.
+func (c *Change) MatchesRemote(remoteURL string) bool {
+	if c.Host == "" || !strings.EqualFold(remoteHost(remoteURL), c.Host) {
+		return false
+	}
+	path := strings.ToLower(strings.TrimSuffix(remotePath(remoteURL), ".git"))
+	repo := strings.ToLower(c.Ref.Repo)
+	// Gerrit serves authenticated clones under /a/
+	return path == repo || path == "a/"+repo
+}
+
+// RepoFromRemote extracts the repository path from a remote URL, e.g.
+// "owner/name" from git@github.com:owner/name.git.
+func RepoFromRemote(remoteURL string) (string, bool) {
+	path := strings.TrimPrefix(strings.TrimSuffix(remotePath(remoteURL), ".git"), "a/")
+	if !strings.Contains(path, "/") && fromURL(remoteURL) != "gerrit" {
+		return "", false
+	}
+	return path, path != ""
+}
+
+// remoteHost returns the host of an https, ssh, or scp-like git URL.
+func remoteHost(raw string) string {
+	raw = strings.TrimSpace(raw)
+	if strings.Contains(raw, "://") {
+		u, err := url.Parse(raw)
+		if err != nil {
+			return ""
+		}
+		return u.Hostname()
+	}
+	host, _, ok := strings.Cut(raw, ":")
+	if !ok {
+		return ""
+	}
+	if _, h, ok := strings.Cut(host, "@"); ok {
+		host = h
+	}
+	return host
+}
+
+// remotePath returns the repository path of a git URL without slashes
+// around it.
+func remotePath(raw string) string {
+	raw = strings.TrimSpace(raw)
+	if strings.Contains(raw, "://") {
+		u, err := url.Parse(raw)
+		if err != nil {
+			return ""
+		}
+		return strings.Trim(u.Path, "/")
+	}
+	_, path, _ := strings.Cut(raw, ":")
+	return strings.Trim(path, "/")
+}
+
+// httpClient is shared by the providers.
+var httpClient = &http.Client{Timeout: 30 * time.Second}
+
+// request sends an API request, JSON-encoding body if it is not nil, and
+// returns the response body. auth adds credentials to the request.
+func request(method, u string, body any, auth func(*http.Request)) ([]byte, error) {
+	var r io.Reader
+	if body != nil {
+		payload, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		r = bytes.NewReader(payload)
+	}
+	req, err := http.NewRequest(method, u, r)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	auth(req)
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg := strings.TrimSpace(string(data))
+		if len(msg) > 200 {
+			msg = msg[:200] + "..."
+		}
+		if msg == "" {
+			return nil, fmt.Errorf("%s %s: %s", method, u, resp.Status)
+		}
+		return nil, fmt.Errorf("%s %s: %s: %s", method, u, resp.Status, msg)
+	}
+	return data, nil
+}
@@ -0,0 +1,231 @@
+package provider
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/aezell/agrev/internal/github"
+)
+
+const testDiff = `diff --git a/main.go b/main.go
+index abc1234..def5678 100644
+--- a/main.go
++++ b/main.go
+@@ -1,3 +1,3 @@
+ package main
+-var a = 1
++var a = 2
+ func main() {}
+`
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		name, arg, remote, want string
+	}{
+		{"", "https://github.com/octo/cat/pull/1", "", "github"},
+		{"", "https://bitbucket.org/team/app/pull-requests/7", "", "bitbucket"},
+		{"", "https://review.example.com/c/proj/+/4711", "", "gerrit"},
+		{"", "12", "git@bitbucket.org:team/app.git", "bitbucket"},
+		{"", "12", "ssh://user@gerrit.example.com:29418/proj", "gerrit"},
+		{"", "12", "", "github"},
+		{"gerrit", "12", "git@github.com:octo/cat.git", "gerrit"},
+	}
+	for _, tt := range tests {
+		p, err := Detect(tt.name, tt.arg, tt.remote)
+		if err != nil {
+			t.Errorf("Detect(%q, %q, %q) failed: %v", tt.name, tt.arg, tt.remote, err)
+			continue
+		}
+		if p.Name() != tt.want {
+			t.Errorf("Detect(%q, %q, %q) = %s, want %s", tt.name, tt.arg, tt.remote, p.Name(), tt.want)
+		}
+	}
+	if _, err := Detect("gitlab", "1", ""); err == nil {
+		t.Error("expected error for unknown provider")
+	}
+}
+
+func TestRemotes(t *testing.T) {
+	for remote, want := range map[string]string{
+		"https://github.com/aezell/agrev.git":     "aezell/agrev",
+		"git@github.com:aezell/agrev.git":         "aezell/agrev",
+		"ssh://git@bitbucket.org/team/app.git":    "team/app",
+		"https://review.example.com/a/tools/core": "tools/core",
+	} {
+		got, ok := RepoFromRemote(remote)
+		if !ok || got != want {
+			t.Errorf("RepoFromRemote(%q) = %q, %v; want %q", remote, got, ok, want)
+		}
+	}
+	if _, ok := RepoFromRemote("/srv/git/agrev"); ok {
+		t.Error("expected local path to have no repository")
+	}
+
+	c := &Change{Ref: Ref{Repo: "tools/core"}, Host: "review.example.com"}
+	if !c.MatchesRemote("https://review.example.com/a/tools/core") {
+		t.Error("expected authenticated Gerrit remote to match")
+	}
+	if c.MatchesRemote("https://github.com/tools/core.git") {
+		t.Error("expected remote on another host not to match")
+	}
+}
+
+func TestGitHubPostReview(t *testing.T) {
+	var got github.Review
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&got)
+		w.Write([]byte(`{"html_url":"https://github.com/octo/cat/pull/1"}`))
+	}))
+	defer srv.Close()
+
+	g := &GitHub{Client: &github.Client{BaseURL: srv.URL, HTTP: srv.Client()}}
+	c := &Change{Ref: Ref{Repo: "octo/cat", ID: "1"}, HeadSHA: "abc", Diff: testDiff}
+	_, err := g.PostReview(c, Review{Body: "summary", Event: EventRequestChanges, Comments: []Comment{
+		{Path: "main.go", Line: 2, Old: true, Body: "removed"},
+		{Path: "main.go", Line: 2, Body: "added"},
+		{Path: "main.go", Line: 40, Body: "far away"},
+	}})
+	if err != nil {
+		t.Fatalf("PostReview failed: %v", err)
+	}
+	if got.Event != "REQUEST_CHANGES" || got.CommitID != "abc" {
+		t.Errorf("unexpected review: %+v", got)
+	}
+	if len(got.Comments) != 2 || got.Comments[0].Position != 2 || got.Comments[1].Position != 3 {
+		t.Errorf("unexpected positions: %+v", got.Comments)
+	}
+	if !strings.Contains(got.Body, "main.go:40") {
+		t.Errorf("expected comment outside the diff in body, got %q", got.Body)
+	}
+}
+
+func TestBitbucket(t *testing.T) {
+	b := &Bitbucket{}
+	ref, err := b.ParseRef("https://bitbucket.org/team/app/pull-requests/7/overview", "")
+	if err != nil || ref != (Ref{Repo: "team/app", ID: "7"}) {
+		t.Fatalf("ParseRef = %+v, %v", ref, err)
+	}
+	if _, err := b.ParseRef("7", ""); err == nil {
+		t.Error("expected bare number without repo to fail")
+	}
+
+	var posted []map[string]any
+	var approved bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer tok" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/repositories/team/app/pullrequests/7":
+			w.Write([]byte(`{"id":7,"title":"Fix","author":{"display_name":"Ada"},
+				"source":{"branch":{"name":"fix"},"commit":{"hash":"abc123"},"repository":{"full_name":"team/app"}},
+				"destination":{"branch":{"name":"main"}},"links":{"html":{"href":"https://bitbucket.org/team/app/pull-requests/7"}}}`))
+		case "/repositories/team/app/pullrequests/7/diff":
+			w.Write([]byte(testDiff))
+		case "/repositories/team/app/pullrequests/7/comments":
+			var body map[string]any
+			json.NewDecoder(r.Body).Decode(&body)
+			posted = append(posted, body)
+			w.WriteHeader(http.StatusCreated)
+		case "/repositories/team/app/pullrequests/7/approve":
+			approved = true
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	b = &Bitbucket{BaseURL: srv.URL, Token: "tok"}
+	c, err := b.Fetch(ref)
+	if err != nil {
+		t.Fatalf("Fetch failed: %v", err)
+	}
+	if c.Title != "Fix" || c.HeadSHA != "abc123" || c.FetchRef != "refs/heads/fix" || c.Diff != testDiff {
+		t.Errorf("unexpected change: %+v", c)
+	}
+
+	_, err = b.PostReview(c, Review{Body: "summary", Event: EventApprove, Comments: []Comment{
+		{Path: "main.go", Line: 2, Old: true, Body: "removed"},
+	}})
+	if err != nil {
+		t.Fatalf("PostReview failed: %v", err)
+	}
+	if len(posted) != 2 || !approved {
+		t.Fatalf("expected inline comment, summary, and approval; got %v, approved=%v", posted, approved)
+	}
+	inline, _ := posted[0]["inline"].(map[string]any)
+	if inline["path"] != "main.go" || inline["from"] != float64(2) {
+		t.Errorf("unexpected inline anchor: %v", posted[0])
+	}
+}
+
+func TestGerrit(t *testing.T) {
+	g := &Gerrit{}
+	ref, err := g.ParseRef("https://review.example.com/c/tools/core/+/4711/2", "")
+	if err != nil || ref != (Ref{Repo: "tools/core", ID: "4711"}) {
+		t.Fatalf("ParseRef = %+v, %v", ref, err)
+	}
+	if g.BaseURL != "https://review.example.com" {
+		t.Errorf("expected base URL from change URL, got %q", g.BaseURL)
+	}
+	if _, err := g.ParseRef("I0123456789abcdef0123456789abcdef01234567", ""); err != nil {
+		t.Errorf("expected Change-Id to parse: %v", err)
+	}
+	if _, err := g.ParseRef("fix-bug", ""); err == nil {
+		t.Error("expected invalid change to fail")
+	}
+
+	var review map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, _, _ := r.BasicAuth()
+		if user != "bot" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.EscapedPath() {
+		case "/a/changes/tools%2Fcore~4711":
+			io.WriteString(w, ")]}'\n"+`{"project":"tools/core","branch":"main","subject":"Fix","_number":4711,
+				"current_revision":"abc123","owner":{"name":"Ada"},
+				"revisions":{"abc123":{"_number":2,"ref":"refs/changes/11/4711/2"}}}`)
+		case "/a/changes/tools%2Fcore~4711/revisions/abc123/patch":
+			io.WriteString(w, base64.StdEncoding.EncodeToString([]byte(testDiff)))
+		case "/a/changes/tools%2Fcore~4711/revisions/abc123/review":
+			json.NewDecoder(r.Body).Decode(&review)
+			io.WriteString(w, ")]}'\n{}")
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	g = &Gerrit{BaseURL: srv.URL, Username: "bot", Password: "secret"}
+	c, err := g.Fetch(ref)
+	if err != nil {
+		t.Fatalf("Fetch failed: %v", err)
+	}
+	if c.Label != "Change 4711/2" || c.FetchRef != "refs/changes/11/4711/2" || c.Diff != testDiff {
+		t.Errorf("unexpected change: %+v", c)
+	}
+
+	_, err = g.PostReview(c, Review{Body: "summary", Event: EventRequestChanges, Comments: []Comment{
+		{Path: "main.go", Line: 2, Body: "added"},
+	}})
+	if err != nil {
+		t.Fatalf("PostReview failed: %v", err)
+	}
+	robot, _ := review["robot_comments"].(map[string]any)
+	comments, _ := robot["main.go"].([]any)
+	if len(comments) != 1 {
+		t.Fatalf("expected one robot comment, got %v", review)
+	}
+	labels, _ := review["labels"].(map[string]any)
+	if labels["Code-Review"] != float64(-1) {
+		t.Errorf("expected Code-Review -1, got %v", review["labels"])
+	}
+}
@@ -0,0 +1,277 @@
+package report
+
+import (
+	"fmt"
+	"html"
+	"strings"
+	"time"
+
+	"github.com/bluekeyes/go-gitdiff/gitdiff"
+	"github.com/aezell/agrev/internal/analysis"
+	"github.com/aezell/agrev/internal/diff"
+)
+
+// highlightStyle is the chroma style of the HTML report's diffs, matching
+// its colors.
+const highlightStyle = "dracula"
+
+// maxStepDetail caps the detail shown for each trace step, so a long
+// command output doesn't swamp the page.
+const maxStepDetail = 4000
+
+// lineAnchor is the id of a file's line in the new version, which findings
+// link to.
+func lineAnchor(file, line int) string {
+	return fmt.Sprintf("file-%d-L%d", file, line)
+}
+
+// findingLink links a finding's location to its line in the file diffs,
+// or to the file when the line isn't shown.
+func (r *Report) findingLink(f analysis.Finding) string {
+	for i, file := range r.Diff.Files {
+		if file.Name() != f.File {
+			continue
+		}
+		if f.Line > 0 && shownLine(file, f.Line) {
+			return "#" + lineAnchor(i, f.Line)
+		}
+		return fmt.Sprintf("#file-%d", i)
+	}
+	return ""
+}
+
+// shownLine reports whether line of the new version is in one of the
+// file's hunks.
+func shownLine(f *diff.File, line int) bool {
+	for _, frag := range f.Fragments {
+		start := int(frag.NewPosition)
+		if line >= start && line < start+int(frag.NewLines) {
+			return true
+		}
+	}
+	return false
+}
+
+// writeFiles writes each file's diff, syntax highlighted, with the findings
+// and trace links on its lines under them. Files with findings start
+// expanded.
+func (r *Report) writeFiles(b *strings.Builder) {
+	esc := html.EscapeString
+	byFile := make(map[string][]analysis.Finding)
+	for _, f := range r.findings() {
+		byFile[f.File] = append(byFile[f.File], f)
+	}
+	// The trace steps that wrote each file's lines
+	stepsOn := make(map[string]map[int][]int)
+	for _, a := range r.annotations() {
+		if a.Step == 0 {
+			continue
+		}
+		if stepsOn[a.File] == nil {
+			stepsOn[a.File] = make(map[int][]int)
+		}
+		stepsOn[a.File][a.Range.Start] = append(stepsOn[a.File][a.Range.Start], a.Step)
+	}
+
+	for i, f := range r.Diff.Files {
+		findings := byFile[f.Name()]
+		open := ""
+		if len(findings) > 0 {
+			open = " open"
+		}
+		fmt.Fprintf(b, "<details class=\"file-diff\" id=\"file-%d\" data-name=\"%s\"%s>\n<summary>", i, esc(f.Name()), open)
+		if r.Review != nil {
+			fmt.Fprintf(b, "<span class=\"%s\">%s</span> ", r.Review.Decision(i), r.Review.Decision(i))
+		}
+		fmt.Fprintf(b, "<code class=\"file\">%s</code> <span class=\"approved\">+%d</span> <span class=\"rejected\">-%d</span>", esc(f.Name()), f.AddedLines, f.DeletedLines)
+		if len(findings) > 0 {
+			fmt.Fprintf(b, " <span class=\"risk-%s\">%d finding(s)</span>", findings[0].Risk, len(findings))
+		}
+		b.WriteString("</summary>\n")
+
+		// Findings not on a line shown go above the diff
+		var above []analysis.Finding
+		onLine := make(map[int][]analysis.Finding)
+		for _, fd := range findings {
+			if fd.Line > 0 && shownLine(f, fd.Line) {
+				onLine[fd.Line] = append(onLine[fd.Line], fd)
+			} else {
+				above = append(above, fd)
+			}
+		}
+		if len(above) > 0 {
+			b.WriteString("<ul class=\"inline-findings\">\n")
+			for _, fd := range above {
+				fmt.Fprintf(b, "<li>%s</li>\n", findingHTML(fd))
+			}
+			b.WriteString("</ul>\n")
+		}
+
+		switch {
+		case f.IsBinary:
+			b.WriteString("<p class=\"meta\">Binary file.</p>\n")
+		case len(f.Fragments) == 0:
+			b.WriteString("<p class=\"meta\">No content changes.</p>\n")
+		default:
+			b.WriteString("<table class=\"diff\">\n")
+			r.writeHunks(b, i, f, onLine, stepsOn[f.Name()])
+			b.WriteString("</table>\n")
+		}
+		b.WriteString("</details>\n")
+	}
+}
+
+// writeHunks writes the rows of file i's hunks. Lines of the new version
+// get an anchor, and are followed by the findings on them and links to the
+// trace steps that wrote them.
+func (r *Report) writeHunks(b *strings.Builder, i int, f *diff.File, onLine map[int][]analysis.Finding, stepsOn map[int][]int) {
+	esc := html.EscapeString
+	type row struct {
+		op             gitdiff.LineOp
+		oldNum, newNum int
+		text           string
+	}
+	for _, frag := range f.Fragments {
+		fmt.Fprintf(b, "<tr class=\"hunk\"><td colspan=\"3\">%s</td></tr>\n", esc(strings.TrimSpace(frag.Header())))
+
+		oldNum, newNum := int(frag.OldPosition), int(frag.NewPosition)
+		var rows []row
+		text := make([]string, 0, len(frag.Lines))
+		for _, l := range frag.Lines {
+			rw := row{op: l.Op, text: diff.DisplayText(strings.TrimRight(l.Line, "\r\n"))}
+			switch l.Op {
+			case gitdiff.OpContext:
+				rw.oldNum, rw.newNum = oldNum, newNum
+				oldNum++
+				newNum++
+			case gitdiff.OpDelete:
+				rw.oldNum = oldNum
+				oldNum++
+			case gitdiff.OpAdd:
+				rw.newNum = newNum
+				newNum++
+			}
+			rows = append(rows, rw)
+			text = append(text, rw.text)
+		}
+
+		highlighted := diff.HighlightLinesStyle(f.Name(), highlightStyle, text)
+		for n, rw := range rows {
+			class, mark := "ctx", " "
+			switch rw.op {
+			case gitdiff.OpAdd:
+				class, mark = "add", "+"
+			case gitdiff.OpDelete:
+				class, mark = "del", "-"
+			}
+			id := ""
+			if rw.newNum > 0 {
+				id = fmt.Sprintf(" id=\"%s\"", lineAnchor(i, rw.newNum))
+			}
+			fmt.Fprintf(b, "<tr class=\"%s\"%s><td class=\"num\">%s</td><td class=\"num\">%s</td><td class=\"code\">%s",
+				class, id, lineNum(rw.oldNum), lineNum(rw.newNum), mark)
+			if n < len(highlighted) {
+				for _, t := range highlighted[n].Tokens {
+					if t.Color != "" {
+						fmt.Fprintf(b, "<span style=\"color:%s\">%s</span>", t.Color, esc(t.Text))
+					} else {
+						b.WriteString(esc(t.Text))
+					}
+				}
+			} else {
+				b.WriteString(esc(rw.text))
+			}
+			b.WriteString("</td></tr>\n")
+
+			if rw.newNum == 0 {
+				continue
+			}
+			for _, fd := range onLine[rw.newNum] {
+				fmt.Fprintf(b, "<tr class=\"finding\"><td colspan=\"3\">%s</td></tr>\n", findingHTML(fd))
+			}
+			if steps := stepsOn[rw.newNum]; len(steps) > 0 {
+				b.WriteString("<tr class=\"trace-link\"><td colspan=\"3\">from ")
+				for k, s := range steps {
+					if k > 0 {
+						b.WriteString(", ")
+					}
+					fmt.Fprintf(b, "<a href=\"#step-%d\">step %d</a>", s, s)
+				}
+				b.WriteString("</td></tr>\n")
+			}
+		}
+	}
+}
+
+func lineNum(n int) string {
+	if n == 0 {
+		return ""
+	}
+	return fmt.Sprint(n)
+}
+
+// findingHTML describes a finding on a line of the file diffs.
+func findingHTML(f analysis.Finding) string {
+	esc := html.EscapeString
+	s := fmt.Sprintf("<span class=\"risk-%s\">%s</span> <span class=\"pass\">%s</span> ", f.Risk, f.Risk, esc(f.Pass))
+	if f.Rule != "" {
+		rule := esc(f.Rule)
+		if url := f.DocsURL(); url != "" {
+			rule = fmt.Sprintf("<a href=\"%s\">%s</a>", esc(url), rule)
+		}
+		s += rule + " "
+	}
+	return s + esc(f.Message)
+}
+
+// writeTimeline writes the trace's steps in order, each expandable to its
+// detail: the reasoning, the edit, or the command and its output.
+func (r *Report) writeTimeline(b *strings.Builder) {
+	esc := html.EscapeString
+	b.WriteString("<ol class=\"timeline\">\n")
+	for n, s := range r.Trace.Steps {
+		head := fmt.Sprintf("<span class=\"step-type step-%s\">%s</span> %s", s.Type, s.Type, esc(s.Summary))
+		if !s.Timestamp.IsZero() {
+			head = fmt.Sprintf("<span class=\"meta\">%s</span> ", s.Timestamp.Format(time.TimeOnly)) + head
+		}
+		if s.FilePath != "" && !strings.Contains(s.Summary, s.FilePath) {
+			head += fmt.Sprintf(" <code class=\"file\">%s</code>", esc(s.FilePath))
+		}
+
+		var detail strings.Builder
+		if s.Detail != "" && s.Detail != s.Summary {
+			detail.WriteString(s.Detail + "\n")
+		}
+		if s.OldString != "" {
+			for _, l := range strings.Split(strings.TrimSuffix(s.OldString, "\n"), "\n") {
+				detail.WriteString("-" + l + "\n")
+			}
+		}
+		if s.NewString != "" {
+			for _, l := range strings.Split(strings.TrimSuffix(s.NewString, "\n"), "\n") {
+				detail.WriteString("+" + l + "\n")
+			}
+		}
+		if s.Command != "" {
+			fmt.Fprintf(&detail, "$ %s\n", s.Command)
+			if s.Output != "" {
+				detail.WriteString(s.Output + "\n")
+			}
+			if s.ExitCode != 0 {
+				fmt.Fprintf(&detail, "(exit %d)\n", s.ExitCode)
+			}
+		}
+
+		fmt.Fprintf(b, "<li id=\"step-%d\">", n+1)
+		if text := strings.TrimRight(detail.String(), "\n"); text != "" {
+			if len(text) > maxStepDetail {
+				text = strings.ToValidUTF8(text[:maxStepDetail], "") + "\n…"
+			}
+			fmt.Fprintf(b, "<details><summary>%s</summary><pre>%s</pre></details>", head, esc(text))
+		} else {
+			b.WriteString(head)
+		}
+		b.WriteString("</li>\n")
+	}
+	b.WriteString("</ol>\n")
+}
@@ -0,0 +1,562 @@
+// Package report renders a review report that brings together the agent
+// trace, the analysis findings, and the decisions and comments of a review,
+// for attaching to a pull request or keeping as an audit record.
+package report
+
+import (
+	"fmt"
+	"html"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/aezell/agrev/internal/analysis"
+	"github.com/aezell/agrev/internal/annotate"
+	"github.com/aezell/agrev/internal/diff"
+	"github.com/aezell/agrev/internal/model"
+	"github.com/aezell/agrev/internal/trace"
+	"github.com/aezell/agrev/internal/tui"
+)
+
+// Report is what a review report is made from. Trace, Results and Review
+// may be nil; their sections then say so.
+type Report struct {
+	Diff      *diff.DiffSet
+	Trace     *trace.Trace
+	Results   *analysis.Results
+	Review    *tui.ReviewResult
+	Generated time.Time
+}
+
+// findings returns the findings, highest risk first.
+func (r *Report) findings() []analysis.Finding {
+	if r.Results == nil {
+		return nil
+	}
+	findings := slices.Clone(r.Results.Findings)
+	slices.SortStableFunc(findings, func(a, b analysis.Finding) int { return int(b.Risk) - int(a.Risk) })
+	return findings
+}
+
+// decision describes file i's decision, with a tally of its hunks when it
+// was partly approved.
+func (r *Report) decision(i int) string {
+	d := r.Review.Decision(i)
+	if d != model.DecisionPartial {
+		s := d.String()
+		if len(r.Review.Decisions.Conditions[i]) > 0 {
+			s += " with follow-ups"
+		}
+		if r.Review.Decisions.Bulk[i] {
+			s += " in bulk"
+		}
+		return s
+	}
+	n, approved := len(r.Review.Files[i].Fragments), 0
+	for h := range n {
+		if r.Review.Decisions.Hunk(i, h) == model.DecisionApproved {
+			approved++
+		}
+	}
+	return fmt.Sprintf("partial: %d/%d hunks approved", approved, n)
+}
+
+// tally counts the review's files by decision, e.g. "2 approved, 1
+// rejected, 0 pending".
+func (r *Report) tally() string {
+	counts := make(map[model.ReviewDecision]int)
+	for i := range r.Review.Files {
+		counts[r.Review.Decision(i)]++
+	}
+	s := fmt.Sprintf("%d approved, %d rejected", counts[model.DecisionApproved], counts[model.DecisionRejected])
+	if partial := counts[model.DecisionPartial]; partial > 0 {
+		s += fmt.Sprintf(", %d partly approved", partial)
+	}
+	return s + fmt.Sprintf(", %d pending", counts[model.DecisionPending])
+}
+
+// traceStats describes the trace's source, size and duration.
+func traceStats(t *trace.Trace) string {
+	s := fmt.Sprintf("%s, %d steps, %d file(s) touched", t.Source, len(t.Steps), len(t.FilesChanged))
+	if !t.StartTime.IsZero() && t.EndTime.After(t.StartTime) {
+		s += ", " + t.EndTime.Sub(t.StartTime).Round(time.Second).String()
+	}
+	return s
+}
+
+// annotated is a trace annotation with the file it is on.
+type annotated struct {
+	File string
+	model.Annotation
+}
+
+// annotations returns the trace's annotations on the diff's files, in
+// the diff's order.
+func (r *Report) annotations() []annotated {
+	if r.Trace == nil {
+		return nil
+	}
+	var out []annotated
+	for _, f := range r.Diff.Files {
+		for _, a := range annotate.File(f, r.Trace) {
+			out = append(out, annotated{File: f.Name(), Annotation: a})
+		}
+	}
+	return out
+}
+
+func (a annotated) location() string {
+	switch {
+	case a.Range.Start == 0:
+		return a.File
+	case a.Range.End > a.Range.Start:
+		return fmt.Sprintf("%s:%d-%d", a.File, a.Range.Start, a.Range.End)
+	default:
+		return fmt.Sprintf("%s:%d", a.File, a.Range.Start)
+	}
+}
+
+func location(f analysis.Finding) string {
+	if f.Line > 0 {
+		return fmt.Sprintf("%s:%d", f.File, f.Line)
+	}
+	return f.File
+}
+
+func commentLocation(c model.Comment) string {
+	loc := c.File
+	if c.Line > 0 {
+		loc = fmt.Sprintf("%s:%d", c.File, c.Line)
+	}
+	if c.Hunk > 0 {
+		loc += fmt.Sprintf(" (hunk %d)", c.Hunk)
+	}
+	return loc
+}
+
+// commentMeta names a comment's author and time, when known.
+func commentMeta(c model.Comment) string {
+	var parts []string
+	if c.Author != "" {
+		parts = append(parts, c.Author)
+	}
+	if !c.Time.IsZero() {
+		parts = append(parts, c.Time.Format("2006-01-02 15:04"))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// mdCell keeps text from breaking out of a markdown table cell.
+// checkMark is the Markdown task list mark for an item done or not.
+func checkMark(done bool) string {
+	if done {
+		return "x"
+	}
+	return " "
+}
+
+func mdCell(s string) string {
+	s = strings.ReplaceAll(s, "|", `\|`)
+	return strings.ReplaceAll(s, "\n", " ")
+}
+
+// Markdown renders the report as GitHub-flavored markdown.
+func (r *Report) Markdown() string {
+	var b strings.Builder
+	nFiles, added, deleted := r.Diff.Stats()
+
+	b.WriteString("# Review Report\n\n")
+	fmt.Fprintf(&b, "**%d file(s)** changed, **+%d** insertions, **-%d** deletions", nFiles, added, deleted)
+	if r.Diff.Range.Base != "" {
+		fmt.Fprintf(&b, " (`%s`)", r.Diff.Range.Short())
+	}
+	b.WriteString("\n\n")
+	if !r.Generated.IsZero() {
+		fmt.Fprintf(&b, "_Generated %s by agrev_\n\n", r.Generated.Format("2006-01-02 15:04 MST"))
+	}
+
+	b.WriteString("## Agent Trace\n\n")
+	if r.Trace == nil {
+		b.WriteString("No agent trace.\n\n")
+	} else {
+		fmt.Fprintf(&b, "**Source:** %s\n\n", traceStats(r.Trace))
+		if summary := strings.TrimSpace(r.Trace.Summary); summary != "" {
+			// Demote the summary's headings below the report's
+			for _, line := range strings.Split(summary, "\n") {
+				if strings.HasPrefix(line, "#") {
+					line = "##" + line
+				}
+				b.WriteString(line + "\n")
+			}
+			b.WriteString("\n")
+		}
+		if anns := r.annotations(); len(anns) > 0 {
+			b.WriteString("### Annotations\n\n")
+			for _, a := range anns {
+				fmt.Fprintf(&b, "- `%s` — %s: %s\n", a.location(), a.Type, a.Message)
+			}
+			b.WriteString("\n")
+		}
+	}
+
+	b.WriteString("## Analysis\n\n")
+	switch findings := r.findings(); {
+	case r.Results == nil:
+		b.WriteString("Analysis was not run.\n\n")
+	case len(findings) == 0:
+		b.WriteString("No issues found.\n\n")
+	default:
+		fmt.Fprintf(&b, "**Risk:** %s | **Findings:** %s\n\n", r.Results.MaxRisk(), r.Results.Summary())
+		b.WriteString("| Risk | Pass | Rule | File | Message |\n")
+		b.WriteString("|------|------|------|------|---------|\n")
+		for _, f := range findings {
+			loc := "`" + location(f) + "`"
+			if f.Scope != "" {
+				loc += " in `" + f.Scope + "`"
+			}
+			rule := f.Rule
+			if url := f.DocsURL(); url != "" {
+				rule = fmt.Sprintf("[%s](%s)", f.Rule, url)
+			}
+			fmt.Fprintf(&b, "| %s | %s | %s | %s | %s |\n", f.Risk, f.Pass, rule, loc, mdCell(f.Message))
+		}
+		b.WriteString("\n")
+	}
+
+	if bumps, ok := analysis.DependencyUpdate(r.Diff, r.Results); ok {
+		b.WriteString("## Dependency update\n\n")
+		fmt.Fprintf(&b, "**%d package(s)** bumped, added, or removed\n\n", len(bumps))
+		b.WriteString("| Package | Version | Jump | Vulnerabilities |\n")
+		b.WriteString("|---------|---------|------|-----------------|\n")
+		for _, bump := range bumps {
+			name := "`" + bump.Name + "`"
+			if bump.Changelog != "" {
+				name = fmt.Sprintf("[`%s`](%s)", bump.Name, bump.Changelog)
+			}
+			fmt.Fprintf(&b, "| %s | %s | %s | %s |\n", name, bump.Versions(), bump.Jump(), bump.Status())
+		}
+		b.WriteString("\n")
+	}
+
+	b.WriteString("## Review\n\n")
+	if r.Review == nil {
+		b.WriteString("No saved review of these changes.\n")
+		return b.String()
+	}
+	fmt.Fprintf(&b, "**%d file(s)** reviewed: %s\n\n", len(r.Review.Files), r.tally())
+	owned := r.Review.Owned()
+	if owned {
+		b.WriteString("| Decision | File | Changes | Owners |\n")
+		b.WriteString("|----------|------|---------|--------|\n")
+	} else {
+		b.WriteString("| Decision | File | Changes |\n")
+		b.WriteString("|----------|------|---------|\n")
+	}
+	for i, f := range r.Review.Files {
+		fmt.Fprintf(&b, "| %s | `%s` | +%d -%d |", r.decision(i), f.Name(), f.AddedLines, f.DeletedLines)
+		if owned {
+			b.WriteString(" " + strings.Join(r.Review.Owners.Of(f), " ") + " |")
+		}
+		b.WriteString("\n")
+	}
+
+	if notes := r.Review.Notes(); len(notes) > 0 {
+		b.WriteString("\n### Rejection notes\n\n")
+		for _, n := range notes {
+			fmt.Fprintf(&b, "- `%s` — %s\n", n.Location(), n.Body)
+		}
+	}
+
+	if conds := r.Review.Conditions(); len(conds) > 0 {
+		b.WriteString("\n### Follow-ups\n\n")
+		for _, c := range conds {
+			fmt.Fprintf(&b, "- [ ] `%s` — %s\n", c.File, c.Body)
+		}
+	}
+
+	if labels := r.Review.Labels(); len(labels) > 0 {
+		b.WriteString("\n### Labels\n\n")
+		for _, l := range labels {
+			fmt.Fprintf(&b, "- `%s` — %s\n", l.Location(), l.Tags())
+		}
+	}
+
+	if len(r.Review.Checklist) > 0 {
+		b.WriteString("\n### Checklist\n\n")
+		for _, c := range r.Review.Checklist {
+			fmt.Fprintf(&b, "- [%s] %s\n", checkMark(c.Done), c.Text)
+		}
+	}
+
+	if len(r.Review.Comments) > 0 {
+		b.WriteString("\n### Comments\n\n")
+		for _, c := range r.Review.Comments {
+			fmt.Fprintf(&b, "- `%s` — %s", commentLocation(c), c.Body)
+			if meta := commentMeta(c); meta != "" {
+				b.WriteString(" _(" + meta + ")_")
+			}
+			b.WriteString("\n")
+		}
+	}
+
+	return b.String()
+}
+
+const htmlHead = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>agrev Review Report</title>
+<style>
+  body { font-family: -apple-system, BlinkMacSystemFont, 'Segoe UI', Roboto, sans-serif; max-width: 1100px; margin: 40px auto; padding: 0 20px; background: #282a36; color: #f8f8f2; }
+  h1 { color: #bd93f9; }
+  h2 { color: #bd93f9; border-bottom: 1px solid #44475a; padding-bottom: 4px; margin-top: 32px; }
+  .summary { background: #343746; padding: 16px; border-radius: 8px; margin-bottom: 24px; }
+  .summary span { margin-right: 24px; }
+  .risk-critical, .risk-high, .rejected { color: #ff5555; font-weight: bold; }
+  .risk-medium, .partial { color: #f1fa8c; }
+  .risk-low { color: #8be9fd; }
+  .risk-info, .pending, .meta { color: #6272a4; }
+  .approved { color: #50fa7b; }
+  table { width: 100%; border-collapse: collapse; }
+  th { text-align: left; padding: 8px 12px; background: #44475a; color: #f8f8f2; }
+  td { padding: 8px 12px; border-bottom: 1px solid #44475a; }
+  tr:hover { background: #343746; }
+  .pass { color: #bd93f9; }
+  .file { color: #8be9fd; }
+  code { background: #343746; padding: 2px 6px; border-radius: 4px; font-size: 0.9em; }
+  pre { background: #343746; padding: 16px; border-radius: 8px; white-space: pre-wrap; }
+  footer { margin-top: 32px; color: #6272a4; font-size: 0.85em; }
+  a { color: #8be9fd; }
+  details.section > summary { cursor: pointer; list-style: none; }
+  details.section > summary h2 { display: inline-block; }
+  details.section > summary h2::before { content: "▸ "; }
+  details.section[open] > summary h2::before { content: "▾ "; }
+  .toolbar { margin: 16px 0; }
+  .toolbar button, .toolbar input { background: #44475a; color: #f8f8f2; border: 1px solid #6272a4; border-radius: 4px; padding: 4px 10px; margin-right: 8px; }
+  .file-diff { margin: 8px 0; border: 1px solid #44475a; border-radius: 8px; }
+  .file-diff > summary { cursor: pointer; padding: 8px 12px; background: #343746; border-radius: 8px; }
+  .file-diff > ul, .file-diff > p { margin: 8px 12px; }
+  table.diff { font-family: ui-monospace, SFMono-Regular, Menlo, monospace; font-size: 0.85em; }
+  table.diff td { padding: 0 8px; border: none; white-space: pre-wrap; word-break: break-all; }
+  table.diff td.num { color: #6272a4; text-align: right; width: 1%; white-space: nowrap; user-select: none; }
+  table.diff tr.hunk td { color: #6272a4; background: #343746; padding: 4px 8px; }
+  table.diff tr.add { background: rgba(80, 250, 123, 0.1); }
+  table.diff tr.del { background: rgba(255, 85, 85, 0.1); }
+  table.diff tr.finding td, table.diff tr.trace-link td { font-family: -apple-system, BlinkMacSystemFont, 'Segoe UI', Roboto, sans-serif; padding: 6px 12px; }
+  table.diff tr.finding td { background: #44475a; border-left: 3px solid #ffb86c; }
+  table.diff tr.trace-link td { color: #6272a4; font-size: 0.9em; }
+  table.diff tr:target, .timeline li:target { outline: 2px solid #bd93f9; }
+  .timeline li { margin: 4px 0; }
+  .timeline summary { cursor: pointer; }
+  .step-type { display: inline-block; min-width: 6em; color: #ff79c6; }
+</style>
+</head>
+<body>
+<h1>agrev Review Report</h1>
+`
+
+// htmlScript makes the report navigable: expanding and collapsing every
+// section, filtering the files by name, and opening the sections around
+// a linked line or step.
+const htmlScript = `<script>
+function setAll(open) { document.querySelectorAll('details').forEach(function (d) { d.open = open; }); }
+function filterFiles(q) {
+  q = q.toLowerCase();
+  document.querySelectorAll('.file-diff').forEach(function (d) {
+    d.style.display = d.dataset.name.toLowerCase().indexOf(q) >= 0 ? '' : 'none';
+  });
+}
+function reveal() {
+  var el = location.hash && document.getElementById(location.hash.slice(1));
+  for (var d = el; d; d = d.parentElement) { if (d.tagName === 'DETAILS') d.open = true; }
+  if (el) el.scrollIntoView({block: 'center'});
+}
+window.addEventListener('hashchange', reveal);
+reveal();
+</script>
+`
+
+// HTML renders the report as a standalone, interactive HTML page: the
+// sections collapse, findings link to their lines in the syntax-highlighted
+// diffs, and the trace's timeline expands step by step. Everything is
+// inline, so the page can be mailed or attached as it is.
+func (r *Report) HTML() string {
+	var b strings.Builder
+	esc := html.EscapeString
+	nFiles, added, deleted := r.Diff.Stats()
+
+	b.WriteString(htmlHead)
+	b.WriteString(`<div class="summary">` + "\n")
+	fmt.Fprintf(&b, "  <span><strong>%d</strong> file(s) changed</span>\n", nFiles)
+	fmt.Fprintf(&b, "  <span class=\"approved\">+%d</span>\n  <span class=\"rejected\">-%d</span>\n", added, deleted)
+	if r.Diff.Range.Base != "" {
+		fmt.Fprintf(&b, "  <span><code>%s</code></span>\n", esc(r.Diff.Range.Short()))
+	}
+	if r.Results != nil {
+		risk := r.Results.MaxRisk()
+		fmt.Fprintf(&b, "  <span>Risk: <span class=\"risk-%s\">%s</span></span>\n", risk, risk)
+	}
+	b.WriteString("</div>\n")
+	b.WriteString(`<div class="toolbar"><button onclick="setAll(true)">Expand all</button><button onclick="setAll(false)">Collapse all</button>` +
+		`<input type="search" placeholder="Filter files" oninput="filterFiles(this.value)"></div>` + "\n")
+
+	b.WriteString("<details class=\"section\" open><summary><h2>Agent Trace</h2></summary>\n")
+	if r.Trace == nil {
+		b.WriteString("<p class=\"meta\">No agent trace.</p>\n")
+	} else {
+		fmt.Fprintf(&b, "<p>Source: %s</p>\n", esc(traceStats(r.Trace)))
+		if summary := strings.TrimSpace(r.Trace.Summary); summary != "" {
+			fmt.Fprintf(&b, "<pre>%s</pre>\n", esc(summary))
+		}
+		if anns := r.annotations(); len(anns) > 0 {
+			b.WriteString("<h3>Annotations</h3>\n<ul>\n")
+			for _, a := range anns {
+				class := "meta"
+				if a.Type == model.AnnotationRisk || a.Type == model.AnnotationWarning {
+					class = "risk-medium"
+				}
+				fmt.Fprintf(&b, "<li><code>%s</code> — <span class=\"%s\">%s: %s</span></li>\n",
+					esc(a.location()), class, a.Type, esc(a.Message))
+			}
+			b.WriteString("</ul>\n")
+		}
+		if len(r.Trace.Steps) > 0 {
+			b.WriteString("<h3>Timeline</h3>\n")
+			r.writeTimeline(&b)
+		}
+	}
+	b.WriteString("</details>\n")
+
+	b.WriteString("<details class=\"section\" open><summary><h2>Analysis</h2></summary>\n")
+	switch findings := r.findings(); {
+	case r.Results == nil:
+		b.WriteString("<p class=\"meta\">Analysis was not run.</p>\n")
+	case len(findings) == 0:
+		b.WriteString("<p class=\"approved\">No issues found.</p>\n")
+	default:
+		fmt.Fprintf(&b, "<p>Findings: %s</p>\n", esc(r.Results.Summary()))
+		b.WriteString("<table>\n<thead><tr><th>Risk</th><th>Pass</th><th>Rule</th><th>File</th><th>Message</th></tr></thead>\n<tbody>\n")
+		for _, f := range findings {
+			loc := "<code>" + esc(location(f)) + "</code>"
+			if link := r.findingLink(f); link != "" {
+				loc = fmt.Sprintf("<a href=\"%s\">%s</a>", link, loc)
+			}
+			if f.Scope != "" {
+				loc += " in <code>" + esc(f.Scope) + "</code>"
+			}
+			rule := esc(f.Rule)
+			if url := f.DocsURL(); url != "" {
+				rule = fmt.Sprintf("<a href=\"%s\">%s</a>", esc(url), rule)
+			}
+			fmt.Fprintf(&b, "<tr><td class=\"risk-%s\">%s</td><td class=\"pass\">%s</td><td>%s</td><td class=\"file\">%s</td><td>%s</td></tr>\n",
+				f.Risk, f.Risk, esc(f.Pass), rule, loc, esc(f.Message))
+		}
+		b.WriteString("</tbody></table>\n")
+	}
+	b.WriteString("</details>\n")
+
+	if bumps, ok := analysis.DependencyUpdate(r.Diff, r.Results); ok {
+		b.WriteString("<details class=\"section\" open><summary><h2>Dependency update</h2></summary>\n")
+		fmt.Fprintf(&b, "<p><strong>%d</strong> package(s) bumped, added, or removed</p>\n", len(bumps))
+		b.WriteString("<table>\n<thead><tr><th>Package</th><th>Version</th><th>Jump</th><th>Vulnerabilities</th></tr></thead>\n<tbody>\n")
+		for _, bump := range bumps {
+			name := "<code>" + esc(bump.Name) + "</code>"
+			if bump.Changelog != "" {
+				name = fmt.Sprintf("<a href=\"%s\">%s</a>", esc(bump.Changelog), name)
+			}
+			class := "meta"
+			if len(bump.Vulnerabilities) > 0 {
+				class = "risk-high"
+			}
+			fmt.Fprintf(&b, "<tr><td>%s</td><td>%s</td><td>%s</td><td class=\"%s\">%s</td></tr>\n",
+				name, esc(bump.Versions()), bump.Jump(), class, esc(bump.Status()))
+		}
+		b.WriteString("</tbody></table>\n</details>\n")
+	}
+
+	b.WriteString("<details class=\"section\" open><summary><h2>Review</h2></summary>\n")
+	if r.Review == nil {
+		b.WriteString("<p class=\"meta\">No saved review of these changes.</p>\n")
+	} else {
+		fmt.Fprintf(&b, "<p><strong>%d</strong> file(s) reviewed: %s</p>\n", len(r.Review.Files), esc(r.tally()))
+		owned := r.Review.Owned()
+		b.WriteString("<table>\n<thead><tr><th>Decision</th><th>File</th><th>Changes</th>")
+		if owned {
+			b.WriteString("<th>Owners</th>")
+		}
+		b.WriteString("</tr></thead>\n<tbody>\n")
+		for i, f := range r.Review.Files {
+			fmt.Fprintf(&b, "<tr><td class=\"%s\">%s</td><td class=\"file\"><code>%s</code></td><td>+%d -%d</td>",
+				r.Review.Decision(i), esc(r.decision(i)), esc(f.Name()), f.AddedLines, f.DeletedLines)
+			if owned {
+				fmt.Fprintf(&b, "<td>%s</td>", esc(strings.Join(r.Review.Owners.Of(f), " ")))
+			}
+			b.WriteString("</tr>\n")
+		}
+		b.WriteString("</tbody></table>\n")
+
+		if notes := r.Review.Notes(); len(notes) > 0 {
+			b.WriteString("<h3>Rejection notes</h3>\n<ul>\n")
+			for _, n := range notes {
+				fmt.Fprintf(&b, "<li><code>%s</code> — %s</li>\n", esc(n.Location()), esc(n.Body))
+			}
+			b.WriteString("</ul>\n")
+		}
+
+		if conds := r.Review.Conditions(); len(conds) > 0 {
+			b.WriteString("<h3>Follow-ups</h3>\n<ul>\n")
+			for _, c := range conds {
+				fmt.Fprintf(&b, "<li><input type=\"checkbox\" disabled> <code>%s</code> — %s</li>\n", esc(c.File), esc(c.Body))
+			}
+			b.WriteString("</ul>\n")
+		}
+
+		if labels := r.Review.Labels(); len(labels) > 0 {
+			b.WriteString("<h3>Labels</h3>\n<ul>\n")
+			for _, l := range labels {
+				fmt.Fprintf(&b, "<li><code>%s</code> — %s</li>\n", esc(l.Location()), esc(l.Tags()))
+			}
+			b.WriteString("</ul>\n")
+		}
+
+		if len(r.Review.Checklist) > 0 {
+			b.WriteString("<h3>Checklist</h3>\n<ul>\n")
+			for _, c := range r.Review.Checklist {
+				checked := ""
+				if c.Done {
+					checked = " checked"
+				}
+				fmt.Fprintf(&b, "<li><input type=\"checkbox\" disabled%s> %s</li>\n", checked, esc(c.Text))
+			}
+			b.WriteString("</ul>\n")
+		}
+
+		if len(r.Review.Comments) > 0 {
+			b.WriteString("<h3>Comments</h3>\n<ul>\n")
+			for _, c := range r.Review.Comments {
+				fmt.Fprintf(&b, "<li><code>%s</code> — %s", esc(commentLocation(c)), esc(c.Body))
+				if meta := commentMeta(c); meta != "" {
+					fmt.Fprintf(&b, " <span class=\"meta\">(%s)</span>", esc(meta))
+				}
+				b.WriteString("</li>\n")
+			}
+			b.WriteString("</ul>\n")
+		}
+	}
+
+	b.WriteString("</details>\n")
+
+	b.WriteString("<details class=\"section\" open><summary><h2>Files</h2></summary>\n")
+	r.writeFiles(&b)
+	b.WriteString("</details>\n")
+
+	b.WriteString("<footer>Generated")
+	if !r.Generated.IsZero() {
+		b.WriteString(" " + esc(r.Generated.Format("2006-01-02 15:04 MST")))
+	}
+	b.WriteString(" by <strong>agrev</strong></footer>\n")
+	b.WriteString(htmlScript)
+	b.WriteString("</body>\n</html>\n")
+	return b.String()
+}
@@ -0,0 +1,163 @@
+package report
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aezell/agrev/internal/analysis"
+	"github.com/aezell/agrev/internal/diff"
+	"github.com/aezell/agrev/internal/model"
+	"github.com/aezell/agrev/internal/owners"
+	"github.com/aezell/agrev/internal/trace"
+	"github.com/aezell/agrev/internal/tui"
+)
+
+const testDiff = `diff --git a/auth.go b/auth.go
+--- a/auth.go
++++ b/auth.go
+@@ -1,3 +1,3 @@
+ package auth
+-var token = ""
++var token = os.Getenv("TOKEN")
+ func Check() {}
+diff --git a/util.go b/util.go
+--- a/util.go
++++ b/util.go
+@@ -1 +1 @@
+-package util
++package utils
+`
+
+func newReport(t *testing.T) *Report {
+	t.Helper()
+	ds, err := diff.Parse(testDiff)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	decisions := model.NewDecisions()
+	decisions.SetFile(0, model.DecisionRejected)
+	decisions.SetNote(0, model.WholeFile, "leaks <the> token")
+	decisions.SetBulk(1, model.DecisionApproved)
+	decisions.AddCondition(1, "rename <it> back")
+	co, err := owners.Parse(strings.NewReader("auth.go @org/security\n"))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	return &Report{
+		Diff: ds,
+		Trace: &trace.Trace{Source: "claude-code", Steps: []trace.Step{
+			{Type: trace.StepFileRead, Summary: "Read auth.go", FilePath: "auth.go"},
+			{Type: trace.StepReasoning, Summary: "Take the token from the environment"},
+			{Type: trace.StepFileEdit, Summary: "Edit auth.go", FilePath: "auth.go", NewString: "var token = os.Getenv(\"TOKEN\")\n"},
+			{Type: trace.StepFileWrite, Summary: "Write util.go", FilePath: "util.go"},
+		}, Summary: "## Summary\n\nRead the token from the environment."},
+		Results: &analysis.Results{Findings: []analysis.Finding{
+			{Pass: "anti_patterns", File: "util.go", Line: 1, Message: "package renamed", Risk: model.RiskLow},
+			{Pass: "security", Rule: analysis.RuleSecrets, File: "auth.go", Line: 2, Message: "reads env var | TOKEN", Risk: model.RiskHigh},
+		}},
+		Review: &tui.ReviewResult{
+			Decisions: decisions,
+			Files:     ds.Files,
+			Comments:  []model.Comment{{File: "util.go", Line: 1, Body: "why rename?", Author: "ada"}},
+			Owners:    co,
+			Checklist: []model.ChecklistItem{{Text: "ran it locally", Done: true}, {Text: "check <the> docs"}},
+		},
+		Generated: time.Date(2026, 3, 4, 10, 0, 0, 0, time.UTC),
+	}
+}
+
+func TestMarkdown(t *testing.T) {
+	md := newReport(t).Markdown()
+	for _, want := range []string{
+		"**2 file(s)** changed, **+2** insertions, **-2** deletions",
+		"**Source:** claude-code, 4 steps, 0 file(s) touched",
+		"#### Summary", // demoted below the report's headings
+		"- `auth.go:2` — trace_link: step 3 (edit): Edit auth.go",
+		"- `auth.go:2` — info: agent: Take the token from the environment",
+		"- `util.go` — risk: overwritten without reading the file first",
+		"| high | security | [AGV-SEC-006](" + analysis.DocsURL + "#agv-sec-006) | `auth.go:2` | reads env var \\| TOKEN |",
+		"**2 file(s)** reviewed: 1 approved, 1 rejected, 0 pending",
+		"| rejected | `auth.go` | +1 -1 | @org/security |",
+		"| approved with follow-ups in bulk | `util.go` | +1 -1 |  |",
+		"- [ ] `util.go` — rename <it> back",
+		"### Checklist\n\n- [x] ran it locally\n- [ ] check <the> docs",
+		"- `auth.go` — leaks <the> token",
+		"- `util.go:1` — why rename? _(ada)_",
+	} {
+		if !strings.Contains(md, want) {
+			t.Errorf("expected %q in report:\n%s", want, md)
+		}
+	}
+	if strings.Index(md, "security") > strings.Index(md, "anti_patterns") {
+		t.Error("expected findings sorted highest risk first")
+	}
+}
+
+func TestMarkdownWithoutInputs(t *testing.T) {
+	r := newReport(t)
+	r.Trace, r.Results, r.Review = nil, nil, nil
+	md := r.Markdown()
+	for _, want := range []string{"No agent trace.", "Analysis was not run.", "No saved review of these changes."} {
+		if !strings.Contains(md, want) {
+			t.Errorf("expected %q in report:\n%s", want, md)
+		}
+	}
+}
+
+func TestHTML(t *testing.T) {
+	page := newReport(t).HTML()
+	for _, want := range []string{
+		"<!DOCTYPE html>",
+		`<span>Risk: <span class="risk-high">high</span></span>`,
+		`<td class="rejected">rejected</td>`,
+		"leaks &lt;the&gt; token",
+		`<li><input type="checkbox" disabled> <code>util.go</code> — rename &lt;it&gt; back</li>`,
+		`<li><input type="checkbox" disabled checked> ran it locally</li>`,
+		`<li><code>util.go</code> — <span class="risk-medium">risk: overwritten without reading the file first</span></li>`,
+		`<a href="` + analysis.DocsURL + `#agv-sec-006">AGV-SEC-006</a>`,
+		"Generated 2026-03-04 10:00 UTC by",
+		// The interactive parts: linked findings, file diffs, and the timeline
+		`<a href="#file-0-L2"><code>auth.go:2</code></a>`,
+		`<details class="file-diff" id="file-0" data-name="auth.go" open>`,
+		`<tr class="add" id="file-0-L2"><td class="num"></td><td class="num">2</td><td class="code">+<span style="color:`,
+		`<tr class="finding"><td colspan="3"><span class="risk-high">high</span> <span class="pass">security</span>`,
+		`<tr class="trace-link"><td colspan="3">from <a href="#step-3">step 3</a></td></tr>`,
+		`<li id="step-3"><details><summary><span class="step-type step-edit">edit</span> Edit auth.go</summary><pre>+var token = os.Getenv(&#34;TOKEN&#34;)</pre></details></li>`,
+		"<script>",
+	} {
+		if !strings.Contains(page, want) {
+			t.Errorf("expected %q in page:\n%s", want, page)
+		}
+	}
+	if strings.Contains(page, "<the>") {
+		t.Error("expected notes to be escaped")
+	}
+}
+
+func TestMarkdownDependencyUpdate(t *testing.T) {
+	ds, err := diff.Parse(`diff --git a/Cargo.toml b/Cargo.toml
+--- a/Cargo.toml
++++ b/Cargo.toml
+@@ -5,3 +5,3 @@
+ [dependencies]
+-serde = "1.0.190"
++serde = "1.0.193"
+ tokio = "1"
+`)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	md := (&Report{Diff: ds, Results: &analysis.Results{}}).Markdown()
+	for _, want := range []string{
+		"## Dependency update",
+		"| [`serde`](https://crates.io/crates/serde/versions) | 1.0.190 → 1.0.193 | patch |",
+	} {
+		if !strings.Contains(md, want) {
+			t.Errorf("expected %q in report:\n%s", want, md)
+		}
+	}
+	if md := newReport(t).Markdown(); strings.Contains(md, "Dependency update") {
+		t.Error("expected no dependency update section for a change to code")
+	}
+}
@@ -0,0 +1,124 @@
+// Package review holds a review as one unit of work: the change under
+// review, the agent's trace of it, its analysis findings and change groups,
+// and the decisions and comments made on it. Commands build a Session and
+// hand it to the TUI, the API server, and the saved session alike.
+package review
+
+import (
+	"github.com/aezell/agrev/internal/analysis"
+	"github.com/aezell/agrev/internal/config"
+	"github.com/aezell/agrev/internal/diff"
+	"github.com/aezell/agrev/internal/gate"
+	"github.com/aezell/agrev/internal/group"
+	"github.com/aezell/agrev/internal/model"
+	"github.com/aezell/agrev/internal/trace"
+)
+
+// Session is a review of one diff. The embedded model.ReviewSession holds
+// the commit range, the change groups, and the decisions and comments,
+// whose file indexes are into Diff.Files.
+type Session struct {
+	model.ReviewSession
+
+	Diff    *diff.DiffSet
+	Trace   *trace.Trace      // nil without a trace
+	Results *analysis.Results // nil until the analysis has run
+}
+
+// New starts a review of ds with nothing decided yet, its files grouped
+// using the trace and findings. t and results may be nil.
+func New(ds *diff.DiffSet, t *trace.Trace, results *analysis.Results) *Session {
+	s := &Session{Diff: ds, Trace: t, Results: results}
+	s.CommitRange = ds.Range.Short()
+	s.Decisions = model.NewDecisions()
+	s.Regroup()
+	return s
+}
+
+// Regroup rebuilds the change groups, as after the findings change.
+func (s *Session) Regroup() {
+	s.Groups = group.Build(s.Diff, s.Trace, s.Results)
+}
+
+// SetResults gives the review its findings and regroups its files by them.
+func (s *Session) SetResults(results *analysis.Results) {
+	s.Results = results
+	s.Regroup()
+}
+
+// SplitHunk splits hunk h of file i into one hunk per run of changes, as
+// diff.File.SplitHunk does, and moves the decisions and comments on the
+// file's hunks to match. It returns the number of hunks h became.
+func (s *Session) SplitHunk(i, h int) int {
+	f := s.Diff.Files[i]
+	n := f.SplitHunk(h)
+	if n < 2 {
+		return n
+	}
+	// The pieces inherit the hunk's own decision, if it had one
+	s.Decisions.SplitHunk(i, h, n)
+	SplitComments(s.Comments, f, h, n)
+	return n
+}
+
+// SplitComments updates the hunks of the comments on f after its hunk h
+// was split into n: comments on later hunks move up, and those on h go to
+// the piece holding their line.
+func SplitComments(comments []model.Comment, f *diff.File, h, n int) {
+	for i := range comments {
+		c := &comments[i]
+		if c.File != f.Name() {
+			continue
+		}
+		// Comment hunks count from 1
+		switch {
+		case c.Hunk > h+1:
+			c.Hunk += n - 1
+		case c.Hunk == h+1 && c.Line > 0:
+			c.Hunk = f.HunkAt(c.Line)
+		}
+	}
+}
+
+// SetChecklist gives the review the checklist items in items that apply to
+// its files. Items already on the checklist stay as they were.
+func (s *Session) SetChecklist(items []config.ChecklistItem) {
+	done := make(map[string]bool)
+	for _, c := range s.Checklist {
+		done[c.Text] = c.Done
+	}
+	s.Checklist = Checklist(items, s.Diff)
+	for i := range s.Checklist {
+		s.Checklist[i].Done = done[s.Checklist[i].Text]
+	}
+}
+
+// Checklist returns the items that apply to ds, in order: those without
+// paths, and those with a path matching a changed file. Repeated items are
+// listed once.
+func Checklist(items []config.ChecklistItem, ds *diff.DiffSet) []model.ChecklistItem {
+	var out []model.ChecklistItem
+	seen := make(map[string]bool)
+	for _, c := range items {
+		if seen[c.Item] || (len(c.Paths) > 0 && !changes(ds, c.Paths)) {
+			continue
+		}
+		seen[c.Item] = true
+		out = append(out, model.ChecklistItem{Text: c.Item})
+	}
+	return out
+}
+
+// changes reports whether ds changes a file matching any of the globs.
+func changes(ds *diff.DiffSet, globs []string) bool {
+	for _, f := range ds.Files {
+		for _, name := range gate.FilePaths(f) {
+			for _, g := range globs {
+				if gate.Match(g, name) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,83 @@
+package review
+
+import (
+	"testing"
+
+	"github.com/aezell/agrev/internal/analysis"
+	"github.com/aezell/agrev/internal/config"
+	"github.com/aezell/agrev/internal/diff"
+	"github.com/aezell/agrev/internal/model"
+)
+
+const testDiff = `diff --git a/auth/token.go b/auth/token.go
+--- a/auth/token.go
++++ b/auth/token.go
+@@ -1 +1 @@
+-package auth
++package auth // tokens
+diff --git a/docs/auth.md b/docs/auth.md
+--- a/docs/auth.md
++++ b/docs/auth.md
+@@ -1 +1 @@
+-# Auth
++# Authentication
+`
+
+func TestNew(t *testing.T) {
+	ds, err := diff.Parse(testDiff)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	ds.Range = diff.Range{Base: "1a2b3c4d5e", Head: "5d6e7f8a9b"}
+
+	s := New(ds, nil, nil)
+	if s.CommitRange != "1a2b3c4..5d6e7f8" || s.Decisions.Files == nil || len(s.Comments) != 0 {
+		t.Errorf("unexpected session %+v", s.ReviewSession)
+	}
+	if len(s.Groups) == 0 {
+		t.Fatal("expected the files grouped")
+	}
+	for _, g := range s.Groups {
+		if g.Risk != model.RiskInfo {
+			t.Errorf("expected no risk without findings, got %+v", g)
+		}
+	}
+
+	s.SetResults(&analysis.Results{Findings: []analysis.Finding{
+		{Pass: "security", File: "auth/token.go", Line: 1, Message: "token", Risk: model.RiskHigh},
+	}})
+	var risky bool
+	for _, g := range s.Groups {
+		risky = risky || g.Risk == model.RiskHigh
+	}
+	if !risky {
+		t.Errorf("expected the groups to follow the findings, got %+v", s.Groups)
+	}
+}
+
+func TestSetChecklist(t *testing.T) {
+	ds, err := diff.Parse(testDiff)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	items := []config.ChecklistItem{
+		{Item: "ran it locally"},
+		{Item: "confirm the backfill plan", Paths: []string{"migrations/**"}},
+		{Item: "check token expiry", Paths: []string{"auth/*.go"}},
+		{Item: "ran it locally", Paths: []string{"docs/**"}},
+	}
+
+	s := New(ds, nil, nil)
+	s.SetChecklist(items)
+	want := []model.ChecklistItem{{Text: "ran it locally"}, {Text: "check token expiry"}}
+	if len(s.Checklist) != len(want) || s.Checklist[0] != want[0] || s.Checklist[1] != want[1] {
+		t.Fatalf("expected the global item and the auth item, got %+v", s.Checklist)
+	}
+
+	// Checked items stay checked when the checklist is rebuilt
+	s.Checklist[1].Done = true
+	s.SetChecklist(items)
+	if !s.Checklist[1].Done || s.Checklist[0].Done || model.ChecklistLeft(s.Checklist) != 1 {
+		t.Errorf("expected the auth item still checked, got %+v", s.Checklist)
+	}
+}
@@ -0,0 +1,152 @@
+// Package sbom describes the dependencies a change adds as a CycloneDX SBOM
+// fragment, so agent-introduced packages can be fed into the SBOM tooling a
+// security team already runs.
+package sbom
+
+import (
+	"crypto/rand"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aezell/agrev/internal/analysis"
+)
+
+// SpecVersion is the CycloneDX version written.
+const SpecVersion = "1.5"
+
+// BOM is a CycloneDX document. Only the fields agrev fills in are modeled.
+type BOM struct {
+	BOMFormat    string      `json:"bomFormat"`
+	SpecVersion  string      `json:"specVersion"`
+	SerialNumber string      `json:"serialNumber"`
+	Version      int         `json:"version"`
+	Metadata     Metadata    `json:"metadata"`
+	Components   []Component `json:"components"`
+}
+
+// Metadata says when and by what the BOM was made, and of which change.
+type Metadata struct {
+	Timestamp  string     `json:"timestamp"`
+	Tools      Tools      `json:"tools"`
+	Properties []Property `json:"properties,omitempty"`
+}
+
+// Tools lists the tools that made the BOM.
+type Tools struct {
+	Components []Component `json:"components"`
+}
+
+// Component is a package, or the tool that made the BOM.
+type Component struct {
+	Type       string     `json:"type"`
+	BOMRef     string     `json:"bom-ref,omitempty"`
+	Group      string     `json:"group,omitempty"`
+	Name       string     `json:"name"`
+	Version    string     `json:"version,omitempty"`
+	PURL       string     `json:"purl,omitempty"`
+	Properties []Property `json:"properties,omitempty"`
+}
+
+// Property is a name/value pair; agrev's are prefixed "agrev:".
+type Property struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// purlTypes maps the deps pass's ecosystems to package URL types.
+var purlTypes = map[string]string{
+	"go":    "golang",
+	"npm":   "npm",
+	"cargo": "cargo",
+	"pip":   "pypi",
+	"gem":   "gem",
+	"hex":   "hex",
+}
+
+// exactVersion matches versions that pin a single release rather than a
+// range, such as "v1.2.3" or "4.17.21".
+var exactVersion = regexp.MustCompile(`^v?[0-9]+(\.[0-9]+)*([-+][0-9A-Za-z.+-]*)?$`)
+
+// New describes deps as a BOM made by agrev at toolVersion. subject names
+// the change, such as a commit range, and may be empty. A dependency added
+// in several files at the same version, such as go.mod and go.sum, is
+// listed once.
+func New(deps []analysis.Dependency, toolVersion, subject string, now time.Time) *BOM {
+	b := &BOM{
+		BOMFormat:    "CycloneDX",
+		SpecVersion:  SpecVersion,
+		SerialNumber: "urn:uuid:" + newUUID(),
+		Version:      1,
+		Metadata: Metadata{
+			Timestamp: now.UTC().Format(time.RFC3339),
+			Tools:     Tools{Components: []Component{{Type: "application", Name: "agrev", Version: toolVersion}}},
+		},
+		Components: []Component{},
+	}
+	if subject != "" {
+		b.Metadata.Properties = []Property{{Name: "agrev:change", Value: subject}}
+	}
+
+	seen := make(map[string]bool)
+	for _, d := range deps {
+		c := component(d)
+		if seen[c.PURL] {
+			continue
+		}
+		seen[c.PURL] = true
+		b.Components = append(b.Components, c)
+	}
+	return b
+}
+
+// component describes d. Only an exact version goes in the version and
+// package URL; a constraint such as "^1.2" is kept as a property.
+func component(d analysis.Dependency) Component {
+	c := Component{Type: "library", Name: d.Name}
+	name := d.Name
+	if d.Ecosystem == "pip" {
+		// PEP 503 normalization, as the pypi purl type requires
+		name = strings.ReplaceAll(strings.ToLower(name), "_", "-")
+	}
+	if d.Ecosystem == "npm" && strings.HasPrefix(name, "@") {
+		c.Group, c.Name, _ = strings.Cut(name, "/")
+	}
+	var segments []string
+	for _, s := range strings.Split(name, "/") {
+		segments = append(segments, escape(s))
+	}
+	c.PURL = "pkg:" + purlTypes[d.Ecosystem] + "/" + strings.Join(segments, "/")
+
+	// A bare version in Cargo.toml is a caret requirement
+	if exactVersion.MatchString(d.Version) && d.Ecosystem != "cargo" {
+		c.Version = d.Version
+		c.PURL += "@" + escape(d.Version)
+	} else if d.Version != "" {
+		c.Properties = append(c.Properties, Property{Name: "agrev:constraint", Value: d.Version})
+	}
+	c.BOMRef = c.PURL
+	c.Properties = append(c.Properties,
+		Property{Name: "agrev:file", Value: d.File},
+		Property{Name: "agrev:line", Value: strconv.Itoa(d.Line)},
+	)
+	return c
+}
+
+// escape percent-encodes a package URL segment; "@" separates the version,
+// so it is encoded too.
+func escape(s string) string {
+	return strings.ReplaceAll(url.PathEscape(s), "@", "%40")
+}
+
+// newUUID returns a random (version 4) UUID.
+func newUUID() string {
+	var u [16]byte
+	rand.Read(u[:])
+	u[6] = u[6]&0x0f | 0x40
+	u[8] = u[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", u[0:4], u[4:6], u[6:8], u[8:10], u[10:])
+}
@@ -0,0 +1,86 @@
+package sbom
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aezell/agrev/internal/analysis"
+	"github.com/aezell/agrev/internal/diff"
+)
+
+const depsDiff = `diff --git a/go.mod b/go.mod
+--- a/go.mod
++++ b/go.mod
+@@ -3,3 +3,4 @@ module example.com/app
+ require (
++	github.com/newdep/foo v1.2.3
+ 	github.com/existing/dep v1.0.0
+ )
+diff --git a/go.sum b/go.sum
+--- a/go.sum
++++ b/go.sum
+@@ -1 +1,3 @@
+ github.com/existing/dep v1.0.0 h1:abc=
++github.com/newdep/foo v1.2.3 h1:def=
++github.com/newdep/foo v1.2.3/go.mod h1:ghi=
+diff --git a/package.json b/package.json
+--- a/package.json
++++ b/package.json
+@@ -1,3 +1,5 @@
+ {
+   "dependencies": {
++    "@scope/widget": "^2.0.0",
++    "left-pad": "1.3.0"
+   }
+diff --git a/requirements.txt b/requirements.txt
+--- a/requirements.txt
++++ b/requirements.txt
+@@ -0,0 +1 @@
++Flask_Login==0.6.3 ; python_version >= "3.8"
+`
+
+func TestNew(t *testing.T) {
+	ds, err := diff.Parse(depsDiff)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2026, 3, 4, 10, 0, 0, 0, time.UTC)
+	bom := New(analysis.NewDependencies(ds), "1.2.3", "main...HEAD", now)
+
+	if bom.BOMFormat != "CycloneDX" || bom.SpecVersion != "1.5" || !strings.HasPrefix(bom.SerialNumber, "urn:uuid:") || len(bom.SerialNumber) != 45 {
+		t.Errorf("unexpected header %+v", bom)
+	}
+	if bom.Metadata.Timestamp != "2026-03-04T10:00:00Z" || bom.Metadata.Tools.Components[0].Version != "1.2.3" || bom.Metadata.Properties[0].Value != "main...HEAD" {
+		t.Errorf("unexpected metadata %+v", bom.Metadata)
+	}
+
+	var purls []string
+	for _, c := range bom.Components {
+		purls = append(purls, c.PURL)
+	}
+	want := []string{
+		"pkg:golang/github.com/newdep/foo@v1.2.3", // once, though go.sum lists it twice
+		"pkg:npm/%40scope/widget",
+		"pkg:npm/left-pad@1.3.0",
+		"pkg:pypi/flask-login@0.6.3",
+	}
+	if strings.Join(purls, " ") != strings.Join(want, " ") {
+		t.Fatalf("got components %v, want %v", purls, want)
+	}
+	widget := bom.Components[1]
+	if widget.Group != "@scope" || widget.Name != "widget" || widget.Version != "" ||
+		widget.Properties[0] != (Property{Name: "agrev:constraint", Value: "^2.0.0"}) ||
+		widget.Properties[1] != (Property{Name: "agrev:file", Value: "package.json"}) {
+		t.Errorf("unexpected component %+v", widget)
+	}
+
+	data, err := json.Marshal(bom)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `"bom-ref":"pkg:golang/github.com/newdep/foo@v1.2.3"`) {
+		t.Errorf("expected bom-refs in %s", data)
+	}
+}
@@ -0,0 +1,321 @@
+// Package session saves a review in progress to .agrev/session.json, so the
+// TUI, the CLI, and the API server can resume, report on, or hand over the
+// same review.
+package session
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/aezell/agrev/internal/diff"
+	"github.com/aezell/agrev/internal/history"
+	"github.com/aezell/agrev/internal/model"
+	"github.com/aezell/agrev/internal/review"
+)
+
+// Version is the version of the format Save writes. Load reads it and any
+// earlier version.
+const Version = 1
+
+// FileName is the session file inside history.Dir.
+const FileName = "session.json"
+
+// Session is a saved review: what was decided and said about a diff, and
+// which findings it had. Decisions and comments are kept by file name, with
+// a hash of each file's changes so they are only restored onto the same
+// changes.
+type Session struct {
+	Version  int       `json:"version"`
+	DiffHash string    `json:"diff_hash"` // of the whole raw diff
+	Created  time.Time `json:"created"`
+	Updated  time.Time `json:"updated"`
+	Files    []File    `json:"files"`
+	Comments []Comment `json:"comments,omitempty"`
+	Findings []Finding `json:"findings,omitempty"`
+
+	Checklist []ChecklistItem `json:"checklist,omitempty"`
+}
+
+// File is the decision on one file of the diff.
+type File struct {
+	Name      string   `json:"name"`
+	Hash      string   `json:"hash"`                                              // of the file's changes
+	Decision  string   `json:"decision" enum:"approved,rejected,partial,pending"` // derived from Hunks when they are set
+	Note      string   `json:"note,omitempty"`                                    // why the file was rejected
+	Hunks     []string `json:"hunks,omitempty"`                                   // each hunk's decision, when hunks were decided one by one
+	HunkNotes []string `json:"hunk_notes,omitempty"`                              // why each hunk was rejected, "" for none
+	Bulk      bool     `json:"bulk,omitempty"`                                    // decided along with other files, not on its own
+
+	Conditions []string `json:"conditions,omitempty"` // follow-ups the file was approved on
+
+	Labels     []string   `json:"labels,omitempty"`      // labels on the whole file
+	HunkLabels [][]string `json:"hunk_labels,omitempty"` // labels on each hunk, when any has some
+}
+
+// ChecklistItem is an item of the review checklist and whether the
+// reviewer checked it off.
+type ChecklistItem struct {
+	Item string `json:"item"`
+	Done bool   `json:"done"`
+}
+
+// Comment is a review comment. Line and Hunk are as in model.Comment.
+type Comment struct {
+	File   string    `json:"file"`
+	Line   int       `json:"line,omitempty"`
+	Hunk   int       `json:"hunk,omitempty"`
+	Body   string    `json:"body"`
+	Author string    `json:"author,omitempty"`
+	Time   time.Time `json:"time"`
+}
+
+// Finding records an analysis finding by its fingerprint, with enough
+// detail to report on it without running the analysis again.
+type Finding struct {
+	Fingerprint string `json:"fingerprint"`
+	Pass        string `json:"pass"`
+	Rule        string `json:"rule,omitempty"`
+	File        string `json:"file"`
+	Line        int    `json:"line,omitempty"`
+	Risk        string `json:"risk"`
+	Message     string `json:"message"`
+	State       string `json:"state,omitempty" enum:"acknowledged,fixed"` // as triaged by reviewers in earlier runs
+}
+
+// Path returns the session file for a repository.
+func Path(repoDir string) string {
+	return filepath.Join(repoDir, history.Dir, FileName)
+}
+
+// Hash returns the hash sessions identify a raw diff by.
+func Hash(raw string) string {
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(raw)))
+}
+
+// fileHash identifies a file's changes independently of the rest of the
+// diff.
+func fileHash(f *diff.File) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\x00%s\x00%s\x00%s\x00", f.OldName, f.NewName, f.OldOID, f.NewOID)
+	for _, frag := range f.Fragments {
+		h.Write([]byte(frag.String()))
+	}
+	return fmt.Sprintf("%x", h.Sum(nil))
+}
+
+// New starts a session for ds.
+func New(ds *diff.DiffSet) *Session {
+	now := time.Now()
+	s := &Session{Version: Version, Created: now, Updated: now}
+	s.Record(&review.Session{Diff: ds, ReviewSession: model.ReviewSession{Decisions: model.NewDecisions()}})
+	return s
+}
+
+// Record replaces the session's contents with the state of r.
+func (s *Session) Record(r *review.Session) {
+	ds, decisions, results := r.Diff, r.Decisions, r.Results
+	s.Version = Version
+	s.DiffHash = Hash(ds.Raw)
+	s.Updated = time.Now()
+
+	s.Files = make([]File, len(ds.Files))
+	for i, f := range ds.Files {
+		n := len(f.Fragments)
+		s.Files[i] = File{
+			Name:     f.Name(),
+			Hash:     fileHash(f),
+			Decision: decisions.File(i, n).String(),
+			Note:     decisions.Note(i, model.WholeFile),
+			Bulk:     decisions.Bulk[i],
+
+			Conditions: decisions.Conditions[i],
+		}
+		if decisions.HunksDecided(i) {
+			noted := false
+			for h := range n {
+				s.Files[i].Hunks = append(s.Files[i].Hunks, decisions.Hunk(i, h).String())
+				s.Files[i].HunkNotes = append(s.Files[i].HunkNotes, decisions.Note(i, h))
+				noted = noted || decisions.Note(i, h) != ""
+			}
+			if !noted {
+				s.Files[i].HunkNotes = nil
+			}
+		}
+		s.Files[i].Labels = decisions.Label(i, model.WholeFile)
+		for h := range n {
+			if len(decisions.Label(i, h)) > 0 {
+				s.Files[i].HunkLabels = make([][]string, n)
+				for h := range n {
+					s.Files[i].HunkLabels[h] = decisions.Label(i, h)
+				}
+				break
+			}
+		}
+	}
+
+	s.Comments = nil
+	for _, c := range r.Comments {
+		s.Comments = append(s.Comments, Comment{File: c.File, Line: c.Line, Hunk: c.Hunk, Body: c.Body, Author: c.Author, Time: c.Time})
+	}
+
+	s.Checklist = nil
+	for _, c := range r.Checklist {
+		s.Checklist = append(s.Checklist, ChecklistItem{Item: c.Text, Done: c.Done})
+	}
+
+	s.Findings = nil
+	if results != nil {
+		for _, f := range results.Findings {
+			s.Findings = append(s.Findings, Finding{
+				Fingerprint: f.Fingerprint(),
+				Pass:        f.Pass,
+				Rule:        f.Rule,
+				File:        f.File,
+				Line:        f.Line,
+				Risk:        f.Risk.String(),
+				Message:     f.Message,
+			})
+			if f.State != model.FindingOpen {
+				s.Findings[len(s.Findings)-1].State = f.State.String()
+			}
+		}
+	}
+}
+
+// Restore gives r the session's decisions and comments on the files of its
+// diff whose changes are the ones they were made on, replacing r's own.
+// Files that changed since start over. Items of r's checklist that were
+// checked off stay checked off.
+func (s *Session) Restore(r *review.Session) {
+	ds := r.Diff
+	saved := make(map[string]File, len(s.Files))
+	for _, f := range s.Files {
+		saved[f.Name] = f
+	}
+
+	decisions := model.NewDecisions()
+	unchanged := make(map[string]bool)
+	for i, f := range ds.Files {
+		sf, ok := saved[f.Name()]
+		if !ok || sf.Hash != fileHash(f) {
+			continue
+		}
+		unchanged[sf.Name] = true
+		for _, l := range sf.Labels {
+			decisions.AddLabel(i, model.WholeFile, l)
+		}
+		for h, labels := range sf.HunkLabels {
+			for _, l := range labels {
+				if h < len(f.Fragments) {
+					decisions.AddLabel(i, h, l)
+				}
+			}
+		}
+		if len(sf.Hunks) == len(f.Fragments) && len(sf.Hunks) > 0 {
+			for h, name := range sf.Hunks {
+				if d, ok := model.ParseReviewDecision(name); ok && d != model.DecisionPending {
+					decisions.SetHunk(i, h, d)
+					if h < len(sf.HunkNotes) {
+						decisions.SetNote(i, h, sf.HunkNotes[h])
+					}
+				}
+			}
+			continue
+		}
+		if d, ok := model.ParseReviewDecision(sf.Decision); ok && d != model.DecisionPending && d != model.DecisionPartial {
+			if sf.Bulk {
+				decisions.SetBulk(i, d)
+			} else {
+				decisions.SetFile(i, d)
+			}
+			decisions.SetNote(i, model.WholeFile, sf.Note)
+			for _, c := range sf.Conditions {
+				decisions.AddCondition(i, c)
+			}
+		}
+	}
+
+	var comments []model.Comment
+	for _, c := range s.Comments {
+		if unchanged[c.File] {
+			comments = append(comments, model.Comment{File: c.File, Line: c.Line, Hunk: c.Hunk, Body: c.Body, Author: c.Author, Time: c.Time})
+		}
+	}
+	r.Decisions, r.Comments = decisions, comments
+
+	for _, sc := range s.Checklist {
+		for i := range r.Checklist {
+			if r.Checklist[i].Text == sc.Item && sc.Done {
+				r.Checklist[i].Done = true
+			}
+		}
+	}
+}
+
+// Load reads the repository's saved session. A missing file yields nil.
+func Load(repoDir string) (*Session, error) {
+	data, err := os.ReadFile(Path(repoDir))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading session: %w", err)
+	}
+	return Parse(data)
+}
+
+// Parse decodes a session, checking it is in a version this agrev reads.
+func Parse(data []byte) (*Session, error) {
+	var s Session
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("parsing session: %w", err)
+	}
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// Validate checks the session is in a version this agrev reads.
+func (s *Session) Validate() error {
+	if s.Version < 1 || s.Version > Version {
+		return fmt.Errorf("unsupported session version %d (this agrev reads up to %d)", s.Version, Version)
+	}
+	return nil
+}
+
+// Save writes the session to the repository's .agrev/ directory, replacing
+// the previous one. The file is written whole, then renamed into place, so
+// a reader never sees half of it.
+func (s *Session) Save(repoDir string) error {
+	dir, err := history.MakeDir(repoDir)
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, FileName+".*")
+	if err != nil {
+		return fmt.Errorf("writing session: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		return fmt.Errorf("writing session: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("writing session: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), Path(repoDir)); err != nil {
+		return fmt.Errorf("writing session: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,147 @@
+package session
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aezell/agrev/internal/analysis"
+	"github.com/aezell/agrev/internal/diff"
+	"github.com/aezell/agrev/internal/model"
+	"github.com/aezell/agrev/internal/review"
+)
+
+const testDiff = `diff --git a/main.go b/main.go
+--- a/main.go
++++ b/main.go
+@@ -1,3 +1,3 @@
+ package main
+-var a = 1
++var a = 2
+ func main() {}
+@@ -10,3 +10,3 @@
+ func f() {
+-	return
++	panic("no")
+ }
+diff --git a/util.go b/util.go
+--- a/util.go
++++ b/util.go
+@@ -1 +1 @@
+-package util
++package utils
+`
+
+func parse(t *testing.T, raw string) *diff.DiffSet {
+	t.Helper()
+	ds, err := diff.Parse(raw)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	return ds
+}
+
+func TestSaveAndLoad(t *testing.T) {
+	dir := t.TempDir()
+	if s, err := Load(dir); s != nil || err != nil {
+		t.Fatalf("expected no saved session, got %+v, %v", s, err)
+	}
+
+	ds := parse(t, testDiff)
+	decisions := model.NewDecisions()
+	decisions.SetHunk(0, 0, model.DecisionApproved)
+	decisions.SetHunk(0, 1, model.DecisionRejected)
+	decisions.SetNote(0, 1, "keep returning")
+	decisions.SetBulk(1, model.DecisionApproved)
+	decisions.AddCondition(1, "add a test for the helper")
+	decisions.AddLabel(0, 1, "refactor-only")
+	decisions.AddLabel(1, model.WholeFile, "needs-security-review")
+	when := time.Date(2026, 3, 4, 10, 0, 0, 0, time.UTC)
+	comments := []model.Comment{{File: "main.go", Line: 2, Hunk: 1, Body: "why?", Author: "ada", Time: when}}
+	results := &analysis.Results{Findings: []analysis.Finding{
+		{Pass: "security", File: "main.go", Line: 11, Message: "panic", Risk: model.RiskMedium},
+	}}
+
+	r := review.New(ds, nil, results)
+	r.Decisions, r.Comments = decisions, comments
+	r.Checklist = []model.ChecklistItem{{Text: "ran it locally", Done: true}, {Text: "read the docs"}}
+	s := New(ds)
+	s.Record(r)
+	if err := s.Save(dir); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	loaded, err := Load(dir)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if loaded.Version != Version || loaded.DiffHash != Hash(testDiff) {
+		t.Errorf("unexpected header %+v", loaded)
+	}
+	if f := loaded.Files[0]; f.Name != "main.go" || f.Decision != "partial" || strings.Join(f.Hunks, ",") != "approved,rejected" ||
+		len(f.HunkNotes) != 2 || f.HunkNotes[1] != "keep returning" || len(f.HunkLabels) != 2 || f.HunkLabels[0] != nil || f.Labels != nil {
+		t.Errorf("unexpected main.go %+v", f)
+	}
+	if f := loaded.Files[1]; f.Decision != "approved" || f.Hunks != nil || !f.Bulk || len(f.Conditions) != 1 || len(f.Labels) != 1 || f.HunkLabels != nil {
+		t.Errorf("unexpected util.go %+v", f)
+	}
+	if len(loaded.Findings) != 1 || loaded.Findings[0].Fingerprint != results.Findings[0].Fingerprint() || loaded.Findings[0].Risk != "medium" {
+		t.Errorf("unexpected findings %+v", loaded.Findings)
+	}
+
+	if len(loaded.Checklist) != 2 || !loaded.Checklist[0].Done || loaded.Checklist[1].Done {
+		t.Errorf("unexpected checklist %+v", loaded.Checklist)
+	}
+
+	onto := review.New(ds, nil, nil)
+	onto.Checklist = []model.ChecklistItem{{Text: "read the docs"}, {Text: "ran it locally"}}
+	loaded.Restore(onto)
+	if onto.Checklist[0].Done || !onto.Checklist[1].Done {
+		t.Errorf("expected the checked item restored, got %+v", onto.Checklist)
+	}
+	restored, restoredComments := onto.Decisions, onto.Comments
+	if restored.File(0, 2) != model.DecisionPartial || restored.Hunk(0, 1) != model.DecisionRejected || restored.File(1, 1) != model.DecisionApproved ||
+		restored.Note(0, 1) != "keep returning" || !restored.Bulk[1] || !slices.Equal(restored.Conditions[1], []string{"add a test for the helper"}) ||
+		!slices.Equal(restored.Label(0, 1), []string{"refactor-only"}) || !slices.Equal(restored.Label(1, model.WholeFile), []string{"needs-security-review"}) {
+		t.Errorf("unexpected restored decisions %+v", restored)
+	}
+	if len(restoredComments) != 1 || restoredComments[0] != comments[0] {
+		t.Errorf("unexpected restored comments %+v", restoredComments)
+	}
+}
+
+func TestRestoreSkipsChangedFiles(t *testing.T) {
+	ds := parse(t, testDiff)
+	decisions := model.NewDecisions()
+	decisions.SetFile(0, model.DecisionApproved)
+	decisions.SetFile(1, model.DecisionRejected)
+	r := review.New(ds, nil, nil)
+	r.Decisions, r.Comments = decisions, []model.Comment{{File: "util.go", Body: "rename"}}
+	s := New(ds)
+	s.Record(r)
+
+	// util.go changed since, main.go didn't
+	changed := parse(t, strings.Replace(testDiff, "+package utils", "+package helpers", 1))
+	onto := review.New(changed, nil, nil)
+	s.Restore(onto)
+	restored, comments := onto.Decisions, onto.Comments
+	if restored.Files[0] != model.DecisionApproved {
+		t.Errorf("expected main.go still approved, got %+v", restored)
+	}
+	if _, ok := restored.Files[1]; ok || len(comments) != 0 {
+		t.Errorf("expected util.go to start over, got %+v, %+v", restored, comments)
+	}
+}
+
+func TestParseVersion(t *testing.T) {
+	if _, err := Parse([]byte(`{"version": 2, "files": []}`)); err == nil {
+		t.Error("expected a newer version to be refused")
+	}
+	if _, err := Parse([]byte(`{"files": []}`)); err == nil {
+		t.Error("expected a missing version to be refused")
+	}
+	if s, err := Parse([]byte(`{"version": 1, "files": []}`)); err != nil || s.Version != 1 {
+		t.Errorf("expected version 1 to load, got %+v, %v", s, err)
+	}
+}
@@ -28,10 +28,12 @@ type claudeMessage struct {
 type claudeContentBlock struct {
 	Type      string          `json:"type"`
 	Text      string          `json:"text"`
+	ID        string          `json:"id"`          // for tool_use
 	Name      string          `json:"name"`       // tool name for tool_use
 	Input     json.RawMessage `json:"input"`       // tool input for tool_use
 	ToolUseID string          `json:"tool_use_id"` // for tool_result
 	Content   json.RawMessage `json:"content"`     // for tool_result
+	IsError   bool            `json:"is_error"`    // for tool_result
 }
 
 // Tool input types
@@ -73,6 +75,7 @@ func parseClaudeReader(r io.Reader, source string) (*Trace, error) {
 
 	filesSet := make(map[string]bool)
 	var reasoningParts []string
+	toolSteps := make(map[string]int) // tool_use ID -> index in trace.Steps
 
 	scanner := bufio.NewScanner(r)
 	scanner.Buffer(make([]byte, 0, 1024*1024), 10*1024*1024) // 10MB max line
@@ -103,13 +106,18 @@ func parseClaudeReader(r io.Reader, source string) (*Trace, error) {
 
 		switch entry.Type {
 		case "user":
-			step := parseUserEntry(entry, ts)
+			step := parseUserEntry(entry, ts, trace, toolSteps)
 			if step != nil {
 				trace.Steps = append(trace.Steps, *step)
 			}
 
 		case "assistant":
-			steps := parseAssistantEntry(entry, ts, filesSet, &reasoningParts)
+			steps, ids := parseAssistantEntry(entry, ts, filesSet, &reasoningParts)
+			for i, id := range ids {
+				if id != "" {
+					toolSteps[id] = len(trace.Steps) + i
+				}
+			}
 			trace.Steps = append(trace.Steps, steps...)
 		}
 	}
@@ -129,7 +137,10 @@ func parseClaudeReader(r io.Reader, source string) (*Trace, error) {
 	return trace, nil
 }
 
-func parseUserEntry(entry claudeEntry, ts time.Time) *Step {
+// parseUserEntry returns the step for a user message. Tool results also
+// arrive as user entries; those are attached to the bash step that produced
+// them instead.
+func parseUserEntry(entry claudeEntry, ts time.Time, trace *Trace, toolSteps map[string]int) *Step {
 	if len(entry.Message) == 0 {
 		return nil
 	}
@@ -150,19 +161,60 @@ func parseUserEntry(entry claudeEntry, ts time.Time) *Step {
 				Detail:    text,
 			}
 		}
+		return nil
+	}
+
+	var blocks []claudeContentBlock
+	if err := json.Unmarshal(msg.Content, &blocks); err != nil {
+		return nil
+	}
+	for _, block := range blocks {
+		if block.Type != "tool_result" {
+			continue
+		}
+		i, ok := toolSteps[block.ToolUseID]
+		if !ok || trace.Steps[i].Type != StepBash {
+			continue
+		}
+		trace.Steps[i].Output = toolResultText(block.Content)
+		if block.IsError && trace.Steps[i].ExitCode == 0 {
+			trace.Steps[i].ExitCode = 1
+		}
 	}
 
 	return nil
 }
 
-func parseAssistantEntry(entry claudeEntry, ts time.Time, filesSet map[string]bool, reasoning *[]string) []Step {
+// toolResultText extracts the text of a tool_result, which is either a string
+// or an array of text blocks.
+func toolResultText(raw json.RawMessage) string {
+	var text string
+	if err := json.Unmarshal(raw, &text); err == nil {
+		return text
+	}
+	var blocks []claudeContentBlock
+	if err := json.Unmarshal(raw, &blocks); err != nil {
+		return ""
+	}
+	var parts []string
+	for _, b := range blocks {
+		if b.Type == "text" {
+			parts = append(parts, b.Text)
+		}
+	}
+	return strings.Join(parts, "\n")
+}
+
+// parseAssistantEntry returns the steps for an assistant message along with
+// the tool_use ID of each step ("" for steps that aren't tool calls).
+func parseAssistantEntry(entry claudeEntry, ts time.Time, filesSet map[string]bool, reasoning *[]string) ([]Step, []string) {
 	if len(entry.Message) == 0 {
-		return nil
+		return nil, nil
 	}
 
 	var msg claudeMessage
 	if err := json.Unmarshal(entry.Message, &msg); err != nil {
-		return nil
+		return nil, nil
 	}
 
 	// Content might be a string
@@ -175,18 +227,19 @@ func parseAssistantEntry(entry claudeEntry, ts time.Time, filesSet map[string]bo
 				Timestamp: ts,
 				Summary:   truncateStr(textContent, 100),
 				Detail:    textContent,
-			}}
+			}}, []string{""}
 		}
-		return nil
+		return nil, nil
 	}
 
 	// Content is an array of blocks
 	var blocks []claudeContentBlock
 	if err := json.Unmarshal(msg.Content, &blocks); err != nil {
-		return nil
+		return nil, nil
 	}
 
 	var steps []Step
+	var ids []string
 
 	for _, block := range blocks {
 		switch block.Type {
@@ -199,17 +252,19 @@ func parseAssistantEntry(entry claudeEntry, ts time.Time, filesSet map[string]bo
 					Summary:   truncateStr(block.Text, 100),
 					Detail:    block.Text,
 				})
+				ids = append(ids, "")
 			}
 
 		case "tool_use":
 			step := parseToolUse(block, ts, filesSet)
 			if step != nil {
 				steps = append(steps, *step)
+				ids = append(ids, block.ID)
 			}
 		}
 	}
 
-	return steps
+	return steps, ids
 }
 
 func parseToolUse(block claudeContentBlock, ts time.Time, filesSet map[string]bool) *Step {
@@ -223,7 +278,7 @@ func parseToolUse(block claudeContentBlock, ts time.Time, filesSet map[string]bo
 				Timestamp: ts,
 				FilePath:  inp.FilePath,
 				Summary:   fmt.Sprintf("Write %s", shortPath(inp.FilePath)),
-				Detail:    truncateStr(inp.Content, 500),
+				Detail:    inp.Content,
 			}
 		}
 
@@ -236,7 +291,9 @@ func parseToolUse(block claudeContentBlock, ts time.Time, filesSet map[string]bo
 				Timestamp: ts,
 				FilePath:  inp.FilePath,
 				Summary:   fmt.Sprintf("Edit %s", shortPath(inp.FilePath)),
-				Detail:    fmt.Sprintf("-%s\n+%s", truncateStr(inp.OldString, 200), truncateStr(inp.NewString, 200)),
+				Detail:    fmt.Sprintf("-%s\n+%s", inp.OldString, inp.NewString),
+				OldString: inp.OldString,
+				NewString: inp.NewString,
 			}
 		}
 
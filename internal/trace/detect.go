@@ -55,6 +55,16 @@ func Detect(repoDir string) (path, format string) {
 }
 
 func detectClaudeCode(repoDir string) string {
+	dir := claudeProjectDir(repoDir)
+	if dir == "" {
+		return ""
+	}
+	return mostRecentJSONL(dir)
+}
+
+// claudeProjectDir returns the directory Claude Code keeps the repository's
+// session traces in, or "" if there is none.
+func claudeProjectDir(repoDir string) string {
 	home, err := os.UserHomeDir()
 	if err != nil {
 		return ""
@@ -106,12 +116,7 @@ func detectClaudeCode(repoDir string) string {
 		}
 	}
 
-	if matchingDir == "" {
-		return ""
-	}
-
-	// Find the most recent JSONL file
-	return mostRecentJSONL(matchingDir)
+	return matchingDir
 }
 
 func mostRecentJSONL(dir string) string {
@@ -11,9 +11,9 @@ import (
 // Generic JSONL trace format:
 //   {"type": "plan", "content": "I'll add rate limiting..."}
 //   {"type": "file_read", "path": "api/middleware.go"}
-//   {"type": "file_edit", "path": "api/middleware.go", "description": "Add RateLimiter struct"}
+//   {"type": "file_edit", "path": "api/middleware.go", "description": "Add RateLimiter struct", "old_string": "...", "new_string": "..."}
 //   {"type": "file_write", "path": "api/middleware.go", "description": "Create new file"}
-//   {"type": "bash", "command": "go test ./...", "exit_code": 0}
+//   {"type": "bash", "command": "go test ./...", "exit_code": 0, "output": "ok ..."}
 //   {"type": "reasoning", "content": "Tests pass. Now I need to..."}
 
 type genericEntry struct {
@@ -23,6 +23,9 @@ type genericEntry struct {
 	Description string `json:"description"`
 	Command     string `json:"command"`
 	ExitCode    int    `json:"exit_code"`
+	Output      string `json:"output"`
+	OldString   string `json:"old_string"`
+	NewString   string `json:"new_string"`
 	Timestamp   string `json:"timestamp"`
 }
 
@@ -117,6 +120,8 @@ func parseGenericReader(r io.Reader) (*Trace, error) {
 				FilePath:  entry.Path,
 				Summary:   summary,
 				Detail:    entry.Content,
+				OldString: entry.OldString,
+				NewString: entry.NewString,
 			})
 
 		case "bash":
@@ -125,6 +130,7 @@ func parseGenericReader(r io.Reader) (*Trace, error) {
 				Timestamp: ts,
 				Command:   entry.Command,
 				ExitCode:  entry.ExitCode,
+				Output:    entry.Output,
 				Summary:   truncateStr(entry.Command, 80),
 				Detail:    entry.Command,
 			})
@@ -0,0 +1,52 @@
+package trace
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Session is a trace file found for a repository.
+type Session struct {
+	Path    string
+	Format  string // "claude-code", "aider", "generic"
+	ModTime time.Time
+	Size    int64
+}
+
+// ID returns the file name without its extension, which for Claude Code
+// traces is the session ID.
+func (s Session) ID() string {
+	return strings.TrimSuffix(filepath.Base(s.Path), filepath.Ext(s.Path))
+}
+
+// Sessions lists every trace Detect could choose from for a repository,
+// most recently modified first.
+func Sessions(repoDir string) []Session {
+	var sessions []Session
+	add := func(path, format string) {
+		info, err := os.Stat(path)
+		if err != nil || info.IsDir() {
+			return
+		}
+		sessions = append(sessions, Session{Path: path, Format: format, ModTime: info.ModTime(), Size: info.Size()})
+	}
+
+	if dir := claudeProjectDir(repoDir); dir != "" {
+		entries, _ := os.ReadDir(dir)
+		for _, e := range entries {
+			if strings.HasSuffix(e.Name(), ".jsonl") {
+				add(filepath.Join(dir, e.Name()), "claude-code")
+			}
+		}
+	}
+	add(filepath.Join(repoDir, ".aider.chat.history.md"), "aider")
+	add(filepath.Join(repoDir, ".agrev-trace.jsonl"), "generic")
+
+	sort.SliceStable(sessions, func(i, j int) bool {
+		return sessions[i].ModTime.After(sessions[j].ModTime)
+	})
+	return sessions
+}
@@ -0,0 +1,113 @@
+package trace
+
+import (
+	"sort"
+	"time"
+)
+
+// Stats summarizes what an agent did in a trace.
+type Stats struct {
+	Steps    int
+	Duration time.Duration // first to last timestamp; 0 without timestamps
+
+	// Types lists step types in StepType order, skipping absent ones.
+	Types []TypeStats
+
+	Commands       int
+	FailedCommands int // non-zero exit status
+
+	// Files lists the files the agent touched, most active first.
+	Files []FileStats
+}
+
+// TypeStats counts one step type. Duration is the time from each step of
+// this type to the next step, a rough measure of where the session went.
+type TypeStats struct {
+	Type     StepType
+	Count    int
+	Duration time.Duration
+}
+
+// FileStats counts the steps touching one file.
+type FileStats struct {
+	Path   string
+	Reads  int
+	Writes int
+	Edits  int
+}
+
+// Total is the number of steps touching the file.
+func (f FileStats) Total() int {
+	return f.Reads + f.Writes + f.Edits
+}
+
+// Stats computes statistics over the trace's steps.
+func (t *Trace) Stats() Stats {
+	s := Stats{Steps: len(t.Steps)}
+
+	types := make(map[StepType]*TypeStats)
+	files := make(map[string]*FileStats)
+	var first, last time.Time
+	for i, step := range t.Steps {
+		ts := types[step.Type]
+		if ts == nil {
+			ts = &TypeStats{Type: step.Type}
+			types[step.Type] = ts
+		}
+		ts.Count++
+		if i+1 < len(t.Steps) && !step.Timestamp.IsZero() {
+			if next := t.Steps[i+1].Timestamp; next.After(step.Timestamp) {
+				ts.Duration += next.Sub(step.Timestamp)
+			}
+		}
+		if !step.Timestamp.IsZero() {
+			if first.IsZero() || step.Timestamp.Before(first) {
+				first = step.Timestamp
+			}
+			if step.Timestamp.After(last) {
+				last = step.Timestamp
+			}
+		}
+
+		if step.Type == StepBash {
+			s.Commands++
+			if step.ExitCode != 0 {
+				s.FailedCommands++
+			}
+		}
+
+		if step.FilePath == "" {
+			continue
+		}
+		fs := files[step.FilePath]
+		if fs == nil {
+			fs = &FileStats{Path: step.FilePath}
+			files[step.FilePath] = fs
+		}
+		switch step.Type {
+		case StepFileRead:
+			fs.Reads++
+		case StepFileWrite:
+			fs.Writes++
+		case StepFileEdit:
+			fs.Edits++
+		}
+	}
+	s.Duration = last.Sub(first)
+
+	for st := StepPlan; st <= StepUserMessage; st++ {
+		if ts := types[st]; ts != nil {
+			s.Types = append(s.Types, *ts)
+		}
+	}
+	for _, fs := range files {
+		s.Files = append(s.Files, *fs)
+	}
+	sort.Slice(s.Files, func(i, j int) bool {
+		if s.Files[i].Total() != s.Files[j].Total() {
+			return s.Files[i].Total() > s.Files[j].Total()
+		}
+		return s.Files[i].Path < s.Files[j].Path
+	})
+	return s
+}
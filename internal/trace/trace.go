@@ -1,7 +1,11 @@
 // Package trace handles ingestion and parsing of agent conversation traces.
 package trace
 
-import "time"
+import (
+	"path/filepath"
+	"strings"
+	"time"
+)
 
 // StepType categorizes a step in the agent's workflow.
 type StepType int
@@ -50,15 +54,29 @@ type Step struct {
 	// File-related fields (for read/write/edit steps)
 	FilePath string
 
+	// Edit-related fields: the exact text replaced, when the trace records it
+	OldString string
+	NewString string
+
 	// Bash-related fields
 	Command  string
 	ExitCode int
+	Output   string // command output, when the trace records it
 
 	// For correlation with diff hunks
 	LineStart int // 0 if unknown
 	LineEnd   int // 0 if unknown
 }
 
+// Touches reports whether the step touches the diff file name. Traces may
+// record absolute paths, so base names and suffixes match too.
+func (s Step) Touches(name string) bool {
+	if s.FilePath == "" {
+		return false
+	}
+	return filepath.Base(s.FilePath) == filepath.Base(name) || strings.HasSuffix(s.FilePath, name)
+}
+
 // Trace is the parsed representation of an agent conversation.
 type Trace struct {
 	Source    string    // "claude-code", "aider", "generic"
@@ -1,8 +1,11 @@
 package trace
 
 import (
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestParseClaudeCode(t *testing.T) {
@@ -77,6 +80,35 @@ func TestParseClaudeCode(t *testing.T) {
 	}
 }
 
+func TestParseClaudeCodeToolDetail(t *testing.T) {
+	jsonl := `{"type":"assistant","timestamp":"2026-01-15T10:00:15Z","message":{"role":"assistant","content":[{"type":"tool_use","id":"toolu_1","name":"Edit","input":{"file_path":"/app/main.go","old_string":"// routes","new_string":"router.Handle(\"/login\", loginHandler)"}}]}}
+{"type":"assistant","timestamp":"2026-01-15T10:00:20Z","message":{"role":"assistant","content":[{"type":"text","text":"Running tests."},{"type":"tool_use","id":"toolu_2","name":"Bash","input":{"command":"go test ./..."}}]}}
+{"type":"user","timestamp":"2026-01-15T10:00:22Z","message":{"role":"user","content":[{"type":"tool_result","tool_use_id":"toolu_2","is_error":true,"content":[{"type":"text","text":"--- FAIL: TestLogin"},{"type":"text","text":"FAIL"}]}]}}
+{"type":"user","timestamp":"2026-01-15T10:00:23Z","message":{"role":"user","content":[{"type":"tool_result","tool_use_id":"toolu_1","content":"The file has been updated."}]}}
+`
+
+	trace, err := parseClaudeReader(strings.NewReader(jsonl), "test")
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if len(trace.Steps) != 3 {
+		t.Fatalf("expected 3 steps, got %d", len(trace.Steps))
+	}
+
+	edit := trace.Steps[0]
+	if edit.OldString != "// routes" || edit.NewString != `router.Handle("/login", loginHandler)` {
+		t.Errorf("edit step: unexpected old/new %q / %q", edit.OldString, edit.NewString)
+	}
+
+	bash := trace.Steps[2]
+	if bash.Output != "--- FAIL: TestLogin\nFAIL" {
+		t.Errorf("bash step: unexpected output %q", bash.Output)
+	}
+	if bash.ExitCode == 0 {
+		t.Error("bash step: expected non-zero exit code for error result")
+	}
+}
+
 func TestParseGenericJSONL(t *testing.T) {
 	jsonl := `{"type":"plan","content":"I'll add rate limiting using a token bucket"}
 {"type":"file_read","path":"api/middleware.go"}
@@ -183,3 +215,78 @@ func TestDetectNoRepo(t *testing.T) {
 func writeTestFile(path, content string) error {
 	return writeFile(path, content)
 }
+
+func TestStats(t *testing.T) {
+	jsonl := `{"type":"plan","content":"add rate limiting","timestamp":"2026-01-15T10:00:00Z"}
+{"type":"file_read","path":"api/middleware.go","timestamp":"2026-01-15T10:00:10Z"}
+{"type":"file_edit","path":"api/middleware.go","description":"Add RateLimiter","timestamp":"2026-01-15T10:01:10Z"}
+{"type":"bash","command":"go test ./...","exit_code":1,"timestamp":"2026-01-15T10:01:20Z"}
+{"type":"file_edit","path":"api/middleware.go","description":"Fix test","timestamp":"2026-01-15T10:02:00Z"}
+{"type":"bash","command":"go test ./...","exit_code":0,"timestamp":"2026-01-15T10:02:30Z"}
+{"type":"file_write","path":"api/ratelimit.go","timestamp":"2026-01-15T10:03:00Z"}
+`
+	tr, err := parseGenericReader(strings.NewReader(jsonl))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	s := tr.Stats()
+
+	if s.Steps != 7 || s.Duration != 3*time.Minute {
+		t.Errorf("expected 7 steps over 3m, got %d over %v", s.Steps, s.Duration)
+	}
+	if s.Commands != 2 || s.FailedCommands != 1 {
+		t.Errorf("expected 2 commands with 1 failure, got %d/%d", s.Commands, s.FailedCommands)
+	}
+
+	want := []TypeStats{
+		{StepPlan, 1, 10 * time.Second},
+		{StepFileRead, 1, time.Minute},
+		{StepFileWrite, 1, 0},
+		{StepFileEdit, 2, 40 * time.Second},
+		{StepBash, 2, 70 * time.Second},
+	}
+	if len(s.Types) != len(want) {
+		t.Fatalf("expected %d step types, got %+v", len(want), s.Types)
+	}
+	for i := range want {
+		if s.Types[i] != want[i] {
+			t.Errorf("Types[%d] = %+v, want %+v", i, s.Types[i], want[i])
+		}
+	}
+
+	if len(s.Files) != 2 || s.Files[0] != (FileStats{Path: "api/middleware.go", Reads: 1, Edits: 2}) {
+		t.Errorf("expected middleware.go most active, got %+v", s.Files)
+	}
+}
+
+func TestSessions(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	repo := t.TempDir()
+
+	project := filepath.Join(home, ".claude", "projects", strings.ReplaceAll(repo, "/", "-"))
+	if err := os.MkdirAll(project, 0755); err != nil {
+		t.Fatal(err)
+	}
+	old := filepath.Join(project, "older-session.jsonl")
+	recent := filepath.Join(project, "recent-session.jsonl")
+	aider := filepath.Join(repo, ".aider.chat.history.md")
+	for i, p := range []string{old, aider, recent} {
+		if err := writeTestFile(p, "{}\n"); err != nil {
+			t.Fatal(err)
+		}
+		mtime := time.Date(2026, 1, 1+i, 0, 0, 0, 0, time.UTC)
+		os.Chtimes(p, mtime, mtime)
+	}
+
+	sessions := Sessions(repo)
+	if len(sessions) != 3 {
+		t.Fatalf("expected 3 sessions, got %+v", sessions)
+	}
+	if sessions[0].ID() != "recent-session" || sessions[1].Format != "aider" || sessions[2].Path != old {
+		t.Errorf("expected sessions newest first, got %+v", sessions)
+	}
+	if path, _ := Detect(repo); path != recent {
+		t.Errorf("expected Detect to pick the newest Claude Code session, got %q", path)
+	}
+}
@@ -0,0 +1,225 @@
+// Package triage records what reviewers concluded about analysis findings
+// in .agrev/findings.json: that a finding was acknowledged, is a false
+// positive, or was fixed. Findings are kept by fingerprint, so later runs
+// on the same code suppress false positives and down-rank acknowledged
+// findings.
+package triage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/aezell/agrev/internal/analysis"
+	"github.com/aezell/agrev/internal/history"
+	"github.com/aezell/agrev/internal/model"
+)
+
+// Version is the version of the format Save writes.
+const Version = 1
+
+// FileName is the triage file inside history.Dir.
+const FileName = "findings.json"
+
+// Store holds the findings reviewers triaged, one entry per fingerprint.
+type Store struct {
+	Version  int     `json:"version"`
+	Findings []Entry `json:"findings"`
+}
+
+// Entry is a reviewer's conclusion about a finding, with enough detail to
+// report on it without running the analysis again.
+type Entry struct {
+	Fingerprint string    `json:"fingerprint"`
+	State       string    `json:"state" enum:"acknowledged,false_positive,fixed"`
+	Pass        string    `json:"pass"`
+	File        string    `json:"file"`
+	Risk        string    `json:"risk"` // as the analysis rated it
+	Message     string    `json:"message"`
+	Author      string    `json:"author,omitempty"`
+	Time        time.Time `json:"time"`
+}
+
+// Path returns the triage file for a repository.
+func Path(repoDir string) string {
+	return filepath.Join(repoDir, history.Dir, FileName)
+}
+
+// Load reads the repository's triaged findings. A missing file yields an
+// empty store.
+func Load(repoDir string) (*Store, error) {
+	data, err := os.ReadFile(Path(repoDir))
+	if errors.Is(err, fs.ErrNotExist) {
+		return &Store{Version: Version}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading triaged findings: %w", err)
+	}
+	var s Store
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("parsing triaged findings: %w", err)
+	}
+	if s.Version < 1 || s.Version > Version {
+		return nil, fmt.Errorf("unsupported triage version %d (this agrev reads up to %d)", s.Version, Version)
+	}
+	return &s, nil
+}
+
+// Save writes the store to the repository's .agrev/ directory, replacing
+// the file whole, as session.Save does.
+func (s *Store) Save(repoDir string) error {
+	dir, err := history.MakeDir(repoDir)
+	if err != nil {
+		return err
+	}
+	s.Version = Version
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, FileName+".*")
+	if err != nil {
+		return fmt.Errorf("writing triaged findings: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		return fmt.Errorf("writing triaged findings: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("writing triaged findings: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), Path(repoDir)); err != nil {
+		return fmt.Errorf("writing triaged findings: %w", err)
+	}
+	return nil
+}
+
+// State returns what reviewers concluded about the finding with the given
+// fingerprint. A nil Store has every finding open.
+func (s *Store) State(fingerprint string) model.FindingState {
+	if s == nil {
+		return model.FindingOpen
+	}
+	for _, e := range s.Findings {
+		if e.Fingerprint == fingerprint {
+			st, _ := model.ParseFindingState(e.State)
+			return st
+		}
+	}
+	return model.FindingOpen
+}
+
+// Set records a reviewer's conclusion about f, replacing any earlier one.
+// Setting it open forgets the finding.
+func (s *Store) Set(f analysis.Finding, state model.FindingState, author string) {
+	fp := f.Fingerprint()
+	risk := f.Risk.String()
+	for i, e := range s.Findings {
+		if e.Fingerprint == fp {
+			risk = e.Risk // f may have been down-ranked by it
+			s.Findings = append(s.Findings[:i], s.Findings[i+1:]...)
+			break
+		}
+	}
+	if state == model.FindingOpen {
+		return
+	}
+	s.Findings = append(s.Findings, Entry{
+		Fingerprint: fp,
+		State:       state.String(),
+		Pass:        f.Pass,
+		File:        f.File,
+		Risk:        risk,
+		Message:     f.Message,
+		Author:      author,
+		Time:        time.Now(),
+	})
+}
+
+// Apply gives each of the results' findings its state. False positives
+// move to Suppressed, and acknowledged findings are down-ranked to info so
+// they no longer count towards exit codes and gates. Findings marked fixed
+// that come up again keep their risk. A nil Store or Results does nothing.
+func (s *Store) Apply(r *analysis.Results) {
+	if s == nil || r == nil || len(s.Findings) == 0 {
+		return
+	}
+	var kept []analysis.Finding
+	for _, f := range r.Findings {
+		f.State = s.State(f.Fingerprint())
+		switch f.State {
+		case model.FindingFalsePositive:
+			r.Suppressed = append(r.Suppressed, f)
+			continue
+		case model.FindingAcknowledged:
+			f.Risk = model.RiskInfo
+		}
+		kept = append(kept, f)
+	}
+	r.Findings = kept
+}
+
+// Summary counts the triaged findings, overall and per pass.
+type Summary struct {
+	Acknowledged   int          `json:"acknowledged"`
+	FalsePositives int          `json:"false_positives"`
+	Fixed          int          `json:"fixed"`
+	Passes         []PassCounts `json:"passes,omitempty"` // by triaged findings, most first
+}
+
+// PassCounts counts one pass's triaged findings.
+type PassCounts struct {
+	Pass           string `json:"pass"`
+	Acknowledged   int    `json:"acknowledged"`
+	FalsePositives int    `json:"false_positives"`
+	Fixed          int    `json:"fixed"`
+}
+
+func (c PassCounts) total() int { return c.Acknowledged + c.FalsePositives + c.Fixed }
+
+// Summarize counts the findings triaged at or after since.
+func (s *Store) Summarize(since time.Time) Summary {
+	var sum Summary
+	if s == nil {
+		return sum
+	}
+	passes := make(map[string]*PassCounts)
+	for _, e := range s.Findings {
+		if e.Time.Before(since) {
+			continue
+		}
+		p := passes[e.Pass]
+		if p == nil {
+			p = &PassCounts{Pass: e.Pass}
+			passes[e.Pass] = p
+		}
+		st, _ := model.ParseFindingState(e.State)
+		switch st {
+		case model.FindingAcknowledged:
+			sum.Acknowledged++
+			p.Acknowledged++
+		case model.FindingFalsePositive:
+			sum.FalsePositives++
+			p.FalsePositives++
+		case model.FindingFixed:
+			sum.Fixed++
+			p.Fixed++
+		}
+	}
+	for _, p := range passes {
+		sum.Passes = append(sum.Passes, *p)
+	}
+	sort.Slice(sum.Passes, func(i, j int) bool {
+		if a, b := sum.Passes[i].total(), sum.Passes[j].total(); a != b {
+			return a > b
+		}
+		return sum.Passes[i].Pass < sum.Passes[j].Pass
+	})
+	return sum
+}
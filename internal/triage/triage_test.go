@@ -0,0 +1,88 @@
+package triage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aezell/agrev/internal/analysis"
+	"github.com/aezell/agrev/internal/model"
+)
+
+func findings() []analysis.Finding {
+	return []analysis.Finding{
+		{Pass: "security", File: "auth.go", Line: 3, Message: "reads env var", Risk: model.RiskHigh},
+		{Pass: "anti_patterns", File: "util.go", Line: 7, Message: "TODO left in", Risk: model.RiskLow},
+		{Pass: "schema", File: "db.sql", Line: 1, Message: "drops table", Risk: model.RiskCritical},
+		{Pass: "deps", File: "go.mod", Line: 5, Message: "new dependency", Risk: model.RiskMedium},
+	}
+}
+
+func TestApply(t *testing.T) {
+	s := &Store{Version: Version}
+	fs := findings()
+	s.Set(fs[0], model.FindingAcknowledged, "ada")
+	s.Set(fs[1], model.FindingFalsePositive, "ada")
+	s.Set(fs[2], model.FindingFixed, "ada")
+	s.Set(fs[3], model.FindingFalsePositive, "ada")
+	s.Set(fs[3], model.FindingOpen, "ada") // reopened
+
+	// A later run, with lines moved
+	r := &analysis.Results{Findings: findings()}
+	for i := range r.Findings {
+		r.Findings[i].Line += 10
+	}
+	s.Apply(r)
+
+	if len(r.Findings) != 3 || len(r.Suppressed) != 1 || r.Suppressed[0].Pass != "anti_patterns" {
+		t.Fatalf("expected the false positive suppressed, got %+v / %+v", r.Findings, r.Suppressed)
+	}
+	if f := r.Findings[0]; f.State != model.FindingAcknowledged || f.Risk != model.RiskInfo {
+		t.Errorf("expected the acknowledged finding down-ranked, got %+v", f)
+	}
+	if f := r.Findings[1]; f.State != model.FindingFixed || f.Risk != model.RiskCritical {
+		t.Errorf("expected the fixed finding back at full risk, got %+v", f)
+	}
+	if f := r.Findings[2]; f.State != model.FindingOpen {
+		t.Errorf("expected the reopened finding open, got %+v", f)
+	}
+}
+
+func TestSaveLoad(t *testing.T) {
+	dir := t.TempDir()
+	s, err := Load(dir)
+	if err != nil || len(s.Findings) != 0 {
+		t.Fatalf("expected an empty store, got %+v, %v", s, err)
+	}
+	s.Set(findings()[0], model.FindingFalsePositive, "ada")
+	if err := s.Save(dir); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	loaded, err := Load(dir)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if st := loaded.State(findings()[0].Fingerprint()); st != model.FindingFalsePositive {
+		t.Errorf("expected the state to survive a round trip, got %v", st)
+	}
+	if e := loaded.Findings[0]; e.Author != "ada" || e.Risk != "high" {
+		t.Errorf("unexpected entry %+v", e)
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	s := &Store{Version: Version}
+	fs := findings()
+	s.Set(fs[0], model.FindingFalsePositive, "")
+	s.Set(fs[1], model.FindingAcknowledged, "")
+	s.Set(fs[2], model.FindingFixed, "")
+	s.Findings[2].Time = time.Now().AddDate(0, -2, 0)
+
+	sum := s.Summarize(time.Now().AddDate(0, -1, 0))
+	if sum.FalsePositives != 1 || sum.Acknowledged != 1 || sum.Fixed != 0 || len(sum.Passes) != 2 {
+		t.Errorf("unexpected summary %+v", sum)
+	}
+	if sum.Passes[0].Pass != "anti_patterns" || sum.Passes[1].FalsePositives != 1 {
+		t.Errorf("expected passes by name on a tie, got %+v", sum.Passes)
+	}
+}
@@ -0,0 +1,66 @@
+package tui
+
+import (
+	"github.com/charmbracelet/lipgloss"
+	"github.com/aezell/agrev/internal/annotate"
+	"github.com/aezell/agrev/internal/model"
+)
+
+// annotateLines puts the trace's annotations on the current file (see
+// package annotate) above the first line they cover, and those on the
+// whole file, or on lines the view doesn't show, at the top.
+func (m *Model) annotateLines(lines []renderedLine) []renderedLine {
+	if m.trace == nil || len(m.diffSet.Files) == 0 {
+		return lines
+	}
+	anns := annotate.File(m.diffSet.Files[m.fileIndex], m.trace)
+	if len(anns) == 0 {
+		return lines
+	}
+
+	var top, result []renderedLine
+	k := 0
+	for k < len(anns) && anns[k].Range.Start == 0 {
+		top = append(top, annotationLine(anns[k], -1))
+		k++
+	}
+	for _, rl := range lines {
+		for rl.NewNum > 0 && !rl.IsHunk && k < len(anns) && anns[k].Range.Start <= rl.NewNum {
+			if anns[k].Range.End >= rl.NewNum {
+				result = append(result, annotationLine(anns[k], rl.Hunk))
+			} else {
+				top = append(top, annotationLine(anns[k], -1))
+			}
+			k++
+		}
+		result = append(result, rl)
+	}
+	for ; k < len(anns); k++ {
+		top = append(top, annotationLine(anns[k], -1))
+	}
+	return append(top, result...)
+}
+
+// annotationLine renders an annotation as a note line in hunk.
+func annotationLine(a model.Annotation, hunk int) renderedLine {
+	content := "  ⤷ " + a.Message
+	switch a.Type {
+	case model.AnnotationInfo:
+		content = "    " + a.Message
+	case model.AnnotationRisk, model.AnnotationWarning:
+		content = "  ! " + a.Message
+	}
+	return renderedLine{IsAnnotation: true, Annotation: a.Type, Hunk: hunk, Content: content}
+}
+
+// annotationStyle styles a note by its annotation type.
+func annotationStyle(t model.AnnotationType) lipgloss.Style {
+	switch t {
+	case model.AnnotationInfo:
+		return traceReasonStyle
+	case model.AnnotationRisk, model.AnnotationWarning:
+		return findingMediumStyle
+	default:
+		return traceWriteStyle
+	}
+}
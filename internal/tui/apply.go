@@ -7,61 +7,347 @@ import (
 	"github.com/bluekeyes/go-gitdiff/gitdiff"
 	"github.com/aezell/agrev/internal/diff"
 	"github.com/aezell/agrev/internal/model"
+	"github.com/aezell/agrev/internal/owners"
 )
 
 // ReviewResult holds the outcome of an interactive review session.
 type ReviewResult struct {
-	Decisions map[int]model.ReviewDecision
+	Decisions model.Decisions // by index into Files
 	Files     []*diff.File
+	Comments  []model.Comment
+	Owners    *owners.File // CODEOWNERS; nil if none
+	Triaged   bool         // the reviewer triaged findings, so Options.Triage needs saving
+	Checklist []model.ChecklistItem
 }
 
-// ApprovedFiles returns only the files that were approved.
+// Decision returns file i's decision, derived from its hunks' when they
+// were decided one by one.
+func (r *ReviewResult) Decision(i int) model.ReviewDecision {
+	return r.Decisions.File(i, len(r.Files[i].Fragments))
+}
+
+// ApprovedFiles returns the files with approved changes. A file whose hunks
+// were decided one by one is narrowed to its approved hunks, joined where
+// they overlap so the patch still applies.
 func (r *ReviewResult) ApprovedFiles() []*diff.File {
 	var approved []*diff.File
 	for i, f := range r.Files {
-		if r.Decisions[i] == model.DecisionApproved {
+		switch r.Decision(i) {
+		case model.DecisionApproved:
 			approved = append(approved, f)
+		case model.DecisionPartial:
+			var frags []*gitdiff.TextFragment
+			for h, frag := range f.Fragments {
+				if r.Decisions.Hunk(i, h) == model.DecisionApproved {
+					frags = append(frags, frag)
+				}
+			}
+			if len(frags) > 0 {
+				narrowed := *f
+				narrowed.Fragments = diff.JoinFragments(frags)
+				approved = append(approved, &narrowed)
+			}
 		}
 	}
 	return approved
 }
 
-// RejectedFiles returns only the files that were rejected.
+// RejectedFiles returns the files rejected in full.
 func (r *ReviewResult) RejectedFiles() []*diff.File {
-	var rejected []*diff.File
-	for i, f := range r.Files {
-		if r.Decisions[i] == model.DecisionRejected {
-			rejected = append(rejected, f)
-		}
-	}
-	return rejected
+	return r.filesDecided(model.DecisionRejected)
+}
+
+// PartialFiles returns the files whose hunks were decided differently.
+func (r *ReviewResult) PartialFiles() []*diff.File {
+	return r.filesDecided(model.DecisionPartial)
 }
 
 // PendingFiles returns files with no decision.
 func (r *ReviewResult) PendingFiles() []*diff.File {
-	var pending []*diff.File
+	return r.filesDecided(model.DecisionPending)
+}
+
+func (r *ReviewResult) filesDecided(d model.ReviewDecision) []*diff.File {
+	var files []*diff.File
 	for i, f := range r.Files {
-		if _, ok := r.Decisions[i]; !ok {
-			pending = append(pending, f)
+		if r.Decision(i) == d {
+			files = append(files, f)
 		}
 	}
-	return pending
+	return files
 }
 
 // GeneratePatch creates a unified diff string containing only the approved files.
+// Binary files are left out since the diff doesn't carry their contents.
 func (r *ReviewResult) GeneratePatch() string {
-	approved := r.ApprovedFiles()
+	var approved []*diff.File
+	for _, f := range r.ApprovedFiles() {
+		if !f.IsBinary {
+			approved = append(approved, f)
+		}
+	}
 	if len(approved) == 0 {
 		return ""
 	}
 
 	var b strings.Builder
+
+	// Comments go in the preamble, which git apply ignores
+	if len(r.Comments) > 0 {
+		b.WriteString("Review comments:\n")
+		for _, c := range r.Comments {
+			b.WriteString(fmt.Sprintf("  %s: %s%s\n", commentLocation(c), c.Body, commentByline(c, " — ")))
+		}
+		b.WriteString("\n")
+	}
+
 	for _, f := range approved {
 		b.WriteString(formatFilePatch(f))
 	}
 	return b.String()
 }
 
+// GenerateReport creates a markdown report of the review decisions and comments.
+func (r *ReviewResult) GenerateReport() string {
+	var b strings.Builder
+
+	approved := r.filesDecided(model.DecisionApproved)
+	rejected := r.RejectedFiles()
+	partial := r.PartialFiles()
+	pending := r.PendingFiles()
+
+	b.WriteString("## Review Report\n\n")
+	b.WriteString(fmt.Sprintf("**%d file(s)** reviewed: %d approved, %d rejected", len(r.Files), len(approved), len(rejected)))
+	if len(partial) > 0 {
+		b.WriteString(fmt.Sprintf(", %d partly approved", len(partial)))
+	}
+	b.WriteString(fmt.Sprintf(", %d pending\n\n", len(pending)))
+
+	owned := r.Owned()
+	if owned {
+		b.WriteString("| Decision | File | Changes | Owners |\n")
+		b.WriteString("|----------|------|---------|--------|\n")
+	} else {
+		b.WriteString("| Decision | File | Changes |\n")
+		b.WriteString("|----------|------|---------|\n")
+	}
+	for i, f := range r.Files {
+		decision := r.Decision(i).String()
+		if decision == "partial" {
+			decision = r.hunkTally(i)
+		}
+		if len(r.Decisions.Conditions[i]) > 0 {
+			decision += " with follow-ups"
+		}
+		if r.Decisions.Bulk[i] {
+			decision += " in bulk"
+		}
+		b.WriteString(fmt.Sprintf("| %s | `%s` | +%d -%d |", decision, f.Name(), f.AddedLines, f.DeletedLines))
+		if owned {
+			b.WriteString(" " + strings.Join(r.Owners.Of(f), " ") + " |")
+		}
+		b.WriteString("\n")
+	}
+
+	if notes := r.Notes(); len(notes) > 0 {
+		b.WriteString("\n### Rejection notes\n\n")
+		for _, n := range notes {
+			b.WriteString(fmt.Sprintf("- `%s` — %s\n", n.Location(), n.Body))
+		}
+	}
+
+	if conds := r.Conditions(); len(conds) > 0 {
+		b.WriteString("\n### Follow-ups\n\n")
+		for _, c := range conds {
+			b.WriteString(fmt.Sprintf("- [ ] `%s` — %s\n", c.File, c.Body))
+		}
+	}
+
+	if labels := r.Labels(); len(labels) > 0 {
+		b.WriteString("\n### Labels\n\n")
+		for _, l := range labels {
+			b.WriteString(fmt.Sprintf("- `%s` — %s\n", l.Location(), l.Tags()))
+		}
+	}
+
+	if len(r.Checklist) > 0 {
+		b.WriteString("\n### Checklist\n\n")
+		for _, c := range r.Checklist {
+			mark := " "
+			if c.Done {
+				mark = "x"
+			}
+			b.WriteString(fmt.Sprintf("- [%s] %s\n", mark, c.Text))
+		}
+	}
+
+	if len(r.Comments) > 0 {
+		b.WriteString("\n### Comments\n\n")
+		for _, c := range r.Comments {
+			b.WriteString(fmt.Sprintf("- `%s` — %s", commentLocation(c), c.Body))
+			if meta := commentMeta(c); meta != "" {
+				b.WriteString(" _(" + meta + ")_")
+			}
+			b.WriteString("\n")
+		}
+	}
+
+	return b.String()
+}
+
+// ForRepo narrows the outcome of reviewing several repositories at once to
+// repo's files and comments, named as in the repository, so its patch and
+// commit message can be made on their own.
+func (r *ReviewResult) ForRepo(repo diff.Repo) *ReviewResult {
+	out := *r
+	out.Files, out.Comments = nil, nil
+	var picked []int
+	for i, f := range r.Files {
+		if c, ok := diff.InRepo(f, repo); ok {
+			out.Files = append(out.Files, c)
+			picked = append(picked, i)
+		}
+	}
+	out.Decisions = r.Decisions.Select(picked)
+	for _, c := range r.Comments {
+		if name, ok := strings.CutPrefix(c.File, repo.Name+"/"); ok {
+			c.File = name
+			out.Comments = append(out.Comments, c)
+		}
+	}
+	return &out
+}
+
+// Owned reports whether CODEOWNERS assigns owners to any of the files.
+func (r *ReviewResult) Owned() bool {
+	for _, f := range r.Files {
+		if len(r.Owners.Of(f)) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Note is the reason given for rejecting a file or one of its hunks.
+type Note struct {
+	File string
+	Hunk int // 1-based; 0 for the whole file
+	Body string
+}
+
+// Location names what the note is about, e.g. "main.go" or "main.go
+// (hunk 2)".
+func (n Note) Location() string {
+	if n.Hunk > 0 {
+		return fmt.Sprintf("%s (hunk %d)", n.File, n.Hunk)
+	}
+	return n.File
+}
+
+// Notes returns the notes on the review's rejections in diff order.
+func (r *ReviewResult) Notes() []Note {
+	var notes []Note
+	for i, f := range r.Files {
+		for h := model.WholeFile; h < len(f.Fragments); h++ {
+			if body := r.Decisions.Note(i, h); body != "" {
+				notes = append(notes, Note{File: f.Name(), Hunk: h + 1, Body: body})
+			}
+		}
+	}
+	return notes
+}
+
+// Label holds the labels reviewers attached to a file or one of its hunks.
+type Label struct {
+	File   string
+	Hunk   int // 1-based; 0 for the whole file
+	Labels []string
+}
+
+// Location names what the labels are on, as Note.Location does.
+func (l Label) Location() string {
+	return Note{File: l.File, Hunk: l.Hunk}.Location()
+}
+
+// Tags shows the labels as tags, e.g. "#needs-security-review #docs".
+func (l Label) Tags() string {
+	return formatLabels(l.Labels)
+}
+
+// Labels returns the labels on the review's files and hunks in diff order.
+func (r *ReviewResult) Labels() []Label {
+	var labels []Label
+	for i, f := range r.Files {
+		for h := model.WholeFile; h < len(f.Fragments); h++ {
+			if l := r.Decisions.Label(i, h); len(l) > 0 {
+				labels = append(labels, Label{File: f.Name(), Hunk: h + 1, Labels: l})
+			}
+		}
+	}
+	return labels
+}
+
+// Condition is a follow-up a file was approved on.
+type Condition struct {
+	File string
+	Body string
+}
+
+// Conditions returns the follow-ups the review's approvals were given on,
+// in diff order.
+func (r *ReviewResult) Conditions() []Condition {
+	var conds []Condition
+	for i, f := range r.Files {
+		for _, c := range r.Decisions.Conditions[i] {
+			conds = append(conds, Condition{File: f.Name(), Body: c})
+		}
+	}
+	return conds
+}
+
+// hunkTally describes how file i's hunks were decided, e.g. "partial: 2/3
+// hunks approved".
+func (r *ReviewResult) hunkTally(i int) string {
+	n, approved := len(r.Files[i].Fragments), 0
+	for h := range n {
+		if r.Decisions.Hunk(i, h) == model.DecisionApproved {
+			approved++
+		}
+	}
+	return fmt.Sprintf("partial: %d/%d hunks approved", approved, n)
+}
+
+func commentLocation(c model.Comment) string {
+	if c.Line > 0 {
+		return fmt.Sprintf("%s:%d", c.File, c.Line)
+	}
+	return c.File
+}
+
+// commentMeta describes where in the diff a comment sits and who wrote it
+// when, e.g. "hunk 2, alice, 2026-01-15 10:04"; parts not known are left out.
+func commentMeta(c model.Comment) string {
+	var parts []string
+	if c.Hunk > 0 {
+		parts = append(parts, fmt.Sprintf("hunk %d", c.Hunk))
+	}
+	if c.Author != "" {
+		parts = append(parts, c.Author)
+	}
+	if !c.Time.IsZero() {
+		parts = append(parts, c.Time.Format("2006-01-02 15:04"))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// commentByline returns sep and the comment's author, or "" if it has none.
+func commentByline(c model.Comment, sep string) string {
+	if c.Author == "" {
+		return ""
+	}
+	return sep + c.Author
+}
+
 // GenerateCommitMessage creates a suggested commit message from approved changes.
 func (r *ReviewResult) GenerateCommitMessage() string {
 	approved := r.ApprovedFiles()
@@ -116,6 +402,18 @@ func (r *ReviewResult) GenerateCommitMessage() string {
 		b.WriteString(fmt.Sprintf("  - %s\n", f.Name()))
 	}
 
+	partial := r.PartialFiles()
+	if len(partial) > 0 {
+		b.WriteString("\nPartly approved files:\n")
+		for _, f := range partial {
+			for i := range r.Files {
+				if r.Files[i] == f {
+					b.WriteString(fmt.Sprintf("  - %s (%s)\n", f.Name(), r.hunkTally(i)))
+				}
+			}
+		}
+	}
+
 	rejected := r.RejectedFiles()
 	if len(rejected) > 0 {
 		b.WriteString("\nRejected files:\n")
@@ -124,54 +422,118 @@ func (r *ReviewResult) GenerateCommitMessage() string {
 		}
 	}
 
+	if notes := r.Notes(); len(notes) > 0 {
+		b.WriteString("\nRejection notes:\n")
+		for _, n := range notes {
+			b.WriteString(fmt.Sprintf("  - %s: %s\n", n.Location(), n.Body))
+		}
+	}
+
+	if conds := r.Conditions(); len(conds) > 0 {
+		b.WriteString("\nFollow-ups:\n")
+		for _, c := range conds {
+			b.WriteString(fmt.Sprintf("  - [ ] %s: %s\n", c.File, c.Body))
+		}
+	}
+
+	if len(r.Comments) > 0 {
+		b.WriteString("\nReview comments:\n")
+		for _, c := range r.Comments {
+			b.WriteString(fmt.Sprintf("  - %s: %s%s\n", commentLocation(c), c.Body, commentByline(c, " — ")))
+		}
+	}
+
 	return b.String()
 }
 
 // formatFilePatch reconstructs a unified diff for a single file.
 func formatFilePatch(f *diff.File) string {
 	var b strings.Builder
+	writeFileHeader(&b, f)
+	for _, frag := range f.Fragments {
+		writeFragment(&b, frag)
+	}
+	return b.String()
+}
+
+// formatHunkPatch reconstructs a unified diff holding only hunk i of f.
+func formatHunkPatch(f *diff.File, i int) string {
+	var b strings.Builder
+	writeFileHeader(&b, f)
+	writeFragment(&b, f.Fragments[i])
+	return b.String()
+}
+
+func writeFileHeader(b *strings.Builder, f *diff.File) {
+	oldPath, newPath := f.OldName, f.NewName
+	if oldPath == "" {
+		oldPath = newPath
+	}
+	if newPath == "" {
+		newPath = oldPath
+	}
+
+	// Modes as git writes them, for files whose diff didn't give one a
+	// regular file's
+	oldMode, newMode := f.OldMode, f.NewMode
+	if oldMode == 0 {
+		oldMode = 0o100644
+	}
+	if newMode == 0 {
+		newMode = oldMode
+	}
 
-	oldName := f.OldName
-	newName := f.NewName
-	if oldName == "" {
-		oldName = "/dev/null"
+	b.WriteString(fmt.Sprintf("diff --git a/%s b/%s\n", oldPath, newPath))
+	switch {
+	case f.IsNew:
+		b.WriteString(fmt.Sprintf("new file mode %o\n", newMode))
+	case f.IsDeleted:
+		b.WriteString(fmt.Sprintf("deleted file mode %o\n", oldMode))
+	case newMode != oldMode:
+		b.WriteString(fmt.Sprintf("old mode %o\nnew mode %o\n", oldMode, newMode))
+	}
+	switch {
+	case f.IsRenamed:
+		b.WriteString(fmt.Sprintf("rename from %s\nrename to %s\n", oldPath, newPath))
+	case f.IsCopy:
+		b.WriteString(fmt.Sprintf("copy from %s\ncopy to %s\n", oldPath, newPath))
 	}
-	if newName == "" {
-		newName = "/dev/null"
+	if len(f.Fragments) == 0 {
+		return
 	}
 
-	b.WriteString(fmt.Sprintf("diff --git a/%s b/%s\n", f.Name(), f.Name()))
+	// git apply wants /dev/null without the a/ or b/ prefix
+	from, to := "a/"+oldPath, "b/"+newPath
 	if f.IsNew {
-		b.WriteString("new file mode 100644\n")
-	} else if f.IsDeleted {
-		b.WriteString("deleted file mode 100644\n")
+		from = "/dev/null"
 	}
-	b.WriteString(fmt.Sprintf("--- a/%s\n", oldName))
-	b.WriteString(fmt.Sprintf("+++ b/%s\n", newName))
+	if f.IsDeleted {
+		to = "/dev/null"
+	}
+	b.WriteString(fmt.Sprintf("--- %s\n", from))
+	b.WriteString(fmt.Sprintf("+++ %s\n", to))
+}
 
-	for _, frag := range f.Fragments {
-		b.WriteString(fmt.Sprintf("@@ -%d,%d +%d,%d @@",
-			frag.OldPosition, frag.OldLines,
-			frag.NewPosition, frag.NewLines))
-		if frag.Comment != "" {
-			b.WriteString(" " + frag.Comment)
-		}
-		b.WriteString("\n")
+func writeFragment(b *strings.Builder, frag *gitdiff.TextFragment) {
+	b.WriteString(fmt.Sprintf("@@ -%d,%d +%d,%d @@",
+		frag.OldPosition, frag.OldLines,
+		frag.NewPosition, frag.NewLines))
+	if frag.Comment != "" {
+		b.WriteString(" " + frag.Comment)
+	}
+	b.WriteString("\n")
 
-		for _, line := range frag.Lines {
-			switch line.Op {
-			case gitdiff.OpContext:
-				b.WriteString(" " + line.Line)
-			case gitdiff.OpDelete:
-				b.WriteString("-" + line.Line)
-			case gitdiff.OpAdd:
-				b.WriteString("+" + line.Line)
-			}
-			if !strings.HasSuffix(line.Line, "\n") {
-				b.WriteString("\n")
-			}
+	for _, line := range frag.Lines {
+		switch line.Op {
+		case gitdiff.OpContext:
+			b.WriteString(" " + line.Line)
+		case gitdiff.OpDelete:
+			b.WriteString("-" + line.Line)
+		case gitdiff.OpAdd:
+			b.WriteString("+" + line.Line)
+		}
+		if !strings.HasSuffix(line.Line, "\n") {
+			b.WriteString("\n\\ No newline at end of file\n")
 		}
 	}
-
-	return b.String()
 }
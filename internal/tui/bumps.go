@@ -0,0 +1,51 @@
+package tui
+
+import (
+	"fmt"
+
+	"github.com/aezell/agrev/internal/analysis"
+	"github.com/aezell/agrev/internal/diff"
+)
+
+// bumpSummary returns the packages a change that only updates
+// dependencies bumps, shown above each of its files' diffs in place of
+// reading the lockfile hunks. It is nil for any other change, and worked
+// out again when the diff or its findings change.
+func (m *Model) bumpSummary() []renderedLine {
+	if m.bumpsDiff == m.diffSet && m.bumpsResults == m.analysisResults {
+		return m.bumpLines
+	}
+	m.bumpsDiff, m.bumpsResults = m.diffSet, m.analysisResults
+	m.bumpLines = bumpLines(m.diffSet, m.analysisResults)
+	return m.bumpLines
+}
+
+// bumpLines lists the packages ds bumps, with their version jumps, known
+// vulnerabilities, and changelogs, if ds is a dependency update.
+func bumpLines(ds *diff.DiffSet, results *analysis.Results) []renderedLine {
+	bumps, ok := analysis.DependencyUpdate(ds, results)
+	if !ok {
+		return nil
+	}
+	note := func(format string, args ...any) renderedLine {
+		return renderedLine{IsSemantic: true, Content: fmt.Sprintf(format, args...)}
+	}
+	if len(bumps) == 0 {
+		return []renderedLine{note("  ⇡ dependency update: no package versions changed")}
+	}
+	lines := []renderedLine{note("  ⇡ dependency update: %d package(s)", len(bumps))}
+	for _, b := range bumps {
+		line := fmt.Sprintf("    %s %s", b.Name, b.Versions())
+		if jump := b.Jump(); jump != "" && jump != "new" && jump != "removed" {
+			line += " (" + jump + ")"
+		}
+		if status := b.Status(); status != "" {
+			line += " · vulnerabilities: " + status
+		}
+		lines = append(lines, note("%s", line))
+		if b.Changelog != "" {
+			lines = append(lines, note("      %s", b.Changelog))
+		}
+	}
+	return lines
+}
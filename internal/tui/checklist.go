@@ -0,0 +1,104 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/aezell/agrev/internal/audit"
+	"github.com/aezell/agrev/internal/model"
+)
+
+// openChecklist shows the review checklist panel, or says there is none.
+func (m *Model) openChecklist() {
+	if len(m.checklist) == 0 {
+		m.message = "no checklist for this review (set checklist in .agrev.yml)"
+		return
+	}
+	m.checklistCursor = 0
+	m.showChecklist = true
+}
+
+// toggleChecklist checks off the item under the cursor, or unchecks it.
+func (m *Model) toggleChecklist() {
+	if m.checklistCursor >= len(m.checklist) {
+		return
+	}
+	c := &m.checklist[m.checklistCursor]
+	c.Done = !c.Done
+	action := audit.ActionCheck
+	if !c.Done {
+		action = audit.ActionUncheck
+	}
+	m.logAction(audit.Event{Action: action, Detail: c.Text})
+	if left := model.ChecklistLeft(m.checklist); left == 0 {
+		m.message = "checklist done"
+	} else {
+		m.message = fmt.Sprintf("%d checklist item(s) left", left)
+	}
+}
+
+func (m Model) updateChecklistPanel(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch {
+	case msg.Type == tea.KeyEsc, key.Matches(msg, keys.Checklist):
+		m.showChecklist = false
+	case key.Matches(msg, keys.Quit):
+		return m, tea.Quit
+	case key.Matches(msg, keys.Down):
+		if m.checklistCursor < len(m.checklist)-1 {
+			m.checklistCursor++
+		}
+	case key.Matches(msg, keys.Up):
+		if m.checklistCursor > 0 {
+			m.checklistCursor--
+		}
+	case msg.Type == tea.KeySpace, msg.Type == tea.KeyEnter:
+		m.toggleChecklist()
+	}
+	return m, nil
+}
+
+func (m Model) renderChecklistPanel() string {
+	boxWidth := m.width * 3 / 4
+	if boxWidth < 50 {
+		boxWidth = m.width - 4
+	}
+
+	var b strings.Builder
+	left := model.ChecklistLeft(m.checklist)
+	b.WriteString(fileHeaderStyle.Render(fmt.Sprintf("Checklist (%d of %d done)", len(m.checklist)-left, len(m.checklist))))
+	b.WriteByte('\n')
+
+	for i, c := range m.checklist {
+		mark := "[ ]"
+		style := fileItemStyle
+		if c.Done {
+			mark = "[x]"
+			style = lipgloss.NewStyle().Foreground(colorGreen)
+		}
+		if i == m.checklistCursor {
+			style = fileItemSelectedStyle.Width(boxWidth - 4)
+		}
+		b.WriteString(style.Render(truncate(mark+" "+c.Text, boxWidth-4)))
+		b.WriteByte('\n')
+	}
+
+	if left > 0 && m.policy.RequiresChecklist() {
+		b.WriteString("\n")
+		b.WriteString(filePendingStyle.Render("The policy requires every item checked off to finish the review."))
+		b.WriteByte('\n')
+	}
+	if m.message != "" {
+		b.WriteString("\n")
+		b.WriteString(helpBarStyle.Render(m.message))
+		b.WriteByte('\n')
+	}
+
+	b.WriteString("\n")
+	b.WriteString(helpBarStyle.Render("j/k move  space check off  esc close"))
+
+	box := fileListStyle.Width(boxWidth).Render(b.String())
+	return lipgloss.Place(m.width, m.height, lipgloss.Center, lipgloss.Center, box)
+}
@@ -0,0 +1,58 @@
+package tui
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// osc52 returns the terminal escape sequence that sets the system clipboard
+// to text. Inside tmux the sequence is wrapped for passthrough.
+func osc52(text string, tmux bool) string {
+	seq := "\x1b]52;c;" + base64.StdEncoding.EncodeToString([]byte(text)) + "\x07"
+	if tmux {
+		return "\x1bPtmux;\x1b" + seq + "\x1b\\"
+	}
+	return seq
+}
+
+// clipboardOut is where the OSC 52 sequence is written.
+var clipboardOut io.Writer = os.Stdout
+
+// copyToClipboard returns a command placing text on the system clipboard via
+// OSC 52, which works over SSH as long as the terminal supports it.
+func copyToClipboard(text string) tea.Cmd {
+	return func() tea.Msg {
+		fmt.Fprint(clipboardOut, osc52(text, os.Getenv("TMUX") != ""))
+		return nil
+	}
+}
+
+// copyPatch copies the current hunk, or with wholeFile the current file's
+// patch, to the clipboard.
+func (m Model) copyPatch(wholeFile bool) (tea.Model, tea.Cmd) {
+	if len(m.diffSet.Files) == 0 {
+		return m, nil
+	}
+	f := m.diffSet.Files[m.fileIndex]
+	if len(f.Fragments) == 0 {
+		m.message = "nothing to copy"
+		return m, nil
+	}
+
+	var patch string
+	if wholeFile {
+		patch = formatFilePatch(f)
+		m.message = fmt.Sprintf("copied %s patch", f.Name())
+	} else {
+		h := m.currentHunk()
+		patch = formatHunkPatch(f, h)
+		m.message = fmt.Sprintf("copied hunk %d/%d", h+1, len(f.Fragments))
+	}
+	m.message += fmt.Sprintf(" (%d lines)", strings.Count(patch, "\n"))
+	return m, copyToClipboard(patch)
+}
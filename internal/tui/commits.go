@@ -0,0 +1,130 @@
+package tui
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/aezell/agrev/internal/diff"
+	"github.com/aezell/agrev/internal/model"
+)
+
+// currentCommit returns the commit being viewed, or nil for the whole range.
+func (m *Model) currentCommit() *diff.Commit {
+	if m.commitIndex < 0 || m.commitIndex >= len(m.commits) {
+		return nil
+	}
+	return &m.commits[m.commitIndex]
+}
+
+// stashDecisions records the current view's decisions by file name, and
+// hunk decisions by hunk ID, so they follow the file between commit views
+// and the range view. Notes, follow-ups, and labels go with them.
+func (m *Model) stashDecisions() {
+	for i, f := range m.diffSet.Files {
+		if d, ok := m.decisions.Files[i]; ok {
+			m.nameDecisions[f.Name()] = d
+		} else {
+			delete(m.nameDecisions, f.Name())
+		}
+		if m.decisions.Bulk[i] {
+			m.nameBulk[f.Name()] = true
+		} else {
+			delete(m.nameBulk, f.Name())
+		}
+		if conds := m.decisions.Conditions[i]; len(conds) > 0 {
+			m.nameConds[f.Name()] = slices.Clone(conds)
+		} else {
+			delete(m.nameConds, f.Name())
+		}
+		hunks := m.nameHunks[f.Name()]
+		for h := range f.Fragments {
+			d, ok := m.decisions.Hunks[model.HunkKey{File: i, Hunk: h}]
+			switch {
+			case ok && hunks == nil:
+				hunks = make(map[string]model.ReviewDecision)
+				m.nameHunks[f.Name()] = hunks
+				fallthrough
+			case ok:
+				hunks[hunkID(f, h)] = d
+			case hunks != nil:
+				delete(hunks, hunkID(f, h))
+			}
+		}
+		for h := model.WholeFile; h < len(f.Fragments); h++ {
+			if note := m.decisions.Note(i, h); note != "" {
+				m.nameNotes[noteKey(f, h)] = note
+			} else {
+				delete(m.nameNotes, noteKey(f, h))
+			}
+			if labels := m.decisions.Label(i, h); len(labels) > 0 {
+				m.nameLabels[noteKey(f, h)] = slices.Clone(labels)
+			} else {
+				delete(m.nameLabels, noteKey(f, h))
+			}
+		}
+	}
+}
+
+// unstashDecisions sets the current view's decisions from those stashed by
+// file name and hunk ID.
+func (m *Model) unstashDecisions() {
+	m.decisions = model.NewDecisions()
+	for i, f := range m.diffSet.Files {
+		if d, ok := m.nameDecisions[f.Name()]; ok {
+			m.decisions.Files[i] = d
+		}
+		if m.nameBulk[f.Name()] {
+			m.decisions.Bulk[i] = true
+		}
+		if conds := m.nameConds[f.Name()]; len(conds) > 0 {
+			m.decisions.Conditions[i] = slices.Clone(conds)
+		}
+		if hunks := m.nameHunks[f.Name()]; hunks != nil {
+			for h := range f.Fragments {
+				if d, ok := hunks[hunkID(f, h)]; ok {
+					m.decisions.SetHunk(i, h, d)
+				}
+			}
+		}
+		for h := model.WholeFile; h < len(f.Fragments); h++ {
+			m.decisions.SetNote(i, h, m.nameNotes[noteKey(f, h)])
+			for _, l := range m.nameLabels[noteKey(f, h)] {
+				m.decisions.AddLabel(i, h, l)
+			}
+		}
+	}
+}
+
+// showCommit switches the view to commit i, or back to the whole range for -1.
+func (m *Model) showCommit(i int) {
+	if i == m.commitIndex || i >= len(m.commits) {
+		return
+	}
+	m.stashDecisions()
+
+	ds := m.rangeDiff
+	if i >= 0 {
+		ds = m.commits[i].Diff
+	}
+	m.commitIndex = i
+	m.diffSet = ds
+
+	m.unstashDecisions()
+	m.fileContent = make(map[int][]string)
+	m.extraContext = make(map[int]map[int]int)
+	m.wholeFileView = make(map[int]bool)
+	m.foldedHunks = make(map[int]map[int]bool)
+	m.clearSearch()
+	m.selectFile(0)
+}
+
+// commitHeader renders the commit panel shown above the diff in commit view.
+func (m Model) commitHeader(width int) string {
+	c := m.currentCommit()
+	meta := fmt.Sprintf("commit %s (%d/%d)  %s", c.ShortHash(), m.commitIndex+1, len(m.commits), c.Author)
+	if !c.Date.IsZero() {
+		meta += "  " + c.Date.Format("2006-01-02 15:04")
+	}
+	return commitHeaderStyle.Render(truncate(meta, width)) + "\n" +
+		contextLineStyle.Render(truncate(c.Subject, width))
+}
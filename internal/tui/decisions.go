@@ -0,0 +1,300 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/bluekeyes/go-gitdiff/gitdiff"
+	"github.com/aezell/agrev/internal/audit"
+	"github.com/aezell/agrev/internal/diff"
+	"github.com/aezell/agrev/internal/model"
+	"github.com/aezell/agrev/internal/policy"
+	"github.com/aezell/agrev/internal/review"
+)
+
+// fileDecision returns file i's decision, derived from its hunks' when they
+// were decided one by one.
+func (m *Model) fileDecision(i int) model.ReviewDecision {
+	return m.decisions.File(i, len(m.diffSet.Files[i].Fragments))
+}
+
+// fileDecided reports whether every hunk of file i has a decision.
+func (m *Model) fileDecided(i int) bool {
+	return m.decisions.Decided(i, len(m.diffSet.Files[i].Fragments))
+}
+
+// decideFile records d for the whole of file i, replacing any decisions and
+// notes on it and its hunks in this view and in the others.
+func (m *Model) decideFile(i int, d model.ReviewDecision) {
+	m.forgetHunks(i)
+	m.decisions.SetFile(i, d)
+	m.logAction(audit.Event{Action: decisionAction(d), File: m.diffSet.Files[i].Name()})
+}
+
+// decideBulk records d for the whole of file i as one of several files
+// decided at once, for the reason given in why. CODEOWNERS rules don't
+// count it as an explicit approval.
+func (m *Model) decideBulk(i int, d model.ReviewDecision, why string) {
+	m.forgetHunks(i)
+	m.decisions.SetBulk(i, d)
+	m.logAction(audit.Event{Action: decisionAction(d), File: m.diffSet.Files[i].Name(), Bulk: true, Detail: why})
+}
+
+// decisionAction names d in the audit log.
+func decisionAction(d model.ReviewDecision) string {
+	if d == model.DecisionRejected {
+		return audit.ActionReject
+	}
+	return audit.ActionApprove
+}
+
+// logAction appends e to the audit log, if there is one. A failed write is
+// reported in the status bar but doesn't hold up the review.
+func (m *Model) logAction(e audit.Event) {
+	if err := m.audit.Write(e); err != nil {
+		m.message = fmt.Sprintf("audit log: %v", err)
+	}
+}
+
+// ownedInBulk returns a reminder, for the status bar, of how many of the
+// files just approved in bulk have CODEOWNERS owners or a require_approval
+// policy rule, whose approval has to be given file by file. It is "" when
+// none have.
+func (m *Model) ownedInBulk(files []int) string {
+	owned := 0
+	for _, i := range files {
+		if len(m.owners.Of(m.diffSet.Files[i])) > 0 || m.requiresApproval(i) {
+			owned++
+		}
+	}
+	if owned == 0 {
+		return ""
+	}
+	return fmt.Sprintf("; %d owned or under policy still need approving one by one", owned)
+}
+
+// policyHits returns the policy rules applying to file i.
+func (m *Model) policyHits(i int) []policy.Hit {
+	var hits []policy.Hit
+	for _, h := range m.policy.Evaluate(m.diffSet, m.analysisResults) {
+		if h.File == i {
+			hits = append(hits, h)
+		}
+	}
+	return hits
+}
+
+// requiresApproval reports whether a require_approval rule applies to file
+// i.
+func (m *Model) requiresApproval(i int) bool {
+	for _, h := range m.policyHits(i) {
+		if h.Rule.Action == policy.ActionRequireApproval {
+			return true
+		}
+	}
+	return false
+}
+
+// autoApprove approves, in bulk, the undecided files the policy's
+// auto_approve rules cover. Like approving whitespace-only files at the
+// start, it isn't recorded for undo, since the reviewer didn't do it.
+func (m *Model) autoApprove() {
+	for _, i := range policy.AutoApproved(m.policy.Evaluate(m.diffSet, m.analysisResults)) {
+		if !m.fileDecided(i) {
+			m.decideBulk(i, model.DecisionApproved, "policy auto_approve")
+		}
+	}
+}
+
+// forgetHunks drops the decisions and notes stashed for file i and its
+// hunks from other views, as deciding the whole file replaces them.
+func (m *Model) forgetHunks(i int) {
+	name := m.diffSet.Files[i].Name()
+	delete(m.nameHunks, name)
+	for k := range m.nameNotes {
+		if k == name || strings.HasPrefix(k, name+"\x00") {
+			delete(m.nameNotes, k)
+		}
+	}
+}
+
+// decideHunk records d for the hunk at the top of the viewport and moves to
+// the next hunk, or on to the next file once every hunk is decided. A
+// rejection opens the prompt for its note.
+func (m *Model) decideHunk(d model.ReviewDecision) tea.Cmd {
+	if len(m.diffSet.Files) == 0 || len(m.diffSet.Files[m.fileIndex].Fragments) == 0 {
+		return nil
+	}
+	f := m.diffSet.Files[m.fileIndex]
+	h := m.currentHunk()
+	verb := "approve"
+	if d == model.DecisionRejected {
+		verb = "reject"
+	}
+	m.record(fmt.Sprintf("%s hunk %d of %s", verb, h+1, f.Name()))
+	m.decisions.SetHunk(m.fileIndex, h, d)
+	m.logAction(audit.Event{Action: decisionAction(d), File: f.Name(), Hunk: h + 1})
+	var cmd tea.Cmd
+	if d == model.DecisionRejected {
+		cmd = m.askNote(m.fileIndex, h)
+	}
+
+	if m.fileDecided(m.fileIndex) {
+		m.advanceAfterDecision()
+		return cmd
+	}
+	m.relayout()
+	for next := h + 1; next < len(f.Fragments); next++ {
+		if m.decisions.Hunk(m.fileIndex, next) == model.DecisionPending {
+			m.jumpToHunk(next)
+			break
+		}
+	}
+	return cmd
+}
+
+// splitHunk splits the hunk at the top of the viewport into one hunk per
+// run of changes, as git add -p does, so they can be decided one by one.
+// The pieces take the hunk's decision, note, and labels, and later hunks'
+// decisions and comments move up.
+func (m *Model) splitHunk() {
+	if len(m.diffSet.Files) == 0 {
+		return
+	}
+	f := m.diffSet.Files[m.fileIndex]
+	h := m.currentHunk()
+	id := hunkID(f, h)
+	n := f.SplitHunk(h)
+	if n < 2 {
+		m.message = "hunk can't be split"
+		return
+	}
+	m.decisions.SplitHunk(m.fileIndex, h, n)
+	review.SplitComments(m.comments, f, h, n)
+	m.splitHistory(f, id, h, n)
+	// Expanded context and folds were per hunk
+	delete(m.extraContext, m.fileIndex)
+	delete(m.foldedHunks, m.fileIndex)
+	m.relayout()
+	m.jumpToHunk(h)
+	m.message = fmt.Sprintf("split hunk %d into %d", h+1, n)
+}
+
+func newNoteInput() textinput.Model {
+	ti := textinput.New()
+	ti.Placeholder = "why? enter to save, esc to skip"
+	ti.CharLimit = 200
+	return ti
+}
+
+// askNote opens the prompt for a note on why file i, or hunk h of it, was
+// rejected.
+func (m *Model) askNote(i, h int) tea.Cmd {
+	m.noting, m.noteConds = true, false
+	m.noteTarget = model.HunkKey{File: i, Hunk: h}
+	m.noteFile = m.diffSet.Files[i].Name()
+	m.noteInput.Reset()
+	m.noteInput.Placeholder = "why? enter to save, esc to skip"
+	return m.noteInput.Focus()
+}
+
+// askConditions opens the prompt for the follow-ups file i was just
+// approved on. It takes them one at a time until an empty line or esc.
+func (m *Model) askConditions(i int) tea.Cmd {
+	cmd := m.askNote(i, model.WholeFile)
+	m.noteConds = true
+	m.noteInput.Placeholder = "e.g. add tests for the parser; enter on an empty line when done"
+	return cmd
+}
+
+func (m Model) updateNote(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.Type {
+	case tea.KeyEnter:
+		// The note belongs to the rejection just recorded, so it is undone
+		// along with it
+		t := m.noteTarget
+		note := strings.TrimSpace(m.noteInput.Value())
+		if note != "" && t.File < len(m.diffSet.Files) && m.diffSet.Files[t.File].Name() == m.noteFile {
+			e := audit.Event{Action: audit.ActionNote, File: m.noteFile, Hunk: t.Hunk + 1, Detail: note}
+			if m.noteConds {
+				m.decisions.AddCondition(t.File, note)
+				e.Action, e.Hunk = audit.ActionFollowUp, 0
+				m.logAction(e)
+				m.noteInput.Reset()
+				return m, nil
+			}
+			m.decisions.SetNote(t.File, t.Hunk, note)
+			m.logAction(e)
+			m.relayout()
+		}
+		m.noting = false
+		m.noteInput.Blur()
+		return m, nil
+	case tea.KeyEsc:
+		m.noting = false
+		m.noteInput.Blur()
+		return m, nil
+	}
+
+	var cmd tea.Cmd
+	m.noteInput, cmd = m.noteInput.Update(msg)
+	return m, cmd
+}
+
+func (m Model) renderNoteBar() string {
+	what := m.noteFile
+	if m.noteTarget.Hunk != model.WholeFile {
+		what = fmt.Sprintf("hunk %d of %s", m.noteTarget.Hunk+1, m.noteFile)
+	}
+	prompt := statusKeyStyle.Render(" Why reject " + what + "? ")
+	if m.noteConds {
+		n := len(m.decisions.Conditions[m.noteTarget.File])
+		prompt = statusKeyStyle.Render(fmt.Sprintf(" Follow-up %d for %s: ", n+1, m.noteFile))
+	}
+	return lipgloss.NewStyle().
+		Foreground(colorFg).
+		Background(colorBgLight).
+		Width(m.width).
+		Render(prompt + " " + m.noteInput.View())
+}
+
+// noteKey names the note on hunk h of f, or on f itself for WholeFile, the
+// way hunkID names its decision across views.
+func noteKey(f *diff.File, h int) string {
+	if h == model.WholeFile {
+		return f.Name()
+	}
+	return f.Name() + "\x00" + hunkID(f, h)
+}
+
+// jumpToHunk scrolls to the header of hunk h of the current file.
+func (m *Model) jumpToHunk(h int) {
+	for i, rl := range m.lines {
+		if rl.IsHunk && rl.Hunk == h {
+			m.scrollOffset = i
+			return
+		}
+	}
+}
+
+// hunkID names hunk h of f by its file and its lines, so a hunk's decision
+// follows it between commit views and the range view wherever it appears
+// unchanged.
+func hunkID(f *diff.File, h int) string {
+	var b strings.Builder
+	for _, l := range f.Fragments[h].Lines {
+		switch l.Op {
+		case gitdiff.OpAdd:
+			b.WriteByte('+')
+		case gitdiff.OpDelete:
+			b.WriteByte('-')
+		default:
+			b.WriteByte(' ')
+		}
+		b.WriteString(l.Line)
+	}
+	return b.String()
+}
@@ -0,0 +1,70 @@
+package tui
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/aezell/agrev/internal/audit"
+)
+
+// editorFinishedMsg is sent when the external editor exits.
+type editorFinishedMsg struct {
+	err error
+}
+
+// editorArgs builds the argument list that opens path at line in editor,
+// which may include its own arguments (e.g. "code --wait").
+func editorArgs(editor, path string, line int) []string {
+	args := strings.Fields(editor)
+	if len(args) == 0 {
+		args = []string{"vi"}
+	}
+	if line <= 0 {
+		return append(args, path)
+	}
+
+	switch filepath.Base(args[0]) {
+	case "code", "code-insiders", "codium", "cursor":
+		return append(args, "--goto", fmt.Sprintf("%s:%d", path, line))
+	case "subl", "zed", "hx", "helix":
+		return append(args, fmt.Sprintf("%s:%d", path, line))
+	default:
+		// vi, vim, nvim, nano, emacs, micro, kak and most others accept +line
+		return append(args, fmt.Sprintf("+%d", line), path)
+	}
+}
+
+// openEditor suspends the TUI and opens the current file at the line under
+// the cursor in $VISUAL or $EDITOR.
+func (m Model) openEditor() (tea.Model, tea.Cmd) {
+	if len(m.diffSet.Files) == 0 {
+		return m, nil
+	}
+	f := m.diffSet.Files[m.fileIndex]
+	if f.IsDeleted {
+		m.message = "file was deleted"
+		return m, nil
+	}
+
+	path := f.NewName
+	if m.repoDir != "" {
+		path = filepath.Join(m.repoDir, path)
+	}
+
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	line := m.cursorLineNum()
+	args := editorArgs(editor, path, line)
+	m.logAction(audit.Event{Action: audit.ActionEdit, File: f.Name(), Line: line})
+
+	c := exec.Command(args[0], args[1:]...)
+	return m, tea.ExecProcess(c, func(err error) tea.Msg {
+		return editorFinishedMsg{err: err}
+	})
+}
@@ -0,0 +1,105 @@
+package tui
+
+import (
+	"fmt"
+
+	"github.com/aezell/agrev/internal/diff"
+)
+
+// renderCurrentFile renders the current file's diff with any context
+// expansion the reviewer has requested.
+func (m *Model) renderCurrentFile() []renderedLine {
+	f := m.diffSet.Files[m.fileIndex]
+	if f.IsBinary {
+		return m.binaryPreview(f)
+	}
+	if f.IsSubmodule {
+		return m.submodulePreview(f)
+	}
+	content := m.fileContent[m.fileIndex]
+	if content == nil {
+		return renderFile(f)
+	}
+
+	extra := m.extraContext[m.fileIndex]
+	if m.wholeFileView[m.fileIndex] {
+		extra = make(map[int]int, len(f.Fragments))
+		for i := range f.Fragments {
+			extra[i] = wholeFile
+		}
+	}
+	return renderFileExpanded(f, content, extra)
+}
+
+// loadContent reads the current file's full content if it isn't cached yet,
+// reporting failures in the status bar.
+func (m *Model) loadContent() bool {
+	if m.fileContent[m.fileIndex] != nil {
+		return true
+	}
+	content, err := diff.NewContent(m.repoDir, m.diffSet.Files[m.fileIndex])
+	if err != nil {
+		m.message = fmt.Sprintf("can't expand: %v", err)
+		return false
+	}
+	m.fileContent[m.fileIndex] = content
+	return true
+}
+
+// currentHunk returns the index of the hunk at the top of the viewport.
+func (m *Model) currentHunk() int {
+	for i := m.scrollOffset; i < len(m.lines); i++ {
+		if !m.lines[i].IsFinding && !m.lines[i].IsComment {
+			return m.lines[i].Hunk
+		}
+	}
+	return 0
+}
+
+// expandContext shows n more lines of context around the current hunk.
+func (m *Model) expandContext(n int) {
+	if len(m.diffSet.Files) == 0 || len(m.diffSet.Files[m.fileIndex].Fragments) == 0 || !m.loadContent() {
+		return
+	}
+	hunks := m.extraContext[m.fileIndex]
+	if hunks == nil {
+		hunks = make(map[int]int)
+		m.extraContext[m.fileIndex] = hunks
+	}
+	hunks[m.currentHunk()] += n
+	m.relayout()
+}
+
+// toggleWholeFile switches between the hunk view and the diff embedded in
+// the complete file.
+func (m *Model) toggleWholeFile() {
+	if len(m.diffSet.Files) == 0 {
+		return
+	}
+	if !m.wholeFileView[m.fileIndex] && !m.loadContent() {
+		return
+	}
+	m.wholeFileView[m.fileIndex] = !m.wholeFileView[m.fileIndex]
+	m.relayout()
+}
+
+// relayout re-renders the current file, keeping the line at the top of the
+// viewport in place.
+func (m *Model) relayout() {
+	var anchor renderedLine
+	if m.scrollOffset < len(m.lines) {
+		anchor = m.lines[m.scrollOffset]
+	}
+	m.updateLines()
+
+	for i, rl := range m.lines {
+		if rl.IsHunk == anchor.IsHunk && rl.Hunk == anchor.Hunk &&
+			rl.OldNum == anchor.OldNum && rl.NewNum == anchor.NewNum && rl.Content == anchor.Content {
+			m.scrollOffset = i
+			return
+		}
+	}
+	if m.scrollOffset >= len(m.lines) {
+		m.scrollOffset = max(len(m.lines)-1, 0)
+	}
+}
@@ -0,0 +1,102 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/aezell/agrev/internal/diff"
+	"github.com/aezell/agrev/internal/explain"
+)
+
+// explainedMsg carries an explanation back from the LLM.
+type explainedMsg struct {
+	title string
+	text  string
+	err   error
+}
+
+// explainHunk asks for an explanation of the current hunk and its findings
+// off the UI goroutine. The result opens in the explanation view.
+func (m Model) explainHunk() (tea.Model, tea.Cmd) {
+	if len(m.diffSet.Files) == 0 {
+		return m, nil
+	}
+	if m.explain == nil {
+		m.message = explain.ErrDisabled.Error()
+		return m, nil
+	}
+	if m.explaining {
+		m.message = "already waiting for an explanation"
+		return m, nil
+	}
+	f := m.diffSet.Files[m.fileIndex]
+	if len(f.Fragments) == 0 {
+		m.message = "nothing to explain"
+		return m, nil
+	}
+
+	h := m.currentHunk()
+	// Surrounding context is best effort; the hunk alone still explains
+	content := m.fileContent[m.fileIndex]
+	if content == nil {
+		if c, err := diff.NewContent(m.repoDir, f); err == nil {
+			content = c
+		}
+	}
+	req := explain.NewRequest(f, h, content, m.fileFindings)
+	title := fmt.Sprintf("%s  hunk %d/%d", f.Name(), h+1, len(f.Fragments))
+
+	m.explaining = true
+	m.message = fmt.Sprintf("explaining hunk %d/%d...", h+1, len(f.Fragments))
+	fn := m.explain
+	return m, func() tea.Msg {
+		text, err := fn(req)
+		return explainedMsg{title: title, text: text, err: err}
+	}
+}
+
+func (m Model) updateExplain(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	body := renderMarkdown(m.explainText, m.width-4)
+	maxScroll := max(len(body)-m.detailBodyHeight(), 0)
+
+	switch msg.String() {
+	case "esc", "enter", "q":
+		m.showExplain = false
+	case "ctrl+c":
+		return m, tea.Quit
+	case "j", "down":
+		m.explainScroll = min(m.explainScroll+1, maxScroll)
+	case "k", "up":
+		m.explainScroll = max(m.explainScroll-1, 0)
+	case "pgdown", " ":
+		m.explainScroll = min(m.explainScroll+m.detailBodyHeight(), maxScroll)
+	case "pgup":
+		m.explainScroll = max(m.explainScroll-m.detailBodyHeight(), 0)
+	case "g":
+		m.explainScroll = 0
+	case "G":
+		m.explainScroll = maxScroll
+	}
+	return m, nil
+}
+
+func (m Model) renderExplain() string {
+	var b strings.Builder
+	b.WriteString(traceHeaderStyle.Render("Explanation  " + m.explainTitle))
+	b.WriteString("\n\n")
+
+	body := renderMarkdown(m.explainText, m.width-4)
+	end := min(m.explainScroll+m.detailBodyHeight(), len(body))
+	for i := m.explainScroll; i < end; i++ {
+		b.WriteString(body[i])
+		b.WriteByte('\n')
+	}
+
+	pos := ""
+	if len(body) > m.detailBodyHeight() {
+		pos = fmt.Sprintf("  %d-%d/%d", m.explainScroll+1, end, len(body))
+	}
+	b.WriteString(helpBarStyle.Render("j/k scroll  g/G top/bottom  esc close" + pos))
+	return b.String()
+}
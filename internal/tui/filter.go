@@ -0,0 +1,150 @@
+package tui
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/aezell/agrev/internal/model"
+)
+
+// fileFilter is a set of file list filters. Active filters combine, so a file
+// must satisfy all of them to be shown.
+type fileFilter int
+
+const (
+	filterPending fileFilter = 1 << iota
+	filterHighRisk
+	filterFindings
+	filterNew
+)
+
+// filterNames lists the filters in toggle-key order for display.
+var filterNames = []struct {
+	filter fileFilter
+	name   string
+}{
+	{filterPending, "pending"},
+	{filterHighRisk, "high-risk"},
+	{filterFindings, "findings"},
+	{filterNew, "new"},
+}
+
+func (f fileFilter) String() string {
+	var parts []string
+	for _, fn := range filterNames {
+		if f&fn.filter != 0 {
+			parts = append(parts, fn.name)
+		}
+	}
+	return strings.Join(parts, "+")
+}
+
+// fileVisible reports whether the file at index i passes the active filters.
+func (m *Model) fileVisible(i int) bool {
+	if m.filter == 0 && m.labelFilter == "" {
+		return true
+	}
+	f := m.diffSet.Files[i]
+
+	if m.labelFilter != "" && !slices.Contains(m.decisions.FileLabels(i), m.labelFilter) {
+		return false
+	}
+
+	if m.filter&filterPending != 0 {
+		if m.fileDecided(i) {
+			return false
+		}
+	}
+	if m.filter&filterNew != 0 && !f.IsNew {
+		return false
+	}
+	if m.filter&(filterHighRisk|filterFindings) != 0 {
+		findings, maxRisk := m.fileRisk(i)
+		if m.filter&filterFindings != 0 && findings == 0 {
+			return false
+		}
+		if m.filter&filterHighRisk != 0 && maxRisk < model.RiskHigh {
+			return false
+		}
+	}
+	return true
+}
+
+// filterDescription describes the active file list filters for the status
+// bar, e.g. "pending+#refactor-only", or "" when there are none.
+func (m *Model) filterDescription() string {
+	desc := m.filter.String()
+	if m.labelFilter != "" {
+		if desc != "" {
+			desc += "+"
+		}
+		desc += "#" + m.labelFilter
+	}
+	return desc
+}
+
+// visibleFiles returns the indices of files passing the active filters, in
+// file list order.
+func (m *Model) visibleFiles() []int {
+	var result []int
+	for i := range m.diffSet.Files {
+		if m.fileVisible(i) {
+			result = append(result, i)
+		}
+	}
+	m.sortFiles(result)
+	return result
+}
+
+// noFilesMatch reports whether the filters hide every file. Nothing is
+// selected then: the diff pane says so instead of showing the hidden file,
+// and the keys that act on the selected file do nothing.
+func (m *Model) noFilesMatch() bool {
+	return len(m.diffSet.Files) > 0 && len(m.visibleFiles()) == 0
+}
+
+// fileKeys are the keys that act on the selected file.
+var fileKeys = []key.Binding{
+	keys.NextHunk, keys.PrevHunk, keys.NextFinding, keys.PrevFinding,
+	keys.Expand, keys.ExpandMore, keys.WholeFile, keys.Semantic, keys.Fold,
+	keys.Approve, keys.ApproveWith, keys.Reject, keys.ApproveHunk, keys.RejectHunk, keys.SplitHunk,
+	keys.Comment, keys.Label, keys.LabelHunk, keys.Edit, keys.Explain,
+	keys.CopyHunk, keys.CopyFile,
+}
+
+// toggleFilter flips f and moves the selection onto a visible file if the
+// current one was filtered out.
+func (m *Model) toggleFilter(f fileFilter) {
+	m.filter ^= f
+	if len(m.diffSet.Files) == 0 || m.fileVisible(m.fileIndex) {
+		return
+	}
+	if visible := m.visibleFiles(); len(visible) > 0 {
+		m.selectFile(visible[0])
+	}
+}
+
+// nextVisibleFile returns the file after the current one in the file list,
+// or -1 if there is none.
+func (m *Model) nextVisibleFile() int {
+	visible := m.visibleFiles()
+	for pos, i := range visible {
+		if i == m.fileIndex && pos+1 < len(visible) {
+			return visible[pos+1]
+		}
+	}
+	return -1
+}
+
+// prevVisibleFile returns the file before the current one in the file list,
+// or -1 if there is none.
+func (m *Model) prevVisibleFile() int {
+	visible := m.visibleFiles()
+	for pos, i := range visible {
+		if i == m.fileIndex && pos > 0 {
+			return visible[pos-1]
+		}
+	}
+	return -1
+}
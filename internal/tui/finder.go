@@ -0,0 +1,161 @@
+package tui
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// finderResult is a file matching the fuzzy finder query.
+type finderResult struct {
+	file  int
+	score int
+}
+
+func newFinderInput() textinput.Model {
+	ti := textinput.New()
+	ti.Prompt = "> "
+	ti.Placeholder = "type to filter files"
+	ti.CharLimit = 200
+	return ti
+}
+
+// fuzzyScore scores how well query matches path as a subsequence. It returns
+// false if not every query character appears in order. Higher scores are better:
+// consecutive runs, matches at word boundaries, and matches in the base name
+// are rewarded, and shorter paths win ties.
+func fuzzyScore(path, query string) (int, bool) {
+	if query == "" {
+		return 0, true
+	}
+
+	p := strings.ToLower(path)
+	q := strings.ToLower(query)
+	baseStart := strings.LastIndex(p, "/") + 1
+
+	score := 0
+	qi := 0
+	prev := -2
+	for pi := 0; pi < len(p) && qi < len(q); pi++ {
+		if p[pi] != q[qi] {
+			continue
+		}
+		score++
+		if pi == prev+1 {
+			score += 5 // consecutive run
+		}
+		if pi == 0 || strings.ContainsRune("/_-. ", rune(p[pi-1])) {
+			score += 3 // start of a path segment or word
+		}
+		if pi >= baseStart {
+			score += 2 // inside the file name itself
+		}
+		prev = pi
+		qi++
+	}
+	if qi < len(q) {
+		return 0, false
+	}
+	return score*100 - len(p), true
+}
+
+// updateFinderResults recomputes the ranked file list for the current query.
+func (m *Model) updateFinderResults() {
+	query := m.finderInput.Value()
+	m.finderResults = m.finderResults[:0]
+	for i, f := range m.diffSet.Files {
+		if score, ok := fuzzyScore(f.Name(), query); ok {
+			m.finderResults = append(m.finderResults, finderResult{file: i, score: score})
+		}
+	}
+	if query != "" {
+		sort.SliceStable(m.finderResults, func(a, b int) bool {
+			return m.finderResults[a].score > m.finderResults[b].score
+		})
+	}
+	m.finderCursor = 0
+}
+
+func (m Model) updateFinder(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.Type {
+	case tea.KeyEsc:
+		m.showFinder = false
+		m.finderInput.Blur()
+		return m, nil
+	case tea.KeyEnter:
+		m.showFinder = false
+		m.finderInput.Blur()
+		if m.finderCursor < len(m.finderResults) {
+			m.selectFile(m.finderResults[m.finderCursor].file)
+		}
+		return m, nil
+	case tea.KeyUp, tea.KeyCtrlK:
+		if m.finderCursor > 0 {
+			m.finderCursor--
+		}
+		return m, nil
+	case tea.KeyDown, tea.KeyCtrlJ:
+		if m.finderCursor < len(m.finderResults)-1 {
+			m.finderCursor++
+		}
+		return m, nil
+	}
+
+	var cmd tea.Cmd
+	prev := m.finderInput.Value()
+	m.finderInput, cmd = m.finderInput.Update(msg)
+	if m.finderInput.Value() != prev {
+		m.updateFinderResults()
+	}
+	return m, cmd
+}
+
+func (m Model) renderFinder() string {
+	boxWidth := m.width * 2 / 3
+	if boxWidth < 40 {
+		boxWidth = m.width - 4
+	}
+	listHeight := m.height - 10
+	if listHeight < 3 {
+		listHeight = 3
+	}
+
+	var b strings.Builder
+	b.WriteString(fileHeaderStyle.Render(fmt.Sprintf("Find file (%d/%d)", len(m.finderResults), len(m.diffSet.Files))))
+	b.WriteByte('\n')
+	b.WriteString(m.finderInput.View())
+	b.WriteString("\n\n")
+
+	// Keep the cursor in view
+	start := 0
+	if m.finderCursor >= listHeight {
+		start = m.finderCursor - listHeight + 1
+	}
+	end := start + listHeight
+	if end > len(m.finderResults) {
+		end = len(m.finderResults)
+	}
+
+	if len(m.finderResults) == 0 {
+		b.WriteString(helpBarStyle.Render("No matching files"))
+	}
+	for i := start; i < end; i++ {
+		f := m.diffSet.Files[m.finderResults[i].file]
+		line := truncate(fmt.Sprintf("%s  +%d -%d", f.Name(), f.AddedLines, f.DeletedLines), boxWidth-4)
+		if i == m.finderCursor {
+			b.WriteString(fileItemSelectedStyle.Width(boxWidth - 4).Render(line))
+		} else {
+			b.WriteString(fileItemStyle.Render(line))
+		}
+		if i < end-1 {
+			b.WriteByte('\n')
+		}
+	}
+
+	box := fileListStyle.Width(boxWidth).Render(b.String())
+	return lipgloss.Place(m.width, m.height, lipgloss.Center, lipgloss.Center, box)
+}
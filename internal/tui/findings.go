@@ -0,0 +1,236 @@
+package tui
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/aezell/agrev/internal/analysis"
+	"github.com/aezell/agrev/internal/audit"
+	"github.com/aezell/agrev/internal/model"
+)
+
+// sortedFindings returns all analysis findings, highest risk first, then by
+// file and line, followed by the false positives suppressed from them in
+// the same order.
+func (m *Model) sortedFindings() []analysis.Finding {
+	if m.analysisResults == nil {
+		return nil
+	}
+	return append(byRisk(m.analysisResults.Findings), byRisk(m.analysisResults.Suppressed)...)
+}
+
+func byRisk(list []analysis.Finding) []analysis.Finding {
+	findings := make([]analysis.Finding, len(list))
+	copy(findings, list)
+	sort.SliceStable(findings, func(i, j int) bool {
+		a, b := findings[i], findings[j]
+		if a.Risk != b.Risk {
+			return a.Risk > b.Risk
+		}
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		return a.Line < b.Line
+	})
+	return findings
+}
+
+// triageFinding marks the finding under the findings panel's cursor with
+// state, or reopens it if it has that state already. The mark is saved
+// when the review ends and takes effect in later runs; until then the
+// finding is only labelled with it.
+func (m *Model) triageFinding(state model.FindingState) {
+	findings := m.sortedFindings()
+	if m.findingsCursor >= len(findings) {
+		return
+	}
+	if m.triage == nil {
+		m.message = "Findings can only be triaged in a git repository"
+		return
+	}
+	fin := findings[m.findingsCursor]
+	if fin.State == state {
+		state = model.FindingOpen
+	}
+	m.triage.Set(fin, state, m.author)
+	m.triaged = true
+	m.logAction(audit.Event{Action: audit.ActionTriage, File: fin.File, Line: fin.Line, Detail: state.String() + ": " + fin.Message})
+
+	fp := fin.Fingerprint()
+	for _, list := range [][]analysis.Finding{m.analysisResults.Findings, m.analysisResults.Suppressed} {
+		for i := range list {
+			if list[i].Fingerprint() == fp {
+				list[i].State = state
+			}
+		}
+	}
+	m.updateFileFindings()
+	m.updateLines()
+	if state == model.FindingOpen {
+		m.message = "Finding reopened"
+	} else {
+		m.message = "Finding marked " + stateLabel(state) + "; later runs will " + map[model.FindingState]string{
+			model.FindingAcknowledged:  "rank it as info",
+			model.FindingFalsePositive: "suppress it",
+			model.FindingFixed:         "flag it if it comes back",
+		}[state]
+	}
+}
+
+// findingTag labels a triaged finding's inline annotation, e.g.
+// ", acknowledged".
+func findingTag(fin analysis.Finding) string {
+	if fin.State == model.FindingOpen {
+		return ""
+	}
+	return ", " + stateLabel(fin.State)
+}
+
+// stateLabel names a finding state for display.
+func stateLabel(s model.FindingState) string {
+	return strings.ReplaceAll(s.String(), "_", " ")
+}
+
+// jumpToFinding shows the file containing fin and scrolls to its line.
+func (m *Model) jumpToFinding(fin analysis.Finding) {
+	for i, f := range m.diffSet.Files {
+		if f.Name() == fin.File {
+			m.selectFile(i)
+			break
+		}
+	}
+	if m.diffSet.Files[m.fileIndex].Name() != fin.File {
+		m.message = fmt.Sprintf("%s is not in the diff", fin.File)
+		return
+	}
+
+	// Prefer the code line itself; fall back to the inline annotation
+	if fin.Line > 0 {
+		for i, rl := range m.lines {
+			if !rl.IsHunk && !rl.IsFinding && !rl.IsComment && (rl.NewNum == fin.Line || rl.OldNum == fin.Line) {
+				m.scrollOffset = i
+				return
+			}
+		}
+	}
+	for i, rl := range m.lines {
+		if rl.IsFinding && strings.HasSuffix(rl.Content, "] "+fin.Message) {
+			m.scrollOffset = i
+			return
+		}
+	}
+}
+
+func (m Model) updateFindingsPanel(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	findings := m.sortedFindings()
+	switch {
+	case msg.Type == tea.KeyEsc, key.Matches(msg, keys.FindingsPanel):
+		m.showFindings = false
+	case key.Matches(msg, keys.Quit):
+		return m, tea.Quit
+	case key.Matches(msg, keys.Down):
+		if m.findingsCursor < len(findings)-1 {
+			m.findingsCursor++
+		}
+	case key.Matches(msg, keys.Up):
+		if m.findingsCursor > 0 {
+			m.findingsCursor--
+		}
+	case msg.Type == tea.KeyEnter:
+		if m.findingsCursor < len(findings) {
+			m.showFindings = false
+			m.jumpToFinding(findings[m.findingsCursor])
+		}
+	case key.Matches(msg, keys.Approve):
+		m.triageFinding(model.FindingAcknowledged)
+	case key.Matches(msg, keys.Reject):
+		m.triageFinding(model.FindingFalsePositive)
+	case msg.String() == "d":
+		m.triageFinding(model.FindingFixed)
+	}
+	return m, nil
+}
+
+func (m Model) renderFindingsPanel() string {
+	findings := m.sortedFindings()
+
+	boxWidth := m.width * 3 / 4
+	if boxWidth < 50 {
+		boxWidth = m.width - 4
+	}
+	listHeight := m.height - 8
+	if listHeight < 3 {
+		listHeight = 3
+	}
+
+	var b strings.Builder
+	title := fmt.Sprintf("Findings (%d)", len(findings))
+	if n := len(m.analysisResults.Suppressed); n > 0 {
+		title = fmt.Sprintf("Findings (%d, %d suppressed as false positives)", len(findings)-n, n)
+	}
+	b.WriteString(fileHeaderStyle.Render(title))
+	b.WriteByte('\n')
+
+	if len(findings) == 0 {
+		b.WriteString(helpBarStyle.Render("No findings"))
+	}
+
+	// Keep the cursor in view
+	start := 0
+	if m.findingsCursor >= listHeight {
+		start = m.findingsCursor - listHeight + 1
+	}
+	end := min(start+listHeight, len(findings))
+
+	for i := start; i < end; i++ {
+		fin := findings[i]
+		loc := fin.File
+		if fin.Line > 0 {
+			loc = fmt.Sprintf("%s:%d", fin.File, fin.Line)
+		}
+		if fin.Scope != "" {
+			loc += " (in " + fin.Scope + ")"
+		}
+		pass := fin.Pass
+		if fin.Rule != "" {
+			pass += " " + fin.Rule
+		}
+		text := fmt.Sprintf("%-8s %s  [%s] %s", fin.Risk, loc, pass, fin.Message)
+		if fin.State != model.FindingOpen {
+			text += "  (" + stateLabel(fin.State) + ")"
+		}
+		line := truncate(text, boxWidth-4)
+		switch {
+		case i == m.findingsCursor:
+			b.WriteString(fileItemSelectedStyle.Width(boxWidth - 4).Render(line))
+		case fin.State == model.FindingAcknowledged || fin.State == model.FindingFalsePositive:
+			b.WriteString(findingLowStyle.Render(line))
+		default:
+			b.WriteString(findingRiskStyle(fin).Render(line))
+		}
+		if i < end-1 {
+			b.WriteByte('\n')
+		}
+	}
+
+	b.WriteString("\n\n")
+	b.WriteString(helpBarStyle.Render("j/k move  enter jump  a acknowledge  x false positive  d fixed  esc close"))
+
+	box := fileListStyle.Width(boxWidth).Render(b.String())
+	return lipgloss.Place(m.width, m.height, lipgloss.Center, lipgloss.Center, box)
+}
+
+func findingRiskStyle(fin analysis.Finding) lipgloss.Style {
+	switch {
+	case fin.Risk >= 3:
+		return findingHighStyle
+	case fin.Risk >= 2:
+		return findingMediumStyle
+	default:
+		return findingLowStyle
+	}
+}
@@ -0,0 +1,159 @@
+package tui
+
+import (
+	"fmt"
+
+	"github.com/bluekeyes/go-gitdiff/gitdiff"
+	"github.com/aezell/agrev/internal/diff"
+	"github.com/aezell/agrev/internal/model"
+)
+
+// Runs of more than foldContextMin unchanged lines inside a hunk are folded
+// down to foldContextKeep lines on each side.
+const (
+	foldContextMin  = 8
+	foldContextKeep = 3
+)
+
+// hunkFolded reports whether hunk h of the current file is collapsed. Hunks
+// of decided files and hunks that only change whitespace are collapsed
+// unless the reviewer unfolded them.
+func (m *Model) hunkFolded(h int) bool {
+	if folded, ok := m.foldedHunks[m.fileIndex][h]; ok {
+		return folded
+	}
+	d := m.decisions.Hunk(m.fileIndex, h)
+	return d == model.DecisionApproved || d == model.DecisionRejected || m.hunkWhitespaceOnly(h)
+}
+
+// hunkWhitespaceOnly reports whether hunk h of the current file only
+// changes whitespace.
+func (m *Model) hunkWhitespaceOnly(h int) bool {
+	if len(m.diffSet.Files) == 0 {
+		return false
+	}
+	frags := m.diffSet.Files[m.fileIndex].Fragments
+	return h >= 0 && h < len(frags) && diff.WhitespaceOnly(frags[h])
+}
+
+// generatedFolded reports whether the current file is a lockfile or other
+// generated file shown as a one-line summary. They start that way; z shows
+// the diff.
+func (m *Model) generatedFolded() bool {
+	return len(m.diffSet.Files) > 0 && !m.showGenerated[m.fileIndex] && m.diffSet.Files[m.fileIndex].Generated()
+}
+
+// toggleFold collapses or expands the hunk at the top of the viewport, or
+// expands a collapsed generated file.
+func (m *Model) toggleFold() {
+	if len(m.diffSet.Files) == 0 || len(m.diffSet.Files[m.fileIndex].Fragments) == 0 {
+		return
+	}
+	if m.generatedFolded() {
+		m.showGenerated[m.fileIndex] = true
+		m.updateLines()
+		m.scrollOffset = 0
+		return
+	}
+	h := m.currentHunk()
+	hunks := m.foldedHunks[m.fileIndex]
+	if hunks == nil {
+		hunks = make(map[int]bool)
+		m.foldedHunks[m.fileIndex] = hunks
+	}
+	hunks[h] = !m.hunkFolded(h)
+
+	m.updateLines()
+	for i, rl := range m.lines {
+		if rl.IsHunk && rl.Hunk == h {
+			m.scrollOffset = i
+			return
+		}
+	}
+}
+
+// foldLines collapses folded hunks to their header plus a placeholder and,
+// unless disabled, folds long runs of unchanged lines from the diff. Context
+// the reviewer expanded is never folded. A collapsed generated file becomes
+// its summary alone.
+func (m *Model) foldLines(lines []renderedLine) []renderedLine {
+	if m.generatedFolded() {
+		return []renderedLine{{
+			IsFold:  true,
+			Content: "  ⋯ " + m.diffSet.Files[m.fileIndex].GeneratedSummary() + " (generated), z to expand",
+		}}
+	}
+	var result []renderedLine
+	for i := 0; i < len(lines); {
+		rl := lines[i]
+		if rl.IsHunk {
+			if d, ok := m.decisions.Hunks[model.HunkKey{File: m.fileIndex, Hunk: rl.Hunk}]; ok {
+				if note := m.decisions.Note(m.fileIndex, rl.Hunk); note != "" {
+					rl.Content += "  [" + d.String() + ": " + note + "]"
+				} else {
+					rl.Content += "  [" + d.String() + "]"
+				}
+			}
+			if labels := m.decisions.Label(m.fileIndex, rl.Hunk); len(labels) > 0 {
+				rl.Content += "  " + formatLabels(labels)
+			}
+		}
+
+		if rl.IsHunk && m.hunkFolded(rl.Hunk) {
+			result = append(result, rl)
+			i++
+			var n, added, deleted int
+			for ; i < len(lines) && lines[i].Hunk == rl.Hunk && !lines[i].Expanded && !lines[i].IsHunk; i++ {
+				if lines[i].OldNum == 0 && lines[i].NewNum == 0 {
+					break // separator before the next hunk
+				}
+				n++
+				switch lines[i].Op {
+				case gitdiff.OpAdd:
+					added++
+				case gitdiff.OpDelete:
+					deleted++
+				}
+			}
+			what := "lines folded"
+			if m.hunkWhitespaceOnly(rl.Hunk) {
+				what = "lines of whitespace-only changes"
+			}
+			result = append(result, renderedLine{
+				IsFold:  true,
+				Hunk:    rl.Hunk,
+				Content: fmt.Sprintf("  ⋯ %d %s (+%d -%d), z to unfold", n, what, added, deleted),
+			})
+			continue
+		}
+
+		if m.foldContext && isDiffContext(rl) {
+			j := i
+			for j < len(lines) && isDiffContext(lines[j]) && lines[j].Hunk == rl.Hunk {
+				j++
+			}
+			if j-i > foldContextMin {
+				result = append(result, lines[i:i+foldContextKeep]...)
+				result = append(result, renderedLine{
+					IsFold:  true,
+					Hunk:    rl.Hunk,
+					Content: fmt.Sprintf("  ⋯ %d unchanged lines", j-i-2*foldContextKeep),
+				})
+				result = append(result, lines[j-foldContextKeep:j]...)
+			} else {
+				result = append(result, lines[i:j]...)
+			}
+			i = j
+			continue
+		}
+
+		result = append(result, rl)
+		i++
+	}
+	return result
+}
+
+// isDiffContext reports whether rl is an unchanged line from the diff itself.
+func isDiffContext(rl renderedLine) bool {
+	return rl.Op == gitdiff.OpContext && !rl.IsHunk && !rl.Expanded && (rl.OldNum > 0 || rl.NewNum > 0)
+}
@@ -0,0 +1,215 @@
+package tui
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/aezell/agrev/internal/group"
+	"github.com/aezell/agrev/internal/model"
+)
+
+// openGroups clusters the files of the diff being viewed into change groups
+// and shows the groups panel. Groups are rebuilt each time so they follow
+// commit switches and live reloads.
+func (m *Model) openGroups() {
+	m.groups = group.Build(m.diffSet, m.trace, m.analysisResults)
+	for i := range m.groups {
+		m.groups[i].Decision = m.groupDecision(m.groups[i])
+	}
+	m.groupsCursor = 0
+	for gi, g := range m.groups {
+		if slices.Contains(g.Files, m.diffSet.Files[m.fileIndex].Name()) {
+			m.groupsCursor = gi
+			break
+		}
+	}
+	m.showGroups = true
+}
+
+// groupFiles returns the indexes of g's files in the diff.
+func (m *Model) groupFiles(g model.ChangeGroup) []int {
+	var files []int
+	for i, f := range m.diffSet.Files {
+		if slices.Contains(g.Files, f.Name()) {
+			files = append(files, i)
+		}
+	}
+	return files
+}
+
+// groupDecision derives a group's decision from its files': rejected if any
+// is, partial if any is, approved once all are, pending otherwise.
+func (m *Model) groupDecision(g model.ChangeGroup) model.ReviewDecision {
+	files := m.groupFiles(g)
+	approved, partial := 0, false
+	for _, i := range files {
+		switch m.fileDecision(i) {
+		case model.DecisionRejected:
+			return model.DecisionRejected
+		case model.DecisionPartial:
+			partial = true
+		case model.DecisionApproved:
+			approved++
+		}
+	}
+	switch {
+	case partial:
+		return model.DecisionPartial
+	case len(files) > 0 && approved == len(files):
+		return model.DecisionApproved
+	}
+	return model.DecisionPending
+}
+
+// decideGroup records d for every file of the group under the cursor.
+func (m *Model) decideGroup(d model.ReviewDecision) {
+	if m.groupsCursor >= len(m.groups) {
+		return
+	}
+	g := &m.groups[m.groupsCursor]
+	verb, done := "approve", "approved"
+	if d == model.DecisionRejected {
+		verb, done = "reject", "rejected"
+	}
+	m.record(fmt.Sprintf("%s group %q", verb, g.Label))
+	files := m.groupFiles(*g)
+	for _, i := range files {
+		m.decideBulk(i, d, fmt.Sprintf("group %q", g.Label))
+	}
+	g.Decision = d
+	m.message = fmt.Sprintf("%s %d files in %q", done, len(g.Files), g.Label)
+	if d == model.DecisionApproved {
+		m.message += m.ownedInBulk(files)
+	}
+	m.relayout() // decided files render folded
+	if m.groupsCursor < len(m.groups)-1 {
+		m.groupsCursor++
+	}
+}
+
+func (m Model) updateGroupsPanel(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch {
+	case msg.Type == tea.KeyEsc, key.Matches(msg, keys.GroupsPanel):
+		m.showGroups = false
+	case key.Matches(msg, keys.Quit):
+		return m, tea.Quit
+	case key.Matches(msg, keys.Down):
+		if m.groupsCursor < len(m.groups)-1 {
+			m.groupsCursor++
+		}
+	case key.Matches(msg, keys.Up):
+		if m.groupsCursor > 0 {
+			m.groupsCursor--
+		}
+	case key.Matches(msg, keys.Approve):
+		m.decideGroup(model.DecisionApproved)
+	case key.Matches(msg, keys.Reject):
+		m.decideGroup(model.DecisionRejected)
+	case key.Matches(msg, keys.Undo):
+		m.undo()
+		for i := range m.groups {
+			m.groups[i].Decision = m.groupDecision(m.groups[i])
+		}
+	case msg.Type == tea.KeyEnter:
+		if m.groupsCursor < len(m.groups) {
+			if files := m.groupFiles(m.groups[m.groupsCursor]); len(files) > 0 {
+				m.showGroups = false
+				m.selectFile(files[0])
+			}
+		}
+	}
+	return m, nil
+}
+
+func (m Model) renderGroupsPanel() string {
+	boxWidth := m.width * 3 / 4
+	if boxWidth < 50 {
+		boxWidth = m.width - 4
+	}
+	listHeight := m.height - 8
+	if listHeight < 3 {
+		listHeight = 3
+	}
+
+	// Each group takes its label line, then its files
+	var lines []string
+	var styles []lipgloss.Style
+	cursorLine := 0
+	for gi, g := range m.groups {
+		if gi == m.groupsCursor {
+			cursorLine = len(lines)
+		}
+		var mark string
+		switch g.Decision {
+		case model.DecisionApproved:
+			mark = "V"
+		case model.DecisionRejected:
+			mark = "X"
+		case model.DecisionPartial:
+			mark = "~"
+		default:
+			mark = "-"
+		}
+		head := fmt.Sprintf("%s %-8s %s (%d files)", mark, g.Risk, g.Label, len(g.Files))
+		if len(g.DependsOn) > 0 {
+			head += "  after " + strings.Join(m.groupLabels(g.DependsOn), ", ")
+		}
+		lines = append(lines, head)
+		switch {
+		case gi == m.groupsCursor:
+			styles = append(styles, fileItemSelectedStyle.Width(boxWidth-4))
+		case g.Decision == model.DecisionApproved:
+			styles = append(styles, lipgloss.NewStyle().Foreground(colorGreen))
+		case g.Decision == model.DecisionRejected:
+			styles = append(styles, lipgloss.NewStyle().Foreground(colorRed))
+		default:
+			styles = append(styles, fileItemStyle)
+		}
+		for _, name := range g.Files {
+			lines = append(lines, "      "+name)
+			styles = append(styles, filePendingStyle)
+		}
+	}
+
+	var b strings.Builder
+	b.WriteString(fileHeaderStyle.Render(fmt.Sprintf("Change Groups (%d)", len(m.groups))))
+	b.WriteByte('\n')
+
+	if len(m.groups) == 0 {
+		b.WriteString(helpBarStyle.Render("No changes"))
+	}
+
+	// Keep the cursor's group in view
+	start := 0
+	if cursorLine >= listHeight {
+		start = cursorLine - listHeight + 1
+	}
+	end := min(start+listHeight, len(lines))
+	for i := start; i < end; i++ {
+		b.WriteString(styles[i].Render(truncate(lines[i], boxWidth-4)))
+		if i < end-1 {
+			b.WriteByte('\n')
+		}
+	}
+
+	b.WriteString("\n\n")
+	b.WriteString(helpBarStyle.Render("j/k move  a approve group  x reject group  u undo  enter review  esc close"))
+
+	box := fileListStyle.Width(boxWidth).Render(b.String())
+	return lipgloss.Place(m.width, m.height, lipgloss.Center, lipgloss.Center, box)
+}
+
+// groupLabels returns the labels of the groups with the given IDs.
+func (m Model) groupLabels(ids []string) []string {
+	var labels []string
+	for _, g := range m.groups {
+		if slices.Contains(ids, g.ID) {
+			labels = append(labels, g.Label)
+		}
+	}
+	return labels
+}
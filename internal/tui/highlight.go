@@ -0,0 +1,95 @@
+package tui
+
+import (
+	"github.com/bluekeyes/go-gitdiff/gitdiff"
+	"github.com/aezell/agrev/internal/diff"
+)
+
+// highlightMargin is how many lines above and below the viewport are
+// highlighted along with it, so scrolling a little finds them ready.
+const highlightMargin = 100
+
+// highlightLeadIn is how many code lines before those the lexer is given
+// first, so a window starting inside a comment or string is lexed from
+// outside it. Their tokens aren't kept.
+const highlightLeadIn = 200
+
+// lineKey identifies a code line of a file's diff across re-renders.
+type lineKey struct {
+	op       gitdiff.LineOp
+	old, new int
+}
+
+// isCode reports whether rl is a line of the file, which gets syntax colors.
+func (rl renderedLine) isCode() bool {
+	return (rl.OldNum > 0 || rl.NewNum > 0) && !rl.IsHunk && !rl.IsFinding && !rl.IsComment &&
+		!rl.IsFold && !rl.IsMove && !rl.IsAnnotation && !rl.IsPreview && !rl.IsSemantic
+}
+
+func (rl renderedLine) key() lineKey {
+	return lineKey{op: rl.Op, old: rl.OldNum, new: rl.NewNum}
+}
+
+// highlightVisible gives the code lines in and around the viewport their
+// syntax colors. Lines are highlighted the first time they come near the
+// viewport and kept for the file, so opening a huge file only highlights
+// the part of it on screen.
+func (m *Model) highlightVisible() {
+	if len(m.diffSet.Files) == 0 || len(m.lines) == 0 {
+		return
+	}
+	f := m.diffSet.Files[m.fileIndex]
+	cache := m.highlights[f]
+	if cache == nil {
+		cache = make(map[lineKey][]diff.Token)
+		m.highlights[f] = cache
+	}
+
+	from := min(max(m.scrollOffset, 0), len(m.lines)-1)
+	to := min(from+max(m.viewHeight, 1), len(m.lines))
+	if m.fillTokens(cache, from, to) {
+		return
+	}
+
+	// Highlight the window around the viewport, after the lead-in before it
+	start, end := max(from-highlightMargin, 0), min(to+highlightMargin, len(m.lines))
+	first := start
+	for i, n := start-1, 0; i >= 0 && n < highlightLeadIn; i-- {
+		if m.lines[i].isCode() {
+			first, n = i, n+1
+		}
+	}
+	var code []int
+	var text []string
+	for i := first; i < end; i++ {
+		if m.lines[i].isCode() {
+			code = append(code, i)
+			text = append(text, m.lines[i].Content)
+		}
+	}
+	for j, hl := range diff.HighlightLines(f.Name(), text) {
+		if j < len(code) && code[j] >= start {
+			cache[m.lines[code[j]].key()] = hl.Tokens
+		}
+	}
+	m.fillTokens(cache, start, end)
+}
+
+// fillTokens sets the tokens of the code lines from m.lines[from:to] that
+// cache has, and reports whether it had them all.
+func (m *Model) fillTokens(cache map[lineKey][]diff.Token, from, to int) bool {
+	all := true
+	for i := from; i < to; i++ {
+		rl := &m.lines[i]
+		if !rl.isCode() {
+			continue
+		}
+		tokens, ok := cache[rl.key()]
+		if !ok {
+			all = false
+			continue
+		}
+		rl.Tokens = tokens
+	}
+	return all
+}
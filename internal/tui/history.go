@@ -0,0 +1,158 @@
+package tui
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+
+	"github.com/aezell/agrev/internal/audit"
+	"github.com/aezell/agrev/internal/diff"
+	"github.com/aezell/agrev/internal/model"
+	"github.com/aezell/agrev/internal/review"
+)
+
+// maxHistory bounds the undo stack.
+const maxHistory = 500
+
+// reviewState is the review state that undo and redo restore. Decisions are
+// keyed by file name, and hunk ID for hunks, so snapshots survive switching
+// commits and live reloads.
+type reviewState struct {
+	decisions map[string]model.ReviewDecision
+	hunks     map[string]map[string]model.ReviewDecision
+	notes     map[string]string
+	bulk      map[string]bool
+	conds     map[string][]string
+	labels    map[string][]string
+	comments  []model.Comment
+}
+
+// historyEntry is a snapshot taken before a review action, with a label for
+// the status bar.
+type historyEntry struct {
+	state  reviewState
+	action string
+}
+
+// snapshot captures the current review state.
+func (m *Model) snapshot() reviewState {
+	m.stashDecisions()
+	return reviewState{
+		decisions: maps.Clone(m.nameDecisions),
+		hunks:     cloneHunks(m.nameHunks),
+		notes:     maps.Clone(m.nameNotes),
+		bulk:      maps.Clone(m.nameBulk),
+		conds:     maps.Clone(m.nameConds),
+		labels:    maps.Clone(m.nameLabels),
+		comments:  append([]model.Comment(nil), m.comments...),
+	}
+}
+
+// restore replaces the review state with s and returns the name of the first
+// file whose decision changed, if any.
+func (m *Model) restore(s reviewState) string {
+	before := m.decisions.Clone()
+	m.nameDecisions = maps.Clone(s.decisions)
+	m.nameHunks = cloneHunks(s.hunks)
+	m.nameNotes = maps.Clone(s.notes)
+	m.nameBulk = maps.Clone(s.bulk)
+	m.nameConds = maps.Clone(s.conds)
+	m.nameLabels = maps.Clone(s.labels)
+	m.unstashDecisions()
+	m.comments = append([]model.Comment(nil), s.comments...)
+
+	changed := ""
+	for i, f := range m.diffSet.Files {
+		n := len(f.Fragments)
+		if before.File(i, n) != m.decisions.File(i, n) || before.HunksDecided(i) != m.decisions.HunksDecided(i) {
+			changed = f.Name()
+			break
+		}
+	}
+	return changed
+}
+
+func cloneHunks(hunks map[string]map[string]model.ReviewDecision) map[string]map[string]model.ReviewDecision {
+	c := make(map[string]map[string]model.ReviewDecision, len(hunks))
+	for name, byID := range hunks {
+		c[name] = maps.Clone(byID)
+	}
+	return c
+}
+
+// splitHistory carries the undo and redo snapshots over a split of hunk h
+// of f, whose ID was id before, into n pieces: what they stashed for the
+// hunk goes to each piece, and their comments move as the live ones did.
+// The split itself isn't undone, so without this restoring a snapshot would
+// lose the hunk's decision.
+func (m *Model) splitHistory(f *diff.File, id string, h, n int) {
+	name := f.Name()
+	key := name + "\x00" + id
+	for _, stack := range [][]historyEntry{m.undoStack, m.redoStack} {
+		for i := range stack {
+			s := &stack[i].state
+			for p := h; p < h+n; p++ {
+				if d, ok := s.hunks[name][id]; ok {
+					s.hunks[name][hunkID(f, p)] = d
+				}
+				if note, ok := s.notes[key]; ok {
+					s.notes[noteKey(f, p)] = note
+				}
+				if labels, ok := s.labels[key]; ok {
+					s.labels[noteKey(f, p)] = slices.Clone(labels)
+				}
+			}
+			review.SplitComments(s.comments, f, h, n)
+		}
+	}
+}
+
+// record saves the review state before an action so it can be undone.
+// A new action discards anything that could have been redone.
+func (m *Model) record(action string) {
+	m.undoStack = append(m.undoStack, historyEntry{state: m.snapshot(), action: action})
+	if len(m.undoStack) > maxHistory {
+		m.undoStack = m.undoStack[len(m.undoStack)-maxHistory:]
+	}
+	m.redoStack = nil
+}
+
+// undo reverts the most recent review action.
+func (m *Model) undo() {
+	if len(m.undoStack) == 0 {
+		m.message = "nothing to undo"
+		return
+	}
+	e := m.undoStack[len(m.undoStack)-1]
+	m.undoStack = m.undoStack[:len(m.undoStack)-1]
+	m.redoStack = append(m.redoStack, historyEntry{state: m.snapshot(), action: e.action})
+	m.showRestored(m.restore(e.state))
+	m.message = fmt.Sprintf("undid %s", e.action)
+	m.logAction(audit.Event{Action: audit.ActionUndo, Detail: e.action})
+}
+
+// redo reapplies the most recently undone review action.
+func (m *Model) redo() {
+	if len(m.redoStack) == 0 {
+		m.message = "nothing to redo"
+		return
+	}
+	e := m.redoStack[len(m.redoStack)-1]
+	m.redoStack = m.redoStack[:len(m.redoStack)-1]
+	m.undoStack = append(m.undoStack, historyEntry{state: m.snapshot(), action: e.action})
+	m.showRestored(m.restore(e.state))
+	m.message = fmt.Sprintf("redid %s", e.action)
+	m.logAction(audit.Event{Action: audit.ActionRedo, Detail: e.action})
+}
+
+// showRestored moves to the file whose decision an undo or redo changed, so
+// the reviewer sees its effect, and re-renders otherwise.
+func (m *Model) showRestored(name string) {
+	for i, f := range m.diffSet.Files {
+		if f.Name() == name && i != m.fileIndex {
+			m.selectFile(i)
+			return
+		}
+	}
+	m.relayout()
+}
@@ -3,24 +3,60 @@ package tui
 import "github.com/charmbracelet/bubbles/key"
 
 type keyMap struct {
-	Up          key.Binding
-	Down        key.Binding
-	NextFile    key.Binding
-	PrevFile    key.Binding
-	NextHunk    key.Binding
-	PrevHunk    key.Binding
-	NextFinding key.Binding
-	PrevFinding key.Binding
-	Toggle      key.Binding
-	Trace       key.Binding
-	FocusSwap   key.Binding
-	Search      key.Binding
-	Help        key.Binding
-	Approve     key.Binding
-	Reject      key.Binding
-	Undo        key.Binding
-	Finish      key.Binding
-	Quit        key.Binding
+	Up             key.Binding
+	Down           key.Binding
+	NextFile       key.Binding
+	PrevFile       key.Binding
+	NextCommit     key.Binding
+	PrevCommit     key.Binding
+	NextHunk       key.Binding
+	PrevHunk       key.Binding
+	NextFinding    key.Binding
+	PrevFinding    key.Binding
+	FindingsPanel  key.Binding
+	GroupsPanel    key.Binding
+	Checklist      key.Binding
+	Toggle         key.Binding
+	Expand         key.Binding
+	ExpandMore     key.Binding
+	WholeFile      key.Binding
+	Semantic       key.Binding
+	Fold           key.Binding
+	FoldContext    key.Binding
+	Trace          key.Binding
+	Timeline       key.Binding
+	FocusSwap      key.Binding
+	Search         key.Binding
+	FindFile       key.Binding
+	NextMatch      key.Binding
+	PrevMatch      key.Binding
+	Help           key.Binding
+	Approve        key.Binding
+	ApproveWith    key.Binding
+	Reject         key.Binding
+	ApproveHunk    key.Binding
+	RejectHunk     key.Binding
+	SplitHunk      key.Binding
+	ApproveSpace   key.Binding
+	Undo           key.Binding
+	Redo           key.Binding
+	Comment        key.Binding
+	Label          key.Binding
+	LabelHunk      key.Binding
+	Edit           key.Binding
+	Explain        key.Binding
+	CopyHunk       key.Binding
+	CopyFile       key.Binding
+	FilterPending  key.Binding
+	FilterHighRisk key.Binding
+	FilterFindings key.Binding
+	FilterNew      key.Binding
+	FilterLabel    key.Binding
+	FilterClear    key.Binding
+	Sort           key.Binding
+	Queue          key.Binding
+	Finish         key.Binding
+	Quit           key.Binding
 }
 
 var keys = keyMap{
@@ -40,6 +76,14 @@ var keys = keyMap{
 		key.WithKeys("N"),
 		key.WithHelp("N", "prev file"),
 	),
+	NextCommit: key.NewBinding(
+		key.WithKeys(">"),
+		key.WithHelp(">", "next commit"),
+	),
+	PrevCommit: key.NewBinding(
+		key.WithKeys("<"),
+		key.WithHelp("<", "prev commit"),
+	),
 	NextHunk: key.NewBinding(
 		key.WithKeys("]"),
 		key.WithHelp("]", "next hunk"),
@@ -56,14 +100,54 @@ var keys = keyMap{
 		key.WithKeys("F"),
 		key.WithHelp("F", "prev finding"),
 	),
+	FindingsPanel: key.NewBinding(
+		key.WithKeys("!"),
+		key.WithHelp("!", "findings panel"),
+	),
+	GroupsPanel: key.NewBinding(
+		key.WithKeys("g"),
+		key.WithHelp("g", "change groups"),
+	),
+	Checklist: key.NewBinding(
+		key.WithKeys("L"),
+		key.WithHelp("L", "review checklist"),
+	),
 	Toggle: key.NewBinding(
 		key.WithKeys("v"),
 		key.WithHelp("v", "unified/split"),
 	),
+	Expand: key.NewBinding(
+		key.WithKeys("+"),
+		key.WithHelp("+", "expand context 5 lines"),
+	),
+	ExpandMore: key.NewBinding(
+		key.WithKeys("*"),
+		key.WithHelp("*", "expand context 20 lines"),
+	),
+	WholeFile: key.NewBinding(
+		key.WithKeys("w"),
+		key.WithHelp("w", "whole-file view"),
+	),
+	Semantic: key.NewBinding(
+		key.WithKeys("S"),
+		key.WithHelp("S", "declaration summary"),
+	),
+	Fold: key.NewBinding(
+		key.WithKeys("z"),
+		key.WithHelp("z", "fold hunk"),
+	),
+	FoldContext: key.NewBinding(
+		key.WithKeys("Z"),
+		key.WithHelp("Z", "fold unchanged runs"),
+	),
 	Trace: key.NewBinding(
 		key.WithKeys("t"),
 		key.WithHelp("t", "toggle trace"),
 	),
+	Timeline: key.NewBinding(
+		key.WithKeys("T"),
+		key.WithHelp("T", "trace timeline"),
+	),
 	FocusSwap: key.NewBinding(
 		key.WithKeys("tab"),
 		key.WithHelp("tab", "switch panel"),
@@ -72,6 +156,18 @@ var keys = keyMap{
 		key.WithKeys("/"),
 		key.WithHelp("/", "search"),
 	),
+	FindFile: key.NewBinding(
+		key.WithKeys("ctrl+p"),
+		key.WithHelp("ctrl+p", "find file"),
+	),
+	NextMatch: key.NewBinding(
+		key.WithKeys("n"),
+		key.WithHelp("n", "next match"),
+	),
+	PrevMatch: key.NewBinding(
+		key.WithKeys("p"),
+		key.WithHelp("p", "prev match"),
+	),
 	Help: key.NewBinding(
 		key.WithKeys("?"),
 		key.WithHelp("?", "help"),
@@ -80,13 +176,97 @@ var keys = keyMap{
 		key.WithKeys("a"),
 		key.WithHelp("a", "approve file"),
 	),
+	ApproveWith: key.NewBinding(
+		key.WithKeys("C"),
+		key.WithHelp("C", "approve file with follow-ups"),
+	),
 	Reject: key.NewBinding(
 		key.WithKeys("x"),
 		key.WithHelp("x", "reject file"),
 	),
+	ApproveHunk: key.NewBinding(
+		key.WithKeys("A"),
+		key.WithHelp("A", "approve hunk"),
+	),
+	RejectHunk: key.NewBinding(
+		key.WithKeys("X"),
+		key.WithHelp("X", "reject hunk"),
+	),
+	SplitHunk: key.NewBinding(
+		key.WithKeys("|"),
+		key.WithHelp("|", "split hunk"),
+	),
+	ApproveSpace: key.NewBinding(
+		key.WithKeys("W"),
+		key.WithHelp("W", "approve whitespace-only files"),
+	),
 	Undo: key.NewBinding(
 		key.WithKeys("u"),
-		key.WithHelp("u", "undo decision"),
+		key.WithHelp("u", "undo"),
+	),
+	Redo: key.NewBinding(
+		key.WithKeys("ctrl+r"),
+		key.WithHelp("ctrl+r", "redo"),
+	),
+	Comment: key.NewBinding(
+		key.WithKeys("c"),
+		key.WithHelp("c", "comment on line"),
+	),
+	Label: key.NewBinding(
+		key.WithKeys("b"),
+		key.WithHelp("b", "label file"),
+	),
+	LabelHunk: key.NewBinding(
+		key.WithKeys("B"),
+		key.WithHelp("B", "label hunk"),
+	),
+	Edit: key.NewBinding(
+		key.WithKeys("e"),
+		key.WithHelp("e", "open in $EDITOR"),
+	),
+	Explain: key.NewBinding(
+		key.WithKeys("E"),
+		key.WithHelp("E", "explain hunk"),
+	),
+	CopyHunk: key.NewBinding(
+		key.WithKeys("y"),
+		key.WithHelp("y", "copy hunk"),
+	),
+	CopyFile: key.NewBinding(
+		key.WithKeys("Y"),
+		key.WithHelp("Y", "copy file patch"),
+	),
+	FilterPending: key.NewBinding(
+		key.WithKeys("1"),
+		key.WithHelp("1", "only pending files"),
+	),
+	FilterHighRisk: key.NewBinding(
+		key.WithKeys("2"),
+		key.WithHelp("2", "only high-risk files"),
+	),
+	FilterFindings: key.NewBinding(
+		key.WithKeys("3"),
+		key.WithHelp("3", "only files with findings"),
+	),
+	FilterNew: key.NewBinding(
+		key.WithKeys("4"),
+		key.WithHelp("4", "only new files"),
+	),
+	FilterLabel: key.NewBinding(
+		key.WithKeys("5"),
+		key.WithHelp("5", "cycle label filter"),
+	),
+	FilterClear: key.NewBinding(
+		key.WithKeys("0"),
+		key.WithHelp("0", "clear filters"),
+	),
+	Sort: key.NewBinding(
+		key.WithKeys("s"),
+		key.WithHelp("s", "cycle file sort"),
+	),
+	Queue: key.NewBinding(
+		key.WithKeys("Q"),
+		key.WithHelp("Q", "queue mode"),
 	),
 	Finish: key.NewBinding(
 		key.WithKeys("enter"),
@@ -0,0 +1,154 @@
+package tui
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/aezell/agrev/internal/audit"
+	"github.com/aezell/agrev/internal/model"
+)
+
+func newLabelInput() textinput.Model {
+	ti := textinput.New()
+	ti.CharLimit = 200
+	return ti
+}
+
+// askLabel opens the prompt for labels on file i, or hunk h of it.
+func (m *Model) askLabel(i, h int) tea.Cmd {
+	m.labeling = true
+	m.labelTarget = model.HunkKey{File: i, Hunk: h}
+	m.labelFile = m.diffSet.Files[i].Name()
+	m.labelInput.Reset()
+	m.labelInput.Placeholder = "e.g. needs-security-review; an existing label removes it"
+	return m.labelInput.Focus()
+}
+
+func (m Model) updateLabel(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.Type {
+	case tea.KeyEnter:
+		t := m.labelTarget
+		if t.File < len(m.diffSet.Files) && m.diffSet.Files[t.File].Name() == m.labelFile {
+			m.toggleLabels(t, strings.FieldsFunc(m.labelInput.Value(), func(r rune) bool {
+				return r == ',' || r == ' '
+			}))
+		}
+		m.labeling = false
+		m.labelInput.Blur()
+		return m, nil
+	case tea.KeyEsc:
+		m.labeling = false
+		m.labelInput.Blur()
+		return m, nil
+	}
+
+	var cmd tea.Cmd
+	m.labelInput, cmd = m.labelInput.Update(msg)
+	return m, cmd
+}
+
+// toggleLabels adds each of labels to the file or hunk t, or removes it if
+// it is there already, as one undoable action.
+func (m *Model) toggleLabels(t model.HunkKey, labels []string) {
+	var add, remove []string
+	for _, l := range labels {
+		l = model.NormalizeLabel(l)
+		switch {
+		case l == "" || slices.Contains(add, l) || slices.Contains(remove, l):
+		case slices.Contains(m.decisions.Label(t.File, t.Hunk), l):
+			remove = append(remove, l)
+		default:
+			add = append(add, l)
+		}
+	}
+	if len(add)+len(remove) == 0 {
+		return
+	}
+
+	what := m.labelFile
+	if t.Hunk != model.WholeFile {
+		what = fmt.Sprintf("hunk %d of %s", t.Hunk+1, m.labelFile)
+	}
+	m.record("label " + what)
+	for _, l := range add {
+		m.decisions.AddLabel(t.File, t.Hunk, l)
+		m.logAction(audit.Event{Action: audit.ActionLabel, File: m.labelFile, Hunk: t.Hunk + 1, Detail: l})
+	}
+	for _, l := range remove {
+		m.decisions.RemoveLabel(t.File, t.Hunk, l)
+		m.logAction(audit.Event{Action: audit.ActionUnlabel, File: m.labelFile, Hunk: t.Hunk + 1, Detail: l})
+	}
+	if m.labelFilter != "" && !m.fileVisible(m.fileIndex) {
+		if visible := m.visibleFiles(); len(visible) > 0 {
+			m.selectFile(visible[0])
+		}
+	}
+	m.relayout()
+}
+
+func (m Model) renderLabelBar() string {
+	what := m.labelFile
+	if m.labelTarget.Hunk != model.WholeFile {
+		what = fmt.Sprintf("hunk %d of %s", m.labelTarget.Hunk+1, m.labelFile)
+	}
+	prompt := " Label " + what
+	if labels := m.decisions.Label(m.labelTarget.File, m.labelTarget.Hunk); len(labels) > 0 {
+		prompt += " (" + formatLabels(labels) + ")"
+	}
+	return lipgloss.NewStyle().
+		Foreground(colorFg).
+		Background(colorBgLight).
+		Width(m.width).
+		Render(statusKeyStyle.Render(prompt+": ") + " " + m.labelInput.View())
+}
+
+// formatLabels shows labels as tags, e.g. "#needs-security-review #docs".
+func formatLabels(labels []string) string {
+	tags := make([]string, len(labels))
+	for i, l := range labels {
+		tags[i] = "#" + l
+	}
+	return strings.Join(tags, " ")
+}
+
+// labelsInUse returns the labels on any file or hunk of the diff, sorted.
+func (m *Model) labelsInUse() []string {
+	var labels []string
+	for _, l := range m.decisions.Labels {
+		labels = append(labels, l...)
+	}
+	slices.Sort(labels)
+	return slices.Compact(labels)
+}
+
+// cycleLabelFilter narrows the file list to the files carrying the next
+// label in use, on them or their hunks, and then back to all files.
+func (m *Model) cycleLabelFilter() {
+	labels := m.labelsInUse()
+	if len(labels) == 0 {
+		m.labelFilter = ""
+		m.message = "no labels yet; b labels a file, B a hunk"
+		return
+	}
+	next := 0
+	if pos, found := slices.BinarySearch(labels, m.labelFilter); m.labelFilter != "" {
+		next = pos
+		if found {
+			next++
+		}
+	}
+	m.labelFilter = ""
+	if next < len(labels) {
+		m.labelFilter = labels[next]
+	}
+	if len(m.diffSet.Files) == 0 || m.fileVisible(m.fileIndex) {
+		return
+	}
+	if visible := m.visibleFiles(); len(visible) > 0 {
+		m.selectFile(visible[0])
+	}
+}
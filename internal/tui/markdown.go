@@ -0,0 +1,289 @@
+package tui
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/aezell/agrev/internal/diff"
+)
+
+// renderMarkdown renders the markdown subset agents tend to write (headings,
+// fenced code, lists, block quotes, rules, and inline emphasis, code, and
+// links) as styled lines wrapped to width.
+func renderMarkdown(src string, width int) []string {
+	width = max(width, 10)
+	var out []string
+	var para []string
+
+	flush := func() {
+		if len(para) > 0 {
+			out = append(out, wrapInline(strings.Join(para, " "), width, "", "", contextLineStyle)...)
+			para = nil
+		}
+	}
+	blank := func() {
+		if len(out) > 0 && out[len(out)-1] != "" {
+			out = append(out, "")
+		}
+	}
+
+	lines := diff.SplitLines(src)
+	for i := 0; i < len(lines); i++ {
+		line := strings.TrimRight(lines[i], " \t")
+		trimmed := strings.TrimLeft(line, " \t")
+		indent := len(line) - len(trimmed)
+
+		switch {
+		case trimmed == "":
+			flush()
+			blank()
+
+		case strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~"):
+			flush()
+			fence := trimmed[:3]
+			lang := strings.TrimSpace(trimmed[3:])
+			var code []string
+			for i++; i < len(lines) && !strings.HasPrefix(strings.TrimSpace(lines[i]), fence); i++ {
+				code = append(code, strings.ReplaceAll(lines[i], "\t", "    "))
+			}
+			out = append(out, renderCodeBlock(lang, code, width)...)
+
+		case isHeading(trimmed):
+			flush()
+			blank()
+			level := strings.IndexFunc(trimmed, func(r rune) bool { return r != '#' })
+			text := strings.TrimSpace(strings.TrimRight(trimmed[level:], "#"))
+			style := mdHeadingStyle
+			if level == 1 {
+				style = mdTitleStyle
+			}
+			out = append(out, wrapInline(text, width, "", "", style)...)
+
+		case isRule(trimmed):
+			flush()
+			out = append(out, mdRuleStyle.Render(strings.Repeat("─", width)))
+
+		case strings.HasPrefix(trimmed, ">"):
+			flush()
+			text := strings.TrimSpace(strings.TrimPrefix(trimmed, ">"))
+			bar := mdRuleStyle.Render("│ ")
+			out = append(out, wrapInline(text, width, bar, bar, mdQuoteStyle)...)
+
+		default:
+			if marker, text, ok := listItem(trimmed); ok {
+				flush()
+				pad := strings.Repeat(" ", indent/2*2)
+				first := pad + mdBulletStyle.Render(marker) + " "
+				rest := pad + strings.Repeat(" ", utf8.RuneCountInString(marker)+1)
+				out = append(out, wrapInline(text, width, first, rest, contextLineStyle)...)
+				continue
+			}
+			para = append(para, trimmed)
+		}
+	}
+	flush()
+
+	for len(out) > 0 && out[len(out)-1] == "" {
+		out = out[:len(out)-1]
+	}
+	return out
+}
+
+func isHeading(s string) bool {
+	n := strings.IndexFunc(s, func(r rune) bool { return r != '#' })
+	return n >= 1 && n <= 6 && s[n] == ' '
+}
+
+func isRule(s string) bool {
+	if len(s) < 3 {
+		return false
+	}
+	c := s[0]
+	if c != '-' && c != '*' && c != '_' {
+		return false
+	}
+	return strings.Trim(strings.ReplaceAll(s, " ", ""), string(c)) == ""
+}
+
+// listItem splits a bullet or numbered list line into its display marker and
+// text.
+func listItem(s string) (marker, text string, ok bool) {
+	if len(s) >= 2 && strings.ContainsRune("-*+", rune(s[0])) && s[1] == ' ' {
+		return "•", strings.TrimSpace(s[2:]), true
+	}
+	digits := strings.IndexFunc(s, func(r rune) bool { return r < '0' || r > '9' })
+	if digits > 0 && digits+1 < len(s) && (s[digits] == '.' || s[digits] == ')') && s[digits+1] == ' ' {
+		return s[:digits+1], strings.TrimSpace(s[digits+2:]), true
+	}
+	return "", "", false
+}
+
+// renderCodeBlock renders fenced code with syntax highlighting on a tinted
+// background, truncating long lines.
+func renderCodeBlock(lang string, code []string, width int) []string {
+	return renderHighlighted(code, diff.HighlightSource(lang, code), width, mdCodeBlockStyle, " ")
+}
+
+// renderHighlighted renders code lines with their highlighting tokens over
+// base, each line led by marker and padded to width.
+func renderHighlighted(code []string, highlighted []diff.HighlightedLine, width int, base lipgloss.Style, marker string) []string {
+	out := make([]string, 0, len(code))
+	for i, line := range code {
+		text := truncate(line, width-1-len(marker))
+		tokens := highlighted[i].Tokens
+		if highlighted[i].Plain() != line {
+			tokens = []diff.Token{{Text: line}}
+		}
+
+		var b strings.Builder
+		b.WriteString(base.Render(marker))
+		pos := 0
+		for _, tok := range tokens {
+			if pos >= len(text) {
+				break
+			}
+			end := min(pos+len(tok.Text), len(text))
+			style := base
+			if tok.Color != "" {
+				style = style.Foreground(lipgloss.Color(tok.Color))
+			}
+			b.WriteString(style.Render(text[pos:end]))
+			pos = end
+		}
+		if pos < len(text) {
+			b.WriteString(base.Render(text[pos:]))
+		}
+		if pad := width - len(marker) - lipgloss.Width(text); pad > 0 {
+			b.WriteString(base.Render(strings.Repeat(" ", pad)))
+		}
+		out = append(out, b.String())
+	}
+	return out
+}
+
+// inlineSpan is a run of text sharing one inline style.
+type inlineSpan struct {
+	text  string
+	style lipgloss.Style
+}
+
+// parseInline splits text into spans for **bold**, *italic* / _italic_,
+// `code`, and [links](url), with everything else in base.
+func parseInline(text string, base lipgloss.Style) []inlineSpan {
+	var spans []inlineSpan
+	var plain strings.Builder
+	emit := func(s string, style lipgloss.Style) {
+		if plain.Len() > 0 {
+			spans = append(spans, inlineSpan{plain.String(), base})
+			plain.Reset()
+		}
+		spans = append(spans, inlineSpan{s, style})
+	}
+
+	for i := 0; i < len(text); {
+		rest := text[i:]
+		switch {
+		case rest[0] == '`':
+			if end := strings.IndexByte(rest[1:], '`'); end >= 0 {
+				emit(rest[1:1+end], mdCodeStyle)
+				i += end + 2
+				continue
+			}
+		case strings.HasPrefix(rest, "**") || strings.HasPrefix(rest, "__"):
+			if end := strings.Index(rest[2:], rest[:2]); end > 0 {
+				emit(rest[2:2+end], base.Bold(true))
+				i += end + 4
+				continue
+			}
+		case rest[0] == '*' || rest[0] == '_':
+			// Only treat as emphasis when it opens a word, so snake_case survives
+			if (i == 0 || text[i-1] == ' ') && len(rest) > 1 && rest[1] != ' ' {
+				if end := strings.IndexByte(rest[1:], rest[0]); end > 0 {
+					emit(rest[1:1+end], base.Italic(true))
+					i += end + 2
+					continue
+				}
+			}
+		case rest[0] == '[':
+			if mid := strings.Index(rest, "]("); mid > 0 {
+				if end := strings.IndexByte(rest[mid:], ')'); end > 0 {
+					emit(rest[1:mid], base.Underline(true))
+					emit(" ("+rest[mid+2:mid+end]+")", mdRuleStyle)
+					i += mid + end + 1
+					continue
+				}
+			}
+		}
+		plain.WriteByte(text[i])
+		i++
+	}
+	if plain.Len() > 0 {
+		spans = append(spans, inlineSpan{plain.String(), base})
+	}
+	return spans
+}
+
+// wrapInline word-wraps text with inline markup to width. The first line is
+// prefixed with first and continuation lines with rest; both are already
+// styled and their display widths are subtracted from width.
+func wrapInline(text string, width int, first, rest string, base lipgloss.Style) []string {
+	var out []string
+	var b strings.Builder
+	prefix := first
+	avail := max(width-lipgloss.Width(prefix), 1)
+	col := 0
+
+	newLine := func() {
+		out = append(out, prefix+b.String())
+		b.Reset()
+		prefix = rest
+		avail = max(width-lipgloss.Width(prefix), 1)
+		col = 0
+	}
+
+	// Spaces are held back until the next word lands on the same line, so
+	// wrapped lines don't end in a space.
+	space := false
+	for _, sp := range parseInline(text, base) {
+		words := strings.Split(sp.text, " ")
+		for wi, word := range words {
+			if wi > 0 && col > 0 {
+				space = true
+			}
+			for word != "" {
+				w := utf8.RuneCountInString(word)
+				if space {
+					if col+1+w > avail {
+						newLine()
+					} else {
+						b.WriteString(sp.style.Render(" "))
+						col++
+					}
+					space = false
+				} else if col > 0 && col+w > avail {
+					newLine()
+				}
+				if w > avail {
+					// Hard-break words longer than a whole line
+					cut := 0
+					for n := 0; n < avail; n++ {
+						_, size := utf8.DecodeRuneInString(word[cut:])
+						cut += size
+					}
+					b.WriteString(sp.style.Render(word[:cut]))
+					word = word[cut:]
+					col = avail
+					continue
+				}
+				b.WriteString(sp.style.Render(word))
+				col += w
+				word = ""
+			}
+		}
+	}
+	if b.Len() > 0 || len(out) == 0 {
+		out = append(out, prefix+b.String())
+	}
+	return out
+}
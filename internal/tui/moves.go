@@ -0,0 +1,43 @@
+package tui
+
+import (
+	"fmt"
+
+	"github.com/bluekeyes/go-gitdiff/gitdiff"
+	"github.com/aezell/agrev/internal/model"
+)
+
+// markMoves flags the current file's lines that were moved to or from
+// another file and puts a note naming the other side above each block.
+func (m *Model) markMoves(lines []renderedLine) []renderedLine {
+	if len(m.diffSet.Moves) == 0 {
+		return lines
+	}
+	f := m.diffSet.Files[m.fileIndex]
+
+	var result []renderedLine
+	var prev model.Move
+	inMove := false
+	for _, rl := range lines {
+		var mv model.Move
+		var moved bool
+		switch {
+		case rl.IsHunk || rl.IsFold:
+		case rl.Op == gitdiff.OpDelete:
+			mv, moved = m.diffSet.MoveAt(f, rl.OldNum, true)
+		case rl.Op == gitdiff.OpAdd:
+			mv, moved = m.diffSet.MoveAt(f, rl.NewNum, false)
+		}
+		if moved && (!inMove || mv != prev) {
+			note := fmt.Sprintf("  ↪ moved to %s:%d-%d", mv.ToFile, mv.To.Start, mv.To.End)
+			if rl.Op == gitdiff.OpAdd {
+				note = fmt.Sprintf("  ↩ moved from %s:%d-%d", mv.FromFile, mv.From.Start, mv.From.End)
+			}
+			result = append(result, renderedLine{IsMove: true, Hunk: rl.Hunk, Content: note})
+		}
+		prev, inMove = mv, moved
+		rl.Moved = moved
+		result = append(result, rl)
+	}
+	return result
+}
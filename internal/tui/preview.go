@@ -0,0 +1,180 @@
+package tui
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/aezell/agrev/internal/diff"
+)
+
+// Thumbnail bounds in terminal cells. Each cell shows two pixels stacked
+// with a half block, so pixels come out roughly square.
+const (
+	thumbMaxCols = 64
+	thumbMaxRows = 24
+)
+
+// binaryPreview renders the lines shown in place of a diff for a binary
+// file: old and new sizes plus, for images, a thumbnail of the new version.
+// Text git took for binary, like UTF-16, is decoded and diffed instead.
+func (m *Model) binaryPreview(f *diff.File) []renderedLine {
+	oldData, oldErr := diff.OldBytes(m.repoDir, f)
+	newData, newErr := diff.NewBytes(m.repoDir, f)
+	if (oldErr == nil || f.IsNew) && (newErr == nil || f.IsDeleted) {
+		if decoded, enc, ok := diff.Decoded(f, oldData, newData); ok {
+			return append([]renderedLine{{IsPreview: true, Content: foldStyle.Render("  " + enc + " text, decoded")}}, renderFile(decoded)...)
+		}
+	}
+
+	lines := []renderedLine{{IsHunk: true, Content: "Binary file"}}
+	text := func(s string) {
+		lines = append(lines, renderedLine{IsPreview: true, Content: contextLineStyle.Render(s)})
+	}
+
+	size := func(data []byte, err error) string {
+		if err != nil {
+			return "—"
+		}
+		return formatBytes(len(data))
+	}
+	sizes := fmt.Sprintf("old: %s  new: %s", size(oldData, oldErr), size(newData, newErr))
+	if oldErr == nil && newErr == nil {
+		delta := len(newData) - len(oldData)
+		sign := "+"
+		if delta < 0 {
+			sign, delta = "-", -delta
+		}
+		sizes += fmt.Sprintf("  (%s%s)", sign, formatBytes(delta))
+	}
+	text(sizes)
+
+	if newErr != nil {
+		if !f.IsDeleted {
+			text(fmt.Sprintf("can't read new version: %v", newErr))
+		}
+		return lines
+	}
+	img, format, err := image.Decode(bytes.NewReader(newData))
+	if err != nil {
+		return lines
+	}
+	b := img.Bounds()
+	text(fmt.Sprintf("%s image, %d×%d", strings.ToUpper(format), b.Dx(), b.Dy()))
+	lines = append(lines, renderedLine{IsPreview: true})
+	for _, row := range thumbnail(img, thumbMaxCols, thumbMaxRows) {
+		lines = append(lines, renderedLine{IsPreview: true, Content: row})
+	}
+	return lines
+}
+
+// submodulePreview renders the lines shown in place of a diff for a
+// submodule: the commits it pointed at and, when the submodule is checked
+// out, the commits gained (>) and dropped (<) between them.
+func (m *Model) submodulePreview(f *diff.File) []renderedLine {
+	lines := []renderedLine{{IsHunk: true, Content: "Submodule"}}
+	text := func(style lipgloss.Style, s string) {
+		lines = append(lines, renderedLine{IsPreview: true, Content: style.Render(s)})
+	}
+	short := func(hash string) string { return hash[:min(len(hash), 7)] }
+
+	switch {
+	case f.OldCommit == "":
+		text(contextLineStyle, fmt.Sprintf("added at %s", short(f.NewCommit)))
+	case f.NewCommit == "":
+		text(contextLineStyle, fmt.Sprintf("removed, was at %s", short(f.OldCommit)))
+	case f.OldCommit == f.NewCommit:
+		text(contextLineStyle, fmt.Sprintf("still at %s", short(f.NewCommit)))
+	default:
+		text(contextLineStyle, fmt.Sprintf("%s → %s", short(f.OldCommit), short(f.NewCommit)))
+	}
+	if f.Dirty {
+		text(findingMediumStyle, "working tree has uncommitted changes")
+	}
+
+	log, err := diff.SubmoduleLog(m.repoDir, f)
+	if err != nil {
+		text(foldStyle, fmt.Sprintf("can't list commits: %v", err))
+		return lines
+	}
+	if len(log) > 0 {
+		lines = append(lines, renderedLine{IsPreview: true})
+	}
+	for _, l := range log {
+		style := addedLineStyle
+		if strings.HasPrefix(l, "<") {
+			style = deletedLineStyle
+		}
+		text(style, l)
+	}
+	return lines
+}
+
+// thumbnail renders img scaled to fit within cols×rows cells using upper
+// half blocks colored with the top pixel as foreground and the bottom pixel
+// as background. Transparent pixels are blended onto the theme background.
+func thumbnail(img image.Image, cols, rows int) []string {
+	b := img.Bounds()
+	if b.Dx() == 0 || b.Dy() == 0 {
+		return nil
+	}
+	w, h := b.Dx(), b.Dy()
+	scale := max(float64(w)/float64(cols), float64(h)/float64(rows*2), 1)
+	outW := max(int(float64(w)/scale), 1)
+	outH := max(int(float64(h)/scale), 1)
+
+	bg := mustRGB(colorBg)
+	pixel := func(x, y int) string {
+		// Average the source box covered by this output pixel
+		x0, x1 := b.Min.X+int(float64(x)*scale), b.Min.X+int(float64(x+1)*scale)
+		y0, y1 := b.Min.Y+int(float64(y)*scale), b.Min.Y+int(float64(y+1)*scale)
+		x1, y1 = max(x1, x0+1), max(y1, y0+1)
+		var r, g, bl, a, n uint64
+		for sy := y0; sy < y1; sy++ {
+			for sx := x0; sx < x1; sx++ {
+				c := color.NRGBAModel.Convert(img.At(sx, sy)).(color.NRGBA)
+				r += uint64(c.R)
+				g += uint64(c.G)
+				bl += uint64(c.B)
+				a += uint64(c.A)
+				n++
+			}
+		}
+		blend := func(v uint64, bgv int) int {
+			alpha := float64(a) / float64(n) / 255
+			return int(float64(v)/float64(n)*alpha + float64(bgv)*(1-alpha))
+		}
+		return fmt.Sprintf("#%02x%02x%02x", blend(r, bg[0]), blend(g, bg[1]), blend(bl, bg[2]))
+	}
+
+	var out []string
+	for y := 0; y < outH; y += 2 {
+		var sb strings.Builder
+		for x := 0; x < outW; x++ {
+			style := lipgloss.NewStyle().Foreground(lipgloss.Color(pixel(x, y)))
+			if y+1 < outH {
+				style = style.Background(lipgloss.Color(pixel(x, y+1)))
+			}
+			sb.WriteString(style.Render("▀"))
+		}
+		out = append(out, sb.String())
+	}
+	return out
+}
+
+// formatBytes formats a byte count for display.
+func formatBytes(n int) string {
+	switch {
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1f KB", float64(n)/(1<<10))
+	}
+	return fmt.Sprintf("%d B", n)
+}
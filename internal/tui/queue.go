@@ -0,0 +1,85 @@
+package tui
+
+import (
+	"fmt"
+
+	"github.com/aezell/agrev/internal/model"
+)
+
+// toggleQueue switches queue mode, which presents one file at a time in
+// descending risk order so the dangerous changes get cleared first.
+func (m *Model) toggleQueue() {
+	m.queueMode = !m.queueMode
+	if !m.queueMode {
+		m.sortMode = m.queueSavedSort
+		return
+	}
+	m.queueSavedSort = m.sortMode
+	m.sortMode = sortRisk
+	if len(m.diffSet.Files) == 0 {
+		return
+	}
+	// Start at the riskiest file still waiting for a decision
+	visible := m.visibleFiles()
+	for _, i := range visible {
+		if !m.fileDecided(i) {
+			m.selectFile(i)
+			return
+		}
+	}
+	if len(visible) > 0 {
+		m.selectFile(visible[0])
+	}
+}
+
+// queueProgress reports the current file's position in the queue, the queue
+// length, and how many high-risk files are still undecided.
+func (m *Model) queueProgress() (pos, total, highRemaining int) {
+	visible := m.visibleFiles()
+	for n, i := range visible {
+		if i == m.fileIndex {
+			pos = n + 1
+		}
+		if m.fileDecided(i) {
+			continue
+		}
+		if _, risk := m.fileRisk(i); risk >= model.RiskHigh {
+			highRemaining++
+		}
+	}
+	return pos, len(visible), highRemaining
+}
+
+// queueDone reports whether every file in the queue has a decision.
+func (m *Model) queueDone() bool {
+	for _, i := range m.visibleFiles() {
+		if !m.fileDecided(i) {
+			return false
+		}
+	}
+	return true
+}
+
+// renderQueueHeader renders the progress line shown above the diff in queue
+// mode, e.g. "3 of 27, 2 high-risk remaining".
+func (m Model) renderQueueHeader() string {
+	pos, total, high := m.queueProgress()
+	text := fmt.Sprintf(" Queue: %d of %d", pos, total)
+	switch high {
+	case 0:
+		text += ", no high-risk remaining"
+	case 1:
+		text += ", 1 high-risk remaining"
+	default:
+		text += fmt.Sprintf(", %d high-risk remaining", high)
+	}
+	if len(m.diffSet.Files) > 0 {
+		if n, risk := m.fileRisk(m.fileIndex); n > 0 {
+			text += fmt.Sprintf("  ·  this file: %s risk, %d findings", risk, n)
+		}
+	}
+	if m.queueDone() {
+		text += "  ·  queue complete, enter for summary"
+	}
+	return commitHeaderStyle.Width(m.width).Render(text)
+}
@@ -7,7 +7,9 @@ import (
 
 	"github.com/bluekeyes/go-gitdiff/gitdiff"
 	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
 	"github.com/aezell/agrev/internal/diff"
+	"github.com/aezell/agrev/internal/model"
 )
 
 // renderedLine is a single line of diff output ready for display.
@@ -17,52 +19,114 @@ type renderedLine struct {
 	Op      gitdiff.LineOp
 	Content string // raw text content (no trailing newline)
 	IsHunk  bool   // true if this is a hunk header
+	Hunk    int    // index of the fragment this line belongs to or surrounds
 
-	// Syntax highlighting tokens (nil = no highlighting)
+	// Syntax highlighting tokens (nil = no highlighting), given to the
+	// lines near the viewport by highlightVisible
 	Tokens []diff.Token
 
+	// Word-level changes against the paired delete/add line
+	Changed []diff.Span
+
+	// Line ending: "\n", "\r\n", or "" for a last line without one. It is
+	// shown when missing, or when it is all that changed against the paired
+	// line.
+	Ending     string
+	ShowEnding bool
+
 	// Finding annotation
 	IsFinding  bool
 	FindingRisk int // 0=low, 1=medium, 2=high (maps to model.RiskLevel)
+
+	// Reviewer comment annotation
+	IsComment bool
+
+	// Context added by expansion rather than present in the diff
+	Expanded bool
+
+	// Placeholder for folded lines
+	IsFold bool
+
+	// Part of a block moved to or from another file, and the note above it
+	Moved  bool
+	IsMove bool
+
+	// Note from the agent's trace above the lines it covers
+	IsAnnotation bool
+	Annotation   model.AnnotationType
+
+	// Binary file preview; Content is already styled
+	IsPreview bool
+
+	// Declaration or dependency update summary above the diff
+	IsSemantic bool
 }
 
 // renderFile produces renderedLines for a file's diff fragments.
 func renderFile(f *diff.File) []renderedLine {
+	return renderFileExpanded(f, nil, nil)
+}
+
+// wholeFile as a hunk's extra context shows every line of the file around it.
+const wholeFile = math.MaxInt32
+
+// renderFileExpanded is renderFile with extra[i] more lines of context around
+// hunk i, taken from content (the complete new version of the file). Expanded
+// regions never overlap, so expanding every hunk by wholeFile shows the diff
+// embedded in the full file.
+func renderFileExpanded(f *diff.File, content []string, extra map[int]int) []renderedLine {
 	var lines []renderedLine
 
-	// Collect all content lines for syntax highlighting
-	var contentLines []string
-	for _, frag := range f.Fragments {
-		for _, line := range frag.Lines {
-			contentLines = append(contentLines, strings.TrimRight(line.Line, "\n\r"))
-		}
+	// contextLine appends the unchanged new-file line n (1-based)
+	contextLine := func(n, oldDelta, hunk int) {
+		lines = append(lines, renderedLine{
+			Op:      gitdiff.OpContext,
+			OldNum:  n + oldDelta,
+			NewNum:  n,
+			Content:  diff.DisplayText(content[n-1]),
+			Hunk:     hunk,
+			Expanded: true,
+		})
 	}
 
-	// Highlight all content lines at once
-	highlighted := diff.HighlightLines(f.Name(), contentLines)
-	hlIdx := 0
-
+	shown := 0 // last new-file line already displayed
 	for i, frag := range f.Fragments {
+		firstNew, firstOld := hunkStart(frag)
+		lastNew := firstNew + int(frag.NewLines) - 1
+
+		n := extra[i]
+		if len(content) < lastNew {
+			n = 0 // content doesn't match this diff; don't expand
+		}
+
+		from := max(shown+1, firstNew-n, 1)
+		if i > 0 && from > shown+1 {
+			lines = append(lines, renderedLine{Content: "", Hunk: i})
+		}
+		for ln := from; ln < firstNew; ln++ {
+			contextLine(ln, firstOld-firstNew, i)
+		}
+
 		// Hunk header
 		header := formatHunkHeader(frag)
 		lines = append(lines, renderedLine{
 			IsHunk:  true,
 			Content: header,
+			Hunk:    i,
 		})
 
 		oldLine := int(frag.OldPosition)
 		newLine := int(frag.NewPosition)
+		fragStart := len(lines)
 
 		for _, line := range frag.Lines {
 			rl := renderedLine{
 				Op:      line.Op,
-				Content: strings.TrimRight(line.Line, "\n\r"),
-			}
-
-			if hlIdx < len(highlighted) {
-				rl.Tokens = highlighted[hlIdx].Tokens
-				hlIdx++
+				Content: diff.DisplayText(strings.TrimRight(line.Line, "\n\r")),
+				Hunk:    i,
+				Ending:  lineEnding(line.Line),
 			}
+			rl.ShowEnding = rl.Ending == ""
 
 			switch line.Op {
 			case gitdiff.OpContext:
@@ -81,15 +145,91 @@ func renderFile(f *diff.File) []renderedLine {
 			lines = append(lines, rl)
 		}
 
-		// Add a blank separator between hunks (but not after the last)
-		if i < len(f.Fragments)-1 {
-			lines = append(lines, renderedLine{Content: ""})
+		markIntraline(lines[fragStart:])
+		shown = lastNew
+
+		// Context below the hunk stops short of the next one
+		if n > 0 {
+			limit := len(content)
+			if i < len(f.Fragments)-1 {
+				next, _ := hunkStart(f.Fragments[i+1])
+				limit = next - 1
+			}
+			to := min(lastNew+n, limit)
+			delta := firstOld + int(frag.OldLines) - (lastNew + 1)
+			for ln := lastNew + 1; ln <= to; ln++ {
+				contextLine(ln, delta, i)
+			}
+			shown = max(shown, to)
 		}
 	}
 
 	return lines
 }
 
+// hunkStart returns the first new and old line numbers covered by frag. An
+// empty side's position names the line before the hunk, not the first in it.
+func hunkStart(frag *gitdiff.TextFragment) (newLine, oldLine int) {
+	newLine, oldLine = int(frag.NewPosition), int(frag.OldPosition)
+	if frag.NewLines == 0 {
+		newLine++
+	}
+	if frag.OldLines == 0 {
+		oldLine++
+	}
+	return newLine, oldLine
+}
+
+// markIntraline pairs each run of deleted lines with the run of added lines
+// that follows it and records the word-level changes between the pairs.
+func markIntraline(lines []renderedLine) {
+	for i := 0; i < len(lines); {
+		if lines[i].Op != gitdiff.OpDelete {
+			i++
+			continue
+		}
+		delStart := i
+		for i < len(lines) && lines[i].Op == gitdiff.OpDelete {
+			i++
+		}
+		addStart := i
+		for i < len(lines) && lines[i].Op == gitdiff.OpAdd {
+			i++
+		}
+
+		pairs := min(addStart-delStart, i-addStart)
+		for p := 0; p < pairs; p++ {
+			del, add := &lines[delStart+p], &lines[addStart+p]
+			del.Changed, add.Changed = diff.IntralineDiff(del.Content, add.Content)
+			if del.Content == add.Content && del.Ending != add.Ending {
+				del.ShowEnding, add.ShowEnding = true, true
+			}
+		}
+	}
+}
+
+// lineEnding returns the line ending of a line from a diff.
+func lineEnding(line string) string {
+	switch {
+	case strings.HasSuffix(line, "\r\n"):
+		return "\r\n"
+	case strings.HasSuffix(line, "\n"):
+		return "\n"
+	}
+	return ""
+}
+
+// endingLabel describes a line ending that is shown after a line.
+func endingLabel(ending string) string {
+	switch ending {
+	case "\r\n":
+		return "␍␊ CRLF"
+	case "\n":
+		return "␊ LF"
+	}
+	return "⊘ no newline at end of file"
+}
+
 func formatHunkHeader(frag *gitdiff.TextFragment) string {
 	old := fmt.Sprintf("-%d", frag.OldPosition)
 	if frag.OldLines != 1 {
@@ -107,27 +247,6 @@ func formatHunkHeader(frag *gitdiff.TextFragment) string {
 	return header
 }
 
-// renderHighlightedContent renders line content with syntax tokens and diff coloring.
-func renderHighlightedContent(rl renderedLine, prefix string) string {
-	if len(rl.Tokens) == 0 {
-		return prefix + rl.Content
-	}
-
-	var b strings.Builder
-	b.WriteString(prefix)
-
-	for _, tok := range rl.Tokens {
-		if tok.Color != "" && rl.Op == gitdiff.OpContext {
-			// Apply syntax color only for context lines
-			b.WriteString(lipgloss.NewStyle().Foreground(lipgloss.Color(tok.Color)).Render(tok.Text))
-		} else {
-			b.WriteString(tok.Text)
-		}
-	}
-
-	return b.String()
-}
-
 // pulseColor interpolates between a dim and bright version of a color based on phase.
 // Returns an animated lipgloss.Color that breathes between dim and full brightness.
 func pulseColor(dimRGB, brightRGB [3]int, phase float64) lipgloss.Color {
@@ -138,18 +257,16 @@ func pulseColor(dimRGB, brightRGB [3]int, phase float64) lipgloss.Color {
 	return lipgloss.Color(fmt.Sprintf("#%02x%02x%02x", r, g, b))
 }
 
-// Finding color pairs: [dim, bright] for each risk level.
+// Finding color pairs: [dim, bright] for each risk level, set by applyTheme.
 var (
-	findingHighDim    = [3]int{0x8a, 0x5c, 0x3a} // muted orange
-	findingHighBright = [3]int{0xff, 0xb8, 0x6c} // bright orange
-	findingMedDim     = [3]int{0x8a, 0x8a, 0x4c} // muted yellow
-	findingMedBright  = [3]int{0xf1, 0xfa, 0x8c} // bright yellow
-	findingLowDim     = [3]int{0x8a, 0x8a, 0x8a} // muted white
-	findingLowBright  = [3]int{0xf8, 0xf8, 0xf2} // bright white
+	findingHighDim, findingHighBright [3]int
+	findingMedDim, findingMedBright   [3]int
+	findingLowDim, findingLowBright   [3]int
 )
 
 // styleLine applies styling to a rendered line for unified view.
-func styleLine(rl renderedLine, width int, phase float64) string {
+// Occurrences of query (if any) are emphasized.
+func styleLine(rl renderedLine, width int, phase float64, query string) string {
 	if rl.IsFinding {
 		var dim, bright [3]int
 		bold := false
@@ -171,6 +288,30 @@ func styleLine(rl renderedLine, width int, phase float64) string {
 		return style.Render(text)
 	}
 
+	if rl.IsComment {
+		return commentStyle.Render(truncate(rl.Content, width-2))
+	}
+
+	if rl.IsFold {
+		return foldStyle.Render(truncate(rl.Content, width-2))
+	}
+
+	if rl.IsMove {
+		return moveStyle.Render(truncate(rl.Content, width-2))
+	}
+
+	if rl.IsAnnotation {
+		return annotationStyle(rl.Annotation).Render(truncate(rl.Content, width-2))
+	}
+
+	if rl.IsSemantic {
+		return semanticStyle.Render(truncate(rl.Content, width-2))
+	}
+
+	if rl.IsPreview {
+		return rl.Content
+	}
+
 	if rl.IsHunk {
 		return hunkHeaderStyle.Width(width).Render(rl.Content)
 	}
@@ -190,43 +331,36 @@ func styleLine(rl renderedLine, width int, phase float64) string {
 	lineNums := lineNumberStyle.Render(oldNum) + " " + lineNumberStyle.Render(newNum)
 
 	var prefix string
-	var style func(string) string
+	base := contextLineStyle
 
 	switch rl.Op {
 	case gitdiff.OpAdd:
 		prefix = "+"
-		style = func(s string) string { return addedLineStyle.Render(s) }
+		base = addedLineStyle
 	case gitdiff.OpDelete:
 		prefix = "-"
-		style = func(s string) string { return deletedLineStyle.Render(s) }
+		base = deletedLineStyle
 	default:
 		prefix = " "
-		style = nil // context lines get syntax highlighting instead
 	}
 
-	var content string
-	if style == nil {
-		// Context line: use syntax highlighting
-		content = renderHighlightedContent(rl, prefix)
-	} else {
-		content = style(prefix + rl.Content)
-	}
-
-	// Truncate long lines
 	maxContent := width - 12
-	if maxContent > 0 && lipgloss.Width(content) > maxContent {
-		// Simple truncation for styled strings
-		content = truncate(prefix+rl.Content, maxContent)
-		if style != nil {
-			content = style(content)
+
+	// Search matches replace syntax colors with the plain line style plus emphasis
+	if containsQuery(rl.Content, query) {
+		text := prefix + rl.Content
+		if maxContent > 0 {
+			text = truncate(text, maxContent)
 		}
+		return lineNums + " " + highlightMatches(text, query, base)
 	}
 
-	return lineNums + " " + content
+	return lineNums + " " + renderCode(prefix, rl, maxContent)
 }
 
 // styleLineSplit renders a line for split (side-by-side) view.
-func styleLineSplit(rl renderedLine, halfWidth int, phase float64) (left, right string) {
+// Occurrences of query (if any) are emphasized.
+func styleLineSplit(rl renderedLine, halfWidth int, phase float64, query string) (left, right string) {
 	if rl.IsFinding {
 		var dim, bright [3]int
 		bold := false
@@ -248,6 +382,30 @@ func styleLineSplit(rl renderedLine, halfWidth int, phase float64) (left, right
 		return style.Render(text), ""
 	}
 
+	if rl.IsComment {
+		return commentStyle.Render(truncate(rl.Content, halfWidth*2)), ""
+	}
+
+	if rl.IsFold {
+		return foldStyle.Render(truncate(rl.Content, halfWidth*2)), ""
+	}
+
+	if rl.IsMove {
+		return moveStyle.Render(truncate(rl.Content, halfWidth*2)), ""
+	}
+
+	if rl.IsAnnotation {
+		return annotationStyle(rl.Annotation).Render(truncate(rl.Content, halfWidth*2)), ""
+	}
+
+	if rl.IsSemantic {
+		return semanticStyle.Render(truncate(rl.Content, halfWidth*2)), ""
+	}
+
+	if rl.IsPreview {
+		return rl.Content, ""
+	}
+
 	if rl.IsHunk {
 		half := hunkHeaderStyle.Width(halfWidth).Render(rl.Content)
 		return half, ""
@@ -258,14 +416,12 @@ func styleLineSplit(rl renderedLine, halfWidth int, phase float64) (left, right
 	switch rl.Op {
 	case gitdiff.OpDelete:
 		num := fmt.Sprintf("%4d", rl.OldNum)
-		content := truncate(rl.Content, maxContent)
-		left = lineNumberStyle.Render(num) + " " + deletedLineStyle.Render("-"+content)
+		left = lineNumberStyle.Render(num) + " " + splitContent("-", rl, maxContent, query, deletedLineStyle)
 		right = strings.Repeat(" ", halfWidth)
 	case gitdiff.OpAdd:
 		left = strings.Repeat(" ", halfWidth)
 		num := fmt.Sprintf("%4d", rl.NewNum)
-		content := truncate(rl.Content, maxContent)
-		right = lineNumberStyle.Render(num) + " " + addedLineStyle.Render("+"+content)
+		right = lineNumberStyle.Render(num) + " " + splitContent("+", rl, maxContent, query, addedLineStyle)
 	default:
 		oldNum := "    "
 		newNum := "    "
@@ -275,20 +431,112 @@ func styleLineSplit(rl renderedLine, halfWidth int, phase float64) (left, right
 		if rl.NewNum > 0 {
 			newNum = fmt.Sprintf("%4d", rl.NewNum)
 		}
-		content := truncate(rl.Content, maxContent)
-		left = lineNumberStyle.Render(oldNum) + " " + contextLineStyle.Render(" "+content)
-		right = lineNumberStyle.Render(newNum) + " " + contextLineStyle.Render(" "+content)
+		content := splitContent(" ", rl, maxContent, query, contextLineStyle)
+		left = lineNumberStyle.Render(oldNum) + " " + content
+		right = lineNumberStyle.Render(newNum) + " " + content
 	}
 
 	return left, right
 }
 
+// splitContent renders a line's text for one side of the split view.
+// Search matches take precedence over syntax colors.
+func splitContent(prefix string, rl renderedLine, maxContent int, query string, base lipgloss.Style) string {
+	if containsQuery(rl.Content, query) {
+		return highlightMatches(prefix+truncate(rl.Content, maxContent), query, base)
+	}
+	return renderCode(prefix, rl, maxContent+1)
+}
+
+// renderCode renders prefix and line content with syntax colors. Added and
+// deleted lines keep their token colors over a dim green or red background,
+// with the word-level changes in rl.Changed on a stronger one. Moved lines
+// are plain purple instead, so they stand apart from real changes. The
+// result is truncated to maxContent.
+func renderCode(prefix string, rl renderedLine, maxContent int) string {
+	line, emph := contextLineStyle, contextLineStyle
+	switch {
+	case rl.Moved:
+		line, emph = movedCodeStyle, movedCodeStyle
+	case rl.Op == gitdiff.OpAdd:
+		line, emph = addedCodeStyle, addedEmphStyle
+	case rl.Op == gitdiff.OpDelete:
+		line, emph = deletedCodeStyle, deletedEmphStyle
+	}
+
+	// Truncate by display width, so wide and multi-byte characters are kept
+	// whole, and clip the changed spans to what is left
+	text := rl.Content
+	ellipsis := ""
+	changed := rl.Changed
+	if maxContent > 0 && ansi.StringWidth(prefix+text) > maxContent {
+		text = ansi.Truncate(text, max(maxContent-ansi.StringWidth(prefix)-1, 0), "")
+		ellipsis = "…"
+		changed = clipSpans(changed, len(text))
+	}
+
+	// Fall back to a single uncolored token if highlighting lost track of the
+	// text. Moved lines are never syntax colored.
+	tokens := rl.Tokens
+	if plain := (diff.HighlightedLine{Tokens: tokens}).Plain(); rl.Moved || plain != rl.Content {
+		tokens = []diff.Token{{Text: rl.Content}}
+	}
+
+	var b strings.Builder
+	b.WriteString(line.Render(prefix))
+	pos := 0
+	for _, tok := range tokens {
+		end := min(pos+len(tok.Text), len(text))
+		for pos < end {
+			style, next := line, end
+			for _, sp := range changed {
+				if pos >= sp.Start && pos < sp.End {
+					style, next = emph, min(end, sp.End)
+					break
+				}
+				if sp.Start > pos {
+					next = min(end, sp.Start)
+					break
+				}
+			}
+			if tok.Color != "" {
+				style = style.Foreground(lipgloss.Color(tok.Color))
+			}
+			b.WriteString(style.Render(text[pos:next]))
+			pos = next
+		}
+		if pos >= len(text) {
+			break
+		}
+	}
+	if ellipsis != "" {
+		b.WriteString(line.Render(ellipsis))
+	} else if rl.ShowEnding {
+		label := " " + endingLabel(rl.Ending)
+		if maxContent > 0 {
+			label = truncate(label, maxContent-ansi.StringWidth(prefix+text))
+		}
+		b.WriteString(foldStyle.Render(label))
+	}
+	return b.String()
+}
+
+// truncate cuts s to max columns, ending it with an ellipsis if it is cut.
 func truncate(s string, max int) string {
 	if max <= 0 {
 		return ""
 	}
-	if len(s) > max {
-		return s[:max-1] + "…"
+	return ansi.Truncate(s, max, "…")
+}
+
+// clipSpans returns the parts of spans that fall before n.
+func clipSpans(spans []diff.Span, n int) []diff.Span {
+	var out []diff.Span
+	for _, sp := range spans {
+		if sp.Start >= n {
+			break
+		}
+		out = append(out, diff.Span{Start: sp.Start, End: min(sp.End, n)})
 	}
-	return s
+	return out
 }
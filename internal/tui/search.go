@@ -0,0 +1,229 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/bluekeyes/go-gitdiff/gitdiff"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// searchMatch locates a diff line containing the search query.
+type searchMatch struct {
+	file   int
+	oldNum int
+	newNum int
+}
+
+func newSearchInput() textinput.Model {
+	ti := textinput.New()
+	ti.Prompt = "/"
+	ti.Placeholder = "search"
+	ti.CharLimit = 200
+	return ti
+}
+
+// findMatches returns every diff line across all files containing query, in
+// file order. Matching is case-insensitive unless query has an uppercase letter.
+func (m *Model) findMatches(query string) []searchMatch {
+	if query == "" {
+		return nil
+	}
+
+	var matches []searchMatch
+	for fi, f := range m.diffSet.Files {
+		for _, frag := range f.Fragments {
+			oldLine := int(frag.OldPosition)
+			newLine := int(frag.NewPosition)
+			for _, line := range frag.Lines {
+				sm := searchMatch{file: fi}
+				switch line.Op {
+				case gitdiff.OpContext:
+					sm.oldNum, sm.newNum = oldLine, newLine
+					oldLine++
+					newLine++
+				case gitdiff.OpDelete:
+					sm.oldNum = oldLine
+					oldLine++
+				case gitdiff.OpAdd:
+					sm.newNum = newLine
+					newLine++
+				}
+				if containsQuery(line.Line, query) {
+					matches = append(matches, sm)
+				}
+			}
+		}
+	}
+	return matches
+}
+
+// lineIndexFor returns the index in m.lines of the diff line at the given
+// old/new line numbers, or -1 if it is not in the current file.
+func (m *Model) lineIndexFor(oldNum, newNum int) int {
+	for i, rl := range m.lines {
+		if rl.IsHunk || rl.IsFinding || rl.IsComment {
+			continue
+		}
+		if rl.OldNum == oldNum && rl.NewNum == newNum && (oldNum > 0 || newNum > 0) {
+			return i
+		}
+	}
+	return -1
+}
+
+// jumpToMatch moves the view to the match at idx, switching files if needed.
+func (m *Model) jumpToMatch(idx int) {
+	if idx < 0 || idx >= len(m.searchMatches) {
+		return
+	}
+	m.searchIndex = idx
+	sm := m.searchMatches[idx]
+	if sm.file != m.fileIndex {
+		m.selectFile(sm.file)
+	}
+	if i := m.lineIndexFor(sm.oldNum, sm.newNum); i >= 0 {
+		m.scrollOffset = i
+	}
+}
+
+// firstMatchFrom returns the index of the first match at or after the given
+// file and line position, wrapping to the first match.
+func (m *Model) firstMatchFrom(file, offset int) int {
+	for i, sm := range m.searchMatches {
+		if sm.file > file {
+			return i
+		}
+		if sm.file == file {
+			if li := m.lineIndexFor(sm.oldNum, sm.newNum); li >= offset || li < 0 {
+				return i
+			}
+		}
+	}
+	return 0
+}
+
+func (m *Model) nextMatch() {
+	if len(m.searchMatches) == 0 {
+		return
+	}
+	m.jumpToMatch((m.searchIndex + 1) % len(m.searchMatches))
+}
+
+func (m *Model) prevMatch() {
+	if len(m.searchMatches) == 0 {
+		return
+	}
+	m.jumpToMatch((m.searchIndex - 1 + len(m.searchMatches)) % len(m.searchMatches))
+}
+
+func (m *Model) clearSearch() {
+	m.searchQuery = ""
+	m.searchMatches = nil
+	m.searchIndex = 0
+}
+
+func (m Model) updateSearch(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.Type {
+	case tea.KeyEnter:
+		m.searching = false
+		m.searchInput.Blur()
+		if m.searchQuery == "" {
+			m.clearSearch()
+		}
+		return m, nil
+	case tea.KeyEsc:
+		m.searching = false
+		m.searchInput.Blur()
+		m.clearSearch()
+		return m, nil
+	}
+
+	var cmd tea.Cmd
+	m.searchInput, cmd = m.searchInput.Update(msg)
+
+	// Incremental search: re-run on every edit, jumping from where the search began
+	if q := m.searchInput.Value(); q != m.searchQuery {
+		m.searchQuery = q
+		m.searchMatches = m.findMatches(q)
+		m.searchIndex = 0
+		if len(m.searchMatches) > 0 {
+			if m.fileIndex != m.searchOriginFile {
+				m.selectFile(m.searchOriginFile)
+			}
+			m.jumpToMatch(m.firstMatchFrom(m.searchOriginFile, m.searchOriginLine))
+		}
+	}
+	return m, cmd
+}
+
+func (m Model) renderSearchBar() string {
+	info := ""
+	if m.searchQuery != "" {
+		if len(m.searchMatches) == 0 {
+			info = "no matches"
+		} else {
+			info = fmt.Sprintf("match %d/%d", m.searchIndex+1, len(m.searchMatches))
+		}
+	}
+	left := m.searchInput.View()
+	barGap := m.width - lipgloss.Width(left) - lipgloss.Width(info) - 1
+	if barGap < 1 {
+		barGap = 1
+	}
+	return lipgloss.NewStyle().
+		Foreground(colorFg).
+		Background(colorBgLight).
+		Width(m.width).
+		Render(left + strings.Repeat(" ", barGap) + info)
+}
+
+// containsQuery reports whether s contains query, using smart case.
+func containsQuery(s, query string) bool {
+	if query == "" {
+		return false
+	}
+	if strings.ToLower(query) == query {
+		return strings.Contains(strings.ToLower(s), query)
+	}
+	return strings.Contains(s, query)
+}
+
+// highlightMatches renders text with base style, emphasizing each occurrence of query.
+func highlightMatches(text, query string, base lipgloss.Style) string {
+	if query == "" {
+		return base.Render(text)
+	}
+
+	haystack := text
+	needle := query
+	if strings.ToLower(query) == query {
+		haystack = strings.ToLower(text)
+		if len(haystack) != len(text) {
+			// Case folding changed byte offsets; skip emphasis rather than misalign
+			return base.Render(text)
+		}
+	}
+
+	var b strings.Builder
+	pos := 0
+	for {
+		i := strings.Index(haystack[pos:], needle)
+		if i < 0 {
+			break
+		}
+		start := pos + i
+		end := start + len(needle)
+		if start > pos {
+			b.WriteString(base.Render(text[pos:start]))
+		}
+		b.WriteString(searchMatchStyle.Render(text[start:end]))
+		pos = end
+	}
+	if pos < len(text) {
+		b.WriteString(base.Render(text[pos:]))
+	}
+	return b.String()
+}
@@ -0,0 +1,69 @@
+package tui
+
+import (
+	"fmt"
+
+	"github.com/aezell/agrev/internal/diff"
+)
+
+// semanticSummary returns the declaration summary shown above the current
+// file's diff, working it out the first time.
+func (m *Model) semanticSummary() []renderedLine {
+	f := m.diffSet.Files[m.fileIndex]
+	if lines, ok := m.semanticLines[f]; ok {
+		return lines
+	}
+	lines := semanticLines(m.repoDir, f)
+	m.semanticLines[f] = lines
+	return lines
+}
+
+// semanticLines lists the declarations f adds, removes, and modifies.
+func semanticLines(repoDir string, f *diff.File) []renderedLine {
+	note := func(format string, args ...any) renderedLine {
+		return renderedLine{IsSemantic: true, Content: fmt.Sprintf(format, args...)}
+	}
+	if f.IsBinary || f.IsSubmodule {
+		return nil
+	}
+	old, new, err := diff.Versions(repoDir, f)
+	if err != nil {
+		return []renderedLine{note("  ≡ no declaration summary: %v", err)}
+	}
+	name := f.NewName
+	if f.IsDeleted {
+		name = f.OldName
+	}
+	changes, ok := diff.DeclChanges(name, old, new)
+	switch {
+	case !ok:
+		return []renderedLine{note("  ≡ no declaration summary for this language")}
+	case len(changes) == 0:
+		return []renderedLine{note("  ≡ no declarations changed")}
+	}
+
+	lines := []renderedLine{note("  ≡ %d declaration(s) changed", len(changes))}
+	for _, c := range changes {
+		mark := "~"
+		switch c.Change {
+		case "added":
+			mark = "+"
+		case "removed":
+			mark = "-"
+		}
+		lines = append(lines, note("    %s %s", mark, c))
+	}
+	return lines
+}
+
+// toggleSemantic shows or hides the declaration summary above each file's
+// diff.
+func (m *Model) toggleSemantic() {
+	m.semantic = !m.semantic
+	if !m.semantic {
+		m.relayout()
+		return
+	}
+	m.updateLines()
+	m.scrollOffset = 0 // the summary is at the top
+}
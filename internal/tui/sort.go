@@ -0,0 +1,94 @@
+package tui
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/aezell/agrev/internal/model"
+)
+
+// fileSort is the file list ordering.
+type fileSort int
+
+const (
+	sortDiff     fileSort = iota // order files appear in the diff
+	sortRisk                     // highest finding risk first
+	sortSize                     // most changed lines first
+	sortPath                     // alphabetical
+	sortFindings                 // most findings first
+	numFileSorts
+)
+
+func (s fileSort) String() string {
+	switch s {
+	case sortRisk:
+		return "risk"
+	case sortSize:
+		return "size"
+	case sortPath:
+		return "path"
+	case sortFindings:
+		return "findings"
+	default:
+		return "diff"
+	}
+}
+
+// fileRisk returns the number of findings and the highest finding risk for
+// the file at index i.
+func (m *Model) fileRisk(i int) (count int, maxRisk model.RiskLevel) {
+	if m.analysisResults == nil {
+		return 0, model.RiskInfo
+	}
+	name := m.diffSet.Files[i].Name()
+	for _, fin := range m.analysisResults.Findings {
+		if fin.File == name {
+			count++
+			if fin.Risk > maxRisk {
+				maxRisk = fin.Risk
+			}
+		}
+	}
+	return count, maxRisk
+}
+
+// sortFiles orders file indices by the active sort, keeping diff order for ties.
+func (m *Model) sortFiles(files []int) {
+	if m.sortMode == sortDiff {
+		return
+	}
+
+	type key struct {
+		findings int
+		risk     model.RiskLevel
+	}
+	keys := make(map[int]key, len(files))
+	for _, i := range files {
+		n, r := m.fileRisk(i)
+		keys[i] = key{n, r}
+	}
+
+	sort.SliceStable(files, func(a, b int) bool {
+		fa, fb := m.diffSet.Files[files[a]], m.diffSet.Files[files[b]]
+		ka, kb := keys[files[a]], keys[files[b]]
+		switch m.sortMode {
+		case sortRisk:
+			if ka.risk != kb.risk {
+				return ka.risk > kb.risk
+			}
+			return ka.findings > kb.findings
+		case sortSize:
+			return fa.AddedLines+fa.DeletedLines > fb.AddedLines+fb.DeletedLines
+		case sortPath:
+			return strings.ToLower(fa.Name()) < strings.ToLower(fb.Name())
+		case sortFindings:
+			return ka.findings > kb.findings
+		}
+		return false
+	})
+}
+
+// cycleSort switches to the next file ordering.
+func (m *Model) cycleSort() {
+	m.sortMode = (m.sortMode + 1) % numFileSorts
+}
@@ -0,0 +1,144 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/aezell/agrev/internal/diff"
+	"github.com/aezell/agrev/internal/trace"
+)
+
+// openStepDetail shows steps[i] in the full-screen detail view. The list is
+// kept so h/l can move between neighbouring steps.
+func (m *Model) openStepDetail(steps []trace.Step, i int) {
+	if i < 0 || i >= len(steps) {
+		return
+	}
+	m.showStepDetail = true
+	m.detailSteps = steps
+	m.detailIndex = i
+	m.detailScroll = 0
+}
+
+// stepDetailBody renders a step's full content. Reasoning, plans, and user
+// messages are markdown; edits show the replaced and replacement text, and
+// commands show their output.
+func stepDetailBody(s trace.Step, width int) []string {
+	text := s.Detail
+	if text == "" {
+		text = s.Summary
+	}
+	switch s.Type {
+	case trace.StepReasoning, trace.StepPlan, trace.StepUserMessage:
+		return renderMarkdown(text, width)
+
+	case trace.StepBash:
+		out := renderCodeBlock("bash", []string{"$ " + s.Command}, width)
+		if s.ExitCode != 0 {
+			out = append(out, findingHighStyle.Render(fmt.Sprintf("exit status %d", s.ExitCode)))
+		}
+		output := s.Output
+		if output == "" && s.Detail != s.Command {
+			output = s.Detail
+		}
+		if output != "" {
+			out = append(out, "")
+			out = append(out, renderCodeBlock("", expandTabs(diff.SplitLines(output)), width)...)
+		}
+		return out
+
+	case trace.StepFileEdit:
+		if s.OldString == "" && s.NewString == "" {
+			break
+		}
+		oldLines := expandTabs(diff.SplitLines(s.OldString))
+		newLines := expandTabs(diff.SplitLines(s.NewString))
+		out := []string{hunkHeaderStyle.Render("replaced")}
+		out = append(out, renderHighlighted(oldLines, diff.HighlightLines(s.FilePath, oldLines), width, deletedCodeStyle, "-")...)
+		out = append(out, "", hunkHeaderStyle.Render("with"))
+		out = append(out, renderHighlighted(newLines, diff.HighlightLines(s.FilePath, newLines), width, addedCodeStyle, "+")...)
+		return out
+
+	case trace.StepFileWrite:
+		lines := expandTabs(diff.SplitLines(text))
+		return renderHighlighted(lines, diff.HighlightLines(s.FilePath, lines), width, mdCodeBlockStyle, " ")
+	}
+	return renderCodeBlock("", expandTabs(diff.SplitLines(text)), width)
+}
+
+func expandTabs(lines []string) []string {
+	for i, l := range lines {
+		lines[i] = strings.ReplaceAll(l, "\t", "    ")
+	}
+	return lines
+}
+
+// detailBodyHeight is the number of body lines that fit under the header.
+func (m Model) detailBodyHeight() int {
+	return max(m.height-5, 1)
+}
+
+func (m Model) updateStepDetail(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	body := stepDetailBody(m.detailSteps[m.detailIndex], m.width-4)
+	maxScroll := max(len(body)-m.detailBodyHeight(), 0)
+
+	switch msg.String() {
+	case "esc", "enter", "q":
+		m.showStepDetail = false
+	case "ctrl+c":
+		return m, tea.Quit
+	case "j", "down":
+		m.detailScroll = min(m.detailScroll+1, maxScroll)
+	case "k", "up":
+		m.detailScroll = max(m.detailScroll-1, 0)
+	case "pgdown", " ":
+		m.detailScroll = min(m.detailScroll+m.detailBodyHeight(), maxScroll)
+	case "pgup":
+		m.detailScroll = max(m.detailScroll-m.detailBodyHeight(), 0)
+	case "g":
+		m.detailScroll = 0
+	case "G":
+		m.detailScroll = maxScroll
+	case "l", "right":
+		if m.detailIndex < len(m.detailSteps)-1 {
+			m.detailIndex++
+			m.detailScroll = 0
+		}
+	case "h", "left":
+		if m.detailIndex > 0 {
+			m.detailIndex--
+			m.detailScroll = 0
+		}
+	}
+	return m, nil
+}
+
+func (m Model) renderStepDetail() string {
+	s := m.detailSteps[m.detailIndex]
+	width := m.width - 4
+	var b strings.Builder
+
+	header := fmt.Sprintf("Step %d/%d  %s", m.detailIndex+1, len(m.detailSteps), s.Type)
+	if when := timeLabel(s.Timestamp); when != "" {
+		header += "  " + when
+	}
+	b.WriteString(traceHeaderStyle.Render(header))
+	b.WriteByte('\n')
+	b.WriteString(renderTraceStep(s, width, true))
+	b.WriteString("\n\n")
+
+	body := stepDetailBody(s, width)
+	end := min(m.detailScroll+m.detailBodyHeight(), len(body))
+	for i := m.detailScroll; i < end; i++ {
+		b.WriteString(body[i])
+		b.WriteByte('\n')
+	}
+
+	pos := ""
+	if len(body) > m.detailBodyHeight() {
+		pos = fmt.Sprintf("  %d-%d/%d", m.detailScroll+1, end, len(body))
+	}
+	b.WriteString(helpBarStyle.Render("j/k scroll  h/l prev/next step  g/G top/bottom  esc close" + pos))
+	return b.String()
+}
@@ -2,151 +2,244 @@ package tui
 
 import "github.com/charmbracelet/lipgloss"
 
-// Color palette.
+// Color palette, set from the active theme by applyTheme.
 var (
-	colorRed       = lipgloss.Color("#ff5555")
-	colorGreen     = lipgloss.Color("#50fa7b")
-	colorYellow    = lipgloss.Color("#f1fa8c")
-	colorBlue      = lipgloss.Color("#8be9fd")
-	colorPurple    = lipgloss.Color("#bd93f9")
-	colorDim       = lipgloss.Color("#6272a4")
-	colorBg        = lipgloss.Color("#282a36")
-	colorBgLight   = lipgloss.Color("#343746")
-	colorFg        = lipgloss.Color("#f8f8f2")
-	colorOrange    = lipgloss.Color("#ffb86c")
-	colorBorder    = lipgloss.Color("#44475a")
-	colorHighlight = lipgloss.Color("#44475a")
+	colorRed, colorGreen, colorYellow, colorBlue, colorPurple, colorDim,
+	colorBg, colorBgLight, colorFg, colorOrange, colorBorder, colorHighlight,
+	colorAddedBg, colorDeletedBg, colorAddedEmph, colorDeletedEmph lipgloss.Color
 )
 
-// Style definitions.
+// Style definitions, built from the palette by buildStyles.
 var (
+	fileListStyle, fileItemStyle, fileItemSelectedStyle, fileItemNewStyle,
+	fileItemDeletedStyle, diffViewStyle, lineNumberStyle, addedLineStyle,
+	deletedLineStyle, addedCodeStyle, deletedCodeStyle, addedEmphStyle,
+	deletedEmphStyle, contextLineStyle, hunkHeaderStyle, fileHeaderStyle,
+	statusBarStyle, statusKeyStyle, traceViewStyle, traceHeaderStyle,
+	traceWriteStyle, traceBashStyle, traceReasonStyle, traceReadStyle,
+	traceUserStyle, findingHighStyle, findingMediumStyle, findingLowStyle,
+	searchMatchStyle, commentStyle, fileApprovedStyle, fileRejectedStyle,
+	filePartialStyle, filePendingStyle, summaryHeaderStyle, summaryApprovedStyle, summaryRejectedStyle,
+	summaryPendingStyle, helpBarStyle, helpKeyStyle, foldStyle, moveStyle, movedCodeStyle, semanticStyle,
+	commitHeaderStyle, mdTitleStyle, mdHeadingStyle, mdBulletStyle, mdQuoteStyle,
+	mdRuleStyle, mdCodeStyle, mdCodeBlockStyle lipgloss.Style
+)
+
+// buildStyles derives every style from the active palette. It runs whenever
+// the theme changes.
+func buildStyles() {
 	// File list styles
 	fileListStyle = lipgloss.NewStyle().
-			Border(lipgloss.RoundedBorder()).
-			BorderForeground(colorBorder).
-			Padding(0, 1)
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(colorBorder).
+		Padding(0, 1)
 
 	fileItemStyle = lipgloss.NewStyle().
-			Foreground(colorFg)
+		Foreground(colorFg)
 
 	fileItemSelectedStyle = lipgloss.NewStyle().
-				Foreground(colorFg).
-				Background(colorHighlight).
-				Bold(true)
+		Foreground(colorFg).
+		Background(colorHighlight).
+		Bold(true)
 
 	fileItemNewStyle = lipgloss.NewStyle().
-				Foreground(colorGreen)
+		Foreground(colorGreen)
 
 	fileItemDeletedStyle = lipgloss.NewStyle().
-				Foreground(colorRed)
+		Foreground(colorRed)
 
 	// Diff view styles
 	diffViewStyle = lipgloss.NewStyle().
-			Border(lipgloss.RoundedBorder()).
-			BorderForeground(colorBorder).
-			Padding(0, 1)
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(colorBorder).
+		Padding(0, 1)
 
 	lineNumberStyle = lipgloss.NewStyle().
-			Foreground(colorDim).
-			Width(4).
-			Align(lipgloss.Right)
+		Foreground(colorDim).
+		Width(4).
+		Align(lipgloss.Right)
 
 	addedLineStyle = lipgloss.NewStyle().
-			Foreground(colorGreen)
+		Foreground(colorGreen)
 
 	deletedLineStyle = lipgloss.NewStyle().
-				Foreground(colorRed)
+		Foreground(colorRed)
+
+	// Syntax-highlighted added and deleted lines keep a tinted background
+	addedCodeStyle = lipgloss.NewStyle().
+		Foreground(colorGreen).
+		Background(colorAddedBg)
+
+	deletedCodeStyle = lipgloss.NewStyle().
+		Foreground(colorRed).
+		Background(colorDeletedBg)
+
+	// Intraline emphasis for the changed words of paired delete/add lines
+	addedEmphStyle = lipgloss.NewStyle().
+		Foreground(colorGreen).
+		Background(colorAddedEmph).
+		Bold(true)
+
+	deletedEmphStyle = lipgloss.NewStyle().
+		Foreground(colorRed).
+		Background(colorDeletedEmph).
+		Bold(true)
 
 	contextLineStyle = lipgloss.NewStyle().
-				Foreground(colorFg)
+		Foreground(colorFg)
 
 	hunkHeaderStyle = lipgloss.NewStyle().
-			Foreground(colorPurple).
-			Bold(true)
+		Foreground(colorPurple).
+		Bold(true)
 
 	fileHeaderStyle = lipgloss.NewStyle().
-			Foreground(colorBlue).
-			Bold(true).
-			Padding(0, 0, 1, 0)
+		Foreground(colorBlue).
+		Bold(true).
+		Padding(0, 0, 1, 0)
 
 	// Status bar
 	statusBarStyle = lipgloss.NewStyle().
-			Foreground(colorFg).
-			Background(colorBgLight).
-			Padding(0, 1)
+		Foreground(colorFg).
+		Background(colorBgLight).
+		Padding(0, 1)
 
 	statusKeyStyle = lipgloss.NewStyle().
-			Foreground(colorYellow).
-			Background(colorBgLight).
-			Bold(true)
+		Foreground(colorYellow).
+		Background(colorBgLight).
+		Bold(true)
 
 	// Trace panel styles
 	traceViewStyle = lipgloss.NewStyle().
-			Border(lipgloss.RoundedBorder()).
-			BorderForeground(colorBorder).
-			Padding(0, 1)
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(colorBorder).
+		Padding(0, 1)
 
 	traceHeaderStyle = lipgloss.NewStyle().
-				Foreground(colorPurple).
-				Bold(true).
-				Padding(0, 0, 1, 0)
+		Foreground(colorPurple).
+		Bold(true).
+		Padding(0, 0, 1, 0)
 
 	traceWriteStyle = lipgloss.NewStyle().
-			Foreground(colorGreen)
+		Foreground(colorGreen)
 
 	traceBashStyle = lipgloss.NewStyle().
-			Foreground(colorYellow)
+		Foreground(colorYellow)
 
 	traceReasonStyle = lipgloss.NewStyle().
-				Foreground(colorFg)
+		Foreground(colorFg)
 
 	traceReadStyle = lipgloss.NewStyle().
-			Foreground(colorBlue)
+		Foreground(colorBlue)
 
 	traceUserStyle = lipgloss.NewStyle().
-			Foreground(colorPurple)
+		Foreground(colorPurple)
 
 	// Finding annotation styles
 	findingHighStyle = lipgloss.NewStyle().
-				Foreground(colorOrange).
-				Bold(true)
+		Foreground(colorOrange).
+		Bold(true)
 
 	findingMediumStyle = lipgloss.NewStyle().
-				Foreground(colorYellow)
+		Foreground(colorYellow)
 
 	findingLowStyle = lipgloss.NewStyle().
-			Foreground(colorFg)
+		Foreground(colorFg)
+
+	// Search match emphasis
+	searchMatchStyle = lipgloss.NewStyle().
+		Foreground(colorBg).
+		Background(colorYellow).
+		Bold(true)
+
+	// Commit panel in commit-by-commit view
+	commitHeaderStyle = lipgloss.NewStyle().
+		Foreground(colorYellow).
+		Bold(true)
+
+	// Folded hunks and context runs
+	foldStyle = lipgloss.NewStyle().
+		Foreground(colorDim).
+		Italic(true)
+
+	// Code moved between files, and the note naming the other side
+	moveStyle = lipgloss.NewStyle().
+		Foreground(colorPurple).
+		Italic(true)
+
+	movedCodeStyle = lipgloss.NewStyle().
+		Foreground(colorPurple)
+
+	// The declaration summary above a file's diff
+	semanticStyle = lipgloss.NewStyle().
+		Foreground(colorOrange)
+
+	// Reviewer comment annotations
+	commentStyle = lipgloss.NewStyle().
+		Foreground(colorBlue).
+		Italic(true)
 
 	// Review decision styles
 	fileApprovedStyle = lipgloss.NewStyle().
-				Foreground(colorGreen).
-				Bold(true)
+		Foreground(colorGreen).
+		Bold(true)
 
 	fileRejectedStyle = lipgloss.NewStyle().
-				Foreground(colorRed).
-				Bold(true)
+		Foreground(colorRed).
+		Bold(true)
+
+	filePartialStyle = lipgloss.NewStyle().
+		Foreground(colorYellow).
+		Bold(true)
 
 	filePendingStyle = lipgloss.NewStyle().
-				Foreground(colorDim)
+		Foreground(colorDim)
 
 	summaryHeaderStyle = lipgloss.NewStyle().
-				Foreground(colorBlue).
-				Bold(true).
-				Padding(1, 0)
+		Foreground(colorBlue).
+		Bold(true).
+		Padding(1, 0)
 
 	summaryApprovedStyle = lipgloss.NewStyle().
-				Foreground(colorGreen)
+		Foreground(colorGreen)
 
 	summaryRejectedStyle = lipgloss.NewStyle().
-				Foreground(colorRed)
+		Foreground(colorRed)
 
 	summaryPendingStyle = lipgloss.NewStyle().
-				Foreground(colorYellow)
+		Foreground(colorYellow)
 
 	// Help bar
 	helpBarStyle = lipgloss.NewStyle().
-			Foreground(colorDim)
+		Foreground(colorDim)
 
 	helpKeyStyle = lipgloss.NewStyle().
-			Foreground(colorYellow)
-)
+		Foreground(colorYellow)
+
+	// Markdown in trace step details
+	mdTitleStyle = lipgloss.NewStyle().
+		Foreground(colorPurple).
+		Bold(true).
+		Underline(true)
+
+	mdHeadingStyle = lipgloss.NewStyle().
+		Foreground(colorBlue).
+		Bold(true)
+
+	mdBulletStyle = lipgloss.NewStyle().
+		Foreground(colorOrange)
+
+	mdQuoteStyle = lipgloss.NewStyle().
+		Foreground(colorDim).
+		Italic(true)
+
+	mdRuleStyle = lipgloss.NewStyle().
+		Foreground(colorDim)
+
+	mdCodeStyle = lipgloss.NewStyle().
+		Foreground(colorOrange).
+		Background(colorBgLight)
+
+	mdCodeBlockStyle = lipgloss.NewStyle().
+		Foreground(colorFg).
+		Background(colorBgLight)
+}
@@ -0,0 +1,254 @@
+package tui
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/aezell/agrev/internal/config"
+	"github.com/aezell/agrev/internal/diff"
+)
+
+// theme is a color palette for the TUI plus the chroma style used for
+// syntax highlighting.
+type theme struct {
+	Red       lipgloss.Color
+	Green     lipgloss.Color
+	Yellow    lipgloss.Color
+	Blue      lipgloss.Color
+	Purple    lipgloss.Color
+	Dim       lipgloss.Color
+	Bg        lipgloss.Color
+	BgLight   lipgloss.Color
+	Fg        lipgloss.Color
+	Orange    lipgloss.Color
+	Border    lipgloss.Color
+	Highlight lipgloss.Color
+
+	// Backgrounds for added/deleted lines and their word-level changes
+	AddedBg     lipgloss.Color
+	DeletedBg   lipgloss.Color
+	AddedEmph   lipgloss.Color
+	DeletedEmph lipgloss.Color
+
+	// Finding pulse colors as [dim, bright] pairs
+	FindingHigh   [2]lipgloss.Color
+	FindingMedium [2]lipgloss.Color
+	FindingLow    [2]lipgloss.Color
+
+	Chroma string
+}
+
+// Built-in themes.
+var themes = map[string]theme{
+	"dark": {
+		Red:           "#ff5555",
+		Green:         "#50fa7b",
+		Yellow:        "#f1fa8c",
+		Blue:          "#8be9fd",
+		Purple:        "#bd93f9",
+		Dim:           "#6272a4",
+		Bg:            "#282a36",
+		BgLight:       "#343746",
+		Fg:            "#f8f8f2",
+		Orange:        "#ffb86c",
+		Border:        "#44475a",
+		Highlight:     "#44475a",
+		AddedBg:       "#1f3327",
+		DeletedBg:     "#3a2229",
+		AddedEmph:     "#2f5a3a",
+		DeletedEmph:   "#6b2f3a",
+		FindingHigh:   [2]lipgloss.Color{"#8a5c3a", "#ffb86c"},
+		FindingMedium: [2]lipgloss.Color{"#8a8a4c", "#f1fa8c"},
+		FindingLow:    [2]lipgloss.Color{"#8a8a8a", "#f8f8f2"},
+		Chroma:        "dracula",
+	},
+	"light": {
+		Red:           "#c0262d",
+		Green:         "#1a7f37",
+		Yellow:        "#9a6700",
+		Blue:          "#0550ae",
+		Purple:        "#8250df",
+		Dim:           "#6e7781",
+		Bg:            "#ffffff",
+		BgLight:       "#eaeef2",
+		Fg:            "#24292f",
+		Orange:        "#bc4c00",
+		Border:        "#d0d7de",
+		Highlight:     "#ddf4ff",
+		AddedBg:       "#e6ffec",
+		DeletedBg:     "#ffebe9",
+		AddedEmph:     "#abf2bc",
+		DeletedEmph:   "#ffc1c0",
+		FindingHigh:   [2]lipgloss.Color{"#e0a77a", "#bc4c00"},
+		FindingMedium: [2]lipgloss.Color{"#d4b86a", "#9a6700"},
+		FindingLow:    [2]lipgloss.Color{"#a8b1ba", "#24292f"},
+		Chroma:        "github",
+	},
+	"high-contrast": {
+		Red:           "#ff4040",
+		Green:         "#00ff66",
+		Yellow:        "#ffff00",
+		Blue:          "#00ffff",
+		Purple:        "#ff80ff",
+		Dim:           "#c0c0c0",
+		Bg:            "#000000",
+		BgLight:       "#303030",
+		Fg:            "#ffffff",
+		Orange:        "#ff9900",
+		Border:        "#ffffff",
+		Highlight:     "#0050a0",
+		AddedBg:       "#003300",
+		DeletedBg:     "#400000",
+		AddedEmph:     "#006600",
+		DeletedEmph:   "#800000",
+		FindingHigh:   [2]lipgloss.Color{"#ff9900", "#ffcc00"},
+		FindingMedium: [2]lipgloss.Color{"#cccc00", "#ffff00"},
+		FindingLow:    [2]lipgloss.Color{"#c0c0c0", "#ffffff"},
+		Chroma:        "monokai",
+	},
+}
+
+// ThemeNames returns the names of the built-in themes.
+func ThemeNames() []string {
+	names := make([]string, 0, len(themes))
+	for name := range themes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// SetTheme selects the TUI theme by name, looking in the custom themes from
+// the config before the built-ins. An empty name selects the default dark theme.
+func SetTheme(name string, custom map[string]config.ThemeConfig) error {
+	t, err := resolveTheme(name, custom)
+	if err != nil {
+		return err
+	}
+	applyTheme(t)
+	return nil
+}
+
+func resolveTheme(name string, custom map[string]config.ThemeConfig) (theme, error) {
+	if name == "" {
+		name = "dark"
+	}
+
+	tc, ok := custom[name]
+	if !ok {
+		t, ok := themes[name]
+		if !ok {
+			return theme{}, fmt.Errorf("unknown theme %q (built-in: %s)", name, strings.Join(ThemeNames(), ", "))
+		}
+		return t, nil
+	}
+
+	baseName := tc.Base
+	if baseName == "" {
+		baseName = "dark"
+	}
+	t, ok := themes[baseName]
+	if !ok {
+		return theme{}, fmt.Errorf("theme %q: unknown base theme %q", name, baseName)
+	}
+	if tc.Chroma != "" {
+		t.Chroma = tc.Chroma
+	}
+
+	for key, value := range tc.Colors {
+		if _, err := hexRGB(lipgloss.Color(value)); err != nil {
+			return theme{}, fmt.Errorf("theme %q: color %s: %w", name, key, err)
+		}
+		c := lipgloss.Color(value)
+		switch strings.ToLower(key) {
+		case "red":
+			t.Red = c
+		case "green":
+			t.Green = c
+		case "yellow":
+			t.Yellow = c
+		case "blue":
+			t.Blue = c
+		case "purple":
+			t.Purple = c
+		case "dim":
+			t.Dim = c
+		case "bg":
+			t.Bg = c
+		case "bg_light":
+			t.BgLight = c
+		case "fg":
+			t.Fg = c
+		case "orange":
+			t.Orange = c
+		case "border":
+			t.Border = c
+		case "highlight":
+			t.Highlight = c
+		case "added_bg":
+			t.AddedBg = c
+		case "deleted_bg":
+			t.DeletedBg = c
+		case "added_emph":
+			t.AddedEmph = c
+		case "deleted_emph":
+			t.DeletedEmph = c
+		default:
+			return theme{}, fmt.Errorf("theme %q: unknown color %q", name, key)
+		}
+	}
+	return t, nil
+}
+
+// hexRGB parses a "#rrggbb" color.
+func hexRGB(c lipgloss.Color) ([3]int, error) {
+	s := strings.TrimPrefix(string(c), "#")
+	if len(s) != 6 {
+		return [3]int{}, fmt.Errorf("expected #rrggbb, got %q", string(c))
+	}
+	v, err := strconv.ParseUint(s, 16, 32)
+	if err != nil {
+		return [3]int{}, fmt.Errorf("expected #rrggbb, got %q", string(c))
+	}
+	return [3]int{int(v >> 16 & 0xff), int(v >> 8 & 0xff), int(v & 0xff)}, nil
+}
+
+// mustRGB is hexRGB for colors already known to be valid.
+func mustRGB(c lipgloss.Color) [3]int {
+	rgb, _ := hexRGB(c)
+	return rgb
+}
+
+// applyTheme installs t as the active palette and rebuilds all styles.
+func applyTheme(t theme) {
+	colorRed = t.Red
+	colorGreen = t.Green
+	colorYellow = t.Yellow
+	colorBlue = t.Blue
+	colorPurple = t.Purple
+	colorDim = t.Dim
+	colorBg = t.Bg
+	colorBgLight = t.BgLight
+	colorFg = t.Fg
+	colorOrange = t.Orange
+	colorBorder = t.Border
+	colorHighlight = t.Highlight
+	colorAddedBg = t.AddedBg
+	colorDeletedBg = t.DeletedBg
+	colorAddedEmph = t.AddedEmph
+	colorDeletedEmph = t.DeletedEmph
+
+	findingHighDim, findingHighBright = mustRGB(t.FindingHigh[0]), mustRGB(t.FindingHigh[1])
+	findingMedDim, findingMedBright = mustRGB(t.FindingMedium[0]), mustRGB(t.FindingMedium[1])
+	findingLowDim, findingLowBright = mustRGB(t.FindingLow[0]), mustRGB(t.FindingLow[1])
+
+	buildStyles()
+	diff.SetHighlightStyle(t.Chroma)
+}
+
+func init() {
+	applyTheme(themes["dark"])
+}
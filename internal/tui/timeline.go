@@ -0,0 +1,221 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/aezell/agrev/internal/trace"
+)
+
+// timelineLanes groups step types into the rows of the timeline.
+var timelineLanes = []struct {
+	name  string
+	types []trace.StepType
+}{
+	{"user", []trace.StepType{trace.StepUserMessage}},
+	{"think", []trace.StepType{trace.StepPlan, trace.StepReasoning}},
+	{"read", []trace.StepType{trace.StepFileRead}},
+	{"write", []trace.StepType{trace.StepFileWrite, trace.StepFileEdit}},
+	{"bash", []trace.StepType{trace.StepBash, trace.StepToolResult}},
+}
+
+// timelineColumn maps step i to a column on an axis of the given width,
+// by timestamp when the trace has them and by position otherwise.
+func (m Model) timelineColumn(i, width int) int {
+	steps := m.trace.Steps
+	if width <= 1 || len(steps) <= 1 {
+		return 0
+	}
+	start, end := steps[0].Timestamp, steps[len(steps)-1].Timestamp
+	ts := steps[i].Timestamp
+	if !start.IsZero() && !ts.IsZero() && end.After(start) {
+		col := int(float64(ts.Sub(start)) / float64(end.Sub(start)) * float64(width-1))
+		return max(0, min(col, width-1))
+	}
+	return i * (width - 1) / (len(steps) - 1)
+}
+
+// moveTimeline moves the timeline cursor by delta steps.
+func (m *Model) moveTimeline(delta int) {
+	m.timelineCursor = max(0, min(m.timelineCursor+delta, len(m.trace.Steps)-1))
+}
+
+// hunksForStep returns the indices of hunks in diff file f overlapping the
+// lines a step touched, if it recorded any.
+func hunksForStep(s trace.Step, fragments []hunkRange) []int {
+	if s.LineStart == 0 {
+		return nil
+	}
+	end := s.LineEnd
+	if end < s.LineStart {
+		end = s.LineStart
+	}
+	var result []int
+	for i, h := range fragments {
+		if h.start <= end && s.LineStart <= h.end {
+			result = append(result, i)
+		}
+	}
+	return result
+}
+
+// hunkRange is the new-file line range a hunk covers.
+type hunkRange struct{ start, end int }
+
+func (m Model) hunkRanges(fileIndex int) []hunkRange {
+	var ranges []hunkRange
+	for _, frag := range m.diffSet.Files[fileIndex].Fragments {
+		start, _ := hunkStart(frag)
+		ranges = append(ranges, hunkRange{start, start + int(frag.NewLines) - 1})
+	}
+	return ranges
+}
+
+func (m Model) updateTimeline(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch {
+	case msg.Type == tea.KeyEsc, key.Matches(msg, keys.Timeline):
+		m.showTimeline = false
+	case key.Matches(msg, keys.Quit):
+		return m, tea.Quit
+	case msg.String() == "l", msg.Type == tea.KeyRight:
+		m.moveTimeline(1)
+	case msg.String() == "h", msg.Type == tea.KeyLeft:
+		m.moveTimeline(-1)
+	case msg.String() == "L":
+		m.moveTimeline(10)
+	case msg.String() == "H":
+		m.moveTimeline(-10)
+	case msg.String() == "g":
+		m.timelineCursor = 0
+	case msg.String() == "G":
+		m.timelineCursor = len(m.trace.Steps) - 1
+	case msg.String() == "d":
+		m.openStepDetail(m.trace.Steps, m.timelineCursor)
+	case msg.Type == tea.KeyEnter:
+		// Jump to the file the step touched
+		s := m.trace.Steps[m.timelineCursor]
+		for i, f := range m.diffSet.Files {
+			if s.Touches(f.Name()) {
+				m.showTimeline = false
+				m.selectFile(i)
+				if s.LineStart > 0 {
+					for li, rl := range m.lines {
+						if rl.NewNum >= s.LineStart && !rl.IsFinding && !rl.IsComment {
+							m.scrollOffset = li
+							break
+						}
+					}
+				}
+				return m, nil
+			}
+		}
+		m.message = "step touches no file in the diff"
+	}
+	return m, nil
+}
+
+func (m Model) renderTimeline() string {
+	steps := m.trace.Steps
+	var b strings.Builder
+
+	title := fmt.Sprintf("Trace Timeline (%s) — %d steps", m.trace.Source, len(steps))
+	b.WriteString(traceHeaderStyle.Render(title))
+	b.WriteByte('\n')
+
+	if len(steps) == 0 {
+		b.WriteString(helpBarStyle.Render("No trace steps"))
+		return b.String()
+	}
+
+	const labelWidth = 7
+	axisWidth := max(m.width-labelWidth-4, 10)
+	cursorCol := m.timelineColumn(m.timelineCursor, axisWidth)
+
+	// One lane per step group
+	for _, lane := range timelineLanes {
+		row := []rune(strings.Repeat("·", axisWidth))
+		for i, s := range steps {
+			for _, t := range lane.types {
+				if s.Type == t {
+					row[m.timelineColumn(i, axisWidth)] = []rune(stepIcon(s.Type))[0]
+				}
+			}
+		}
+		b.WriteString(helpKeyStyle.Width(labelWidth).Render(lane.name))
+		b.WriteString(" ")
+		b.WriteString(contextLineStyle.Render(string(row[:cursorCol])))
+		b.WriteString(searchMatchStyle.Render(string(row[cursorCol])))
+		b.WriteString(contextLineStyle.Render(string(row[cursorCol+1:])))
+		b.WriteByte('\n')
+	}
+
+	// Cursor marker and time labels
+	b.WriteString(strings.Repeat(" ", labelWidth+1+cursorCol))
+	b.WriteString(hunkHeaderStyle.Render("▲"))
+	b.WriteByte('\n')
+	startLabel, endLabel := timeLabel(steps[0].Timestamp), timeLabel(steps[len(steps)-1].Timestamp)
+	gap := max(axisWidth-len(startLabel)-len(endLabel), 1)
+	b.WriteString(strings.Repeat(" ", labelWidth+1))
+	b.WriteString(helpBarStyle.Render(startLabel + strings.Repeat(" ", gap) + endLabel))
+	b.WriteString("\n\n")
+
+	// Current step
+	cur := steps[m.timelineCursor]
+	when := timeLabel(cur.Timestamp)
+	if when == "" {
+		when = "—"
+	}
+	b.WriteString(fileHeaderStyle.Render(fmt.Sprintf("Step %d/%d  %s  %s", m.timelineCursor+1, len(steps), cur.Type, when)))
+	b.WriteByte('\n')
+	b.WriteString(renderTraceStep(cur, m.width-4, true))
+	b.WriteString("\n\n")
+
+	// Files affected so far: ● at this step, ✓ earlier, · not yet
+	b.WriteString(fileHeaderStyle.Render("Files at this point"))
+	b.WriteByte('\n')
+	for i, f := range m.diffSet.Files {
+		name := f.Name()
+		marker := filePendingStyle.Render("·")
+		style := filePendingStyle
+		for si := 0; si <= m.timelineCursor; si++ {
+			s := steps[si]
+			if (s.Type == trace.StepFileWrite || s.Type == trace.StepFileEdit) && s.Touches(name) {
+				marker, style = fileApprovedStyle.Render("✓"), fileItemStyle
+			}
+		}
+		line := name
+		if cur.Touches(name) {
+			marker, style = fileRejectedStyle.Render("●"), fileItemSelectedStyle
+			if hunks := hunksForStep(cur, m.hunkRanges(i)); len(hunks) > 0 {
+				var parts []string
+				for _, h := range hunks {
+					parts = append(parts, formatHunkHeader(f.Fragments[h]))
+				}
+				line += "  " + strings.Join(parts, " ")
+			}
+		}
+		b.WriteString("  " + marker + " " + style.Render(truncate(line, m.width-8)))
+		b.WriteByte('\n')
+	}
+
+	b.WriteByte('\n')
+	b.WriteString(helpBarStyle.Render("h/l step  H/L ±10  g/G first/last  enter jump to file  d detail  esc close"))
+
+	content := b.String()
+	lines := strings.Split(content, "\n")
+	if m.height > 0 && len(lines) > m.height {
+		content = strings.Join(lines[:m.height], "\n")
+	}
+	return lipgloss.NewStyle().Width(m.width).Render(content)
+}
+
+func timeLabel(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Local().Format("15:04:05")
+}
@@ -4,30 +4,60 @@ package tui
 import (
 	"fmt"
 	"math"
-	"path/filepath"
+	"slices"
 	"strings"
 	"time"
 
 	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/textinput"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 	"github.com/aezell/agrev/internal/analysis"
+	"github.com/aezell/agrev/internal/audit"
 	"github.com/aezell/agrev/internal/diff"
+	"github.com/aezell/agrev/internal/explain"
 	"github.com/aezell/agrev/internal/model"
+	"github.com/aezell/agrev/internal/owners"
+	"github.com/aezell/agrev/internal/policy"
+	"github.com/aezell/agrev/internal/review"
 	"github.com/aezell/agrev/internal/trace"
+	"github.com/aezell/agrev/internal/triage"
 )
 
 // Model is the top-level Bubble Tea model for agrev.
 type Model struct {
 	diffSet *diff.DiffSet
 	trace   *trace.Trace // nil if no trace
+	repoDir string       // for reading file contents; empty if unknown
+	owners  *owners.File // CODEOWNERS; nil if none
+	policy  *policy.Policy
+	triage  *triage.Store // findings triaged in earlier runs; nil outside a repository
+	triaged bool          // whether this review marked any
+	audit   *audit.Log    // where reviewer actions are logged; nil for none
+
+	// Commit-by-commit review of a range
+	commits       []diff.Commit
+	commitIndex   int           // commit being viewed; -1 for the whole range
+	rangeDiff     *diff.DiffSet // diff of the whole range
+	nameDecisions map[string]model.ReviewDecision            // decisions by file name across views
+	nameHunks     map[string]map[string]model.ReviewDecision // file name -> hunk ID -> the hunk's own decision
+	nameNotes     map[string]string                          // noteKey -> the note on a file or hunk
+	nameBulk      map[string]bool                            // names of files decided in bulk
+	nameConds     map[string][]string                        // file name -> the follow-ups it was approved on
+	nameLabels    map[string][]string                        // noteKey -> the labels on a file or hunk
+
+	// Watch mode: polled for a changed diff, nil when not watching
+	reload func(raw string) (*diff.DiffSet, *analysis.Results, error)
 
 	// UI state
 	width  int
 	height int
 
 	// File list
-	fileIndex int // currently selected file
+	fileIndex int        // currently selected file
+	filter      fileFilter // active file list filters
+	labelFilter string     // label the file list is narrowed to; "" for none
+	sortMode  fileSort   // file list ordering
 
 	// Diff viewport
 	scrollOffset int // scroll position within the current file's diff
@@ -39,6 +69,38 @@ type Model struct {
 	// View mode
 	splitView bool
 
+	// Context expansion
+	fileContent   map[int][]string    // fileIndex -> full new content, loaded on demand
+	extraContext  map[int]map[int]int // fileIndex -> hunk -> extra context lines
+	wholeFileView map[int]bool        // fileIndex -> show the diff within the full file
+
+	// Syntax colors of the code lines of each file highlighted so far
+	highlights map[*diff.File]map[lineKey][]diff.Token
+
+	// Declaration summary above each diff, worked out once per file
+	semantic      bool
+	semanticLines map[*diff.File][]renderedLine
+
+	// Packages bumped, above each diff of a change that only updates
+	// dependencies; worked out for the diff and findings they were from
+	bumpLines    []renderedLine
+	bumpsDiff    *diff.DiffSet
+	bumpsResults *analysis.Results
+
+	// Folding
+	foldedHunks   map[int]map[int]bool // fileIndex -> hunk -> explicitly folded/unfolded
+	foldContext   bool                 // fold long runs of unchanged lines
+	showGenerated map[int]bool         // fileIndex -> lockfile or generated file expanded
+
+	// One-shot status message, cleared on the next key press
+	message string
+
+	// Name of the change under review, e.g. "PR #12"
+	label string
+
+	// Reviewer name recorded on comments; empty if unknown
+	author string
+
 	// Trace panel
 	showTrace    bool
 	traceScroll  int
@@ -51,8 +113,82 @@ type Model struct {
 	analysisResults *analysis.Results
 	fileFindings    []analysis.Finding // findings for current file
 
-	// Review decisions
-	decisions map[int]model.ReviewDecision // fileIndex -> decision
+	// Review decisions, by file index and by hunk where decided one by one
+	decisions model.Decisions
+
+	// Queue mode: one file at a time in risk order
+	queueMode      bool
+	queueSavedSort fileSort // sort to restore when leaving queue mode
+
+	// Undo/redo history of review actions
+	undoStack []historyEntry
+	redoStack []historyEntry
+
+	// Review comments
+	comments     []model.Comment
+	commenting   bool // comment composer is open
+	commentInput textinput.Model
+	commentLine  int // line the open composer is attached to
+
+	// Notes on rejections, and the follow-ups on approvals with conditions
+	noting     bool // note prompt is open
+	noteInput  textinput.Model
+	noteTarget model.HunkKey // what the open prompt is about
+	noteFile   string        // name of noteTarget's file, in case the diff reloads
+	noteConds  bool          // the prompt takes follow-ups for an approval, one at a time
+
+	// Labels on files and hunks
+	labeling    bool // label prompt is open
+	labelInput  textinput.Model
+	labelTarget model.HunkKey // what the open prompt is about
+	labelFile   string        // name of labelTarget's file, in case the diff reloads
+
+	// Search
+	searching        bool // search input is open
+	searchInput      textinput.Model
+	searchQuery      string
+	searchMatches    []searchMatch
+	searchIndex      int // current position in searchMatches
+	searchOriginFile int // where the search began, for incremental jumps
+	searchOriginLine int
+
+	// Fuzzy file finder
+	showFinder    bool
+	finderInput   textinput.Model
+	finderResults []finderResult
+	finderCursor  int
+
+	// Trace timeline
+	showTimeline   bool
+	timelineCursor int // index into trace.Steps
+
+	// Full-screen trace step detail
+	showStepDetail bool
+	detailSteps    []trace.Step
+	detailIndex    int
+	detailScroll   int
+
+	// Findings panel
+	showFindings   bool
+	findingsCursor int
+
+	// Change groups panel
+	showGroups   bool
+	groups       []model.ChangeGroup
+	groupsCursor int
+
+	// Review checklist panel
+	checklist       []model.ChecklistItem
+	showChecklist   bool
+	checklistCursor int
+
+	// LLM explanations; explain is nil when they're off
+	explain       func(explain.Request) (string, error)
+	explaining    bool // a request is in flight
+	showExplain   bool
+	explainTitle  string
+	explainText   string
+	explainScroll int
 
 	// Summary view
 	showSummary   bool
@@ -80,11 +216,33 @@ func New(ds *diff.DiffSet, t *trace.Trace, ar *analysis.Results) Model {
 		trace:           t,
 		splitView:       false,
 		analysisResults: ar,
-		decisions:       make(map[int]model.ReviewDecision),
+		decisions:       model.NewDecisions(),
+		fileContent:     make(map[int][]string),
+		extraContext:    make(map[int]map[int]int),
+		wholeFileView:   make(map[int]bool),
+		highlights:      make(map[*diff.File]map[lineKey][]diff.Token),
+		semanticLines:   make(map[*diff.File][]renderedLine),
+		foldedHunks:     make(map[int]map[int]bool),
+		foldContext:     true,
+		showGenerated:   make(map[int]bool),
+		commitIndex:     -1,
+		rangeDiff:       ds,
+		nameDecisions:   make(map[string]model.ReviewDecision),
+		nameHunks:       make(map[string]map[string]model.ReviewDecision),
+		nameNotes:       make(map[string]string),
+		nameBulk:        make(map[string]bool),
+		nameConds:       make(map[string][]string),
+		nameLabels:      make(map[string][]string),
+		commentInput:    newCommentInput(),
+		noteInput:       newNoteInput(),
+		labelInput:      newLabelInput(),
+		searchInput:     newSearchInput(),
+		finderInput:     newFinderInput(),
 	}
 	m.updateFileFindings()
 	m.updateLines()
 	m.updateTraceSteps()
+	m.highlightVisible()
 	return m
 }
 
@@ -104,10 +262,17 @@ func (m *Model) updateLines() {
 		m.lines = nil
 		return
 	}
-	base := renderFile(m.diffSet.Files[m.fileIndex])
+	base := m.annotateLines(m.markMoves(m.foldLines(m.renderCurrentFile())))
+	if m.semantic {
+		base = append(append([]renderedLine(nil), m.semanticSummary()...), base...)
+	}
+	if bumps := m.bumpSummary(); len(bumps) > 0 {
+		base = append(append([]renderedLine(nil), bumps...), base...)
+	}
+	fileComments := m.fileComments()
 
-	// Insert finding annotations into the line list
-	if len(m.fileFindings) == 0 {
+	// Insert finding and comment annotations into the line list
+	if len(m.fileFindings) == 0 && len(fileComments) == 0 {
 		m.lines = base
 		return
 	}
@@ -123,10 +288,21 @@ func (m *Model) updateLines() {
 		}
 	}
 
+	commentsByLine := make(map[int][]model.Comment)
+	var fileLevelComments []model.Comment
+	for _, c := range fileComments {
+		if c.Line == 0 {
+			fileLevelComments = append(fileLevelComments, c)
+		} else {
+			commentsByLine[c.Line] = append(commentsByLine[c.Line], c)
+		}
+	}
+
 	var lines []renderedLine
-	placed := make(map[int]bool) // track which line numbers were matched
+	placed := make(map[int]bool)        // track which line numbers were matched
+	placedComments := make(map[int]bool) // same, for comments
 
-	// Interleave findings after their matching diff lines (check both NewNum and OldNum)
+	// Interleave findings and comments after their matching diff lines (check both NewNum and OldNum)
 	for _, rl := range base {
 		lines = append(lines, rl)
 		for _, num := range []int{rl.NewNum, rl.OldNum} {
@@ -138,21 +314,29 @@ func (m *Model) updateLines() {
 						lines = append(lines, renderedLine{
 							IsFinding:   true,
 							FindingRisk: int(fin.Risk),
-							Content:     fmt.Sprintf("  >> [%s%s] %s", fin.Pass, loc, fin.Message),
+							Content:     fmt.Sprintf("  >> [%s%s%s] %s", fin.Pass, loc, findingTag(fin), fin.Message),
 						})
 					}
 				}
 			}
+			if num > 0 && !placedComments[num] {
+				if comments, ok := commentsByLine[num]; ok {
+					placedComments[num] = true
+					for _, c := range comments {
+						lines = append(lines, commentLine(c))
+					}
+				}
+			}
 		}
 	}
 
-	// File-level findings and any unplaced findings go at the top
+	// File-level and unplaced findings and comments go at the top
 	var topFindings []renderedLine
 	for _, fin := range fileLevelFindings {
 		topFindings = append(topFindings, renderedLine{
 			IsFinding:   true,
 			FindingRisk: int(fin.Risk),
-			Content:     fmt.Sprintf("  >> [%s] %s", fin.Pass, fin.Message),
+			Content:     fmt.Sprintf("  >> [%s%s] %s", fin.Pass, findingTag(fin), fin.Message),
 		})
 	}
 	for lineNum, findings := range findingsByLine {
@@ -164,10 +348,21 @@ func (m *Model) updateLines() {
 			topFindings = append(topFindings, renderedLine{
 				IsFinding:   true,
 				FindingRisk: int(fin.Risk),
-				Content:     fmt.Sprintf("  >> [%s%s] %s", fin.Pass, loc, fin.Message),
+				Content:     fmt.Sprintf("  >> [%s%s%s] %s", fin.Pass, loc, findingTag(fin), fin.Message),
 			})
 		}
 	}
+	for _, c := range fileLevelComments {
+		topFindings = append(topFindings, commentLine(c))
+	}
+	for lineNum, comments := range commentsByLine {
+		if placedComments[lineNum] {
+			continue
+		}
+		for _, c := range comments {
+			topFindings = append(topFindings, commentLine(c))
+		}
+	}
 	if len(topFindings) > 0 {
 		lines = append(topFindings, lines...)
 	}
@@ -175,6 +370,29 @@ func (m *Model) updateLines() {
 	m.lines = lines
 }
 
+func commentLine(c model.Comment) renderedLine {
+	loc := ""
+	if c.Line > 0 {
+		loc = fmt.Sprintf(":%d", c.Line)
+	}
+	return renderedLine{
+		IsComment: true,
+		Content:   fmt.Sprintf("  ## [comment%s] %s", loc, c.Body),
+	}
+}
+
+// fileComments returns the comments attached to the current file.
+func (m *Model) fileComments() []model.Comment {
+	name := m.diffSet.Files[m.fileIndex].Name()
+	var result []model.Comment
+	for _, c := range m.comments {
+		if c.File == name {
+			result = append(result, c)
+		}
+	}
+	return result
+}
+
 func (m *Model) updateTraceSteps() {
 	if m.trace == nil {
 		m.traceSteps = nil
@@ -193,11 +411,8 @@ func (m *Model) updateTraceSteps() {
 	// Match by filename (trace may have absolute paths)
 	var filtered []trace.Step
 	for _, s := range m.trace.Steps {
-		if s.FilePath != "" {
-			base := filepath.Base(s.FilePath)
-			if base == filepath.Base(name) || strings.HasSuffix(s.FilePath, name) {
-				filtered = append(filtered, s)
-			}
+		if s.Touches(name) {
+			filtered = append(filtered, s)
 		}
 	}
 
@@ -211,11 +426,24 @@ func (m *Model) updateTraceSteps() {
 
 // Init implements tea.Model.
 func (m Model) Init() tea.Cmd {
+	if m.reload != nil {
+		return tea.Batch(tickCmd(), watchTickCmd())
+	}
 	return tickCmd()
 }
 
-// Update implements tea.Model.
+// Update implements tea.Model. Whatever a message changed, the lines it
+// brought into view are highlighted afterwards.
 func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	next, cmd := m.update(msg)
+	if nm, ok := next.(Model); ok {
+		nm.highlightVisible()
+		next = nm
+	}
+	return next, cmd
+}
+
+func (m Model) update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg := msg.(type) {
 	case tickMsg:
 		m.pulsePhase += 0.15
@@ -224,6 +452,35 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		}
 		return m, tickCmd()
 
+	case watchTickMsg:
+		return m, m.reloadCmd()
+
+	case diffReloadedMsg:
+		if msg.err != nil {
+			m.message = fmt.Sprintf("reload: %v", msg.err)
+		} else if msg.ds != nil {
+			m.applyReload(msg.ds, msg.ar)
+		}
+		return m, watchTickCmd()
+
+	case explainedMsg:
+		m.explaining = false
+		if msg.err != nil {
+			m.message = fmt.Sprintf("explain: %v", msg.err)
+			return m, nil
+		}
+		m.showExplain = true
+		m.explainTitle = msg.title
+		m.explainText = msg.text
+		m.explainScroll = 0
+		return m, nil
+
+	case editorFinishedMsg:
+		if msg.err != nil {
+			m.message = fmt.Sprintf("editor: %v", msg.err)
+		}
+		return m, nil
+
 	case tea.WindowSizeMsg:
 		m.width = msg.Width
 		m.height = msg.Height
@@ -231,11 +488,82 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		return m, nil
 
 	case tea.KeyMsg:
+		m.message = ""
+
 		// In summary view, handle differently
 		if m.showSummary {
 			return m.updateSummary(msg)
 		}
 
+		// The comment composer captures all keys while open
+		if m.commenting {
+			return m.updateComment(msg)
+		}
+
+		// As does the prompt for a rejection's note
+		if m.noting {
+			return m.updateNote(msg)
+		}
+
+		// And the label prompt
+		if m.labeling {
+			return m.updateLabel(msg)
+		}
+
+		// So does the search input
+		if m.searching {
+			return m.updateSearch(msg)
+		}
+
+		// And the file finder overlay
+		if m.showFinder {
+			return m.updateFinder(msg)
+		}
+
+		if m.showFindings {
+			return m.updateFindingsPanel(msg)
+		}
+
+		if m.showGroups {
+			return m.updateGroupsPanel(msg)
+		}
+
+		if m.showChecklist {
+			return m.updateChecklistPanel(msg)
+		}
+
+		if m.showStepDetail {
+			return m.updateStepDetail(msg)
+		}
+
+		if m.showExplain {
+			return m.updateExplain(msg)
+		}
+
+		if m.showTimeline {
+			return m.updateTimeline(msg)
+		}
+
+		// With an active search, n/p step through matches and esc clears it
+		if m.searchQuery != "" {
+			switch {
+			case key.Matches(msg, keys.NextMatch):
+				m.nextMatch()
+				return m, nil
+			case key.Matches(msg, keys.PrevMatch):
+				m.prevMatch()
+				return m, nil
+			case msg.Type == tea.KeyEsc:
+				m.clearSearch()
+				return m, nil
+			}
+		}
+
+		if m.noFilesMatch() && key.Matches(msg, fileKeys...) {
+			m.message = "no files match the filter; 0 clears it"
+			return m, nil
+		}
+
 		switch {
 		case key.Matches(msg, keys.Quit):
 			return m, tea.Quit
@@ -263,23 +591,23 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			}
 
 		case key.Matches(msg, keys.NextFile):
-			if m.fileIndex < len(m.diffSet.Files)-1 {
-				m.fileIndex++
-				m.scrollOffset = 0
-				m.traceScroll = 0
-				m.updateFileFindings()
-				m.updateLines()
-				m.updateTraceSteps()
+			if i := m.nextVisibleFile(); i >= 0 {
+				m.selectFile(i)
 			}
 
 		case key.Matches(msg, keys.PrevFile):
-			if m.fileIndex > 0 {
-				m.fileIndex--
-				m.scrollOffset = 0
-				m.traceScroll = 0
-				m.updateFileFindings()
-				m.updateLines()
-				m.updateTraceSteps()
+			if i := m.prevVisibleFile(); i >= 0 {
+				m.selectFile(i)
+			}
+
+		case key.Matches(msg, keys.NextCommit):
+			if m.commitIndex < len(m.commits)-1 {
+				m.showCommit(m.commitIndex + 1)
+			}
+
+		case key.Matches(msg, keys.PrevCommit):
+			if m.commitIndex >= 0 {
+				m.showCommit(m.commitIndex - 1)
 			}
 
 		case key.Matches(msg, keys.NextHunk):
@@ -294,9 +622,40 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		case key.Matches(msg, keys.PrevFinding):
 			m.jumpToPrevFinding()
 
+		case key.Matches(msg, keys.FindingsPanel):
+			m.showFindings = true
+			m.findingsCursor = 0
+
+		case key.Matches(msg, keys.GroupsPanel):
+			if len(m.diffSet.Files) > 0 {
+				m.openGroups()
+			}
+
+		case key.Matches(msg, keys.Checklist):
+			m.openChecklist()
+
 		case key.Matches(msg, keys.Toggle):
 			m.splitView = !m.splitView
 
+		case key.Matches(msg, keys.Expand):
+			m.expandContext(5)
+
+		case key.Matches(msg, keys.ExpandMore):
+			m.expandContext(20)
+
+		case key.Matches(msg, keys.WholeFile):
+			m.toggleWholeFile()
+
+		case key.Matches(msg, keys.Semantic):
+			m.toggleSemantic()
+
+		case key.Matches(msg, keys.Fold):
+			m.toggleFold()
+
+		case key.Matches(msg, keys.FoldContext):
+			m.foldContext = !m.foldContext
+			m.relayout()
+
 		case key.Matches(msg, keys.Trace):
 			if m.trace != nil {
 				m.showTrace = !m.showTrace
@@ -305,6 +664,11 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				}
 			}
 
+		case key.Matches(msg, keys.Timeline):
+			if m.trace != nil && len(m.trace.Steps) > 0 {
+				m.showTimeline = true
+			}
+
 		case key.Matches(msg, keys.FocusSwap):
 			if m.showTrace {
 				m.focusPanel = 1 - m.focusPanel
@@ -315,22 +679,134 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 		case key.Matches(msg, keys.Approve):
 			if len(m.diffSet.Files) > 0 {
-				m.decisions[m.fileIndex] = model.DecisionApproved
+				m.record("approve " + m.diffSet.Files[m.fileIndex].Name())
+				m.decideFile(m.fileIndex, model.DecisionApproved)
+				m.advanceAfterDecision()
+			}
+
+		case key.Matches(msg, keys.ApproveWith):
+			if len(m.diffSet.Files) > 0 {
+				m.record("approve " + m.diffSet.Files[m.fileIndex].Name() + " with follow-ups")
+				m.decideFile(m.fileIndex, model.DecisionApproved)
+				cmd := m.askConditions(m.fileIndex)
 				m.advanceAfterDecision()
+				return m, cmd
 			}
 
 		case key.Matches(msg, keys.Reject):
 			if len(m.diffSet.Files) > 0 {
-				m.decisions[m.fileIndex] = model.DecisionRejected
+				m.record("reject " + m.diffSet.Files[m.fileIndex].Name())
+				m.decideFile(m.fileIndex, model.DecisionRejected)
+				cmd := m.askNote(m.fileIndex, model.WholeFile)
+				m.advanceAfterDecision()
+				return m, cmd
+			}
+
+		case key.Matches(msg, keys.ApproveHunk):
+			m.decideHunk(model.DecisionApproved)
+
+		case key.Matches(msg, keys.RejectHunk):
+			return m, m.decideHunk(model.DecisionRejected)
+
+		case key.Matches(msg, keys.SplitHunk):
+			m.splitHunk()
+
+		case key.Matches(msg, keys.ApproveSpace):
+			if files := m.approveWhitespace(); len(files) > 0 {
+				m.message = fmt.Sprintf("approved %d whitespace-only files", len(files)) + m.ownedInBulk(files)
 				m.advanceAfterDecision()
+			} else {
+				m.message = "no undecided whitespace-only files"
 			}
 
 		case key.Matches(msg, keys.Undo):
+			m.undo()
+
+		case key.Matches(msg, keys.Redo):
+			m.redo()
+
+		case key.Matches(msg, keys.Search):
 			if len(m.diffSet.Files) > 0 {
-				delete(m.decisions, m.fileIndex)
+				m.searching = true
+				m.searchOriginFile = m.fileIndex
+				m.searchOriginLine = m.scrollOffset
+				m.clearSearch()
+				m.searchInput.Reset()
+				return m, m.searchInput.Focus()
 			}
 
+		case key.Matches(msg, keys.FindFile):
+			if len(m.diffSet.Files) > 0 {
+				m.showFinder = true
+				m.finderInput.Reset()
+				m.updateFinderResults()
+				return m, m.finderInput.Focus()
+			}
+
+		case key.Matches(msg, keys.Label):
+			if len(m.diffSet.Files) > 0 {
+				return m, m.askLabel(m.fileIndex, model.WholeFile)
+			}
+
+		case key.Matches(msg, keys.LabelHunk):
+			if len(m.diffSet.Files) > 0 && len(m.diffSet.Files[m.fileIndex].Fragments) > 0 {
+				return m, m.askLabel(m.fileIndex, m.currentHunk())
+			}
+
+		case key.Matches(msg, keys.Comment):
+			if len(m.diffSet.Files) > 0 {
+				m.commenting = true
+				m.commentLine = m.cursorLineNum()
+				m.commentInput.Reset()
+				return m, m.commentInput.Focus()
+			}
+
+		case key.Matches(msg, keys.FilterPending):
+			m.toggleFilter(filterPending)
+
+		case key.Matches(msg, keys.FilterHighRisk):
+			m.toggleFilter(filterHighRisk)
+
+		case key.Matches(msg, keys.FilterFindings):
+			m.toggleFilter(filterFindings)
+
+		case key.Matches(msg, keys.FilterNew):
+			m.toggleFilter(filterNew)
+
+		case key.Matches(msg, keys.FilterLabel):
+			m.cycleLabelFilter()
+
+		case key.Matches(msg, keys.FilterClear):
+			m.filter, m.labelFilter = 0, ""
+
+		case key.Matches(msg, keys.Sort):
+			if m.queueMode {
+				m.message = "queue mode sorts by risk"
+			} else {
+				m.cycleSort()
+			}
+
+		case key.Matches(msg, keys.Queue):
+			m.toggleQueue()
+
+		case key.Matches(msg, keys.Edit):
+			return m.openEditor()
+
+		case key.Matches(msg, keys.Explain):
+			return m.explainHunk()
+
+		case key.Matches(msg, keys.CopyHunk):
+			return m.copyPatch(false)
+
+		case key.Matches(msg, keys.CopyFile):
+			return m.copyPatch(true)
+
 		case key.Matches(msg, keys.Finish):
+			// With the trace focused, enter expands the current step instead
+			if m.focusPanel == 1 && len(m.traceSteps) > 0 {
+				m.openStepDetail(m.traceSteps, m.traceScroll)
+				return m, nil
+			}
 			m.showSummary = true
 			m.summaryScroll = 0
 		}
@@ -339,20 +815,103 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	return m, nil
 }
 
-func (m *Model) advanceAfterDecision() {
-	// Auto-advance to the next undecided file
-	for i := m.fileIndex + 1; i < len(m.diffSet.Files); i++ {
-		if _, decided := m.decisions[i]; !decided {
-			m.fileIndex = i
-			m.scrollOffset = 0
-			m.traceScroll = 0
-			m.updateFileFindings()
+func newCommentInput() textinput.Model {
+	ti := textinput.New()
+	ti.Placeholder = "write a comment, enter to save, esc to cancel"
+	ti.CharLimit = 500
+	return ti
+}
+
+// cursorLineNum returns the file line number at the top of the diff viewport,
+// or 0 if no numbered line is visible (which attaches a comment to the file).
+func (m Model) cursorLineNum() int {
+	for i := m.scrollOffset; i < len(m.lines); i++ {
+		rl := m.lines[i]
+		if rl.IsHunk || rl.IsFinding || rl.IsComment {
+			continue
+		}
+		if rl.NewNum > 0 {
+			return rl.NewNum
+		}
+		if rl.OldNum > 0 {
+			return rl.OldNum
+		}
+	}
+	return 0
+}
+
+func (m Model) updateComment(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.Type {
+	case tea.KeyEnter:
+		body := strings.TrimSpace(m.commentInput.Value())
+		if body != "" {
+			m.record("comment on " + m.diffSet.Files[m.fileIndex].Name())
+			f := m.diffSet.Files[m.fileIndex]
+			c := model.Comment{
+				File:   f.Name(),
+				Line:   m.commentLine,
+				Hunk:   f.HunkAt(m.commentLine),
+				Body:   body,
+				Author: m.author,
+				Time:   time.Now(),
+			}
+			m.comments = append(m.comments, c)
+			m.logAction(audit.Event{Action: audit.ActionComment, File: c.File, Line: c.Line, Hunk: c.Hunk, Detail: c.Body})
 			m.updateLines()
-			m.updateTraceSteps()
+		}
+		m.commenting = false
+		m.commentInput.Blur()
+		return m, nil
+	case tea.KeyEsc:
+		m.commenting = false
+		m.commentInput.Blur()
+		return m, nil
+	}
+
+	var cmd tea.Cmd
+	m.commentInput, cmd = m.commentInput.Update(msg)
+	return m, cmd
+}
+
+// selectFile switches the view to the file at index i.
+func (m *Model) selectFile(i int) {
+	m.fileIndex = i
+	m.scrollOffset = 0
+	m.traceScroll = 0
+	m.updateFileFindings()
+	m.updateLines()
+	m.updateTraceSteps()
+}
+
+func (m *Model) advanceAfterDecision() {
+	// Auto-advance to the next undecided file in list order. The current
+	// file may have just been filtered out, so walk the unfiltered order.
+	order := make([]int, len(m.diffSet.Files))
+	for i := range order {
+		order[i] = i
+	}
+	m.sortFiles(order)
+
+	after := false
+	for _, i := range order {
+		if i == m.fileIndex {
+			after = true
+			continue
+		}
+		if after && !m.fileDecided(i) && m.fileVisible(i) {
+			m.selectFile(i)
+			return
+		}
+	}
+	// If all remaining are decided, stay on current file unless the
+	// decision filtered it out of the list
+	if !m.fileVisible(m.fileIndex) {
+		if visible := m.visibleFiles(); len(visible) > 0 {
+			m.selectFile(visible[0])
 			return
 		}
 	}
-	// If all remaining are decided, stay on current file
+	m.relayout() // decided files render folded
 }
 
 func (m Model) updateSummary(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
@@ -366,8 +925,16 @@ func (m Model) updateSummary(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 			m.summaryScroll--
 		}
 	case key.Matches(msg, keys.Finish):
-		// Pressing Enter on summary exits
+		// Pressing Enter on summary exits, once the checklist is done if
+		// the policy requires it
+		if left := model.ChecklistLeft(m.checklist); left > 0 && m.policy.RequiresChecklist() {
+			m.message = fmt.Sprintf("the policy requires the checklist: %d item(s) left (L)", left)
+			return m, nil
+		}
 		return m, tea.Quit
+	case key.Matches(msg, keys.Checklist):
+		m.showSummary = false
+		m.openChecklist()
 	case msg.String() == "esc":
 		// Go back to review
 		m.showSummary = false
@@ -375,19 +942,27 @@ func (m Model) updateSummary(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	return m, nil
 }
 
-// ReviewDecisions returns the current per-file decisions.
-func (m Model) ReviewDecisions() map[int]model.ReviewDecision {
+// ReviewDecisions returns the current decisions on files and hunks.
+func (m Model) ReviewDecisions() model.Decisions {
 	return m.decisions
 }
 
-// DecisionCounts returns counts of approved, rejected, and pending files.
-func (m Model) DecisionCounts() (approved, rejected, pending int) {
+// Comments returns the review comments recorded so far.
+func (m Model) Comments() []model.Comment {
+	return m.comments
+}
+
+// DecisionCounts returns counts of approved, rejected, partly approved, and
+// pending files.
+func (m Model) DecisionCounts() (approved, rejected, partial, pending int) {
 	for i := range m.diffSet.Files {
-		switch m.decisions[i] {
+		switch m.fileDecision(i) {
 		case model.DecisionApproved:
 			approved++
 		case model.DecisionRejected:
 			rejected++
+		case model.DecisionPartial:
+			partial++
 		default:
 			pending++
 		}
@@ -445,6 +1020,34 @@ func (m Model) View() string {
 		return m.renderHelp()
 	}
 
+	if m.showFinder {
+		return m.renderFinder()
+	}
+
+	if m.showFindings {
+		return m.renderFindingsPanel()
+	}
+
+	if m.showGroups {
+		return m.renderGroupsPanel()
+	}
+
+	if m.showChecklist {
+		return m.renderChecklistPanel()
+	}
+
+	if m.showStepDetail {
+		return m.renderStepDetail()
+	}
+
+	if m.showExplain {
+		return m.renderExplain()
+	}
+
+	if m.showTimeline {
+		return m.renderTimeline()
+	}
+
 	// Layout: file list on left, diff in center, trace on right (if shown)
 	// Each bordered panel adds 4 chars (2 border + 2 padding) beyond its Width().
 	const panelChrome = 4 // border (2) + padding (2) per panel
@@ -453,33 +1056,44 @@ func (m Model) View() string {
 	fileListWidth := m.fileListWidth()
 	mainHeight := m.height - 2 // status bar
 
+	// Queue mode trades the file list for a progress header
+	var queueHeader string
+	listChrome := fileListWidth + panelChrome + gap
+	if m.queueMode {
+		queueHeader = m.renderQueueHeader()
+		mainHeight--
+		listChrome = 0
+	}
+
 	// Calculate diff and trace widths
 	// Total budget: m.width = fileList(width+chrome) + gap + diff(width+chrome) [+ gap + trace(width+chrome)]
 	var diffWidth, traceWidth int
 	if m.showTrace && m.trace != nil {
-		available := m.width - (fileListWidth + panelChrome) - gap - gap - panelChrome - panelChrome
+		available := m.width - listChrome - gap - panelChrome - panelChrome
 		traceWidth = available * 35 / 100
 		if traceWidth < 26 {
 			traceWidth = 26
 		}
 		diffWidth = available - traceWidth
 	} else {
-		diffWidth = m.width - (fileListWidth + panelChrome) - gap - panelChrome
+		diffWidth = m.width - listChrome - panelChrome
 	}
 
-	fileList := m.renderFileList(fileListWidth, mainHeight)
 	diffView := m.renderDiffView(diffWidth, mainHeight)
-
-	var main string
+	panels := []string{diffView}
 	if m.showTrace && m.trace != nil {
-		traceView := m.renderTracePanel(traceWidth, mainHeight)
-		main = lipgloss.JoinHorizontal(lipgloss.Top, fileList, " ", diffView, " ", traceView)
-	} else {
-		main = lipgloss.JoinHorizontal(lipgloss.Top, fileList, " ", diffView)
+		panels = append(panels, " ", m.renderTracePanel(traceWidth, mainHeight))
 	}
+	if !m.queueMode {
+		panels = append([]string{m.renderFileList(fileListWidth, mainHeight), " "}, panels...)
+	}
+	main := lipgloss.JoinHorizontal(lipgloss.Top, panels...)
 
 	statusBar := m.renderStatusBar()
 
+	if m.queueMode {
+		return lipgloss.JoinVertical(lipgloss.Left, queueHeader, main, statusBar)
+	}
 	return lipgloss.JoinVertical(lipgloss.Left, main, statusBar)
 }
 
@@ -504,16 +1118,28 @@ func (m Model) fileListWidth() int {
 func (m Model) renderFileList(width, height int) string {
 	var b strings.Builder
 
-	for i, f := range m.diffSet.Files {
+	visible := m.visibleFiles()
+	if len(visible) == 0 {
+		b.WriteString(filePendingStyle.Render("No files match filter"))
+	}
+
+	for vi, i := range visible {
+		f := m.diffSet.Files[i]
 		name := f.Name()
 
 		// Decision indicator
 		var indicator string
-		switch m.decisions[i] {
+		decision := m.fileDecision(i)
+		switch decision {
 		case model.DecisionApproved:
 			indicator = fileApprovedStyle.Render("V ")
+			if len(m.decisions.Conditions[i]) > 0 {
+				indicator = fileApprovedStyle.Render("V+")
+			}
 		case model.DecisionRejected:
 			indicator = fileRejectedStyle.Render("X ")
+		case model.DecisionPartial:
+			indicator = filePartialStyle.Render("~ ")
 		default:
 			indicator = filePendingStyle.Render("- ")
 		}
@@ -529,10 +1155,12 @@ func (m Model) renderFileList(width, height int) string {
 		var style lipgloss.Style
 		if i == m.fileIndex {
 			style = fileItemSelectedStyle
-		} else if m.decisions[i] == model.DecisionApproved {
+		} else if decision == model.DecisionApproved {
 			style = lipgloss.NewStyle().Foreground(colorGreen)
-		} else if m.decisions[i] == model.DecisionRejected {
+		} else if decision == model.DecisionRejected {
 			style = lipgloss.NewStyle().Foreground(colorRed)
+		} else if f.Generated() {
+			style = filePendingStyle // lockfiles and the like recede
 		} else if f.IsNew {
 			style = fileItemNewStyle
 		} else if f.IsDeleted {
@@ -542,7 +1170,7 @@ func (m Model) renderFileList(width, height int) string {
 		}
 
 		b.WriteString(indicator + style.Width(width - 8).Render(line))
-		if i < len(m.diffSet.Files)-1 {
+		if vi < len(visible)-1 {
 			b.WriteByte('\n')
 		}
 	}
@@ -562,15 +1190,42 @@ func (m Model) renderDiffView(width, height int) string {
 	if len(m.diffSet.Files) == 0 {
 		return diffViewStyle.Width(width).Height(height - 2).Render("No changes")
 	}
+	if m.noFilesMatch() {
+		return diffViewStyle.Width(width).Height(height - 2).Render("No files match the filter (0 clears it)")
+	}
 
 	f := m.diffSet.Files[m.fileIndex]
 	innerWidth := width
 	innerHeight := height - 2
 
 	headerText := f.Name()
+	switch {
+	case f.Paired:
+		headerText += fmt.Sprintf("  (deleted and re-added, %d%% similar)", f.Similarity)
+	case f.IsRenamed && f.Similarity > 0 && f.Similarity < 100:
+		headerText += fmt.Sprintf("  (%d%% similar)", f.Similarity)
+	}
 	if len(m.fileFindings) > 0 {
 		headerText += fmt.Sprintf("  [%d findings]", len(m.fileFindings))
 	}
+	if note := m.decisions.Note(m.fileIndex, model.WholeFile); note != "" {
+		headerText += fmt.Sprintf("  [%s: %s]", m.fileDecision(m.fileIndex), note)
+	}
+	if conds := m.decisions.Conditions[m.fileIndex]; len(conds) > 0 {
+		headerText += fmt.Sprintf("  [approved, follow up: %s]", strings.Join(conds, "; "))
+	}
+	if labels := m.decisions.Label(m.fileIndex, model.WholeFile); len(labels) > 0 {
+		headerText += "  " + formatLabels(labels)
+	}
+	for _, h := range m.policyHits(m.fileIndex) {
+		headerText += "  [policy " + h.Describe() + "]"
+	}
+	if owners := m.owners.Of(f); len(owners) > 0 {
+		headerText += "  owners: " + strings.Join(owners, " ")
+		if m.decisions.Bulk[m.fileIndex] {
+			headerText += fmt.Sprintf(" (%s in bulk)", m.fileDecision(m.fileIndex))
+		}
+	}
 	header := fileHeaderStyle.Render(headerText)
 
 	// Header with bottom padding takes 2 lines
@@ -580,6 +1235,14 @@ func (m Model) renderDiffView(width, height int) string {
 	}
 
 	var b strings.Builder
+	if m.currentCommit() != nil {
+		b.WriteString(m.commitHeader(innerWidth))
+		b.WriteByte('\n')
+		visibleLines -= 2
+		if visibleLines < 1 {
+			visibleLines = 1
+		}
+	}
 	b.WriteString(header)
 	b.WriteByte('\n')
 
@@ -611,7 +1274,7 @@ func (m Model) renderUnifiedDiff(b *strings.Builder, width, visibleLines int) {
 	}
 
 	for i := m.scrollOffset; i < end; i++ {
-		b.WriteString(styleLine(m.lines[i], width, m.pulsePhase))
+		b.WriteString(styleLine(m.lines[i], width, m.pulsePhase, m.searchQuery))
 		if i < end-1 {
 			b.WriteByte('\n')
 		}
@@ -627,7 +1290,7 @@ func (m Model) renderSplitDiff(b *strings.Builder, width, visibleLines int) {
 	}
 
 	for i := m.scrollOffset; i < end; i++ {
-		left, right := styleLineSplit(m.lines[i], halfWidth, m.pulsePhase)
+		left, right := styleLineSplit(m.lines[i], halfWidth, m.pulsePhase, m.searchQuery)
 		b.WriteString(left)
 		b.WriteString(" │ ")
 		b.WriteString(right)
@@ -649,6 +1312,9 @@ func (m Model) renderTracePanel(width, height int) string {
 		title += fmt.Sprintf(" (%s)", m.trace.Source)
 	}
 	b.WriteString(traceHeaderStyle.Render(title))
+	if m.focusPanel == 1 && len(m.traceSteps) > 0 {
+		b.WriteString(helpBarStyle.Render("  enter expands"))
+	}
 	b.WriteByte('\n')
 
 	if len(m.traceSteps) == 0 {
@@ -741,12 +1407,50 @@ func stepIcon(st trace.StepType) string {
 }
 
 func (m Model) renderStatusBar() string {
+	if m.commenting {
+		return m.renderCommentBar()
+	}
+	if m.noting {
+		return m.renderNoteBar()
+	}
+	if m.labeling {
+		return m.renderLabelBar()
+	}
+	if m.searching {
+		return m.renderSearchBar()
+	}
+
 	nFiles, added, deleted := m.diffSet.Stats()
 
 	left := fmt.Sprintf(" File %d/%d", m.fileIndex+1, nFiles)
+	if m.label != "" {
+		left = " " + m.label + " " + left
+	}
 	if len(m.lines) > 0 {
 		left += fmt.Sprintf("  Line %d/%d", m.scrollOffset+1, len(m.lines))
 	}
+	if m.wholeFileView[m.fileIndex] {
+		left += "  [whole file]"
+	}
+	if m.semantic {
+		left += "  [declarations]"
+	}
+	if len(m.bumpLines) > 0 {
+		left += "  [dependency update]"
+	}
+	if m.reload != nil {
+		left += "  [watching]"
+	}
+	if len(m.commits) > 0 {
+		if m.commitIndex >= 0 {
+			left += fmt.Sprintf("  Commit %d/%d", m.commitIndex+1, len(m.commits))
+		} else {
+			left += fmt.Sprintf("  All %d commits", len(m.commits))
+		}
+	}
+	if m.message != "" {
+		left += "  " + m.message
+	}
 
 	mode := "unified"
 	if m.splitView {
@@ -755,6 +1459,23 @@ func (m Model) renderStatusBar() string {
 
 	right := fmt.Sprintf("+%d -%d  %s", added, deleted, mode)
 
+	if desc := m.filterDescription(); desc != "" {
+		right = fmt.Sprintf("filter:%s (%d)  ", desc, len(m.visibleFiles())) + right
+	}
+	if m.queueMode {
+		right = "queue  " + right
+	} else if m.sortMode != sortDiff {
+		right = fmt.Sprintf("sort:%s  ", m.sortMode) + right
+	}
+
+	if m.searchQuery != "" {
+		if len(m.searchMatches) > 0 {
+			right = fmt.Sprintf("/%s %d/%d  ", m.searchQuery, m.searchIndex+1, len(m.searchMatches)) + right
+		} else {
+			right = fmt.Sprintf("/%s no matches  ", m.searchQuery) + right
+		}
+	}
+
 	if m.analysisResults != nil && len(m.analysisResults.Findings) > 0 {
 		right += fmt.Sprintf("  risk:%s", m.analysisResults.MaxRisk())
 	}
@@ -767,11 +1488,17 @@ func (m Model) renderStatusBar() string {
 		right += "  " + traceInfo
 	}
 
-	approved, rejected, pending := m.DecisionCounts()
-	if approved > 0 || rejected > 0 {
+	approved, rejected, partial, pending := m.DecisionCounts()
+	if partial > 0 {
+		right += fmt.Sprintf("  %dV %dX %d~ %d?", approved, rejected, partial, pending)
+	} else if approved > 0 || rejected > 0 {
 		right += fmt.Sprintf("  %dV %dX %d?", approved, rejected, pending)
 	}
 
+	if len(m.comments) > 0 {
+		right += fmt.Sprintf("  %d comments", len(m.comments))
+	}
+
 	right += "  ? help"
 
 	barGap := m.width - lipgloss.Width(left) - lipgloss.Width(right)
@@ -788,13 +1515,26 @@ func (m Model) renderStatusBar() string {
 	return bar
 }
 
+func (m Model) renderCommentBar() string {
+	loc := m.diffSet.Files[m.fileIndex].Name()
+	if m.commentLine > 0 {
+		loc += fmt.Sprintf(":%d", m.commentLine)
+	}
+	prompt := statusKeyStyle.Render(" Comment " + loc + " ")
+	return lipgloss.NewStyle().
+		Foreground(colorFg).
+		Background(colorBgLight).
+		Width(m.width).
+		Render(prompt + " " + m.commentInput.View())
+}
+
 func (m Model) renderSummary() string {
 	var b strings.Builder
 
 	b.WriteString(summaryHeaderStyle.Render("Review Summary"))
 	b.WriteString("\n\n")
 
-	approved, rejected, pending := m.DecisionCounts()
+	approved, rejected, partial, pending := m.DecisionCounts()
 	total := len(m.diffSet.Files)
 
 	b.WriteString(fmt.Sprintf("  %d file(s) reviewed out of %d\n\n", total-pending, total))
@@ -807,6 +1547,10 @@ func (m Model) renderSummary() string {
 		b.WriteString(summaryRejectedStyle.Render(fmt.Sprintf("  X Rejected: %d", rejected)))
 		b.WriteString("\n")
 	}
+	if partial > 0 {
+		b.WriteString(summaryPendingStyle.Render(fmt.Sprintf("  ~ Partly approved: %d", partial)))
+		b.WriteString("\n")
+	}
 	if pending > 0 {
 		b.WriteString(summaryPendingStyle.Render(fmt.Sprintf("  ? Pending:  %d", pending)))
 		b.WriteString("\n")
@@ -814,26 +1558,108 @@ func (m Model) renderSummary() string {
 
 	b.WriteString("\n")
 
+	_, added, deleted := m.diffSet.Stats()
+	writeGroupStats(&b, "By language", m.diffSet.StatsByLanguage(), added+deleted)
+	writeGroupStats(&b, "By directory", m.diffSet.StatsByDir(), added+deleted)
+
 	// List files by decision
 	for i, f := range m.diffSet.Files {
 		name := f.Name()
-		switch m.decisions[i] {
+		switch m.fileDecision(i) {
 		case model.DecisionApproved:
 			b.WriteString(summaryApprovedStyle.Render(fmt.Sprintf("  V %s", name)))
+			for _, c := range m.decisions.Conditions[i] {
+				b.WriteString("\n")
+				b.WriteString(summaryPendingStyle.Render("      [ ] " + c))
+			}
 		case model.DecisionRejected:
-			b.WriteString(summaryRejectedStyle.Render(fmt.Sprintf("  X %s", name)))
+			line := "  X " + name
+			if note := m.decisions.Note(i, model.WholeFile); note != "" {
+				line += " — " + note
+			}
+			b.WriteString(summaryRejectedStyle.Render(line))
+		case model.DecisionPartial:
+			approvedHunks := 0
+			for h := range f.Fragments {
+				if m.decisions.Hunk(i, h) == model.DecisionApproved {
+					approvedHunks++
+				}
+			}
+			b.WriteString(summaryPendingStyle.Render(fmt.Sprintf("  ~ %s (%d of %d hunks approved)", name, approvedHunks, len(f.Fragments))))
 		default:
 			b.WriteString(summaryPendingStyle.Render(fmt.Sprintf("  ? %s", name)))
 		}
 		b.WriteString("\n")
+		if labels := m.decisions.FileLabels(i); len(labels) > 0 {
+			b.WriteString(summaryPendingStyle.Render("      " + formatLabels(labels)))
+			b.WriteString("\n")
+		}
+		for h := range f.Fragments {
+			if note := m.decisions.Note(i, h); note != "" {
+				b.WriteString(summaryPendingStyle.Render(fmt.Sprintf("      hunk %d %s: %s", h+1, m.decisions.Hunk(i, h), note)))
+				b.WriteString("\n")
+			}
+		}
+	}
+
+	if len(m.comments) > 0 {
+		b.WriteString("\n")
+		b.WriteString(summaryHeaderStyle.Render(fmt.Sprintf("Comments (%d)", len(m.comments))))
+		b.WriteString("\n")
+		for _, c := range m.comments {
+			loc := c.File
+			if c.Line > 0 {
+				loc = fmt.Sprintf("%s:%d", c.File, c.Line)
+			}
+			b.WriteString(fmt.Sprintf("  %s  %s\n", commentStyle.Render(loc), c.Body))
+		}
+	}
+
+	if len(m.checklist) > 0 {
+		b.WriteString("\n")
+		b.WriteString(summaryHeaderStyle.Render(fmt.Sprintf("Checklist (%d of %d done)", len(m.checklist)-model.ChecklistLeft(m.checklist), len(m.checklist))))
+		b.WriteString("\n")
+		for _, c := range m.checklist {
+			if c.Done {
+				b.WriteString(summaryApprovedStyle.Render("  [x] " + c.Text))
+			} else {
+				b.WriteString(summaryPendingStyle.Render("  [ ] " + c.Text))
+			}
+			b.WriteString("\n")
+		}
 	}
 
 	b.WriteString("\n")
+	if m.message != "" {
+		b.WriteString(summaryRejectedStyle.Render("  " + m.message))
+		b.WriteString("\n")
+	}
 	b.WriteString(helpBarStyle.Render("  Press Enter to exit  |  Esc to go back"))
 
 	return b.String()
 }
 
+// maxSummaryGroups caps each stats breakdown in the summary.
+const maxSummaryGroups = 5
+
+// writeGroupStats writes a stats breakdown for the summary, largest groups
+// first, with each one's share of the lines changed.
+func writeGroupStats(b *strings.Builder, title string, groups []diff.GroupStats, total int) {
+	if len(groups) == 0 {
+		return
+	}
+	b.WriteString(summaryHeaderStyle.Render(title))
+	b.WriteString("\n")
+	for i, g := range groups {
+		if i == maxSummaryGroups {
+			b.WriteString(fmt.Sprintf("  … %d more\n", len(groups)-i))
+			break
+		}
+		b.WriteString(fmt.Sprintf("  %3d%%  %-30s +%d -%d\n", g.Percent(total), g.Name, g.Added, g.Deleted))
+	}
+	b.WriteString("\n")
+}
+
 func (m Model) renderHelp() string {
 	var b strings.Builder
 
@@ -844,16 +1670,43 @@ func (m Model) renderHelp() string {
 		{"j/k", "Scroll up/down"},
 		{"n", "Next file"},
 		{"N", "Previous file"},
+		{">/<", "Next/previous commit (range reviews)"},
 		{"]", "Next hunk"},
 		{"[", "Previous hunk"},
 		{"f", "Next finding"},
 		{"F", "Previous finding"},
+		{"!", "Findings panel (enter jumps to finding; a/x/d mark it acknowledged / false positive / fixed)"},
+		{"g", "Change groups by intent (a/x approve/reject a whole group)"},
+		{"L", "Review checklist from .agrev.yml (space checks an item off)"},
 		{"a", "Approve current file"},
-		{"x", "Reject current file"},
-		{"u", "Undo decision"},
-		{"Enter", "Finish review (summary)"},
+		{"C", "Approve current file on condition: type each follow-up and press enter, then enter on an empty line (shown V+)"},
+		{"x", "Reject current file, then say why (enter) or skip (esc)"},
+		{"A/X", "Approve / reject the hunk at the top of the view (the file shows ~ when its hunks differ)"},
+		{"|", "Split the hunk at the top of the view into one per run of changes, to decide them one by one"},
+		{"W", "Approve all undecided files that only change whitespace"},
+		{"u", "Undo last review action (decision or comment)"},
+		{"Ctrl+R", "Redo"},
+		{"c", "Comment on current line"},
+		{"b/B", "Label the file / the hunk at the top of the view (space or comma between labels; an existing one is removed)"},
+		{"/", "Search (n/p next/prev match, esc clears)"},
+		{"ctrl+p", "Find file by name"},
+		{"+/*", "Expand context around hunk by 5/20 lines"},
+		{"w", "Toggle whole-file view"},
+		{"S", "Toggle declaration summary (functions and types changed)"},
+		{"e", "Open file at current line in $EDITOR"},
+		{"y/Y", "Copy hunk / file patch to clipboard"},
+		{"E", "Explain current hunk and its findings (needs explain in .agrev.yml)"},
+		{"z", "Fold/unfold current hunk (decided files and whitespace-only hunks start folded), or expand a lockfile"},
+		{"Z", "Toggle folding of long unchanged runs"},
+		{"1/2/3/4", "Filter: pending / high-risk / findings / new"},
+		{"5", "Cycle the label filter through the labels in use"},
+		{"0", "Clear file filters"},
+		{"s", "Cycle file sort: diff / risk / size / path / findings"},
+		{"Q", "Queue mode: one file at a time, riskiest first"},
+		{"Enter", "Finish review (summary); with trace focused, expand step"},
 		{"v", "Toggle unified/split view"},
 		{"t", "Toggle trace panel"},
+		{"T", "Trace timeline (h/l scrub, enter jumps to file, d step detail)"},
 		{"Tab", "Switch focus (diff/trace)"},
 		{"?", "Toggle this help"},
 		{"q", "Quit"},
@@ -872,9 +1725,90 @@ func (m Model) renderHelp() string {
 	return b.String()
 }
 
-// Run starts the TUI application and returns the review result.
-func Run(ds *diff.DiffSet, t *trace.Trace, ar *analysis.Results) (*ReviewResult, error) {
-	m := New(ds, t, ar)
+// Options configures an interactive review session.
+type Options struct {
+	// RepoDir is the repository root, used to read complete file contents
+	// for context expansion. Empty disables expansion.
+	RepoDir string
+
+	// Queue starts the review in queue mode.
+	Queue bool
+
+	// Label names the change under review in the status bar, e.g. "PR #12".
+	Label string
+
+	// Author is the reviewer's name, recorded on their comments.
+	Author string
+
+	// Commits, when reviewing a range, allows stepping through the range
+	// one commit at a time.
+	Commits []diff.Commit
+
+	// Explain returns an LLM explanation for the E key. Nil leaves
+	// explanations off.
+	Explain func(explain.Request) (string, error)
+
+	// ApproveWhitespace starts the review with files that only change
+	// whitespace approved.
+	ApproveWhitespace bool
+
+	// Semantic starts the review with the declaration summary shown.
+	Semantic bool
+
+	// Owners annotates files with their CODEOWNERS owners. Nil leaves
+	// them unannotated.
+	Owners *owners.File
+
+	// Policy shows which .agrev/policy.yml rules apply to each file, and
+	// starts the review with the files its auto_approve rules cover
+	// approved. Nil has no rules.
+	Policy *policy.Policy
+
+	// Audit logs every decision, note, undo, edit, and comment the reviewer
+	// makes. Nil logs nothing.
+	Audit *audit.Log
+
+	// Triage records the findings the reviewer acknowledges or marks as
+	// false positives or fixed in the findings panel. Nil disables marking
+	// them.
+	Triage *triage.Store
+
+	// Reload enables watch mode. It is polled with the current raw diff and
+	// returns the new diff and its analysis, or a nil DiffSet if unchanged.
+	Reload func(raw string) (*diff.DiffSet, *analysis.Results, error)
+}
+
+// Run starts the TUI application on r and returns the review result. The
+// review resumes from r's decisions and comments, such as ones restored
+// from a saved session, and r is left holding the reviewer's, on the diff
+// as it was at the end in watch mode.
+func Run(r *review.Session, opts Options) (*ReviewResult, error) {
+	m := New(r.Diff, r.Trace, r.Results)
+	m.repoDir = opts.RepoDir
+	m.commits = opts.Commits
+	m.label = opts.Label
+	m.author = opts.Author
+	m.owners = opts.Owners
+	m.policy = opts.Policy
+	m.triage = opts.Triage
+	m.audit = opts.Audit
+	if r.Decisions.Files != nil {
+		m.decisions = r.Decisions.Clone()
+	}
+	m.comments = append(m.comments, r.Comments...)
+	m.checklist = slices.Clone(r.Checklist)
+	if opts.ApproveWhitespace {
+		m.approveWhitespace()
+		m.undoStack = nil // not a reviewer action
+	}
+	m.autoApprove()
+	if opts.Queue {
+		m.toggleQueue()
+	}
+	m.reload = opts.Reload
+	m.explain = opts.Explain
+	m.semantic = opts.Semantic
+	m.updateLines() // binary previews read from the repository
 	p := tea.NewProgram(m, tea.WithAltScreen())
 	finalModel, err := p.Run()
 	if err != nil {
@@ -882,9 +1816,19 @@ func Run(ds *diff.DiffSet, t *trace.Trace, ar *analysis.Results) (*ReviewResult,
 	}
 
 	fm := finalModel.(Model)
+	fm.showCommit(-1) // decisions are reported against the whole range
+	r.Diff, r.Results = fm.diffSet, fm.analysisResults
+	r.Decisions, r.Comments, r.Checklist = fm.decisions, fm.comments, fm.checklist
+	if fm.reload != nil {
+		r.Regroup()
+	}
 	result := &ReviewResult{
 		Decisions: fm.decisions,
-		Files:     ds.Files,
+		Files:     r.Diff.Files,
+		Comments:  fm.comments,
+		Owners:    opts.Owners,
+		Triaged:   fm.triaged,
+		Checklist: fm.checklist,
 	}
 	return result, nil
 }
@@ -1,13 +1,35 @@
 package tui
 
 import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"os/exec"
+	"path/filepath"
 	"strings"
 	"testing"
+	"time"
+	"unicode/utf8"
 
+	"github.com/bluekeyes/go-gitdiff/gitdiff"
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+	"github.com/muesli/termenv"
+	"github.com/aezell/agrev/internal/analysis"
+	"github.com/aezell/agrev/internal/apply"
+	"github.com/aezell/agrev/internal/audit"
+	"github.com/aezell/agrev/internal/config"
 	"github.com/aezell/agrev/internal/diff"
+	"github.com/aezell/agrev/internal/explain"
 	"github.com/aezell/agrev/internal/model"
+	"github.com/aezell/agrev/internal/policy"
 	"github.com/aezell/agrev/internal/trace"
+	"github.com/aezell/agrev/internal/triage"
 )
 
 const testDiff = `diff --git a/main.go b/main.go
@@ -233,7 +255,7 @@ func TestApproveFile(t *testing.T) {
 	newM, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'a'}})
 	m = newM.(Model)
 
-	if m.decisions[0] != model.DecisionApproved {
+	if m.decisions.Files[0] != model.DecisionApproved {
 		t.Error("expected file 0 to be approved")
 	}
 
@@ -250,7 +272,7 @@ func TestRejectFile(t *testing.T) {
 	newM, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'x'}})
 	m = newM.(Model)
 
-	if m.decisions[0] != model.DecisionRejected {
+	if m.decisions.Files[0] != model.DecisionRejected {
 		t.Error("expected file 0 to be rejected")
 	}
 
@@ -275,16 +297,214 @@ func TestUndoDecision(t *testing.T) {
 	newM, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'u'}})
 	m = newM.(Model)
 
-	if _, exists := m.decisions[0]; exists {
+	if _, exists := m.decisions.Files[0]; exists {
 		t.Error("expected decision to be undone")
 	}
 }
 
+const hunksDiff = `diff --git a/list.txt b/list.txt
+--- a/list.txt
++++ b/list.txt
+@@ -1,3 +1,3 @@
+ one
+-two
++TWO
+ three
+@@ -10,3 +10,3 @@
+ ten
+-eleven
++ELEVEN
+ twelve
+`
+
+func TestDecideHunks(t *testing.T) {
+	ds, err := diff.Parse(hunksDiff)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	newM, _ := New(ds, nil, nil).Update(tea.WindowSizeMsg{Width: 120, Height: 40})
+	m := newM.(Model)
+
+	// Approving the first hunk moves to the second
+	newM, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'A'}})
+	m = newM.(Model)
+	if m.decisions.Hunk(0, 0) != model.DecisionApproved || m.fileDecided(0) {
+		t.Fatalf("expected only hunk 1 approved, got %+v", m.decisions)
+	}
+	if m.currentHunk() != 1 {
+		t.Errorf("expected to move to hunk 2, at %d", m.currentHunk())
+	}
+
+	newM, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'X'}})
+	m = newM.(Model)
+	if !m.noting {
+		t.Fatal("expected the rejection to ask for a note")
+	}
+	m.noteInput.SetValue("keep the old spelling")
+	newM, _ = m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	m = newM.(Model)
+	if m.decisions.Note(0, 1) != "keep the old spelling" {
+		t.Errorf("expected the note on hunk 2, got %+v", m.decisions.Notes)
+	}
+	if m.fileDecision(0) != model.DecisionPartial || !m.fileDecided(0) {
+		t.Errorf("expected file partly approved, got %s", m.fileDecision(0))
+	}
+	if approved, rejected, partial, pending := m.DecisionCounts(); approved != 0 || rejected != 0 || partial != 1 || pending != 0 {
+		t.Errorf("unexpected counts %d/%d/%d/%d", approved, rejected, partial, pending)
+	}
+
+	// The patch carries only the approved hunk
+	result := &ReviewResult{Decisions: m.ReviewDecisions(), Files: ds.Files}
+	approved := result.ApprovedFiles()
+	if len(approved) != 1 || len(approved[0].Fragments) != 1 {
+		t.Fatalf("expected one file narrowed to one hunk, got %+v", approved)
+	}
+	patch := result.GeneratePatch()
+	if !strings.Contains(patch, "+TWO") || strings.Contains(patch, "ELEVEN") {
+		t.Errorf("expected only the approved hunk in the patch:\n%s", patch)
+	}
+	if report := result.GenerateReport(); !strings.Contains(report, "partial: 1/2 hunks approved") ||
+		!strings.Contains(report, "- `list.txt (hunk 2)` — keep the old spelling") {
+		t.Errorf("expected the report to tally hunks:\n%s", report)
+	}
+
+	// Undo takes back the hunk decision alone
+	newM, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'u'}})
+	m = newM.(Model)
+	if m.decisions.Hunk(0, 1) != model.DecisionPending || m.decisions.Hunk(0, 0) != model.DecisionApproved || len(m.decisions.Notes) != 0 {
+		t.Errorf("expected the rejection and its note undone, got %+v", m.decisions)
+	}
+
+	// A file decision replaces the hunks'
+	newM, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'x'}})
+	m = newM.(Model)
+	if m.decisions.HunksDecided(0) || m.fileDecision(0) != model.DecisionRejected {
+		t.Errorf("expected the file rejected outright, got %+v", m.decisions)
+	}
+}
+
+func TestSplitHunk(t *testing.T) {
+	ds, err := diff.Parse(`diff --git a/list.txt b/list.txt
+--- a/list.txt
++++ b/list.txt
+@@ -1,5 +1,5 @@
+ one
+-two
++TWO
+ three
+-four
++FOUR
+ five
+@@ -10,3 +10,3 @@
+ ten
+-eleven
++ELEVEN
+ twelve
+`)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	newM, _ := New(ds, nil, nil).Update(tea.WindowSizeMsg{Width: 120, Height: 40})
+	m := newM.(Model)
+	m.decisions.SetHunk(0, 1, model.DecisionRejected)
+	m.comments = []model.Comment{{File: "list.txt", Line: 11, Hunk: 2, Body: "spelling"}}
+
+	newM, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'|'}})
+	m = newM.(Model)
+	if n := len(ds.Files[0].Fragments); n != 3 {
+		t.Fatalf("expected the first hunk split in two, got %d hunks", n)
+	}
+	// The later hunk's decision and comment move up
+	if m.decisions.Hunk(0, 2) != model.DecisionRejected || m.decisions.Hunk(0, 0) != model.DecisionPending || m.decisions.Hunk(0, 1) != model.DecisionPending {
+		t.Errorf("expected the rejection to move to hunk 3, got %+v", m.decisions.Hunks)
+	}
+	if m.comments[0].Hunk != 3 {
+		t.Errorf("expected the comment on hunk 3, got %d", m.comments[0].Hunk)
+	}
+	if m.currentHunk() != 0 {
+		t.Errorf("expected to stay on the first piece, at %d", m.currentHunk())
+	}
+
+	// The pieces are decided one by one
+	newM, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'A'}})
+	m = newM.(Model)
+	if m.decisions.Hunk(0, 0) != model.DecisionApproved || m.currentHunk() != 1 {
+		t.Errorf("expected the first piece approved and the second next, got %+v at %d", m.decisions.Hunks, m.currentHunk())
+	}
+	result := &ReviewResult{Decisions: m.ReviewDecisions(), Files: ds.Files}
+	if patch := result.GeneratePatch(); !strings.Contains(patch, "+TWO") || strings.Contains(patch, "+FOUR") {
+		t.Errorf("expected only the approved piece in the patch:\n%s", patch)
+	}
+
+	// A hunk with one run of changes stays whole
+	newM, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'|'}})
+	m = newM.(Model)
+	if len(ds.Files[0].Fragments) != 3 || m.message != "hunk can't be split" {
+		t.Errorf("expected the second piece left alone, got %d hunks and %q", len(ds.Files[0].Fragments), m.message)
+	}
+}
+
+func TestSplitHunkUndo(t *testing.T) {
+	ds, err := diff.Parse(`diff --git a/list.txt b/list.txt
+--- a/list.txt
++++ b/list.txt
+@@ -1,5 +1,5 @@
+ one
+-two
++TWO
+ three
+-four
++FOUR
+ five
+@@ -10,3 +10,3 @@
+ ten
+-eleven
++ELEVEN
+ twelve
+`)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	newM, _ := New(ds, nil, nil).Update(tea.WindowSizeMsg{Width: 120, Height: 40})
+	m := newM.(Model)
+
+	// Reject the first hunk, skipping the note, approve the second, then
+	// split the first
+	for _, msg := range []tea.KeyMsg{
+		{Type: tea.KeyRunes, Runes: []rune{'X'}},
+		{Type: tea.KeyEsc},
+		{Type: tea.KeyRunes, Runes: []rune{'A'}},
+	} {
+		newM, _ = m.Update(msg)
+		m = newM.(Model)
+	}
+	m.jumpToHunk(0)
+	newM, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'|'}})
+	m = newM.(Model)
+	if len(ds.Files[0].Fragments) != 3 {
+		t.Fatalf("expected the first hunk split in two, got %d hunks", len(ds.Files[0].Fragments))
+	}
+
+	// Undoing the approval keeps the rejection on both pieces
+	newM, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'u'}})
+	m = newM.(Model)
+	if m.decisions.Hunk(0, 0) != model.DecisionRejected || m.decisions.Hunk(0, 1) != model.DecisionRejected || m.decisions.Hunk(0, 2) != model.DecisionPending {
+		t.Errorf("expected the pieces rejected and the last hunk pending, got %+v", m.decisions.Hunks)
+	}
+
+	// Redo brings the approval back to the hunk's new index
+	newM, _ = m.Update(tea.KeyMsg{Type: tea.KeyCtrlR})
+	m = newM.(Model)
+	if m.decisions.Hunk(0, 0) != model.DecisionRejected || m.decisions.Hunk(0, 1) != model.DecisionRejected || m.decisions.Hunk(0, 2) != model.DecisionApproved {
+		t.Errorf("expected the approval redone on hunk 3, got %+v", m.decisions.Hunks)
+	}
+}
+
 func TestDecisionCounts(t *testing.T) {
 	m := setupModel(t)
 
 	// Initially all pending
-	approved, rejected, pending := m.DecisionCounts()
+	approved, rejected, _, pending := m.DecisionCounts()
 	if approved != 0 || rejected != 0 || pending != 2 {
 		t.Errorf("expected 0/0/2, got %d/%d/%d", approved, rejected, pending)
 	}
@@ -297,7 +517,7 @@ func TestDecisionCounts(t *testing.T) {
 	newM, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'x'}})
 	m = newM.(Model)
 
-	approved, rejected, pending = m.DecisionCounts()
+	approved, rejected, _, pending = m.DecisionCounts()
 	if approved != 1 || rejected != 1 || pending != 0 {
 		t.Errorf("expected 1/1/0, got %d/%d/%d", approved, rejected, pending)
 	}
@@ -343,10 +563,10 @@ func TestReviewResult(t *testing.T) {
 	}
 
 	result := &ReviewResult{
-		Decisions: map[int]model.ReviewDecision{
+		Decisions: model.Decisions{Files: map[int]model.ReviewDecision{
 			0: model.DecisionApproved,
 			1: model.DecisionRejected,
-		},
+		}},
 		Files: ds.Files,
 	}
 
@@ -368,10 +588,10 @@ func TestGeneratePatch(t *testing.T) {
 	}
 
 	result := &ReviewResult{
-		Decisions: map[int]model.ReviewDecision{
+		Decisions: model.Decisions{Files: map[int]model.ReviewDecision{
 			0: model.DecisionApproved,
 			1: model.DecisionRejected,
-		},
+		}},
 		Files: ds.Files,
 	}
 
@@ -391,6 +611,218 @@ func TestGeneratePatch(t *testing.T) {
 	}
 }
 
+func TestForRepo(t *testing.T) {
+	api, _ := diff.Parse(testDiff)
+	web, _ := diff.Parse(testDiff)
+	repos := []diff.Repo{{Name: "api"}, {Name: "web"}}
+	ds := diff.Combine(repos, []*diff.DiffSet{api, web})
+
+	d := model.NewDecisions()
+	d.SetFile(0, model.DecisionRejected)
+	d.SetFile(2, model.DecisionApproved)
+	d.AddCondition(2, "add a test")
+	result := &ReviewResult{
+		Decisions: d,
+		Files:     ds.Files,
+		Comments:  []model.Comment{{File: "api/main.go", Body: "why?"}, {File: "web/main.go", Line: 4, Body: "nice"}},
+	}
+
+	r := result.ForRepo(repos[1])
+	if len(r.Files) != 2 || r.Decision(0) != model.DecisionApproved || len(r.Conditions()) != 1 {
+		t.Fatalf("expected web's files with their decisions, got %d files, %+v", len(r.Files), r.Decisions)
+	}
+	if len(r.Comments) != 1 || r.Comments[0].File != "main.go" {
+		t.Errorf("expected web's comment named as in the repository, got %+v", r.Comments)
+	}
+	patch := r.GeneratePatch()
+	if !strings.Contains(patch, "diff --git a/main.go b/main.go") || strings.Contains(patch, "web/") {
+		t.Errorf("expected a patch that applies in the repository, got:\n%s", patch)
+	}
+	if result.ForRepo(repos[0]).GeneratePatch() != "" {
+		t.Error("expected no patch for a repository with nothing approved")
+	}
+	if ds.Files[2].Name() != "web/main.go" {
+		t.Errorf("expected the combined diff left as it was, got %s", ds.Files[2].Name())
+	}
+}
+
+func TestApproveWithConditions(t *testing.T) {
+	m := setupModel(t)
+	name := m.diffSet.Files[0].Name()
+
+	newM, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'C'}})
+	m = newM.(Model)
+	if !m.noting || !m.noteConds || m.fileDecision(0) != model.DecisionApproved {
+		t.Fatalf("expected the file approved and the follow-up prompt open, got %+v", m.decisions)
+	}
+	for _, c := range []string{"add tests for the greeting", "log the change", "  "} {
+		m.noteInput.SetValue(c)
+		newM, _ = m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+		m = newM.(Model)
+	}
+	if m.noting {
+		t.Error("expected an empty line to close the prompt")
+	}
+	if got := m.decisions.Conditions[0]; len(got) != 2 || got[1] != "log the change" {
+		t.Errorf("expected two follow-ups, got %q", got)
+	}
+	if summary := m.renderSummary(); !strings.Contains(summary, "[ ] add tests for the greeting") {
+		t.Errorf("expected the follow-up in the summary:\n%s", summary)
+	}
+
+	result := &ReviewResult{Decisions: m.ReviewDecisions(), Files: m.diffSet.Files}
+	if msg := result.GenerateCommitMessage(); !strings.Contains(msg, "Follow-ups:\n  - [ ] "+name+": add tests for the greeting") {
+		t.Errorf("expected the follow-up as a checkbox in the commit message:\n%s", msg)
+	}
+	report := result.GenerateReport()
+	if !strings.Contains(report, "| approved with follow-ups | `"+name+"`") ||
+		!strings.Contains(report, "- [ ] `"+name+"` — add tests for the greeting") {
+		t.Errorf("expected the follow-up in the report:\n%s", report)
+	}
+
+	// The follow-ups are undone with the approval
+	newM, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'u'}})
+	m = newM.(Model)
+	if m.fileDecision(0) != model.DecisionPending || len(m.decisions.Conditions[0]) != 0 {
+		t.Errorf("expected the approval and its follow-ups undone, got %+v", m.decisions)
+	}
+}
+
+func TestLabels(t *testing.T) {
+	m := setupModel(t)
+	name := m.diffSet.Files[0].Name()
+
+	press := func(msg tea.KeyMsg) {
+		t.Helper()
+		newM, _ := m.Update(msg)
+		m = newM.(Model)
+	}
+	press(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'b'}})
+	if !m.labeling {
+		t.Fatal("expected the label prompt open")
+	}
+	m.labelInput.SetValue("#needs-security-review, docs")
+	press(tea.KeyMsg{Type: tea.KeyEnter})
+	if got := m.decisions.Label(0, model.WholeFile); strings.Join(got, ",") != "docs,needs-security-review" {
+		t.Errorf("expected two labels on the file, got %q", got)
+	}
+
+	// An existing label is removed
+	press(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'b'}})
+	m.labelInput.SetValue("docs")
+	press(tea.KeyMsg{Type: tea.KeyEnter})
+	if got := m.decisions.Label(0, model.WholeFile); len(got) != 1 {
+		t.Errorf("expected docs removed, got %q", got)
+	}
+
+	// Filtering by the label hides util.go
+	press(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'5'}})
+	if got := m.visibleFiles(); m.labelFilter != "needs-security-review" || len(got) != 1 || got[0] != 0 {
+		t.Errorf("expected only %s shown, got %v", name, got)
+	}
+	if !strings.Contains(m.renderStatusBar(), "#needs-security-review") {
+		t.Error("expected the label filter in the status bar")
+	}
+	press(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'5'}})
+	if m.labelFilter != "" || len(m.visibleFiles()) != 2 {
+		t.Errorf("expected the filter cleared after the last label, got %q", m.labelFilter)
+	}
+
+	result := &ReviewResult{Decisions: m.ReviewDecisions(), Files: m.diffSet.Files}
+	if report := result.GenerateReport(); !strings.Contains(report, "### Labels\n\n- `"+name+"` — #needs-security-review") {
+		t.Errorf("expected the label in the report:\n%s", report)
+	}
+
+	press(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'u'}})
+	press(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'u'}})
+	if got := m.decisions.Label(0, model.WholeFile); len(got) != 0 {
+		t.Errorf("expected the labels undone, got %q", got)
+	}
+}
+
+func TestAuditLog(t *testing.T) {
+	dir := t.TempDir()
+	m := setupModel(t)
+	m.audit = audit.New(dir, "tui")
+	m.audit.Reviewer = "Ada <ada@example.com>"
+	name := m.diffSet.Files[0].Name()
+
+	press := func(msg tea.KeyMsg) {
+		t.Helper()
+		newM, _ := m.Update(msg)
+		m = newM.(Model)
+	}
+	press(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'x'}})
+	m.noteInput.SetValue("keep the old greeting")
+	press(tea.KeyMsg{Type: tea.KeyEnter})
+	press(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'u'}})
+
+	events, err := audit.Load(dir)
+	if err != nil || len(events) != 3 {
+		t.Fatalf("expected a rejection, its note, and the undo, got %+v, %v", events, err)
+	}
+	if e := events[0]; e.Action != audit.ActionReject || e.File != name || e.Hunk != 0 || e.Reviewer != "Ada <ada@example.com>" || e.Source != "tui" {
+		t.Errorf("unexpected rejection %+v", e)
+	}
+	if e := events[1]; e.Action != audit.ActionNote || e.Detail != "keep the old greeting" {
+		t.Errorf("unexpected note %+v", e)
+	}
+	if e := events[2]; e.Action != audit.ActionUndo || e.Detail != "reject "+name {
+		t.Errorf("unexpected undo %+v", e)
+	}
+}
+
+func TestChecklist(t *testing.T) {
+	m := setupModel(t)
+	m.policy = &policy.Policy{RequireChecklist: true}
+	m.checklist = []model.ChecklistItem{{Text: "ran it locally"}, {Text: "confirm the backfill plan"}}
+
+	newM, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'L'}})
+	m = newM.(Model)
+	if !m.showChecklist {
+		t.Fatal("expected the checklist panel open")
+	}
+	newM, _ = m.Update(tea.KeyMsg{Type: tea.KeySpace})
+	m = newM.(Model)
+	if !m.checklist[0].Done || m.checklist[1].Done {
+		t.Errorf("expected the first item checked off, got %+v", m.checklist)
+	}
+	newM, _ = m.Update(tea.KeyMsg{Type: tea.KeyEsc})
+	m = newM.(Model)
+
+	// Finishing waits for the rest of the checklist
+	newM, _ = m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	m = newM.(Model)
+	_, cmd := m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	if cmd != nil {
+		t.Fatal("expected the review not to finish with an item left")
+	}
+	newM, _ = m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	m = newM.(Model)
+	if summary := m.renderSummary(); !strings.Contains(summary, "[ ] confirm the backfill plan") || !strings.Contains(summary, "1 item(s) left") {
+		t.Errorf("expected the open item and why the review can't finish in the summary:\n%s", summary)
+	}
+
+	newM, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'L'}})
+	m = newM.(Model)
+	newM, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'j'}})
+	m = newM.(Model)
+	newM, _ = m.Update(tea.KeyMsg{Type: tea.KeySpace})
+	m = newM.(Model)
+	newM, _ = m.Update(tea.KeyMsg{Type: tea.KeyEsc})
+	m = newM.(Model)
+	newM, _ = m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	m = newM.(Model)
+	if _, cmd := m.Update(tea.KeyMsg{Type: tea.KeyEnter}); cmd == nil {
+		t.Error("expected the review to finish with the checklist done")
+	}
+
+	result := &ReviewResult{Files: m.diffSet.Files, Checklist: m.checklist}
+	if report := result.GenerateReport(); !strings.Contains(report, "### Checklist\n\n- [x] ran it locally\n- [x] confirm the backfill plan") {
+		t.Errorf("expected the checklist in the report:\n%s", report)
+	}
+}
+
 func TestGenerateCommitMessage(t *testing.T) {
 	ds, err := diff.Parse(testDiff)
 	if err != nil {
@@ -398,10 +830,10 @@ func TestGenerateCommitMessage(t *testing.T) {
 	}
 
 	result := &ReviewResult{
-		Decisions: map[int]model.ReviewDecision{
+		Decisions: model.Decisions{Files: map[int]model.ReviewDecision{
 			0: model.DecisionApproved,
 			1: model.DecisionRejected,
-		},
+		}},
 		Files: ds.Files,
 	}
 
@@ -446,3 +878,1819 @@ func TestStatusBarShowsReviewProgress(t *testing.T) {
 		t.Error("expected status bar to show approved count")
 	}
 }
+
+func TestCommentOnLine(t *testing.T) {
+	m := setupModel(t)
+
+	// Scroll past the hunk header onto the first diff line
+	newM, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'j'}})
+	m = newM.(Model)
+
+	newM, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'c'}})
+	m = newM.(Model)
+	if !m.commenting {
+		t.Fatal("expected comment composer to open")
+	}
+
+	// Keys go to the composer, not the navigation bindings
+	newM, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("needs a test")})
+	m = newM.(Model)
+	if m.fileIndex != 0 {
+		t.Errorf("expected typing to not navigate, got fileIndex %d", m.fileIndex)
+	}
+
+	newM, _ = m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	m = newM.(Model)
+
+	if m.commenting {
+		t.Error("expected composer to close after enter")
+	}
+	if len(m.comments) != 1 {
+		t.Fatalf("expected 1 comment, got %d", len(m.comments))
+	}
+	c := m.comments[0]
+	if c.File != "main.go" || c.Line != 1 || c.Body != "needs a test" {
+		t.Errorf("unexpected comment: %+v", c)
+	}
+
+	found := false
+	for _, rl := range m.lines {
+		if rl.IsComment && strings.Contains(rl.Content, "needs a test") {
+			found = true
+		}
+	}
+	if !found {
+		t.Error("expected comment to be rendered inline")
+	}
+}
+
+func TestCommentEscCancels(t *testing.T) {
+	m := setupModel(t)
+
+	newM, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'c'}})
+	m = newM.(Model)
+	newM, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("draft")})
+	m = newM.(Model)
+	newM, _ = m.Update(tea.KeyMsg{Type: tea.KeyEsc})
+	m = newM.(Model)
+
+	if m.commenting {
+		t.Error("expected composer to close on esc")
+	}
+	if len(m.comments) != 0 {
+		t.Errorf("expected no comments after cancel, got %d", len(m.comments))
+	}
+}
+
+func TestReviewResultComments(t *testing.T) {
+	ds, err := diff.Parse(testDiff)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	result := &ReviewResult{
+		Decisions: model.Decisions{Files: map[int]model.ReviewDecision{0: model.DecisionApproved}},
+		Files:     ds.Files,
+		Comments:  []model.Comment{{File: "main.go", Line: 4, Body: "prefer fmt.Println"}},
+	}
+
+	patch := result.GeneratePatch()
+	if !strings.HasPrefix(patch, "Review comments:") {
+		t.Errorf("expected patch to start with comment header, got %q", patch[:40])
+	}
+	if !strings.Contains(patch, "main.go:4: prefer fmt.Println") {
+		t.Error("expected patch header to contain comment")
+	}
+
+	// The header must not break re-parsing of the patch
+	reparsed, err := diff.Parse(patch)
+	if err != nil {
+		t.Fatalf("re-parsing patch: %v", err)
+	}
+	if len(reparsed.Files) != 1 {
+		t.Errorf("expected 1 file in patch, got %d", len(reparsed.Files))
+	}
+
+	report := result.GenerateReport()
+	if !strings.Contains(report, "### Comments") || !strings.Contains(report, "`main.go:4` — prefer fmt.Println") {
+		t.Errorf("expected report to include comments, got:\n%s", report)
+	}
+
+	result.Comments[0].Hunk = 1
+	result.Comments[0].Author = "alice"
+	result.Comments[0].Time = time.Date(2026, 1, 15, 10, 4, 0, 0, time.UTC)
+	report = result.GenerateReport()
+	if !strings.Contains(report, "prefer fmt.Println _(hunk 1, alice, 2026-01-15 10:04)_") {
+		t.Errorf("expected the comment's hunk, author and time in the report, got:\n%s", report)
+	}
+	if msg := result.GenerateCommitMessage(); !strings.Contains(msg, "Review comments:\n  - main.go:4: prefer fmt.Println — alice") {
+		t.Errorf("expected the commit message to list comments, got:\n%s", msg)
+	}
+}
+
+func TestSearchAcrossFiles(t *testing.T) {
+	m := setupModel(t)
+
+	newM, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'/'}})
+	m = newM.(Model)
+	if !m.searching {
+		t.Fatal("expected search input to open")
+	}
+
+	// "return" only appears in util.go, so incremental search jumps there
+	newM, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("return")})
+	m = newM.(Model)
+	if len(m.searchMatches) != 1 {
+		t.Fatalf("expected 1 match, got %d", len(m.searchMatches))
+	}
+	if m.fileIndex != 1 {
+		t.Errorf("expected search to jump to file 1, got %d", m.fileIndex)
+	}
+	if !strings.Contains(m.lines[m.scrollOffset].Content, "return") {
+		t.Errorf("expected view at matching line, got %q", m.lines[m.scrollOffset].Content)
+	}
+
+	newM, _ = m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	m = newM.(Model)
+	if m.searching || m.searchQuery != "return" {
+		t.Error("expected enter to close input and keep the query active")
+	}
+}
+
+func TestSearchNextPrevMatch(t *testing.T) {
+	m := setupModel(t)
+
+	newM, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'/'}})
+	m = newM.(Model)
+	newM, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("println")})
+	m = newM.(Model)
+	newM, _ = m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	m = newM.(Model)
+
+	if len(m.searchMatches) != 3 {
+		t.Fatalf("expected 3 matches, got %d", len(m.searchMatches))
+	}
+	if m.searchIndex != 0 {
+		t.Errorf("expected first match selected, got %d", m.searchIndex)
+	}
+
+	// n steps through matches instead of switching files
+	newM, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'n'}})
+	m = newM.(Model)
+	if m.searchIndex != 1 || m.fileIndex != 0 {
+		t.Errorf("expected match 1 in file 0, got match %d in file %d", m.searchIndex, m.fileIndex)
+	}
+
+	// p wraps backwards
+	newM, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'p'}})
+	m = newM.(Model)
+	newM, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'p'}})
+	m = newM.(Model)
+	if m.searchIndex != 2 {
+		t.Errorf("expected wrap to last match, got %d", m.searchIndex)
+	}
+
+	view := m.View()
+	if !strings.Contains(view, "/println 3/3") {
+		t.Error("expected status bar to show search position")
+	}
+
+	// esc clears the search and n goes back to next-file
+	newM, _ = m.Update(tea.KeyMsg{Type: tea.KeyEsc})
+	m = newM.(Model)
+	if m.searchQuery != "" {
+		t.Error("expected esc to clear search")
+	}
+	newM, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'n'}})
+	m = newM.(Model)
+	if m.fileIndex != 1 {
+		t.Errorf("expected n to move to next file after clearing search, got %d", m.fileIndex)
+	}
+}
+
+func TestHighlightMatches(t *testing.T) {
+	out := highlightMatches("hello world", "world", contextLineStyle)
+	if !strings.Contains(out, "world") || !strings.Contains(out, "hello") {
+		t.Errorf("expected all text preserved, got %q", out)
+	}
+	if containsQuery("Hello", "hello") != true {
+		t.Error("expected lowercase query to match case-insensitively")
+	}
+	if containsQuery("hello", "Hello") {
+		t.Error("expected mixed-case query to match case-sensitively")
+	}
+}
+
+func TestFuzzyScore(t *testing.T) {
+	if _, ok := fuzzyScore("internal/tui/render.go", "xyz"); ok {
+		t.Error("expected no match for absent characters")
+	}
+	if _, ok := fuzzyScore("internal/tui/render.go", "itr"); !ok {
+		t.Error("expected subsequence match")
+	}
+
+	// A basename hit should outrank a scattered match
+	base, _ := fuzzyScore("internal/api/ws.go", "ws")
+	scattered, _ := fuzzyScore("cmd/wiki/settings.go", "ws")
+	if base <= scattered {
+		t.Errorf("expected basename match to score higher: %d <= %d", base, scattered)
+	}
+}
+
+func TestFileFinderJumps(t *testing.T) {
+	m := setupModel(t)
+
+	newM, _ := m.Update(tea.KeyMsg{Type: tea.KeyCtrlP})
+	m = newM.(Model)
+	if !m.showFinder {
+		t.Fatal("expected finder to open on ctrl+p")
+	}
+	if len(m.finderResults) != 2 {
+		t.Errorf("expected all files listed with empty query, got %d", len(m.finderResults))
+	}
+
+	view := m.View()
+	if !strings.Contains(view, "Find file") {
+		t.Error("expected finder overlay to render")
+	}
+
+	newM, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("util")})
+	m = newM.(Model)
+	if len(m.finderResults) != 1 {
+		t.Fatalf("expected 1 result for 'util', got %d", len(m.finderResults))
+	}
+
+	newM, _ = m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	m = newM.(Model)
+	if m.showFinder {
+		t.Error("expected finder to close on enter")
+	}
+	if m.fileIndex != 1 {
+		t.Errorf("expected jump to util.go (1), got %d", m.fileIndex)
+	}
+}
+
+func TestFileFilters(t *testing.T) {
+	m := setupModel(t)
+
+	// Only new files: main.go is hidden, so selection moves to util.go
+	newM, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'4'}})
+	m = newM.(Model)
+	if m.fileIndex != 1 {
+		t.Errorf("expected selection to move to util.go, got %d", m.fileIndex)
+	}
+	if !strings.Contains(m.renderStatusBar(), "filter:new") {
+		t.Error("expected active filter in status bar")
+	}
+
+	// Navigation skips hidden files
+	newM, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'N'}})
+	m = newM.(Model)
+	if m.fileIndex != 1 {
+		t.Errorf("expected prev file to skip filtered main.go, got %d", m.fileIndex)
+	}
+
+	// Clear and filter to pending; approving a file drops it from the list
+	newM, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'0'}})
+	m = newM.(Model)
+	m.selectFile(0)
+	newM, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'1'}})
+	m = newM.(Model)
+	newM, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'a'}})
+	m = newM.(Model)
+	if got := m.visibleFiles(); len(got) != 1 || got[0] != 1 {
+		t.Errorf("expected only util.go pending, got %v", got)
+	}
+	if m.fileIndex != 1 {
+		t.Errorf("expected advance to util.go, got %d", m.fileIndex)
+	}
+
+	// Approving the last pending file leaves nothing selected
+	newM, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'a'}})
+	m = newM.(Model)
+	if got := m.visibleFiles(); len(got) != 0 {
+		t.Fatalf("expected no pending files, got %v", got)
+	}
+	if view := m.View(); !strings.Contains(view, "No files match the filter") || strings.Contains(view, "util.go") {
+		t.Errorf("expected an empty diff pane, got:\n%s", view)
+	}
+	newM, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'x'}})
+	m = newM.(Model)
+	if d := m.fileDecision(1); d != model.DecisionApproved {
+		t.Errorf("expected reject to leave the hidden file alone, got %v", d)
+	}
+
+	// Clearing the filter shows the file again
+	newM, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'0'}})
+	m = newM.(Model)
+	if view := m.View(); strings.Contains(view, "No files match the filter") || !strings.Contains(view, "util.go") {
+		t.Errorf("expected the diff back after clearing the filter, got:\n%s", view)
+	}
+}
+
+func TestFileFilterFindings(t *testing.T) {
+	ds, err := diff.Parse(testDiff)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	ar := &analysis.Results{Findings: []analysis.Finding{
+		{Pass: "security", File: "util.go", Line: 3, Message: "risky", Risk: model.RiskHigh},
+	}}
+	m := New(ds, nil, ar)
+
+	m.toggleFilter(filterHighRisk)
+	if got := m.visibleFiles(); len(got) != 1 || got[0] != 1 {
+		t.Errorf("expected only util.go to be high-risk, got %v", got)
+	}
+
+	m.filter = filterFindings | filterNew
+	if got := m.visibleFiles(); len(got) != 1 {
+		t.Errorf("expected filters to combine, got %v", got)
+	}
+}
+
+func TestIntralineMarking(t *testing.T) {
+	ds, err := diff.Parse(testDiff)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	lines := renderFile(ds.Files[0])
+
+	var del, add *renderedLine
+	for i := range lines {
+		switch lines[i].Op {
+		case gitdiff.OpDelete:
+			del = &lines[i]
+		case gitdiff.OpAdd:
+			if add == nil {
+				add = &lines[i]
+			}
+		}
+	}
+	if del == nil || add == nil {
+		t.Fatal("expected paired delete/add lines")
+	}
+	if len(add.Changed) != 1 {
+		t.Fatalf("expected one changed span on the added line, got %v", add.Changed)
+	}
+	if got := add.Content[add.Changed[0].Start:add.Changed[0].End]; got != " world" {
+		t.Errorf("expected ' world' emphasized, got %q", got)
+	}
+
+	// The unpaired second added line has nothing to compare against
+	for _, rl := range lines {
+		if rl.Op == gitdiff.OpAdd && strings.Contains(rl.Content, "goodbye") && rl.Changed != nil {
+			t.Error("expected no intraline changes on unpaired line")
+		}
+	}
+
+	// Rendering keeps the text intact and respects truncation
+	if got := renderCode("+", *add, 0); !strings.Contains(got, "world") {
+		t.Errorf("expected rendered line to contain full text, got %q", got)
+	}
+	if got := renderCode("+", *add, 10); strings.Contains(got, "world") || !strings.HasSuffix(got, "…") {
+		t.Errorf("expected truncated line, got %q", got)
+	}
+}
+
+func TestRenderCodeTruncatesByWidth(t *testing.T) {
+	// Wide and multi-byte characters, with a changed span past the cut
+	rl := renderedLine{
+		Op:      gitdiff.OpAdd,
+		NewNum:  1,
+		Content: "名前 := \"héllo wörld\"",
+		Changed: []diff.Span{{Start: 0, End: 6}, {Start: 15, End: 24}},
+	}
+	got := renderCode("+", rl, 8)
+	if !utf8.ValidString(got) {
+		t.Fatalf("expected valid UTF-8, got %q", got)
+	}
+	if w := ansi.StringWidth(got); w > 8 {
+		t.Errorf("expected at most 8 columns, got %d in %q", w, got)
+	}
+	if plain := ansi.Strip(got); plain != "+名前 :…" {
+		t.Errorf("expected the line cut at a character boundary, got %q", plain)
+	}
+}
+
+func TestSyntaxColorsOnChangedLines(t *testing.T) {
+	lipgloss.SetColorProfile(termenv.TrueColor)
+	defer lipgloss.SetColorProfile(termenv.Ascii)
+
+	rl := renderedLine{
+		Op:      gitdiff.OpAdd,
+		NewNum:  1,
+		Content: "return x",
+		Tokens: []diff.Token{
+			{Text: "return", Color: "#ff79c6"},
+			{Text: " x"},
+		},
+	}
+	got := renderCode("+", rl, 0)
+	if !strings.Contains(got, "38;2;255;121;198") {
+		t.Errorf("expected keyword color on added line, got %q", got)
+	}
+	if !strings.Contains(got, "48;2;") {
+		t.Errorf("expected tinted background on added line, got %q", got)
+	}
+}
+
+func TestLazyHighlighting(t *testing.T) {
+	// A long generated file: only the lines near the viewport are highlighted
+	var b strings.Builder
+	b.WriteString("diff --git a/gen.go b/gen.go\nnew file mode 100644\n--- /dev/null\n+++ b/gen.go\n@@ -0,0 +1,5000 @@\n")
+	for i := range 5000 {
+		fmt.Fprintf(&b, "+var v%d = \"value\" // generated\n", i)
+	}
+	ds, err := diff.Parse(b.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := New(ds, nil, nil)
+	newM, _ := m.Update(tea.WindowSizeMsg{Width: 120, Height: 40})
+	m = newM.(Model)
+
+	f := ds.Files[0]
+	if n := len(m.highlights[f]); n == 0 || n > m.viewHeight+highlightMargin {
+		t.Fatalf("expected only the lines near the top highlighted, got %d", n)
+	}
+	first := m.lines[1] // after the hunk header
+	if len(first.Tokens) < 2 || first.Tokens[0].Text != "var" {
+		t.Errorf("expected the first line's tokens, got %+v", first.Tokens)
+	}
+	if last := m.lines[len(m.lines)-1]; last.Tokens != nil {
+		t.Errorf("expected the last line left for later, got %+v", last.Tokens)
+	}
+
+	m.scrollOffset = len(m.lines) - m.viewHeight
+	newM, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'j'}})
+	m = newM.(Model)
+	if last := m.lines[len(m.lines)-1]; len(last.Tokens) < 2 || (diff.HighlightedLine{Tokens: last.Tokens}).Plain() != last.Content {
+		t.Errorf("expected the last line highlighted once in view, got %+v", last.Tokens)
+	}
+	if n := len(m.highlights[f]); n > 2*(m.viewHeight+2*highlightMargin) {
+		t.Errorf("expected the lines in between skipped, got %d highlighted", n)
+	}
+
+	// Rendering the file again reuses the cached tokens
+	m.updateLines()
+	m.highlightVisible()
+	if last := m.lines[len(m.lines)-1]; last.Tokens == nil {
+		t.Error("expected the cached tokens after re-rendering")
+	}
+}
+
+func TestResolveTheme(t *testing.T) {
+	th, err := resolveTheme("", nil)
+	if err != nil || th.Chroma != "dracula" {
+		t.Errorf("expected default dark theme, got %+v (%v)", th, err)
+	}
+
+	if _, err := resolveTheme("nope", nil); err == nil {
+		t.Error("expected error for unknown theme")
+	}
+
+	custom := map[string]config.ThemeConfig{
+		"mine": {Base: "light", Chroma: "monokai", Colors: map[string]string{"red": "#123456"}},
+		"bad":  {Colors: map[string]string{"red": "crimson"}},
+	}
+	th, err = resolveTheme("mine", custom)
+	if err != nil {
+		t.Fatalf("resolveTheme failed: %v", err)
+	}
+	if th.Red != "#123456" || th.Chroma != "monokai" || th.Bg != themes["light"].Bg {
+		t.Errorf("expected overrides on light base, got %+v", th)
+	}
+	if _, err := resolveTheme("bad", custom); err == nil {
+		t.Error("expected error for invalid color")
+	}
+}
+
+func TestSetTheme(t *testing.T) {
+	defer SetTheme("dark", nil)
+
+	if err := SetTheme("light", nil); err != nil {
+		t.Fatalf("SetTheme failed: %v", err)
+	}
+	if colorFg != themes["light"].Fg {
+		t.Errorf("expected light foreground, got %s", colorFg)
+	}
+	if findingLowBright != mustRGB(themes["light"].FindingLow[1]) {
+		t.Error("expected finding pulse colors to follow the theme")
+	}
+}
+
+// expandDiff changes lines 10 and 30 of a 40-line file.
+const expandDiff = `diff --git a/long.txt b/long.txt
+--- a/long.txt
++++ b/long.txt
+@@ -9,3 +9,3 @@
+ line 9
+-line 10
++line ten
+ line 11
+@@ -29,3 +29,3 @@
+ line 29
+-line 30
++line thirty
+ line 31
+`
+
+func setupExpandModel(t *testing.T) Model {
+	t.Helper()
+	dir := t.TempDir()
+	var content strings.Builder
+	for i := 1; i <= 40; i++ {
+		switch i {
+		case 10:
+			content.WriteString("line ten\n")
+		case 30:
+			content.WriteString("line thirty\n")
+		default:
+			fmt.Fprintf(&content, "line %d\n", i)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(dir, "long.txt"), []byte(content.String()), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	ds, err := diff.Parse(expandDiff)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	m := New(ds, nil, nil)
+	m.repoDir = dir
+	m.updateLines()
+	newM, _ := m.Update(tea.WindowSizeMsg{Width: 120, Height: 40})
+	return newM.(Model)
+}
+
+func codeLineNums(lines []renderedLine) []int {
+	var nums []int
+	for _, rl := range lines {
+		if !rl.IsHunk && rl.NewNum > 0 {
+			nums = append(nums, rl.NewNum)
+		}
+	}
+	return nums
+}
+
+func TestExpandContext(t *testing.T) {
+	m := setupExpandModel(t)
+	before := len(codeLineNums(m.lines))
+
+	newM, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'+'}})
+	m = newM.(Model)
+
+	nums := codeLineNums(m.lines)
+	if len(nums) != before+10 {
+		t.Fatalf("expected 10 more lines around the first hunk, got %d -> %d", before, len(nums))
+	}
+	if nums[0] != 4 {
+		t.Errorf("expected expansion to start at line 4, got %d", nums[0])
+	}
+	for _, rl := range m.lines {
+		if rl.NewNum == 4 && (rl.OldNum != 4 || rl.Content != "line 4") {
+			t.Errorf("unexpected expanded line %+v", rl)
+		}
+	}
+
+	// Expanding by 20 more must not run into the second hunk
+	newM, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'*'}})
+	m = newM.(Model)
+	seen := make(map[int]bool)
+	for _, n := range codeLineNums(m.lines) {
+		if seen[n] {
+			t.Fatalf("line %d shown twice", n)
+		}
+		seen[n] = true
+	}
+}
+
+func TestWholeFileView(t *testing.T) {
+	m := setupExpandModel(t)
+
+	newM, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'w'}})
+	m = newM.(Model)
+
+	nums := codeLineNums(m.lines)
+	if len(nums) != 40 {
+		t.Fatalf("expected all 40 lines in whole-file view, got %d", len(nums))
+	}
+	for i, n := range nums {
+		if n != i+1 {
+			t.Fatalf("expected lines in order, got %d at %d", n, i)
+		}
+	}
+	if !strings.Contains(m.renderStatusBar(), "whole file") {
+		t.Error("expected whole-file indicator in status bar")
+	}
+
+	newM, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'w'}})
+	m = newM.(Model)
+	if len(codeLineNums(m.lines)) != 6 {
+		t.Errorf("expected toggle back to hunks, got %d lines", len(codeLineNums(m.lines)))
+	}
+}
+
+func TestSemanticSummary(t *testing.T) {
+	m := setupModel(t)
+	press := func(r rune) {
+		newM, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{r}})
+		m = newM.(Model)
+	}
+	summary := func() []string {
+		var out []string
+		for _, rl := range m.lines {
+			if rl.IsSemantic {
+				out = append(out, strings.TrimSpace(rl.Content))
+			}
+		}
+		return out
+	}
+
+	press('S')
+	if !strings.Contains(m.renderStatusBar(), "[declarations]") {
+		t.Error("expected declarations indicator in status bar")
+	}
+	// main.go's old version is only in a repository
+	if got := summary(); len(got) != 1 || !strings.Contains(got[0], "no declaration summary") || !m.lines[0].IsSemantic {
+		t.Errorf("summary without a repository = %q", got)
+	}
+
+	// A new file's hunk has all of it
+	press('n')
+	if got := summary(); fmt.Sprint(got) != "[≡ 1 declaration(s) changed + function add added]" {
+		t.Errorf("summary of util.go = %q", got)
+	}
+
+	press('S')
+	if got := summary(); len(got) != 0 {
+		t.Errorf("summary still shown after toggling off: %q", got)
+	}
+}
+
+func TestExpandWithoutRepo(t *testing.T) {
+	m := setupModel(t)
+	newM, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'+'}})
+	m = newM.(Model)
+	if !strings.Contains(m.renderStatusBar(), "can't expand") {
+		t.Error("expected error message without a repository")
+	}
+}
+
+func TestEditorArgs(t *testing.T) {
+	tests := []struct {
+		editor string
+		line   int
+		want   []string
+	}{
+		{"vim", 12, []string{"vim", "+12", "main.go"}},
+		{"nvim", 3, []string{"nvim", "+3", "main.go"}},
+		{"code --wait", 12, []string{"code", "--wait", "--goto", "main.go:12"}},
+		{"/usr/local/bin/subl", 5, []string{"/usr/local/bin/subl", "main.go:5"}},
+		{"", 7, []string{"vi", "+7", "main.go"}},
+		{"vim", 0, []string{"vim", "main.go"}},
+	}
+	for _, tt := range tests {
+		got := editorArgs(tt.editor, "main.go", tt.line)
+		if strings.Join(got, " ") != strings.Join(tt.want, " ") {
+			t.Errorf("editorArgs(%q, %d) = %q, want %q", tt.editor, tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestEditorKeyReturnsExecCmd(t *testing.T) {
+	m := setupModel(t)
+	m.scrollOffset = 1
+	_, cmd := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'e'}})
+	if cmd == nil {
+		t.Fatal("expected a command to run the editor")
+	}
+
+	newM, _ := m.Update(editorFinishedMsg{err: fmt.Errorf("exit status 1")})
+	m = newM.(Model)
+	if !strings.Contains(m.renderStatusBar(), "editor: exit status 1") {
+		t.Error("expected editor error in status bar")
+	}
+	if m.scrollOffset != 1 {
+		t.Error("expected review state to survive the editor")
+	}
+}
+
+func TestFindingsPanel(t *testing.T) {
+	ds, err := diff.Parse(testDiff)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	ar := &analysis.Results{Findings: []analysis.Finding{
+		{Pass: "anti_patterns", File: "main.go", Line: 5, Message: "debug print", Risk: model.RiskLow},
+		{Pass: "security", File: "util.go", Line: 4, Message: "unchecked math", Risk: model.RiskHigh},
+	}}
+	m := New(ds, nil, ar)
+	newM, _ := m.Update(tea.WindowSizeMsg{Width: 120, Height: 40})
+	m = newM.(Model)
+
+	newM, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'!'}})
+	m = newM.(Model)
+	if !m.showFindings {
+		t.Fatal("expected findings panel to open on !")
+	}
+	view := m.View()
+	if !strings.Contains(view, "Findings (2)") {
+		t.Error("expected findings panel header")
+	}
+	// Highest risk is listed first
+	if strings.Index(view, "unchecked math") > strings.Index(view, "debug print") {
+		t.Error("expected findings sorted by risk")
+	}
+
+	newM, _ = m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	m = newM.(Model)
+	if m.showFindings {
+		t.Error("expected panel to close after jumping")
+	}
+	if m.fileIndex != 1 {
+		t.Errorf("expected jump to util.go, got file %d", m.fileIndex)
+	}
+	if rl := m.lines[m.scrollOffset]; rl.NewNum != 4 {
+		t.Errorf("expected viewport at line 4, got %+v", rl)
+	}
+
+	// f and F still step through the findings in the diff
+	newM, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'f'}})
+	m = newM.(Model)
+	if m.showFindings || !m.lines[m.scrollOffset].IsFinding {
+		t.Errorf("expected f to jump to the next finding, got %+v", m.lines[m.scrollOffset])
+	}
+}
+
+func TestGroupsPanel(t *testing.T) {
+	m := setupModel(t)
+
+	newM, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'g'}})
+	m = newM.(Model)
+	if !m.showGroups {
+		t.Fatal("expected groups panel to open on g")
+	}
+	view := m.View()
+	if !strings.Contains(view, "Change Groups (1)") || !strings.Contains(view, "(2 files)") {
+		t.Errorf("expected both files in one group, got:\n%s", view)
+	}
+
+	newM, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'a'}})
+	m = newM.(Model)
+	if approved, _, _, pending := m.DecisionCounts(); approved != 2 || pending != 0 {
+		t.Errorf("expected both files approved with the group, got %d approved %d pending", approved, pending)
+	}
+	if m.groups[0].Decision != model.DecisionApproved {
+		t.Errorf("expected the group approved, got %v", m.groups[0].Decision)
+	}
+
+	newM, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'u'}})
+	m = newM.(Model)
+	if approved, _, _, _ := m.DecisionCounts(); approved != 0 {
+		t.Errorf("expected undo to revert the group decision, got %d approved", approved)
+	}
+	if m.groups[0].Decision != model.DecisionPending {
+		t.Errorf("expected the group pending after undo, got %v", m.groups[0].Decision)
+	}
+
+	newM, _ = m.Update(tea.KeyMsg{Type: tea.KeyEsc})
+	m = newM.(Model)
+	if m.showGroups {
+		t.Error("expected esc to close the panel")
+	}
+}
+
+func TestFileSort(t *testing.T) {
+	m := setupModel(t)
+
+	// diff -> risk -> size
+	for i := 0; i < 2; i++ {
+		newM, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'s'}})
+		m = newM.(Model)
+	}
+	if m.sortMode != sortSize {
+		t.Fatalf("expected size sort, got %s", m.sortMode)
+	}
+	if got := m.visibleFiles(); got[0] != 1 || got[1] != 0 {
+		t.Errorf("expected util.go (5 lines) before main.go (3 lines), got %v", got)
+	}
+	if !strings.Contains(m.renderStatusBar(), "sort:size") {
+		t.Error("expected sort mode in status bar")
+	}
+
+	// Navigation follows list order: util.go is first, main.go second
+	m.selectFile(1)
+	newM, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'n'}})
+	m = newM.(Model)
+	if m.fileIndex != 0 {
+		t.Errorf("expected next file in sorted order to be main.go, got %d", m.fileIndex)
+	}
+
+	m.sortMode = sortPath
+	if got := m.visibleFiles(); got[0] != 0 {
+		t.Errorf("expected main.go first by path, got %v", got)
+	}
+}
+
+func countFolds(lines []renderedLine) int {
+	n := 0
+	for _, rl := range lines {
+		if rl.IsFold {
+			n++
+		}
+	}
+	return n
+}
+
+func TestFoldHunk(t *testing.T) {
+	m := setupModel(t)
+
+	newM, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'z'}})
+	m = newM.(Model)
+	if countFolds(m.lines) != 1 || len(codeLineNums(m.lines)) != 0 {
+		t.Fatalf("expected hunk folded to a placeholder, got %d folds", countFolds(m.lines))
+	}
+	if !strings.Contains(m.View(), "lines folded") {
+		t.Error("expected fold placeholder in view")
+	}
+
+	newM, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'z'}})
+	m = newM.(Model)
+	if countFolds(m.lines) != 0 {
+		t.Error("expected hunk unfolded")
+	}
+}
+
+func TestDecidedFilesFold(t *testing.T) {
+	m := setupModel(t)
+	m.selectFile(1)
+
+	// Last file: approving stays put and collapses it
+	newM, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'a'}})
+	m = newM.(Model)
+	if countFolds(m.lines) != 1 {
+		t.Fatal("expected decided file to be folded")
+	}
+
+	// The reviewer can still unfold it
+	newM, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'z'}})
+	m = newM.(Model)
+	if countFolds(m.lines) != 0 {
+		t.Error("expected explicit unfold to win over decision")
+	}
+}
+
+func TestWhitespaceOnlyHunks(t *testing.T) {
+	raw := `diff --git a/fmt.go b/fmt.go
+--- a/fmt.go
++++ b/fmt.go
+@@ -1,3 +1,3 @@
+ func f() {
+-return  1
++	return 1
+ }
+diff --git a/real.go b/real.go
+--- a/real.go
++++ b/real.go
+@@ -1,3 +1,3 @@
+ func g() {
+-	return 1
++	return 2
+ }
+`
+	ds, err := diff.Parse(raw)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	newM, _ := New(ds, nil, nil).Update(tea.WindowSizeMsg{Width: 120, Height: 40})
+	m := newM.(Model)
+
+	if countFolds(m.lines) != 1 || !strings.Contains(m.View(), "whitespace-only") {
+		t.Fatal("expected the whitespace-only hunk to start folded")
+	}
+	m.selectFile(1)
+	if countFolds(m.lines) != 0 {
+		t.Error("expected a real change to stay unfolded")
+	}
+
+	newM, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'W'}})
+	m = newM.(Model)
+	if m.decisions.Files[0] != model.DecisionApproved {
+		t.Error("expected the whitespace-only file to be approved")
+	}
+	if _, decided := m.decisions.Files[1]; decided {
+		t.Error("expected the real change to stay undecided")
+	}
+
+	newM, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'u'}})
+	m = newM.(Model)
+	if _, decided := m.decisions.Files[0]; decided {
+		t.Error("expected undo to revert the bulk approval")
+	}
+}
+
+func TestGeneratedFilesCollapse(t *testing.T) {
+	raw := `diff --git a/go.sum b/go.sum
+--- a/go.sum
++++ b/go.sum
+@@ -1,2 +1,2 @@
+-example.com/a v1.0.0 h1:old=
++example.com/a v1.1.0 h1:new=
+ example.com/b v0.2.0 h1:same=
+diff --git a/main.go b/main.go
+--- a/main.go
++++ b/main.go
+@@ -1,1 +1,1 @@
+-package old
++package main
+`
+	ds, err := diff.Parse(raw)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	newM, _ := New(ds, nil, nil).Update(tea.WindowSizeMsg{Width: 120, Height: 40})
+	m := newM.(Model)
+
+	if len(m.lines) != 1 || countFolds(m.lines) != 1 || !strings.Contains(m.View(), "go.sum: 2 entries changed") {
+		t.Fatalf("expected go.sum collapsed to its summary, got %d lines", len(m.lines))
+	}
+
+	newM, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'z'}})
+	m = newM.(Model)
+	if countFolds(m.lines) != 0 || len(codeLineNums(m.lines)) == 0 {
+		t.Error("expected z to show the lockfile's diff")
+	}
+
+	m.selectFile(1)
+	if countFolds(m.lines) != 0 {
+		t.Error("expected an ordinary file to stay expanded")
+	}
+}
+
+func TestDependencyUpdateSummary(t *testing.T) {
+	raw := `diff --git a/go.mod b/go.mod
+--- a/go.mod
++++ b/go.mod
+@@ -3,3 +3,3 @@
+ require (
+-	github.com/foo/bar v1.2.0
++	github.com/foo/bar v1.3.0
+ )
+diff --git a/go.sum b/go.sum
+--- a/go.sum
++++ b/go.sum
+@@ -1,1 +1,1 @@
+-github.com/foo/bar v1.2.0 h1:old=
++github.com/foo/bar v1.3.0 h1:new=
+`
+	ds, err := diff.Parse(raw)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	newM, _ := New(ds, nil, nil).Update(tea.WindowSizeMsg{Width: 120, Height: 40})
+	m := newM.(Model)
+	summary := func() []string {
+		var out []string
+		for _, rl := range m.lines {
+			if rl.IsSemantic {
+				out = append(out, strings.TrimSpace(rl.Content))
+			}
+		}
+		return out
+	}
+
+	want := []string{"⇡ dependency update: 1 package(s)", "github.com/foo/bar v1.2.0 → v1.3.0 (minor) · vulnerabilities: ", "https://github.com/foo/bar/compare/v1.2.0...v1.3.0"}
+	got := summary()
+	if len(got) != 3 || got[0] != want[0] || !strings.HasPrefix(got[1], want[1]) || got[2] != want[2] {
+		t.Errorf("summary = %q", got)
+	}
+	if !strings.Contains(m.renderStatusBar(), "[dependency update]") {
+		t.Error("expected dependency update indicator in status bar")
+	}
+
+	// The collapsed lockfile has the summary too
+	m.selectFile(1)
+	if got := summary(); len(got) != 3 || countFolds(m.lines) != 1 {
+		t.Errorf("go.sum summary = %q", got)
+	}
+}
+
+func TestLineEndingMarkers(t *testing.T) {
+	raw := "diff --git a/a.txt b/a.txt\n--- a/a.txt\n+++ b/a.txt\n@@ -1,3 +1,3 @@\n" +
+		"-one\r\n+one\n" +
+		"-t\xe9st\n+test\n" +
+		" end\n\\ No newline at end of file\n"
+	ds, err := diff.Parse(raw)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	newM, _ := New(ds, nil, nil).Update(tea.WindowSizeMsg{Width: 120, Height: 40})
+	m := newM.(Model)
+
+	view := m.View()
+	for _, want := range []string{"␍␊ CRLF", "one ␊ LF", "no newline at end of file", "tést"} {
+		if !strings.Contains(view, want) {
+			t.Errorf("expected %q in view", want)
+		}
+	}
+	shown := 0
+	for _, rl := range m.lines {
+		if rl.ShowEnding {
+			shown++
+		}
+	}
+	if shown != 3 {
+		t.Errorf("expected endings shown on the CRLF pair and the last line, got %d", shown)
+	}
+}
+
+func TestMovedCode(t *testing.T) {
+	raw := `diff --git a/old.go b/old.go
+--- a/old.go
++++ b/old.go
+@@ -1,7 +1,2 @@
+ package old
+-func helper(a, b int) int {
+-	c := a + b
+-	return c
+-}
+-func removed() {}
+ func keep() {}
+diff --git a/new.go b/new.go
+--- a/new.go
++++ b/new.go
+@@ -1,1 +1,5 @@
+ package new
++func helper(a, b int) int {
++	c := a + b
++	return c
++}
+`
+	ds, err := diff.Parse(raw)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	m := New(ds, nil, nil)
+
+	var notes, moved int
+	for _, rl := range m.lines {
+		if rl.IsMove {
+			notes++
+			if !strings.Contains(rl.Content, "moved to new.go:2-5") {
+				t.Errorf("unexpected move note %q", rl.Content)
+			}
+		}
+		if rl.Moved {
+			moved++
+		}
+		if rl.Content == "func removed() {}" && rl.Moved {
+			t.Error("expected the really deleted line not to be marked moved")
+		}
+	}
+	if notes != 1 || moved != 4 {
+		t.Errorf("expected one note over 4 moved lines, got %d notes and %d moved lines", notes, moved)
+	}
+
+	m.selectFile(1)
+	if !strings.Contains(m.lines[2].Content, "moved from old.go:2-5") {
+		t.Errorf("expected a move note above the added block, got %q", m.lines[2].Content)
+	}
+}
+
+func TestFoldContextRuns(t *testing.T) {
+	var b strings.Builder
+	b.WriteString("diff --git a/a.txt b/a.txt\n--- a/a.txt\n+++ b/a.txt\n@@ -1,14 +1,14 @@\n")
+	b.WriteString("-old first\n+new first\n")
+	for i := 2; i <= 13; i++ {
+		fmt.Fprintf(&b, " same %d\n", i)
+	}
+	b.WriteString("-old last\n+new last\n")
+
+	ds, err := diff.Parse(b.String())
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	m := New(ds, nil, nil)
+
+	if countFolds(m.lines) != 1 {
+		t.Fatalf("expected the 12-line context run to be folded, got %d folds", countFolds(m.lines))
+	}
+	// Two added lines plus the kept context on each side of the fold
+	if got := len(codeLineNums(m.lines)); got != 2+2*foldContextKeep {
+		t.Errorf("expected %d visible new-side lines, got %d", 2+2*foldContextKeep, got)
+	}
+
+	newM, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'Z'}})
+	m = newM.(Model)
+	if countFolds(m.lines) != 0 {
+		t.Error("expected Z to disable context folding")
+	}
+}
+
+func TestOSC52(t *testing.T) {
+	if got := osc52("hi", false); got != "\x1b]52;c;aGk=\x07" {
+		t.Errorf("unexpected sequence %q", got)
+	}
+	if got := osc52("hi", true); !strings.HasPrefix(got, "\x1bPtmux;") || !strings.HasSuffix(got, "\x1b\\") {
+		t.Errorf("expected tmux passthrough wrapping, got %q", got)
+	}
+}
+
+func TestCopyHunk(t *testing.T) {
+	t.Setenv("TMUX", "")
+	var out strings.Builder
+	clipboardOut = &out
+	defer func() { clipboardOut = os.Stdout }()
+
+	m := setupModel(t)
+	newM, cmd := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'y'}})
+	m = newM.(Model)
+	if cmd == nil {
+		t.Fatal("expected copy command")
+	}
+	cmd()
+
+	seq := out.String()
+	encoded := strings.TrimSuffix(strings.TrimPrefix(seq, "\x1b]52;c;"), "\x07")
+	decoded, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		t.Fatalf("bad base64 in %q: %v", seq, err)
+	}
+	patch := string(decoded)
+	if !strings.Contains(patch, "+++ b/main.go") || !strings.Contains(patch, "+\tprintln(\"goodbye\")") {
+		t.Errorf("expected main.go hunk patch, got:\n%s", patch)
+	}
+	if !strings.Contains(m.renderStatusBar(), "copied hunk 1/1") {
+		t.Error("expected copy confirmation in status bar")
+	}
+}
+
+func TestWatchReloadPreservesDecisions(t *testing.T) {
+	m := setupModel(t)
+	m.decisions.Files[0] = model.DecisionApproved
+	m.decisions.Files[1] = model.DecisionRejected
+	m.comments = []model.Comment{
+		{File: "main.go", Line: 4, Body: "keep"},
+		{File: "util.go", Line: 3, Body: "also keep"},
+	}
+
+	// util.go changes, main.go is untouched
+	changed := strings.Replace(testDiff, "return a + b", "return a - b", 1)
+	ds, err := diff.Parse(changed)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	reloaded := false
+	m.reload = func(raw string) (*diff.DiffSet, *analysis.Results, error) {
+		if raw != testDiff {
+			t.Errorf("expected reload to be given the current diff")
+		}
+		reloaded = true
+		return ds, nil, nil
+	}
+
+	newM, cmd := m.Update(watchTickMsg{})
+	m = newM.(Model)
+	newM, next := m.Update(cmd())
+	m = newM.(Model)
+	if !reloaded {
+		t.Fatal("expected reload to be polled")
+	}
+	if next == nil {
+		t.Error("expected watch to keep polling")
+	}
+
+	if m.decisions.Files[0] != model.DecisionApproved {
+		t.Error("expected decision on unchanged main.go to survive reload")
+	}
+	if _, ok := m.decisions.Files[1]; ok {
+		t.Error("expected decision on changed util.go to be reset")
+	}
+	if len(m.comments) != 2 {
+		t.Errorf("expected comments kept for files still present, got %d", len(m.comments))
+	}
+	if !strings.Contains(m.renderStatusBar(), "diff reloaded") {
+		t.Error("expected reload message")
+	}
+}
+
+func TestTraceTimeline(t *testing.T) {
+	ds, err := diff.Parse(testDiff)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	start := time.Date(2025, 1, 1, 10, 0, 0, 0, time.UTC)
+	tr := &trace.Trace{
+		Source: "claude-code",
+		Steps: []trace.Step{
+			{Type: trace.StepReasoning, Timestamp: start, Summary: "Plan the helper"},
+			{Type: trace.StepFileEdit, Timestamp: start.Add(time.Minute), Summary: "Edit util.go", FilePath: "/repo/util.go", LineStart: 3, LineEnd: 4},
+			{Type: trace.StepBash, Timestamp: start.Add(3 * time.Minute), Summary: "go test ./..."},
+		},
+	}
+	m := New(ds, tr, nil)
+	newM, _ := m.Update(tea.WindowSizeMsg{Width: 120, Height: 40})
+	m = newM.(Model)
+
+	newM, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'T'}})
+	m = newM.(Model)
+	if !m.showTimeline {
+		t.Fatal("expected timeline to open on T")
+	}
+
+	// Timestamps place the steps proportionally on the axis
+	if col := m.timelineColumn(1, 91); col != 30 {
+		t.Errorf("expected step 2 at a third of the axis, got column %d", col)
+	}
+
+	newM, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'l'}})
+	m = newM.(Model)
+	view := m.View()
+	if !strings.Contains(view, "Step 2/3") {
+		t.Error("expected cursor on step 2")
+	}
+	if !strings.Contains(view, "● util.go  @@ -0,0 +1,5 @@") {
+		t.Errorf("expected util.go and its hunk highlighted, got:\n%s", view)
+	}
+
+	newM, _ = m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	m = newM.(Model)
+	if m.showTimeline || m.fileIndex != 1 {
+		t.Errorf("expected jump to util.go, got file %d (timeline open: %v)", m.fileIndex, m.showTimeline)
+	}
+}
+
+func TestCommitNavigation(t *testing.T) {
+	m := setupModel(t)
+	parts := strings.SplitAfter(testDiff, " }\n")
+	first, err := diff.Parse(parts[0])
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	second, err := diff.Parse(parts[1])
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	m.commits = []diff.Commit{
+		{Hash: "1111111111111111", Author: "A <a@example.com>", Subject: "Say goodbye", Diff: first},
+		{Hash: "2222222222222222", Author: "B <b@example.com>", Subject: "Add util", Diff: second},
+	}
+
+	next := tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'>'}}
+	prev := tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'<'}}
+
+	newM, _ := m.Update(next)
+	m = newM.(Model)
+	if m.commitIndex != 0 || len(m.diffSet.Files) != 1 || m.diffSet.Files[0].Name() != "main.go" {
+		t.Fatalf("expected first commit with main.go, got index %d", m.commitIndex)
+	}
+	view := m.View()
+	if !strings.Contains(view, "commit 1111111") || !strings.Contains(view, "Say goodbye") {
+		t.Error("expected commit header in view")
+	}
+
+	// Decide in the second commit; the decision carries back to the range.
+	newM, _ = m.Update(next)
+	m = newM.(Model)
+	if m.diffSet.Files[0].Name() != "util.go" {
+		t.Fatalf("expected util.go in second commit, got %s", m.diffSet.Files[0].Name())
+	}
+	newM, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'a'}})
+	m = newM.(Model)
+
+	for i := 0; i < 3; i++ {
+		newM, _ = m.Update(prev)
+		m = newM.(Model)
+	}
+	if m.commitIndex != -1 || len(m.diffSet.Files) != 2 {
+		t.Fatalf("expected whole range, got index %d", m.commitIndex)
+	}
+	if m.decisions.Files[1] != model.DecisionApproved {
+		t.Errorf("expected util.go approved in range view, got %v", m.decisions.Files[1])
+	}
+	if m.decisions.Files[0] != model.DecisionPending {
+		t.Errorf("expected main.go pending, got %v", m.decisions.Files[0])
+	}
+}
+
+func TestBinaryImagePreview(t *testing.T) {
+	dir := t.TempDir()
+	img := image.NewNRGBA(image.Rect(0, 0, 200, 100))
+	for x := 0; x < 200; x++ {
+		for y := 0; y < 100; y++ {
+			img.Set(x, y, color.NRGBA{R: uint8(x), G: uint8(y), B: 128, A: 255})
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "logo.png"), buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	ds := &diff.DiffSet{Files: []*diff.File{{NewName: "logo.png", IsNew: true, IsBinary: true}}}
+	m := New(ds, nil, nil)
+	m.repoDir = dir
+	m.updateLines()
+	newM, _ := m.Update(tea.WindowSizeMsg{Width: 120, Height: 40})
+	m = newM.(Model)
+
+	var text []string
+	thumbRows := 0
+	for _, rl := range m.lines {
+		if strings.Contains(rl.Content, "▀") {
+			thumbRows++
+		} else {
+			text = append(text, rl.Content)
+		}
+	}
+	joined := strings.Join(text, "\n")
+	if !strings.Contains(joined, "old: —  new: "+formatBytes(buf.Len())) {
+		t.Errorf("expected sizes line, got:\n%s", joined)
+	}
+	if !strings.Contains(joined, "PNG image, 200×100") {
+		t.Errorf("expected image description, got:\n%s", joined)
+	}
+	// 200×100 fits 64 columns at 32 pixel rows, two per cell
+	if thumbRows != 16 {
+		t.Errorf("expected 16 thumbnail rows, got %d", thumbRows)
+	}
+	if !strings.Contains(m.View(), "▀") {
+		t.Error("expected thumbnail in view")
+	}
+}
+
+func TestSubmodulePreview(t *testing.T) {
+	ds := &diff.DiffSet{Files: []*diff.File{{
+		OldName: "lib", NewName: "lib", IsSubmodule: true, Dirty: true,
+		OldCommit: "f0163751f6e5426c5d700848e735d09a79f11dc0",
+		NewCommit: "5f6b99566e8141c66ab73afae25f966fded1fff6",
+	}}}
+	m := New(ds, nil, nil)
+
+	var text []string
+	for _, rl := range m.lines {
+		text = append(text, rl.Content)
+	}
+	joined := strings.Join(text, "\n")
+	for _, want := range []string{"Submodule", "f016375 → 5f6b995", "uncommitted changes", "can't list commits"} {
+		if !strings.Contains(joined, want) {
+			t.Errorf("expected %q in preview, got:\n%s", want, joined)
+		}
+	}
+}
+
+func TestFormatBytes(t *testing.T) {
+	for n, want := range map[int]string{0: "0 B", 1023: "1023 B", 1536: "1.5 KB", 3 << 20: "3.0 MB"} {
+		if got := formatBytes(n); got != want {
+			t.Errorf("formatBytes(%d) = %q, want %q", n, got, want)
+		}
+	}
+}
+
+func TestRenderMarkdown(t *testing.T) {
+	src := "# Plan\n\nUpdate **main.go** to print `goodbye`,\nthen run the tests.\n\n- first item\n- second item that is long enough to wrap onto another line\n\n```go\nfunc main() {}\n```\n\n> quoted"
+	lines := renderMarkdown(src, 40)
+	plain := strings.Join(lines, "\n")
+
+	for _, want := range []string{"Plan", "Update main.go to print goodbye, then", "• first item", "  wrap onto another line", " func main() {}", "│ quoted"} {
+		if !strings.Contains(plain, want) {
+			t.Errorf("expected %q in rendered markdown:\n%s", want, plain)
+		}
+	}
+	for _, unwanted := range []string{"#", "**", "`", "```"} {
+		if strings.Contains(plain, unwanted) {
+			t.Errorf("expected markup %q to be removed:\n%s", unwanted, plain)
+		}
+	}
+	for _, l := range lines {
+		if w := lipgloss.Width(l); w > 40 {
+			t.Errorf("line wider than 40 (%d): %q", w, l)
+		}
+	}
+}
+
+func TestStepDetailView(t *testing.T) {
+	ds, err := diff.Parse(testDiff)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	tr := &trace.Trace{
+		Source: "claude-code",
+		Steps: []trace.Step{
+			{Type: trace.StepReasoning, Summary: "Planning", Detail: "## Approach\n\n1. Change *greeting*\n2. Add `add` helper"},
+			{Type: trace.StepBash, Summary: "go test ./...", Command: "go test ./...", Detail: "ok\tpkg\t0.1s"},
+			{Type: trace.StepFileEdit, Summary: "Edit main.go", FilePath: "main.go", OldString: "println(\"hello\")", NewString: "println(\"hello world\")\nprintln(\"goodbye\")"},
+			{Type: trace.StepBash, Summary: "Run tests", Command: "go test ./...", ExitCode: 1, Output: "--- FAIL: TestMain\nFAIL"},
+		},
+	}
+	m := New(ds, tr, nil)
+	newM, _ := m.Update(tea.WindowSizeMsg{Width: 120, Height: 40})
+	m = newM.(Model)
+
+	// util.go has no steps of its own, so the panel lists the whole trace
+	for _, k := range []tea.KeyMsg{
+		{Type: tea.KeyRunes, Runes: []rune{'n'}},
+		{Type: tea.KeyRunes, Runes: []rune{'t'}},
+		{Type: tea.KeyTab},
+		{Type: tea.KeyEnter},
+	} {
+		newM, _ = m.Update(k)
+		m = newM.(Model)
+	}
+	if !m.showStepDetail || m.showSummary {
+		t.Fatal("expected enter on the focused trace to open step detail")
+	}
+	view := m.View()
+	if !strings.Contains(view, "Approach") || !strings.Contains(view, "1. Change greeting") || strings.Contains(view, "##") {
+		t.Errorf("expected rendered markdown in detail view:\n%s", view)
+	}
+
+	newM, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'l'}})
+	m = newM.(Model)
+	if view := m.View(); !strings.Contains(view, "$ go test ./...") || !strings.Contains(view, "ok    pkg") {
+		t.Errorf("expected bash step in detail view:\n%s", view)
+	}
+
+	newM, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'l'}})
+	m = newM.(Model)
+	view = m.View()
+	for _, want := range []string{`-println("hello")`, `+println("hello world")`, `+println("goodbye")`} {
+		if !strings.Contains(view, want) {
+			t.Errorf("expected %q in edit detail:\n%s", want, view)
+		}
+	}
+
+	newM, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'l'}})
+	m = newM.(Model)
+	view = m.View()
+	if !strings.Contains(view, "exit status 1") || !strings.Contains(view, "--- FAIL: TestMain") {
+		t.Errorf("expected command output in bash detail:\n%s", view)
+	}
+
+	newM, _ = m.Update(tea.KeyMsg{Type: tea.KeyEsc})
+	m = newM.(Model)
+	if m.showStepDetail {
+		t.Error("expected esc to close step detail")
+	}
+}
+
+func TestUndoRedoHistory(t *testing.T) {
+	m := setupModel(t)
+	press := func(msg tea.KeyMsg) {
+		t.Helper()
+		newM, _ := m.Update(msg)
+		m = newM.(Model)
+	}
+	runes := func(s string) tea.KeyMsg { return tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(s)} }
+
+	// Approve both files, then comment on the second
+	press(runes("a"))
+	press(runes("a"))
+	press(runes("c"))
+	m.commentInput.SetValue("needs a doc comment")
+	press(tea.KeyMsg{Type: tea.KeyEnter})
+	if len(m.comments) != 1 || len(m.decisions.Files) != 2 {
+		t.Fatalf("setup failed: %d comments, %d decisions", len(m.comments), len(m.decisions.Files))
+	}
+
+	// Undo steps back one action at a time
+	press(runes("u"))
+	if len(m.comments) != 0 || len(m.decisions.Files) != 2 {
+		t.Errorf("expected comment undone first, got %d comments, %d decisions", len(m.comments), len(m.decisions.Files))
+	}
+	press(runes("u"))
+	if _, ok := m.decisions.Files[1]; ok || m.decisions.Files[0] != model.DecisionApproved {
+		t.Errorf("expected second approval undone, got %v", m.decisions.Files)
+	}
+	if m.fileIndex != 1 {
+		t.Errorf("expected undo to jump to util.go, got file %d", m.fileIndex)
+	}
+	press(runes("u"))
+	press(runes("u"))
+	if len(m.decisions.Files) != 0 || !strings.Contains(m.message, "nothing to undo") {
+		t.Errorf("expected empty history, got %v (%q)", m.decisions.Files, m.message)
+	}
+
+	// Redo replays them in order
+	press(tea.KeyMsg{Type: tea.KeyCtrlR})
+	press(tea.KeyMsg{Type: tea.KeyCtrlR})
+	if len(m.decisions.Files) != 2 || len(m.comments) != 0 {
+		t.Errorf("expected both approvals redone, got %v", m.decisions.Files)
+	}
+
+	// A new action clears the redo stack
+	press(runes("x"))
+	press(tea.KeyMsg{Type: tea.KeyEsc}) // no note
+	press(tea.KeyMsg{Type: tea.KeyCtrlR})
+	if len(m.comments) != 0 || !strings.Contains(m.message, "nothing to redo") {
+		t.Errorf("expected redo history cleared, got %d comments (%q)", len(m.comments), m.message)
+	}
+}
+
+func TestQueueMode(t *testing.T) {
+	ds, err := diff.Parse(testDiff)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	ar := &analysis.Results{Findings: []analysis.Finding{
+		{Pass: "security", File: "util.go", Line: 3, Message: "risky", Risk: model.RiskHigh},
+	}}
+	m := New(ds, nil, ar)
+	newM, _ := m.Update(tea.WindowSizeMsg{Width: 120, Height: 40})
+	m = newM.(Model)
+
+	newM, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'Q'}})
+	m = newM.(Model)
+	if !m.queueMode || m.fileIndex != 1 {
+		t.Fatalf("expected queue mode to start at high-risk util.go, got file %d", m.fileIndex)
+	}
+	view := m.View()
+	if !strings.Contains(view, "Queue: 1 of 2, 1 high-risk remaining") {
+		t.Errorf("expected progress header, got:\n%s", view)
+	}
+	if strings.Contains(view, "main.go") {
+		t.Error("expected file list hidden in queue mode")
+	}
+	for _, line := range strings.Split(view, "\n") {
+		if w := lipgloss.Width(line); w > 120 {
+			t.Errorf("line wider than terminal (%d)", w)
+			break
+		}
+	}
+
+	// Approving advances to the next file in risk order
+	newM, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'a'}})
+	m = newM.(Model)
+	if m.fileIndex != 0 || !strings.Contains(m.View(), "Queue: 2 of 2, no high-risk remaining") {
+		t.Errorf("expected main.go second with no high-risk left, got file %d", m.fileIndex)
+	}
+
+	newM, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'a'}})
+	m = newM.(Model)
+	if !strings.Contains(m.View(), "queue complete") {
+		t.Error("expected queue complete notice")
+	}
+
+	newM, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'Q'}})
+	m = newM.(Model)
+	if m.queueMode || m.sortMode != sortDiff {
+		t.Errorf("expected leaving queue mode to restore sort, got %v", m.sortMode)
+	}
+}
+
+func TestPolicy(t *testing.T) {
+	ds, err := diff.Parse(testDiff)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	pol, err := policy.Parse([]byte(`rules:
+  - {name: review-main, action: require_approval, paths: [main.go]}
+  - {name: go, action: auto_approve, paths: ["*.go"]}
+`))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	m := New(ds, nil, nil)
+	m.policy = pol
+	m.autoApprove()
+	newM, _ := m.Update(tea.WindowSizeMsg{Width: 200, Height: 40})
+	m = newM.(Model)
+
+	// main.go needs approving by hand despite matching *.go
+	if m.fileDecided(0) || m.decisions.Files[1] != model.DecisionApproved || !m.decisions.Bulk[1] {
+		t.Errorf("expected only util.go approved automatically, got %v", m.decisions.Files)
+	}
+	if len(m.undoStack) != 0 {
+		t.Error("expected automatic approval kept off the undo stack")
+	}
+	if view := m.View(); !strings.Contains(view, "[policy review-main: requires approval]") {
+		t.Errorf("expected the policy hint in the header, got:\n%s", view)
+	}
+}
+
+func TestTriageFinding(t *testing.T) {
+	ds, err := diff.Parse(testDiff)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	ar := &analysis.Results{Findings: []analysis.Finding{
+		{Pass: "security", File: "util.go", Line: 3, Message: "risky", Risk: model.RiskHigh},
+	}}
+	m := New(ds, nil, ar)
+	m.triage = &triage.Store{Version: triage.Version}
+	newM, _ := m.Update(tea.WindowSizeMsg{Width: 120, Height: 40})
+	m = newM.(Model)
+
+	newM, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'!'}})
+	m = newM.(Model)
+	newM, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'x'}})
+	m = newM.(Model)
+	if !m.triaged || m.triage.State(ar.Findings[0].Fingerprint()) != model.FindingFalsePositive {
+		t.Fatalf("expected the finding recorded as a false positive, got %+v", m.triage.Findings)
+	}
+	if !strings.Contains(m.View(), "(false positive)") {
+		t.Errorf("expected the panel to label the finding, got:\n%s", m.View())
+	}
+
+	// Pressing it again reopens the finding
+	newM, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'x'}})
+	m = newM.(Model)
+	if len(m.triage.Findings) != 0 || ar.Findings[0].State != model.FindingOpen {
+		t.Errorf("expected the finding reopened, got %+v", m.triage.Findings)
+	}
+}
+
+func TestGeneratePatchApplies(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	dir := t.TempDir()
+	git := func(args ...string) string {
+		t.Helper()
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+		return string(out)
+	}
+	git("init", "-q")
+	if err := os.WriteFile(filepath.Join(dir, "main.go"), []byte("package main\n\nfunc main() {\n\tprintln(\"hello\")\n}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	git("add", "main.go")
+
+	ds, err := diff.Parse(testDiff)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	result := &ReviewResult{
+		Decisions: model.Decisions{Files: map[int]model.ReviewDecision{0: model.DecisionApproved, 1: model.DecisionApproved}},
+		Files:     ds.Files,
+		Comments:  []model.Comment{{File: "main.go", Line: 4, Body: "ok"}},
+	}
+	if err := diff.GitApply(dir, result.GeneratePatch(), "--cached"); err != nil {
+		t.Fatalf("generated patch did not apply: %v", err)
+	}
+
+	staged := git("diff", "--cached", "--name-only")
+	if !strings.Contains(staged, "util.go") {
+		t.Errorf("expected new file staged, got %q", staged)
+	}
+	if content := git("show", ":main.go"); !strings.Contains(content, "goodbye") {
+		t.Errorf("expected main.go change staged, got %q", content)
+	}
+}
+
+func TestGeneratePatchModesAndCopies(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	ds, err := diff.Parse(`diff --git a/run.sh b/run.sh
+old mode 100644
+new mode 100755
+diff --git a/new.sh b/new.sh
+new file mode 100755
+--- /dev/null
++++ b/new.sh
+@@ -0,0 +1 @@
++echo new
+diff --git a/a.txt b/c.txt
+similarity index 80%
+copy from a.txt
+copy to c.txt
+--- a/a.txt
++++ b/c.txt
+@@ -1,3 +1,3 @@
+ x
+-y
++why
+ z
+`)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	result := &ReviewResult{
+		Decisions: model.Decisions{Files: map[int]model.ReviewDecision{0: model.DecisionApproved, 1: model.DecisionApproved, 2: model.DecisionApproved}},
+		Files:     ds.Files,
+	}
+	patch := result.GeneratePatch()
+
+	// Both git and agrev apply it, with the modes and the copy
+	for _, useGit := range []bool{true, false} {
+		dir := t.TempDir()
+		os.WriteFile(filepath.Join(dir, "run.sh"), []byte("echo hi\n"), 0o644)
+		os.WriteFile(filepath.Join(dir, "a.txt"), []byte("x\ny\nz\n"), 0o644)
+		if useGit {
+			if err := diff.GitApply(dir, patch, "--check"); err != nil {
+				t.Fatalf("git apply --check: %v\n%s", err, patch)
+			}
+			if err := diff.GitApply(dir, patch); err != nil {
+				t.Fatalf("git apply: %v\n%s", err, patch)
+			}
+		} else {
+			patched, err := diff.Parse(patch)
+			if err != nil {
+				t.Fatalf("parsing the patch: %v", err)
+			}
+			if _, err := apply.Apply(dir, patched.Files, apply.Options{}); err != nil {
+				t.Fatalf("apply.Apply: %v\n%s", err, patch)
+			}
+		}
+		for name, want := range map[string]os.FileMode{"run.sh": 0o755, "new.sh": 0o755, "a.txt": 0o644, "c.txt": 0o644} {
+			if info, err := os.Stat(filepath.Join(dir, name)); err != nil || info.Mode().Perm() != want {
+				t.Errorf("git %v: %s has mode %v (%v), want %v", useGit, name, info.Mode().Perm(), err, want)
+			}
+		}
+		if data, _ := os.ReadFile(filepath.Join(dir, "c.txt")); string(data) != "x\nwhy\nz\n" {
+			t.Errorf("git %v: copy is %q", useGit, data)
+		}
+		if data, _ := os.ReadFile(filepath.Join(dir, "a.txt")); string(data) != "x\ny\nz\n" {
+			t.Errorf("git %v: original of the copy is %q", useGit, data)
+		}
+	}
+}
+
+func TestExplainHunk(t *testing.T) {
+	m := setupModel(t)
+	press := func(m Model, r rune) (Model, tea.Cmd) {
+		newM, cmd := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{r}})
+		return newM.(Model), cmd
+	}
+
+	// Off unless configured
+	m, cmd := press(m, 'E')
+	if cmd != nil || !strings.Contains(m.message, "explain.endpoint") {
+		t.Errorf("expected a hint to configure explain, got %q", m.message)
+	}
+
+	var got explain.Request
+	m.explain = func(r explain.Request) (string, error) {
+		got = r
+		return "This change **prints goodbye**.", nil
+	}
+	m, cmd = press(m, 'E')
+	if cmd == nil || !m.explaining {
+		t.Fatal("expected an explain command")
+	}
+	newM, _ := m.Update(cmd())
+	m = newM.(Model)
+
+	if got.File != "main.go" || !strings.Contains(got.Hunk, "+\tprintln(\"goodbye\")") {
+		t.Errorf("expected the main.go hunk to be sent, got %+v", got)
+	}
+	if !m.showExplain || m.explaining {
+		t.Fatal("expected the explanation view to open")
+	}
+	if view := m.View(); !strings.Contains(view, "prints goodbye") || !strings.Contains(view, "main.go  hunk 1/1") {
+		t.Errorf("expected explanation in view, got:\n%s", view)
+	}
+
+	m, _ = press(m, 'q')
+	if m.showExplain {
+		t.Error("expected q to close the explanation")
+	}
+}
+
+func TestAnnotations(t *testing.T) {
+	ds, err := diff.Parse(testDiff)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	tr := &trace.Trace{Steps: []trace.Step{
+		{Type: trace.StepReasoning, Summary: "Say goodbye too"},
+		{Type: trace.StepFileEdit, Summary: "Edit main.go", FilePath: "main.go", NewString: "\tprintln(\"hello world\")\n\tprintln(\"goodbye\")\n"},
+	}}
+	m := New(ds, tr, nil)
+	newM, _ := m.Update(tea.WindowSizeMsg{Width: 160, Height: 40})
+	m = newM.(Model)
+
+	var notes []string
+	at := -1
+	for i, rl := range m.lines {
+		if rl.IsAnnotation {
+			notes = append(notes, rl.Content)
+			if at < 0 {
+				at = i
+			}
+		}
+	}
+	want := []string{"  ⤷ step 2 (edit): Edit main.go", "    agent: Say goodbye too", "  ! edited without reading the file first"}
+	if strings.Join(notes, "|") != strings.Join(want, "|") {
+		t.Fatalf("annotations = %q, want %q", notes, want)
+	}
+	// Above the first line the edit wrote
+	if next := m.lines[at+len(want)]; next.NewNum != 4 {
+		t.Errorf("expected the notes above new line 4, got %+v", next)
+	}
+	if view := m.View(); !strings.Contains(view, "agent: Say goodbye too") {
+		t.Errorf("expected the reasoning in view, got:\n%s", view)
+	}
+}
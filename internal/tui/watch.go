@@ -0,0 +1,133 @@
+package tui
+
+import (
+	"fmt"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/aezell/agrev/internal/analysis"
+	"github.com/aezell/agrev/internal/diff"
+	"github.com/aezell/agrev/internal/model"
+)
+
+// watchInterval is how often watch mode polls for a changed diff.
+const watchInterval = time.Second
+
+type watchTickMsg struct{}
+
+// diffReloadedMsg carries the result of polling for a new diff. ds is nil if
+// the diff is unchanged.
+type diffReloadedMsg struct {
+	ds  *diff.DiffSet
+	ar  *analysis.Results
+	err error
+}
+
+func watchTickCmd() tea.Cmd {
+	return tea.Tick(watchInterval, func(time.Time) tea.Msg {
+		return watchTickMsg{}
+	})
+}
+
+// reloadCmd polls the reload function off the UI goroutine.
+func (m Model) reloadCmd() tea.Cmd {
+	reload, raw := m.reload, m.diffSet.Raw
+	return func() tea.Msg {
+		ds, ar, err := reload(raw)
+		return diffReloadedMsg{ds: ds, ar: ar, err: err}
+	}
+}
+
+// applyReload swaps in a new diff, carrying over decisions and view state for
+// files whose patch is unchanged. Comments are kept for files still present.
+func (m *Model) applyReload(ds *diff.DiffSet, ar *analysis.Results) {
+	type oldFile struct {
+		index int
+		patch string
+	}
+	old := make(map[string]oldFile, len(m.diffSet.Files))
+	for i, f := range m.diffSet.Files {
+		old[f.Name()] = oldFile{i, formatFilePatch(f)}
+	}
+	current := ""
+	if len(m.diffSet.Files) > 0 {
+		current = m.diffSet.Files[m.fileIndex].Name()
+	}
+
+	decisions := model.NewDecisions()
+	fileContent := make(map[int][]string)
+	extraContext := make(map[int]map[int]int)
+	wholeFileView := make(map[int]bool)
+	foldedHunks := make(map[int]map[int]bool)
+	present := make(map[string]bool, len(ds.Files))
+	newIndex := 0
+	for j, f := range ds.Files {
+		present[f.Name()] = true
+		if f.Name() == current {
+			newIndex = j
+		}
+		o, ok := old[f.Name()]
+		if !ok || o.patch != formatFilePatch(f) {
+			continue
+		}
+		if d, ok := m.decisions.Files[o.index]; ok {
+			decisions.Files[j] = d
+		}
+		if m.decisions.Bulk[o.index] {
+			decisions.Bulk[j] = true
+		}
+		if conds := m.decisions.Conditions[o.index]; len(conds) > 0 {
+			decisions.Conditions[j] = conds
+		}
+		for h := range f.Fragments {
+			if d, ok := m.decisions.Hunks[model.HunkKey{File: o.index, Hunk: h}]; ok {
+				decisions.SetHunk(j, h, d)
+			}
+		}
+		for h := model.WholeFile; h < len(f.Fragments); h++ {
+			decisions.SetNote(j, h, m.decisions.Note(o.index, h))
+			for _, l := range m.decisions.Label(o.index, h) {
+				decisions.AddLabel(j, h, l)
+			}
+		}
+		if c, ok := m.fileContent[o.index]; ok {
+			fileContent[j] = c
+		}
+		if e, ok := m.extraContext[o.index]; ok {
+			extraContext[j] = e
+		}
+		if w, ok := m.wholeFileView[o.index]; ok {
+			wholeFileView[j] = w
+		}
+		if h, ok := m.foldedHunks[o.index]; ok {
+			foldedHunks[j] = h
+		}
+	}
+
+	var comments []model.Comment
+	for _, c := range m.comments {
+		if present[c.File] {
+			comments = append(comments, c)
+		}
+	}
+
+	sameFile := newIndex < len(ds.Files) && len(ds.Files) > 0 && ds.Files[newIndex].Name() == current
+	scroll := m.scrollOffset
+
+	m.diffSet = ds
+	m.analysisResults = ar
+	m.decisions = decisions
+	m.fileContent = fileContent
+	m.extraContext = extraContext
+	m.wholeFileView = wholeFileView
+	m.foldedHunks = foldedHunks
+	m.comments = comments
+	m.searchMatches = m.findMatches(m.searchQuery)
+	m.searchIndex = 0
+
+	m.selectFile(newIndex)
+	if sameFile {
+		m.scrollOffset = min(scroll, max(len(m.lines)-1, 0))
+	}
+	m.message = fmt.Sprintf("diff reloaded: %d file(s)", len(ds.Files))
+}
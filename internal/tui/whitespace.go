@@ -0,0 +1,29 @@
+package tui
+
+import (
+	"fmt"
+
+	"github.com/aezell/agrev/internal/model"
+)
+
+// approveWhitespace approves every undecided file whose hunks only change
+// whitespace and returns the indexes of the files it approved. Agents often
+// reformat whole files alongside a real change; this clears that noise in
+// one step.
+func (m *Model) approveWhitespace() []int {
+	var pending []int
+	for i, f := range m.diffSet.Files {
+		if !m.fileDecided(i) && f.WhitespaceOnly() {
+			pending = append(pending, i)
+		}
+	}
+	if len(pending) == 0 {
+		return nil
+	}
+
+	m.record(fmt.Sprintf("approve %d whitespace-only files", len(pending)))
+	for _, i := range pending {
+		m.decideBulk(i, model.DecisionApproved, "whitespace only")
+	}
+	return pending
+}